@@ -32,6 +32,8 @@ export default function App() {
   const [llmArrows, setLLMArrows] = useState<Array<Array<string>>>([]);
   const [title, setTitle] = useState<string>("");
   const [userSide, setUserSide] = useState<Side>(Side.White);
+  // Names this conversation so the server can keep a summary of its older messages.
+  const [sessionId] = useState<string>(() => crypto.randomUUID());
   const MAX_RETRIES = 3;
   const chatContainerRef = useRef<HTMLDivElement>(null);
 
@@ -93,7 +95,8 @@ export default function App() {
   // Handle chat message
   async function sendMessage(newMessage: ChatMessage) {
     const payload = {
-      message_history: [...chatMessages, newMessage].slice(-10),
+      message_history: [...chatMessages, newMessage],
+      session_id: sessionId,
       game_state: {
         move_history: moveHistory,
         fen: game.fen()
@@ -102,7 +105,7 @@ export default function App() {
     }
     setIsLoading(true);
     try {
-      const response = await fetch("http://localhost:42069/chat", {
+      const response = await fetch("http://localhost:42069/v1/chat", {
         method: "POST",
         headers: {
           "Content-Type": "application/json"
@@ -153,7 +156,7 @@ export default function App() {
       console.log("sending to api attempt:", attempt, payload);
 
       try {
-        const response = await fetch("http://localhost:42069/generateMove", {
+        const response = await fetch("http://localhost:42069/v1/generateMove", {
           method: "POST",
           headers: {
             "Content-Type": "application/json",
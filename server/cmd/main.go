@@ -1,46 +1,387 @@
 package main
 
 import (
+	"arnavsurve/nara-chess/server/pkg/account"
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/auth"
+	"arnavsurve/nara-chess/server/pkg/cache"
+	"arnavsurve/nara-chess/server/pkg/chesscom"
+	"arnavsurve/nara-chess/server/pkg/config"
+	"arnavsurve/nara-chess/server/pkg/cors"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"arnavsurve/nara-chess/server/pkg/explorer"
 	"arnavsurve/nara-chess/server/pkg/handlers"
-	"log"
+	"arnavsurve/nara-chess/server/pkg/lichess"
+	"arnavsurve/nara-chess/server/pkg/llm"
+	"arnavsurve/nara-chess/server/pkg/logging"
+	"arnavsurve/nara-chess/server/pkg/metrics"
+	"arnavsurve/nara-chess/server/pkg/middleware"
+	"arnavsurve/nara-chess/server/pkg/ratelimit"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/tablebase"
+	"arnavsurve/nara-chess/server/pkg/tracing"
+	"arnavsurve/nara-chess/server/pkg/webhook"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
-	err := godotenv.Load()
+	if err := loadDotEnv(".env"); err != nil {
+		fatal("Error loading .env", "err", err)
+	}
+
+	settings, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatal("Error loading .env")
+		fatal("Invalid configuration", "err", err)
 	}
+	logging.Setup(os.Stderr, settings.LogFormat, settings.Debug)
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/generateMove", func(w http.ResponseWriter, r *http.Request) {
-		handlers.HandleGenerateMove(w, r)
-	})
-	mux.HandleFunc("/chat", func(w http.ResponseWriter, r *http.Request) {
-		handlers.HandleChatMessage(w, r)
-	})
+	cfg := handlers.DefaultConfig()
+	cfg.Temperature = settings.Temperature
+	cfg.Timeout = settings.RequestTimeout
+	cfg.MaxModelCalls = settings.MaxModelCalls
+	cfg.MaxMoveAttempts = settings.MaxMoveAttempts
+	cfg.ChatTokenBudget = settings.ChatTokenBudget
+	cfg.MoveCacheTTL = settings.MoveCacheTTL
+	cfg.AnalysisCacheTTL = settings.AnalysisCacheTTL
+	cfg.CorrectSideMismatch = settings.CorrectSideMismatch
+	cfg.LoginRedirect = settings.LoginRedirect
+	origins, err := cors.NewPolicy(settings.AllowedOrigins)
+	if err != nil {
+		fatal("Invalid configuration", "err", err)
+	}
+	origins.Headers = append(origins.Headers, handlers.ProviderHeader)
+	origins.Expose = append(ratelimit.HeaderNames("RateLimit"), ratelimit.HeaderNames(handlers.QuotaHeader)...)
+	origins.Expose = append(origins.Expose, "Retry-After")
+	origins.Credentials = settings.AllowCredentials
+	origins.MaxAge = settings.CORSMaxAge
+	cfg.Origins = origins
+	profiles, err := handlers.LoadProfiles(settings.ProfilesFile)
+	if err != nil {
+		fatal("Error loading generation profiles", "err", err)
+	}
+	cfg.Profiles = profiles
+	prices, err := ai.LoadPrices(settings.PricesFile)
+	if err != nil {
+		fatal("Error loading model prices", "err", err)
+	}
+	cfg.Prices = prices
 
-	muxCORS := CORSMiddleware(mux)
+	var coachProviders []llm.CoachProvider
+	for _, name := range settings.Providers {
+		p, models := newProvider(name, settings)
+		slog.Info("Provider configured", "provider", p.Name(), "models", strings.Join(models, " -> "))
+		// Providers holding a long-lived client set it up once here and share it across requests.
+		if c, ok := p.(interface{ Connect(context.Context) error }); ok {
+			if err := c.Connect(context.Background()); err != nil {
+				fatal("Error connecting to the model provider", "provider", p.Name(), "err", err)
+			}
+		}
+		if c, ok := p.(io.Closer); ok {
+			defer c.Close()
+		}
+		// Each model is retried after transient failures before the chain falls back, every
+		// attempt taking its turn for one of the provider's slots.
+		limiter := ai.NewLimiter(p, settings.ModelConcurrency, settings.ModelQueue)
+		retrier := ai.NewRetrier(limiter, settings.ModelRetries, settings.RetryBackoff)
+		chain := ai.NewModelChain(retrier, models, settings.AttemptTimeout)
+		coachProviders = append(coachProviders, ai.NewBreaker(chain, settings.BreakerThreshold, settings.BreakerCooldown))
+	}
+	provider, err := llm.NewRouter(coachProviders...)
+	if err != nil {
+		fatal("Error setting up the model providers", "err", err)
+	}
+	slog.Info("Model providers", "providers", strings.Join(provider.Names(), ", "))
+	pingCtx, cancelPing := context.WithTimeout(context.Background(), 10*time.Second)
+	for name, err := range provider.Health(pingCtx) {
+		if err != nil {
+			slog.Warn("Model provider failed its health check", "provider", name, "err", err)
+		}
+	}
+	cancelPing()
 
-	log.Println("Serving at 127.0.0.1:42069")
-	if err = http.ListenAndServe(":42069", muxCORS); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	cfg.HybridMoves = settings.HybridMoves
+	cfg.ExplorerPrompt = settings.ExplorerPrompt
+	var games store.GameStore = store.NewMemoryStore()
+	if path := settings.DBPath; path != "" {
+		db, err := store.OpenSQLite(path)
+		if err != nil {
+			fatal("Failed to open game database", "err", err)
+		}
+		defer db.Close()
+		games = db
+		slog.Info("Storing games in SQLite", "path", path)
+		if n, err := db.PruneEntries(time.Now()); err != nil {
+			slog.Error("Error pruning the response cache", "err", err)
+		} else if n > 0 {
+			slog.Info("Pruned expired responses from the cache", "count", n)
+		}
+	} else {
+		slog.Info("Storing games in memory (set NARA_DB_PATH to persist them)")
 	}
-}
 
-func CORSMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	// shared stays a nil interface without Redis, which keeps everything per instance.
+	var shared redis.UniversalClient
+	if url := settings.RedisURL; url != "" {
+		opts, err := redis.ParseURL(url)
+		if err != nil {
+			fatal("Invalid configuration", "err", err)
+		}
+		client := redis.NewClient(opts)
+		defer client.Close()
+		pingCtx, cancelPing := context.WithTimeout(context.Background(), 5*time.Second)
+		err = client.Ping(pingCtx).Err()
+		cancelPing()
+		if err != nil {
+			fatal("Failed to connect to Redis", "addr", opts.Addr, "err", err)
+		}
+		shared = client
+		cfg.ResponseCache = cache.NewRedis(client, "nara:cache:")
+		slog.Info("Sharing caches, rate limits and game broadcasts through Redis", "addr", opts.Addr)
+	} else {
+		slog.Info("Keeping caches, rate limits and game broadcasts to this instance (set NARA_REDIS_URL to share them)")
+	}
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
+	h := handlers.New(provider, games, cfg)
+	if shared != nil {
+		shareCtx, stopSharing := context.WithCancel(context.Background())
+		defer stopSharing()
+		if err := h.Sessions.Share(shareCtx, shared); err != nil {
+			fatal("Failed to subscribe to game broadcasts in Redis", "err", err)
+		}
+	}
+	if path := settings.StockfishPath; path != "" {
+		uci, err := engine.StartUCI(path, settings.StockfishMoveTime)
+		if err != nil {
+			fatal("Failed to start UCI engine", "err", err)
 		}
+		defer uci.Close()
+		h.Engine = uci
+		slog.Info("UCI engine started", "path", path, "hybrid_moves", cfg.HybridMoves)
+	}
+	tbURL := settings.TablebaseURL
+	if tbURL == config.Off {
+		slog.Info("Tablebase lookups disabled")
+	} else {
+		h.Tablebase = tablebase.NewClient(tbURL)
+		slog.Info("Probing endgames in tablebases", "max_pieces", tablebase.MaxPieces, "url", tbURL)
+	}
+	explorerURL := settings.ExplorerURL
+	if explorerURL == config.Off {
+		slog.Info("Opening explorer disabled")
+	} else {
+		h.Explorer = explorer.NewClient(explorerURL, settings.LichessToken)
+		slog.Info("Opening explorer enabled", "url", explorerURL, "in_prompts", cfg.ExplorerPrompt)
+	}
+	lichessURL := settings.LichessURL
+	if lichessURL == config.Off {
+		slog.Info("Lichess import disabled")
+	} else {
+		h.Lichess = lichess.NewClient(lichessURL, settings.LichessToken)
+		slog.Info("Lichess import enabled", "url", lichessURL)
+	}
+	chessComURL := settings.ChessComURL
+	if chessComURL == config.Off {
+		slog.Info("Chess.com import disabled")
+	} else {
+		h.ChessCom = chesscom.NewClient(chessComURL)
+		slog.Info("Chess.com import enabled", "url", chessComURL)
+	}
+	if report := h.RunSelfTest(); report.Passed {
+		slog.Info("Engine self-test passed", "checks", len(report.Checks))
+	}
 
-		next.ServeHTTP(w, r)
+	if secret := settings.WebhookSecret; secret != "" {
+		h.Webhooks = webhook.NewSender(secret)
+	} else {
+		slog.Info("Analysis job callbacks disabled (set NARA_WEBHOOK_SECRET to enable)")
+	}
+	h.StartAnalysisWorkers(settings.AnalysisWorkers)
+
+	adminKeys, err := auth.LoadKeys(strings.Join(settings.AdminKeys, ","), "")
+	if err != nil {
+		fatal("Failed to load admin keys", "err", err)
+	}
+
+	apiKeys, err := auth.LoadKeys(strings.Join(settings.APIKeys, ","), settings.APIKeysFile)
+	if err != nil {
+		fatal("Failed to load API keys", "err", err)
+	}
+	keyDB, _ := games.(store.APIKeyStore)
+	keyStore, err := auth.NewKeyStore(apiKeys, adminKeys, keyDB, auth.Limits{
+		PerKey:     settings.APIKeyRateLimit,
+		PerIP:      settings.IPRateLimit,
+		TrustProxy: settings.TrustProxy,
+		Shared:     shared,
 	})
+	if err != nil {
+		fatal("Failed to load API keys", "err", err)
+	}
+	h.Keys = keyStore
+	h.Quota = ratelimit.NewQuota(settings.DailyModelQuota, shared, "nara:quota:")
+	if quota := settings.DailyModelQuota; quota > 0 {
+		slog.Info("Daily model call quota enabled", "calls_per_caller", quota)
+	} else {
+		slog.Info("Daily model call quota disabled")
+	}
+	if keyStore.Enabled() {
+		slog.Info("API key authentication enabled")
+	} else {
+		slog.Info("API key authentication disabled (set NARA_API_KEYS or NARA_API_KEYS_FILE, or issue a key at /v1/apiKeys, to enable)")
+	}
+
+	rt := routes(h, keyStore, adminKeys)
+	var mux http.Handler = rt
+	if settings.SignIn() {
+		accounts, err := newAccounts(settings)
+		if err != nil {
+			fatal("Invalid configuration", "err", err)
+		}
+		h.Accounts = accounts
+		mux = accounts.Middleware(mux)
+		slog.Info("Sign-in enabled", "providers", strings.Join(accounts.Providers(), ", "))
+		if !settings.AllowCredentials {
+			slog.Warn("Browsers on other origins won't send the session cookie (set NARA_CORS_CREDENTIALS)")
+		}
+	} else {
+		slog.Info("Sign-in disabled (set GOOGLE_CLIENT_ID or NARA_LICHESS_CLIENT_ID to enable)")
+	}
+	muxCORS := origins.Middleware(mux)
+	// Around CORS, innermost first: panics are answered with a JSON 500, responses are
+	// compressed, and the tracing, metrics and access log all see the response that went
+	// out, panics included. The request ID is outermost so every line logged carries it.
+	muxCORS = middleware.Recover(muxCORS)
+	muxCORS = middleware.Gzip(muxCORS)
+	if endpoint := settings.TracingEndpoint; endpoint != "" {
+		shutdownTracing, err := tracing.Setup(context.Background(), endpoint)
+		if err != nil {
+			fatal("Failed to set up tracing", "err", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				slog.Error("Error flushing traces", "err", err)
+			}
+		}()
+		muxCORS = tracing.Middleware(rt.Pattern, muxCORS)
+		slog.Info("Exporting traces", "endpoint", endpoint)
+	} else {
+		slog.Info("Tracing disabled (set NARA_OTLP_ENDPOINT to export traces)")
+	}
+	muxCORS = metrics.Middleware(rt.Pattern, muxCORS)
+	muxCORS = middleware.AccessLog(rt.Pattern, muxCORS)
+	muxCORS = logging.Middleware(muxCORS)
+
+	shutdownTimeout := settings.ShutdownTimeout
+	// Requests derive their contexts from base, so cancelling it cuts off the model calls
+	// still running when the drain times out.
+	base, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+	srv := &http.Server{
+		Addr:        fmt.Sprintf(":%d", settings.Port),
+		Handler:     muxCORS,
+		BaseContext: func(net.Listener) context.Context { return base },
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+	slog.Info("Serving", "addr", fmt.Sprintf("127.0.0.1:%d", settings.Port))
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-serveErr:
+		fatal("Failed to start server", "err", err)
+	case <-signals.Done():
+	}
+	// A second signal kills the process at once.
+	stopSignals()
+
+	slog.Info("Shutting down, waiting for requests under way", "timeout", shutdownTimeout)
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Cancelling the requests still under way", "err", err)
+		cancelBase()
+		srv.Close()
+	}
+	if err := h.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Abandoning the background work still under way", "err", err)
+	}
+	// The deferred calls flush the traces and stop the engine and the store.
+	slog.Info("Server stopped")
+}
+
+// loadDotEnv adds the variables in the .env file at path to the environment, leaving
+// those already set alone. Containers and CI inject the environment directly, so a
+// missing file is expected there and only logged.
+func loadDotEnv(path string) error {
+	if err := godotenv.Load(path); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		slog.Info("No .env file found, reading configuration from the environment")
+	}
+	return nil
+}
+
+// fatal logs a startup failure and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// newAccounts sets up sign-in with the providers configured. Providers send the browser
+// back to the versioned callback path on the server's public URL.
+func newAccounts(settings *config.Config) (*account.Service, error) {
+	base := settings.ServerURL()
+	callback := func(provider string) string {
+		return base + "/v1/auth/" + provider + "/callback"
+	}
+	var providers []*account.Provider
+	if settings.GoogleClientID != "" {
+		providers = append(providers, account.Google(settings.GoogleClientID, settings.GoogleClientSecret, callback("google")))
+	}
+	if settings.LichessClientID != "" {
+		providers = append(providers, account.Lichess(settings.LichessClientID, callback("lichess"), settings.LichessURL))
+	}
+	secure := strings.HasPrefix(base, "https://")
+	return account.NewService(settings.SessionSecret, settings.SessionTTL, secure, providers...)
+}
+
+// newProvider sets up the named model provider, which config.Validate has checked, and
+// returns it with its fallback chain of models.
+func newProvider(name string, settings *config.Config) (llm.CoachProvider, []string) {
+	conf, _ := settings.Provider(name)
+	models := conf.Models
+	switch name {
+	case "openai":
+		p := ai.NewOpenAI(conf.APIKey, models[0])
+		if conf.URL != "" {
+			p.BaseURL = conf.URL
+		}
+		return p, models
+	case "anthropic":
+		return ai.NewAnthropic(conf.APIKey, models[0]), models
+	case "ollama":
+		return ai.NewOllama(conf.URL, models[0]), models
+	}
+	return ai.NewGemini(conf.APIKey, models[0]), models
 }
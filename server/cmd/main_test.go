@@ -0,0 +1,72 @@
+package main
+
+import (
+	"arnavsurve/nara-chess/server/pkg/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unsetenv unsets key for the rest of the test.
+func unsetenv(t *testing.T, key string) {
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+func TestStartupWithoutDotEnv(t *testing.T) {
+	unsetenv(t, "NARA_CONFIG_FILE")
+	if err := loadDotEnv(filepath.Join(t.TempDir(), ".env")); err != nil {
+		t.Fatalf("loadDotEnv without a .env file: %v", err)
+	}
+
+	// The key comes from the real environment instead.
+	t.Setenv("GEMINI_API_KEY", "from-environment")
+	settings, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	if settings.Gemini.APIKey != "from-environment" {
+		t.Errorf("GEMINI_API_KEY = %q, want the environment's", settings.Gemini.APIKey)
+	}
+}
+
+func TestStartupWithoutAPIKey(t *testing.T) {
+	unsetenv(t, "NARA_CONFIG_FILE")
+	unsetenv(t, "GEMINI_API_KEY")
+	if err := loadDotEnv(filepath.Join(t.TempDir(), ".env")); err != nil {
+		t.Fatalf("loadDotEnv without a .env file: %v", err)
+	}
+
+	_, err := config.Load(nil)
+	if err == nil || !strings.Contains(err.Error(), "gemini needs an API key") {
+		t.Errorf("config.Load = %v, want the missing key reported", err)
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	unsetenv(t, "NARA_DOTENV_FILE_ONLY")
+	t.Setenv("NARA_DOTENV_BOTH", "from-environment")
+
+	path := filepath.Join(t.TempDir(), ".env")
+	env := "NARA_DOTENV_FILE_ONLY=from-file\nNARA_DOTENV_BOTH=from-file\n"
+	if err := os.WriteFile(path, []byte(env), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadDotEnv(path); err != nil {
+		t.Fatalf("loadDotEnv: %v", err)
+	}
+	if got := os.Getenv("NARA_DOTENV_FILE_ONLY"); got != "from-file" {
+		t.Errorf("NARA_DOTENV_FILE_ONLY = %q, want the file's", got)
+	}
+	if got := os.Getenv("NARA_DOTENV_BOTH"); got != "from-environment" {
+		t.Errorf("NARA_DOTENV_BOTH = %q, want the environment to win", got)
+	}
+}
+
+func TestLoadDotEnvUnreadable(t *testing.T) {
+	// A directory where the file should be is a real error, not a missing file.
+	if err := loadDotEnv(t.TempDir()); err == nil {
+		t.Error("loadDotEnv read a directory")
+	}
+}
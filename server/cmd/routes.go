@@ -0,0 +1,116 @@
+package main
+
+import (
+	"arnavsurve/nara-chess/server/pkg/auth"
+	"arnavsurve/nara-chess/server/pkg/handlers"
+	"arnavsurve/nara-chess/server/pkg/router"
+	"net/http"
+	"net/http/pprof"
+)
+
+// routes mounts the API's endpoints under /v1. They are also served at their old
+// unversioned paths until the clients have moved; a /v2 with breaking changes would get
+// its own router.Version here.
+func routes(h *handlers.Handler, keys *auth.KeyStore, adminKeys []string) *router.Router {
+	rt := router.New()
+	admin := func(f http.HandlerFunc) http.Handler {
+		return auth.RequireAdmin(adminKeys, f)
+	}
+	// coach marks the endpoints that call the model provider, which count against the
+	// caller's daily quota.
+	coach := func(f http.HandlerFunc) http.Handler {
+		return auth.RequireScope(auth.ScopeCoach, h.MeterModelCalls(f))
+	}
+
+	// Orchestrators probe the server without keys.
+	rt.HandleFunc("GET /healthz", h.HandleLiveness)
+	rt.HandleFunc("GET /readyz", h.HandleReadiness)
+	rt.HandleFunc("GET /version", h.HandleVersion)
+
+	v1 := rt.Version("v1").Unversioned()
+	// The sign-in flow runs in the browser, which can't send an API key while it is
+	// redirected to and from the provider. Every other endpoint needs one when keys are on.
+	v1.HandleFunc("GET /auth/{provider}/login", h.HandleLogin)
+	v1.HandleFunc("GET /auth/{provider}/callback", h.HandleLoginCallback)
+	api := v1.With(keys.Middleware)
+	api.HandleFunc("POST /auth/logout", h.HandleLogout)
+	api.HandleFunc("GET /me", h.HandleMe)
+	api.HandleFunc("GET /quota", h.HandleQuota)
+	api.Handle("POST /generateMove", coach(h.HandleGenerateMove))
+	api.Handle("POST /chat", coach(h.HandleChatMessage))
+	api.Handle("POST /chat/stream", coach(h.HandleChatStream))
+	api.HandleFunc("POST /legalMoves", h.HandleLegalMoves)
+	api.HandleFunc("POST /validateMove", h.HandleValidateMove)
+	api.HandleFunc("POST /validateFens", h.HandleValidateFENs)
+	api.HandleFunc("POST /positionsFromHistory", h.HandlePositionsFromHistory)
+	api.HandleFunc("POST /importGames", h.HandleImportGames)
+	api.HandleFunc("POST /import/lichess/{username}", h.HandleImportLichess)
+	api.HandleFunc("POST /import/chesscom/{username}", h.HandleImportChessCom)
+	api.Handle("POST /analyze/pgn", coach(h.HandleAnalyzePGN))
+	api.HandleFunc("POST /analysis/jobs", h.HandleNewAnalysisJob)
+	api.HandleFunc("GET /analysis/jobs/{id}", h.HandleGetAnalysisJob)
+	api.Handle("POST /studyPlan", coach(h.HandleStudyPlan))
+	api.Handle("POST /teachingLine", coach(h.HandleTeachingLine))
+	api.HandleFunc("POST /principalVariation", h.HandlePrincipalVariation)
+	api.Handle("POST /evaluate", coach(h.HandleEvaluate))
+	api.Handle("POST /candidates", coach(h.HandleCandidates))
+	api.Handle("POST /explore", coach(h.HandleExplore))
+	api.HandleFunc("POST /threats", h.HandleThreats)
+	api.Handle("POST /developmentSuggestion", coach(h.HandleDevelopmentSuggestion))
+	api.Handle("POST /ponder", coach(h.HandlePonder))
+	api.HandleFunc("POST /mateHint", h.HandleMateHint)
+	api.Handle("POST /hint", coach(h.HandleHint))
+	api.HandleFunc("GET /explorer", h.HandleExplorer)
+	api.HandleFunc("GET /schema", h.HandleSchema)
+	api.HandleFunc("GET /metrics", h.HandleMetrics)
+	api.Handle("GET /admin/usage", admin(h.HandleUsage))
+	api.Handle("GET /admin/runtime", admin(h.HandleRuntime))
+	api.HandleFunc("GET /health", h.HandleHealth)
+	api.HandleFunc("GET /health/providers", h.HandleProviderHealth)
+	api.Handle("POST /health/selfTest", admin(h.HandleSelfTest))
+	api.Handle("GET /apiKeys", admin(h.HandleAPIKeys))
+	api.Handle("POST /apiKeys", admin(h.HandleAPIKeys))
+	api.Handle("DELETE /apiKeys/{id}", admin(h.HandleRevokeAPIKey))
+	api.HandleFunc("GET /games", h.HandleListGames)
+	api.HandleFunc("POST /game/new", h.HandleNewGame)
+	api.HandleFunc("GET /game/{id}", h.HandleGetGame)
+	api.HandleFunc("POST /game/{id}/move", h.HandleGameMove)
+	api.HandleFunc("POST /game/{id}/takeback", h.HandleTakeback)
+	api.Handle("POST /game/{id}/resign", coach(h.HandleResign))
+	api.Handle("POST /game/{id}/offerDraw", coach(h.HandleOfferDraw))
+	api.HandleFunc("GET /game/{id}/pgn", h.HandleExportPGN)
+	api.Handle("GET /game/{id}/report", coach(h.HandleGameReport))
+	api.HandleFunc("GET /game/{id}/evalGraph", h.HandleEvalGraph)
+	api.Handle("GET /profile/report", coach(h.HandleProgressReport))
+	api.HandleFunc("GET /profile/{user_id}", h.HandleGetProfile)
+	api.HandleFunc("GET /lessons", h.HandleListLessons)
+	api.Handle("POST /lessons/{id}/step", coach(h.HandleLessonStep))
+	api.HandleFunc("GET /puzzles/next", h.HandleNextPuzzle)
+	api.HandleFunc("POST /puzzles/{id}/attempt", h.HandlePuzzleAttempt)
+	api.Handle("GET /puzzle/daily", coach(h.HandleDailyPuzzle))
+	api.Handle("POST /puzzle/import", admin(h.HandleImportPuzzles))
+	api.HandleFunc("GET /repertoire", h.HandleRepertoires)
+	api.HandleFunc("POST /repertoire", h.HandleRepertoires)
+	api.HandleFunc("GET /repertoire/{id}", h.HandleGetRepertoire)
+	api.HandleFunc("GET /repertoire/{id}/drill", h.HandleRepertoireDrill)
+	api.HandleFunc("POST /repertoire/{id}/drill", h.HandleRepertoireDrill)
+	api.Handle("GET /trainer/guess", coach(h.HandleTrainerGuess))
+	api.Handle("POST /trainer/guess", coach(h.HandleTrainerGuess))
+	api.Handle("POST /trainer/blindfold", coach(h.HandleBlindfold))
+	api.HandleFunc("POST /trainer/blindfold/{id}/answer", h.HandleBlindfoldAnswer)
+	api.Handle("POST /trainer/import", admin(h.HandleImportMasterGames))
+	api.Handle("GET /ws/game", coach(h.HandleGameSocket))
+
+	// The profiler keeps the paths go tool pprof expects. Profiles are fetched with an
+	// admin key, such as with curl, and then opened with go tool pprof.
+	profiler := func(f http.HandlerFunc) http.Handler {
+		return keys.Middleware(admin(f))
+	}
+	rt.Handle("/debug/pprof/", profiler(pprof.Index))
+	rt.Handle("GET /debug/pprof/cmdline", profiler(pprof.Cmdline))
+	rt.Handle("GET /debug/pprof/profile", profiler(pprof.Profile))
+	rt.Handle("/debug/pprof/symbol", profiler(pprof.Symbol))
+	rt.Handle("GET /debug/pprof/trace", profiler(pprof.Trace))
+
+	return rt
+}
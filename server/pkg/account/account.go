@@ -0,0 +1,236 @@
+package account
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// SessionCookie holds the signed-in user's session token.
+const SessionCookie = "nara_session"
+
+// loginCookie holds the state and PKCE verifier of a sign-in under way for loginTTL.
+const (
+	loginCookie = "nara_login"
+	loginTTL    = 10 * time.Minute
+)
+
+// MinSecretLength is the shortest secret NewService accepts, the size of an HMAC-SHA256
+// key.
+const MinSecretLength = 32
+
+var (
+	ErrUnknownProvider = errors.New("account: unknown sign-in provider")
+	// ErrLoginState is returned for a callback that doesn't belong to a sign-in started
+	// by this browser in the last few minutes.
+	ErrLoginState = errors.New("account: sign-in expired or was not started here")
+	// ErrLoginDenied is returned when the user or the provider turned the sign-in down.
+	ErrLoginDenied = errors.New("account: sign-in denied")
+)
+
+// Service runs the sign-in flows of its providers and the sessions that follow them.
+// Sessions are stateless: the cookie holds a JWT naming the user, signed with the
+// service's secret, so signing out clears the cookie and changing the secret signs
+// everyone out.
+type Service struct {
+	providers map[string]*Provider
+	secret    []byte
+	ttl       time.Duration
+	// secure marks the cookies Secure, for servers reached over HTTPS.
+	secure bool
+}
+
+// NewService returns a service signing sessions that last ttl with secret, which must be
+// at least MinSecretLength bytes. secure restricts the cookies to HTTPS.
+func NewService(secret string, ttl time.Duration, secure bool, providers ...*Provider) (*Service, error) {
+	if len(secret) < MinSecretLength {
+		return nil, fmt.Errorf("account: session secret must be at least %d bytes", MinSecretLength)
+	}
+	s := &Service{providers: make(map[string]*Provider, len(providers)), secret: []byte(secret), ttl: ttl, secure: secure}
+	for _, p := range providers {
+		s.providers[p.Name] = p
+	}
+	return s, nil
+}
+
+// Providers returns the names of the providers users can sign in with, sorted.
+func (s *Service) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// BeginLogin starts a sign-in with the named provider and returns the URL to send the
+// browser to. The flow's state and PKCE verifier are kept in a short-lived cookie for
+// FinishLogin.
+func (s *Service) BeginLogin(w http.ResponseWriter, provider string) (string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+	state, verifier := oauth2.GenerateVerifier(), oauth2.GenerateVerifier()
+	token, err := s.sign(claims{Use: useLogin, Provider: provider, State: state, Verifier: verifier}, loginTTL)
+	if err != nil {
+		return "", err
+	}
+	s.setCookie(w, loginCookie, token, loginTTL)
+	return p.authCodeURL(state, verifier), nil
+}
+
+// FinishLogin completes the sign-in the provider sent the browser back from with r and
+// returns who signed in. The sign-in's cookie is cleared whatever the outcome, so a
+// callback can't be replayed.
+func (s *Service) FinishLogin(w http.ResponseWriter, r *http.Request, provider string) (Identity, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return Identity{}, ErrUnknownProvider
+	}
+	s.clearCookie(w, loginCookie)
+
+	query := r.URL.Query()
+	if reason := query.Get("error"); reason != "" {
+		return Identity{}, fmt.Errorf("%w: %s", ErrLoginDenied, reason)
+	}
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil {
+		return Identity{}, ErrLoginState
+	}
+	c, err := s.verify(cookie.Value, useLogin)
+	if err != nil || c.Provider != provider || query.Get("code") == "" ||
+		subtle.ConstantTimeCompare([]byte(c.State), []byte(query.Get("state"))) != 1 {
+		return Identity{}, ErrLoginState
+	}
+	return p.exchange(r.Context(), query.Get("code"), c.Verifier)
+}
+
+// StartSession signs userID in on the browser w answers.
+func (s *Service) StartSession(w http.ResponseWriter, userID string) error {
+	token, err := s.sign(claims{Use: useSession, Subject: userID}, s.ttl)
+	if err != nil {
+		return err
+	}
+	s.setCookie(w, SessionCookie, token, s.ttl)
+	return nil
+}
+
+// EndSession signs the browser w answers out.
+func (s *Service) EndSession(w http.ResponseWriter) {
+	s.clearCookie(w, SessionCookie)
+}
+
+type contextKey struct{}
+
+// Middleware passes the ID of the user signed in with the request's session cookie on to
+// UserID. Requests without a valid session go through as anonymous.
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie(SessionCookie); err == nil {
+			if c, err := s.verify(cookie.Value, useSession); err == nil && c.Subject != "" {
+				r = r.WithContext(context.WithValue(r.Context(), contextKey{}, c.Subject))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UserID returns the ID of the user signed in for the request with ctx, or "" for an
+// anonymous request.
+func UserID(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+func (s *Service) setCookie(w http.ResponseWriter, name, value string, ttl time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (s *Service) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{Name: name, Path: "/", MaxAge: -1, HttpOnly: true, Secure: s.secure, SameSite: http.SameSiteLaxMode})
+}
+
+// Tokens are used either for a session or for a sign-in under way, and one can't stand in
+// for the other.
+const (
+	useSession = "session"
+	useLogin   = "login"
+)
+
+// claims is the payload of the service's JWTs.
+type claims struct {
+	Use       string `json:"use"`
+	Subject   string `json:"sub,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	Provider  string `json:"provider,omitempty"`
+	State     string `json:"state,omitempty"`
+	Verifier  string `json:"verifier,omitempty"`
+}
+
+// jwtHeader is the encoded header of every token: HS256 is the only algorithm signed or
+// accepted.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// sign encodes c as a JWT expiring after ttl.
+func (s *Service) sign(c claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	c.IssuedAt = now.Unix()
+	c.ExpiresAt = now.Add(ttl).Unix()
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + s.signature(unsigned), nil
+}
+
+// verify checks token's signature, expiry and use and returns its claims.
+func (s *Service) verify(token, use string) (claims, error) {
+	var c claims
+	header, rest, ok := strings.Cut(token, ".")
+	payload, signature, ok2 := strings.Cut(rest, ".")
+	if !ok || !ok2 || header != jwtHeader {
+		return c, errors.New("account: malformed token")
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.signature(header+"."+payload))) {
+		return c, errors.New("account: bad token signature")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, err
+	}
+	if c.Use != use || time.Now().Unix() >= c.ExpiresAt {
+		return c, errors.New("account: token expired or misused")
+	}
+	return c, nil
+}
+
+func (s *Service) signature(unsigned string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,135 @@
+// Package account signs pupils in with OAuth providers such as Google and Lichess and
+// keeps them signed in with a session cookie holding a signed JWT.
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+// GoogleUserInfoURL is Google's OpenID Connect userinfo endpoint.
+const GoogleUserInfoURL = "https://openidconnect.googleapis.com/v1/userinfo"
+
+// Identity is who a provider says signed in.
+type Identity struct {
+	// Subject is the user's stable ID at the provider.
+	Subject string
+	Name    string
+	Email   string
+}
+
+// Provider is an OAuth provider users sign in with. Every flow uses PKCE.
+type Provider struct {
+	Name   string
+	config *oauth2.Config
+	// profileURL answers with the signed-in user's profile, which identify reads.
+	profileURL string
+	identify   func(body []byte) (Identity, error)
+	http       *http.Client
+}
+
+// Google signs users in with their Google account, asking for their name and email.
+func Google(clientID, clientSecret, redirectURL string) *Provider {
+	return &Provider{
+		Name: "google",
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Endpoint:     endpoints.Google,
+			RedirectURL:  redirectURL,
+			Scopes:       []string{"openid", "profile", "email"},
+		},
+		profileURL: GoogleUserInfoURL,
+		identify: func(body []byte) (Identity, error) {
+			var info struct {
+				Sub   string `json:"sub"`
+				Name  string `json:"name"`
+				Email string `json:"email"`
+			}
+			err := json.Unmarshal(body, &info)
+			return Identity{Subject: info.Sub, Name: info.Name, Email: info.Email}, err
+		},
+		http: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Lichess signs users in with their account on the lichess server at baseURL. Lichess
+// needs no client registration: clientID is any name for the app, and no scopes are
+// asked for since reading the username needs none.
+func Lichess(clientID, redirectURL, baseURL string) *Provider {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &Provider{
+		Name: "lichess",
+		config: &oauth2.Config{
+			ClientID: clientID,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   baseURL + "/oauth",
+				TokenURL:  baseURL + "/api/token",
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+			RedirectURL: redirectURL,
+		},
+		profileURL: baseURL + "/api/account",
+		identify: func(body []byte) (Identity, error) {
+			var account struct {
+				ID       string `json:"id"`
+				Username string `json:"username"`
+			}
+			err := json.Unmarshal(body, &account)
+			return Identity{Subject: account.ID, Name: account.Username}, err
+		},
+		http: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// authCodeURL is where the browser is sent to sign in, carrying state and the challenge
+// of verifier.
+func (p *Provider) authCodeURL(state, verifier string) string {
+	return p.config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+}
+
+// exchange trades the code the provider sent the browser back with for a token and reads
+// who it belongs to.
+func (p *Provider) exchange(ctx context.Context, code, verifier string) (Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.http)
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: exchanging code: %w", p.Name, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.profileURL, nil)
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "nara-chess")
+	resp, err := p.config.Client(ctx, token).Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", p.Name, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: reading profile: %w", p.Name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("%s: profile: %s: %s", p.Name, resp.Status, strings.TrimSpace(string(body[:min(len(body), 512)])))
+	}
+	id, err := p.identify(body)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: decoding profile: %w", p.Name, err)
+	}
+	if id.Subject == "" {
+		return Identity{}, errors.New(p.Name + ": profile has no user ID")
+	}
+	return id, nil
+}
@@ -0,0 +1,74 @@
+package ai
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+var (
+	ErrMissingAPIKey    = errors.New("ai: API key not configured")
+	ErrPingUnsupported  = errors.New("ai: provider has no health check")
+	ErrEmptyResponse    = errors.New("ai: empty or invalid response structure")
+	ErrUnexpectedFormat = errors.New("ai: unexpected response part type")
+)
+
+// Request is a single structured-output generation call.
+type Request struct {
+	Prompt      string
+	Schema      *genai.Schema
+	Temperature float32
+	// Model overrides the provider's default model when set.
+	Model string
+	// MaxOutputTokens caps the response length when positive.
+	MaxOutputTokens int32
+}
+
+// Provider generates JSON text conforming to a request's schema.
+type Provider interface {
+	GenerateJSON(ctx context.Context, req Request) (string, error)
+}
+
+// StreamProvider is implemented by providers that can deliver a response incrementally.
+// onChunk receives each piece of text as it arrives; returning an error stops the stream.
+// The complete text is returned at the end.
+type StreamProvider interface {
+	GenerateJSONStream(ctx context.Context, req Request, onChunk func(string) error) (string, error)
+}
+
+// GenerateStream streams req from p when it supports streaming and otherwise delivers the
+// whole response as a single chunk.
+func GenerateStream(ctx context.Context, p Provider, req Request, onChunk func(string) error) (string, error) {
+	if sp, ok := p.(StreamProvider); ok {
+		return sp.GenerateJSONStream(ctx, req, onChunk)
+	}
+	text, err := p.GenerateJSON(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if err := onChunk(text); err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// Pinger is implemented by providers that can check their connection without generating.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping checks p, looking through wrappers such as Breaker and ModelChain to the provider
+// they wrap. It returns ErrPingUnsupported when no provider in the chain is a Pinger.
+func Ping(ctx context.Context, p Provider) error {
+	for {
+		if pinger, ok := p.(Pinger); ok {
+			return pinger.Ping(ctx)
+		}
+		w, ok := p.(interface{ Unwrap() Provider })
+		if !ok {
+			return ErrPingUnsupported
+		}
+		p = w.Unwrap()
+	}
+}
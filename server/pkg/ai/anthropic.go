@@ -0,0 +1,114 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	anthropicBaseURL = "https://api.anthropic.com/v1"
+	anthropicVersion = "2023-06-01"
+	// anthropicMaxTokens is sent when a request sets no limit, since the API requires one.
+	anthropicMaxTokens = 4096
+	// anthropicTool is the tool the model is forced to call; its input is the response.
+	anthropicTool = "respond"
+)
+
+// Anthropic generates through the Messages API. A schema is enforced by forcing the model
+// to call a single tool whose input schema is the response schema.
+type Anthropic struct {
+	apiKey  string
+	model   string
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewAnthropic(apiKey, model string) *Anthropic {
+	return &Anthropic{apiKey: apiKey, model: model, BaseURL: anthropicBaseURL}
+}
+
+func (a *Anthropic) Name() string {
+	return "anthropic"
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int32              `json:"max_tokens"`
+	Temperature float32            `json:"temperature"`
+	Messages    []openAIMessage    `json:"messages"`
+	Tools       []anthropicToolDef `json:"tools,omitempty"`
+	ToolChoice  map[string]string  `json:"tool_choice,omitempty"`
+}
+
+type anthropicToolDef struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func (a *Anthropic) GenerateJSON(ctx context.Context, req Request) (string, error) {
+	if a.apiKey == "" {
+		return "", ErrMissingAPIKey
+	}
+
+	body := anthropicRequest{
+		Model:       a.model,
+		MaxTokens:   anthropicMaxTokens,
+		Temperature: req.Temperature,
+		Messages:    []openAIMessage{{Role: "user", Content: req.Prompt}},
+	}
+	if req.Model != "" {
+		body.Model = req.Model
+	}
+	// The Messages API accepts temperatures up to 1 where other providers allow 2.
+	if body.Temperature > 1 {
+		body.Temperature = 1
+	}
+	if req.MaxOutputTokens > 0 {
+		body.MaxTokens = req.MaxOutputTokens
+	}
+	if req.Schema != nil {
+		body.Tools = []anthropicToolDef{{
+			Name:        anthropicTool,
+			Description: "Submit your response.",
+			InputSchema: JSONSchema(req.Schema),
+		}}
+		body.ToolChoice = map[string]string{"type": "tool", "name": anthropicTool}
+	}
+
+	var resp anthropicResponse
+	header := http.Header{
+		"X-Api-Key":         {a.apiKey},
+		"Anthropic-Version": {anthropicVersion},
+	}
+	if err := postJSON(ctx, a.Client, a.Name(), strings.TrimSuffix(a.BaseURL, "/")+"/messages", header, body, &resp); err != nil {
+		return "", err
+	}
+	reportUsage(ctx, Usage{Provider: a.Name(), Model: body.Model, InputTokens: resp.Usage.InputTokens, OutputTokens: resp.Usage.OutputTokens})
+
+	for _, block := range resp.Content {
+		switch {
+		case req.Schema != nil && block.Type == "tool_use" && block.Name == anthropicTool:
+			return string(block.Input), nil
+		case req.Schema == nil && block.Type == "text" && block.Text != "":
+			return block.Text, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %+v", ErrEmptyResponse, resp)
+}
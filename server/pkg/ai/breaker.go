@@ -0,0 +1,194 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("ai: circuit breaker open")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// BreakerStats is a snapshot of a Breaker for metrics.
+type BreakerStats struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Opens               int    `json:"opens"`
+	Rejected            int    `json:"rejected"`
+}
+
+// Breaker wraps a Provider with a circuit breaker. After threshold consecutive failures it
+// opens and rejects calls with ErrCircuitOpen; once cooldown has passed it half-opens and
+// lets a single probe call through, closing again if the probe succeeds.
+type Breaker struct {
+	provider  Provider
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	opens    int
+	rejected int
+}
+
+func NewBreaker(provider Provider, threshold int, cooldown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{provider: provider, threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Unwrap returns the wrapped provider.
+func (b *Breaker) Unwrap() Provider {
+	return b.provider
+}
+
+// Name returns the wrapped provider's name, or "" when it has none.
+func (b *Breaker) Name() string {
+	if n, ok := b.provider.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return ""
+}
+
+func (b *Breaker) GenerateJSON(ctx context.Context, req Request) (string, error) {
+	if !b.allow() {
+		return "", ErrCircuitOpen
+	}
+	text, err := b.provider.GenerateJSON(ctx, req)
+	b.record(callerErr(ctx, err))
+	return text, err
+}
+
+// GenerateJSONStream streams through the wrapped provider under the same failure
+// accounting as GenerateJSON. A stream stopped by onChunk counts as a success, since the
+// provider was delivering.
+func (b *Breaker) GenerateJSONStream(ctx context.Context, req Request, onChunk func(string) error) (string, error) {
+	if !b.allow() {
+		return "", ErrCircuitOpen
+	}
+	stopped := false
+	text, err := GenerateStream(ctx, b.provider, req, func(chunk string) error {
+		if err := onChunk(chunk); err != nil {
+			stopped = true
+			return err
+		}
+		return nil
+	})
+	if stopped {
+		b.record(nil)
+	} else {
+		b.record(callerErr(ctx, err))
+	}
+	return text, err
+}
+
+// callerErr is err, unless the caller cancelled ctx: providers report a call cut short by
+// a disconnected client in their own ways, and it says nothing about their health.
+func callerErr(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return context.Canceled
+	}
+	return err
+}
+
+// allow reports whether a call may go through, moving an open breaker whose cooldown has
+// passed to half-open and admitting one probe.
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		b.state = BreakerHalfOpen
+	}
+	switch b.state {
+	case BreakerOpen:
+		b.rejected++
+		return false
+	case BreakerHalfOpen:
+		if b.probing {
+			b.rejected++
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasProbe := b.state == BreakerHalfOpen
+	if wasProbe {
+		b.probing = false
+	}
+
+	// A missing key, a call shed before reaching the provider or a caller that gave up says
+	// nothing about the provider's health.
+	if err != nil && (errors.Is(err, ErrMissingAPIKey) || errors.Is(err, ErrOverloaded) || errors.Is(err, context.Canceled)) {
+		return
+	}
+	if err == nil {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if wasProbe || b.failures >= b.threshold {
+		if b.state != BreakerOpen {
+			b.opens++
+		}
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// State returns the breaker's current state, reporting half-open once the cooldown of an
+// open breaker has passed.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) Stats() BreakerStats {
+	state := b.State()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BreakerStats{
+		State:               state.String(),
+		ConsecutiveFailures: b.failures,
+		Opens:               b.opens,
+		Rejected:            b.rejected,
+	}
+}
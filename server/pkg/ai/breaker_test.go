@@ -0,0 +1,158 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock is a breaker clock the test moves by hand.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newTestBreaker returns a breaker over provider that opens after three failures and
+// half-opens 30 seconds later by clock.
+func newTestBreaker(provider Provider) (*Breaker, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := NewBreaker(provider, 3, 30*time.Second)
+	b.now = clock.now
+	return b, clock
+}
+
+// wantState fails the test unless b is in state after opens openings.
+func wantState(t *testing.T, b *Breaker, state BreakerState, opens int) {
+	t.Helper()
+	stats := b.Stats()
+	if b.State() != state || stats.State != state.String() || stats.Opens != opens {
+		t.Fatalf("breaker %s after %d opens, want %s after %d", stats.State, stats.Opens, state, opens)
+	}
+}
+
+func TestBreakerCycle(t *testing.T) {
+	provider := &failingProvider{err: errUnavailable}
+	b, clock := newTestBreaker(provider)
+	ctx := context.Background()
+
+	// Closed: failures pass through until the threshold.
+	for i := range 3 {
+		wantState(t, b, BreakerClosed, 0)
+		if _, err := b.GenerateJSON(ctx, Request{}); !errors.Is(err, errUnavailable) {
+			t.Fatalf("call %d: err = %v, want the provider's error", i+1, err)
+		}
+	}
+
+	// Open: calls are rejected without reaching the provider.
+	wantState(t, b, BreakerOpen, 1)
+	clock.advance(29 * time.Second)
+	if _, err := b.GenerateJSON(ctx, Request{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if provider.calls != 3 || b.Stats().Rejected != 1 {
+		t.Fatalf("provider called %d times with %d rejected, want 3 and 1", provider.calls, b.Stats().Rejected)
+	}
+
+	// Half-open: a failed probe opens the breaker for another cooldown.
+	clock.advance(time.Second)
+	wantState(t, b, BreakerHalfOpen, 1)
+	if _, err := b.GenerateJSON(ctx, Request{}); !errors.Is(err, errUnavailable) {
+		t.Fatalf("probe err = %v, want the provider's error", err)
+	}
+	wantState(t, b, BreakerOpen, 2)
+	clock.advance(29 * time.Second)
+	wantState(t, b, BreakerOpen, 2)
+
+	// Half-open again: a successful probe closes the breaker.
+	clock.advance(time.Second)
+	wantState(t, b, BreakerHalfOpen, 2)
+	provider.err = nil
+	if _, err := b.GenerateJSON(ctx, Request{}); err != nil {
+		t.Fatalf("probe err = %v", err)
+	}
+	wantState(t, b, BreakerClosed, 2)
+	if failures := b.Stats().ConsecutiveFailures; failures != 0 {
+		t.Errorf("%d consecutive failures after closing, want 0", failures)
+	}
+}
+
+// blockingProvider holds each call until release is closed.
+type blockingProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProvider) GenerateJSON(ctx context.Context, req Request) (string, error) {
+	p.started <- struct{}{}
+	<-p.release
+	return "{}", nil
+}
+
+func TestBreakerHalfOpenAdmitsOneProbe(t *testing.T) {
+	failing := &failingProvider{err: errUnavailable}
+	b, clock := newTestBreaker(failing)
+	for range 3 {
+		b.GenerateJSON(context.Background(), Request{})
+	}
+	clock.advance(30 * time.Second)
+
+	probe := &blockingProvider{started: make(chan struct{}), release: make(chan struct{})}
+	b.provider = probe
+	done := make(chan error)
+	go func() {
+		_, err := b.GenerateJSON(context.Background(), Request{})
+		done <- err
+	}()
+	<-probe.started
+
+	// A second call while the probe is out is turned away.
+	if _, err := b.GenerateJSON(context.Background(), Request{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("err = %v during the probe, want ErrCircuitOpen", err)
+	}
+	close(probe.release)
+	if err := <-done; err != nil {
+		t.Fatalf("probe err = %v", err)
+	}
+	wantState(t, b, BreakerClosed, 1)
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	provider := &failingProvider{err: errUnavailable}
+	b, _ := newTestBreaker(provider)
+	for range 5 {
+		provider.err = errUnavailable
+		b.GenerateJSON(context.Background(), Request{})
+		b.GenerateJSON(context.Background(), Request{})
+		provider.err = nil
+		b.GenerateJSON(context.Background(), Request{})
+	}
+	wantState(t, b, BreakerClosed, 0)
+}
+
+func TestBreakerIgnoresCallerErrors(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+	}{
+		{"missing key", context.Background(), fmt.Errorf("gemini: %w", ErrMissingAPIKey)},
+		{"overloaded", context.Background(), ErrOverloaded},
+		{"caller cancelled", cancelled, errUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBreaker(&failingProvider{err: tt.err})
+			for range 5 {
+				b.GenerateJSON(tt.ctx, Request{})
+			}
+			wantState(t, b, BreakerClosed, 0)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrBudgetExhausted = errors.New("ai: model call budget exhausted")
+
+// Budget caps the total number of model calls a single request may make across every
+// retry loop that serves it.
+type Budget struct {
+	mu   sync.Mutex
+	max  int
+	used int
+}
+
+func NewBudget(max int) *Budget {
+	return &Budget{max: max}
+}
+
+// Take reserves one model call, reporting false when the budget is spent.
+func (b *Budget) Take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used >= b.max {
+		return false
+	}
+	b.used++
+	return true
+}
+
+// Remaining reports whether at least one more call may be made. A nil budget is
+// unbounded.
+func (b *Budget) Remaining() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used < b.max
+}
+
+// Left returns how many more calls b allows, or -1 when b is nil and so unbounded.
+func (b *Budget) Left() int {
+	if b == nil {
+		return -1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.max - b.used
+}
+
+func (b *Budget) Used() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+type budgetKey struct{}
+
+func WithBudget(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// BudgetFrom returns the budget attached to ctx, or nil when calls are unbounded.
+func BudgetFrom(ctx context.Context) *Budget {
+	b, _ := ctx.Value(budgetKey{}).(*Budget)
+	return b
+}
+
+// Meter charges model calls to something that outlives the request, such as its caller's
+// daily quota. An error from Charge stops the call.
+type Meter interface {
+	Charge() error
+}
+
+type meterKey struct{}
+
+func WithMeter(ctx context.Context, m Meter) context.Context {
+	return context.WithValue(ctx, meterKey{}, m)
+}
+
+// TakeCall reserves one model call for the request with ctx, from its budget and then its
+// meter when it has them.
+func TakeCall(ctx context.Context) error {
+	if b := BudgetFrom(ctx); b != nil && !b.Take() {
+		return ErrBudgetExhausted
+	}
+	if m, _ := ctx.Value(meterKey{}).(Meter); m != nil {
+		return m.Charge()
+	}
+	return nil
+}
+
+// retryCall reserves a call from ctx's budget for another try at a call already taken,
+// reporting false when the budget is spent. The meter isn't charged again: the caller
+// asked for one call.
+func retryCall(ctx context.Context) bool {
+	b := BudgetFrom(ctx)
+	return b == nil || b.Take()
+}
@@ -0,0 +1,95 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// failingProvider fails every call with err, counting them.
+type failingProvider struct {
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+func (p *failingProvider) GenerateJSON(ctx context.Context, req Request) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	return "", p.err
+}
+
+var errUnavailable = &APIError{Provider: "test", StatusCode: http.StatusServiceUnavailable}
+
+func TestBudgetCapsCalls(t *testing.T) {
+	ctx := WithBudget(context.Background(), NewBudget(3))
+	for i := range 3 {
+		if err := TakeCall(ctx); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	if err := TakeCall(ctx); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("fourth call: err = %v, want ErrBudgetExhausted", err)
+	}
+	if b := BudgetFrom(ctx); b.Remaining() || b.Used() != 3 {
+		t.Errorf("budget has %d used, remaining %v; want 3 used and none left", b.Used(), b.Remaining())
+	}
+}
+
+func TestNoBudgetIsUnbounded(t *testing.T) {
+	ctx := context.Background()
+	if !BudgetFrom(ctx).Remaining() {
+		t.Error("a request without a budget has no calls remaining")
+	}
+	for range 100 {
+		if err := TakeCall(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// The first call is taken by the caller; the Retrier's retries share what is left.
+func TestRetrierDrawsOnBudget(t *testing.T) {
+	provider := &failingProvider{err: errUnavailable}
+	ctx := WithBudget(context.Background(), NewBudget(3))
+	if err := TakeCall(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewRetrier(provider, 10, 0).GenerateJSON(ctx, Request{})
+	if !errors.Is(err, errUnavailable) {
+		t.Errorf("err = %v, want the provider's error", err)
+	}
+	if provider.calls != 3 {
+		t.Errorf("made %d calls, want the budget's 3", provider.calls)
+	}
+}
+
+func TestModelChainDrawsOnBudget(t *testing.T) {
+	provider := &failingProvider{err: errUnavailable}
+	ctx := WithBudget(context.Background(), NewBudget(2))
+	if err := TakeCall(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	chain := NewModelChain(provider, []string{"first", "second", "third", "fourth"}, 0)
+	if _, err := chain.GenerateJSON(ctx, Request{}); err == nil {
+		t.Fatal("chain succeeded with a failing provider")
+	}
+	if provider.calls != 2 {
+		t.Errorf("tried %d models, want the budget's 2", provider.calls)
+	}
+}
+
+func TestRetrierWithoutBudget(t *testing.T) {
+	provider := &failingProvider{err: errUnavailable}
+	if _, err := NewRetrier(provider, 2, 0).GenerateJSON(context.Background(), Request{}); err == nil {
+		t.Fatal("retrier succeeded with a failing provider")
+	}
+	if provider.calls != 3 {
+		t.Errorf("made %d calls, want the first and 2 retries", provider.calls)
+	}
+}
@@ -0,0 +1,147 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/metrics"
+	"arnavsurve/nara-chess/server/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrMalformedJSON is returned when a model's response is not valid JSON.
+var ErrMalformedJSON = errors.New("ai: model returned malformed JSON")
+
+// inFlight counts the calls under way through every ModelChain.
+var inFlight atomic.Int64
+
+// InFlight returns how many model calls are under way, each counted once however many
+// models of its chain it tries.
+func InFlight() int {
+	return int(inFlight.Load())
+}
+
+// ModelChain calls a provider with each model of a chain in turn until one answers with
+// valid JSON, for instance falling back from an experimental model that is timing out to
+// a faster stable one. A request naming its own model tries that model first and then the
+// rest of the chain. Each fallback draws on the request's Budget, and the chain stops once
+// it is spent. The model that answered is recorded in the context's Trace.
+type ModelChain struct {
+	provider Provider
+	models   []string
+	// AttemptTimeout bounds each model's attempt so a slow model leaves time for the next.
+	// Zero leaves only the request's own deadline.
+	AttemptTimeout time.Duration
+}
+
+func NewModelChain(provider Provider, models []string, attemptTimeout time.Duration) *ModelChain {
+	return &ModelChain{provider: provider, models: models, AttemptTimeout: attemptTimeout}
+}
+
+// Unwrap returns the wrapped provider.
+func (c *ModelChain) Unwrap() Provider {
+	return c.provider
+}
+
+// Name returns the wrapped provider's name, or "" when it has none.
+func (c *ModelChain) Name() string {
+	if n, ok := c.provider.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return ""
+}
+
+func (c *ModelChain) GenerateJSON(ctx context.Context, req Request) (string, error) {
+	return c.run(ctx, req, func(ctx context.Context, req Request) (string, bool, error) {
+		text, err := c.provider.GenerateJSON(ctx, req)
+		return text, false, err
+	})
+}
+
+// GenerateJSONStream streams through the chain. Once a model has delivered part of its
+// response the next model can't take over, so its failure is returned as is.
+func (c *ModelChain) GenerateJSONStream(ctx context.Context, req Request, onChunk func(string) error) (string, error) {
+	return c.run(ctx, req, func(ctx context.Context, req Request) (string, bool, error) {
+		delivered := false
+		text, err := GenerateStream(ctx, c.provider, req, func(chunk string) error {
+			delivered = true
+			return onChunk(chunk)
+		})
+		return text, delivered, err
+	})
+}
+
+// run tries each model in order. call reports whether its failure is final.
+func (c *ModelChain) run(ctx context.Context, req Request, call func(context.Context, Request) (string, bool, error)) (string, error) {
+	inFlight.Add(1)
+	defer inFlight.Add(-1)
+	models := c.chain(req.Model)
+	var err error
+	for i, model := range models {
+		attempt := req
+		attempt.Model = model
+
+		attemptCtx, span := tracing.Start(ctx, "ai.generate",
+			attribute.String("gen_ai.system", c.Name()),
+			attribute.String("gen_ai.request.model", model),
+			attribute.Int("attempt", i+1))
+		cancel := context.CancelFunc(func() {})
+		if c.AttemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(attemptCtx, c.AttemptTimeout)
+		}
+		var text string
+		var final bool
+		start := time.Now()
+		text, final, err = call(attemptCtx, attempt)
+		cancel()
+		if err == nil && !json.Valid([]byte(text)) {
+			err = fmt.Errorf("%w from %s", ErrMalformedJSON, model)
+		}
+		metrics.ModelCall(c.Name(), model, time.Since(start), err)
+		tracing.End(span, err)
+		if err == nil {
+			if t := TraceFrom(ctx); t != nil {
+				t.record(c.Name(), model)
+			}
+			return text, nil
+		}
+
+		// A missing key fails every model alike, as does a provider with too many calls
+		// waiting, and a request that is over has no time left.
+		if final || errors.Is(err, ErrMissingAPIKey) || errors.Is(err, ErrOverloaded) || ctx.Err() != nil {
+			return "", err
+		}
+		if i < len(models)-1 {
+			if !retryCall(ctx) {
+				return "", err
+			}
+			slog.WarnContext(ctx, "Model failed, falling back", "model", model, "next", models[i+1], "err", err)
+			metrics.ModelFallback(c.Name(), model)
+		}
+	}
+	return "", err
+}
+
+// chain returns the models to try: the requested model first when there is one, then the
+// configured chain without it.
+func (c *ModelChain) chain(requested string) []string {
+	if requested == "" {
+		if len(c.models) == 0 {
+			return []string{""} // the provider's default model
+		}
+		return c.models
+	}
+	models := []string{requested}
+	for _, m := range c.models {
+		if m != requested {
+			models = append(models, m)
+		}
+	}
+	return models
+}
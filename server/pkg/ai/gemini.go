@@ -0,0 +1,224 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// Gemini generates through one genai.Client shared by every call, so requests reuse its
+// pooled connections instead of each paying for a new client and TLS handshake. The
+// client is created by Connect or on first use, and recreated after an auth error.
+type Gemini struct {
+	apiKey string
+	model  string
+
+	mu     sync.Mutex
+	shared *genai.Client
+}
+
+func NewGemini(apiKey, model string) *Gemini {
+	return &Gemini{apiKey: apiKey, model: model}
+}
+
+// Connect creates the shared client ahead of the first request.
+func (g *Gemini) Connect(ctx context.Context) error {
+	_, err := g.client(ctx)
+	return err
+}
+
+// Ping checks that the API is reachable and accepts the key by fetching the default
+// model's metadata, which costs no tokens.
+func (g *Gemini) Ping(ctx context.Context) error {
+	client, err := g.client(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = client.GenerativeModel(g.model).Info(ctx)
+	g.checkAuth(client, err)
+	return err
+}
+
+// Close releases the shared client.
+func (g *Gemini) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.shared == nil {
+		return nil
+	}
+	err := g.shared.Close()
+	g.shared = nil
+	return err
+}
+
+func (g *Gemini) Name() string {
+	return "gemini"
+}
+
+func (g *Gemini) GenerateJSON(ctx context.Context, req Request) (string, error) {
+	if g.apiKey == "" {
+		return "", ErrMissingAPIKey
+	}
+
+	client, err := g.client(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	model := g.generativeModel(client, req)
+	resp, err := model.GenerateContent(ctx, genai.Text(req.Prompt))
+	if err != nil {
+		g.checkAuth(client, err)
+		return "", err
+	}
+	g.reportUsage(ctx, req, resp)
+
+	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("%w: %+v", ErrEmptyResponse, resp)
+	}
+
+	jsonPart := resp.Candidates[0].Content.Parts[0]
+	jsonString, ok := jsonPart.(genai.Text)
+	if !ok {
+		return "", fmt.Errorf("%w: got %T", ErrUnexpectedFormat, jsonPart)
+	}
+
+	return string(jsonString), nil
+}
+
+// GenerateJSONStream is GenerateJSON delivering each text part as Gemini produces it.
+func (g *Gemini) GenerateJSONStream(ctx context.Context, req Request, onChunk func(string) error) (string, error) {
+	if g.apiKey == "" {
+		return "", ErrMissingAPIKey
+	}
+
+	client, err := g.client(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	iter := g.generativeModel(client, req).GenerateContentStream(ctx, genai.Text(req.Prompt))
+	// Each response carries the running token counts; the last one has the totals.
+	var last *genai.GenerateContentResponse
+	defer func() { g.reportUsage(ctx, req, last) }()
+	for {
+		resp, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			g.checkAuth(client, err)
+			return "", err
+		}
+		last = resp
+		if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+			continue
+		}
+		for _, part := range resp.Candidates[0].Content.Parts {
+			text, ok := part.(genai.Text)
+			if !ok {
+				return "", fmt.Errorf("%w: got %T", ErrUnexpectedFormat, part)
+			}
+			sb.WriteString(string(text))
+			if err := onChunk(string(text)); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "", ErrEmptyResponse
+	}
+	return sb.String(), nil
+}
+
+// client returns the shared client, creating it when there is none.
+func (g *Gemini) client(ctx context.Context) (*genai.Client, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.shared != nil {
+		return g.shared, nil
+	}
+	// The client outlives the call that happens to create it.
+	client, err := genai.NewClient(context.WithoutCancel(ctx), option.WithAPIKey(g.apiKey))
+	if err != nil {
+		return nil, fmt.Errorf("creating Gemini client: %w", err)
+	}
+	g.shared = client
+	return client, nil
+}
+
+// checkAuth drops client when err is an authentication failure, so the next call builds a
+// fresh client rather than reusing one whose credentials were rejected. Calls still in
+// flight on the old client keep it until they finish; it is then garbage collected.
+func (g *Gemini) checkAuth(client *genai.Client, err error) {
+	if !isAuthError(err) {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.shared == client {
+		slog.Warn("Gemini rejected the client's credentials, recreating it", "err", err)
+		g.shared = nil
+	}
+}
+
+// isAuthError reports whether err is the API rejecting the key. An invalid key comes back
+// as 400 with reason API_KEY_INVALID rather than 401.
+func isAuthError(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	switch gerr.Code {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	case http.StatusBadRequest:
+		return strings.Contains(gerr.Body, "API_KEY_INVALID") || strings.Contains(gerr.Message, "API key not valid")
+	}
+	return false
+}
+
+// reportUsage reports the tokens counted in resp's usage metadata.
+func (g *Gemini) reportUsage(ctx context.Context, req Request, resp *genai.GenerateContentResponse) {
+	if resp == nil || resp.UsageMetadata == nil {
+		return
+	}
+	reportUsage(ctx, Usage{
+		Provider:     g.Name(),
+		Model:        g.modelName(req),
+		InputTokens:  int(resp.UsageMetadata.PromptTokenCount),
+		OutputTokens: int(resp.UsageMetadata.CandidatesTokenCount),
+	})
+}
+
+func (g *Gemini) modelName(req Request) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return g.model
+}
+
+func (g *Gemini) generativeModel(client *genai.Client, req Request) *genai.GenerativeModel {
+	model := client.GenerativeModel(g.modelName(req))
+	model.GenerationConfig = genai.GenerationConfig{
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   req.Schema,
+		Temperature:      &req.Temperature,
+	}
+	if req.MaxOutputTokens > 0 {
+		model.GenerationConfig.MaxOutputTokens = &req.MaxOutputTokens
+	}
+
+	return model
+}
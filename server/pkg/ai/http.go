@@ -0,0 +1,58 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// APIError is a non-2xx response from a provider's HTTP API.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+	// Header holds the response's headers, such as Retry-After.
+	Header http.Header
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ai: %s returned %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// postJSON sends body as JSON to url and decodes the JSON response into out.
+func postJSON(ctx context.Context, client *http.Client, provider, url string, header http.Header, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Error bodies are short JSON documents; cap them so a misbehaving proxy can't flood the log.
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return &APIError{Provider: provider, StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(text)), Header: resp.Header}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: decoding %s response: %v", ErrEmptyResponse, provider, err)
+	}
+	return nil
+}
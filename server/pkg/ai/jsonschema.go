@@ -0,0 +1,58 @@
+package ai
+
+import (
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// JSONSchema converts a Gemini response schema to the JSON Schema other providers accept.
+// A nil schema converts to nil.
+func JSONSchema(s *genai.Schema) map[string]any {
+	if s == nil {
+		return nil
+	}
+	out := map[string]any{}
+	switch s.Type {
+	case genai.TypeString:
+		out["type"] = "string"
+	case genai.TypeNumber:
+		out["type"] = "number"
+	case genai.TypeInteger:
+		out["type"] = "integer"
+	case genai.TypeBoolean:
+		out["type"] = "boolean"
+	case genai.TypeArray:
+		out["type"] = "array"
+		if s.Items != nil {
+			out["items"] = JSONSchema(s.Items)
+		}
+	case genai.TypeObject:
+		out["type"] = "object"
+		properties := make(map[string]any, len(s.Properties))
+		for name, p := range s.Properties {
+			properties[name] = JSONSchema(p)
+		}
+		out["properties"] = properties
+		required := s.Required
+		if required == nil {
+			required = []string{}
+		}
+		out["required"] = required
+	}
+	if s.Nullable {
+		if t, ok := out["type"].(string); ok {
+			out["type"] = []string{t, "null"}
+		}
+	}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if len(s.Enum) > 0 {
+		out["enum"] = s.Enum
+	}
+	if s.Format != "" && !strings.EqualFold(s.Format, "enum") {
+		out["format"] = s.Format
+	}
+	return out
+}
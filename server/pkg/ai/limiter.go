@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/metrics"
+)
+
+// ErrOverloaded is returned when a provider already has as many calls waiting for it as
+// its Limiter lets queue.
+var ErrOverloaded = errors.New("ai: too many model calls waiting")
+
+// queued counts the calls waiting for a slot of every Limiter.
+var queued atomic.Int64
+
+// Queued returns how many model calls are waiting for a provider to have a slot free.
+func Queued() int {
+	return int(queued.Load())
+}
+
+// Limiter wraps a Provider, bounding how many calls it makes at once so a burst of
+// requests waits its turn instead of opening a connection each. Calls beyond the limit
+// wait in line, up to maxQueue of them; more are shed with ErrOverloaded at once rather
+// than left waiting for a slot they would time out before getting.
+type Limiter struct {
+	provider Provider
+	slots    chan struct{}
+	maxQueue int64
+	waiting  atomic.Int64
+}
+
+// NewLimiter lets provider make up to concurrency calls at once, with up to maxQueue more
+// waiting.
+func NewLimiter(provider Provider, concurrency, maxQueue int) *Limiter {
+	return &Limiter{provider: provider, slots: make(chan struct{}, max(concurrency, 1)), maxQueue: int64(max(maxQueue, 0))}
+}
+
+// Unwrap returns the wrapped provider.
+func (l *Limiter) Unwrap() Provider {
+	return l.provider
+}
+
+// Name returns the wrapped provider's name, or "" when it has none.
+func (l *Limiter) Name() string {
+	if n, ok := l.provider.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return ""
+}
+
+func (l *Limiter) GenerateJSON(ctx context.Context, req Request) (string, error) {
+	if err := l.acquire(ctx); err != nil {
+		return "", err
+	}
+	defer l.release()
+	return l.provider.GenerateJSON(ctx, req)
+}
+
+// GenerateJSONStream holds its slot until the stream ends.
+func (l *Limiter) GenerateJSONStream(ctx context.Context, req Request, onChunk func(string) error) (string, error) {
+	if err := l.acquire(ctx); err != nil {
+		return "", err
+	}
+	defer l.release()
+	return GenerateStream(ctx, l.provider, req, onChunk)
+}
+
+// acquire takes a slot, waiting in line for one while all are taken unless the line is
+// full already or ctx is done first.
+func (l *Limiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		metrics.ModelQueued(l.Name(), 0)
+		return nil
+	default:
+	}
+
+	if l.waiting.Add(1) > l.maxQueue {
+		l.waiting.Add(-1)
+		metrics.ModelShed(l.Name())
+		return ErrOverloaded
+	}
+	queued.Add(1)
+	defer func() {
+		l.waiting.Add(-1)
+		queued.Add(-1)
+	}()
+	start := time.Now()
+	select {
+	case l.slots <- struct{}{}:
+		metrics.ModelQueued(l.Name(), time.Since(start))
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Limiter) release() {
+	<-l.slots
+}
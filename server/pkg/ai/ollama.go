@@ -0,0 +1,74 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const ollamaBaseURL = "http://localhost:11434"
+
+// Ollama generates with a locally hosted model through Ollama's chat API, so the coach can
+// run without any hosted provider. It needs no API key.
+type Ollama struct {
+	model   string
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewOllama(baseURL, model string) *Ollama {
+	if baseURL == "" {
+		baseURL = ollamaBaseURL
+	}
+	return &Ollama{model: model, BaseURL: baseURL}
+}
+
+func (o *Ollama) Name() string {
+	return "ollama"
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	// Format is a JSON Schema, or "json" for free-form JSON.
+	Format  any            `json:"format"`
+	Options map[string]any `json:"options"`
+}
+
+type ollamaResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+func (o *Ollama) GenerateJSON(ctx context.Context, req Request) (string, error) {
+	body := ollamaRequest{
+		Model:    o.model,
+		Messages: []openAIMessage{{Role: "user", Content: req.Prompt}},
+		Format:   "json",
+		Options:  map[string]any{"temperature": req.Temperature},
+	}
+	if req.Model != "" {
+		body.Model = req.Model
+	}
+	if req.Schema != nil {
+		body.Format = JSONSchema(req.Schema)
+	}
+	if req.MaxOutputTokens > 0 {
+		body.Options["num_predict"] = req.MaxOutputTokens
+	}
+
+	var resp ollamaResponse
+	if err := postJSON(ctx, o.Client, o.Name(), strings.TrimSuffix(o.BaseURL, "/")+"/api/chat", nil, body, &resp); err != nil {
+		return "", err
+	}
+	reportUsage(ctx, Usage{Provider: o.Name(), Model: body.Model, InputTokens: resp.PromptEvalCount, OutputTokens: resp.EvalCount})
+	if resp.Message.Content == "" {
+		return "", fmt.Errorf("%w: %+v", ErrEmptyResponse, resp)
+	}
+	return resp.Message.Content, nil
+}
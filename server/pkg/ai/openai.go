@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const openAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAI generates through the Chat Completions API. BaseURL may point at any compatible
+// server, such as a vLLM or LiteLLM deployment.
+type OpenAI struct {
+	apiKey  string
+	model   string
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewOpenAI(apiKey, model string) *OpenAI {
+	return &OpenAI{apiKey: apiKey, model: model, BaseURL: openAIBaseURL}
+}
+
+func (o *OpenAI) Name() string {
+	return "openai"
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model          string          `json:"model"`
+	Messages       []openAIMessage `json:"messages"`
+	Temperature    float32         `json:"temperature"`
+	MaxTokens      int32           `json:"max_completion_tokens,omitempty"`
+	ResponseFormat map[string]any  `json:"response_format"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content *string `json:"content"`
+			Refusal *string `json:"refusal"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+func (o *OpenAI) GenerateJSON(ctx context.Context, req Request) (string, error) {
+	if o.apiKey == "" {
+		return "", ErrMissingAPIKey
+	}
+
+	body := openAIRequest{
+		Model:       o.model,
+		Messages:    []openAIMessage{{Role: "user", Content: req.Prompt}},
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxOutputTokens,
+		// Without a schema the prompt's format instructions still ask for JSON.
+		ResponseFormat: map[string]any{"type": "json_object"},
+	}
+	if req.Model != "" {
+		body.Model = req.Model
+	}
+	if req.Schema != nil {
+		body.ResponseFormat = map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   "response",
+				"schema": JSONSchema(req.Schema),
+			},
+		}
+	}
+
+	var resp openAIResponse
+	header := http.Header{"Authorization": {"Bearer " + o.apiKey}}
+	if err := postJSON(ctx, o.Client, o.Name(), strings.TrimSuffix(o.BaseURL, "/")+"/chat/completions", header, body, &resp); err != nil {
+		return "", err
+	}
+	reportUsage(ctx, Usage{Provider: o.Name(), Model: body.Model, InputTokens: resp.Usage.PromptTokens, OutputTokens: resp.Usage.CompletionTokens})
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("%w: %+v", ErrEmptyResponse, resp)
+	}
+	message := resp.Choices[0].Message
+	if message.Refusal != nil && *message.Refusal != "" {
+		return "", fmt.Errorf("%w: model refused: %s", ErrEmptyResponse, *message.Refusal)
+	}
+	if message.Content == nil || *message.Content == "" {
+		return "", fmt.Errorf("%w: %+v", ErrEmptyResponse, resp)
+	}
+	return *message.Content, nil
+}
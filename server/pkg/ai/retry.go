@@ -0,0 +1,187 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/metrics"
+
+	"google.golang.org/api/googleapi"
+)
+
+// maxRetryDelay caps the wait before a retry, however many came before it. A provider
+// asking for a longer wait in Retry-After isn't retried at all.
+const maxRetryDelay = 8 * time.Second
+
+// Retrier wraps a Provider, calling it again after a transient failure, such as a 429, a
+// 5xx or a dropped connection, instead of failing the call at once. It waits a random time
+// up to a delay that doubles with each retry, from base up to maxRetryDelay, so clients
+// that failed together don't retry together; a provider's Retry-After is waited out when
+// it is longer. A retry that can't finish before the call's deadline isn't made, nor one
+// the request's Budget has no call left for.
+type Retrier struct {
+	provider Provider
+	retries  int
+	base     time.Duration
+}
+
+// NewRetrier retries each failing call of provider up to retries times, waiting up to
+// base before the first retry.
+func NewRetrier(provider Provider, retries int, base time.Duration) *Retrier {
+	return &Retrier{provider: provider, retries: max(retries, 0), base: base}
+}
+
+// Unwrap returns the wrapped provider.
+func (r *Retrier) Unwrap() Provider {
+	return r.provider
+}
+
+// Name returns the wrapped provider's name, or "" when it has none.
+func (r *Retrier) Name() string {
+	if n, ok := r.provider.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return ""
+}
+
+func (r *Retrier) GenerateJSON(ctx context.Context, req Request) (string, error) {
+	return r.run(ctx, req, func() (string, bool, error) {
+		text, err := r.provider.GenerateJSON(ctx, req)
+		return text, false, err
+	})
+}
+
+// GenerateJSONStream retries a stream only until it has delivered part of its response,
+// which a second call would deliver again.
+func (r *Retrier) GenerateJSONStream(ctx context.Context, req Request, onChunk func(string) error) (string, error) {
+	return r.run(ctx, req, func() (string, bool, error) {
+		delivered := false
+		text, err := GenerateStream(ctx, r.provider, req, func(chunk string) error {
+			delivered = true
+			return onChunk(chunk)
+		})
+		return text, delivered, err
+	})
+}
+
+// run makes call until it succeeds, fails for good or runs out of retries. call reports
+// whether its failure is final.
+func (r *Retrier) run(ctx context.Context, req Request, call func() (string, bool, error)) (string, error) {
+	for retry := 0; ; retry++ {
+		text, final, err := call()
+		if err == nil || final || retry == r.retries || ctx.Err() != nil || !IsTransient(err) {
+			return text, err
+		}
+
+		delay := r.delay(retry, err)
+		if delay < 0 {
+			return "", err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return "", err
+		}
+		if !retryCall(ctx) {
+			return "", err
+		}
+		slog.WarnContext(ctx, "Transient model error, retrying", "provider", r.Name(), "model", req.Model,
+			"retry", retry+1, "delay", delay, "err", err)
+		metrics.ModelRetry(r.Name(), req.Model)
+		if sleep(ctx, delay) != nil {
+			return "", err
+		}
+	}
+}
+
+// delay returns how long to wait before retry number retry+1 after err, or -1 when the
+// provider asked for a longer wait than maxRetryDelay.
+func (r *Retrier) delay(retry int, err error) time.Duration {
+	ceiling := r.base
+	for i := 0; i < retry && ceiling < maxRetryDelay; i++ {
+		ceiling *= 2
+	}
+	ceiling = min(ceiling, maxRetryDelay)
+	var d time.Duration
+	if ceiling > 0 {
+		d = rand.N(ceiling) + 1
+	}
+	if after := retryAfter(err); after > maxRetryDelay {
+		return -1
+	} else if after > d {
+		d = after
+	}
+	return d
+}
+
+// sleep waits for d unless ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsTransient reports whether err is a failure that may well not happen again: the
+// provider being rate limited, overloaded or failing inside, or the connection to it
+// dropping. Errors in the request, such as a rejected key, are never transient.
+func IsTransient(err error) bool {
+	// A deadline passes for the caller, not the provider, though it passes for a net.Error
+	// timeout.
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		return transientStatus(gerr.Code)
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return transientStatus(apiErr.StatusCode)
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// A timeout of the HTTP client's own.
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func transientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the wait a provider asked for in err's Retry-After header, in
+// seconds, or 0.
+func retryAfter(err error) time.Duration {
+	var header http.Header
+	var gerr *googleapi.Error
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &gerr):
+		header = gerr.Header
+	case errors.As(err, &apiErr):
+		header = apiErr.Header
+	}
+	seconds, convErr := strconv.Atoi(header.Get("Retry-After"))
+	if convErr != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
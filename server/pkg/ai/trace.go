@@ -0,0 +1,48 @@
+package ai
+
+import (
+	"context"
+	"sync"
+)
+
+// Trace records which provider and model answered a request's model calls, so responses
+// can report it. The last successful call wins.
+type Trace struct {
+	mu       sync.Mutex
+	provider string
+	model    string
+}
+
+func (t *Trace) record(provider, model string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.provider, t.model = provider, model
+}
+
+// Answered returns the provider and model of the last successful call, or empty strings.
+func (t *Trace) Answered() (provider, model string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.provider, t.model
+}
+
+// Adopt records the provider and model that answered the calls traced by from, for a
+// request that shared them rather than making its own.
+func (t *Trace) Adopt(from *Trace) {
+	provider, model := from.Answered()
+	if provider != "" || model != "" {
+		t.record(provider, model)
+	}
+}
+
+type traceKey struct{}
+
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// TraceFrom returns the trace attached to ctx, or nil.
+func TraceFrom(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Usage is the tokens a single model call consumed, as the provider reported them.
+type Usage struct {
+	Provider     string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+}
+
+type usageKey struct{}
+
+// WithUsage has every model call made with the returned context report its usage to
+// record, including calls whose response is later rejected, since they are billed all the
+// same.
+func WithUsage(ctx context.Context, record func(Usage)) context.Context {
+	return context.WithValue(ctx, usageKey{}, record)
+}
+
+// reportUsage hands u to the recorder attached to ctx and notes it on the call's span. Calls reporting no tokens at all,
+// as from a provider that doesn't count them, are dropped.
+func reportUsage(ctx context.Context, u Usage) {
+	if u.InputTokens == 0 && u.OutputTokens == 0 {
+		return
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("gen_ai.response.model", u.Model),
+		attribute.Int("gen_ai.usage.input_tokens", u.InputTokens),
+		attribute.Int("gen_ai.usage.output_tokens", u.OutputTokens))
+	if record, _ := ctx.Value(usageKey{}).(func(Usage)); record != nil {
+		record(u)
+	}
+}
+
+// Price is what a model costs in US dollars per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Prices maps model names to their prices. A name also prices the models it is a prefix
+// of, the longest match winning, so "gemini-2.0-flash" covers its dated versions.
+type Prices map[string]Price
+
+// DefaultPrices are the list prices of the hosted models the server is usually configured
+// with. Local models, such as Ollama's, cost nothing.
+var DefaultPrices = Prices{
+	"gemini-2.5-pro":    {Input: 1.25, Output: 10},
+	"gemini-2.5-flash":  {Input: 0.30, Output: 2.50},
+	"gemini-2.0-flash":  {Input: 0.10, Output: 0.40},
+	"gemini-1.5-pro":    {Input: 1.25, Output: 5},
+	"gemini-1.5-flash":  {Input: 0.075, Output: 0.30},
+	"gpt-4o":            {Input: 2.50, Output: 10},
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.60},
+	"gpt-4.1":           {Input: 2, Output: 8},
+	"gpt-4.1-mini":      {Input: 0.40, Output: 1.60},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4},
+	"claude-3-5-sonnet": {Input: 3, Output: 15},
+	"claude-3-7-sonnet": {Input: 3, Output: 15},
+	"claude-sonnet-4":   {Input: 3, Output: 15},
+}
+
+// Cost estimates the cost of u in US dollars. Models p has no price for cost nothing.
+func (p Prices) Cost(u Usage) float64 {
+	var price Price
+	matched := -1
+	for name, pr := range p {
+		if strings.HasPrefix(u.Model, name) && len(name) > matched {
+			price, matched = pr, len(name)
+		}
+	}
+	return (float64(u.InputTokens)*price.Input + float64(u.OutputTokens)*price.Output) / 1e6
+}
+
+// LoadPrices reads a JSON object mapping model names to prices and returns DefaultPrices
+// with them added or replaced. An empty path returns DefaultPrices alone.
+func LoadPrices(path string) (Prices, error) {
+	prices := maps.Clone(DefaultPrices)
+	if path == "" {
+		return prices, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading prices: %w", err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	var overrides Prices
+	if err := decoder.Decode(&overrides); err != nil {
+		return nil, fmt.Errorf("parsing prices %s: %w", path, err)
+	}
+	for name, price := range overrides {
+		if price.Input < 0 || price.Output < 0 {
+			return nil, fmt.Errorf("price of %q: must not be negative", name)
+		}
+		prices[name] = price
+	}
+	return prices, nil
+}
@@ -0,0 +1,214 @@
+package analysis
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+)
+
+const (
+	PhaseOpening    = "opening"
+	PhaseMiddlegame = "middlegame"
+	PhaseEndgame    = "endgame"
+)
+
+const (
+	// ClassBrilliant is a best move that gives up material for it.
+	ClassBrilliant  = "brilliant"
+	ClassBest       = "best"
+	ClassGood       = "good"
+	ClassInaccuracy = "inaccuracy"
+	ClassMistake    = "mistake"
+	ClassBlunder    = "blunder"
+)
+
+const (
+	MotifHangingPiece = "hanging_piece"
+	MotifMissedMate   = "missed_mate"
+	MotifAllowedMate  = "allowed_mate"
+	MotifMissedTactic = "missed_tactic"
+)
+
+// Mate scores are clamped to this many centipawns when computing losses so a single
+// missed mate does not dominate averages.
+const mateCentipawns = 1000
+
+// DefaultDepth keeps whole-game analysis fast enough to run inside a request.
+const DefaultDepth = 2
+
+// sacrificeCentipawns is the material a move must give up, after the opponent's best
+// reply, to count as a sacrifice: more than a pawn.
+const sacrificeCentipawns = 200
+
+type MoveAnalysis struct {
+	Ply            int         `json:"ply"`
+	San            string      `json:"san"`
+	Color          chess.Color `json:"-"`
+	Side           string      `json:"side"`
+	Phase          string      `json:"phase"`
+	BestMove       string      `json:"best_move"`
+	EvalBefore     int         `json:"eval_before"` // centipawns for the side that moved
+	EvalAfter      int         `json:"eval_after"`
+	Loss           int         `json:"centipawn_loss"`
+	Classification string      `json:"classification"`
+	Motif          string      `json:"motif,omitempty"`
+}
+
+// AnalyzeGame replays history from start and scores every move with the local engine.
+func AnalyzeGame(start *chess.Position, history []string, depth int) ([]MoveAnalysis, error) {
+	moves := make([]MoveAnalysis, 0, len(history))
+	err := AnalyzeMoves(start, history, depth, 0, func(ma MoveAnalysis) error {
+		moves = append(moves, ma)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moves, nil
+}
+
+// AnalyzeMoves is AnalyzeGame for analyses too long to wait for: it scores the moves of
+// history after the first from plies, handing each to done as soon as it is scored. done's
+// error stops the analysis and is returned.
+func AnalyzeMoves(start *chess.Position, history []string, depth, from int, done func(MoveAnalysis) error) error {
+	positions, err := chess.Replay(start, history)
+	if err != nil {
+		return err
+	}
+	positions = append([]*chess.Position{start}, positions...)
+	if from >= len(history) {
+		return nil
+	}
+
+	before := engine.Search(positions[from], depth)
+	for i := from; i < len(history); i++ {
+		after := engine.Search(positions[i+1], depth)
+		m, _ := positions[i].ParseSAN(history[i])
+		ma := analyzeMove(positions[i], m, before, after)
+		ma.Ply = i + 1
+		if err := done(ma); err != nil {
+			return err
+		}
+		before = after
+	}
+	return nil
+}
+
+// AnalyzeMove scores the single move san played in pos with the local engine. The
+// result's Ply is left for the caller to fill in.
+func AnalyzeMove(pos *chess.Position, san string, depth int) (MoveAnalysis, error) {
+	m, err := pos.ParseSAN(san)
+	if err != nil {
+		return MoveAnalysis{}, err
+	}
+	return analyzeMove(pos, m, engine.Search(pos, depth), engine.Search(pos.Play(m), depth)), nil
+}
+
+// analyzeMove classifies m given the searches of the positions before and after it.
+func analyzeMove(pos *chess.Position, m chess.Move, before, after engine.Result) MoveAnalysis {
+	next := pos.Play(m)
+	evalBefore := clampMate(before.Score)
+	evalAfter := -clampMate(after.Score)
+	if next.IsCheckmate() {
+		evalAfter = mateCentipawns
+	} else if len(next.LegalMoves()) == 0 {
+		evalAfter = 0
+	}
+	loss := max(evalBefore-evalAfter, 0)
+
+	ma := MoveAnalysis{
+		San:            pos.SAN(m),
+		Color:          pos.Turn,
+		Side:           pos.Turn.String(),
+		Phase:          Phase(pos),
+		BestMove:       pos.SAN(before.Move),
+		EvalBefore:     evalBefore,
+		EvalAfter:      evalAfter,
+		Loss:           loss,
+		Classification: Classify(loss),
+	}
+	if m == before.Move {
+		ma.Classification = ClassBest
+	}
+	// A sound sacrifice keeps the position at least level.
+	if ma.Classification == ClassBest && evalAfter >= 0 && sacrifices(pos, next, after.Move) {
+		ma.Classification = ClassBrilliant
+	}
+	ma.Motif = motif(pos, m, before, after)
+	return ma
+}
+
+// sacrifices reports whether the mover is down material, relative to pos, once the
+// opponent has played their best reply in next.
+func sacrifices(pos, next *chess.Position, reply chess.Move) bool {
+	if reply == (chess.Move{}) {
+		return false
+	}
+	mover := pos.Turn
+	balance := func(p *chess.Position) int {
+		return engine.Material(p, mover) - engine.Material(p, mover.Other())
+	}
+	return balance(pos)-balance(next.Play(reply)) >= sacrificeCentipawns
+}
+
+// Classify maps a centipawn loss to a move quality label.
+func Classify(loss int) string {
+	switch {
+	case loss >= 300:
+		return ClassBlunder
+	case loss >= 100:
+		return ClassMistake
+	case loss >= 50:
+		return ClassInaccuracy
+	case loss <= 10:
+		return ClassBest
+	default:
+		return ClassGood
+	}
+}
+
+// Phase classifies a position as opening, middlegame or endgame from the move number and
+// the remaining non-pawn material.
+func Phase(pos *chess.Position) string {
+	nonPawn := 0
+	for _, piece := range pos.Board {
+		if t := piece.Type(); t != chess.NoPieceType && t != chess.Pawn && t != chess.King {
+			nonPawn += engine.PieceValue(t)
+		}
+	}
+	switch {
+	case nonPawn <= 2600:
+		return PhaseEndgame
+	case pos.FullmoveNumber <= 10:
+		return PhaseOpening
+	default:
+		return PhaseMiddlegame
+	}
+}
+
+func motif(pos *chess.Position, played chess.Move, before, after engine.Result) string {
+	if played == before.Move {
+		return ""
+	}
+	switch {
+	case before.Score > 0 && engine.IsMateScore(before.Score):
+		return MotifMissedMate
+	case after.Score > 0 && engine.IsMateScore(after.Score):
+		return MotifAllowedMate
+	}
+	loss := clampMate(before.Score) + clampMate(after.Score)
+	if loss < 100 {
+		return ""
+	}
+	next := pos.Play(played)
+	if after.Move != (chess.Move{}) && next.IsCapture(after.Move) {
+		return MotifHangingPiece
+	}
+	if pos.IsCapture(before.Move) || pos.CheckInfo(before.Move).Check {
+		return MotifMissedTactic
+	}
+	return ""
+}
+
+func clampMate(score int) int {
+	return max(min(score, mateCentipawns), -mateCentipawns)
+}
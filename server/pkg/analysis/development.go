@@ -0,0 +1,134 @@
+package analysis
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"fmt"
+)
+
+const (
+	SuggestionDevelop = "develop"
+	SuggestionCastle  = "castle"
+	SuggestionCenter  = "center"
+)
+
+// Opening phase ends after this many full moves or once too much material is traded.
+const (
+	openingMaxFullmoves    = 12
+	openingMinMaterial     = 2 * (2*320 + 2*330 + 2*500 + 900 - 330) // both sides, at most a minor piece each traded
+	developmentSearchDepth = 2
+)
+
+type Suggestion struct {
+	Kind        string      `json:"kind"`
+	Move        string      `json:"move,omitempty"`
+	Arrows      [][2]string `json:"arrows"`
+	Explanation string      `json:"explanation"`
+}
+
+// IsOpeningPhase reports whether pos is still in the opening judging by the move number
+// and the amount of non-pawn material left on the board.
+func IsOpeningPhase(pos *chess.Position) bool {
+	if pos.FullmoveNumber > openingMaxFullmoves {
+		return false
+	}
+	material := 0
+	for _, piece := range pos.Board {
+		if t := piece.Type(); t != chess.NoPieceType && t != chess.Pawn && t != chess.King {
+			material += engine.PieceValue(t)
+		}
+	}
+	return material >= openingMinMaterial
+}
+
+// DevelopmentSuggestions lists opening-principle improvements for the side to move:
+// minor pieces still on their starting squares, an uncastled king and a missing central
+// pawn. Each suggestion carries a concrete legal move chosen by the local engine.
+func DevelopmentSuggestions(pos *chess.Position) []Suggestion {
+	var suggestions []Suggestion
+	legal := pos.LegalMoves()
+	home := 0
+	if pos.Turn == chess.Black {
+		home = 7
+	}
+
+	for _, file := range []int{1, 6, 2, 5} { // knights before bishops
+		sq := chess.NewSquare(file, home)
+		wantType, name := chess.Knight, "knight"
+		if file == 2 || file == 5 {
+			wantType, name = chess.Bishop, "bishop"
+		}
+		if pos.Board[sq] != chess.NewPiece(pos.Turn, wantType) {
+			continue
+		}
+
+		var moves []chess.Move
+		for _, m := range legal {
+			if m.From == sq {
+				moves = append(moves, m)
+			}
+		}
+		if len(moves) == 0 {
+			suggestions = append(suggestions, Suggestion{
+				Kind:        SuggestionDevelop,
+				Arrows:      [][2]string{},
+				Explanation: fmt.Sprintf("Your %s on %s is still at home and has no moves yet; free it with a pawn move.", name, sq),
+			})
+			continue
+		}
+		best := engine.SearchMoves(pos, developmentSearchDepth, moves)
+		suggestions = append(suggestions, Suggestion{
+			Kind:        SuggestionDevelop,
+			Move:        pos.SAN(best.Move),
+			Arrows:      [][2]string{{best.Move.From.String(), best.Move.To.String()}},
+			Explanation: fmt.Sprintf("Your %s on %s hasn't moved yet; %s brings it into play.", name, sq, pos.SAN(best.Move)),
+		})
+	}
+
+	king := chess.NewSquare(4, home)
+	kingSide, queenSide := chess.WhiteKingSide, chess.WhiteQueenSide
+	if pos.Turn == chess.Black {
+		kingSide, queenSide = chess.BlackKingSide, chess.BlackQueenSide
+	}
+	if pos.Board[king] == chess.NewPiece(pos.Turn, chess.King) && pos.Castling&(kingSide|queenSide) != 0 {
+		s := Suggestion{
+			Kind:        SuggestionCastle,
+			Arrows:      [][2]string{},
+			Explanation: "Your king is still in the center. Clear the pieces between king and rook so you can castle.",
+		}
+		for _, m := range legal {
+			if m.From == king && (int(m.To)-int(m.From) == 2 || int(m.To)-int(m.From) == -2) {
+				s.Move = pos.SAN(m)
+				s.Arrows = [][2]string{{m.From.String(), m.To.String()}}
+				s.Explanation = fmt.Sprintf("Castling with %s tucks your king away and connects your rooks.", s.Move)
+				break
+			}
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	centerRank := 3
+	if pos.Turn == chess.Black {
+		centerRank = 4
+	}
+	pawn := chess.NewPiece(pos.Turn, chess.Pawn)
+	if pos.Board[chess.NewSquare(3, centerRank)] != pawn && pos.Board[chess.NewSquare(4, centerRank)] != pawn {
+		var pushes []chess.Move
+		for _, m := range legal {
+			if pos.Board[m.From] == pawn && (m.To.File() == 3 || m.To.File() == 4) && m.To.Rank() == centerRank {
+				pushes = append(pushes, m)
+			}
+		}
+		if len(pushes) > 0 {
+			best := engine.SearchMoves(pos, developmentSearchDepth, pushes)
+			suggestions = append(suggestions, Suggestion{
+				Kind:        SuggestionCenter,
+				Move:        pos.SAN(best.Move),
+				Arrows:      [][2]string{{best.Move.From.String(), best.Move.To.String()}},
+				Explanation: fmt.Sprintf("You have no pawn in the center yet; %s stakes a claim to it.", pos.SAN(best.Move)),
+			})
+		}
+	}
+
+	return suggestions
+}
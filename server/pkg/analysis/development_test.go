@@ -0,0 +1,148 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+// playOpening returns the position history reaches from the standard start.
+func playOpening(t *testing.T, history ...string) *chess.Position {
+	t.Helper()
+	positions, err := chess.Replay(chess.NewGame(), history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) == 0 {
+		return chess.NewGame()
+	}
+	return positions[len(positions)-1]
+}
+
+// undeveloped returns the squares of the minor pieces DevelopmentSuggestions flagged.
+func undeveloped(suggestions []Suggestion) []string {
+	var squares []string
+	for _, s := range suggestions {
+		if s.Kind != SuggestionDevelop {
+			continue
+		}
+		for _, sq := range []string{"b1", "g1", "c1", "f1", "b8", "g8", "c8", "f8"} {
+			if strings.Contains(s.Explanation, " on "+sq+" ") {
+				squares = append(squares, sq)
+			}
+		}
+	}
+	return squares
+}
+
+func TestDevelopmentSuggestionsFlagsMinorPieces(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []string
+		want    []string
+	}{
+		{"starting position", nil, []string{"b1", "g1", "c1", "f1"}},
+		{"black after e4", []string{"e4"}, []string{"b8", "g8", "c8", "f8"}},
+		{"kingside knight out", []string{"e4", "e5", "Nf3", "Nc6"}, []string{"b1", "c1", "f1"}},
+		{"italian", []string{"e4", "e5", "Nf3", "Nc6", "Bc4", "Bc5", "Nc3"}, []string{"g8", "c8"}},
+		{"queen's bishop left", []string{"e4", "e5", "Nf3", "Nc6", "Bc4", "Bc5", "Nc3", "Nf6", "d3", "d6", "Bg5"}, []string{"c8"}},
+		{"all developed", []string{"e4", "e5", "Nf3", "Nc6", "Bc4", "Bc5", "Nc3", "Nf6", "d3", "d6", "Bg5", "Bg4"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos := playOpening(t, tt.history...)
+			if !IsOpeningPhase(pos) {
+				t.Fatal("IsOpeningPhase = false")
+			}
+			suggestions := DevelopmentSuggestions(pos)
+			got := undeveloped(suggestions)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("flagged %v, want %v", got, tt.want)
+			}
+
+			for _, s := range suggestions {
+				if s.Move == "" {
+					continue
+				}
+				m, err := pos.ParseSAN(s.Move)
+				if err != nil {
+					t.Errorf("%s suggestion %s is illegal: %v", s.Kind, s.Move, err)
+					continue
+				}
+				if len(s.Arrows) != 1 || s.Arrows[0] != [2]string{m.From.String(), m.To.String()} {
+					t.Errorf("%s suggestion %s has arrows %v", s.Kind, s.Move, s.Arrows)
+				}
+			}
+		})
+	}
+}
+
+func TestDevelopmentSuggestionsMovesDevelopingPiece(t *testing.T) {
+	pos := chess.NewGame()
+	for _, s := range DevelopmentSuggestions(pos) {
+		if s.Kind != SuggestionDevelop {
+			continue
+		}
+		// The knights can move at once; the bishops wait behind their pawns.
+		isKnight := strings.Contains(s.Explanation, "knight")
+		if hasMove := s.Move != ""; hasMove != isKnight {
+			t.Errorf("suggestion %q has move %q", s.Explanation, s.Move)
+		}
+	}
+}
+
+func TestDevelopmentSuggestionsCastleAndCenter(t *testing.T) {
+	kinds := func(suggestions []Suggestion) map[string]Suggestion {
+		byKind := map[string]Suggestion{}
+		for _, s := range suggestions {
+			byKind[s.Kind] = s
+		}
+		return byKind
+	}
+
+	start := kinds(DevelopmentSuggestions(chess.NewGame()))
+	if s, ok := start[SuggestionCenter]; !ok || (s.Move != "e4" && s.Move != "d4") {
+		t.Errorf("center suggestion = %+v, want a central pawn push", s)
+	}
+	if s, ok := start[SuggestionCastle]; !ok || s.Move != "" {
+		t.Errorf("castle suggestion = %+v, want one without a move while castling is blocked", s)
+	}
+
+	ready := kinds(DevelopmentSuggestions(playOpening(t, "e4", "e5", "Nf3", "Nc6", "Bc4", "Bc5")))
+	if s := ready[SuggestionCastle]; s.Move != "O-O" {
+		t.Errorf("castle suggestion = %+v, want O-O", s)
+	}
+	if _, ok := ready[SuggestionCenter]; ok {
+		t.Error("suggested a center pawn with e4 already played")
+	}
+
+	castled := kinds(DevelopmentSuggestions(playOpening(t, "e4", "e5", "Nf3", "Nc6", "Bc4", "Bc5", "O-O", "Nf6")))
+	if _, ok := castled[SuggestionCastle]; ok {
+		t.Error("suggested castling after castling")
+	}
+}
+
+func TestIsOpeningPhase(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want bool
+	}{
+		{"starting position", chess.StartFEN, true},
+		{"late move number", "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 20", false},
+		{"queens and rooks traded", "2b1kbn1/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/1NB1KB2 w - - 0 8", false},
+		{"endgame", "8/5k2/8/8/8/8/3K4/8 w - - 0 5", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := chess.ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := IsOpeningPhase(pos); got != tt.want {
+				t.Errorf("IsOpeningPhase = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package analysis
+
+import (
+	"math"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+)
+
+// EvalPoint is White's evaluation of one position of a game. Ply 0 is the starting
+// position and ply n the position after the nth move.
+type EvalPoint struct {
+	Ply int    `json:"ply"`
+	San string `json:"san,omitempty"` // the move that led here
+	// Score is in centipawns with mates clamped, so a graph's scale stays readable; Mate
+	// gives the full moves to a forced mate, negative when Black mates.
+	Score      int     `json:"score"`
+	Mate       int     `json:"mate,omitempty"`
+	WinPercent float64 `json:"white_win_percent"`
+	// BestMove is the engine's choice for the side to move, empty once the game is over.
+	BestMove string `json:"best_move,omitempty"`
+}
+
+// CriticalMoment is a move after which the evaluation moved by more than a threshold.
+type CriticalMoment struct {
+	Ply  int    `json:"ply"`
+	San  string `json:"san"`
+	Side string `json:"side"`
+	// Before and After are White's evaluation around the move. Swing is the change from
+	// the mover's point of view, so a negative swing is a move that threw away ground.
+	Before   int    `json:"before"`
+	After    int    `json:"after"`
+	Swing    int    `json:"swing"`
+	BestMove string `json:"best_move"`
+}
+
+// EvalGraph replays history from start and evaluates every position with the local
+// engine, returning len(history)+1 points.
+func EvalGraph(start *chess.Position, history []string, depth int) ([]EvalPoint, error) {
+	positions, err := chess.Replay(start, history)
+	if err != nil {
+		return nil, err
+	}
+	positions = append([]*chess.Position{start}, positions...)
+
+	points := make([]EvalPoint, len(positions))
+	for i, pos := range positions {
+		p := EvalPoint{Ply: i}
+		if i > 0 {
+			p.San = history[i-1]
+		}
+		switch {
+		case pos.IsCheckmate():
+			p.Score = engine.WhiteScore(-mateCentipawns, pos.Turn)
+		case len(pos.LegalMoves()) == 0:
+			p.Score = 0
+		default:
+			result := engine.Search(pos, depth)
+			score := engine.WhiteScore(result.Score, pos.Turn)
+			p.Score, p.Mate = clampMate(score), engine.MateIn(score)
+			p.BestMove = pos.SAN(result.Move)
+		}
+		p.WinPercent = math.Round(10*WinPercent(p.Score)) / 10
+		points[i] = p
+	}
+	return points, nil
+}
+
+// CriticalMoments returns the moves of points whose evaluation changed by more than
+// threshold centipawns, in game order. start is the position points begins from.
+func CriticalMoments(start *chess.Position, points []EvalPoint, threshold int) []CriticalMoment {
+	var moments []CriticalMoment
+	mover := start.Turn
+	for i := 1; i < len(points); i++ {
+		before, after := points[i-1], points[i]
+		swing := engine.ScoreFor(after.Score-before.Score, mover)
+		if swing > threshold || swing < -threshold {
+			moments = append(moments, CriticalMoment{
+				Ply:      after.Ply,
+				San:      after.San,
+				Side:     mover.String(),
+				Before:   before.Score,
+				After:    after.Score,
+				Swing:    swing,
+				BestMove: before.BestMove,
+			})
+		}
+		mover = mover.Other()
+	}
+	return moments
+}
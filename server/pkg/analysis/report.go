@@ -0,0 +1,94 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+)
+
+// SideReport summarizes one side's play over a game.
+type SideReport struct {
+	Side string `json:"side"`
+	// Accuracy is the average per-move accuracy from 0 to 100, judged by how much each
+	// move lowered the side's chance of winning rather than by raw centipawns, so errors
+	// in already decided positions weigh little.
+	Accuracy     float64 `json:"accuracy"`
+	AverageLoss  float64 `json:"average_centipawn_loss"`
+	Moves        int     `json:"moves"`
+	Brilliant    int     `json:"brilliant"`
+	Inaccuracies int     `json:"inaccuracies"`
+	Mistakes     int     `json:"mistakes"`
+	Blunders     int     `json:"blunders"`
+}
+
+// WinPercent converts a centipawn evaluation to the mover's expected score in percent,
+// using the logistic fit Lichess derived from rated games.
+func WinPercent(cp int) float64 {
+	return 50 + 50*(2/(1+math.Exp(-0.00368208*float64(cp)))-1)
+}
+
+// moveAccuracy maps the drop in win percentage caused by a move to an accuracy from 0 to
+// 100.
+func moveAccuracy(m MoveAnalysis) float64 {
+	drop := max(WinPercent(m.EvalBefore)-WinPercent(m.EvalAfter), 0)
+	return math.Min(math.Max(103.1668*math.Exp(-0.04354*drop)-3.1669, 0), 100)
+}
+
+// SummarizeSide reports the moves of moves played by side ("white" or "black").
+func SummarizeSide(moves []MoveAnalysis, side string) SideReport {
+	report := SideReport{Side: side}
+	totalLoss, totalAccuracy := 0, 0.0
+	for _, m := range moves {
+		if m.Side != side {
+			continue
+		}
+		report.Moves++
+		totalLoss += m.Loss
+		totalAccuracy += moveAccuracy(m)
+		switch m.Classification {
+		case ClassBrilliant:
+			report.Brilliant++
+		case ClassInaccuracy:
+			report.Inaccuracies++
+		case ClassMistake:
+			report.Mistakes++
+		case ClassBlunder:
+			report.Blunders++
+		}
+	}
+	if report.Moves > 0 {
+		report.AverageLoss = average(totalLoss, report.Moves)
+		report.Accuracy = math.Round(totalAccuracy/float64(report.Moves)*10) / 10
+	}
+	return report
+}
+
+// KeyMoments returns up to n moves that swung the game the most, by the mover's drop in
+// win percentage, in game order. Brilliant moves come first. Only inaccuracies and worse
+// are otherwise considered, so a clean game may have fewer than n.
+func KeyMoments(moves []MoveAnalysis, n int) []MoveAnalysis {
+	type scored struct {
+		m     MoveAnalysis
+		swing float64
+	}
+	var candidates []scored
+	for _, m := range moves {
+		switch m.Classification {
+		case ClassBrilliant:
+			candidates = append(candidates, scored{m, math.Inf(1)})
+		case ClassInaccuracy, ClassMistake, ClassBlunder:
+			candidates = append(candidates, scored{m, WinPercent(m.EvalBefore) - WinPercent(m.EvalAfter)})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].swing > candidates[j].swing
+	})
+
+	moments := make([]MoveAnalysis, 0, n)
+	for _, c := range candidates[:min(n, len(candidates))] {
+		moments = append(moments, c.m)
+	}
+	sort.Slice(moments, func(i, j int) bool {
+		return moments[i].Ply < moments[j].Ply
+	})
+	return moments
+}
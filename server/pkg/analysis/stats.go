@@ -0,0 +1,124 @@
+package analysis
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"sort"
+	"strings"
+)
+
+// openingPlies is how many plies identify an opening line when grouping games.
+const openingPlies = 6
+
+type PhaseStats struct {
+	Moves        int     `json:"moves"`
+	Inaccuracies int     `json:"inaccuracies"`
+	Mistakes     int     `json:"mistakes"`
+	Blunders     int     `json:"blunders"`
+	AverageLoss  float64 `json:"average_centipawn_loss"`
+	totalLoss    int
+}
+
+type OpeningStats struct {
+	Moves       string  `json:"moves"`
+	Games       int     `json:"games"`
+	AverageLoss float64 `json:"average_centipawn_loss"`
+	totalLoss   int
+	moveCount   int
+}
+
+// Weaknesses aggregates the pupil's move quality across several games.
+type Weaknesses struct {
+	GamesAnalyzed int                    `json:"games_analyzed"`
+	MovesAnalyzed int                    `json:"moves_analyzed"`
+	Inaccuracies  int                    `json:"inaccuracies"`
+	Mistakes      int                    `json:"mistakes"`
+	Blunders      int                    `json:"blunders"`
+	AverageLoss   float64                `json:"average_centipawn_loss"`
+	ByPhase       map[string]*PhaseStats `json:"by_phase"`
+	Motifs        map[string]int         `json:"motifs"`
+	Openings      []*OpeningStats        `json:"openings"`
+	totalLoss     int
+}
+
+func NewWeaknesses() *Weaknesses {
+	return &Weaknesses{
+		ByPhase: map[string]*PhaseStats{},
+		Motifs:  map[string]int{},
+	}
+}
+
+// AddGame folds the analysis of one game into the totals, counting only the moves played
+// by pupil.
+func (wk *Weaknesses) AddGame(history []string, moves []MoveAnalysis, pupil chess.Color) {
+	wk.GamesAnalyzed++
+
+	line := strings.Join(history[:min(openingPlies, len(history))], " ")
+	var opening *OpeningStats
+	for _, o := range wk.Openings {
+		if o.Moves == line {
+			opening = o
+		}
+	}
+	if opening == nil {
+		opening = &OpeningStats{Moves: line}
+		wk.Openings = append(wk.Openings, opening)
+	}
+	opening.Games++
+
+	for _, m := range moves {
+		if m.Color != pupil {
+			continue
+		}
+		wk.MovesAnalyzed++
+		wk.totalLoss += m.Loss
+
+		phase := wk.ByPhase[m.Phase]
+		if phase == nil {
+			phase = &PhaseStats{}
+			wk.ByPhase[m.Phase] = phase
+		}
+		phase.Moves++
+		phase.totalLoss += m.Loss
+
+		switch m.Classification {
+		case ClassInaccuracy:
+			wk.Inaccuracies++
+			phase.Inaccuracies++
+		case ClassMistake:
+			wk.Mistakes++
+			phase.Mistakes++
+		case ClassBlunder:
+			wk.Blunders++
+			phase.Blunders++
+		}
+		if m.Motif != "" {
+			wk.Motifs[m.Motif]++
+		}
+		if m.Phase == PhaseOpening {
+			opening.totalLoss += m.Loss
+			opening.moveCount++
+		}
+	}
+
+	wk.finish()
+}
+
+func (wk *Weaknesses) finish() {
+	wk.AverageLoss = average(wk.totalLoss, wk.MovesAnalyzed)
+	for _, p := range wk.ByPhase {
+		p.AverageLoss = average(p.totalLoss, p.Moves)
+	}
+	for _, o := range wk.Openings {
+		o.AverageLoss = average(o.totalLoss, o.moveCount)
+	}
+	sort.SliceStable(wk.Openings, func(i, j int) bool {
+		return wk.Openings[i].AverageLoss > wk.Openings[j].AverageLoss
+	})
+}
+
+func average(total, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return float64(total) / float64(n)
+}
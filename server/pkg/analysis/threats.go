@@ -0,0 +1,195 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+)
+
+const (
+	ThreatHangingPiece = "hanging_piece"
+	ThreatCheck        = "check"
+	ThreatMate         = "mate"
+	ThreatFork         = "fork"
+)
+
+// kingValue stands in for the king's material value when judging exchanges, so a king
+// only ever wins an undefended piece.
+const kingValue = 10_000
+
+// Threat is one immediate tactical threat. Move is the threatening move in SAN, empty for
+// a hanging piece and for a check already on the board. Square is the threatened piece
+// for hanging pieces and checks, and the forking square for forks.
+type Threat struct {
+	Kind        string      `json:"kind"`
+	Side        string      `json:"side"` // the side making the threat
+	Move        string      `json:"move,omitempty"`
+	MateIn      int         `json:"mate_in,omitempty"`
+	Square      string      `json:"square,omitempty"`
+	Targets     []string    `json:"targets,omitempty"`
+	Arrows      [][2]string `json:"arrows"`
+	Description string      `json:"description"`
+}
+
+// Threats lists what by threatens in pos: pieces it could win, checks and forks it could
+// play, and a forced mate in at most mateMoves. When by is not to move the threats are
+// what it would do if it were, and move-based threats are skipped while the side to move
+// is in check, reporting the check instead.
+func Threats(pos *chess.Position, by chess.Color, mateMoves int) []Threat {
+	threats := hangingPieces(pos, by)
+
+	attacker := pos
+	if pos.Turn != by {
+		if pos.InCheck() {
+			king := pos.KingSquare(pos.Turn)
+			check := Threat{Kind: ThreatCheck, Side: by.String(), Square: king.String(), Description: fmt.Sprintf("%s is in check.", capitalize(pos.Turn.String()))}
+			for _, from := range pos.Attackers(king, by) {
+				check.Arrows = append(check.Arrows, [2]string{from.String(), king.String()})
+			}
+			return append(threats, check)
+		}
+		null := *pos
+		null.Turn, null.EnPassant = by, chess.NoSquare
+		attacker = &null
+	}
+
+	var mateMove chess.Move
+	if mate, ok := engine.FindMate(attacker, mateMoves); ok {
+		mateMove = mate.Move
+		san := attacker.SAN(mate.Move)
+		threats = append(threats, Threat{
+			Kind:        ThreatMate,
+			Side:        by.String(),
+			Move:        san,
+			MateIn:      mate.In,
+			Arrows:      [][2]string{{mate.Move.From.String(), mate.Move.To.String()}},
+			Description: fmt.Sprintf("%s has mate in %d starting with %s.", capitalize(by.String()), mate.In, san),
+		})
+	}
+
+	victim := by.Other()
+	for _, m := range attacker.LegalMoves() {
+		san := attacker.SAN(m)
+		if m != mateMove && attacker.CheckInfo(m).Check {
+			king := attacker.KingSquare(victim)
+			threats = append(threats, Threat{
+				Kind:        ThreatCheck,
+				Side:        by.String(),
+				Move:        san,
+				Square:      king.String(),
+				Arrows:      [][2]string{{m.From.String(), m.To.String()}},
+				Description: fmt.Sprintf("%s can give check with %s.", capitalize(by.String()), san),
+			})
+		}
+		if fork, ok := forkThreat(attacker, m, san); ok {
+			threats = append(threats, fork)
+		}
+	}
+	return threats
+}
+
+// hangingPieces lists victim pieces by can win: those it attacks that are undefended or
+// attacked by something cheaper.
+func hangingPieces(pos *chess.Position, by chess.Color) []Threat {
+	var threats []Threat
+	victim := by.Other()
+	for sq, piece := range pos.Board {
+		if piece == chess.NoPiece || piece.Color() != victim || piece.Type() == chess.King {
+			continue
+		}
+		target := chess.Square(sq)
+		attackers := pos.Attackers(target, by)
+		if len(attackers) == 0 {
+			continue
+		}
+		cheapest := attackers[0]
+		for _, from := range attackers[1:] {
+			if exchangeValue(pos.Board[from].Type()) < exchangeValue(pos.Board[cheapest].Type()) {
+				cheapest = from
+			}
+		}
+		defended := pos.IsAttacked(target, victim)
+		if defended && exchangeValue(pos.Board[cheapest].Type()) >= exchangeValue(piece.Type()) {
+			continue
+		}
+		state := "undefended"
+		if defended {
+			state = "attacked by a cheaper piece"
+		}
+		threats = append(threats, Threat{
+			Kind:        ThreatHangingPiece,
+			Side:        by.String(),
+			Square:      target.String(),
+			Arrows:      [][2]string{{cheapest.String(), target.String()}},
+			Description: fmt.Sprintf("The %s %s on %s is %s.", victim, piece.Type().Name(), target, state),
+		})
+	}
+	return threats
+}
+
+// forkThreat reports whether m puts a piece where it attacks two or more victim pieces it
+// would win: the king, undefended pieces or pieces worth more than it. A forking piece the
+// victim can simply take for free does not count.
+func forkThreat(pos *chess.Position, m chess.Move, san string) (Threat, bool) {
+	by := pos.Turn
+	victim := by.Other()
+	next := pos.Play(m)
+	mover := next.Board[m.To].Type()
+	if next.IsAttacked(m.To, victim) && !next.IsAttacked(m.To, by) {
+		return Threat{}, false
+	}
+
+	var targets []string
+	var names []string
+	arrows := [][2]string{{m.From.String(), m.To.String()}}
+	for sq, piece := range next.Board {
+		if piece == chess.NoPiece || piece.Color() != victim {
+			continue
+		}
+		target := chess.Square(sq)
+		attacked := false
+		for _, from := range next.Attackers(target, by) {
+			if from == m.To {
+				attacked = true
+				break
+			}
+		}
+		if !attacked {
+			continue
+		}
+		if piece.Type() != chess.King && exchangeValue(piece.Type()) <= exchangeValue(mover) && next.IsAttacked(target, victim) {
+			continue
+		}
+		targets = append(targets, target.String())
+		names = append(names, fmt.Sprintf("%s on %s", piece.Type().Name(), target))
+		arrows = append(arrows, [2]string{m.To.String(), target.String()})
+	}
+	if len(targets) < 2 {
+		return Threat{}, false
+	}
+	return Threat{
+		Kind:        ThreatFork,
+		Side:        by.String(),
+		Move:        san,
+		Square:      m.To.String(),
+		Targets:     targets,
+		Arrows:      arrows,
+		Description: fmt.Sprintf("%s forks the %s.", san, strings.Join(names, " and ")),
+	}, true
+}
+
+func exchangeValue(t chess.PieceType) int {
+	if t == chess.King {
+		return kingValue
+	}
+	return engine.PieceValue(t)
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
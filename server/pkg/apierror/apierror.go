@@ -0,0 +1,144 @@
+// Package apierror writes the server's error responses, which share one JSON envelope:
+//
+//	{"error": {"code": "INVALID_FEN", "message": "Invalid FEN: ...", "field": "fen"}}
+//
+// Clients branch on the code; the message is for people and may change. A request that
+// fails validation lists every field at fault under "fields", the first of them repeated
+// in the error itself.
+package apierror
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// The error codes. A code names what went wrong independently of the HTTP status, which
+// several codes share.
+const (
+	// InvalidRequest is a request that is malformed or fails validation; Field names the
+	// request field at fault when there is one.
+	InvalidRequest = "INVALID_REQUEST"
+	// InvalidJSON is a body that is not the endpoint's JSON request.
+	InvalidJSON = "INVALID_JSON"
+	// InvalidFEN is a position that doesn't parse as FEN.
+	InvalidFEN = "INVALID_FEN"
+	// InvalidPGN is a PGN that doesn't parse or has no playable game.
+	InvalidPGN = "INVALID_PGN"
+	// IllegalMove is a move, or a move in a history, that is illegal in its position.
+	IllegalMove = "ILLEGAL_MOVE"
+	// GameOver is a move or offer in a game that has ended.
+	GameOver = "GAME_OVER"
+	// VersionConflict is a change made against an outdated version of a game.
+	VersionConflict = "VERSION_CONFLICT"
+	// NotFound is an unknown route or a missing game, puzzle, profile or other resource.
+	NotFound = "NOT_FOUND"
+	// MethodNotAllowed is a route called with the wrong HTTP method.
+	MethodNotAllowed = "METHOD_NOT_ALLOWED"
+	// Unauthorized is a request without a valid API key, or without the session of the
+	// signed-in user whose data it asks for.
+	Unauthorized = "UNAUTHORIZED"
+	// Forbidden is a request whose key may not use the endpoint, or a signed-in user's
+	// request for another pupil's data.
+	Forbidden = "FORBIDDEN"
+	// RateLimited is a request over the rate limit of its key or, without one, its client
+	// address.
+	RateLimited = "RATE_LIMITED"
+	// QuotaExceeded is a model-backed request from a caller who has used up the day's
+	// model calls.
+	QuotaExceeded = "QUOTA_EXCEEDED"
+	// Unavailable is a feature that is disabled or a service that is temporarily down.
+	Unavailable = "SERVICE_UNAVAILABLE"
+	// ModelTimeout is a model call that ran out of time.
+	ModelTimeout = "MODEL_TIMEOUT"
+	// ModelError is a model call that failed or answered with something unusable.
+	ModelError = "MODEL_ERROR"
+	// UpstreamError is a failed call to an outside service such as Lichess.
+	UpstreamError = "UPSTREAM_ERROR"
+	// Internal is a failure inside the server.
+	Internal = "INTERNAL_ERROR"
+)
+
+// Response is the body of every error response.
+type Response struct {
+	Error Error `json:"error"`
+}
+
+// Error describes what went wrong.
+type Error struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Field   string       `json:"field,omitempty"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError is one problem with a field of an invalid request.
+type FieldError struct {
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Write sends the error response with status, code and message.
+func Write(w http.ResponseWriter, status int, code, message string) {
+	WriteField(w, status, code, "", message)
+}
+
+// WriteField is Write for an error about the request field named field.
+func WriteField(w http.ResponseWriter, status int, code, field, message string) {
+	write(w, status, Error{Code: code, Message: message, Field: field})
+}
+
+// WriteFields sends a 400 Bad Request listing every problem with an invalid request. The
+// error's code and field are those of the first problem; its message joins them all.
+func WriteFields(w http.ResponseWriter, fields []FieldError) {
+	e := Error{Code: InvalidRequest, Fields: fields}
+	messages := make([]string, len(fields))
+	for i, f := range fields {
+		messages[i] = f.Message
+	}
+	e.Message = strings.Join(messages, "; ")
+	if len(fields) > 0 {
+		e.Code, e.Field = fields[0].Code, fields[0].Field
+	}
+	write(w, http.StatusBadRequest, e)
+}
+
+func write(w http.ResponseWriter, status int, e Error) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(Response{e}); err != nil {
+		slog.Error("Error encoding error response for client", "err", err)
+	}
+}
+
+// CodeFor is the code of a response with status when nothing more specific applies.
+func CodeFor(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusRequestEntityTooLarge:
+		return InvalidRequest
+	case http.StatusUnauthorized:
+		return Unauthorized
+	case http.StatusForbidden:
+		return Forbidden
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusMethodNotAllowed:
+		return MethodNotAllowed
+	case http.StatusConflict:
+		return VersionConflict
+	case http.StatusTooManyRequests:
+		return RateLimited
+	case http.StatusBadGateway:
+		return UpstreamError
+	case http.StatusServiceUnavailable:
+		return Unavailable
+	case http.StatusGatewayTimeout:
+		return ModelTimeout
+	}
+	return Internal
+}
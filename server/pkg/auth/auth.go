@@ -0,0 +1,430 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/ratelimit"
+	"arnavsurve/nara-chess/server/pkg/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Scopes grant API keys the endpoints that need more than a valid key.
+const (
+	// ScopeCoach grants the endpoints that call the model provider and so spend the
+	// owner's quota.
+	ScopeCoach = "coach"
+	// ScopeAdmin grants the admin endpoints, like the X-Admin-Key header.
+	ScopeAdmin = "admin"
+)
+
+// Scopes lists every scope.
+var Scopes = []string{ScopeCoach, ScopeAdmin}
+
+// KeyPrefix starts every key Create issues.
+const KeyPrefix = "nara_"
+
+// KeyStore authenticates requests with bearer API keys and applies a per-key rate limit.
+// Keys come from the configuration, with every scope, and from a store.APIKeyStore,
+// which keeps only their hashes. A KeyStore with no keys lets every request through,
+// which keeps local development unauthenticated, limited per client address instead.
+type KeyStore struct {
+	db store.APIKeyStore
+	// adminKeys also authenticate requests, sent in X-Admin-Key, with ScopeAdmin.
+	adminKeys  []string
+	keyLimits  *ratelimit.Buckets
+	ipLimits   *ratelimit.Buckets
+	trustProxy bool
+
+	mu sync.Mutex
+	// keys holds the configured keys and the stored keys used so far, keyed by hash.
+	keys       map[string]*keyState
+	configured int
+	stored     int
+}
+
+type keyState struct {
+	// id is the stored key's ID, empty for a configured key.
+	id    string
+	label string
+	// caller identifies the key to Caller.
+	caller   string
+	scopes   []string
+	requests int64
+	limited  int64
+}
+
+// Usage is the request count recorded for a single key.
+type Usage struct {
+	Requests int64 `json:"requests"`
+	Limited  int64 `json:"limited"`
+}
+
+// Limits sets the request rates a KeyStore allows, each per minute with bursts of the same
+// size; a non-positive rate disables its limit.
+type Limits struct {
+	PerKey int
+	// PerIP limits requests by client address while no keys are configured or stored.
+	PerIP int
+	// TrustProxy takes the client address from X-Forwarded-For, for a server behind a
+	// reverse proxy.
+	TrustProxy bool
+	// Shared keeps the limits in Redis, so every instance using it enforces them together.
+	// Nil keeps them in memory.
+	Shared redis.UniversalClient
+}
+
+// NewKeyStore builds a store for the configured keys and those kept in db, which may be
+// nil, enforcing limits. A request with one of adminKeys in its X-Admin-Key header needs
+// no API key, so the admin endpoints stay reachable to issue one.
+func NewKeyStore(keys, adminKeys []string, db store.APIKeyStore, limits Limits) (*KeyStore, error) {
+	s := &KeyStore{
+		db:         db,
+		adminKeys:  adminKeys,
+		keyLimits:  ratelimit.NewBuckets(limits.PerKey, limits.Shared, "nara:rate:key:"),
+		ipLimits:   ratelimit.NewBuckets(limits.PerIP, limits.Shared, "nara:rate:ip:"),
+		trustProxy: limits.TrustProxy,
+		keys:       make(map[string]*keyState, len(keys)),
+	}
+	for _, key := range keys {
+		hash := HashKey(key)
+		s.keys[hash] = newState("", "key:"+hash[:16], Redact(key), Scopes)
+	}
+	s.configured = len(s.keys)
+	if db != nil {
+		stored, err := db.ListAPIKeys()
+		if err != nil {
+			return nil, fmt.Errorf("listing API keys: %w", err)
+		}
+		s.stored = len(stored)
+	}
+	return s, nil
+}
+
+func newState(id, caller, label string, scopes []string) *keyState {
+	return &keyState{id: id, caller: caller, label: label, scopes: scopes}
+}
+
+// HashKey is the hash a key is looked up by. Keys are long and random, so a fast hash is
+// enough to keep a leaked database from revealing them.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadKeys collects API keys from a comma-separated list and, optionally, a file with one
+// key per line. Blank lines and lines starting with # are ignored.
+func LoadKeys(list, path string) ([]string, error) {
+	var keys []string
+	for _, key := range strings.Split(list, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	if path == "" {
+		return keys, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening API keys file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading API keys file: %w", err)
+	}
+	return keys, nil
+}
+
+// Enabled reports whether any key is configured or stored, which makes the API require
+// one.
+func (s *KeyStore) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.configured+s.stored > 0
+}
+
+// Create issues a key named name with scopes and stores its hash. The key is returned
+// only here.
+func (s *KeyStore) Create(name string, scopes []string) (string, *store.APIKey, error) {
+	if s.db == nil {
+		return "", nil, errors.New("auth: no store for API keys")
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	key := KeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	k := &store.APIKey{
+		Name:      name,
+		Hash:      HashKey(key),
+		Prefix:    key[:len(KeyPrefix)+4],
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.db.AddAPIKey(k); err != nil {
+		return "", nil, err
+	}
+	s.mu.Lock()
+	s.stored++
+	s.mu.Unlock()
+	return key, k, nil
+}
+
+// List returns the stored keys, oldest first.
+func (s *KeyStore) List() ([]*store.APIKey, error) {
+	if s.db == nil {
+		return []*store.APIKey{}, nil
+	}
+	return s.db.ListAPIKeys()
+}
+
+// Revoke deletes the stored key id, which stops working at once.
+func (s *KeyStore) Revoke(id string) error {
+	if s.db == nil {
+		return store.ErrAPIKeyNotFound
+	}
+	if err := s.db.DeleteAPIKey(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stored--
+	for hash, state := range s.keys {
+		if state.id == id {
+			delete(s.keys, hash)
+		}
+	}
+	return nil
+}
+
+// lookup finds the state of key, loading a stored key on its first use.
+func (s *KeyStore) lookup(key string) (*keyState, error) {
+	hash := HashKey(key)
+	s.mu.Lock()
+	state, ok := s.keys[hash]
+	s.mu.Unlock()
+	if ok || s.db == nil {
+		return state, nil
+	}
+
+	stored, err := s.db.APIKeyByHash(hash)
+	if errors.Is(err, store.ErrAPIKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A concurrent request may have loaded the key first.
+	if state, ok := s.keys[hash]; ok {
+		return state, nil
+	}
+	state = newState(stored.ID, "key:"+stored.ID, stored.Name, stored.Scopes)
+	s.keys[hash] = state
+	return state, nil
+}
+
+type contextKey struct{}
+
+// principal is who the middleware let a request through as.
+type principal struct {
+	caller string
+	// keyed is set for requests authenticated by a key, which carry its scopes.
+	keyed  bool
+	scopes []string
+}
+
+func withPrincipal(r *http.Request, p principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), contextKey{}, p))
+}
+
+// Caller identifies who sent the request with ctx for per-caller accounting: "key:" and
+// the key's ID, "admin" for the admin key, or "ip:" and the client address when
+// authentication is off. It is empty for requests that didn't pass through Middleware.
+func Caller(ctx context.Context) string {
+	p, _ := ctx.Value(contextKey{}).(principal)
+	return p.caller
+}
+
+// Middleware rejects requests without a valid "Authorization: Bearer <key>" header with
+// 401 and requests over the key's rate limit with 429, with Retry-After. Every limited
+// response carries the RateLimit headers. The key's scopes go with the request for
+// RequireScope and RequireAdmin. With authentication off requests are limited by client
+// address instead.
+func (s *KeyStore) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Enabled() {
+			caller := "ip:" + ratelimit.ClientIP(r, s.trustProxy)
+			if !s.allow(w, s.ipLimits, caller) {
+				return
+			}
+			next.ServeHTTP(w, withPrincipal(r, principal{caller: caller}))
+			return
+		}
+		if validAdminKey(s.adminKeys, r.Header.Get(AdminKeyHeader)) {
+			next.ServeHTTP(w, withPrincipal(r, principal{caller: "admin", keyed: true, scopes: []string{ScopeAdmin}}))
+			return
+		}
+		key, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="nara-chess"`)
+			apierror.Write(w, http.StatusUnauthorized, apierror.Unauthorized, "Missing API key")
+			return
+		}
+
+		state, err := s.lookup(key)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error looking up API key", "err", err)
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to check API key")
+			return
+		}
+		if state == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="nara-chess", error="invalid_token"`)
+			apierror.Write(w, http.StatusUnauthorized, apierror.Unauthorized, "Invalid API key")
+			return
+		}
+		allowed := s.allow(w, s.keyLimits, state.caller)
+		s.mu.Lock()
+		state.requests++
+		if !allowed {
+			state.limited++
+		}
+		s.mu.Unlock()
+		if !allowed {
+			return
+		}
+
+		next.ServeHTTP(w, withPrincipal(r, principal{caller: state.caller, keyed: true, scopes: state.scopes}))
+	})
+}
+
+// allow takes a request from caller's bucket in limits, or answers 429 when it is empty.
+func (s *KeyStore) allow(w http.ResponseWriter, limits *ratelimit.Buckets, caller string) bool {
+	if limits == nil {
+		return true
+	}
+	status := limits.Allow(caller)
+	status.SetHeaders(w.Header(), "RateLimit")
+	if !status.Allowed {
+		apierror.Write(w, http.StatusTooManyRequests, apierror.RateLimited, "Rate limit exceeded")
+	}
+	return status.Allowed
+}
+
+// hasScope reports whether the request's API key has scope. ok is false when the request
+// carries no key, because authentication is off.
+func hasScope(r *http.Request, scope string) (has, ok bool) {
+	p, _ := r.Context().Value(contextKey{}).(principal)
+	return slices.Contains(p.scopes, scope), p.keyed
+}
+
+// RequireScope refuses requests whose API key lacks scope with 403. With authentication
+// off every request is let through.
+func RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if has, ok := hasScope(r, scope); ok && !has {
+			apierror.Write(w, http.StatusForbidden, apierror.Forbidden, fmt.Sprintf("API key lacks the %q scope", scope))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AdminKeyHeader carries the admin key, separately from the API key in Authorization.
+const AdminKeyHeader = "X-Admin-Key"
+
+// RequireAdmin lets through only requests whose X-Admin-Key header matches one of
+// adminKeys or whose API key has ScopeAdmin. With neither configured every request is
+// refused, so admin endpoints are off by default.
+func RequireAdmin(adminKeys []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if has, _ := hasScope(r, ScopeAdmin); has {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(adminKeys) == 0 {
+			apierror.Write(w, http.StatusForbidden, apierror.Forbidden, "Admin access is not configured")
+			return
+		}
+		if !validAdminKey(adminKeys, r.Header.Get(AdminKeyHeader)) {
+			apierror.Write(w, http.StatusForbidden, apierror.Forbidden, "Invalid admin key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validAdminKey(adminKeys []string, given string) bool {
+	if given == "" {
+		return false
+	}
+	for _, key := range adminKeys {
+		if subtle.ConstantTimeCompare([]byte(given), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Usage returns a snapshot of per-key usage since the start, keyed by a redacted form of
+// each configured key and by the name of each stored key used.
+func (s *KeyStore) Usage() map[string]Usage {
+	usage := make(map[string]Usage)
+	if s == nil {
+		return usage
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, state := range s.keys {
+		usage[state.label] = Usage{Requests: state.requests, Limited: state.limited}
+	}
+	return usage
+}
+
+// Redact shortens a key to a recognizable prefix that is safe to log.
+func Redact(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return key[:4] + "****"
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"arnavsurve/nara-chess/server/pkg/store"
+)
+
+const testKey = "nara_test_key_0123456789"
+
+// callerEcho answers with the Caller the middleware let the request through as.
+var callerEcho = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(Caller(r.Context())))
+})
+
+func newTestKeyStore(t *testing.T, keys []string, db store.APIKeyStore, limits Limits) *KeyStore {
+	t.Helper()
+	s, err := NewKeyStore(keys, []string{"admin-secret"}, db, limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func request(s *KeyStore, header, value string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	w := httptest.NewRecorder()
+	s.Middleware(callerEcho).ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	s := newTestKeyStore(t, []string{testKey}, nil, Limits{})
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		status int
+		caller string
+	}{
+		{name: "missing key", status: http.StatusUnauthorized},
+		{name: "not a bearer token", header: "Authorization", value: "Basic " + testKey, status: http.StatusUnauthorized},
+		{name: "empty bearer token", header: "Authorization", value: "Bearer ", status: http.StatusUnauthorized},
+		{name: "invalid key", header: "Authorization", value: "Bearer nara_wrong", status: http.StatusUnauthorized},
+		{name: "valid key", header: "Authorization", value: "Bearer " + testKey, status: http.StatusOK, caller: "key:" + HashKey(testKey)[:16]},
+		{name: "lowercase scheme", header: "Authorization", value: "bearer " + testKey, status: http.StatusOK, caller: "key:" + HashKey(testKey)[:16]},
+		{name: "admin key", header: AdminKeyHeader, value: "admin-secret", status: http.StatusOK, caller: "admin"},
+		{name: "wrong admin key", header: AdminKeyHeader, value: "guess", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(s, tt.header, tt.value)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+			if tt.status == http.StatusOK && w.Body.String() != tt.caller {
+				t.Errorf("caller = %q, want %q", w.Body.String(), tt.caller)
+			}
+		})
+	}
+}
+
+func TestMiddlewareDisabled(t *testing.T) {
+	s := newTestKeyStore(t, nil, nil, Limits{})
+	if s.Enabled() {
+		t.Fatal("a store without keys is enabled")
+	}
+	w := request(s, "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 with authentication off", w.Code)
+	}
+	if w.Body.String() != "ip:192.0.2.1" {
+		t.Errorf("caller = %q, want the client address", w.Body.String())
+	}
+}
+
+func TestMiddlewareStoredKey(t *testing.T) {
+	db := store.NewMemoryStore()
+	s := newTestKeyStore(t, nil, db, Limits{})
+	key, stored, err := s.Create("ci", []string{ScopeCoach})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := request(s, "Authorization", "Bearer "+key); w.Code != http.StatusOK || w.Body.String() != "key:"+stored.ID {
+		t.Fatalf("stored key: status %d, caller %q", w.Code, w.Body.String())
+	}
+	// Another stored key keeps authentication on once the first is revoked.
+	if _, _, err := s.Create("other", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke(stored.ID); err != nil {
+		t.Fatal(err)
+	}
+	if w := request(s, "Authorization", "Bearer "+key); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key: status = %d, want 401", w.Code)
+	}
+}
+
+func TestMiddlewareRateLimitsPerKey(t *testing.T) {
+	s := newTestKeyStore(t, []string{testKey}, nil, Limits{PerKey: 2})
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if w := request(s, "Authorization", "Bearer "+testKey); w.Code != want {
+			t.Errorf("request %d: status = %d, want %d", i+1, w.Code, want)
+		}
+	}
+	usage := s.Usage()[Redact(testKey)]
+	if usage.Requests != 3 || usage.Limited != 1 {
+		t.Errorf("usage = %+v, want 3 requests, 1 limited", usage)
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	db := store.NewMemoryStore()
+	s := newTestKeyStore(t, nil, db, Limits{})
+	coachKey, _, err := s.Create("coach", []string{ScopeCoach})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainKey, _, err := s.Create("plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := s.Middleware(RequireScope(ScopeCoach, callerEcho))
+	for key, want := range map[string]int{coachKey: http.StatusOK, plainKey: http.StatusForbidden} {
+		r := httptest.NewRequest(http.MethodPost, "/api/generate-move", nil)
+		r.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("key %s: status = %d, want %d", Redact(key), w.Code, want)
+		}
+	}
+}
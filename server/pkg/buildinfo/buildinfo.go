@@ -0,0 +1,54 @@
+// Package buildinfo reports which build of the server is running, for /version.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Commit and BuildTime can be set when building, for builds made outside a git checkout:
+//
+//	go build -ldflags "-X arnavsurve/nara-chess/server/pkg/buildinfo.Commit=$(git rev-parse HEAD) \
+//		-X arnavsurve/nara-chess/server/pkg/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd
+//
+// Otherwise they come from the version control details the go command stamps into
+// binaries built in a checkout.
+var (
+	Commit    string
+	BuildTime string
+)
+
+// Info describes the running build. BuildTime is the commit's time when the build's own
+// wasn't recorded.
+type Info struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time,omitempty"`
+	// Modified reports uncommitted changes in the checkout the binary was built from.
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Read returns the running build's details, with "unknown" for a commit nobody recorded.
+func Read() Info {
+	info := Info{Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	return info
+}
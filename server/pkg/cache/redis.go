@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds each Redis command, so a slow Redis costs a cache miss rather than
+// holding up the request.
+const redisTimeout = time.Second
+
+// Redis is a Backing shared by every server instance using the same Redis, so a response
+// one instance computed is served by all of them. Entries expire through Redis's own TTLs.
+type Redis struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedis returns a backing keeping its entries in client under keys starting with prefix.
+func NewRedis(client redis.UniversalClient, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+func (r *Redis) LoadEntry(key string) ([]byte, time.Time, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	pipe := r.client.Pipeline()
+	get := pipe.Get(ctx, r.prefix+key)
+	ttl := pipe.PTTL(ctx, r.prefix+key)
+	if _, err := pipe.Exec(ctx); errors.Is(err, redis.Nil) {
+		return nil, time.Time{}, false, nil
+	} else if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	value, _ := get.Bytes()
+	// A key without a TTL reports a negative duration and never expires.
+	var expires time.Time
+	if d := ttl.Val(); d > 0 {
+		expires = time.Now().Add(d)
+	}
+	return value, expires, true, nil
+}
+
+func (r *Redis) SaveEntry(key string, value []byte, expires time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	var ttl time.Duration
+	if !expires.IsZero() {
+		ttl = time.Until(expires)
+		if ttl <= 0 {
+			return nil
+		}
+	}
+	return r.client.Set(ctx, r.prefix+key, value, ttl).Err()
+}
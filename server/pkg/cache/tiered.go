@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Backing is storage behind a Tiered cache that outlives the process, such as a database
+// table shared by several caches.
+type Backing interface {
+	// LoadEntry returns the value stored under key and when it expires. ok is false when
+	// there is none or it has expired.
+	LoadEntry(key string) (value []byte, expires time.Time, ok bool, err error)
+	// SaveEntry stores value under key until expires; a zero expires keeps it for good.
+	SaveEntry(key string, value []byte, expires time.Time) error
+}
+
+// Tiered is an LRU in front of a Backing: lookups the LRU misses are tried in the backing,
+// and every value added is written to both, encoded as JSON. Keys are stored in the
+// backing under the cache's name, so caches can share one. A nil backing leaves just the
+// LRU. Backing failures are logged and treated as misses, since a cache is only ever an
+// optimisation.
+type Tiered[V any] struct {
+	name    string
+	lru     *LRU[string, V]
+	backing Backing
+	ttl     time.Duration
+
+	hits, loaded, misses atomic.Int64
+}
+
+// TieredStats counts a Tiered cache's lookups. Loaded counts the hits that came from the
+// backing rather than memory.
+type TieredStats struct {
+	Hits   int64 `json:"hits"`
+	Loaded int64 `json:"loaded"`
+	Misses int64 `json:"misses"`
+}
+
+func NewTiered[V any](name string, capacity int, ttl time.Duration, backing Backing) *Tiered[V] {
+	return &Tiered[V]{
+		name:    name,
+		lru:     NewLRU[string, V](capacity, ttl),
+		backing: backing,
+		ttl:     ttl,
+	}
+}
+
+// Get returns the value stored under key, loading it into memory from the backing when
+// only the backing has it.
+func (c *Tiered[V]) Get(key string) (V, bool) {
+	if v, ok := c.lru.Get(key); ok {
+		c.hits.Add(1)
+		return v, true
+	}
+	var zero V
+	if c.backing == nil {
+		c.misses.Add(1)
+		return zero, false
+	}
+	data, expires, ok, err := c.backing.LoadEntry(c.name + "|" + key)
+	if err != nil {
+		slog.Error("Error loading cache entry", "cache", c.name, "err", err)
+	}
+	if !ok {
+		c.misses.Add(1)
+		return zero, false
+	}
+	var v V
+	if err := json.Unmarshal(data, &v); err != nil {
+		slog.Error("Error decoding cache entry", "cache", c.name, "err", err)
+		c.misses.Add(1)
+		return zero, false
+	}
+	c.lru.addUntil(key, v, expires)
+	c.hits.Add(1)
+	c.loaded.Add(1)
+	return v, true
+}
+
+// Add stores value under key in memory and in the backing.
+func (c *Tiered[V]) Add(key string, value V) {
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+	c.lru.addUntil(key, value, expires)
+	if c.backing == nil {
+		return
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		slog.Error("Error encoding cache entry", "cache", c.name, "err", err)
+		return
+	}
+	if err := c.backing.SaveEntry(c.name+"|"+key, data, expires); err != nil {
+		slog.Error("Error saving cache entry", "cache", c.name, "err", err)
+	}
+}
+
+func (c *Tiered[V]) Stats() TieredStats {
+	return TieredStats{Hits: c.hits.Load(), Loaded: c.loaded.Load(), Misses: c.misses.Load()}
+}
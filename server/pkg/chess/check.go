@@ -0,0 +1,43 @@
+package chess
+
+// CheckInfo describes the check, if any, delivered by a move.
+type CheckInfo struct {
+	Check      bool
+	Discovered bool // at least one checker is not the piece that moved
+	Double     bool // two pieces give check at once
+	Checkers   []Square
+}
+
+// CheckInfo reports whether m gives check and, if so, whether the check is direct,
+// discovered, or a double check. The move is assumed to be legal.
+func (p *Position) CheckInfo(m Move) CheckInfo {
+	next := p.Play(m)
+	king := next.KingSquare(next.Turn)
+	if king == NoSquare {
+		return CheckInfo{}
+	}
+
+	checkers := next.Attackers(king, p.Turn)
+	info := CheckInfo{
+		Check:    len(checkers) > 0,
+		Double:   len(checkers) > 1,
+		Checkers: checkers,
+	}
+
+	moved := map[Square]bool{m.To: true}
+	if p.Board[m.From].Type() == King {
+		// The rook that lands next to the king when castling counts as a moved piece.
+		switch int(m.To) - int(m.From) {
+		case 2:
+			moved[NewSquare(5, m.From.Rank())] = true
+		case -2:
+			moved[NewSquare(3, m.From.Rank())] = true
+		}
+	}
+	for _, sq := range checkers {
+		if !moved[sq] {
+			info.Discovered = true
+		}
+	}
+	return info
+}
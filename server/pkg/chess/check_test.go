@@ -0,0 +1,102 @@
+package chess
+
+import (
+	"slices"
+	"testing"
+)
+
+func mustParseFEN(t *testing.T, fen string) *Position {
+	t.Helper()
+	pos, err := ParseFEN(fen)
+	if err != nil {
+		t.Fatalf("ParseFEN(%q): %v", fen, err)
+	}
+	return pos
+}
+
+func mustParseSAN(t *testing.T, pos *Position, san string) Move {
+	t.Helper()
+	m, err := pos.ParseSAN(san)
+	if err != nil {
+		t.Fatalf("ParseSAN(%q) in %s: %v", san, pos.FEN(), err)
+	}
+	return m
+}
+
+func TestCheckInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		fen      string
+		move     string
+		want     CheckInfo
+		checkers []string
+	}{
+		{
+			name: "quiet move",
+			fen:  StartFEN,
+			move: "e4",
+		},
+		{
+			name:     "direct check",
+			fen:      "4k3/8/8/8/8/8/8/R3K3 w - - 0 1",
+			move:     "Ra8+",
+			want:     CheckInfo{Check: true},
+			checkers: []string{"a8"},
+		},
+		{
+			name:     "discovered check",
+			fen:      "4k3/8/8/8/8/8/4B3/4R1K1 w - - 0 1",
+			move:     "Bd3+",
+			want:     CheckInfo{Check: true, Discovered: true},
+			checkers: []string{"e1"},
+		},
+		{
+			name:     "double check",
+			fen:      "4k3/8/8/8/8/8/4B3/4R1K1 w - - 0 1",
+			move:     "Bb5+",
+			want:     CheckInfo{Check: true, Discovered: true, Double: true},
+			checkers: []string{"e1", "b5"},
+		},
+		{
+			name:     "discovered check by en passant",
+			fen:      "8/8/8/k1pP3R/8/8/8/4K3 w - c6 0 1",
+			move:     "dxc6+",
+			want:     CheckInfo{Check: true, Discovered: true},
+			checkers: []string{"h5"},
+		},
+		{
+			name:     "castling rook gives check",
+			fen:      "5k2/8/8/8/8/8/8/4K2R w K - 0 1",
+			move:     "O-O+",
+			want:     CheckInfo{Check: true},
+			checkers: []string{"f1"},
+		},
+		{
+			name:     "black gives check",
+			fen:      "4k3/8/8/8/8/8/3q4/6K1 b - - 0 1",
+			move:     "Qd1+",
+			want:     CheckInfo{Check: true},
+			checkers: []string{"d1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos := mustParseFEN(t, tt.fen)
+			got := pos.CheckInfo(mustParseSAN(t, pos, tt.move))
+			if got.Check != tt.want.Check || got.Discovered != tt.want.Discovered || got.Double != tt.want.Double {
+				t.Errorf("CheckInfo(%s) = check %v, discovered %v, double %v; want %v, %v, %v", tt.move,
+					got.Check, got.Discovered, got.Double, tt.want.Check, tt.want.Discovered, tt.want.Double)
+			}
+			checkers := make([]string, len(got.Checkers))
+			for i, sq := range got.Checkers {
+				checkers[i] = sq.String()
+			}
+			slices.Sort(checkers)
+			want := slices.Clone(tt.checkers)
+			slices.Sort(want)
+			if !slices.Equal(checkers, want) {
+				t.Errorf("CheckInfo(%s) checkers = %v, want %v", tt.move, checkers, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package chess
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FEN field names, as reported in FENError.Field. FENFields is used for problems with the
+// FEN as a whole, such as a missing field.
+const (
+	FENFields         = "fen"
+	FENPlacement      = "placement"
+	FENSideToMove     = "side_to_move"
+	FENCastling       = "castling"
+	FENEnPassant      = "en_passant"
+	FENHalfmoveClock  = "halfmove_clock"
+	FENFullmoveNumber = "fullmove_number"
+)
+
+// FENError reports a FEN that cannot be parsed. Field names the offending field and Value
+// holds its text.
+type FENError struct {
+	Field  string
+	Value  string
+	Reason string
+}
+
+func (e *FENError) Error() string {
+	if e.Field == FENFields {
+		return "invalid FEN: " + e.Reason
+	}
+	return fmt.Sprintf("invalid FEN %s field %q: %s", e.Field, e.Value, e.Reason)
+}
+
+// ParseFEN parses all six FEN fields; the move counters may be left off and default to 0
+// and 1. Piece placement, side to move, castling rights, en passant square and counters
+// are checked for syntax only, and a malformed field is reported as a *FENError. Whether
+// the position could arise in a game is left to ValidatePosition.
+//
+// A placement ending in a bracketed pocket, such as "...RNBQKB1R[Nn]", is a Crazyhouse
+// position; any other FEN is standard chess. ParseVariantFEN reads a FEN under a known
+// variant.
+func ParseFEN(fen string) (*Position, error) {
+	variant := Standard
+	if fields := strings.Fields(fen); len(fields) > 0 && strings.HasSuffix(fields[0], "]") {
+		variant = Crazyhouse
+	}
+	return ParseVariantFEN(fen, variant)
+}
+
+// ParseVariantFEN is ParseFEN for a position played under variant. Only Crazyhouse
+// positions may carry a pocket, and one without a pocket starts with both pockets empty.
+func ParseVariantFEN(fen string, variant Variant) (*Position, error) {
+	fields := strings.Fields(fen)
+	if len(fields) < 4 || len(fields) > 6 {
+		return nil, &FENError{Field: FENFields, Value: fen, Reason: fmt.Sprintf("expected 4 to 6 fields, got %d", len(fields))}
+	}
+
+	pos := &Position{EnPassant: NoSquare, FullmoveNumber: 1, Variant: variant}
+	placement := fields[0]
+	if i := strings.IndexByte(placement, '['); i >= 0 {
+		if variant != Crazyhouse {
+			return nil, &FENError{Field: FENPlacement, Value: fields[0], Reason: fmt.Sprintf("pockets are only used in %s, not %s", Crazyhouse.Title(), variant.Title())}
+		}
+		if err := parsePockets(pos, fields[0], placement[i:]); err != nil {
+			return nil, err
+		}
+		placement = placement[:i]
+	}
+	if err := parsePlacement(pos, placement); err != nil {
+		return nil, err
+	}
+
+	switch fields[1] {
+	case "w":
+		pos.Turn = White
+	case "b":
+		pos.Turn = Black
+	default:
+		return nil, &FENError{Field: FENSideToMove, Value: fields[1], Reason: `must be "w" or "b"`}
+	}
+
+	if fields[2] != "-" {
+		for _, c := range fields[2] {
+			var right CastlingRights
+			switch c {
+			case 'K':
+				right = WhiteKingSide
+			case 'Q':
+				right = WhiteQueenSide
+			case 'k':
+				right = BlackKingSide
+			case 'q':
+				right = BlackQueenSide
+			default:
+				return nil, &FENError{Field: FENCastling, Value: fields[2], Reason: fmt.Sprintf(`unknown right %q; use "-" or letters from KQkq`, c)}
+			}
+			if pos.Castling&right != 0 {
+				return nil, &FENError{Field: FENCastling, Value: fields[2], Reason: fmt.Sprintf("right %q is repeated", c)}
+			}
+			pos.Castling |= right
+		}
+	}
+
+	if fields[3] != "-" {
+		sq, err := ParseSquare(fields[3])
+		if err != nil {
+			return nil, &FENError{Field: FENEnPassant, Value: fields[3], Reason: `must be "-" or a square such as e3`}
+		}
+		want := 5 // White to move: Black's pawn skipped a square on the sixth rank
+		if pos.Turn == Black {
+			want = 2
+		}
+		if sq.Rank() != want {
+			return nil, &FENError{Field: FENEnPassant, Value: fields[3], Reason: fmt.Sprintf("must be on rank %d with %s to move", want+1, pos.Turn)}
+		}
+		pos.EnPassant = sq
+	}
+
+	if len(fields) > 4 {
+		n, ok := fenCounter(fields[4])
+		if !ok {
+			return nil, &FENError{Field: FENHalfmoveClock, Value: fields[4], Reason: "must be a non-negative integer"}
+		}
+		pos.HalfmoveClock = n
+	}
+	if len(fields) > 5 {
+		n, ok := fenCounter(fields[5])
+		if !ok || n < 1 {
+			return nil, &FENError{Field: FENFullmoveNumber, Value: fields[5], Reason: "must be a positive integer"}
+		}
+		pos.FullmoveNumber = n
+	}
+
+	return pos, nil
+}
+
+func parsePlacement(pos *Position, placement string) error {
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		return &FENError{Field: FENPlacement, Value: placement, Reason: fmt.Sprintf("expected 8 ranks, got %d", len(ranks))}
+	}
+	for i, row := range ranks {
+		rank := 7 - i
+		file := 0
+		for j := 0; j < len(row); j++ {
+			c := row[j]
+			if c >= '1' && c <= '8' {
+				if j > 0 && row[j-1] >= '1' && row[j-1] <= '8' {
+					return &FENError{Field: FENPlacement, Value: placement, Reason: fmt.Sprintf("rank %d has consecutive empty-square counts", rank+1)}
+				}
+				file += int(c - '0')
+				continue
+			}
+			if c == '~' {
+				if pos.Variant != Crazyhouse || j == 0 || pieceTypeFromLetter(row[j-1]) == NoPieceType {
+					return &FENError{Field: FENPlacement, Value: placement, Reason: fmt.Sprintf("unexpected '~' on rank %d; it marks a promoted piece in %s", rank+1, Crazyhouse.Title())}
+				}
+				if file <= 8 {
+					pos.Promoted |= 1 << NewSquare(file-1, rank)
+				}
+				continue
+			}
+			t := pieceTypeFromLetter(c)
+			if t == NoPieceType {
+				return &FENError{Field: FENPlacement, Value: placement, Reason: fmt.Sprintf("unknown piece %q on rank %d", c, rank+1)}
+			}
+			if file < 8 {
+				color := White
+				if c >= 'a' {
+					color = Black
+				}
+				pos.Board[NewSquare(file, rank)] = NewPiece(color, t)
+			}
+			file++
+		}
+		if file != 8 {
+			return &FENError{Field: FENPlacement, Value: placement, Reason: fmt.Sprintf("rank %d describes %d squares, not 8", rank+1, file)}
+		}
+	}
+	return nil
+}
+
+// parsePockets reads a Crazyhouse pocket such as "[QNnpp]", listing the pieces in hand
+// for both sides. field is the whole placement field, for error reports.
+func parsePockets(pos *Position, field, pockets string) error {
+	if !strings.HasSuffix(pockets, "]") || strings.Count(pockets, "[") != 1 {
+		return &FENError{Field: FENPlacement, Value: field, Reason: "the pocket must be a single bracketed list at the end, like [Nn]"}
+	}
+	for i := 1; i < len(pockets)-1; i++ {
+		c := pockets[i]
+		t := pieceTypeFromLetter(c)
+		if t == NoPieceType || t == King {
+			return &FENError{Field: FENPlacement, Value: field, Reason: fmt.Sprintf("pocket holds %q; only pawns, knights, bishops, rooks and queens can be in hand", c)}
+		}
+		color := White
+		if c >= 'a' {
+			color = Black
+		}
+		pos.Pockets[color][t]++
+	}
+	return nil
+}
+
+// fenCounter parses a move counter, which must be plain decimal digits.
+func fenCounter(s string) (int, bool) {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
@@ -0,0 +1,44 @@
+package chess
+
+import "arnavsurve/nara-chess/server/pkg/cache"
+
+// legalMoveCacheSize caps the number of positions whose legal moves are remembered.
+const legalMoveCacheSize = 4096
+
+// legalMoveCache remembers generated legal moves by Zobrist hash. Each entry keeps the
+// position's identifying fields so a hash collision is detected instead of returning
+// another position's moves.
+var legalMoveCache = cache.NewLRU[uint64, legalMoveEntry](legalMoveCacheSize, 0)
+
+type legalMoveEntry struct {
+	board     [64]Piece
+	turn      Color
+	castling  CastlingRights
+	enPassant Square
+	variant   Variant
+	pockets   [2]Pocket
+	moves     []Move
+}
+
+// CachedLegalMoves is LegalMoves backed by a shared cache, for callers that generate
+// moves for the same positions repeatedly. The returned slice is the caller's own.
+func (p *Position) CachedLegalMoves() []Move {
+	h := p.Hash()
+	if e, ok := legalMoveCache.Get(h); ok &&
+		e.board == p.Board && e.turn == p.Turn && e.castling == p.Castling && e.enPassant == p.EnPassant &&
+		e.variant == p.Variant && e.pockets == p.Pockets {
+		return append([]Move(nil), e.moves...)
+	}
+
+	moves := p.LegalMoves()
+	legalMoveCache.Add(h, legalMoveEntry{
+		board:     p.Board,
+		turn:      p.Turn,
+		castling:  p.Castling,
+		enPassant: p.EnPassant,
+		variant:   p.Variant,
+		pockets:   p.Pockets,
+		moves:     append([]Move(nil), moves...),
+	})
+	return moves
+}
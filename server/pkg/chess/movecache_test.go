@@ -0,0 +1,172 @@
+package chess
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// operaGame is Morphy's Opera Game, a short game with castling, captures and checks on
+// the way to mate.
+var operaGame = strings.Fields("e4 e5 Nf3 d6 d4 Bg4 dxe5 Bxf3 Qxf3 dxe5 Bc4 Nf6 Qb3 Qe7 Nc3 c6 Bg5 b5 Nxb5 cxb5 Bxb5+ Nbd7 O-O-O Rd8 Rxd7 Rxd7 Rd1 Qe6 Bxd7+ Nxd7 Qb8+ Nxb8 Rd8#")
+
+// moveSet renders moves in a canonical order for comparison.
+func moveSet(moves []Move) []string {
+	s := make([]string, len(moves))
+	for i, m := range moves {
+		s[i] = m.String()
+	}
+	slices.Sort(s)
+	return s
+}
+
+// playout returns the positions of a deterministic walk of up to plies moves from pos.
+func playout(pos *Position, plies int) []*Position {
+	positions := []*Position{pos}
+	for i := range plies {
+		moves := pos.LegalMoves()
+		if len(moves) == 0 {
+			break
+		}
+		pos = pos.Play(moves[(7*i+3)%len(moves)])
+		positions = append(positions, pos)
+	}
+	return positions
+}
+
+func TestCachedLegalMovesMatchFresh(t *testing.T) {
+	for _, fen := range []string{
+		StartFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", // castling both ways, pins
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",                            // en passant along a pinned rank
+		"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1",                              // promotions with capture
+	} {
+		t.Run(fen, func(t *testing.T) {
+			for _, pos := range playout(mustParseFEN(t, fen), 40) {
+				fresh := moveSet(pos.LegalMoves())
+				// The first call may fill the cache and the second is served from it.
+				for range 2 {
+					if cached := moveSet(pos.CachedLegalMoves()); !slices.Equal(cached, fresh) {
+						t.Fatalf("%s: cached moves %v, fresh %v", pos.FEN(), cached, fresh)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCachedLegalMovesTranspositions(t *testing.T) {
+	// The same position reached by two move orders shares an entry.
+	a, err := Replay(NewGame(), []string{"Nf3", "Nf6", "g3", "g6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Replay(NewGame(), []string{"g3", "g6", "Nf3", "Nf6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, y := a[len(a)-1], b[len(b)-1]
+	if x.Hash() != y.Hash() {
+		t.Fatal("transposed positions hash differently")
+	}
+	if !slices.Equal(moveSet(x.CachedLegalMoves()), moveSet(y.LegalMoves())) {
+		t.Error("transposed position got other moves from the cache")
+	}
+
+	// Positions differing only in castling rights or en passant have moves of their own.
+	for _, pair := range [][2]string{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "r3k2r/8/8/8/8/8/8/R3K2R w - - 0 1"},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "4k3/8/8/3pP3/8/8/8/4K3 w - - 0 1"},
+	} {
+		for _, fen := range pair {
+			pos := mustParseFEN(t, fen)
+			if cached, fresh := moveSet(pos.CachedLegalMoves()), moveSet(pos.LegalMoves()); !slices.Equal(cached, fresh) {
+				t.Errorf("%s: cached moves %v, fresh %v", fen, cached, fresh)
+			}
+		}
+	}
+}
+
+func TestCachedLegalMovesDetectsCollision(t *testing.T) {
+	pos := mustParseFEN(t, "4k3/8/8/8/8/8/8/4K2R w K - 0 1")
+	other := mustParseFEN(t, StartFEN)
+	// Plant another position's moves under pos's hash.
+	legalMoveCache.Add(pos.Hash(), legalMoveEntry{
+		board:     other.Board,
+		turn:      other.Turn,
+		castling:  other.Castling,
+		enPassant: other.EnPassant,
+		variant:   other.Variant,
+		moves:     other.LegalMoves(),
+	})
+	if cached, fresh := moveSet(pos.CachedLegalMoves()), moveSet(pos.LegalMoves()); !slices.Equal(cached, fresh) {
+		t.Errorf("cached moves %v after a collision, want %v", cached, fresh)
+	}
+}
+
+func TestCachedLegalMovesCallerOwnsSlice(t *testing.T) {
+	pos := mustParseFEN(t, StartFEN)
+	want := moveSet(pos.LegalMoves())
+	moves := pos.CachedLegalMoves()
+	for i := range moves {
+		moves[i] = Move{}
+	}
+	if got := moveSet(pos.CachedLegalMoves()); !slices.Equal(got, want) {
+		t.Errorf("cached moves %v after the caller changed its copy", got)
+	}
+}
+
+// Positions are values, so a move is unmade by going back to the position it was played
+// from; the hash must come back with it.
+func TestHashMakeUnmake(t *testing.T) {
+	for _, pos := range playout(mustParseFEN(t, "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"), 30) {
+		before, fen := pos.Hash(), pos.FEN()
+		for _, m := range pos.LegalMoves() {
+			next := pos.Play(m)
+			if next.Hash() == before {
+				t.Errorf("%s: %s leaves the hash unchanged", fen, m)
+			}
+			// The hash depends on the position alone, not the moves that reached it.
+			if reparsed := mustParseFEN(t, next.FEN()); reparsed.Hash() != next.Hash() {
+				t.Errorf("%s after %s hashes differently once reparsed", fen, m)
+			}
+		}
+		if pos.Hash() != before || pos.FEN() != fen {
+			t.Fatalf("playing moves from %s changed it", fen)
+		}
+	}
+
+	// Knights out and back restore the start position with different move clocks.
+	positions, err := Replay(NewGame(), []string{"Nf3", "Nf6", "Ng1", "Ng8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back := positions[len(positions)-1]; back.Hash() != NewGame().Hash() {
+		t.Error("returning to the start position changed the hash")
+	}
+}
+
+func BenchmarkReplay(b *testing.B) {
+	for range b.N {
+		if _, err := Replay(NewGame(), operaGame); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLegalMoves(b *testing.B) {
+	positions, err := Replay(NewGame(), operaGame)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("fresh", func(b *testing.B) {
+		for i := range b.N {
+			positions[i%len(positions)].LegalMoves()
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		for i := range b.N {
+			positions[i%len(positions)].CachedLegalMoves()
+		}
+	})
+}
@@ -0,0 +1,243 @@
+package chess
+
+var (
+	knightOffsets = [8][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	kingOffsets   = [8][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	bishopDirs    = [4][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+	rookDirs      = [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+)
+
+var promotionTypes = [4]PieceType{Queen, Rook, Bishop, Knight}
+
+func offset(sq Square, df, dr int) (Square, bool) {
+	f, r := sq.File()+df, sq.Rank()+dr
+	if f < 0 || f > 7 || r < 0 || r > 7 {
+		return NoSquare, false
+	}
+	return NewSquare(f, r), true
+}
+
+// LegalMoves returns every legal move for the side to move, ordered by origin square, with
+// any Crazyhouse drops last.
+func (p *Position) LegalMoves() []Move {
+	pseudo := p.pseudoLegalMoves()
+	legal := pseudo[:0]
+	// A drop cannot expose the king, so drops only need checking when in check.
+	inCheck := p.Variant == Crazyhouse && p.InCheck()
+	for _, m := range pseudo {
+		if m.IsDrop() && !inCheck {
+			legal = append(legal, m)
+			continue
+		}
+		next := p.Play(m)
+		if !next.IsAttacked(next.KingSquare(p.Turn), p.Turn.Other()) {
+			legal = append(legal, m)
+		}
+	}
+	return legal
+}
+
+// IsLegal reports whether m is among the legal moves in the position.
+func (p *Position) IsLegal(m Move) bool {
+	for _, legal := range p.LegalMoves() {
+		if legal == m {
+			return true
+		}
+	}
+	return false
+}
+
+// InCheck reports whether the side to move is in check.
+func (p *Position) InCheck() bool {
+	king := p.KingSquare(p.Turn)
+	return p.IsAttacked(king, p.Turn.Other())
+}
+
+// IsAttacked reports whether any piece of color by attacks sq.
+func (p *Position) IsAttacked(sq Square, by Color) bool {
+	if sq == NoSquare {
+		return false
+	}
+	var buf [16]Square
+	return len(p.appendAttackers(buf[:0], sq, by)) > 0
+}
+
+// Attackers returns the squares of every piece of color by that attacks sq.
+func (p *Position) Attackers(sq Square, by Color) []Square {
+	return p.appendAttackers(nil, sq, by)
+}
+
+func (p *Position) appendAttackers(attackers []Square, sq Square, by Color) []Square {
+	pawnRank := -1
+	if by == Black {
+		pawnRank = 1
+	}
+	for _, df := range [2]int{-1, 1} {
+		if from, ok := offset(sq, df, pawnRank); ok && p.Board[from] == NewPiece(by, Pawn) {
+			attackers = append(attackers, from)
+		}
+	}
+	for _, o := range knightOffsets {
+		if from, ok := offset(sq, o[0], o[1]); ok && p.Board[from] == NewPiece(by, Knight) {
+			attackers = append(attackers, from)
+		}
+	}
+	for _, o := range kingOffsets {
+		if from, ok := offset(sq, o[0], o[1]); ok && p.Board[from] == NewPiece(by, King) {
+			attackers = append(attackers, from)
+		}
+	}
+	attackers = p.appendSliderAttackers(attackers, sq, by, bishopDirs[:], Bishop)
+	attackers = p.appendSliderAttackers(attackers, sq, by, rookDirs[:], Rook)
+
+	return attackers
+}
+
+func (p *Position) appendSliderAttackers(attackers []Square, sq Square, by Color, dirs [][2]int, slider PieceType) []Square {
+	for _, d := range dirs {
+		from, ok := offset(sq, d[0], d[1])
+		for ok {
+			piece := p.Board[from]
+			if piece != NoPiece {
+				if piece.Color() == by && (piece.Type() == slider || piece.Type() == Queen) {
+					attackers = append(attackers, from)
+				}
+				break
+			}
+			from, ok = offset(from, d[0], d[1])
+		}
+	}
+	return attackers
+}
+
+func (p *Position) pseudoLegalMoves() []Move {
+	moves := make([]Move, 0, 48)
+	for i, piece := range p.Board {
+		if piece == NoPiece || piece.Color() != p.Turn {
+			continue
+		}
+		from := Square(i)
+		switch piece.Type() {
+		case Pawn:
+			moves = p.appendPawnMoves(moves, from)
+		case Knight:
+			moves = p.appendStepMoves(moves, from, knightOffsets[:])
+		case Bishop:
+			moves = p.appendSlideMoves(moves, from, bishopDirs[:])
+		case Rook:
+			moves = p.appendSlideMoves(moves, from, rookDirs[:])
+		case Queen:
+			moves = p.appendSlideMoves(moves, from, bishopDirs[:])
+			moves = p.appendSlideMoves(moves, from, rookDirs[:])
+		case King:
+			moves = p.appendStepMoves(moves, from, kingOffsets[:])
+			moves = p.appendCastlingMoves(moves, from)
+		}
+	}
+	if p.Variant == Crazyhouse {
+		moves = p.appendDrops(moves)
+	}
+	return moves
+}
+
+func (p *Position) appendPawnMoves(moves []Move, from Square) []Move {
+	dir, startRank, lastRank := 1, 1, 7
+	if p.Turn == Black {
+		dir, startRank, lastRank = -1, 6, 0
+	}
+
+	add := func(to Square) {
+		if to.Rank() == lastRank {
+			for _, t := range promotionTypes {
+				moves = append(moves, Move{From: from, To: to, Promotion: t})
+			}
+			return
+		}
+		moves = append(moves, Move{From: from, To: to})
+	}
+
+	if to, ok := offset(from, 0, dir); ok && p.Board[to] == NoPiece {
+		add(to)
+		if from.Rank() == startRank {
+			if to2, ok := offset(to, 0, dir); ok && p.Board[to2] == NoPiece {
+				add(to2)
+			}
+		}
+	}
+	for _, df := range [2]int{-1, 1} {
+		to, ok := offset(from, df, dir)
+		if !ok {
+			continue
+		}
+		target := p.Board[to]
+		if (target != NoPiece && target.Color() != p.Turn) || (target == NoPiece && to == p.EnPassant) {
+			add(to)
+		}
+	}
+	return moves
+}
+
+func (p *Position) appendStepMoves(moves []Move, from Square, offsets [][2]int) []Move {
+	for _, o := range offsets {
+		to, ok := offset(from, o[0], o[1])
+		if !ok {
+			continue
+		}
+		if target := p.Board[to]; target == NoPiece || target.Color() != p.Turn {
+			moves = append(moves, Move{From: from, To: to})
+		}
+	}
+	return moves
+}
+
+func (p *Position) appendSlideMoves(moves []Move, from Square, dirs [][2]int) []Move {
+	for _, d := range dirs {
+		to, ok := offset(from, d[0], d[1])
+		for ok {
+			target := p.Board[to]
+			if target != NoPiece {
+				if target.Color() != p.Turn {
+					moves = append(moves, Move{From: from, To: to})
+				}
+				break
+			}
+			moves = append(moves, Move{From: from, To: to})
+			to, ok = offset(to, d[0], d[1])
+		}
+	}
+	return moves
+}
+
+func (p *Position) appendCastlingMoves(moves []Move, from Square) []Move {
+	kingSide, queenSide, rank := WhiteKingSide, WhiteQueenSide, 0
+	if p.Turn == Black {
+		kingSide, queenSide, rank = BlackKingSide, BlackQueenSide, 7
+	}
+	if from != NewSquare(4, rank) {
+		return moves
+	}
+	enemy := p.Turn.Other()
+	rook := NewPiece(p.Turn, Rook)
+
+	if p.Castling&kingSide != 0 && p.Board[NewSquare(7, rank)] == rook &&
+		p.Board[NewSquare(5, rank)] == NoPiece && p.Board[NewSquare(6, rank)] == NoPiece &&
+		!p.IsAttacked(from, enemy) && !p.IsAttacked(NewSquare(5, rank), enemy) {
+		moves = append(moves, Move{From: from, To: NewSquare(6, rank)})
+	}
+	if p.Castling&queenSide != 0 && p.Board[NewSquare(0, rank)] == rook &&
+		p.Board[NewSquare(1, rank)] == NoPiece && p.Board[NewSquare(2, rank)] == NoPiece && p.Board[NewSquare(3, rank)] == NoPiece &&
+		!p.IsAttacked(from, enemy) && !p.IsAttacked(NewSquare(3, rank), enemy) {
+		moves = append(moves, Move{From: from, To: NewSquare(2, rank)})
+	}
+	return moves
+}
+
+// IsCheckmate reports whether the side to move is checkmated.
+func (p *Position) IsCheckmate() bool {
+	return p.InCheck() && len(p.LegalMoves()) == 0
+}
+
+// IsStalemate reports whether the side to move has no legal moves but is not in check.
+func (p *Position) IsStalemate() bool {
+	return !p.InCheck() && len(p.LegalMoves()) == 0
+}
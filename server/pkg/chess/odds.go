@@ -0,0 +1,70 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Material odds, named by what the stronger side leaves off the board at the start.
+const (
+	OddsPawn   = "pawn"   // the f-pawn
+	OddsKnight = "knight" // the queen's knight
+	OddsRook   = "rook"   // the queen's rook, and with it queenside castling
+	OddsQueen  = "queen"
+)
+
+// Odds lists every handicap OddsPosition can set up, smallest first.
+var Odds = []string{OddsPawn, OddsKnight, OddsRook, OddsQueen}
+
+// OddsPosition returns the standard starting position with giver's pieces for odds
+// removed. odds is one or more names from Odds joined with "+", such as "knight+pawn".
+func OddsPosition(odds string, giver Color) (*Position, error) {
+	pos := NewGame()
+	rank := 0
+	if giver == Black {
+		rank = 7
+	}
+	for _, name := range strings.Split(odds, "+") {
+		var sq Square
+		switch name {
+		case OddsPawn:
+			sq = NewSquare(5, rank+1)
+			if giver == Black {
+				sq = NewSquare(5, rank-1)
+			}
+		case OddsKnight:
+			sq = NewSquare(1, rank)
+		case OddsRook:
+			sq = NewSquare(0, rank)
+			pos.Castling &^= castlingMask(sq)
+		case OddsQueen:
+			sq = NewSquare(3, rank)
+		default:
+			return nil, fmt.Errorf("unknown odds %q; use %s, joined with + to combine", name, strings.Join(Odds, ", "))
+		}
+		if pos.Board[sq] == NoPiece {
+			return nil, fmt.Errorf("odds %q given twice", name)
+		}
+		pos.Board[sq] = NoPiece
+	}
+	return pos, nil
+}
+
+// Handicap reports the squares each side's pieces are missing from when p is the standard
+// starting setup with pieces taken away and nothing else changed, as in an odds game. It
+// reports false for any other position, including the full starting position.
+func (p *Position) Handicap() ([2][]Square, bool) {
+	var missing [2][]Square
+	start := NewGame()
+	for i, piece := range p.Board {
+		want := start.Board[i]
+		switch {
+		case piece == want:
+		case piece == NoPiece:
+			missing[want.Color()] = append(missing[want.Color()], Square(i))
+		default:
+			return [2][]Square{}, false
+		}
+	}
+	return missing, len(missing[White])+len(missing[Black]) > 0
+}
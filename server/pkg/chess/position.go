@@ -0,0 +1,204 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+const StartFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+type Position struct {
+	Board          [64]Piece
+	Turn           Color
+	Castling       CastlingRights
+	EnPassant      Square
+	HalfmoveClock  int
+	FullmoveNumber int
+
+	Variant Variant
+	// Pockets holds each side's pieces in hand and Promoted marks, one bit per square,
+	// the pieces that were pawns before promoting. Both are only used in Crazyhouse.
+	Pockets  [2]Pocket
+	Promoted uint64
+}
+
+func NewGame() *Position {
+	pos, err := ParseFEN(StartFEN)
+	if err != nil {
+		panic(err)
+	}
+	return pos
+}
+
+func (p *Position) FEN() string {
+	var sb strings.Builder
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := 0; file < 8; file++ {
+			piece := p.Board[NewSquare(file, rank)]
+			if piece == NoPiece {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			sb.WriteByte(piece.FENChar())
+			if p.Promoted&(1<<(rank*8+file)) != 0 {
+				sb.WriteByte('~')
+			}
+		}
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+		if rank > 0 {
+			sb.WriteByte('/')
+		}
+	}
+
+	if p.Variant == Crazyhouse {
+		sb.WriteByte('[')
+		for _, c := range []Color{White, Black} {
+			for _, t := range pocketOrder {
+				for range p.Pockets[c][t] {
+					sb.WriteByte(NewPiece(c, t).FENChar())
+				}
+			}
+		}
+		sb.WriteByte(']')
+	}
+
+	turn := "w"
+	if p.Turn == Black {
+		turn = "b"
+	}
+	return fmt.Sprintf("%s %s %s %s %d %d", sb.String(), turn, p.Castling, p.EnPassant, p.HalfmoveClock, p.FullmoveNumber)
+}
+
+func (p *Position) KingSquare(c Color) Square {
+	king := NewPiece(c, King)
+	for sq, piece := range p.Board {
+		if piece == king {
+			return Square(sq)
+		}
+	}
+	return NoSquare
+}
+
+// Play returns the position after m. The move is assumed to be at least pseudo-legal.
+func (p *Position) Play(m Move) *Position {
+	next := *p
+	if m.IsDrop() {
+		next.Board[m.To] = NewPiece(p.Turn, m.Drop)
+		next.Pockets[p.Turn][m.Drop]--
+		next.EnPassant = NoSquare
+		if m.Drop == Pawn {
+			next.HalfmoveClock = 0
+		} else {
+			next.HalfmoveClock++
+		}
+		if p.Turn == Black {
+			next.FullmoveNumber++
+		}
+		next.Turn = p.Turn.Other()
+		return &next
+	}
+
+	piece := p.Board[m.From]
+	captured := p.Board[m.To]
+	if p.Variant == Crazyhouse {
+		next.pocketCapture(m, piece, captured)
+	}
+
+	next.Board[m.From] = NoPiece
+	next.Board[m.To] = piece
+	next.EnPassant = NoSquare
+
+	switch piece.Type() {
+	case Pawn:
+		if m.To == p.EnPassant && captured == NoPiece && m.From.File() != m.To.File() {
+			next.Board[NewSquare(m.To.File(), m.From.Rank())] = NoPiece
+		}
+		if diff := int(m.To) - int(m.From); diff == 16 || diff == -16 {
+			next.EnPassant = Square((int(m.To) + int(m.From)) / 2)
+		}
+		if m.Promotion != NoPieceType {
+			next.Board[m.To] = NewPiece(piece.Color(), m.Promotion)
+		}
+	case King:
+		if diff := int(m.To) - int(m.From); diff == 2 || diff == -2 {
+			rank := m.From.Rank()
+			rookFrom, rookTo := NewSquare(7, rank), NewSquare(5, rank)
+			if diff < 0 {
+				rookFrom, rookTo = NewSquare(0, rank), NewSquare(3, rank)
+			}
+			next.Board[rookTo] = next.Board[rookFrom]
+			next.Board[rookFrom] = NoPiece
+		}
+	}
+
+	next.Castling &^= castlingMask(m.From) | castlingMask(m.To)
+
+	if piece.Type() == Pawn || captured != NoPiece {
+		next.HalfmoveClock = 0
+	} else {
+		next.HalfmoveClock++
+	}
+	if p.Turn == Black {
+		next.FullmoveNumber++
+	}
+	next.Turn = p.Turn.Other()
+
+	return &next
+}
+
+// pocketCapture does the Crazyhouse bookkeeping for a move on the board: the captured
+// piece goes to the mover's pocket, as a pawn if it had promoted, and the promoted marks
+// follow the pieces.
+func (p *Position) pocketCapture(m Move, piece, captured Piece) {
+	from, to := uint64(1)<<m.From, uint64(1)<<m.To
+	switch {
+	case captured != NoPiece && p.Promoted&to != 0:
+		p.Pockets[piece.Color()][Pawn]++
+	case captured != NoPiece:
+		p.Pockets[piece.Color()][captured.Type()]++
+	case piece.Type() == Pawn && m.To == p.EnPassant && m.From.File() != m.To.File():
+		p.Pockets[piece.Color()][Pawn]++
+	}
+	wasPromoted := p.Promoted&from != 0 || m.Promotion != NoPieceType
+	p.Promoted &^= from | to
+	if wasPromoted {
+		p.Promoted |= to
+	}
+}
+
+// IsCapture reports whether m captures a piece, including en passant.
+func (p *Position) IsCapture(m Move) bool {
+	if m.IsDrop() {
+		return false
+	}
+	if p.Board[m.To] != NoPiece {
+		return true
+	}
+	return p.Board[m.From].Type() == Pawn && m.To == p.EnPassant && m.From.File() != m.To.File()
+}
+
+// castlingMask returns the rights lost when a piece moves from or to sq.
+func castlingMask(sq Square) CastlingRights {
+	switch sq {
+	case 4:
+		return WhiteKingSide | WhiteQueenSide
+	case 0:
+		return WhiteQueenSide
+	case 7:
+		return WhiteKingSide
+	case 60:
+		return BlackKingSide | BlackQueenSide
+	case 56:
+		return BlackQueenSide
+	case 63:
+		return BlackKingSide
+	}
+	return 0
+}
@@ -0,0 +1,35 @@
+package chess
+
+import "fmt"
+
+// IllegalMoveError reports the first move of a history that could not be played.
+type IllegalMoveError struct {
+	Ply  int // 1-based index into the history
+	Move string
+	Err  error
+}
+
+func (e *IllegalMoveError) Error() string {
+	return fmt.Sprintf("illegal move %q at ply %d: %v", e.Move, e.Ply, e.Err)
+}
+
+func (e *IllegalMoveError) Unwrap() error {
+	return e.Err
+}
+
+// Replay plays a SAN move history from start and returns the position after each ply.
+// When a move is illegal it returns the positions reached so far together with an
+// *IllegalMoveError.
+func Replay(start *Position, history []string) ([]*Position, error) {
+	positions := make([]*Position, 0, len(history))
+	pos := start
+	for i, san := range history {
+		m, err := pos.ParseSAN(san)
+		if err != nil {
+			return positions, &IllegalMoveError{Ply: i + 1, Move: san, Err: err}
+		}
+		pos = pos.Play(m)
+		positions = append(positions, pos)
+	}
+	return positions, nil
+}
@@ -0,0 +1,104 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SAN formats a legal move in Standard Algebraic Notation, including check and mate suffixes.
+func (p *Position) SAN(m Move) string {
+	return p.sanWithoutSuffix(m, p.CachedLegalMoves()) + p.checkSuffix(m)
+}
+
+func (p *Position) sanWithoutSuffix(m Move, legal []Move) string {
+	if m.IsDrop() {
+		return m.String()
+	}
+	piece := p.Board[m.From]
+
+	if piece.Type() == King {
+		switch int(m.To) - int(m.From) {
+		case 2:
+			return "O-O"
+		case -2:
+			return "O-O-O"
+		}
+	}
+
+	var sb strings.Builder
+	capture := p.IsCapture(m)
+
+	if piece.Type() == Pawn {
+		if capture {
+			sb.WriteByte(byte('a' + m.From.File()))
+		}
+	} else {
+		sb.WriteString(piece.Type().Letter())
+
+		sameFile, sameRank, ambiguous := false, false, false
+		for _, other := range legal {
+			if other.To != m.To || other.From == m.From || other.IsDrop() || p.Board[other.From] != piece {
+				continue
+			}
+			ambiguous = true
+			if other.From.File() == m.From.File() {
+				sameFile = true
+			}
+			if other.From.Rank() == m.From.Rank() {
+				sameRank = true
+			}
+		}
+		if ambiguous {
+			switch {
+			case !sameFile:
+				sb.WriteByte(byte('a' + m.From.File()))
+			case !sameRank:
+				sb.WriteByte(byte('1' + m.From.Rank()))
+			default:
+				sb.WriteString(m.From.String())
+			}
+		}
+	}
+
+	if capture {
+		sb.WriteByte('x')
+	}
+	sb.WriteString(m.To.String())
+	if m.Promotion != NoPieceType {
+		sb.WriteByte('=')
+		sb.WriteString(m.Promotion.Letter())
+	}
+	return sb.String()
+}
+
+func (p *Position) checkSuffix(m Move) string {
+	next := p.Play(m)
+	if !next.InCheck() {
+		return ""
+	}
+	if len(next.CachedLegalMoves()) == 0 {
+		return "#"
+	}
+	return "+"
+}
+
+// ParseSAN resolves a SAN string such as "Nf3", "exd5", "O-O", "e8=Q+" or the Crazyhouse
+// drop "N@f3" to a legal move. Check, mate and annotation suffixes are ignored, and a pawn
+// drop may leave out its letter, as in "@e4".
+func (p *Position) ParseSAN(san string) (Move, error) {
+	want := strings.TrimRight(strings.TrimSpace(san), "+#!?")
+	if want == "" {
+		return Move{}, fmt.Errorf("empty move")
+	}
+	if strings.HasPrefix(want, "@") {
+		want = "P" + want
+	}
+
+	legal := p.CachedLegalMoves()
+	for _, m := range legal {
+		if p.sanWithoutSuffix(m, legal) == want {
+			return m, nil
+		}
+	}
+	return Move{}, fmt.Errorf("illegal or unrecognized move %q in position %s", san, p.FEN())
+}
@@ -0,0 +1,152 @@
+package chess
+
+import "strings"
+
+// Reasons a game has ended.
+const (
+	ReasonCheckmate            = "checkmate"
+	ReasonStalemate            = "stalemate"
+	ReasonInsufficientMaterial = "insufficient_material"
+	ReasonFiftyMoveRule        = "fifty_move_rule"
+	ReasonThreefoldRepetition  = "threefold_repetition"
+	// Games can also end off the board.
+	ReasonResignation = "resignation"
+	ReasonDrawAgreed  = "draw_agreed"
+	ReasonTimeout     = "timeout"
+)
+
+// Kinds of insufficient material, named by the pieces on the board.
+const (
+	KingVsKing             = "KvK"
+	KingBishopVsKing       = "KBvK"
+	KingKnightVsKing       = "KNvK"
+	KingBishopVsKingBishop = "KBvKB"
+)
+
+// Status describes whether a game is over and why.
+type Status struct {
+	Over   bool
+	Result string // "1-0", "0-1" or "1/2-1/2" once the game is over
+	Reason string
+	// Material names the kind of insufficient material, e.g. "KBvK", when Reason is
+	// ReasonInsufficientMaterial.
+	Material string
+}
+
+// GameStatus reports the status of the last position in a game, given every position
+// from the start so that repetitions can be counted. Checkmate, and in King of the Hill a
+// king on the hill, take precedence over the draw rules. Material is only ever
+// insufficient in standard chess: a bare king can still walk to the hill, and captured
+// pieces come back into play in Crazyhouse.
+func GameStatus(positions []*Position) Status {
+	if len(positions) == 0 {
+		return Status{}
+	}
+	p := positions[len(positions)-1]
+
+	if p.Variant == KingOfTheHill {
+		for _, c := range []Color{White, Black} {
+			if p.OnHill(c) {
+				return won(c, ReasonKingOfTheHill)
+			}
+		}
+	}
+
+	if len(p.LegalMoves()) == 0 {
+		if p.InCheck() {
+			return won(p.Turn.Other(), ReasonCheckmate)
+		}
+		return drawn(ReasonStalemate)
+	}
+	if kind, ok := p.InsufficientMaterial(); ok && p.Variant == Standard {
+		s := drawn(ReasonInsufficientMaterial)
+		s.Material = kind
+		return s
+	}
+	if p.HalfmoveClock >= 100 {
+		return drawn(ReasonFiftyMoveRule)
+	}
+
+	key := p.repetitionKey()
+	seen := 0
+	for _, q := range positions {
+		if q.repetitionKey() == key {
+			seen++
+		}
+	}
+	if seen >= 3 {
+		return drawn(ReasonThreefoldRepetition)
+	}
+	return Status{}
+}
+
+func won(winner Color, reason string) Status {
+	result := "1-0"
+	if winner == Black {
+		result = "0-1"
+	}
+	return Status{Over: true, Result: result, Reason: reason}
+}
+
+func drawn(reason string) Status {
+	return Status{Over: true, Result: "1/2-1/2", Reason: reason}
+}
+
+// InsufficientMaterial reports whether neither side can possibly deliver mate, and which
+// kind of material is left: a bare king each, a single minor piece against a bare king,
+// or one bishop each on squares of the same color.
+func (p *Position) InsufficientMaterial() (string, bool) {
+	var knights, bishops []Square
+	for i, piece := range p.Board {
+		switch piece.Type() {
+		case NoPieceType, King:
+		case Knight:
+			knights = append(knights, Square(i))
+		case Bishop:
+			bishops = append(bishops, Square(i))
+		default:
+			return "", false
+		}
+	}
+
+	switch {
+	case len(knights) == 0 && len(bishops) == 0:
+		return KingVsKing, true
+	case len(knights) == 1 && len(bishops) == 0:
+		return KingKnightVsKing, true
+	case len(knights) == 0 && len(bishops) == 1:
+		return KingBishopVsKing, true
+	case len(knights) == 0 && len(bishops) == 2:
+		a, b := bishops[0], bishops[1]
+		if p.Board[a].Color() != p.Board[b].Color() && squareShade(a) == squareShade(b) {
+			return KingBishopVsKingBishop, true
+		}
+	}
+	return "", false
+}
+
+func squareShade(sq Square) int {
+	return (sq.File() + sq.Rank()) % 2
+}
+
+// SamePosition reports whether p and q are the same position for the repetition rule,
+// ignoring the move clocks and an en passant square no pawn can capture on.
+func (p *Position) SamePosition(q *Position) bool {
+	return p.repetitionKey() == q.repetitionKey()
+}
+
+// repetitionKey identifies a position for the repetition rule: the placement, side to
+// move, castling rights, and the en passant square only when a capture there is legal.
+func (p *Position) repetitionKey() string {
+	fields := strings.Fields(p.FEN())
+	ep := "-"
+	if p.EnPassant != NoSquare {
+		for _, m := range p.LegalMoves() {
+			if m.To == p.EnPassant && p.Board[m.From].Type() == Pawn {
+				ep = fields[3]
+				break
+			}
+		}
+	}
+	return strings.Join([]string{fields[0], fields[1], fields[2], ep}, " ")
+}
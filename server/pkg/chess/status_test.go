@@ -0,0 +1,97 @@
+package chess
+
+import "testing"
+
+func TestInsufficientMaterial(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want string // "" when mate is still possible
+	}{
+		{"bare kings", "8/8/4k3/8/8/3K4/8/8 w - - 0 1", KingVsKing},
+		{"white knight", "8/8/4k3/8/8/3K4/8/6N1 w - - 0 1", KingKnightVsKing},
+		{"black knight", "8/8/4k3/8/2n5/3K4/8/8 w - - 0 1", KingKnightVsKing},
+		{"white bishop", "8/8/4k3/8/8/3K4/8/2B5 w - - 0 1", KingBishopVsKing},
+		{"black bishop", "8/8/4k3/8/8/3K4/8/5b2 b - - 0 1", KingBishopVsKing},
+		{"bishops on dark squares", "8/4b3/4k3/8/8/3K4/8/2B5 w - - 0 1", KingBishopVsKingBishop},
+		{"bishops on light squares", "8/8/4k3/5b2/8/3K4/8/5B2 w - - 0 1", KingBishopVsKingBishop},
+
+		// Controls where a mate can still be constructed.
+		{"bishops on opposite shades", "8/8/4k3/8/8/3K4/8/2B2b2 w - - 0 1", ""},
+		{"two bishops one side", "8/8/4k3/8/8/3K4/8/2B2B2 w - - 0 1", ""},
+		{"two knights", "8/8/4k3/8/8/3K4/8/1N4N1 w - - 0 1", ""},
+		{"knight against bishop", "8/8/4k3/8/8/3K4/8/1N3b2 w - - 0 1", ""},
+		{"pawn", "8/8/4k3/8/8/3K4/4P3/8 w - - 0 1", ""},
+		{"rook", "8/8/4k3/8/8/3K4/8/R7 w - - 0 1", ""},
+		{"queen", "8/8/4k3/8/8/3K4/8/q7 w - - 0 1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos := mustParseFEN(t, tt.fen)
+			kind, ok := pos.InsufficientMaterial()
+			if kind != tt.want || ok != (tt.want != "") {
+				t.Errorf("InsufficientMaterial = %q, %v; want %q", kind, ok, tt.want)
+			}
+
+			status := GameStatus([]*Position{pos})
+			if tt.want == "" {
+				if status.Over {
+					t.Errorf("GameStatus = %+v, want the game to go on", status)
+				}
+				return
+			}
+			if status.Reason != ReasonInsufficientMaterial || status.Material != tt.want || status.Result != "1/2-1/2" {
+				t.Errorf("GameStatus = %+v, want drawn by %s", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestGameStatusDrawReasons(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want string
+	}{
+		{"stalemate", "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", ReasonStalemate},
+		{"fifty moves", "7k/8/8/8/8/8/8/K6R w - - 100 80", ReasonFiftyMoveRule},
+		{"forty-nine and a half moves", "7k/8/8/8/8/8/8/K6R w - - 99 80", ""},
+		// Mate on the fiftieth move still counts.
+		{"checkmate over fifty moves", "R6k/6pp/8/8/8/8/8/K7 b - - 100 80", ReasonCheckmate},
+		// Stalemate takes precedence over insufficient material.
+		{"stalemate with a knight", "k7/3N4/1K6/8/8/8/8/8 b - - 0 1", ReasonStalemate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := GameStatus([]*Position{mustParseFEN(t, tt.fen)})
+			if status.Reason != tt.want || status.Over != (tt.want != "") {
+				t.Errorf("GameStatus = %+v, want %q", status, tt.want)
+			}
+			if status.Material != "" {
+				t.Errorf("material = %q outside an insufficient material draw", status.Material)
+			}
+		})
+	}
+}
+
+func TestGameStatusThreefoldRepetition(t *testing.T) {
+	shuffle := []string{"Nf3", "Nf6", "Ng1", "Ng8"}
+	var history []string
+	for range 2 {
+		history = append(history, shuffle...)
+	}
+	positions, err := Replay(NewGame(), history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions = append([]*Position{NewGame()}, positions...)
+
+	// The start position occurs for the third time with the last move.
+	if status := GameStatus(positions[:len(positions)-1]); status.Over {
+		t.Errorf("GameStatus before the third repetition = %+v", status)
+	}
+	status := GameStatus(positions)
+	if status.Reason != ReasonThreefoldRepetition || status.Result != "1/2-1/2" {
+		t.Errorf("GameStatus = %+v, want a threefold repetition", status)
+	}
+}
@@ -0,0 +1,206 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Color uint8
+
+const (
+	White Color = iota
+	Black
+)
+
+func (c Color) Other() Color {
+	return c ^ 1
+}
+
+func (c Color) String() string {
+	if c == White {
+		return "white"
+	}
+	return "black"
+}
+
+type PieceType uint8
+
+const (
+	NoPieceType PieceType = iota
+	Pawn
+	Knight
+	Bishop
+	Rook
+	Queen
+	King
+)
+
+// Letter returns the upper-case SAN letter for the piece type (empty for pawns).
+func (t PieceType) Letter() string {
+	switch t {
+	case Knight:
+		return "N"
+	case Bishop:
+		return "B"
+	case Rook:
+		return "R"
+	case Queen:
+		return "Q"
+	case King:
+		return "K"
+	}
+	return ""
+}
+
+// Name returns the lower-case English name of the piece type, such as "knight".
+func (t PieceType) Name() string {
+	switch t {
+	case Pawn:
+		return "pawn"
+	case Knight:
+		return "knight"
+	case Bishop:
+		return "bishop"
+	case Rook:
+		return "rook"
+	case Queen:
+		return "queen"
+	case King:
+		return "king"
+	}
+	return ""
+}
+
+// dropLetter returns the letter a dropped piece is written with, "P" for pawns.
+func dropLetter(t PieceType) string {
+	if t == Pawn {
+		return "P"
+	}
+	return t.Letter()
+}
+
+func pieceTypeFromLetter(c byte) PieceType {
+	switch c {
+	case 'P', 'p':
+		return Pawn
+	case 'N', 'n':
+		return Knight
+	case 'B', 'b':
+		return Bishop
+	case 'R', 'r':
+		return Rook
+	case 'Q', 'q':
+		return Queen
+	case 'K', 'k':
+		return King
+	}
+	return NoPieceType
+}
+
+// Piece packs a color and piece type into a single byte. The zero value is an empty square.
+type Piece uint8
+
+const NoPiece Piece = 0
+
+func NewPiece(c Color, t PieceType) Piece {
+	return Piece(uint8(c)<<3 | uint8(t))
+}
+
+func (p Piece) Type() PieceType {
+	return PieceType(p & 7)
+}
+
+func (p Piece) Color() Color {
+	return Color(p >> 3)
+}
+
+// FENChar returns the piece as it appears in a FEN placement field.
+func (p Piece) FENChar() byte {
+	if p == NoPiece {
+		return '.'
+	}
+	c := "PNBRQK"[p.Type()-1]
+	if p.Color() == Black {
+		c += 'a' - 'A'
+	}
+	return c
+}
+
+// Square indexes the board from a1 (0) to h8 (63).
+type Square int8
+
+const NoSquare Square = -1
+
+func NewSquare(file, rank int) Square {
+	return Square(rank*8 + file)
+}
+
+func (s Square) File() int {
+	return int(s) & 7
+}
+
+func (s Square) Rank() int {
+	return int(s) >> 3
+}
+
+func (s Square) String() string {
+	if s < 0 || s > 63 {
+		return "-"
+	}
+	return string([]byte{byte('a' + s.File()), byte('1' + s.Rank())})
+}
+
+func ParseSquare(s string) (Square, error) {
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return NoSquare, fmt.Errorf("invalid square: %q", s)
+	}
+	return NewSquare(int(s[0]-'a'), int(s[1]-'1')), nil
+}
+
+type CastlingRights uint8
+
+const (
+	WhiteKingSide CastlingRights = 1 << iota
+	WhiteQueenSide
+	BlackKingSide
+	BlackQueenSide
+)
+
+func (c CastlingRights) String() string {
+	if c == 0 {
+		return "-"
+	}
+	var sb strings.Builder
+	for i, r := range "KQkq" {
+		if c&(1<<i) != 0 {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// Move is a move on the board or, in Crazyhouse, a drop. A drop names the dropped piece in
+// Drop and has From equal to To, so the origin square always indexes the board.
+type Move struct {
+	From      Square
+	To        Square
+	Promotion PieceType
+	Drop      PieceType
+}
+
+// IsDrop reports whether m drops a piece from the pocket.
+func (m Move) IsDrop() bool {
+	return m.Drop != NoPieceType
+}
+
+// String returns the move in UCI notation, e.g. "e2e4", "e7e8q" or the drop "N@f3".
+func (m Move) String() string {
+	if m.IsDrop() {
+		return dropLetter(m.Drop) + "@" + m.To.String()
+	}
+	s := m.From.String() + m.To.String()
+	if m.Promotion != NoPieceType {
+		s += strings.ToLower(m.Promotion.Letter())
+	}
+	return s
+}
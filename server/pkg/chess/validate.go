@@ -0,0 +1,127 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PositionError lists every reason a syntactically valid position cannot arise in a game.
+type PositionError struct {
+	Problems []string
+}
+
+func (e *PositionError) Error() string {
+	return "illegal position: " + strings.Join(e.Problems, "; ")
+}
+
+// ValidateFEN parses fen and checks that the position it describes is legal.
+func ValidateFEN(fen string) (*Position, error) {
+	pos, err := ParseFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidatePosition(pos); err != nil {
+		return nil, err
+	}
+	return pos, nil
+}
+
+// ValidatePosition checks the rules a reachable position must satisfy: one king per side,
+// no pawns on the back ranks, plausible piece counts, the side not to move out of check,
+// and castling and en passant fields consistent with the board. In Crazyhouse pieces
+// change sides, so only the total of pieces on the board and in hand is counted. It
+// returns a *PositionError naming every problem found, or nil.
+func ValidatePosition(p *Position) error {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	for _, c := range []Color{White, Black} {
+		var counts [7]int
+		total := 0
+		for sq, piece := range p.Board {
+			if piece == NoPiece || piece.Color() != c {
+				continue
+			}
+			counts[piece.Type()]++
+			total++
+			if piece.Type() == Pawn && (Square(sq).Rank() == 0 || Square(sq).Rank() == 7) {
+				addf("%s pawn on %s", c, Square(sq))
+			}
+		}
+
+		if counts[King] != 1 {
+			addf("%s has %d kings", c, counts[King])
+		}
+		if p.Variant == Crazyhouse {
+			continue
+		}
+		if total > 16 {
+			addf("%s has %d pieces", c, total)
+		}
+		if counts[Pawn] > 8 {
+			addf("%s has %d pawns", c, counts[Pawn])
+		}
+		// Every piece beyond the starting set must have come from a promoted pawn.
+		promoted := max(0, counts[Knight]-2) + max(0, counts[Bishop]-2) + max(0, counts[Rook]-2) + max(0, counts[Queen]-1)
+		if promoted > 8-counts[Pawn] {
+			addf("%s has more promoted pieces than missing pawns", c)
+		}
+	}
+
+	if p.Variant == Crazyhouse {
+		total := p.Pockets[White].Count() + p.Pockets[Black].Count()
+		for _, piece := range p.Board {
+			if piece != NoPiece {
+				total++
+			}
+		}
+		if total > 32 {
+			addf("%d pieces on the board and in hand", total)
+		}
+	}
+
+	if king := p.KingSquare(p.Turn.Other()); king != NoSquare && p.IsAttacked(king, p.Turn) {
+		addf("%s is in check but it is %s to move", p.Turn.Other(), p.Turn)
+	}
+
+	castling := []struct {
+		right      CastlingRights
+		king, rook Square
+		piece      Piece
+	}{
+		{WhiteKingSide, NewSquare(4, 0), NewSquare(7, 0), NewPiece(White, Rook)},
+		{WhiteQueenSide, NewSquare(4, 0), NewSquare(0, 0), NewPiece(White, Rook)},
+		{BlackKingSide, NewSquare(4, 7), NewSquare(7, 7), NewPiece(Black, Rook)},
+		{BlackQueenSide, NewSquare(4, 7), NewSquare(0, 7), NewPiece(Black, Rook)},
+	}
+	for _, c := range castling {
+		if p.Castling&c.right == 0 {
+			continue
+		}
+		if p.Board[c.king] != NewPiece(c.piece.Color(), King) || p.Board[c.rook] != c.piece {
+			addf("castling right %s without king and rook on their home squares", c.right)
+		}
+	}
+
+	if p.EnPassant != NoSquare {
+		// The en passant square is the one the opponent's pawn just skipped over.
+		rank, dir := 5, -1
+		if p.Turn == Black {
+			rank, dir = 2, 1
+		}
+		pawn := p.EnPassant + Square(8*dir)
+		origin := p.EnPassant - Square(8*dir)
+		if p.EnPassant.Rank() != rank ||
+			p.Board[pawn] != NewPiece(p.Turn.Other(), Pawn) ||
+			p.Board[p.EnPassant] != NoPiece || p.Board[origin] != NoPiece {
+			addf("en passant square %s does not follow a double pawn push", p.EnPassant)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &PositionError{Problems: problems}
+	}
+	return nil
+}
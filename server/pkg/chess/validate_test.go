@@ -0,0 +1,79 @@
+package chess
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateFEN(t *testing.T) {
+	for _, fen := range []string{
+		StartFEN,
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+		"7k/8/8/8/8/8/8/K7 w - - 0 1",
+		"NNNNNNNN/8/8/8/8/8/8/K6k w - - 0 1", // every pawn promoted
+	} {
+		if _, err := ValidateFEN(fen); err != nil {
+			t.Errorf("ValidateFEN(%s): %v", fen, err)
+		}
+	}
+}
+
+func TestValidateFENIllegal(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want string
+	}{
+		{"no black king", "8/8/8/8/8/8/8/K7 w - - 0 1", "black has 0 kings"},
+		{"two white kings", "7k/8/8/8/8/8/8/K6K w - - 0 1", "white has 2 kings"},
+		{"pawn on the back rank", "P6k/8/8/8/8/8/8/K7 w - - 0 1", "white pawn on a8"},
+		{"nine pawns", "7k/8/8/8/8/p7/PPPPPPPP/K6P w - - 0 1", "white has 9 pawns"},
+		{"too many promotions", "7k/8/8/8/8/8/PPPPPPPP/KQQ5 w - - 0 1", "white has more promoted pieces than missing pawns"},
+		{"white in check with black to move", "7k/8/8/8/8/8/8/K6r b - - 0 1", "white is in check but it is black to move"},
+		{"black in check with white to move", "k7/8/8/8/8/8/8/R6K w - - 0 1", "black is in check but it is white to move"},
+		{"castling without rook", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBN1 w KQkq - 0 1", "castling right"},
+		{"castling with king moved", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQ1KNR w KQkq - 0 1", "castling right"},
+		{"en passant without push", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq e3 0 1", "en passant square e3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateFEN(tt.fen)
+			var positionErr *PositionError
+			if !errors.As(err, &positionErr) {
+				t.Fatalf("ValidateFEN = %v, want a *PositionError", err)
+			}
+			found := false
+			for _, p := range positionErr.Problems {
+				found = found || strings.Contains(p, tt.want)
+			}
+			if !found {
+				t.Errorf("problems = %q, want one containing %q", positionErr.Problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFENReportsEveryProblem(t *testing.T) {
+	_, err := ValidateFEN("P7/8/8/8/8/8/8/8 w - - 0 1")
+	var positionErr *PositionError
+	if !errors.As(err, &positionErr) || len(positionErr.Problems) != 3 {
+		t.Fatalf("ValidateFEN = %v, want a pawn on the back rank and two missing kings", err)
+	}
+}
+
+func TestValidateFENSyntax(t *testing.T) {
+	for _, fen := range []string{
+		"",
+		"not a fen",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1",
+	} {
+		_, err := ValidateFEN(fen)
+		var positionErr *PositionError
+		if err == nil || errors.As(err, &positionErr) {
+			t.Errorf("ValidateFEN(%q) = %v, want a syntax error", fen, err)
+		}
+	}
+}
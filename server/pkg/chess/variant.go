@@ -0,0 +1,108 @@
+package chess
+
+import "fmt"
+
+// Variant selects the rules a position is played under. It travels with the position, so
+// every move generated from it follows the same rules.
+type Variant uint8
+
+const (
+	Standard Variant = iota
+	// KingOfTheHill is standard chess, except that a king reaching one of the four centre
+	// squares wins the game.
+	KingOfTheHill
+	// Crazyhouse is standard chess, except that captured pieces change sides and go to
+	// the capturer's pocket, from which they may be dropped on any empty square instead of
+	// moving. Pawns cannot be dropped on the first or last rank, and a captured promoted
+	// piece goes to the pocket as a pawn.
+	Crazyhouse
+)
+
+// Variants lists every variant, by the name String gives it.
+var Variants = []Variant{Standard, KingOfTheHill, Crazyhouse}
+
+func (v Variant) String() string {
+	switch v {
+	case KingOfTheHill:
+		return "king_of_the_hill"
+	case Crazyhouse:
+		return "crazyhouse"
+	}
+	return "standard"
+}
+
+// Title returns the variant's name as players write it, such as "King of the Hill".
+func (v Variant) Title() string {
+	switch v {
+	case KingOfTheHill:
+		return "King of the Hill"
+	case Crazyhouse:
+		return "Crazyhouse"
+	}
+	return "Standard chess"
+}
+
+// ParseVariant reads a variant name as String writes it. An empty name is Standard.
+func ParseVariant(s string) (Variant, error) {
+	if s == "" {
+		return Standard, nil
+	}
+	for _, v := range Variants {
+		if v.String() == s {
+			return v, nil
+		}
+	}
+	return Standard, fmt.Errorf("unknown variant %q", s)
+}
+
+// ReasonKingOfTheHill ends a King of the Hill game won by reaching the centre.
+const ReasonKingOfTheHill = "king_of_the_hill"
+
+// hillSquares are the centre squares d4, e4, d5 and e5.
+var hillSquares = [4]Square{27, 28, 35, 36}
+
+// OnHill reports whether c's king stands on one of the four centre squares. Only King of
+// the Hill treats that as a win.
+func (p *Position) OnHill(c Color) bool {
+	king := p.KingSquare(c)
+	for _, sq := range hillSquares {
+		if king == sq {
+			return true
+		}
+	}
+	return false
+}
+
+// Pocket holds the pieces one side has in hand in Crazyhouse, counted by piece type.
+type Pocket [King]int
+
+// Count returns how many pieces the pocket holds.
+func (p Pocket) Count() int {
+	n := 0
+	for _, c := range p {
+		n += c
+	}
+	return n
+}
+
+// pocketOrder is the order pieces are listed in a FEN pocket.
+var pocketOrder = [5]PieceType{Queen, Rook, Bishop, Knight, Pawn}
+
+// appendDrops adds every drop from the side to move's pocket: any piece in hand onto any
+// empty square, but pawns not onto the first or last rank.
+func (p *Position) appendDrops(moves []Move) []Move {
+	pocket := p.Pockets[p.Turn]
+	for _, t := range pocketOrder {
+		if pocket[t] == 0 {
+			continue
+		}
+		for i, piece := range p.Board {
+			sq := Square(i)
+			if piece != NoPiece || (t == Pawn && (sq.Rank() == 0 || sq.Rank() == 7)) {
+				continue
+			}
+			moves = append(moves, Move{From: sq, To: sq, Drop: t})
+		}
+	}
+	return moves
+}
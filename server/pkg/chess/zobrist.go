@@ -0,0 +1,68 @@
+package chess
+
+// Zobrist keys, generated from a fixed seed so hashes are stable across runs.
+var (
+	zobristPieces    [16][64]uint64 // indexed by Piece
+	zobristBlack     uint64
+	zobristCastling  [16]uint64
+	zobristEnPassant [8]uint64
+	zobristPockets   [2][King][17]uint64 // by color, piece type and count, capped at 16
+)
+
+func init() {
+	seed := uint64(0x9E3779B97F4A7C15)
+	next := func() uint64 {
+		// splitmix64
+		seed += 0x9E3779B97F4A7C15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		return z ^ (z >> 31)
+	}
+	for p := range zobristPieces {
+		for sq := range zobristPieces[p] {
+			zobristPieces[p][sq] = next()
+		}
+	}
+	zobristBlack = next()
+	for i := range zobristCastling {
+		zobristCastling[i] = next()
+	}
+	for i := range zobristEnPassant {
+		zobristEnPassant[i] = next()
+	}
+	for c := range zobristPockets {
+		for t := range zobristPockets[c] {
+			for n := range zobristPockets[c][t] {
+				zobristPockets[c][t][n] = next()
+			}
+		}
+	}
+}
+
+// Hash returns the Zobrist hash of the position: placement, side to move, castling rights
+// and en passant file, and the pockets in Crazyhouse. The move clocks are not included, so
+// transpositions hash equally.
+func (p *Position) Hash() uint64 {
+	var h uint64
+	for sq, piece := range p.Board {
+		if piece != NoPiece {
+			h ^= zobristPieces[piece][sq]
+		}
+	}
+	if p.Turn == Black {
+		h ^= zobristBlack
+	}
+	h ^= zobristCastling[p.Castling&15]
+	if p.EnPassant != NoSquare {
+		h ^= zobristEnPassant[p.EnPassant.File()]
+	}
+	for c, pocket := range p.Pockets {
+		for t, n := range pocket {
+			if n > 0 {
+				h ^= zobristPockets[c][t][min(n, 16)]
+			}
+		}
+	}
+	return h
+}
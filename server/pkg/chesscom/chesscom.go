@@ -0,0 +1,101 @@
+// Package chesscom fetches a player's games from the Chess.com published-data API.
+package chesscom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/pgn"
+)
+
+// ChessComURL is the public Chess.com published-data API.
+const ChessComURL = "https://api.chess.com"
+
+// maxArchives bounds how many monthly archives RecentGames reads looking for games.
+const maxArchives = 12
+
+// ErrUserNotFound is returned for a username Chess.com doesn't know.
+var ErrUserNotFound = errors.New("chesscom: user not found")
+
+// Client queries the Chess.com API.
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient returns a client for the Chess.com API at baseURL.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// RecentGames returns up to max of username's most recent standard games, newest first,
+// reading back through at most a year of monthly archives.
+func (c *Client) RecentGames(ctx context.Context, username string, max int) ([]pgn.Game, error) {
+	player := c.baseURL + "/pub/player/" + url.PathEscape(strings.ToLower(username))
+	var index struct {
+		Archives []string `json:"archives"`
+	}
+	if err := c.get(ctx, player+"/games/archives", func(body io.Reader) error {
+		return json.NewDecoder(body).Decode(&index)
+	}); err != nil {
+		return nil, err
+	}
+
+	var games []pgn.Game
+	// The index lists the months oldest first, and each month lists its games oldest first.
+	for i := len(index.Archives) - 1; i >= 0 && i >= len(index.Archives)-maxArchives && len(games) < max; i-- {
+		// Archive URLs name the live API; fetch the same month from baseURL.
+		month := index.Archives[i]
+		if at := strings.Index(month, "/pub/player/"); at >= 0 {
+			month = c.baseURL + month[at:]
+		}
+		var monthGames []pgn.Game
+		if err := c.get(ctx, month, func(body io.Reader) error {
+			var err error
+			monthGames, err = pgn.ParseChessComArchive(body)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		slices.Reverse(monthGames)
+		games = append(games, monthGames[:min(len(monthGames), max-len(games))]...)
+	}
+	return games, nil
+}
+
+// get fetches u and hands a successful response's body to read.
+func (c *Client) get(ctx context.Context, u string, read func(io.Reader) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "nara-chess")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("chesscom: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrUserNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("chesscom: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := read(resp.Body); err != nil {
+		return fmt.Errorf("chesscom: reading games: %w", err)
+	}
+	return nil
+}
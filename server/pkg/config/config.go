@@ -0,0 +1,486 @@
+// Package config gathers the server's settings. Every setting has a default, which a YAML
+// file, the environment and the command-line flags override in turn.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/account"
+	"arnavsurve/nara-chess/server/pkg/chesscom"
+	"arnavsurve/nara-chess/server/pkg/cors"
+	"arnavsurve/nara-chess/server/pkg/explorer"
+	"arnavsurve/nara-chess/server/pkg/lichess"
+	"arnavsurve/nara-chess/server/pkg/logging"
+	"arnavsurve/nara-chess/server/pkg/tablebase"
+
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+)
+
+// Off, given as the URL of an outside service, disables the features that use it.
+const Off = "off"
+
+// Config holds the server's settings. Durations are written like "90s".
+type Config struct {
+	// Port is the TCP port the server listens on.
+	Port int `yaml:"port"`
+	// AllowedOrigins lists the browser origins allowed to call the API and open /ws/game,
+	// as exact origins, wildcards such as "https://*.example.com", "regexp:" and a regular
+	// expression, or "*" for any; see cors.NewPolicy.
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	// AllowCredentials lets browsers send cookies and Authorization headers cross-origin.
+	AllowCredentials bool `yaml:"allow_credentials"`
+	// CORSMaxAge is how long browsers may cache the answer to a preflight request.
+	CORSMaxAge time.Duration `yaml:"cors_max_age"`
+	// ShutdownTimeout bounds how long a shutdown waits for the requests under way, model
+	// calls included, and for the background reviews.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// LogFormat is "text" or "json". Debug also logs prompts, model answers and the other
+	// content of pupils' games, which are kept out of the logs otherwise.
+	LogFormat string `yaml:"log_format"`
+	Debug     bool   `yaml:"debug"`
+	// DBPath is the SQLite database of games, puzzles and pupils; empty keeps them in
+	// memory.
+	DBPath string `yaml:"db_path"`
+	// RedisURL, a redis:// or rediss:// URL, shares the response caches, rate limits,
+	// quotas and game broadcasts between every instance using the same Redis; empty keeps
+	// them to each instance.
+	RedisURL string `yaml:"redis_url"`
+	// TracingEndpoint is the OTLP/HTTP collector spans are exported to, such as
+	// http://localhost:4318; empty disables tracing.
+	TracingEndpoint string `yaml:"tracing_endpoint"`
+
+	// Providers lists the model providers in failover order; the first serves requests that
+	// don't ask for another with the X-Nara-Provider header.
+	Providers []string `yaml:"providers"`
+	Gemini    Provider `yaml:"gemini"`
+	OpenAI    Provider `yaml:"openai"`
+	Anthropic Provider `yaml:"anthropic"`
+	Ollama    Provider `yaml:"ollama"`
+	// Temperature is the models' temperature on endpoints whose profile sets none.
+	Temperature float32 `yaml:"temperature"`
+	// RequestTimeout bounds the model calls of a request on endpoints whose profile sets
+	// none.
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// AttemptTimeout bounds each model in a provider's chain, leaving the rest of the
+	// request's timeout for the fallback models.
+	AttemptTimeout time.Duration `yaml:"attempt_timeout"`
+	// ModelRetries is how often a model is called again after a transient failure, such
+	// as a 429 or a 503, waiting a random time up to RetryBackoff before the first retry
+	// and up to twice as long before each next one.
+	ModelRetries int           `yaml:"model_retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	// ModelConcurrency caps the calls each provider has under way at once; up to
+	// ModelQueue more wait for a slot, and calls beyond those are refused.
+	ModelConcurrency int `yaml:"model_concurrency"`
+	ModelQueue       int `yaml:"model_queue"`
+	// BreakerThreshold failures in a row stop calls to a provider for BreakerCooldown.
+	BreakerThreshold int           `yaml:"breaker_threshold"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown"`
+	// MaxModelCalls caps the model calls of a request, retries included.
+	MaxModelCalls int `yaml:"max_model_calls"`
+	// MaxMoveAttempts caps how often /generateMove asks again after an illegal move.
+	MaxMoveAttempts int `yaml:"max_move_attempts"`
+	// ChatTokenBudget caps the estimated tokens of the chat prompt; older messages are
+	// summarized to keep a long conversation within it.
+	ChatTokenBudget int `yaml:"chat_token_budget"`
+	// ProfilesFile is a JSON file of per-endpoint generation profiles.
+	ProfilesFile string `yaml:"profiles_file"`
+	// PricesFile is a JSON file of model prices, per million tokens, for usage accounting.
+	PricesFile string `yaml:"prices_file"`
+	// MoveCacheTTL and AnalysisCacheTTL are how long the coach's replies and its analyses of
+	// a position are served from the response cache.
+	MoveCacheTTL     time.Duration `yaml:"move_cache_ttl"`
+	AnalysisCacheTTL time.Duration `yaml:"analysis_cache_ttl"`
+	// CorrectSideMismatch rewrites a FEN's side to move when it disagrees with the move
+	// history instead of rejecting the request.
+	CorrectSideMismatch bool `yaml:"correct_side_mismatch"`
+	// ExplorerPrompt adds master game statistics to the coach's prompt in the opening.
+	ExplorerPrompt bool `yaml:"explorer_prompt"`
+
+	// StockfishPath is a UCI engine binary; empty uses the built-in engine.
+	StockfishPath     string        `yaml:"stockfish_path"`
+	StockfishMoveTime time.Duration `yaml:"stockfish_move_time"`
+	// HybridMoves lets the UCI engine choose the coach's moves, leaving the model to write
+	// only the commentary.
+	HybridMoves bool `yaml:"hybrid_moves"`
+
+	// TablebaseURL is a lichess tablebase API server, such as a self-hosted lila-tablebase
+	// over local Syzygy files. Like the other service URLs, Off disables it.
+	TablebaseURL string `yaml:"tablebase_url"`
+	// ExplorerURL is a lichess opening explorer, serving /explorer.
+	ExplorerURL string `yaml:"explorer_url"`
+	// LichessURL is the lichess API, serving /import/lichess.
+	LichessURL string `yaml:"lichess_url"`
+	// LichessToken authenticates the calls to the lichess API and explorer.
+	LichessToken string `yaml:"lichess_token"`
+	// ChessComURL is the Chess.com published-data API, serving /import/chesscom.
+	ChessComURL string `yaml:"chesscom_url"`
+
+	// AnalysisWorkers sets how many /analysis/jobs run at once.
+	AnalysisWorkers int `yaml:"analysis_workers"`
+	// WebhookSecret signs the callbacks of analysis jobs; without it jobs can't register
+	// one.
+	WebhookSecret string `yaml:"webhook_secret"`
+
+	// AdminKeys unlock the admin endpoints.
+	AdminKeys []string `yaml:"admin_keys"`
+	// APIKeys, with the keys listed one per line in APIKeysFile, are keys clients may send,
+	// with every scope. Keys issued at /apiKeys are kept hashed in the database instead.
+	// With no keys of either kind the API is open.
+	APIKeys         []string `yaml:"api_keys"`
+	APIKeysFile     string   `yaml:"api_keys_file"`
+	APIKeyRateLimit int      `yaml:"api_key_rate_limit"`
+	// IPRateLimit limits the requests per minute of each client address while the API is
+	// open; 0 disables it. TrustProxy takes the address from X-Forwarded-For, for a server
+	// behind a reverse proxy that sets it.
+	IPRateLimit int  `yaml:"ip_rate_limit"`
+	TrustProxy  bool `yaml:"trust_proxy"`
+	// DailyModelQuota caps the model calls each signed-in user, API key or, on an open
+	// API, client address may make per UTC day; 0 disables it.
+	DailyModelQuota int `yaml:"daily_model_quota"`
+
+	// GoogleClientID and GoogleClientSecret, or LichessClientID, let pupils sign in with
+	// that provider, so their games, profile and puzzles follow them between browsers.
+	// Lichess needs no registration: its client ID is any name for the app. Signing in
+	// needs SessionSecret, and browsers on another origin need AllowCredentials.
+	GoogleClientID     string `yaml:"google_client_id"`
+	GoogleClientSecret string `yaml:"google_client_secret"`
+	LichessClientID    string `yaml:"lichess_client_id"`
+	// SessionSecret signs the session cookies of signed-in users; changing it signs
+	// everyone out.
+	SessionSecret string        `yaml:"session_secret"`
+	SessionTTL    time.Duration `yaml:"session_ttl"`
+	// PublicURL is the address browsers reach the server at, which sign-in providers send
+	// them back to; empty is http://localhost and the port.
+	PublicURL string `yaml:"public_url"`
+	// LoginRedirect is the client page browsers return to once signed in.
+	LoginRedirect string `yaml:"login_redirect"`
+}
+
+// Provider configures one model provider.
+type Provider struct {
+	APIKey string `yaml:"api_key"`
+	// URL replaces the provider's API address, for proxies and local servers.
+	URL string `yaml:"url"`
+	// Models is the provider's fallback chain, primary model first.
+	Models []string `yaml:"models"`
+}
+
+// Default returns the settings used where nothing overrides them.
+func Default() *Config {
+	return &Config{
+		Port:              42069,
+		AllowedOrigins:    []string{cors.DevOrigin},
+		CORSMaxAge:        10 * time.Minute,
+		ShutdownTimeout:   30 * time.Second,
+		LogFormat:         logging.FormatText,
+		Providers:         []string{"gemini"},
+		Gemini:            Provider{Models: []string{"gemini-2.5-pro-exp-03-25", "gemini-2.0-flash"}},
+		OpenAI:            Provider{Models: []string{"gpt-4o-mini"}},
+		Anthropic:         Provider{Models: []string{"claude-3-5-haiku-latest"}},
+		Ollama:            Provider{Models: []string{"llama3.1"}},
+		Temperature:       0.4,
+		RequestTimeout:    60 * time.Second,
+		AttemptTimeout:    25 * time.Second,
+		ModelRetries:      2,
+		RetryBackoff:      500 * time.Millisecond,
+		ModelConcurrency:  8,
+		ModelQueue:        32,
+		BreakerThreshold:  5,
+		BreakerCooldown:   30 * time.Second,
+		MaxModelCalls:     3,
+		MaxMoveAttempts:   3,
+		ChatTokenBudget:   8000,
+		MoveCacheTTL:      10 * time.Minute,
+		AnalysisCacheTTL:  30 * 24 * time.Hour,
+		StockfishMoveTime: 500 * time.Millisecond,
+		TablebaseURL:      tablebase.LichessURL,
+		ExplorerURL:       explorer.LichessURL,
+		LichessURL:        lichess.LichessURL,
+		ChessComURL:       chesscom.ChessComURL,
+		AnalysisWorkers:   2,
+		APIKeyRateLimit:   60,
+		IPRateLimit:       60,
+		DailyModelQuota:   200,
+		SessionTTL:        30 * 24 * time.Hour,
+		LoginRedirect:     cors.DevOrigin,
+	}
+}
+
+// Load builds the configuration from the defaults, the YAML file named by -config or
+// NARA_CONFIG_FILE, the environment and args, the command-line arguments without the
+// program name, and validates it. It returns flag.ErrHelp for -help.
+func Load(args []string) (*Config, error) {
+	c := Default()
+	// The flags are parsed twice: first for -config and to reject bad values early, then
+	// again over the file and the environment, which they override.
+	path := os.Getenv("NARA_CONFIG_FILE")
+	if err := c.flagSet(&path).Parse(args); err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.loadEnv(); err != nil {
+		return nil, err
+	}
+	if err := c.flagSet(&path).Parse(args); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// loadFile overrides c with the settings in the YAML file at path. Unknown keys are
+// rejected so a misspelt setting fails at startup instead of being ignored.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	defer f.Close()
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides c with the environment variables that are set and not empty.
+func (c *Config) loadEnv() error {
+	var errs []error
+	for _, s := range c.settings() {
+		v := os.Getenv(s.env)
+		if v == "" {
+			continue
+		}
+		if err := s.value.Set(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flagSet binds the flags to c, and -config to path.
+func (c *Config) flagSet(path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("nara", flag.ContinueOnError)
+	fs.StringVar(path, "config", *path, "YAML configuration `file` (NARA_CONFIG_FILE)")
+	for _, s := range c.settings() {
+		if s.flag != "" {
+			fs.Var(s.value, s.flag, fmt.Sprintf("%s (%s)", s.usage, s.env))
+		}
+	}
+	return fs
+}
+
+// Validate reports every setting that is out of range or inconsistent with another.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Port > 0 && c.Port < 1<<16, "port %d must be between 1 and 65535", c.Port)
+	check(c.LogFormat == logging.FormatText || c.LogFormat == logging.FormatJSON, "log_format %q must be %q or %q", c.LogFormat, logging.FormatText, logging.FormatJSON)
+	check(len(c.AllowedOrigins) > 0, "allowed_origins must list at least one origin")
+	if _, err := cors.NewPolicy(c.AllowedOrigins); err != nil {
+		errs = append(errs, err)
+	}
+	// Any origin with credentials would let every site act as the signed-in user.
+	check(!c.AllowCredentials || !slices.Contains(c.AllowedOrigins, "*"), "allow_credentials can't be combined with the \"*\" origin")
+	check(c.CORSMaxAge >= 0, "cors_max_age must not be negative")
+	check(c.ShutdownTimeout > 0, "shutdown_timeout must be positive")
+
+	check(len(c.Providers) > 0, "providers must list at least one model provider")
+	for _, name := range c.Providers {
+		p, ok := c.Provider(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown model provider %q (want gemini, openai, anthropic or ollama)", name))
+			continue
+		}
+		check(len(p.Models) > 0, "%s models must list at least one model", name)
+		check(p.APIKey != "" || name == "ollama", "%s needs an API key", name)
+	}
+	check(c.Temperature >= 0 && c.Temperature <= 2, "temperature %v must be between 0 and 2", c.Temperature)
+	check(c.RequestTimeout > 0, "request_timeout must be positive")
+	check(c.AttemptTimeout > 0, "attempt_timeout must be positive")
+	check(c.ModelRetries >= 0, "model_retries must not be negative")
+	check(c.RetryBackoff > 0, "retry_backoff must be positive")
+	check(c.ModelConcurrency > 0, "model_concurrency must be positive")
+	check(c.ModelQueue >= 0, "model_queue must not be negative")
+	check(c.BreakerThreshold > 0, "breaker_threshold must be positive")
+	check(c.BreakerCooldown > 0, "breaker_cooldown must be positive")
+	check(c.MaxModelCalls > 0, "max_model_calls must be positive")
+	check(c.MaxMoveAttempts > 0, "max_move_attempts must be positive")
+	check(c.ChatTokenBudget > 0, "chat_token_budget must be positive")
+	check(c.MoveCacheTTL > 0, "move_cache_ttl must be positive")
+	check(c.AnalysisCacheTTL > 0, "analysis_cache_ttl must be positive")
+
+	check(c.StockfishMoveTime > 0, "stockfish_move_time must be positive")
+	check(!c.HybridMoves || c.StockfishPath != "", "hybrid_moves requires stockfish_path")
+	for _, service := range []struct{ name, url string }{
+		{"tablebase_url", c.TablebaseURL},
+		{"explorer_url", c.ExplorerURL},
+		{"lichess_url", c.LichessURL},
+		{"chesscom_url", c.ChessComURL},
+	} {
+		if service.url == Off {
+			continue
+		}
+		u, err := url.Parse(service.url)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"%s %q must be an http(s) URL or %q", service.name, service.url, Off)
+	}
+
+	if c.RedisURL != "" {
+		_, err := redis.ParseURL(c.RedisURL)
+		check(err == nil, "redis_url: %v", err)
+	}
+	check(c.TracingEndpoint == "" || httpURL(c.TracingEndpoint), "tracing_endpoint %q must be an http(s) URL", c.TracingEndpoint)
+
+	check(c.AnalysisWorkers >= 0, "analysis_workers must not be negative")
+	check(c.APIKeyRateLimit > 0, "api_key_rate_limit must be positive")
+	check(c.IPRateLimit >= 0, "ip_rate_limit must not be negative")
+	check(c.DailyModelQuota >= 0, "daily_model_quota must not be negative")
+
+	check((c.GoogleClientID == "") == (c.GoogleClientSecret == ""), "google_client_id and google_client_secret must be set together")
+	check(c.LichessClientID == "" || c.LichessURL != Off, "lichess_client_id requires lichess_url")
+	if c.SignIn() {
+		check(len(c.SessionSecret) >= account.MinSecretLength, "signing in requires a session_secret of at least %d characters", account.MinSecretLength)
+	}
+	check(c.SessionTTL > 0, "session_ttl must be positive")
+	check(c.PublicURL == "" || httpURL(c.PublicURL), "public_url %q must be an http(s) URL", c.PublicURL)
+	check(httpURL(c.LoginRedirect), "login_redirect %q must be an http(s) URL", c.LoginRedirect)
+	return errors.Join(errs...)
+}
+
+// SignIn reports whether any sign-in provider is configured.
+func (c *Config) SignIn() bool {
+	return c.GoogleClientID != "" || c.LichessClientID != ""
+}
+
+func httpURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// ServerURL is PublicURL, or the local address when it is empty.
+func (c *Config) ServerURL() string {
+	if c.PublicURL == "" {
+		return fmt.Sprintf("http://localhost:%d", c.Port)
+	}
+	return strings.TrimRight(c.PublicURL, "/")
+}
+
+// Provider returns the settings of the named model provider.
+func (c *Config) Provider(name string) (Provider, bool) {
+	switch name {
+	case "gemini":
+		return c.Gemini, true
+	case "openai":
+		return c.OpenAI, true
+	case "anthropic":
+		return c.Anthropic, true
+	case "ollama":
+		return c.Ollama, true
+	}
+	return Provider{}, false
+}
+
+// setting ties a field of Config to the environment variable that overrides it and, for
+// the settings most often changed per run, a flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	value flag.Value
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"NARA_PORT", "port", "TCP `port` to listen on", (*intValue)(&c.Port)},
+		{"NARA_ALLOWED_ORIGINS", "allowed-origins", "comma-separated browser `origins` allowed to call the API", (*listValue)(&c.AllowedOrigins)},
+		{"NARA_CORS_CREDENTIALS", "", "", (*boolValue)(&c.AllowCredentials)},
+		{"NARA_CORS_MAX_AGE", "", "", (*durationValue)(&c.CORSMaxAge)},
+		{"NARA_SHUTDOWN_TIMEOUT", "shutdown-timeout", "how long a shutdown waits for the requests under way, as a `duration`", (*durationValue)(&c.ShutdownTimeout)},
+		{"NARA_LOG_FORMAT", "log-format", "log `format`, text or json", (*stringValue)(&c.LogFormat)},
+		{"NARA_DEBUG", "debug", "log at debug level, including prompts and model answers", (*boolValue)(&c.Debug)},
+		{"NARA_DB_PATH", "", "", (*stringValue)(&c.DBPath)},
+		{"NARA_REDIS_URL", "", "", (*stringValue)(&c.RedisURL)},
+		{"NARA_OTLP_ENDPOINT", "", "", (*stringValue)(&c.TracingEndpoint)},
+
+		{"NARA_LLM_PROVIDERS", "providers", "comma-separated model `providers` in failover order", (*listValue)(&c.Providers)},
+		{"GEMINI_API_KEY", "", "", (*stringValue)(&c.Gemini.APIKey)},
+		{"GEMINI_MODELS", "gemini-models", "comma-separated Gemini `models`, primary first", (*listValue)(&c.Gemini.Models)},
+		{"OPENAI_API_KEY", "", "", (*stringValue)(&c.OpenAI.APIKey)},
+		{"OPENAI_BASE_URL", "", "", (*stringValue)(&c.OpenAI.URL)},
+		{"OPENAI_MODELS", "openai-models", "comma-separated OpenAI `models`, primary first", (*listValue)(&c.OpenAI.Models)},
+		{"ANTHROPIC_API_KEY", "", "", (*stringValue)(&c.Anthropic.APIKey)},
+		{"ANTHROPIC_MODELS", "anthropic-models", "comma-separated Anthropic `models`, primary first", (*listValue)(&c.Anthropic.Models)},
+		{"OLLAMA_URL", "", "", (*stringValue)(&c.Ollama.URL)},
+		{"OLLAMA_MODELS", "ollama-models", "comma-separated Ollama `models`, primary first", (*listValue)(&c.Ollama.Models)},
+		{"NARA_TEMPERATURE", "temperature", "model `temperature` on endpoints without a profile", (*float32Value)(&c.Temperature)},
+		{"NARA_REQUEST_TIMEOUT", "request-timeout", "`duration` bounding the model calls of a request", (*durationValue)(&c.RequestTimeout)},
+		{"NARA_MODEL_ATTEMPT_TIMEOUT", "attempt-timeout", "`duration` bounding each model in a provider's chain", (*durationValue)(&c.AttemptTimeout)},
+		{"NARA_MODEL_RETRIES", "", "", (*intValue)(&c.ModelRetries)},
+		{"NARA_RETRY_BACKOFF", "", "", (*durationValue)(&c.RetryBackoff)},
+		{"NARA_MODEL_CONCURRENCY", "", "", (*intValue)(&c.ModelConcurrency)},
+		{"NARA_MODEL_QUEUE", "", "", (*intValue)(&c.ModelQueue)},
+		{"NARA_BREAKER_THRESHOLD", "", "", (*intValue)(&c.BreakerThreshold)},
+		{"NARA_BREAKER_COOLDOWN", "", "", (*durationValue)(&c.BreakerCooldown)},
+		{"NARA_MAX_MODEL_CALLS", "", "", (*intValue)(&c.MaxModelCalls)},
+		{"NARA_MAX_MOVE_ATTEMPTS", "", "", (*intValue)(&c.MaxMoveAttempts)},
+		{"NARA_CHAT_TOKEN_BUDGET", "", "", (*intValue)(&c.ChatTokenBudget)},
+		{"NARA_PROFILES_FILE", "", "", (*stringValue)(&c.ProfilesFile)},
+		{"NARA_PRICES_FILE", "", "", (*stringValue)(&c.PricesFile)},
+		{"NARA_MOVE_CACHE_TTL", "", "", (*durationValue)(&c.MoveCacheTTL)},
+		{"NARA_ANALYSIS_CACHE_TTL", "", "", (*durationValue)(&c.AnalysisCacheTTL)},
+		{"NARA_CORRECT_SIDE_MISMATCH", "", "", (*boolValue)(&c.CorrectSideMismatch)},
+		{"NARA_EXPLORER_PROMPT", "", "", (*boolValue)(&c.ExplorerPrompt)},
+
+		{"NARA_STOCKFISH_PATH", "", "", (*stringValue)(&c.StockfishPath)},
+		{"NARA_STOCKFISH_MOVETIME", "", "", (*durationValue)(&c.StockfishMoveTime)},
+		{"NARA_HYBRID_MOVES", "", "", (*boolValue)(&c.HybridMoves)},
+
+		{"NARA_TABLEBASE_URL", "", "", (*stringValue)(&c.TablebaseURL)},
+		{"NARA_EXPLORER_URL", "", "", (*stringValue)(&c.ExplorerURL)},
+		{"NARA_LICHESS_URL", "", "", (*stringValue)(&c.LichessURL)},
+		{"NARA_LICHESS_TOKEN", "", "", (*stringValue)(&c.LichessToken)},
+		{"NARA_CHESSCOM_URL", "", "", (*stringValue)(&c.ChessComURL)},
+
+		{"NARA_ANALYSIS_WORKERS", "", "", (*intValue)(&c.AnalysisWorkers)},
+		{"NARA_WEBHOOK_SECRET", "", "", (*stringValue)(&c.WebhookSecret)},
+
+		{"NARA_ADMIN_KEYS", "", "", (*listValue)(&c.AdminKeys)},
+		{"NARA_API_KEYS", "", "", (*listValue)(&c.APIKeys)},
+		{"NARA_API_KEYS_FILE", "", "", (*stringValue)(&c.APIKeysFile)},
+		{"NARA_API_KEY_RATE_LIMIT", "", "", (*intValue)(&c.APIKeyRateLimit)},
+		{"NARA_IP_RATE_LIMIT", "", "", (*intValue)(&c.IPRateLimit)},
+		{"NARA_TRUST_PROXY", "", "", (*boolValue)(&c.TrustProxy)},
+		{"NARA_DAILY_MODEL_QUOTA", "daily-model-quota", "model `calls` each caller may make per day, 0 for no limit", (*intValue)(&c.DailyModelQuota)},
+
+		{"GOOGLE_CLIENT_ID", "", "", (*stringValue)(&c.GoogleClientID)},
+		{"GOOGLE_CLIENT_SECRET", "", "", (*stringValue)(&c.GoogleClientSecret)},
+		{"NARA_LICHESS_CLIENT_ID", "", "", (*stringValue)(&c.LichessClientID)},
+		{"NARA_SESSION_SECRET", "", "", (*stringValue)(&c.SessionSecret)},
+		{"NARA_SESSION_TTL", "", "", (*durationValue)(&c.SessionTTL)},
+		{"NARA_PUBLIC_URL", "", "", (*stringValue)(&c.PublicURL)},
+		{"NARA_LOGIN_REDIRECT", "", "", (*stringValue)(&c.LoginRedirect)},
+	}
+}
@@ -0,0 +1,142 @@
+// Package cors answers browsers' cross-origin checks for the origins a deployment allows.
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DevOrigin is the web client's development server.
+const DevOrigin = "http://localhost:5173"
+
+// regexpPrefix marks an allowed origin written as a regular expression.
+const regexpPrefix = "regexp:"
+
+// wildcardLabel is what a * in a wildcard origin matches: any run of host or port
+// characters, but never the separators around them.
+const wildcardLabel = `[A-Za-z0-9.-]+`
+
+// Policy decides which browser origins may call the server and what they may send.
+type Policy struct {
+	// Methods and Headers list the methods and request headers preflights allow.
+	Methods []string
+	Headers []string
+	// Expose lists the response headers, beyond the always-safe ones, that scripts may read.
+	Expose []string
+	// Credentials lets browsers send cookies and Authorization headers cross-origin.
+	Credentials bool
+	// MaxAge is how long browsers may cache a preflight's answer; zero leaves it to them.
+	MaxAge time.Duration
+
+	any      bool
+	exact    map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// NewPolicy allows origins, each one of:
+//   - "*", any origin;
+//   - a scheme and host such as "https://app.example.com", that origin alone;
+//   - the same with * for part of the host or port, such as "https://*.example.com", any
+//     origin matching it;
+//   - "regexp:" and a regular expression, which must match the whole origin.
+func NewPolicy(origins []string) (*Policy, error) {
+	p := &Policy{
+		Methods: []string{http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodOptions},
+		Headers: []string{"Content-Type", "Authorization"},
+		exact:   make(map[string]bool),
+	}
+	for _, origin := range origins {
+		switch {
+		case origin == "*":
+			p.any = true
+		case strings.HasPrefix(origin, regexpPrefix):
+			re, err := regexp.Compile(`^(?:` + strings.TrimPrefix(origin, regexpPrefix) + `)$`)
+			if err != nil {
+				return nil, fmt.Errorf("allowed origin %q: %w", origin, err)
+			}
+			p.patterns = append(p.patterns, re)
+		case strings.Contains(origin, "*"):
+			if err := checkOrigin(strings.ReplaceAll(origin, "*", "0")); err != nil {
+				return nil, fmt.Errorf("allowed origin %q: %w", origin, err)
+			}
+			pattern := strings.ReplaceAll(regexp.QuoteMeta(origin), `\*`, wildcardLabel)
+			p.patterns = append(p.patterns, regexp.MustCompile(`^`+pattern+`$`))
+		default:
+			if err := checkOrigin(origin); err != nil {
+				return nil, fmt.Errorf("allowed origin %q: %w", origin, err)
+			}
+			p.exact[origin] = true
+		}
+	}
+	return p, nil
+}
+
+// DefaultPolicy allows the web client's development server alone.
+func DefaultPolicy() *Policy {
+	p, _ := NewPolicy([]string{DevOrigin})
+	return p
+}
+
+func checkOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+		return fmt.Errorf("want a scheme and host such as %q", "https://example.com")
+	}
+	return nil
+}
+
+// Allowed reports whether the browser origin may call the server.
+func (p *Policy) Allowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if p.any || p.exact[origin] {
+		return true
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware adds the CORS headers for allowed origins to next's responses and answers
+// preflight requests itself.
+func (p *Policy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if p.Allowed(origin) {
+			// A literal * can't carry credentials, so those get their origin echoed back.
+			if p.any && !p.Credentials {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if p.Credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			if len(p.Expose) > 0 && r.Method != http.MethodOptions {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(p.Expose, ", "))
+			}
+		}
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(p.Methods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(p.Headers, ", "))
+			if p.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
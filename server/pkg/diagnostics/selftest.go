@@ -0,0 +1,135 @@
+package diagnostics
+
+import (
+	"fmt"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+// Check is the outcome of one self-test.
+type Check struct {
+	Name     string `json:"name"`
+	Passed   bool   `json:"passed"`
+	Detail   string `json:"detail,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the result of a self-test run.
+type Report struct {
+	Passed bool      `json:"passed"`
+	RanAt  time.Time `json:"ran_at"`
+	Checks []Check   `json:"checks"`
+}
+
+// MoveGenerator produces the legal moves of a position.
+type MoveGenerator func(*chess.Position) []chess.Move
+
+// perftCases are shallow node counts from well-known perft positions.
+var perftCases = []struct {
+	name  string
+	fen   string
+	depth int
+	nodes int64
+}{
+	{"perft start position", chess.StartFEN, 3, 8902},
+	{"perft kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 2, 2039},
+	{"perft rook endgame", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 3, 2812},
+	{"perft promotions", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 2, 264},
+}
+
+// legalityCases pin down rules that are easy to break: castling out of or through check,
+// pinned pieces, en passant and checkmate.
+var legalityCases = []struct {
+	name    string
+	fen     string
+	move    string // UCI
+	allowed bool
+}{
+	{"castling through check", "4k3/8/8/8/8/8/5r2/4K2R w K - 0 1", "e1g1", false},
+	{"castling out of check", "4k3/8/8/8/8/8/4r3/4K2R w K - 0 1", "e1g1", false},
+	{"castling allowed", "4k3/8/8/8/8/8/8/4K2R w K - 0 1", "e1g1", true},
+	{"pinned knight", "4k3/4r3/8/8/8/8/4N3/4K3 w - - 0 1", "e2c3", false},
+	{"en passant capture", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", true},
+	{"en passant exposing the king", "8/8/8/K2pP2r/8/8/8/4k3 w - d6 0 1", "e5d6", false},
+	{"no moves when checkmated", "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3", "", false},
+}
+
+// Run self-tests the engine's legal move generator.
+func Run() Report {
+	return RunWith(func(p *chess.Position) []chess.Move { return p.LegalMoves() })
+}
+
+// RunWith self-tests gen: perft node counts on known positions and a handful of legality
+// rules.
+func RunWith(gen MoveGenerator) Report {
+	report := Report{Passed: true, RanAt: time.Now().UTC()}
+	record := func(name string, start time.Time, err error) {
+		c := Check{Name: name, Passed: err == nil, Duration: time.Since(start).String()}
+		if err != nil {
+			c.Detail = err.Error()
+			report.Passed = false
+		}
+		report.Checks = append(report.Checks, c)
+	}
+
+	for _, tc := range perftCases {
+		start := time.Now()
+		pos, err := chess.ParseFEN(tc.fen)
+		if err == nil {
+			if nodes := perft(pos, tc.depth, gen); nodes != tc.nodes {
+				err = fmt.Errorf("depth %d: got %d nodes, want %d", tc.depth, nodes, tc.nodes)
+			}
+		}
+		record(tc.name, start, err)
+	}
+
+	for _, tc := range legalityCases {
+		start := time.Now()
+		pos, err := chess.ParseFEN(tc.fen)
+		if err == nil {
+			err = checkLegality(pos, tc.move, tc.allowed, gen)
+		}
+		record(tc.name, start, err)
+	}
+	return report
+}
+
+func perft(pos *chess.Position, depth int, gen MoveGenerator) int64 {
+	moves := gen(pos)
+	if depth == 1 {
+		return int64(len(moves))
+	}
+	var nodes int64
+	for _, m := range moves {
+		nodes += perft(pos.Play(m), depth-1, gen)
+	}
+	return nodes
+}
+
+// checkLegality verifies that move (UCI) is generated exactly when allowed. An empty move
+// asserts that the position has no legal moves at all.
+func checkLegality(pos *chess.Position, move string, allowed bool, gen MoveGenerator) error {
+	moves := gen(pos)
+	if move == "" {
+		if len(moves) != 0 {
+			return fmt.Errorf("expected no legal moves, got %d", len(moves))
+		}
+		return nil
+	}
+
+	found := false
+	for _, m := range moves {
+		if m.String() == move {
+			found = true
+			break
+		}
+	}
+	switch {
+	case found && !allowed:
+		return fmt.Errorf("illegal move %s was generated", move)
+	case !found && allowed:
+		return fmt.Errorf("legal move %s was not generated", move)
+	}
+	return nil
+}
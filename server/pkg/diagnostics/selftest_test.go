@@ -0,0 +1,98 @@
+package diagnostics
+
+import (
+	"testing"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+func TestRunPasses(t *testing.T) {
+	report := Run()
+	if !report.Passed {
+		t.Fatalf("self-test failed: %+v", report.Checks)
+	}
+	if want := len(perftCases) + len(legalityCases); len(report.Checks) != want {
+		t.Errorf("ran %d checks, want %d", len(report.Checks), want)
+	}
+	for _, c := range report.Checks {
+		if !c.Passed || c.Detail != "" || c.Duration == "" {
+			t.Errorf("check = %+v", c)
+		}
+	}
+	if report.RanAt.IsZero() {
+		t.Error("report has no time")
+	}
+}
+
+// failed maps the checks that failed to their details.
+func failed(report Report) map[string]string {
+	details := map[string]string{}
+	for _, c := range report.Checks {
+		if !c.Passed {
+			details[c.Name] = c.Detail
+		}
+	}
+	return details
+}
+
+func TestRunWithBrokenGenerator(t *testing.T) {
+	// without returns a generator that leaves out the legal moves drop matches.
+	without := func(drop func(p *chess.Position, m chess.Move) bool) MoveGenerator {
+		return func(p *chess.Position) []chess.Move {
+			var moves []chess.Move
+			for _, m := range p.LegalMoves() {
+				if !drop(p, m) {
+					moves = append(moves, m)
+				}
+			}
+			return moves
+		}
+	}
+	tests := []struct {
+		name string
+		gen  MoveGenerator
+		// fails must be among the failed checks.
+		fails []string
+	}{
+		{
+			name: "drops castling",
+			gen: without(func(p *chess.Position, m chess.Move) bool {
+				d := m.To.File() - m.From.File()
+				return p.Board[m.From].Type() == chess.King && (d == 2 || d == -2)
+			}),
+			fails: []string{"perft kiwipete", "castling allowed"},
+		},
+		{
+			name: "drops en passant",
+			gen: without(func(p *chess.Position, m chess.Move) bool {
+				return p.Board[m.From].Type() == chess.Pawn && m.From.File() != m.To.File() && p.Board[m.To] == chess.NoPiece
+			}),
+			fails: []string{"perft rook endgame", "en passant capture"},
+		},
+		{
+			name: "duplicates a move",
+			gen: func(p *chess.Position) []chess.Move {
+				moves := p.LegalMoves()
+				if len(moves) > 0 {
+					moves = append(moves, moves[0])
+				}
+				return moves
+			},
+			fails: []string{"perft start position", "perft kiwipete", "perft rook endgame", "perft promotions"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := RunWith(tt.gen)
+			if report.Passed {
+				t.Fatal("self-test passed a broken generator")
+			}
+			got := failed(report)
+			for _, name := range tt.fails {
+				if detail, ok := got[name]; !ok || detail == "" {
+					t.Errorf("%q did not fail with a detail; failed checks %q", name, got)
+				}
+			}
+		})
+	}
+}
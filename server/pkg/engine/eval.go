@@ -0,0 +1,123 @@
+package engine
+
+import "arnavsurve/nara-chess/server/pkg/chess"
+
+var pieceValues = [7]int{
+	chess.Pawn:   100,
+	chess.Knight: 320,
+	chess.Bishop: 330,
+	chess.Rook:   500,
+	chess.Queen:  900,
+	chess.King:   0,
+}
+
+// Piece-square tables from White's point of view, indexed a1..h8.
+var pieceSquareTables = [7][64]int{
+	chess.Pawn: {
+		0, 0, 0, 0, 0, 0, 0, 0,
+		5, 10, 10, -20, -20, 10, 10, 5,
+		5, -5, -10, 0, 0, -10, -5, 5,
+		0, 0, 0, 20, 20, 0, 0, 0,
+		5, 5, 10, 25, 25, 10, 5, 5,
+		10, 10, 20, 30, 30, 20, 10, 10,
+		50, 50, 50, 50, 50, 50, 50, 50,
+		0, 0, 0, 0, 0, 0, 0, 0,
+	},
+	chess.Knight: {
+		-50, -40, -30, -30, -30, -30, -40, -50,
+		-40, -20, 0, 5, 5, 0, -20, -40,
+		-30, 5, 10, 15, 15, 10, 5, -30,
+		-30, 0, 15, 20, 20, 15, 0, -30,
+		-30, 5, 15, 20, 20, 15, 5, -30,
+		-30, 0, 10, 15, 15, 10, 0, -30,
+		-40, -20, 0, 0, 0, 0, -20, -40,
+		-50, -40, -30, -30, -30, -30, -40, -50,
+	},
+	chess.Bishop: {
+		-20, -10, -10, -10, -10, -10, -10, -20,
+		-10, 5, 0, 0, 0, 0, 5, -10,
+		-10, 10, 10, 10, 10, 10, 10, -10,
+		-10, 0, 10, 10, 10, 10, 0, -10,
+		-10, 5, 5, 10, 10, 5, 5, -10,
+		-10, 0, 5, 10, 10, 5, 0, -10,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-20, -10, -10, -10, -10, -10, -10, -20,
+	},
+	chess.Rook: {
+		0, 0, 0, 5, 5, 0, 0, 0,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		5, 10, 10, 10, 10, 10, 10, 5,
+		0, 0, 0, 0, 0, 0, 0, 0,
+	},
+	chess.Queen: {
+		-20, -10, -10, -5, -5, -10, -10, -20,
+		-10, 0, 5, 0, 0, 0, 0, -10,
+		-10, 5, 5, 5, 5, 5, 0, -10,
+		0, 0, 5, 5, 5, 5, 0, -5,
+		-5, 0, 5, 5, 5, 5, 0, -5,
+		-10, 0, 5, 5, 5, 5, 0, -10,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-20, -10, -10, -5, -5, -10, -10, -20,
+	},
+	chess.King: {
+		20, 30, 10, 0, 0, 10, 30, 20,
+		20, 20, 0, 0, 0, 0, 20, 20,
+		-10, -20, -20, -20, -20, -20, -20, -10,
+		-20, -30, -30, -40, -40, -30, -30, -20,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+	},
+}
+
+// Evaluate returns a static evaluation in centipawns from the side to move's point of view.
+// Crazyhouse pieces in hand count at their nominal value.
+func Evaluate(pos *chess.Position) int {
+	score := pocketValue(pos.Pockets[pos.Turn]) - pocketValue(pos.Pockets[pos.Turn.Other()])
+	for i, piece := range pos.Board {
+		if piece == chess.NoPiece {
+			continue
+		}
+		sq := i
+		if piece.Color() == chess.Black {
+			sq = i ^ 56 // mirror the rank
+		}
+		v := pieceValues[piece.Type()] + pieceSquareTables[piece.Type()][sq]
+		if piece.Color() == pos.Turn {
+			score += v
+		} else {
+			score -= v
+		}
+	}
+	return score
+}
+
+// PieceValue returns the nominal material value of a piece type in centipawns.
+func PieceValue(t chess.PieceType) int {
+	return pieceValues[t]
+}
+
+// Material returns the total nominal material value of c's pieces, on the board and, in
+// Crazyhouse, in hand.
+func Material(pos *chess.Position, c chess.Color) int {
+	total := pocketValue(pos.Pockets[c])
+	for _, piece := range pos.Board {
+		if piece != chess.NoPiece && piece.Color() == c {
+			total += pieceValues[piece.Type()]
+		}
+	}
+	return total
+}
+
+func pocketValue(pocket chess.Pocket) int {
+	total := 0
+	for t, n := range pocket {
+		total += n * pieceValues[t]
+	}
+	return total
+}
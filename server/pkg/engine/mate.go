@@ -0,0 +1,76 @@
+package engine
+
+import "arnavsurve/nara-chess/server/pkg/chess"
+
+// mateNodeLimit bounds the work FindMate does so a quiet middlegame never stalls a request.
+const mateNodeLimit = 500_000
+
+// crazyhouseMaxMate caps the length of mates FindMate looks for in Crazyhouse, where drops
+// exhaust mateNodeLimit slowly.
+const crazyhouseMaxMate = 2
+
+// Mate is a forced mate found by FindMate.
+type Mate struct {
+	In   int        // number of moves by the mating side, including the mating move
+	Move chess.Move // the first move of the mating line
+}
+
+// FindMate looks for a forced mate by the side to move in at most maxMoves moves and
+// returns the shortest one. It reports false when there is none or the search gave up
+// before proving one. Crazyhouse mates are looked for up to crazyhouseMaxMate moves.
+func FindMate(pos *chess.Position, maxMoves int) (Mate, bool) {
+	if pos.Variant == chess.Crazyhouse {
+		maxMoves = min(maxMoves, crazyhouseMaxMate)
+	}
+	s := &mateSearcher{}
+	for n := 1; n <= maxMoves; n++ {
+		if m, ok := s.attack(pos, n); ok {
+			return Mate{In: n, Move: m}, true
+		}
+		if s.aborted {
+			break
+		}
+	}
+	return Mate{}, false
+}
+
+type mateSearcher struct {
+	nodes   int
+	aborted bool
+}
+
+// attack returns a move that forces mate in n moves for the side to move.
+func (s *mateSearcher) attack(pos *chess.Position, n int) (chess.Move, bool) {
+	for _, m := range orderMoves(pos, pos.LegalMoves(), chess.Move{}) {
+		s.nodes++
+		if s.nodes > mateNodeLimit {
+			s.aborted = true
+			return chess.Move{}, false
+		}
+		next := pos.Play(m)
+		if n == 1 {
+			if next.IsCheckmate() {
+				return m, true
+			}
+			continue
+		}
+		if s.defend(next, n-1) {
+			return m, true
+		}
+	}
+	return chess.Move{}, false
+}
+
+// defend reports whether every reply by the side to move still allows mate in n.
+func (s *mateSearcher) defend(pos *chess.Position, n int) bool {
+	moves := pos.LegalMoves()
+	if len(moves) == 0 {
+		return pos.InCheck()
+	}
+	for _, m := range orderMoves(pos, moves, chess.Move{}) {
+		if _, ok := s.attack(pos.Play(m), n); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"testing"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+// forcesMate reports whether m forces mate in n moves from pos, whatever the defence.
+func forcesMate(pos *chess.Position, m chess.Move, n int) bool {
+	next := pos.Play(m)
+	if n == 1 {
+		return next.IsCheckmate()
+	}
+	replies := next.LegalMoves()
+	if len(replies) == 0 {
+		return false
+	}
+	for _, r := range replies {
+		mate, ok := FindMate(next.Play(r), n-1)
+		if !ok || mate.In > n-1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFindMate(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		in   int
+	}{
+		{"back rank", "6k1/5ppp/8/8/8/8/5PPP/1R1R2K1 w - - 0 1", 1},
+		{"rook and king", "6k1/8/5K2/8/8/8/8/R7 w - - 0 1", 2},
+		{"queen sacrifice", "r5k1/5ppp/8/8/8/8/1Q3PPP/1R4K1 w - - 0 1", 2},
+		{"black mates", "1r4k1/1q3ppp/8/8/8/8/5PPP/R5K1 b - - 0 1", 2},
+		{"king walks up", "6k1/8/8/6K1/8/8/8/R7 w - - 0 1", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos := mustParseFEN(t, tt.fen)
+			mate, ok := FindMate(pos, 3)
+			if !ok || mate.In != tt.in {
+				t.Fatalf("FindMate = %+v, %v; want mate in %d", mate, ok, tt.in)
+			}
+			if !forcesMate(pos, mate.Move, tt.in) {
+				t.Errorf("%s does not force mate in %d", pos.SAN(mate.Move), tt.in)
+			}
+
+			// A search too short to reach the mate finds nothing.
+			if mate, ok := FindMate(pos, tt.in-1); ok {
+				t.Errorf("FindMate(%d) = %+v, want none", tt.in-1, mate)
+			}
+		})
+	}
+}
+
+func TestFindMateNone(t *testing.T) {
+	for _, fen := range []string{
+		chess.StartFEN,
+		"2r3k1/5ppp/8/8/8/8/5PPP/1R1R2K1 w - - 0 1",
+		"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1",    // stalemate
+		"R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1", // already mated
+	} {
+		if mate, ok := FindMate(mustParseFEN(t, fen), 3); ok {
+			t.Errorf("FindMate(%s) = %+v, want none", fen, mate)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"fmt"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+// PlyEval is one move of a principal variation with the evaluation after it is played.
+type PlyEval struct {
+	Move  chess.Move
+	San   string
+	Fen   string
+	Score int // centipawns from White's point of view
+}
+
+// WhiteScore converts a side-to-move score into White's point of view.
+func WhiteScore(score int, turn chess.Color) int {
+	if turn == chess.Black {
+		return -score
+	}
+	return score
+}
+
+// ScoreFor converts a score from White's point of view into c's point of view.
+func ScoreFor(whiteScore int, c chess.Color) int {
+	if c == chess.Black {
+		return -whiteScore
+	}
+	return whiteScore
+}
+
+// MateIn converts a mate score into full moves until mate: positive when the side the
+// score belongs to delivers mate, negative when it is mated. It returns 0 for ordinary
+// scores and for a position that is already checkmate.
+func MateIn(score int) int {
+	switch {
+	case score > mateThreshold:
+		return (MateScore - score + 1) / 2
+	case score < -mateThreshold:
+		return -(MateScore + score + 1) / 2
+	}
+	return 0
+}
+
+// FormatScore renders a score for display: "#3" or "#-2" for forced mates and a signed
+// pawn value such as "+0.35" otherwise.
+func FormatScore(score int) string {
+	if IsMateScore(score) {
+		n := MateIn(score)
+		if n == 0 && score < 0 {
+			return "#-0"
+		}
+		return fmt.Sprintf("#%d", n)
+	}
+	return fmt.Sprintf("%+.2f", float64(score)/100)
+}
+
+// AnnotatePV plays each move of pv from pos and evaluates the resulting position with a
+// search whose depth shrinks as the line goes on, so the whole line costs roughly one
+// search of the given depth. Mate distances count from the position after each move.
+func AnnotatePV(pos *chess.Position, pv []chess.Move, depth int) []PlyEval {
+	evals := make([]PlyEval, 0, len(pv))
+	for i, m := range pv {
+		if !pos.IsLegal(m) {
+			break
+		}
+		san := pos.SAN(m)
+		next := pos.Play(m)
+
+		var score int
+		switch {
+		case next.IsCheckmate():
+			score = -MateScore
+		case len(next.LegalMoves()) == 0:
+			score = 0
+		default:
+			score = Search(next, max(depth-i-1, 1)).Score
+		}
+		evals = append(evals, PlyEval{
+			Move:  m,
+			San:   san,
+			Fen:   next.FEN(),
+			Score: WhiteScore(score, next.Turn),
+		})
+		pos = next
+	}
+	return evals
+}
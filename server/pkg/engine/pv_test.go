@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+func TestAnnotatePVMatingLine(t *testing.T) {
+	for _, fen := range []string{
+		"6k1/8/5K2/8/8/8/8/R7 w - - 0 1",
+		"k7/8/2K5/8/8/8/8/1R6 w - - 0 1",
+	} {
+		t.Run(fen, func(t *testing.T) {
+			pos := mustParseFEN(t, fen)
+			result := Search(pos, 4)
+			evals := AnnotatePV(pos, result.PV, 4)
+			if len(evals) != len(result.PV) {
+				t.Fatalf("annotated %d of %d plies", len(evals), len(result.PV))
+			}
+
+			// White is mating throughout, and each ply brings the mate closer.
+			for i, e := range evals {
+				if !IsMateScore(e.Score) || e.Score < 0 {
+					t.Errorf("ply %d (%s) scored %d, want White mating", i+1, e.San, e.Score)
+				}
+				if i > 0 && e.Score < evals[i-1].Score {
+					t.Errorf("ply %d (%s) scored %d after %d, want the mate to draw nearer", i+1, e.San, e.Score, evals[i-1].Score)
+				}
+				if !strings.HasPrefix(FormatScore(e.Score), "#") {
+					t.Errorf("ply %d displayed as %s, want a mate", i+1, FormatScore(e.Score))
+				}
+			}
+			last := evals[len(evals)-1]
+			if last.Score != MateScore || !mustParseFEN(t, last.Fen).IsCheckmate() {
+				t.Errorf("line ends with %s scored %d, want checkmate", last.San, last.Score)
+			}
+		})
+	}
+}
+
+func TestAnnotatePVQuietLine(t *testing.T) {
+	for _, fen := range []string{
+		chess.StartFEN,
+		"r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4",
+	} {
+		t.Run(fen, func(t *testing.T) {
+			pos := mustParseFEN(t, fen)
+			result := Search(pos, 4)
+			evals := AnnotatePV(pos, result.PV, 4)
+			if len(evals) == 0 {
+				t.Fatal("no plies annotated")
+			}
+
+			// Nothing is won or lost along the line, so the eval only drifts.
+			prev := 0
+			for i, e := range evals {
+				if IsMateScore(e.Score) {
+					t.Errorf("ply %d (%s) scored a mate in a quiet line", i+1, e.San)
+				}
+				if d := e.Score - prev; d > 100 || d < -100 {
+					t.Errorf("ply %d (%s) swung the eval from %d to %d", i+1, e.San, prev, e.Score)
+				}
+				prev = e.Score
+			}
+		})
+	}
+}
+
+func TestAnnotatePVStopsAtIllegalMove(t *testing.T) {
+	pos := chess.NewGame()
+	e4, err := pos.ParseSAN("e4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// e4 again is illegal once it has been played.
+	if evals := AnnotatePV(pos, []chess.Move{e4, e4}, 2); len(evals) != 1 {
+		t.Errorf("annotated %d plies, want 1", len(evals))
+	}
+}
+
+func TestFormatScore(t *testing.T) {
+	tests := []struct {
+		score int
+		want  string
+	}{
+		{0, "+0.00"},
+		{35, "+0.35"},
+		{-120, "-1.20"},
+		{MateScore, "#0"},
+		{MateScore - 1, "#1"},
+		{MateScore - 3, "#2"},
+		{-(MateScore - 2), "#-1"},
+		{-MateScore, "#-0"},
+	}
+	for _, tt := range tests {
+		if got := FormatScore(tt.score); got != tt.want {
+			t.Errorf("FormatScore(%d) = %s, want %s", tt.score, got, tt.want)
+		}
+	}
+}
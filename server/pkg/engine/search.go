@@ -0,0 +1,233 @@
+package engine
+
+import (
+	"sort"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+const (
+	MateScore = 1_000_000
+	infinity  = MateScore + 1
+	// Scores beyond this threshold encode a forced mate.
+	mateThreshold = MateScore - 1000
+	// crazyhouseMaxDepth caps searches of Crazyhouse positions, where drops multiply the
+	// moves to consider several times over.
+	crazyhouseMaxDepth = 2
+)
+
+type Result struct {
+	Move  chess.Move
+	Score int // centipawns from the side to move's point of view
+	PV    []chess.Move
+	Depth int
+	Nodes int
+}
+
+// IsMateScore reports whether score encodes a forced mate for either side.
+func IsMateScore(score int) bool {
+	return score > mateThreshold || score < -mateThreshold
+}
+
+// Search runs an iterative-deepening alpha-beta search to the given depth and returns the
+// best move found. Equal-scoring moves are resolved deterministically: captures before
+// checks before quiet moves, then by lower origin and destination square. The returned
+// Result has a zero Move when the side to move has no legal moves.
+func Search(pos *chess.Position, depth int) Result {
+	return SearchMoves(pos, depth, nil)
+}
+
+// SearchMoves is Search restricted to the given root moves. A nil slice searches every
+// legal move. Crazyhouse positions are searched no deeper than crazyhouseMaxDepth.
+func SearchMoves(pos *chess.Position, depth int, rootMoves []chess.Move) Result {
+	if pos.Variant == chess.Crazyhouse {
+		depth = min(depth, crazyhouseMaxDepth)
+	}
+	if depth < 1 {
+		depth = 1
+	}
+
+	s := &searcher{rootMoves: rootMoves}
+	var result Result
+	for d := 1; d <= depth; d++ {
+		score, pv := s.negamax(pos, d, 0, -infinity, infinity, result.PV)
+		result = Result{Score: score, PV: pv, Depth: d, Nodes: s.nodes}
+		if len(pv) > 0 {
+			result.Move = pv[0]
+		}
+		if IsMateScore(score) {
+			break
+		}
+	}
+	return result
+}
+
+type searcher struct {
+	nodes     int
+	rootMoves []chess.Move
+}
+
+func (s *searcher) negamax(pos *chess.Position, depth, ply, alpha, beta int, pvHint []chess.Move) (int, []chess.Move) {
+	s.nodes++
+
+	if wonOnHill(pos) {
+		return -MateScore + ply, nil
+	}
+	moves := pos.LegalMoves()
+	if ply == 0 && s.rootMoves != nil {
+		moves = s.rootMoves
+	}
+	if len(moves) == 0 {
+		if pos.InCheck() {
+			return -MateScore + ply, nil
+		}
+		return 0, nil
+	}
+	if pos.HalfmoveClock >= 100 {
+		return 0, nil
+	}
+	if depth == 0 {
+		return s.quiesce(pos, alpha, beta), nil
+	}
+
+	var hint chess.Move
+	var childHint []chess.Move
+	if len(pvHint) > 0 {
+		hint = pvHint[0]
+		childHint = pvHint[1:]
+	}
+	ordered := orderMoves(pos, moves, hint)
+
+	var bestPV []chess.Move
+	for i, m := range ordered {
+		var h []chess.Move
+		if i == 0 && m == hint {
+			h = childHint
+		}
+		score, childPV := s.negamax(pos.Play(m), depth-1, ply+1, -beta, -alpha, h)
+		score = -score
+		if score > alpha || bestPV == nil {
+			if score > alpha {
+				alpha = score
+			}
+			bestPV = append([]chess.Move{m}, childPV...)
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return alpha, bestPV
+}
+
+// quiesce extends the search along capture sequences so the static evaluation is never
+// taken in the middle of an exchange.
+func (s *searcher) quiesce(pos *chess.Position, alpha, beta int) int {
+	s.nodes++
+
+	if wonOnHill(pos) {
+		return -MateScore
+	}
+	standPat := Evaluate(pos)
+	if standPat >= beta {
+		return beta
+	}
+	if standPat > alpha {
+		alpha = standPat
+	}
+
+	var captures []chess.Move
+	for _, m := range pos.LegalMoves() {
+		if pos.IsCapture(m) || m.Promotion == chess.Queen {
+			captures = append(captures, m)
+		}
+	}
+	for _, m := range orderMoves(pos, captures, chess.Move{}) {
+		score := -s.quiesce(pos.Play(m), -beta, -alpha)
+		if score >= beta {
+			return beta
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	return alpha
+}
+
+// wonOnHill reports whether the side that just moved has won a King of the Hill game by
+// reaching the centre, which the search scores like a mate.
+func wonOnHill(pos *chess.Position) bool {
+	return pos.Variant == chess.KingOfTheHill && pos.OnHill(pos.Turn.Other())
+}
+
+// orderMoves sorts moves for alpha-beta: the hint move first, then captures by
+// most-valuable-victim/least-valuable-attacker, then checks, then quiet moves. Ties are
+// broken by origin square, destination square and promotion piece so the order is stable.
+func orderMoves(pos *chess.Position, moves []chess.Move, hint chess.Move) []chess.Move {
+	type scored struct {
+		move  chess.Move
+		score int
+	}
+	list := make([]scored, len(moves))
+	for i, m := range moves {
+		list[i] = scored{move: m, score: moveOrderScore(pos, m, hint)}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.move.From != b.move.From {
+			return a.move.From < b.move.From
+		}
+		if a.move.To != b.move.To {
+			return a.move.To < b.move.To
+		}
+		if a.move.Promotion != b.move.Promotion {
+			return a.move.Promotion > b.move.Promotion
+		}
+		return a.move.Drop > b.move.Drop
+	})
+
+	ordered := make([]chess.Move, len(list))
+	for i, s := range list {
+		ordered[i] = s.move
+	}
+	return ordered
+}
+
+func moveOrderScore(pos *chess.Position, m, hint chess.Move) int {
+	if m == hint {
+		return 1_000_000
+	}
+	score := 0
+	if pos.IsCapture(m) {
+		victim := pos.Board[m.To].Type()
+		if victim == chess.NoPieceType {
+			victim = chess.Pawn // en passant
+		}
+		score += 100_000 + 10*pieceValues[victim] - pieceValues[pos.Board[m.From].Type()]/10
+	} else if pos.Play(m).InCheck() {
+		score += 50_000
+	}
+	if m.Promotion != chess.NoPieceType {
+		score += pieceValues[m.Promotion]
+	}
+	return score
+}
+
+// TopMoves searches every legal move to the given depth and returns the n best, strongest
+// first. Equal scores keep the deterministic move ordering used by Search.
+func TopMoves(pos *chess.Position, depth, n int) []Result {
+	moves := orderMoves(pos, pos.LegalMoves(), chess.Move{})
+	results := make([]Result, 0, len(moves))
+	for _, m := range moves {
+		results = append(results, SearchMoves(pos, depth, []chess.Move{m}))
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if n < len(results) {
+		results = results[:n]
+	}
+	return results
+}
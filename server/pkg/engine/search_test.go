@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"testing"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+func mustParseFEN(t *testing.T, fen string) *chess.Position {
+	t.Helper()
+	pos, err := chess.ParseFEN(fen)
+	if err != nil {
+		t.Fatalf("ParseFEN(%q): %v", fen, err)
+	}
+	return pos
+}
+
+func TestSearchFindsMateInOne(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want string
+	}{
+		{"back rank", "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "a1a8"},
+		{"rook with king opposition", "7k/8/6K1/8/8/8/8/R7 w - - 0 1", "a1a8"},
+		{"black to move", "r5k1/8/8/8/8/8/5PPP/6K1 b - - 0 1", "a8a1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos := mustParseFEN(t, tt.fen)
+			result := Search(pos, 3)
+			if result.Move.String() != tt.want {
+				t.Errorf("Search played %s, want %s", result.Move, tt.want)
+			}
+			if !IsMateScore(result.Score) || MateIn(result.Score) != 1 {
+				t.Errorf("Search scored %d, want mate in 1", result.Score)
+			}
+			if !pos.Play(result.Move).IsCheckmate() {
+				t.Errorf("%s does not mate", result.Move)
+			}
+		})
+	}
+}
+
+func TestSearchWinsHangingPiece(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want string
+	}{
+		{"undefended queen", "4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1", "d2d5"},
+		{"undefended rook", "4k3/8/8/8/8/2r5/8/2B1K3 b - - 0 1", "c3c1"},
+		{"knight takes loose bishop", "4k3/8/8/4b3/8/5N2/8/4K3 w - - 0 1", "f3e5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Search(mustParseFEN(t, tt.fen), 3)
+			if result.Move.String() != tt.want {
+				t.Errorf("Search played %s, want %s", result.Move, tt.want)
+			}
+			if result.Score <= 0 {
+				t.Errorf("Search scored %d, want a winning score", result.Score)
+			}
+		})
+	}
+}
+
+// A poisoned piece is defended, so quiescence must see the recapture.
+func TestSearchDeclinesDefendedPiece(t *testing.T) {
+	pos := mustParseFEN(t, "4k3/8/2p5/3n4/8/8/3Q4/4K3 w - - 0 1")
+	if result := Search(pos, 2); result.Move.String() == "d2d5" {
+		t.Errorf("Search took a knight defended by a pawn with its queen")
+	}
+}
+
+func TestSearchIsDeterministic(t *testing.T) {
+	pos := chess.NewGame()
+	first := Search(pos, 3)
+	for range 3 {
+		if again := Search(pos, 3); again.Move != first.Move || again.Score != first.Score {
+			t.Fatalf("Search returned %s (%d), then %s (%d)", first.Move, first.Score, again.Move, again.Score)
+		}
+	}
+}
+
+func TestSearchWithoutLegalMoves(t *testing.T) {
+	// Black is stalemated.
+	pos := mustParseFEN(t, "k7/2Q5/1K6/8/8/8/8/8 b - - 0 1")
+	if result := Search(pos, 3); result.Move != (chess.Move{}) {
+		t.Errorf("Search played %s with no legal moves", result.Move)
+	}
+}
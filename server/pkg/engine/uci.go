@@ -0,0 +1,242 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+// ErrEngineExited is returned once the UCI engine process has stopped producing output.
+var ErrEngineExited = errors.New("engine: UCI engine exited")
+
+// uciHandshakeTimeout bounds the uci/isready handshake at startup.
+const uciHandshakeTimeout = 10 * time.Second
+
+// UCI drives an external engine such as Stockfish over the Universal Chess Interface. It
+// searches one position at a time; concurrent BestMove calls are serialized.
+type UCI struct {
+	// MoveTime is the search time given to each BestMove call.
+	MoveTime time.Duration
+
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	in    io.WriteCloser
+	lines chan string
+	// exited is closed once the engine's output has ended.
+	exited chan struct{}
+
+	// options holds the spin options the engine announced, such as UCI_Elo and Skill Level.
+	options map[string]spinOption
+	// elo is the strength currently set, 0 for full strength.
+	elo int
+}
+
+// spinOption is the range of a numeric UCI option.
+type spinOption struct {
+	min, max int
+}
+
+// StartUCI launches the engine binary at path and completes the UCI handshake.
+func StartUCI(path string, moveTime time.Duration) (*UCI, error) {
+	cmd := exec.Command(path)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("engine: start %s: %w", path, err)
+	}
+
+	u := &UCI{MoveTime: moveTime, cmd: cmd, in: in, lines: make(chan string, 64),
+		exited: make(chan struct{}), options: map[string]spinOption{}}
+	go func() {
+		scanner := bufio.NewScanner(out)
+		for scanner.Scan() {
+			u.lines <- scanner.Text()
+		}
+		close(u.lines)
+		close(u.exited)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), uciHandshakeTimeout)
+	defer cancel()
+	for _, step := range [][2]string{{"uci", "uciok"}, {"isready", "readyok"}} {
+		if err := u.send(step[0]); err != nil {
+			u.Close()
+			return nil, err
+		}
+		if _, err := u.waitFor(ctx, step[1]); err != nil {
+			u.Close()
+			return nil, fmt.Errorf("engine: UCI handshake: %w", err)
+		}
+	}
+	return u, nil
+}
+
+// parseOption records a spin option from an "option name ... type spin ..." line.
+func (u *UCI) parseOption(line string) {
+	rest, ok := strings.CutPrefix(line, "option name ")
+	if !ok {
+		return
+	}
+	name, spec, ok := strings.Cut(rest, " type ")
+	if !ok || !strings.HasPrefix(spec, "spin") {
+		return
+	}
+	var opt spinOption
+	fields := strings.Fields(spec)
+	for i := 0; i+1 < len(fields); i++ {
+		switch fields[i] {
+		case "min":
+			opt.min, _ = strconv.Atoi(fields[i+1])
+		case "max":
+			opt.max, _ = strconv.Atoi(fields[i+1])
+		}
+	}
+	u.options[name] = opt
+}
+
+// setStrength limits the engine to about elo, or restores full strength for 0. Ratings
+// the engine supports go to UCI_Elo; weaker ones fall back to the lowest Skill Level,
+// which Stockfish plays well below its UCI_Elo minimum. Engines with neither option play
+// at full strength.
+func (u *UCI) setStrength(ctx context.Context, elo int) error {
+	if elo == u.elo {
+		return nil
+	}
+	var commands []string
+	eloOpt, hasElo := u.options["UCI_Elo"]
+	skill, hasSkill := u.options["Skill Level"]
+	switch {
+	case elo == 0:
+		if hasElo {
+			commands = append(commands, "setoption name UCI_LimitStrength value false")
+		}
+		if hasSkill {
+			commands = append(commands, fmt.Sprintf("setoption name Skill Level value %d", skill.max))
+		}
+	case hasElo && elo >= eloOpt.min:
+		commands = append(commands,
+			"setoption name UCI_LimitStrength value true",
+			fmt.Sprintf("setoption name UCI_Elo value %d", min(elo, eloOpt.max)))
+	case hasSkill:
+		if hasElo {
+			commands = append(commands, "setoption name UCI_LimitStrength value false")
+		}
+		commands = append(commands, fmt.Sprintf("setoption name Skill Level value %d", skill.min))
+	}
+
+	for _, c := range commands {
+		if err := u.send(c); err != nil {
+			return err
+		}
+	}
+	if len(commands) > 0 {
+		if err := u.send("isready"); err != nil {
+			return err
+		}
+		if _, err := u.waitFor(ctx, "readyok"); err != nil {
+			return err
+		}
+	}
+	u.elo = elo
+	return nil
+}
+
+// BestMove searches pos for MoveTime and returns the engine's choice, playing at about
+// elo when it is positive. Cancelling ctx stops the search early and returns the best move
+// found so far.
+func (u *UCI) BestMove(ctx context.Context, pos *chess.Position, elo int) (chess.Move, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if err := u.setStrength(ctx, elo); err != nil {
+		return chess.Move{}, err
+	}
+	if err := u.send("position fen " + pos.FEN()); err != nil {
+		return chess.Move{}, err
+	}
+	if err := u.send(fmt.Sprintf("go movetime %d", u.MoveTime.Milliseconds())); err != nil {
+		return chess.Move{}, err
+	}
+
+	line, err := u.waitFor(ctx, "bestmove")
+	if err != nil {
+		return chess.Move{}, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[1] == "(none)" {
+		return chess.Move{}, fmt.Errorf("engine: no move in %q", line)
+	}
+	for _, m := range pos.LegalMoves() {
+		if m.String() == fields[1] {
+			return m, nil
+		}
+	}
+	return chess.Move{}, fmt.Errorf("engine: illegal best move %q for %s", fields[1], pos.FEN())
+}
+
+// Alive returns ErrEngineExited once the engine process has stopped, and nil while it
+// runs. Unlike a handshake it doesn't wait for a search under way.
+func (u *UCI) Alive() error {
+	select {
+	case <-u.exited:
+		return ErrEngineExited
+	default:
+		return nil
+	}
+}
+
+// Close asks the engine to quit and waits for the process to exit.
+func (u *UCI) Close() error {
+	u.send("quit")
+	u.in.Close()
+	return u.cmd.Wait()
+}
+
+func (u *UCI) send(command string) error {
+	if _, err := io.WriteString(u.in, command+"\n"); err != nil {
+		return fmt.Errorf("engine: send %q: %w", command, err)
+	}
+	return nil
+}
+
+// waitFor reads engine output until a line starting with prefix. When ctx is done it sends
+// "stop" once and keeps reading, so a running search still ends with its bestmove line and
+// the output stays in step with the next command.
+func (u *UCI) waitFor(ctx context.Context, prefix string) (string, error) {
+	done := ctx.Done()
+	for {
+		select {
+		case line, ok := <-u.lines:
+			if !ok {
+				return "", ErrEngineExited
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line, nil
+			}
+			u.parseOption(line)
+		case <-done:
+			if prefix != "bestmove" {
+				return "", ctx.Err()
+			}
+			done = nil
+			if err := u.send("stop"); err != nil {
+				return "", err
+			}
+		}
+	}
+}
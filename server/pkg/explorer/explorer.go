@@ -0,0 +1,109 @@
+// Package explorer reads move statistics from the lichess opening explorer's masters
+// database: how often strong players chose each move from a position and how those games
+// ended.
+package explorer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/cache"
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+// LichessURL is the public lichess opening explorer.
+const LichessURL = "https://explorer.lichess.ovh"
+
+// Stats is the explorer's summary of master games through a position. Moves is sorted by
+// popularity.
+type Stats struct {
+	White   int      `json:"white"`
+	Draws   int      `json:"draws"`
+	Black   int      `json:"black"`
+	Moves   []Move   `json:"moves"`
+	Opening *Opening `json:"opening"`
+}
+
+type Move struct {
+	UCI           string `json:"uci"`
+	SAN           string `json:"san"`
+	AverageRating int    `json:"averageRating"`
+	White         int    `json:"white"`
+	Draws         int    `json:"draws"`
+	Black         int    `json:"black"`
+}
+
+type Opening struct {
+	ECO  string `json:"eco"`
+	Name string `json:"name"`
+}
+
+// Games is the number of master games that reached the position.
+func (s Stats) Games() int {
+	return s.White + s.Draws + s.Black
+}
+
+// Games is the number of master games that continued with the move.
+func (m Move) Games() int {
+	return m.White + m.Draws + m.Black
+}
+
+// Client queries an opening explorer server, remembering answers for a day since the
+// masters database is only updated occasionally.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+	stats   *cache.LRU[string, Stats]
+}
+
+// NewClient returns a client for the explorer at baseURL. token, when set, is sent as a
+// lichess API bearer token.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 5 * time.Second},
+		stats:   cache.NewLRU[string, Stats](4096, 24*time.Hour),
+	}
+}
+
+// Masters returns the master game statistics for pos.
+func (c *Client) Masters(ctx context.Context, pos *chess.Position) (Stats, error) {
+	// The move clocks don't change which games reached the position.
+	fen := strings.Join(strings.Fields(pos.FEN())[:4], " ")
+	if s, ok := c.stats.Get(fen); ok {
+		return s, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/masters?fen="+url.QueryEscape(fen), nil)
+	if err != nil {
+		return Stats{}, err
+	}
+	req.Header.Set("User-Agent", "nara-chess")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Stats{}, fmt.Errorf("explorer: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Stats{}, fmt.Errorf("explorer: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var s Stats
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return Stats{}, fmt.Errorf("explorer: decoding response: %w", err)
+	}
+	c.stats.Add(fen, s)
+	return s, nil
+}
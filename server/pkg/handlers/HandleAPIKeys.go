@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+// HandleAPIKeys issues an API key on POST and on GET lists the keys issued. It is mounted
+// behind admin auth.
+func (h *Handler) HandleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	if h.Keys == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "API keys are not available")
+		return
+	}
+	if r.Method == http.MethodGet {
+		keys, err := h.Keys.List()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing API keys", "err", err)
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to list API keys")
+			return
+		}
+		keysResponse := types.APIKeysResponse{Keys: make([]types.APIKey, 0, len(keys))}
+		for _, k := range keys {
+			keysResponse.Keys = append(keysResponse.Keys, apiKeyView(k))
+		}
+		writeJSON(w, keysResponse)
+		return
+	}
+
+	keyRequest, ok := decodeAndValidate[types.NewAPIKeyRequest](w, r)
+	if !ok {
+		return
+	}
+	scopes := slices.Compact(slices.Sorted(slices.Values(keyRequest.Scopes)))
+	key, k, err := h.Keys.Create(keyRequest.Name, scopes)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating API key", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to create API key")
+		return
+	}
+	slog.InfoContext(r.Context(), "Issued API key", "key_id", k.ID, "name", k.Name, "scopes", k.Scopes)
+
+	writeJSON(w, types.NewAPIKeyResponse{Key: key, APIKey: apiKeyView(k)})
+}
+
+// HandleRevokeAPIKey deletes an API key, which stops working at once. It is mounted behind
+// admin auth.
+func (h *Handler) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	if h.Keys == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "API keys are not available")
+		return
+	}
+	err := h.Keys.Revoke(r.PathValue("id"))
+	if errors.Is(err, store.ErrAPIKeyNotFound) {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking API key", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to revoke API key")
+		return
+	}
+	slog.InfoContext(r.Context(), "Revoked API key", "key_id", r.PathValue("id"))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func apiKeyView(k *store.APIKey) types.APIKey {
+	scopes := k.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return types.APIKey{ID: k.ID, Name: k.Name, Prefix: k.Prefix, Scopes: scopes, CreatedAt: k.CreatedAt}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/account"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// HandleLogin sends the browser to the sign-in page of the provider named in the path.
+func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	if h.Accounts == nil || h.Users == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Sign-in is not available")
+		return
+	}
+	u, err := h.Accounts.BeginLogin(w, r.PathValue("provider"))
+	if errors.Is(err, account.ErrUnknownProvider) {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Unknown sign-in provider")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting sign-in", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to start sign-in")
+		return
+	}
+	http.Redirect(w, r, u, http.StatusFound)
+}
+
+// HandleLoginCallback finishes a sign-in when the provider sends the browser back: it
+// records the user, starts their session and sends them on to the client.
+func (h *Handler) HandleLoginCallback(w http.ResponseWriter, r *http.Request) {
+	if h.Accounts == nil || h.Users == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Sign-in is not available")
+		return
+	}
+	provider := r.PathValue("provider")
+	id, err := h.Accounts.FinishLogin(w, r, provider)
+	switch {
+	case errors.Is(err, account.ErrUnknownProvider):
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Unknown sign-in provider")
+		return
+	case errors.Is(err, account.ErrLoginDenied):
+		apierror.Write(w, http.StatusForbidden, apierror.Forbidden, "Sign-in was denied")
+		return
+	case errors.Is(err, account.ErrLoginState):
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Sign-in expired or was not started here; please sign in again")
+		return
+	case err != nil:
+		slog.WarnContext(r.Context(), "Error finishing sign-in", "err", err)
+		apierror.Write(w, http.StatusBadGateway, apierror.UpstreamError, "Failed to sign in with "+provider)
+		return
+	}
+
+	user, err := h.Users.LoginUser(store.User{Provider: provider, Subject: id.Subject, Name: id.Name, Email: id.Email})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error recording sign-in", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to sign in")
+		return
+	}
+	if err := h.Accounts.StartSession(w, user.ID); err != nil {
+		slog.ErrorContext(r.Context(), "Error starting session", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to sign in")
+		return
+	}
+	http.Redirect(w, r, h.Config.LoginRedirect, http.StatusFound)
+}
+
+// HandleLogout signs the browser out.
+func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if h.Accounts != nil {
+		h.Accounts.EndSession(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleMe serves the signed-in user, whose user_id the client uses for their games,
+// profile, puzzles and repertoires.
+func (h *Handler) HandleMe(w http.ResponseWriter, r *http.Request) {
+	userID := account.UserID(r.Context())
+	if userID == "" || h.Users == nil {
+		apierror.Write(w, http.StatusUnauthorized, apierror.Unauthorized, "Not signed in")
+		return
+	}
+	user, err := h.Users.GetUser(userID)
+	if errors.Is(err, store.ErrUserNotFound) {
+		apierror.Write(w, http.StatusUnauthorized, apierror.Unauthorized, "Not signed in")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user", "user_id", userID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load user")
+		return
+	}
+	writeJSON(w, user)
+}
+
+// pupilID resolves the user_id a request gives for the pupil it concerns. A signed-in
+// user is always their own pupil: an empty given stands for them, and naming anyone else
+// is forbidden. Anonymous requests may name any pupil except a user with an account.
+func pupilID(r *http.Request, given string) (string, error) {
+	if userID := account.UserID(r.Context()); userID != "" {
+		if given != "" && given != userID {
+			return "", errorf(http.StatusForbidden, "user_id %s is not the signed-in user", given)
+		}
+		return userID, nil
+	}
+	if store.IsUserID(given) {
+		return "", errorf(http.StatusUnauthorized, "Sign in to use user_id %s", given)
+	}
+	return given, nil
+}
+
+// canSee reports whether the request may see data belonging to owner, a user_id: data
+// without an owner is open to everyone.
+func canSee(r *http.Request, owner string) error {
+	if owner == "" {
+		return nil
+	}
+	_, err := pupilID(r, owner)
+	return err
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/pgn"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+)
+
+// jobCallbackTimeout bounds the delivery of a job's callback, retries included.
+const jobCallbackTimeout = time.Minute
+
+// HandleNewAnalysisJob queues a full-game analysis for the analysis workers and answers at
+// once with the job, whose progress GET /analysis/jobs/{id} reports. A job with a
+// callback URL has its report posted there, signed by Handler.Webhooks, when it ends.
+func (h *Handler) HandleNewAnalysisJob(w http.ResponseWriter, r *http.Request) {
+	jobRequest, ok := decodeGameRequest[types.AnalysisJobRequest](h, w, r)
+	if !ok {
+		return
+	}
+	if h.Jobs == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Analysis jobs are not available")
+		return
+	}
+	if jobRequest.CallbackURL != "" && h.Webhooks == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Callbacks are not available")
+		return
+	}
+
+	initialFen, history := jobRequest.InitialFen, jobRequest.MoveHistory
+	if jobRequest.Pgn != "" {
+		games, err := pgn.Parse(jobRequest.Pgn)
+		if err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, fmt.Sprintf("Invalid PGN: %v", err))
+			return
+		}
+		if len(games) != 1 {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, "PGN must contain exactly one game")
+			return
+		}
+		imported := importGame(games[0])
+		if imported.Reason != "" {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, fmt.Sprintf("Invalid PGN: ply %d (%s): %s", imported.IllegalPly, imported.IllegalMove, imported.Reason))
+			return
+		}
+		if len(imported.MoveHistory) == 0 {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, "PGN game has no moves")
+			return
+		}
+		if len(imported.MoveHistory) > types.MaxJobPlies {
+			apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, fmt.Sprintf("Analysis jobs cover games of at most %d plies", types.MaxJobPlies))
+			return
+		}
+		initialFen, history = imported.InitialFen, imported.MoveHistory
+	}
+	if initialFen == "" {
+		initialFen = chess.StartFEN
+	}
+	variant := ""
+	if jobRequest.GameID != "" {
+		game, err := visibleGame(r, h.Games, jobRequest.GameID)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		variant = game.Variant
+	}
+	start, err := parseGameFEN(initialFen, variant)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+	if _, err := chess.Replay(start, history); err != nil {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.IllegalMove, "move_history", err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	job := &store.AnalysisJob{
+		Status:      store.JobQueued,
+		GameID:      jobRequest.GameID,
+		InitialFen:  start.FEN(),
+		MoveHistory: history,
+		Depth:       jobRequest.Depth,
+		Moves:       []analysis.MoveAnalysis{},
+		CallbackURL: jobRequest.CallbackURL,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if job.CallbackURL != "" {
+		job.CallbackStatus = store.CallbackPending
+	}
+	if err := h.Jobs.AddJob(job); err != nil {
+		slog.ErrorContext(r.Context(), "Error storing analysis job", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to queue analysis")
+		return
+	}
+	slog.InfoContext(r.Context(), "Queued analysis job", "job_id", job.ID, "plies", len(job.MoveHistory), "depth", job.Depth)
+	h.wakeAnalysisWorker()
+
+	writeJSON(w, jobView(job))
+}
+
+// HandleGetAnalysisJob reports an analysis job's progress and the moves scored so far.
+func (h *Handler) HandleGetAnalysisJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	if h.Jobs == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Analysis jobs are not available")
+		return
+	}
+
+	job, err := h.Jobs.GetJob(r.PathValue("id"))
+	if err == nil && job.GameID != "" {
+		// A job is only as visible as the game it analyses.
+		if game, gameErr := h.Games.Get(job.GameID); gameErr == nil && canSee(r, game.UserID) != nil {
+			err = store.ErrJobNotFound
+		}
+	}
+	if errors.Is(err, store.ErrJobNotFound) {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Analysis job not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading analysis job", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load analysis job")
+		return
+	}
+
+	writeJSON(w, jobView(job))
+}
+
+// StartAnalysisWorkers starts n workers that run the queued analysis jobs, one move at a
+// time so their progress can be polled. Jobs a previous process left running are queued
+// again first; they resume from the first move they had not scored.
+func (h *Handler) StartAnalysisWorkers(n int) {
+	if h.Jobs == nil || n < 1 {
+		return
+	}
+	requeued, err := h.Jobs.RequeueJobs()
+	if err != nil {
+		slog.Error("Error requeueing analysis jobs", "err", err)
+	} else if requeued > 0 {
+		slog.Info("Requeued interrupted analysis jobs", "count", requeued)
+	}
+	for range n {
+		h.goBackground(h.analysisWorker)
+	}
+}
+
+// wakeAnalysisWorker tells an idle worker that a job is waiting. Busy workers look for
+// another job as soon as they finish theirs, so the wake-up may be dropped.
+func (h *Handler) wakeAnalysisWorker() {
+	select {
+	case h.jobWake <- struct{}{}:
+	default:
+	}
+}
+
+// analysisWorker runs queued jobs until Shutdown, waiting for a wake-up, or a minute at
+// most, whenever the queue is empty.
+func (h *Handler) analysisWorker() {
+	for h.stopping.Err() == nil {
+		job, err := h.Jobs.ClaimJob()
+		if err != nil {
+			if !errors.Is(err, store.ErrJobNotFound) {
+				slog.Error("Error claiming analysis job", "err", err)
+			}
+			select {
+			case <-h.jobWake:
+			case <-h.stopping.Done():
+			case <-time.After(time.Minute):
+			}
+			continue
+		}
+		if h.runAnalysisJob(job) && job.CallbackURL != "" {
+			h.goBackground(func() { h.sendJobCallback(job.ID) })
+		}
+	}
+}
+
+// runAnalysisJob scores job's remaining moves, storing each as it is scored, and reports
+// whether the job ended. On Shutdown it stops between moves, leaving the job running for
+// RequeueJobs to hand to the next process.
+func (h *Handler) runAnalysisJob(job *store.AnalysisJob) bool {
+	fail := func(err error) {
+		slog.Warn("Analysis job failed", "job_id", job.ID, "err", err)
+		if _, err := h.Jobs.UpdateJob(job.ID, func(j *store.AnalysisJob) error {
+			j.Status, j.Error = store.JobFailed, err.Error()
+			return nil
+		}); err != nil {
+			slog.Error("Error saving analysis job", "job_id", job.ID, "err", err)
+		}
+	}
+
+	// A job made from a stored game is played under the game's variant.
+	variant := ""
+	if job.GameID != "" {
+		game, err := h.Games.Get(job.GameID)
+		if err != nil {
+			fail(err)
+			return true
+		}
+		variant = game.Variant
+	}
+	start, err := parseGameFEN(job.InitialFen, variant)
+	if err != nil {
+		fail(err)
+		return true
+	}
+	err = analysis.AnalyzeMoves(start, job.MoveHistory, job.Depth, len(job.Moves), func(ma analysis.MoveAnalysis) error {
+		if _, err := h.Jobs.UpdateJob(job.ID, func(j *store.AnalysisJob) error {
+			j.Moves = append(j.Moves, ma)
+			return nil
+		}); err != nil {
+			return err
+		}
+		return h.stopping.Err()
+	})
+	if errors.Is(err, context.Canceled) && h.stopping.Err() != nil {
+		slog.Info("Interrupted analysis job for shutdown", "job_id", job.ID)
+		return false
+	}
+	if err != nil {
+		fail(err)
+		return true
+	}
+	if _, err := h.Jobs.UpdateJob(job.ID, func(j *store.AnalysisJob) error {
+		j.Status = store.JobDone
+		return nil
+	}); err != nil {
+		slog.Error("Error saving analysis job", "job_id", job.ID, "err", err)
+		return false
+	}
+	slog.Info("Finished analysis job", "job_id", job.ID, "plies", len(job.MoveHistory))
+	return true
+}
+
+// sendJobCallback posts the finished job's report to its callback URL and records how the
+// delivery went.
+func (h *Handler) sendJobCallback(id string) {
+	job, err := h.Jobs.GetJob(id)
+	if err != nil {
+		slog.Error("Error loading analysis job for its callback", "job_id", id, "err", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), jobCallbackTimeout)
+	defer cancel()
+	status := store.CallbackDelivered
+	if h.Webhooks == nil {
+		slog.Warn("Not delivering callback of analysis job: callbacks are disabled", "job_id", id)
+		status = store.CallbackFailed
+	} else if err := h.Webhooks.Post(ctx, job.CallbackURL, "analysis_job."+job.Status, jobView(job)); err != nil {
+		slog.Warn("Error delivering callback of analysis job", "job_id", id, "err", err)
+		status = store.CallbackFailed
+	}
+	if _, err := h.Jobs.UpdateJob(id, func(j *store.AnalysisJob) error {
+		j.CallbackStatus = status
+		return nil
+	}); err != nil {
+		slog.Error("Error saving analysis job", "job_id", id, "err", err)
+	}
+}
+
+func jobView(job *store.AnalysisJob) types.AnalysisJob {
+	view := types.AnalysisJob{
+		ID:             job.ID,
+		Status:         job.Status,
+		GameID:         job.GameID,
+		Depth:          job.Depth,
+		Plies:          len(job.MoveHistory),
+		Analyzed:       len(job.Moves),
+		Moves:          job.Moves,
+		Error:          job.Error,
+		CallbackStatus: job.CallbackStatus,
+		CreatedAt:      job.CreatedAt,
+		UpdatedAt:      job.UpdatedAt,
+	}
+	if view.Plies > 0 {
+		view.Progress = math.Round(float64(view.Analyzed)*1000/float64(view.Plies)) / 10
+	}
+	return view
+}
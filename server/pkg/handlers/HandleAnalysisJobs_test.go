@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+	"testing"
+)
+
+func TestAnalysisJobVariantGame(t *testing.T) {
+	h, _ := newTestHandler()
+	// Kd4 wins a King of the Hill game on the spot; the job must not score it as a
+	// standard game a queen down.
+	game := storeHillGame(t, h, "Kd4")
+
+	w := serve(h.HandleNewAnalysisJob, http.MethodPost, "/api/analysis/jobs", `{"game_id": "`+game.ID+`", "depth": 2}`)
+	queued := decodeResponse[types.AnalysisJob](t, w, http.StatusOK)
+
+	job, err := h.Jobs.ClaimJob()
+	if err != nil || job.ID != queued.ID {
+		t.Fatalf("ClaimJob = %v, %v; want job %s", job, err, queued.ID)
+	}
+	if !h.runAnalysisJob(job) {
+		t.Fatal("job did not end")
+	}
+	done, err := h.Jobs.GetJob(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.Status != store.JobDone || len(done.Moves) != 1 {
+		t.Fatalf("job ended %s (%q) with %d moves", done.Status, done.Error, len(done.Moves))
+	}
+	if ma := done.Moves[0]; ma.BestMove != "Kd4" || ma.EvalAfter <= 0 {
+		t.Errorf("Kd4 scored %+v, want it best and winning", ma)
+	}
+}
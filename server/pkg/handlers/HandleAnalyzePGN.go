@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/pgn"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+var pgnReviewResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "Coaching annotations for every move of a game.",
+	Properties: map[string]*genai.Schema{
+		"summary": {
+			Type:        genai.TypeString,
+			Description: "A short (2-4 sentence) overview of how the game went and its turning points.",
+		},
+		"annotations": {
+			Type:        genai.TypeArray,
+			Description: "One annotation per ply, in order.",
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"ply":     {Type: genai.TypeInteger, Description: "The ply number exactly as given in the move list."},
+					"comment": {Type: genai.TypeString, Description: "A one-sentence coaching note on the move."},
+				},
+				Required: []string{"ply", "comment"},
+			},
+		},
+	},
+	Required: []string{"summary", "annotations"},
+}
+
+type pgnReview struct {
+	Summary     string `json:"summary"`
+	Annotations []struct {
+		Ply     int    `json:"ply"`
+		Comment string `json:"comment"`
+	} `json:"annotations"`
+}
+
+// HandleAnalyzePGN reviews a game played elsewhere: it parses the PGN, scores every move
+// with the local engine and has the model annotate each one.
+func (h *Handler) HandleAnalyzePGN(w http.ResponseWriter, r *http.Request) {
+	analyzeRequest, ok := decodeAndValidate[types.AnalyzePGNRequest](w, r)
+	if !ok {
+		return
+	}
+
+	games, err := pgn.Parse(analyzeRequest.Pgn)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, fmt.Sprintf("Invalid PGN: %v", err))
+		return
+	}
+	if len(games) != 1 {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, "PGN must contain exactly one game")
+		return
+	}
+	game := games[0]
+
+	imported := importGame(game)
+	if imported.Reason != "" {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, fmt.Sprintf("Invalid PGN: ply %d (%s): %s", imported.IllegalPly, imported.IllegalMove, imported.Reason))
+		return
+	}
+	if len(imported.MoveHistory) == 0 {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, "PGN game has no moves")
+		return
+	}
+	if len(imported.MoveHistory) > types.MaxReviewPlies {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, fmt.Sprintf("PGN game may have at most %d plies", types.MaxReviewPlies))
+		return
+	}
+
+	start, _ := chess.ParseFEN(imported.InitialFen)
+	moves, err := analysis.AnalyzeGame(start, imported.MoveHistory, analysis.DefaultDepth)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error analyzing PGN game", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to analyze game")
+		return
+	}
+
+	ctx, cancel := h.requestContext(r, "analyzePgn")
+	defer cancel()
+
+	promptText := fmt.Sprintf(`You are a patient chess coach reviewing a game your pupil played elsewhere.
+
+Below is every move of the game with a chess engine's verdict. Centipawn loss measures how much worse the move was than the engine's best move; classification summarizes it. Motifs flag error patterns (hanging_piece, missed_mate, allowed_mate, missed_tactic). Comments in quotes were written in the original PGN.
+
+Write one short, concrete coaching note per ply, in order, using the ply numbers given. Explain the ideas behind good moves and what was missed on inaccuracies, mistakes and blunders, naming the better move when the engine gives one. Then summarize the game's turning points.
+
+Refer to the players as White and Black. Use clear, casual language.
+
+### Game
+%s
+### Moves
+%s
+Respond ONLY with a JSON object matching the schema.`, formatTags(game.Tags), formatReviewMoves(moves, game.Comments))
+
+	slog.InfoContext(r.Context(), "Asking the model to review a PGN game", "plies", len(moves))
+	jsonString, ok := h.generate(ctx, w, h.modelRequest("analyzePgn", promptText, pgnReviewResponseSchema))
+	if !ok {
+		return
+	}
+
+	var review pgnReview
+	if err := json.Unmarshal([]byte(jsonString), &review); err != nil {
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse game review")
+		return
+	}
+	comments := make(map[int]string, len(review.Annotations))
+	for _, a := range review.Annotations {
+		comments[a.Ply] = a.Comment
+	}
+
+	analyzeResponse := types.AnalyzePGNResponse{
+		Tags:       game.Tags,
+		InitialFen: imported.InitialFen,
+		Result:     game.Result,
+		Summary:    review.Summary,
+		Moves:      make([]types.ReviewedMove, 0, len(moves)),
+	}
+	for i, m := range moves {
+		analyzeResponse.Moves = append(analyzeResponse.Moves, types.ReviewedMove{
+			Ply:            m.Ply,
+			San:            m.San,
+			Side:           m.Side,
+			Fen:            imported.Fens[i],
+			Classification: m.Classification,
+			BestMove:       m.BestMove,
+			Loss:           m.Loss,
+			Motif:          m.Motif,
+			PgnComment:     game.Comments[m.Ply],
+			Comment:        comments[m.Ply],
+		})
+	}
+	analyzeResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, analyzeResponse)
+}
+
+// formatTags lists the informative PGN tags, one per line.
+func formatTags(tags map[string]string) string {
+	var sb strings.Builder
+	for _, name := range []string{"Event", "White", "Black", "WhiteElo", "BlackElo", "Opening", "ECO", "TimeControl", "Result"} {
+		if v := tags[name]; v != "" && v != "?" {
+			fmt.Fprintf(&sb, "%s: %s\n", name, v)
+		}
+	}
+	return sb.String()
+}
+
+// formatReviewMoves lists each ply with the engine's verdict and any PGN comment.
+func formatReviewMoves(moves []analysis.MoveAnalysis, comments map[int]string) string {
+	var sb strings.Builder
+	if c := comments[0]; c != "" {
+		fmt.Fprintf(&sb, "Before the first move: %q\n", c)
+	}
+	for _, m := range moves {
+		fmt.Fprintf(&sb, "ply %d: %s (%s) %s, centipawn loss %d", m.Ply, m.San, m.Side, m.Classification, m.Loss)
+		if m.Classification != analysis.ClassBest && m.Classification != analysis.ClassBrilliant {
+			fmt.Fprintf(&sb, ", engine preferred %s", m.BestMove)
+		}
+		if m.Motif != "" {
+			fmt.Fprintf(&sb, ", motif %s", m.Motif)
+		}
+		if c := comments[m.Ply]; c != "" {
+			fmt.Fprintf(&sb, ", comment %q", c)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
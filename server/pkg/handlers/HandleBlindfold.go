@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	mathrand "math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// blindfoldCacheSize is how many blindfold exercises wait for an answer at once.
+const blindfoldCacheSize = 1024
+
+// blindfoldAttempts is how many lines a drill plays out looking for one that suits the
+// requested question.
+const blindfoldAttempts = 10
+
+// blindfoldSpread is how far below the engine's best move, in centipawns, a move may be
+// and still be played in a drill's line, so lines vary without turning silly.
+const blindfoldSpread = 50
+
+var blindfoldResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "A spoken description of a sequence of chess moves.",
+	Properties: map[string]*genai.Schema{
+		"narration": {
+			Type:        genai.TypeString,
+			Description: "The moves described in words, in order, one short sentence per move.",
+		},
+	},
+	Required: []string{"narration"},
+}
+
+type blindfoldNarration struct {
+	Narration string `json:"narration"`
+}
+
+// blindfoldExercise is a drill awaiting its answer: the position its moves reach and what
+// the question expects.
+type blindfoldExercise struct {
+	kind  string
+	final *chess.Position
+	// square and piece answer a piece_on or where_is question.
+	square chess.Square
+	piece  chess.Piece
+	// best is the engine's move for a best_move question, in SAN.
+	best string
+}
+
+// blindfoldExerciseJSON is how a blindfoldExercise is kept in a shared cache, so the
+// answer may reach a different server instance than the drill.
+type blindfoldExerciseJSON struct {
+	Kind   string       `json:"kind"`
+	Fen    string       `json:"fen"`
+	Square chess.Square `json:"square"`
+	Piece  chess.Piece  `json:"piece"`
+	Best   string       `json:"best,omitempty"`
+}
+
+func (e blindfoldExercise) MarshalJSON() ([]byte, error) {
+	return json.Marshal(blindfoldExerciseJSON{Kind: e.kind, Fen: e.final.FEN(), Square: e.square, Piece: e.piece, Best: e.best})
+}
+
+func (e *blindfoldExercise) UnmarshalJSON(data []byte) error {
+	var j blindfoldExerciseJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	final, err := chess.ParseFEN(j.Fen)
+	if err != nil {
+		return err
+	}
+	*e = blindfoldExercise{kind: j.Kind, final: final, square: j.Square, piece: j.Piece, best: j.Best}
+	return nil
+}
+
+// HandleBlindfold starts a blindfold visualization drill: the local engine plays a short
+// line from the starting position, the coach describes it in words, and the pupil is asked
+// about the position it reaches without seeing a board. Answers go to
+// HandleBlindfoldAnswer.
+func (h *Handler) HandleBlindfold(w http.ResponseWriter, r *http.Request) {
+	blindfoldRequest, ok := decodeAndValidate[types.BlindfoldRequest](w, r)
+	if !ok {
+		return
+	}
+
+	var moves []string
+	var exercise blindfoldExercise
+	var question string
+	for attempt := 0; attempt < blindfoldAttempts && question == ""; attempt++ {
+		kind := blindfoldRequest.Kind
+		if kind == "" {
+			kind = types.BlindfoldKinds[mathrand.IntN(len(types.BlindfoldKinds))]
+		}
+		var played []chess.Move
+		var final *chess.Position
+		moves, played, final = playBlindfoldLine(blindfoldRequest.Plies)
+		exercise, question = blindfoldQuestion(kind, played, final)
+		// Any line has a square a piece moved to, so a random question falls back to
+		// piece_on rather than fail.
+		if question == "" && blindfoldRequest.Kind == "" && attempt == blindfoldAttempts-1 {
+			exercise, question = blindfoldQuestion(types.BlindfoldPieceOn, played, final)
+		}
+	}
+	if question == "" {
+		apierror.Write(w, http.StatusUnprocessableEntity, apierror.InvalidRequest, fmt.Sprintf("Could not set a %s question; try a different number of plies", blindfoldRequest.Kind))
+		return
+	}
+
+	ctx, cancel := h.requestContext(r, "blindfold")
+	defer cancel()
+
+	var listing strings.Builder
+	turn := chess.White
+	for i, san := range moves {
+		fmt.Fprintf(&listing, "%d. %s: %s\n", i+1, turn, san)
+		turn = turn.Other()
+	}
+	promptText := fmt.Sprintf(`You are a chess coach running a blindfold visualization drill. Your pupil cannot see a board; they must follow the game in their head from your words alone.
+
+Starting from the initial position, these moves were played, one per line in SAN:
+%s
+Describe the moves in order, one short sentence per move, as you would read them aloud to a blindfolded player: say which side moves, name the piece in full and both the square it leaves and the square it goes to, and mention captures, checks and castling explicitly.
+
+Do not describe the resulting position, suggest further moves, evaluate the moves, or use diagrams or FEN. Do not add anything that is not a move.
+
+Respond ONLY with a JSON object matching the schema.`, listing.String())
+
+	jsonString, ok := h.generate(ctx, w, h.modelRequest("blindfold", promptText, blindfoldResponseSchema))
+	if !ok {
+		return
+	}
+	var narration blindfoldNarration
+	if err := json.Unmarshal([]byte(jsonString), &narration); err != nil {
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse narration")
+		return
+	}
+
+	id := store.NewID()
+	h.blindfold.Add(id, exercise)
+
+	writeJSON(w, types.BlindfoldExercise{
+		ExerciseID: id,
+		Kind:       exercise.kind,
+		Moves:      moves,
+		Narration:  narration.Narration,
+		Question:   question,
+		Meta:       responseMeta(ctx),
+	})
+}
+
+// HandleBlindfoldAnswer grades the pupil's answer to a blindfold exercise and reveals the
+// position.
+func (h *Handler) HandleBlindfoldAnswer(w http.ResponseWriter, r *http.Request) {
+	answerRequest, ok := decodeAndValidate[types.BlindfoldAnswerRequest](w, r)
+	if !ok {
+		return
+	}
+
+	exercise, ok := h.blindfold.Get(r.PathValue("id"))
+	if !ok {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Exercise not found or expired")
+		return
+	}
+	answer := strings.TrimSpace(answerRequest.Answer)
+	answerResponse := types.BlindfoldAnswerResponse{Fen: exercise.final.FEN()}
+
+	switch exercise.kind {
+	case types.BlindfoldPieceOn:
+		piece, ok := parsePieceAnswer(answer)
+		if !ok {
+			apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "answer", `Answer must name a piece and its colour, such as "white knight", or "empty"`)
+			return
+		}
+		answerResponse.Correct = piece == exercise.piece
+		answerResponse.Expected = pieceText(exercise.piece)
+		if exercise.piece == chess.NoPiece {
+			answerResponse.Feedback = fmt.Sprintf("%s is empty.", exercise.square)
+		} else {
+			answerResponse.Feedback = fmt.Sprintf("A %s stands on %s.", answerResponse.Expected, exercise.square)
+		}
+	case types.BlindfoldWhereIs:
+		sq, err := chess.ParseSquare(strings.ToLower(answer))
+		if err != nil {
+			apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "answer", `Answer must be a square, such as "f3"`)
+			return
+		}
+		answerResponse.Correct = sq == exercise.square
+		answerResponse.Expected = exercise.square.String()
+		answerResponse.Feedback = fmt.Sprintf("The %s is on %s.", pieceText(exercise.piece), exercise.square)
+	case types.BlindfoldBestMove:
+		answerResponse.Expected = exercise.best
+		m, err := notation.ParseMove(exercise.final, answer)
+		if err != nil {
+			answerResponse.Feedback = fmt.Sprintf("%s is not a legal move in the position. The engine plays %s.", answer, exercise.best)
+			break
+		}
+		ma, err := analysis.AnalyzeMove(exercise.final, exercise.final.SAN(m), analysis.DefaultDepth)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error analyzing blindfold answer", "answer", answer, "err", err)
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to grade answer")
+			return
+		}
+		switch ma.Classification {
+		case analysis.ClassBrilliant, analysis.ClassBest:
+			answerResponse.Correct = true
+			answerResponse.Feedback = fmt.Sprintf("%s is the engine's choice.", ma.San)
+			if ma.San != exercise.best {
+				answerResponse.Feedback = fmt.Sprintf("%s is as strong as the engine's choice, %s.", ma.San, exercise.best)
+			}
+		case analysis.ClassGood:
+			answerResponse.Correct = true
+			answerResponse.Feedback = fmt.Sprintf("%s is a good move, though the engine prefers %s.", ma.San, exercise.best)
+		default:
+			answerResponse.Feedback = fmt.Sprintf("%s is %s %s, giving away about %d centipawns. The engine plays %s.",
+				ma.San, article(ma.Classification), ma.Classification, ma.Loss, exercise.best)
+		}
+	}
+
+	writeJSON(w, answerResponse)
+}
+
+// playBlindfoldLine plays up to plies moves from the starting position, each picked at
+// random among the engine's better moves. The line stops early if the game ends.
+func playBlindfoldLine(plies int) ([]string, []chess.Move, *chess.Position) {
+	pos := chess.NewGame()
+	var sans []string
+	var played []chess.Move
+	for len(played) < plies {
+		top := engine.TopMoves(pos, 1, 3)
+		if len(top) == 0 {
+			break
+		}
+		n := 1
+		for n < len(top) && top[n].Score >= top[0].Score-blindfoldSpread {
+			n++
+		}
+		m := top[mathrand.IntN(n)].Move
+		sans = append(sans, pos.SAN(m))
+		played = append(played, m)
+		pos = pos.Play(m)
+	}
+	return sans, played, pos
+}
+
+// blindfoldQuestion sets a question of kind about final, reached by played. It returns an
+// empty question when final doesn't suit kind.
+func blindfoldQuestion(kind string, played []chess.Move, final *chess.Position) (blindfoldExercise, string) {
+	exercise := blindfoldExercise{kind: kind, final: final}
+	switch kind {
+	case types.BlindfoldPieceOn:
+		var squares []chess.Square
+		for _, m := range played {
+			if !slices.Contains(squares, m.To) {
+				squares = append(squares, m.To)
+			}
+		}
+		if len(squares) == 0 {
+			return exercise, ""
+		}
+		exercise.square = squares[mathrand.IntN(len(squares))]
+		exercise.piece = final.Board[exercise.square]
+		return exercise, fmt.Sprintf(`After these moves, what stands on %s? Answer with the piece and its colour, such as "white knight", or "empty".`, exercise.square)
+	case types.BlindfoldWhereIs:
+		// Ask about a piece that has left its starting square and has no twin on the board,
+		// so the question has one answer.
+		start := chess.NewGame()
+		count := map[chess.Piece]int{}
+		for _, p := range final.Board {
+			count[p]++
+		}
+		var candidates []chess.Square
+		for i, p := range final.Board {
+			if p == chess.NoPiece || p.Type() == chess.Pawn || count[p] != 1 || start.Board[i] == p {
+				continue
+			}
+			candidates = append(candidates, chess.Square(i))
+		}
+		if len(candidates) == 0 {
+			return exercise, ""
+		}
+		exercise.square = candidates[mathrand.IntN(len(candidates))]
+		exercise.piece = final.Board[exercise.square]
+		return exercise, fmt.Sprintf(`After these moves, on which square is %s's %s? Answer with the square, such as "f3".`,
+			exercise.piece.Color(), exercise.piece.Type().Name())
+	case types.BlindfoldBestMove:
+		if len(final.LegalMoves()) == 0 {
+			return exercise, ""
+		}
+		exercise.best = final.SAN(engine.Search(final, analysis.DefaultDepth).Move)
+		return exercise, fmt.Sprintf("After these moves it is %s to move. Without looking at a board, find the best move.", final.Turn)
+	}
+	return exercise, ""
+}
+
+// parsePieceAnswer reads an answer such as "white knight", "Black's queen" or "empty".
+func parsePieceAnswer(answer string) (chess.Piece, bool) {
+	words := strings.FieldsFunc(strings.ToLower(answer), func(r rune) bool {
+		return r < 'a' || r > 'z'
+	})
+	color, piece := -1, chess.NoPieceType
+	for _, word := range words {
+		switch word {
+		case "empty", "nothing", "none":
+			return chess.NoPiece, true
+		case "white":
+			color = int(chess.White)
+		case "black":
+			color = int(chess.Black)
+		}
+		for t := chess.Pawn; t <= chess.King; t++ {
+			if word == t.Name() {
+				piece = t
+			}
+		}
+	}
+	if color < 0 || piece == chess.NoPieceType {
+		return chess.NoPiece, false
+	}
+	return chess.NewPiece(chess.Color(color), piece), true
+}
+
+// pieceText names a piece as "white knight", or "empty" for no piece.
+func pieceText(p chess.Piece) string {
+	if p == chess.NoPiece {
+		return "empty"
+	}
+	return p.Color().String() + " " + p.Type().Name()
+}
+
+// article returns "an" before word if it starts with a vowel, otherwise "a".
+func article(word string) string {
+	if word != "" && strings.ContainsRune("aeiou", rune(word[0])) {
+		return "an"
+	}
+	return "a"
+}
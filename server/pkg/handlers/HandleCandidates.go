@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// candidatesDepth is the search depth each candidate move is scored at.
+const candidatesDepth = 3
+
+// candidateLineLength is how many plies of each candidate's line are shown.
+const candidateLineLength = 4
+
+var candidatesResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "The plan behind each candidate move.",
+	Properties: map[string]*genai.Schema{
+		"plans": {
+			Type:        genai.TypeArray,
+			Description: "One entry per candidate move listed in the prompt, in order.",
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"move": {Type: genai.TypeString, Description: "The candidate move exactly as given."},
+					"plan": {Type: genai.TypeString, Description: "2-3 sentences on the plan behind the move."},
+				},
+				Required: []string{"move", "plan"},
+			},
+		},
+	},
+	Required: []string{"plans"},
+}
+
+type candidatePlans struct {
+	Plans []struct {
+		Move string `json:"move"`
+		Plan string `json:"plan"`
+	} `json:"plans"`
+}
+
+// HandleCandidates compares the engine's strongest moves in a position, with the coach
+// explaining the plan behind each so the pupil can weigh ideas against each other. Results
+// are cached by position.
+func (h *Handler) HandleCandidates(w http.ResponseWriter, r *http.Request) {
+	candidatesRequest, ok := decodeGameRequest[types.CandidatesRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	pos, err := parseGameFEN(candidatesRequest.Fen, candidatesRequest.Variant)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+	if len(pos.LegalMoves()) == 0 {
+		apierror.Write(w, http.StatusBadRequest, apierror.GameOver, "The game is over in this position")
+		return
+	}
+	key := analysisKey(pos)
+	if cached, ok := h.candidates.Get(key); ok {
+		cached.Meta = &types.ResponseMeta{CacheHit: true}
+		writeJSON(w, cached)
+		return
+	}
+
+	candidatesResponse := types.CandidatesResponse{Side: pos.Turn.String(), Candidates: []types.CandidateMove{}}
+	results := engine.TopMoves(pos, candidatesDepth, types.CandidateMoveCount)
+	var listing strings.Builder
+	for i, result := range results {
+		c := types.CandidateMove{
+			Rank:    i + 1,
+			Move:    pos.SAN(result.Move),
+			Uci:     result.Move.String(),
+			Score:   result.Score,
+			Mate:    engine.MateIn(result.Score),
+			Display: engine.FormatScore(result.Score),
+			Line:    sanLine(pos, result.PV, candidateLineLength),
+			Arrows:  [][2]string{{result.Move.From.String(), result.Move.To.String()}},
+		}
+		candidatesResponse.Candidates = append(candidatesResponse.Candidates, c)
+		fmt.Fprintf(&listing, "%d. %s (evaluation %s for %s), expected line: %s\n", c.Rank, c.Move, c.Display, pos.Turn, strings.Join(c.Line, " "))
+	}
+
+	ctx, cancel := h.requestContext(r, "candidates")
+	defer cancel()
+
+	prompt := fmt.Sprintf(`You are a chess coach helping your pupil compare candidate moves. An engine picked the moves below for %s in this position; do not second-guess them.
+
+FEN: %s
+
+%s
+For each candidate, explain in 2-3 sentences the plan behind it: what it aims for, what it gives up and how it differs from the others. Evaluations are in pawns; "#n" is mate in n.
+
+Refer to the pupil as "you". Use clear, casual language.
+
+Respond ONLY with a JSON object matching the schema.`, pos.Turn, pos.FEN(), listing.String())
+
+	jsonString, ok := h.generate(ctx, w, h.modelRequest("candidates", prompt, candidatesResponseSchema))
+	if !ok {
+		return
+	}
+	var plans candidatePlans
+	if err := json.Unmarshal([]byte(jsonString), &plans); err != nil {
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse candidate plans")
+		return
+	}
+	attachCandidatePlans(pos, candidatesResponse.Candidates, plans)
+	h.candidates.Add(key, candidatesResponse)
+	candidatesResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, candidatesResponse)
+}
+
+// attachCandidatePlans gives each candidate the plan the coach wrote for its move. Plans
+// whose move was not echoed legibly go to the remaining candidates in order.
+func attachCandidatePlans(pos *chess.Position, candidates []types.CandidateMove, plans candidatePlans) {
+	var unmatched []string
+	for _, p := range plans.Plans {
+		matched := false
+		if m, err := notation.ParseMove(pos, p.Move); err == nil {
+			for j := range candidates {
+				if candidates[j].Uci == m.String() && candidates[j].Plan == "" {
+					candidates[j].Plan, matched = p.Plan, true
+					break
+				}
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, p.Plan)
+		}
+	}
+	for j := range candidates {
+		if candidates[j].Plan != "" {
+			continue
+		}
+		if len(unmatched) == 0 {
+			slog.Warn("Coach gave no plan for candidate", "move", candidates[j].Move)
+			continue
+		}
+		candidates[j].Plan, unmatched = unmatched[0], unmatched[1:]
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestCandidatesVariant(t *testing.T) {
+	plans := `{"plans": [{"move": "Kd4", "plan": "Step onto the hill."}]}`
+	h, _ := newTestHandler(plans, plans)
+	candidates := func(variant string) types.CandidatesResponse {
+		t.Helper()
+		body, err := json.Marshal(types.CandidatesRequest{Fen: hillFEN, Variant: variant})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := serve(h.HandleCandidates, http.MethodPost, "/api/candidates", string(body))
+		return decodeResponse[types.CandidatesResponse](t, w, http.StatusOK)
+	}
+
+	if resp := candidates(""); len(resp.Candidates) == 0 || resp.Candidates[0].Score >= 0 {
+		t.Errorf("standard candidates = %+v, want White losing", resp.Candidates)
+	}
+
+	resp := candidates("king_of_the_hill")
+	if resp.Meta != nil && resp.Meta.CacheHit {
+		t.Error("King of the Hill candidates were served from the standard ones' cache entry")
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Move != "Kd4" || resp.Candidates[0].Mate <= 0 {
+		t.Fatalf("King of the Hill candidates = %+v, want Kd4 winning first", resp.Candidates)
+	}
+	if resp.Candidates[0].Plan != "Step onto the hill." {
+		t.Errorf("Kd4 plan = %q", resp.Candidates[0].Plan)
+	}
+}
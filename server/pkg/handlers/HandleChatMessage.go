@@ -1,101 +1,114 @@
 package handlers
 
 import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/llm"
+	"arnavsurve/nara-chess/server/pkg/persona"
+	"arnavsurve/nara-chess/server/pkg/store"
 	"arnavsurve/nara-chess/server/pkg/types"
-	"arnavsurve/nara-chess/server/pkg/utils"
-	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"strings"
-	"time"
 
 	"github.com/google/generative-ai-go/genai"
-	"google.golang.org/api/option"
 )
 
-func HandleChatMessage(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+const analyzeForInstruction = `
 
-	var chatMessageRequest types.ChatMessageRequest
+PERSPECTIVE: Evaluate the position and frame your commentary from %s's point of view: their chances, threats against them and plans for them. It is still %s to move.`
 
-	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // Limit body size to 1MB
-
-	decoder := json.NewDecoder(r.Body)
-	decoder.DisallowUnknownFields()
+var chatMessageResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "Response to the user's message.",
+	Properties: map[string]*genai.Schema{
+		"response": {
+			Type:        genai.TypeString,
+			Description: "A brief message (1-3 sentences) replying to the user.",
+		},
+		"arrows": {
+			Type:        genai.TypeArray,
+			Description: "Optional coaching arrows to display. Each is a tuple of two square strings (from, to). Used to illustrate your response, threats, good ideas, plans, etc.",
+			Items: &genai.Schema{
+				Type: genai.TypeArray,
+				Items: &genai.Schema{
+					Type: genai.TypeString,
+				},
+			},
+		},
+		"arrow_groups": llm.ArrowGroupsSchema,
+	},
+	Required: []string{"response"},
+}
 
-	err := decoder.Decode(&chatMessageRequest)
-	if err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+func (h *Handler) HandleChatMessage(w http.ResponseWriter, r *http.Request) {
+	chatMessageRequest, ok := decodeGameRequest[types.ChatMessageRequest](h, w, r)
+	if !ok {
 		return
 	}
 
-	fmt.Println(chatMessageRequest.MessageHistory)
-
-	if chatMessageRequest.GameState.Fen == "" {
-		http.Error(w, "Request must contain the current board state FEN (fen field)", http.StatusBadRequest)
+	fen, sideWarning, err := h.authoritativeFen(chatMessageRequest.GameState.Variant, chatMessageRequest.GameState.InitialFen, chatMessageRequest.GameState.Fen, chatMessageRequest.GameState.MoveHistory)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
+	if sideWarning != "" {
+		slog.WarnContext(r.Context(), sideWarning)
+	}
+	chatMessageRequest.GameState.Fen = fen
+
+	slog.DebugContext(r.Context(), "Chat message history", "history", chatMessageRequest.MessageHistory)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second) // 60 second timeout
+	ctx, cancel := h.requestContext(r, "chat")
 	defer cancel()
 
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		log.Println("ERROR: GEMINI_API_KEY environment variable not set.")
-		http.Error(w, "Server configuration error", http.StatusInternalServerError)
+	profile := h.pupilProfile(chatMessageRequest.GameState)
+	summary := h.fitChatHistory(ctx, &chatMessageRequest, profile)
+	promptText := chatPrompt(chatMessageRequest, summary, profile)
+
+	slog.InfoContext(r.Context(), "Asking the model for a chat reply")
+	jsonString, ok := h.generate(ctx, w, h.modelRequest("chat", promptText, chatMessageResponseSchema))
+	if !ok {
 		return
 	}
 
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	var chatMessageResponse types.ChatMessageResponse
+	err = json.Unmarshal([]byte(jsonString), &chatMessageResponse)
 	if err != nil {
-		log.Printf("Error creating Gemini client: %v", err)
-		http.Error(w, "Failed to initialize analysis service", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse move suggestion")
 		return
 	}
-	defer client.Close()
 
-	model := client.GenerativeModel("gemini-2.5-pro-exp-03-25")
-
-	chatMessageResponseSchema := &genai.Schema{
-		Type:        genai.TypeObject,
-		Description: "Response to the user's message.",
-		Properties: map[string]*genai.Schema{
-			"response": {
-				Type:        genai.TypeString,
-				Description: "A brief message (1-3 sentences) replying to the user.",
-			},
-			"arrows": {
-				Type:        genai.TypeArray,
-				Description: "Optional coaching arrows to display. Each is a tuple of two square strings (from, to). Used to illustrate your response, threats, good ideas, plans, etc.",
-				Items: &genai.Schema{
-					Type: genai.TypeArray,
-					Items: &genai.Schema{
-						Type: genai.TypeString,
-					},
-				},
-			},
-		},
-		Required: []string{"response"},
+	if chatMessageResponse.Response == "" {
+		slog.WarnContext(r.Context(), "The model returned JSON but the 'response' field was empty")
+		slog.DebugContext(r.Context(), "Model response without a reply", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Analysis service failed to provide a response")
+		return
 	}
 
-	model.GenerationConfig = genai.GenerationConfig{
-		ResponseMIMEType: "application/json",
-		ResponseSchema:   chatMessageResponseSchema,
-		Temperature:      utils.PtrFloat32(0.4),
-	}
+	board, _ := parseGameFEN(chatMessageRequest.GameState.Fen, chatMessageRequest.GameState.Variant)
+	chatMessageResponse.Arrows = llm.SanitizeArrows(chatMessageResponse.Arrows, chatMessageResponse.ArrowGroups, board)
+
+	chatMessageResponse.Meta = responseMeta(ctx)
 
-	moveHistoryStr := strings.Join(chatMessageRequest.GameState.MoveHistory, " ")
+	writeJSON(w, chatMessageResponse)
+
+	slog.DebugContext(r.Context(), "Chat reply", "response", chatMessageResponse.Response)
+}
+
+// chatPrompt builds the coach's chat prompt for req, shared by /chat and /chat/stream.
+// summary stands for the older messages left out of req's history, if any, and profile is
+// what the coach knows about the pupil, if anything.
+func chatPrompt(req types.ChatMessageRequest, summary string, profile *store.Profile) string {
+	moveHistoryStr := strings.Join(req.GameState.MoveHistory, " ")
 
 	var pupilSide string
 	var llmSide string
-	if chatMessageRequest.PlayerSide == "white" {
+	if req.PlayerSide == "white" {
 		pupilSide = "white"
 		llmSide = "black"
 	} else {
@@ -103,7 +116,10 @@ func HandleChatMessage(w http.ResponseWriter, r *http.Request) {
 		llmSide = "white"
 	}
 
-	promptText := fmt.Sprintf(`You are a powerful chess coach and engine engaged in an ongoing conversation with your pupil. You are analyzing their game and helping them improve their play, move by move.
+	chatHistory := formatChatSummary(summary) + formatChatHistory(req.MessageHistory)
+
+	coach, _ := persona.Lookup(req.GameState.Persona)
+	promptText := fmt.Sprintf(`You are %s engaged in an ongoing conversation with your pupil. You are analyzing their game and helping them improve their play, move by move.
 
 You are playing as %s.
 Your pupil is playing as %s.
@@ -120,7 +136,7 @@ You are given:
 2. **Optionally** include a list of up to 3 arrows that help the pupil visualize ideas like threats, tactics, or plans. If you mention any moves in your response relating to any deep analysis, you may include arrows to illustrate these moves.
 
 ### Requirements for your response:
-- Speak in a friendly, direct tone.
+- %s
 - Stay in character as a helpful coach who explains ideas clearly.
 - Use plain English with concrete reasoning and chess terminology.
 - Reference positional features (e.g., weak squares, pawn structure, activity, king safety) and classical ideas when relevant.
@@ -139,60 +155,19 @@ Respond ONLY with a JSON object in the following format:
 {
   "response": "...",  // Your chat response and coaching commentary (1–3 sentences or more, continuing the conversation)
   "arrows": [["e4", "e5"], ["g1", "f3"]]  // 0–3 arrows to illustrate your response
-}`, llmSide, pupilSide, chatMessageRequest.GameState.Fen, moveHistoryStr, formatChatHistory(chatMessageRequest.MessageHistory))
-	fmt.Println(promptText)
-	prompt := genai.Text(promptText)
-
-	log.Printf("Sending request to Gemini for move suggestion. FEN: %s", chatMessageRequest.GameState.Fen)
-	resp, err := model.GenerateContent(ctx, prompt)
-	if err != nil {
-		log.Printf("Error generating content from Gemini: %v", err)
-		if errors.Is(err, context.DeadlineExceeded) {
-			http.Error(w, "Analysis request timed out", http.StatusGatewayTimeout)
-		} else {
-			http.Error(w, "Failed to get move suggestion from service", http.StatusInternalServerError)
+}`, coach.RoleOr("a powerful chess coach and engine"), llmSide, pupilSide, coach.ToneOr("Speak in a friendly, direct tone."),
+		req.GameState.Fen, moveHistoryStr, chatHistory)
+	if req.AnalyzeFor != "" {
+		turn := chess.White
+		if pos, err := parseGameFEN(req.GameState.Fen, req.GameState.Variant); err == nil {
+			turn = pos.Turn
 		}
-		return
+		perspective := analysisPerspective(req.AnalyzeFor, turn, chess.White)
+		promptText += fmt.Sprintf(analyzeForInstruction, perspective, turn)
 	}
-
-	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
-		log.Printf("Error: Received empty or invalid response structure from Gemini. Response: %+v", resp)
-		http.Error(w, "Received empty analysis response", http.StatusInternalServerError)
-		return
-	}
-
-	jsonPart := resp.Candidates[0].Content.Parts[0]
-	jsonString, ok := jsonPart.(genai.Text)
-	if !ok {
-		log.Printf("Error: Expected response part to be genai.Text, but got %T. Content: %+v", jsonPart, jsonPart)
-		http.Error(w, "Received unexpected analysis format from service", http.StatusInternalServerError)
-		return
-	}
-
-	log.Printf("Raw JSON received from Gemini: %s", jsonString)
-
-	var chatMessageResponse types.ChatMessageResponse
-	err = json.Unmarshal([]byte(jsonString), &chatMessageResponse)
-	if err != nil {
-		log.Printf("Error unmarshalling Gemini JSON response: %v\nRaw JSON was: %s", err, jsonString)
-		http.Error(w, "Failed to parse move suggestion", http.StatusInternalServerError)
-		return
-	}
-
-	if chatMessageResponse.Response == "" {
-		log.Printf("Warning: Gemini returned JSON but the 'response' field was empty. Raw: %s", jsonString)
-		http.Error(w, "Analysis service failed to provide a response", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(chatMessageResponse)
-	if err != nil {
-		log.Printf("Error encoding JSON response for client: %v", err)
-	}
-
-	log.Printf("Successfully processed request. Response: %s", chatMessageResponse.Response)
+	promptText += llm.ProfileInstruction(profile)
+	slog.Debug("Chat prompt", "prompt", promptText)
+	return promptText + llm.ArrowGroupsInstruction
 }
 
 func formatChatHistory(messages []types.ChatMessage) string {
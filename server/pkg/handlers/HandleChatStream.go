@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/llm"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// HandleChatStream is /chat delivered as Server-Sent Events. "token" events carry the
+// coach's reply as the model writes it; a final "done" event carries the complete
+// ChatMessageResponse with its arrows, or an "error" event carries a ChatStreamError.
+func (h *Handler) HandleChatStream(w http.ResponseWriter, r *http.Request) {
+	chatMessageRequest, ok := decodeGameRequest[types.ChatMessageRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	fen, sideWarning, err := h.authoritativeFen(chatMessageRequest.GameState.Variant, chatMessageRequest.GameState.InitialFen, chatMessageRequest.GameState.Fen, chatMessageRequest.GameState.MoveHistory)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if sideWarning != "" {
+		slog.WarnContext(r.Context(), sideWarning)
+	}
+	chatMessageRequest.GameState.Fen = fen
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Streaming unsupported")
+		return
+	}
+
+	ctx, cancel := h.requestContext(r, "chat")
+	defer cancel()
+	// Stop generating once the client goes away.
+	stop := context.AfterFunc(r.Context(), cancel)
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(event string, payload any) error {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	sendError := func(status int, message string) {
+		send("error", types.ChatStreamError{Error: message, Status: status})
+	}
+
+	profile := h.pupilProfile(chatMessageRequest.GameState)
+	summary := h.fitChatHistory(ctx, &chatMessageRequest, profile)
+	promptText := chatPrompt(chatMessageRequest, summary, profile)
+
+	slog.InfoContext(r.Context(), "Streaming chat response from the model")
+	reply := llm.NewFieldStream("response")
+	jsonString, err := h.streamModel(ctx, h.modelRequest("chat", promptText, chatMessageResponseSchema), func(chunk string) error {
+		if text := reply.Feed(chunk); text != "" {
+			return send("token", types.ChatStreamToken{Text: text})
+		}
+		return nil
+	})
+	if err != nil {
+		var he *httpError
+		if errors.As(err, &he) {
+			sendError(he.status, he.message)
+		} else {
+			sendError(http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	var chatMessageResponse types.ChatMessageResponse
+	if err := json.Unmarshal([]byte(jsonString), &chatMessageResponse); err != nil {
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		sendError(http.StatusInternalServerError, "Failed to parse move suggestion")
+		return
+	}
+	if chatMessageResponse.Response == "" {
+		slog.WarnContext(r.Context(), "The model returned JSON but the 'response' field was empty")
+		slog.DebugContext(r.Context(), "Model response without a reply", "response", jsonString)
+		sendError(http.StatusInternalServerError, "Analysis service failed to provide a response")
+		return
+	}
+
+	board, _ := parseGameFEN(chatMessageRequest.GameState.Fen, chatMessageRequest.GameState.Variant)
+	chatMessageResponse.Arrows = llm.SanitizeArrows(chatMessageResponse.Arrows, chatMessageResponse.ArrowGroups, board)
+	chatMessageResponse.Meta = responseMeta(ctx)
+	send("done", chatMessageResponse)
+
+	slog.DebugContext(r.Context(), "Streamed chat response", "response", chatMessageResponse.Response)
+}
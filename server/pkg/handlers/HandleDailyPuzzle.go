@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/puzzledb"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// puzzleImportBatch is how many puzzles an import stores per write.
+const puzzleImportBatch = 1000
+
+// dailyPuzzleTTL keeps a day's puzzle cached a little past the end of its day wherever it
+// was first served.
+const dailyPuzzleTTL = 48 * time.Hour
+
+var dailyHintsResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "Hints for a chess puzzle.",
+	Properties: map[string]*genai.Schema{
+		"hints": {
+			Type:        genai.TypeArray,
+			Description: "Exactly three hints, from least to most specific.",
+			Items:       &genai.Schema{Type: genai.TypeString},
+		},
+	},
+	Required: []string{"hints"},
+}
+
+type dailyHints struct {
+	Hints []string `json:"hints"`
+}
+
+// HandleDailyPuzzle serves the same library puzzle to everyone for a UTC day, with hints
+// written by the model once per day.
+func (h *Handler) HandleDailyPuzzle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	if h.PuzzleLibrary == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Puzzles are not available")
+		return
+	}
+
+	date := time.Now().UTC().Format(time.DateOnly)
+	if cached, ok := h.dailyPuzzles.Get(date); ok {
+		cached.Meta = &types.ResponseMeta{CacheHit: true}
+		writeJSON(w, cached)
+		return
+	}
+
+	count, err := h.PuzzleLibrary.CountLibraryPuzzles()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error counting library puzzles", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load puzzle")
+		return
+	}
+	if count == 0 {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "No puzzles have been imported")
+		return
+	}
+	p, err := h.PuzzleLibrary.LibraryPuzzleAt(dailyIndex(date, count))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading daily puzzle", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load puzzle")
+		return
+	}
+	line, err := puzzledb.Replay(*p)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error replaying puzzle", "puzzle_id", p.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load puzzle")
+		return
+	}
+
+	dailyResponse := types.DailyPuzzleResponse{
+		Date:     date,
+		PuzzleID: p.ID,
+		Fen:      line.Position.FEN(),
+		Side:     line.Position.Turn.String(),
+		LastMove: line.Setup,
+		Solution: line.Solution,
+		Rating:   p.Rating,
+		Themes:   p.Themes,
+		GameURL:  p.GameURL,
+	}
+
+	ctx, cancel := h.requestContext(r, "dailyPuzzle")
+	defer cancel()
+
+	promptText := fmt.Sprintf(`You are a patient chess coach giving hints for a puzzle.
+
+Position (FEN): %s
+%s has just played %s. Now it is %s's turn.
+The solution is: %s
+Puzzle themes (Lichess tags): %s
+
+Write exactly three hints, from least to most specific:
+1. A gentle nudge about what to look for (e.g. a weakness or a tactical theme), without naming any piece or square of the solution.
+2. Point to the area of the board or the piece that matters, without giving the move.
+3. Nearly the answer: describe the first move's idea clearly, but do not write the move itself.
+
+Never write the solution's moves in SAN. Keep each hint to one sentence in clear, casual language.
+
+Respond ONLY with a JSON object matching the schema.`, dailyResponse.Fen, line.Position.Turn.Other(), line.Setup, dailyResponse.Side,
+		strings.Join(line.Solution, " "), strings.Join(p.Themes, ", "))
+
+	jsonString, ok := h.generate(ctx, w, h.modelRequest("dailyPuzzle", promptText, dailyHintsResponseSchema))
+	if !ok {
+		return
+	}
+	var hints dailyHints
+	if err := json.Unmarshal([]byte(jsonString), &hints); err != nil {
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse puzzle hints")
+		return
+	}
+	if len(hints.Hints) > types.DailyPuzzleHints {
+		hints.Hints = hints.Hints[:types.DailyPuzzleHints]
+	}
+	dailyResponse.Hints = hints.Hints
+	if dailyResponse.Hints == nil {
+		dailyResponse.Hints = []string{}
+	}
+	h.dailyPuzzles.Add(date, dailyResponse)
+	dailyResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, dailyResponse)
+}
+
+// dailyIndex picks the library position of date's puzzle, spreading consecutive days
+// across the library.
+func dailyIndex(date string, count int) int {
+	f := fnv.New64a()
+	f.Write([]byte(date))
+	return int(f.Sum64() % uint64(count))
+}
+
+// HandleImportPuzzles loads the Lichess puzzle CSV from the request body into the puzzle
+// library. The optional min_popularity query parameter drops less popular puzzles.
+func (h *Handler) HandleImportPuzzles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	if h.PuzzleLibrary == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Puzzles are not available")
+		return
+	}
+	minPopularity := -100
+	if v := r.URL.Query().Get("min_popularity"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "min_popularity", "min_popularity must be an integer")
+			return
+		}
+		minPopularity = n
+	}
+
+	var importResponse types.PuzzleImportResponse
+	batch := make([]store.LibraryPuzzle, 0, puzzleImportBatch)
+	flush := func() error {
+		if err := h.PuzzleLibrary.AddLibraryPuzzles(batch); err != nil {
+			return err
+		}
+		importResponse.Imported += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	skipped, err := puzzledb.Read(r.Body, minPopularity, func(p store.LibraryPuzzle) error {
+		batch = append(batch, p)
+		if len(batch) == puzzleImportBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	importResponse.Skipped = skipped
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error importing puzzles", "imported", importResponse.Imported, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, fmt.Sprintf("Import failed after %d puzzles: %v", importResponse.Imported, err))
+		return
+	}
+	slog.InfoContext(r.Context(), "Imported library puzzles", "imported", importResponse.Imported, "skipped", importResponse.Skipped)
+
+	writeJSON(w, importResponse)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+var developmentCommentSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"comment": {
+			Type:        genai.TypeString,
+			Description: "Two or three friendly sentences tying the suggestions together.",
+		},
+	},
+	Required: []string{"comment"},
+}
+
+// HandleDevelopmentSuggestion computes opening-principle suggestions locally and only asks
+// the model to phrase them as a short coaching comment, which is cached by position.
+func (h *Handler) HandleDevelopmentSuggestion(w http.ResponseWriter, r *http.Request) {
+	developmentRequest, ok := decodeGameRequest[types.DevelopmentSuggestionRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	pos, err := parseGameFEN(developmentRequest.Fen, developmentRequest.Variant)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+
+	developmentResponse := types.DevelopmentSuggestionResponse{
+		OpeningPhase: analysis.IsOpeningPhase(pos),
+		Suggestions:  []analysis.Suggestion{},
+		Arrows:       [][2]string{},
+	}
+	if developmentResponse.OpeningPhase {
+		developmentResponse.Suggestions = append(developmentResponse.Suggestions, analysis.DevelopmentSuggestions(pos)...)
+	}
+	for _, s := range developmentResponse.Suggestions {
+		developmentResponse.Arrows = append(developmentResponse.Arrows, s.Arrows...)
+	}
+
+	if len(developmentResponse.Suggestions) == 0 {
+		developmentResponse.Comment = "Your pieces are developed and your king is safe — time to form a middlegame plan."
+		if !developmentResponse.OpeningPhase {
+			developmentResponse.Comment = "The opening is over in this position, so development advice no longer applies."
+		}
+		writeJSON(w, developmentResponse)
+		return
+	}
+
+	key := analysisKey(pos)
+	if cached, ok := h.developments.Get(key); ok {
+		cached.Meta = &types.ResponseMeta{CacheHit: true}
+		writeJSON(w, cached)
+		return
+	}
+
+	var points strings.Builder
+	for _, s := range developmentResponse.Suggestions {
+		fmt.Fprintf(&points, "- %s\n", s.Explanation)
+	}
+
+	ctx, cancel := h.requestContext(r, "developmentSuggestion")
+	defer cancel()
+
+	promptText := fmt.Sprintf(`You are a friendly chess coach helping a beginner (playing %s) through the opening. An engine has already found the following improvements. Turn them into a short, encouraging comment that explains the opening principles behind them. Do not suggest any other moves. Refer to the pupil as "you".
+
+%s
+Respond ONLY with a JSON object matching the schema.`, pos.Turn, points.String())
+
+	jsonString, ok := h.generate(ctx, w, h.modelRequest("developmentSuggestion", promptText, developmentCommentSchema))
+	if !ok {
+		return
+	}
+
+	var prose struct {
+		Comment string `json:"comment"`
+	}
+	if err := json.Unmarshal([]byte(jsonString), &prose); err != nil {
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse development comment")
+		return
+	}
+	developmentResponse.Comment = prose.Comment
+	h.developments.Add(key, developmentResponse)
+	developmentResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, developmentResponse)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// HandleEvalGraph evaluates every position of a stored game with the local engine for an
+// evaluation graph. The optional threshold query parameter sets the swing, in centipawns,
+// that marks a critical moment.
+func (h *Handler) HandleEvalGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	threshold := types.DefaultCriticalSwing
+	if v := r.URL.Query().Get("threshold"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "threshold", "threshold must be a positive number of centipawns")
+			return
+		}
+		threshold = n
+	}
+
+	game, err := visibleGame(r, h.Games, r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if len(game.MoveHistory) > types.MaxReviewPlies {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, fmt.Sprintf("Evaluation graphs cover games of at most %d plies", types.MaxReviewPlies))
+		return
+	}
+
+	start, err := parseGameFEN(game.InitialFen, game.Variant)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error parsing stored initial FEN of game", "game_id", game.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to analyze game")
+		return
+	}
+	points, err := analysis.EvalGraph(start, game.MoveHistory, analysis.DefaultDepth)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error analyzing game", "game_id", game.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to analyze game")
+		return
+	}
+
+	graphResponse := types.EvalGraphResponse{
+		GameID:          game.ID,
+		Threshold:       threshold,
+		Points:          points,
+		CriticalMoments: analysis.CriticalMoments(start, points, threshold),
+	}
+	if graphResponse.CriticalMoments == nil {
+		graphResponse.CriticalMoments = []analysis.CriticalMoment{}
+	}
+
+	writeJSON(w, graphResponse)
+}
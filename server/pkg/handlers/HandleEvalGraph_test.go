@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// storeHillGame stores a King of the Hill game from hillFEN with history played.
+func storeHillGame(t *testing.T, h *Handler, history ...string) *store.Game {
+	t.Helper()
+	start, err := parseGameFEN(hillFEN, "king_of_the_hill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions, err := chess.Replay(start, history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	game, err := h.Games.Create(store.Game{InitialFen: start.FEN(), Variant: "king_of_the_hill"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game, err = h.Games.Update(game.ID, game.Version, func(g *store.Game) error {
+		g.MoveHistory, g.Fen = history, start.FEN()
+		if len(positions) > 0 {
+			g.Fen = positions[len(positions)-1].FEN()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return game
+}
+
+func TestEvalGraphVariantGame(t *testing.T) {
+	h, _ := newTestHandler()
+	game := storeHillGame(t, h, "Kd4")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/games/"+game.ID+"/evalGraph", nil)
+	r.SetPathValue("id", game.ID)
+	h.HandleEvalGraph(w, r)
+	resp := decodeResponse[types.EvalGraphResponse](t, w, http.StatusOK)
+
+	if len(resp.Points) != 2 {
+		t.Fatalf("graph has %d points, want 2", len(resp.Points))
+	}
+	// Before the move White stands to win on the hill, not lose a queen down.
+	if first := resp.Points[0]; first.BestMove != "Kd4" || first.Mate <= 0 {
+		t.Errorf("first point = %+v, want Kd4 winning for White", first)
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+var evaluateResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "A one-sentence summary of a chess position's evaluation.",
+	Properties: map[string]*genai.Schema{
+		"summary": {
+			Type:        genai.TypeString,
+			Description: "One sentence on who stands better and why.",
+		},
+	},
+	Required: []string{"summary"},
+}
+
+type evaluationSummary struct {
+	Summary string `json:"summary"`
+}
+
+// HandleEvaluate scores a position with the local engine for an evaluation bar and asks
+// the coach for a one-sentence summary of it. Results are cached by position.
+func (h *Handler) HandleEvaluate(w http.ResponseWriter, r *http.Request) {
+	evaluateRequest, ok := decodeGameRequest[types.EvaluateRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	pos, err := parseGameFEN(evaluateRequest.Fen, evaluateRequest.Variant)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+	if len(pos.LegalMoves()) == 0 {
+		apierror.Write(w, http.StatusBadRequest, apierror.GameOver, "The game is over in this position")
+		return
+	}
+
+	fen := pos.FEN()
+	key := analysisKey(pos)
+	if cached, ok := h.evaluations.Get(key); ok {
+		cached.Fen = fen
+		cached.Meta = &types.ResponseMeta{CacheHit: true}
+		writeJSON(w, cached)
+		return
+	}
+
+	result := engine.Search(pos, types.DefaultSearchDepth)
+	score := engine.WhiteScore(result.Score, pos.Turn)
+	evaluateResponse := types.EvaluateResponse{
+		Fen:             fen,
+		Score:           score,
+		Mate:            engine.MateIn(score),
+		Display:         engine.FormatScore(score),
+		WhiteWinPercent: math.Round(10*analysis.WinPercent(score)) / 10,
+		BestMove:        pos.SAN(result.Move),
+		BestLine:        sanLine(pos, result.PV, -1),
+		Depth:           result.Depth,
+	}
+
+	ctx, cancel := h.requestContext(r, "evaluate")
+	defer cancel()
+
+	prompt := fmt.Sprintf(`You are a chess coach explaining an engine evaluation to a club player.
+
+FEN: %s
+%s is to move.
+Evaluation (from White's point of view, in pawns; "#n" is mate in n): %s
+Best line: %s
+
+In one sentence, say who stands better and the main reason why. Do not quote the evaluation number.
+
+Respond ONLY with a JSON object matching the schema.`, fen, pos.Turn, evaluateResponse.Display, strings.Join(evaluateResponse.BestLine, " "))
+
+	jsonString, ok := h.generate(ctx, w, h.modelRequest("evaluate", prompt, evaluateResponseSchema))
+	if !ok {
+		return
+	}
+	var summary evaluationSummary
+	if err := json.Unmarshal([]byte(jsonString), &summary); err != nil {
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse evaluation summary")
+		return
+	}
+	evaluateResponse.Summary = summary.Summary
+	h.evaluations.Add(key, evaluateResponse)
+	evaluateResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, evaluateResponse)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// hillFEN has White's king a step from d4, which wins at once in King of the Hill while
+// Black's extra queen decides a standard game.
+const hillFEN = "k7/8/8/8/8/4K3/8/7q w - - 0 1"
+
+func evaluate(t *testing.T, h *Handler, req types.EvaluateRequest, status int) types.EvaluateResponse {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := serve(h.HandleEvaluate, http.MethodPost, "/api/evaluate", string(body))
+	return decodeResponse[types.EvaluateResponse](t, w, status)
+}
+
+func TestEvaluateVariant(t *testing.T) {
+	h, _ := newTestHandler(`{"summary": "Black is a queen up."}`, `{"summary": "White walks to the hill."}`)
+
+	standard := evaluate(t, h, types.EvaluateRequest{Fen: hillFEN}, http.StatusOK)
+	if standard.Score >= 0 {
+		t.Errorf("standard score = %d, want Black winning", standard.Score)
+	}
+
+	// The same FEN under King of the Hill is scored afresh, not served from the cache.
+	hill := evaluate(t, h, types.EvaluateRequest{Fen: hillFEN, Variant: "king_of_the_hill"}, http.StatusOK)
+	if hill.Meta != nil && hill.Meta.CacheHit {
+		t.Error("King of the Hill evaluation was served from the standard one's cache entry")
+	}
+	if hill.BestMove != "Kd4" || hill.Mate <= 0 {
+		t.Errorf("King of the Hill = best %s, mate %d; want Kd4 winning", hill.BestMove, hill.Mate)
+	}
+}
+
+func TestEvaluateRejectsUnknownVariant(t *testing.T) {
+	h, _ := newTestHandler()
+	w := serve(h.HandleEvaluate, http.MethodPost, "/api/evaluate", `{"fen": "`+hillFEN+`", "variant": "atomic"}`)
+	if resp := decodeResponse[apierror.Response](t, w, http.StatusBadRequest); resp.Error.Field != "variant" {
+		t.Errorf("error = %+v, want variant rejected", resp.Error)
+	}
+}
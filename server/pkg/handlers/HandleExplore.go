@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// exploreDepth is the search depth used to judge each move of an explored line.
+const exploreDepth = 3
+
+// refutationLength is how many plies of a refutation are shown.
+const refutationLength = 4
+
+var exploreResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "The coach's take on a hypothetical line.",
+	Properties: map[string]*genai.Schema{
+		"analysis": {
+			Type:        genai.TypeString,
+			Description: "2-4 sentences on the position the line leads to and, when it is unsound, how it is refuted.",
+		},
+	},
+	Required: []string{"analysis"},
+}
+
+type exploreAnalysis struct {
+	Analysis string `json:"analysis"`
+}
+
+// HandleExplore answers "what happens if I play this?": it replays a hypothetical line
+// from a position, judges every move with the local engine, shows the refutation of the
+// first unsound one and has the coach comment on where the line ends up.
+func (h *Handler) HandleExplore(w http.ResponseWriter, r *http.Request) {
+	exploreRequest, ok := decodeGameRequest[types.ExploreRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	start, err := parseGameFEN(exploreRequest.Fen, exploreRequest.Variant)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+	line, err := notation.NormalizeLine(start, exploreRequest.Moves)
+	if err != nil {
+		var illegal *chess.IllegalMoveError
+		if errors.As(err, &illegal) {
+			apierror.WriteField(w, http.StatusBadRequest, apierror.IllegalMove, "moves", fmt.Sprintf("Move %d of the line (%s) is illegal: %v", illegal.Ply, illegal.Move, illegal.Err))
+			return
+		}
+		apierror.WriteField(w, http.StatusBadRequest, apierror.IllegalMove, "moves", "Invalid moves")
+		return
+	}
+	positions, _ := chess.Replay(start, line)
+	positions = append([]*chess.Position{start}, positions...)
+	end := positions[len(positions)-1]
+
+	exploreResponse := types.ExploreResponse{
+		Fen:      end.FEN(),
+		Moves:    make([]analysis.MoveAnalysis, 0, len(line)),
+		BestLine: []string{},
+		Sound:    true,
+		Status:   gameStatus(positions...),
+	}
+	for i, san := range line {
+		ma, err := analysis.AnalyzeMove(positions[i], san, exploreDepth)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error analyzing explored move", "move", san, "err", err)
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to analyze line")
+			return
+		}
+		ma.Ply = i + 1
+		exploreResponse.Moves = append(exploreResponse.Moves, ma)
+		if exploreResponse.Sound && (ma.Classification == analysis.ClassMistake || ma.Classification == analysis.ClassBlunder) {
+			exploreResponse.Sound = false
+			after := engine.Search(positions[i+1], exploreDepth)
+			exploreResponse.Refutation = &types.Refutation{
+				Ply:        ma.Ply,
+				Move:       ma.San,
+				BetterMove: ma.BestMove,
+				Line:       sanLine(positions[i+1], after.PV, refutationLength),
+				Loss:       ma.Loss,
+			}
+			if len(exploreResponse.Refutation.Line) > 0 {
+				exploreResponse.Refutation.Reply = exploreResponse.Refutation.Line[0]
+			}
+		}
+	}
+
+	switch {
+	case end.IsCheckmate():
+		exploreResponse.Score = engine.WhiteScore(-engine.MateScore, end.Turn)
+	case len(end.LegalMoves()) == 0:
+		exploreResponse.Score = 0
+	default:
+		result := engine.Search(end, types.DefaultSearchDepth)
+		exploreResponse.Score = engine.WhiteScore(result.Score, end.Turn)
+		exploreResponse.BestLine = sanLine(end, result.PV, -1)
+	}
+	exploreResponse.Mate = engine.MateIn(exploreResponse.Score)
+	exploreResponse.Display = engine.FormatScore(exploreResponse.Score)
+
+	var verdicts strings.Builder
+	for _, m := range exploreResponse.Moves {
+		fmt.Fprintf(&verdicts, "%d. %s (%s): %s", m.Ply, m.San, m.Side, m.Classification)
+		if m.Classification != analysis.ClassBest && m.Classification != analysis.ClassBrilliant {
+			fmt.Fprintf(&verdicts, ", engine preferred %s", m.BestMove)
+		}
+		verdicts.WriteString("\n")
+	}
+	refutation := "None: the line is sound."
+	if ref := exploreResponse.Refutation; ref != nil {
+		refutation = fmt.Sprintf("%s at move %d is refuted by %s (line: %s); %s was better.", ref.Move, ref.Ply, ref.Reply, strings.Join(ref.Line, " "), ref.BetterMove)
+	}
+
+	ctx, cancel := h.requestContext(r, "explore")
+	defer cancel()
+
+	prompt := fmt.Sprintf(`You are a chess coach answering your pupil's question "what happens if I play this?" on an analysis board.
+
+Starting position (FEN): %s
+Line explored, with an engine's verdict on each move:
+%s
+Refutation: %s
+Resulting position (FEN): %s
+Evaluation of the resulting position (from White's point of view, in pawns; "#n" is mate in n): %s
+Engine's best continuation: %s
+
+In 2-4 sentences, say what the resulting position is like and who is better. If the line is refuted, explain the refutation and why the better move works. Trust the engine's verdicts.
+
+Refer to the pupil as "you". Use clear, casual language.
+
+Respond ONLY with a JSON object matching the schema.`, start.FEN(), verdicts.String(), refutation, exploreResponse.Fen,
+		exploreResponse.Display, strings.Join(exploreResponse.BestLine, " "))
+
+	jsonString, ok := h.generate(ctx, w, h.modelRequest("explore", prompt, exploreResponseSchema))
+	if !ok {
+		return
+	}
+	var comment exploreAnalysis
+	if err := json.Unmarshal([]byte(jsonString), &comment); err != nil {
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse line analysis")
+		return
+	}
+	exploreResponse.Analysis = comment.Analysis
+	exploreResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, exploreResponse)
+}
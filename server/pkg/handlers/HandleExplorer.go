@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/explorer"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+)
+
+// explorerTimeout bounds an explorer lookup made to enrich the coach's prompt.
+const explorerTimeout = 2 * time.Second
+
+// explorerMaxMove is the last move number at which the coach's prompt gets master
+// statistics; later positions are almost never in the masters database.
+const explorerMaxMove = 20
+
+// HandleExplorer reports how masters have continued from the position in the fen query
+// parameter, proxying the opening explorer.
+func (h *Handler) HandleExplorer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	fen := r.URL.Query().Get("fen")
+	if fen == "" {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "fen", "Missing fen query parameter")
+		return
+	}
+	pos, err := chess.ParseFEN(fen)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+	if h.Explorer == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Opening explorer is not configured")
+		return
+	}
+
+	stats, err := h.Explorer.Masters(r.Context(), pos)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Opening explorer lookup failed", "err", err)
+		apierror.Write(w, http.StatusBadGateway, apierror.UpstreamError, "Opening explorer unavailable")
+		return
+	}
+
+	writeJSON(w, explorerResponse(pos.FEN(), stats))
+}
+
+func explorerResponse(fen string, stats explorer.Stats) types.ExplorerResponse {
+	games := stats.Games()
+	resp := types.ExplorerResponse{
+		Fen:       fen,
+		Games:     games,
+		WhiteWins: percent(stats.White, games),
+		Draws:     percent(stats.Draws, games),
+		BlackWins: percent(stats.Black, games),
+		Moves:     make([]types.ExplorerMove, 0, len(stats.Moves)),
+	}
+	if stats.Opening != nil {
+		resp.ECO, resp.OpeningName = stats.Opening.ECO, stats.Opening.Name
+	}
+	for _, m := range stats.Moves {
+		n := m.Games()
+		resp.Moves = append(resp.Moves, types.ExplorerMove{
+			San:           m.SAN,
+			Uci:           m.UCI,
+			Games:         n,
+			Popularity:    percent(n, games),
+			WhiteWins:     percent(m.White, n),
+			Draws:         percent(m.Draws, n),
+			BlackWins:     percent(m.Black, n),
+			AverageRating: m.AverageRating,
+		})
+	}
+	return resp
+}
+
+// percent is part as a percentage of total, to one decimal place.
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(1000*float64(part)/float64(total)) / 10
+}
+
+// explorerStats fetches master statistics for the coach's prompt when Config.ExplorerPrompt
+// is set and pos is a standard chess position early enough to be in the database. Failures are logged and leave the
+// prompt without them.
+func (h *Handler) explorerStats(ctx context.Context, pos *chess.Position) *explorer.Stats {
+	if !h.Config.ExplorerPrompt || h.Explorer == nil || pos == nil || pos.Variant != chess.Standard || pos.FullmoveNumber > explorerMaxMove {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, explorerTimeout)
+	defer cancel()
+	stats, err := h.Explorer.Masters(ctx, pos)
+	if err != nil {
+		slog.WarnContext(ctx, "Opening explorer lookup failed", "err", err)
+		return nil
+	}
+	if stats.Games() == 0 {
+		return nil
+	}
+	return &stats
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/account"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/pgn"
+	"arnavsurve/nara-chess/server/pkg/render"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (h *Handler) HandleNewGame(w http.ResponseWriter, r *http.Request) {
+	newGameRequest, ok := decodeAndValidate[types.NewGameRequest](w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := pupilID(r, newGameRequest.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	initialFen, err := startFEN(newGameRequest.InitialFen, newGameRequest.Odds, newGameRequest.PupilSide)
+	if err != nil {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "odds", err.Error())
+		return
+	}
+	pos, err := parseGameFEN(initialFen, newGameRequest.Variant)
+	if err != nil {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidFEN, "initial_fen", types.FENMessage("initial_fen", err))
+		return
+	}
+
+	game, err := h.Games.Create(store.Game{
+		InitialFen: pos.FEN(),
+		Variant:    storedVariant(pos.Variant),
+		Persona:    newGameRequest.Persona,
+		UserID:     userID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating game", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to create game")
+		return
+	}
+
+	writeJSON(w, game)
+}
+
+// startFEN is the position a new game starts from: initialFen, the standard start when
+// empty, or with odds the standard start less the material the coach gives up playing
+// against pupilSide.
+func startFEN(initialFen, odds, pupilSide string) (string, error) {
+	if odds == "" {
+		if initialFen == "" {
+			return chess.StartFEN, nil
+		}
+		return initialFen, nil
+	}
+	coach := chess.Black
+	if pupilSide == "black" {
+		coach = chess.White
+	}
+	pos, err := chess.OddsPosition(odds, coach)
+	if err != nil {
+		return "", err
+	}
+	return pos.FEN(), nil
+}
+
+func (h *Handler) HandleGetGame(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	game, err := visibleGame(r, h.Games, r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, game)
+}
+
+// HandleListGames lists stored games, most recently played first, scoped to the signed-in
+// user. Pass ?include_thumbnails=true to add a board drawing of each game's current
+// position.
+func (h *Handler) HandleListGames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	includeThumbnails := false
+	if v := r.URL.Query().Get("include_thumbnails"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "include_thumbnails", "include_thumbnails must be true or false")
+			return
+		}
+		includeThumbnails = b
+	}
+
+	games, err := h.Games.List()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing games", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to list games")
+		return
+	}
+
+	// A signed-in user sees only their own games; everyone else sees the games of pupils
+	// without an account.
+	userID := account.UserID(r.Context())
+	gameListResponse := types.GameListResponse{Games: make([]types.GameSummary, 0, len(games))}
+	for _, g := range games {
+		if (userID != "" && g.UserID != userID) || (userID == "" && store.IsUserID(g.UserID)) {
+			continue
+		}
+		summary := types.GameSummary{
+			GameID:    g.ID,
+			Fen:       g.Fen,
+			Moves:     len(g.MoveHistory),
+			UpdatedAt: g.UpdatedAt,
+		}
+		if includeThumbnails {
+			if pos, err := parseGameFEN(g.Fen, g.Variant); err == nil {
+				summary.Thumbnail = render.BoardString(pos)
+			}
+		}
+		gameListResponse.Games = append(gameListResponse.Games, summary)
+	}
+
+	writeJSON(w, gameListResponse)
+}
+
+// HandleGameMove appends the pupil's move to a stored game. The request must name the
+// version it was made against so a double submission is rejected with 409 Conflict rather
+// than being applied twice.
+func (h *Handler) HandleGameMove(w http.ResponseWriter, r *http.Request) {
+	gameMoveRequest, ok := decodeAndValidate[types.GameMoveRequest](w, r)
+	if !ok {
+		return
+	}
+
+	game, err := h.Games.Update(r.PathValue("id"), gameMoveRequest.ExpectedVersion, guardGame(r, applyMove(gameMoveRequest.Move, store.MoveComment{
+		Comment: gameMoveRequest.Comment,
+		Title:   gameMoveRequest.Title,
+		Arrows:  gameMoveRequest.Arrows,
+	})))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	h.gameUpdated(game)
+
+	writeJSON(w, game)
+}
+
+// errGameClosed rejects changes to a game that was resigned or agreed drawn.
+var errGameClosed = errors.New("The game is over")
+
+// applyMove returns a store update that plays san in the game's current position,
+// recording note with it unless note is empty. note's Ply is filled in.
+func applyMove(san string, note store.MoveComment) func(*store.Game) error {
+	return func(g *store.Game) error {
+		if g.Result != "" {
+			return errGameClosed
+		}
+		pos, err := parseGameFEN(g.Fen, g.Variant)
+		if err != nil {
+			return err
+		}
+		m, err := notation.ParseMove(pos, san)
+		if err != nil {
+			return badRequest(apierror.IllegalMove, "move", "%v", err)
+		}
+		g.MoveHistory = append(g.MoveHistory, pos.SAN(m))
+		g.Fen = pos.Play(m).FEN()
+		if note.Comment != "" || note.Title != "" || len(note.Arrows) > 0 {
+			note.Ply = len(g.MoveHistory)
+			g.Comments = append(g.Comments, note)
+		}
+		return nil
+	}
+}
+
+// HandleTakeback rewinds a stored game by one or two plies, dropping the comments made on
+// the moves taken back. The coach acknowledges the takeback in its next reply.
+func (h *Handler) HandleTakeback(w http.ResponseWriter, r *http.Request) {
+	takebackRequest, ok := decodeAndValidate[types.TakebackRequest](w, r)
+	if !ok {
+		return
+	}
+
+	game, err := h.Games.Update(r.PathValue("id"), takebackRequest.ExpectedVersion, guardGame(r, takeBack(takebackRequest.Plies)))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, game)
+}
+
+// takeBack returns a store update that removes the last plies moves of the game and
+// records the takeback.
+func takeBack(plies int) func(*store.Game) error {
+	return func(g *store.Game) error {
+		if g.Result != "" {
+			return errGameClosed
+		}
+		if plies > len(g.MoveHistory) {
+			return badRequest(apierror.InvalidRequest, "plies", "cannot take back %d plies from a game of %d", plies, len(g.MoveHistory))
+		}
+		start, err := parseGameFEN(g.InitialFen, g.Variant)
+		if err != nil {
+			return err
+		}
+		keep := len(g.MoveHistory) - plies
+		positions, err := chess.Replay(start, g.MoveHistory[:keep])
+		if err != nil {
+			return err
+		}
+		g.Fen = append([]*chess.Position{start}, positions...)[keep].FEN()
+		g.Takebacks = append(g.Takebacks, store.Takeback{
+			Ply:   keep,
+			Moves: slices.Clone(g.MoveHistory[keep:]),
+			At:    time.Now().UTC(),
+		})
+		g.MoveHistory = g.MoveHistory[:keep]
+		g.Comments = slices.DeleteFunc(g.Comments, func(c store.MoveComment) bool { return c.Ply > keep })
+		return nil
+	}
+}
+
+// recentTakeback returns the moves just taken back in the stored game req names, when the
+// coach has not replied since: the game is at most one ply past the takeback.
+func (h *Handler) recentTakeback(req types.GameStateRequest) []string {
+	if req.GameID == "" {
+		return nil
+	}
+	game, err := h.Games.Get(req.GameID)
+	if err != nil || len(game.Takebacks) == 0 {
+		return nil
+	}
+	last := game.Takebacks[len(game.Takebacks)-1]
+	if len(game.MoveHistory)-last.Ply > 1 {
+		return nil
+	}
+	return last.Moves
+}
+
+// storedGameStatus reports how a stored game is over, or nil while it is in progress.
+func storedGameStatus(g *store.Game) *types.GameStatus {
+	if g.Result != "" {
+		return &types.GameStatus{Result: g.Result, Reason: g.Reason}
+	}
+	start, err := parseGameFEN(g.InitialFen, g.Variant)
+	if err != nil {
+		return nil
+	}
+	positions, _ := chess.Replay(start, g.MoveHistory)
+	return gameStatus(append([]*chess.Position{start}, positions...)...)
+}
+
+// visibleGame loads the game id names, provided the request may see it. Another user's
+// game is reported as store.ErrNotFound, so its ID gives nothing away.
+func visibleGame(r *http.Request, games store.GameStore, id string) (*store.Game, error) {
+	game, err := games.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if canSee(r, game.UserID) != nil {
+		return nil, store.ErrNotFound
+	}
+	return game, nil
+}
+
+// guardGame wraps fn, a store update, so it fails with store.ErrNotFound on a game the
+// request may not see.
+func guardGame(r *http.Request, fn func(*store.Game) error) func(*store.Game) error {
+	return func(g *store.Game) error {
+		if canSee(r, g.UserID) != nil {
+			return store.ErrNotFound
+		}
+		return fn(g)
+	}
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Game not found")
+	case errors.Is(err, store.ErrVersionConflict):
+		apierror.Write(w, http.StatusConflict, apierror.VersionConflict, "Game was modified by another request; reload it and retry")
+	case errors.Is(err, errGameClosed):
+		apierror.Write(w, http.StatusBadRequest, apierror.GameOver, err.Error())
+	case errors.As(err, new(*httpError)):
+		writeError(w, err)
+	default:
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, err.Error())
+	}
+}
+
+// HandleExportPGN downloads a stored game as annotated PGN. Coach comments and titles
+// become move comments and arrows become [%cal] commands, so the game opens in Lichess
+// or ChessBase with the coaching intact.
+func (h *Handler) HandleExportPGN(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	game, err := visibleGame(r, h.Games, r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-chess-pgn")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="nara-%s.pgn"`, game.ID))
+	io.WriteString(w, pgn.Format(exportGame(game)))
+}
+
+// exportGame converts a stored game to PGN tags, moves and annotations. A title is only
+// repeated when it differs from the previous one.
+func exportGame(g *store.Game) (pgn.Game, map[int]pgn.Annotation) {
+	tags := map[string]string{
+		"Event":     "Nara coaching session",
+		"Site":      "Nara Chess",
+		"Date":      g.CreatedAt.Format("2006.01.02"),
+		"Annotator": "Nara",
+	}
+	if g.InitialFen != chess.StartFEN {
+		tags["SetUp"] = "1"
+		tags["FEN"] = g.InitialFen
+	}
+	if v, err := chess.ParseVariant(g.Variant); err == nil && v != chess.Standard {
+		tags["Variant"] = v.Title()
+	}
+	result := "*"
+	if status := storedGameStatus(g); status != nil {
+		result = status.Result
+	}
+
+	annotations := make(map[int]pgn.Annotation)
+	lastTitle := ""
+	for _, c := range g.Comments {
+		text := c.Comment
+		if c.Title != "" && c.Title != lastTitle {
+			text = strings.TrimSpace(c.Title + ": " + text)
+			text = strings.TrimSuffix(text, ":")
+			lastTitle = c.Title
+		}
+		a := annotations[c.Ply]
+		if a.Comment != "" && text != "" {
+			a.Comment += " "
+		}
+		a.Comment += text
+		for _, arrow := range c.Arrows {
+			a.Arrows = append(a.Arrows, pgn.Arrow{From: arrow[0], To: arrow[1]})
+		}
+		annotations[c.Ply] = a
+	}
+	return pgn.Game{Tags: tags, Moves: g.MoveHistory, Result: result}, annotations
+}
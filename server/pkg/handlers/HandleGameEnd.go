@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// drawAcceptMargin is the largest advantage, in centipawns, at which the coach still
+// accepts a draw offer.
+const drawAcceptMargin = 50
+
+var drawOfferResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "The coach's in-character answer to a draw offer.",
+	Properties: map[string]*genai.Schema{
+		"comment": {
+			Type:        genai.TypeString,
+			Description: "1-2 sentences declining the draw.",
+		},
+	},
+	Required: []string{"comment"},
+}
+
+type drawOfferComment struct {
+	Comment string `json:"comment"`
+}
+
+// HandleResign ends a stored game with the pupil's resignation and has the coach sum it up.
+func (h *Handler) HandleResign(w http.ResponseWriter, r *http.Request) {
+	resignRequest, ok := decodeAndValidate[types.ResignRequest](w, r)
+	if !ok {
+		return
+	}
+	pupil := chess.White
+	if resignRequest.PupilSide == "black" {
+		pupil = chess.Black
+	}
+
+	result := "1-0"
+	if pupil == chess.White {
+		result = "0-1"
+	}
+	status := chess.Status{Over: true, Result: result, Reason: chess.ReasonResignation}
+	game, err := h.Games.Update(r.PathValue("id"), resignRequest.ExpectedVersion, guardGame(r, closeGame(status)))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	h.gameUpdated(game)
+
+	pos, err := parseGameFEN(game.Fen, game.Variant)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error parsing stored FEN of game", "game_id", game.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load game")
+		return
+	}
+
+	ctx, cancel := h.requestContext(r, "gameOver")
+	defer cancel()
+
+	writeJSON(w, types.ResignResponse{
+		GameOver: h.summarizeGameOver(ctx, pos, game.MoveHistory, pupil, status),
+		Version:  game.Version,
+		Meta:     responseMeta(ctx),
+	})
+}
+
+// HandleOfferDraw puts the pupil's draw offer to the coach, which accepts unless the local
+// engine rates its position better by more than drawAcceptMargin. An accepted offer
+// closes the game.
+func (h *Handler) HandleOfferDraw(w http.ResponseWriter, r *http.Request) {
+	drawOfferRequest, ok := decodeAndValidate[types.DrawOfferRequest](w, r)
+	if !ok {
+		return
+	}
+	pupil := chess.White
+	if drawOfferRequest.PupilSide == "black" {
+		pupil = chess.Black
+	}
+	coach := pupil.Other()
+
+	game, err := visibleGame(r, h.Games, r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if game.Version != drawOfferRequest.ExpectedVersion {
+		writeStoreError(w, store.ErrVersionConflict)
+		return
+	}
+	if storedGameStatus(game) != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.GameOver, errGameClosed.Error())
+		return
+	}
+	pos, err := parseGameFEN(game.Fen, game.Variant)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error parsing stored FEN of game", "game_id", game.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load game")
+		return
+	}
+
+	search := engine.Search(pos, types.DefaultSearchDepth)
+	score := engine.ScoreFor(engine.WhiteScore(search.Score, pos.Turn), coach)
+	drawOfferResponse := types.DrawOfferResponse{
+		Accepted: score <= drawAcceptMargin,
+		Score:    score,
+		Display:  engine.FormatScore(score),
+		Version:  game.Version,
+	}
+
+	ctx, cancel := h.requestContext(r, "drawOffer")
+	defer cancel()
+
+	if drawOfferResponse.Accepted {
+		status := chess.Status{Over: true, Result: "1/2-1/2", Reason: chess.ReasonDrawAgreed}
+		game, err = h.Games.Update(game.ID, game.Version, closeGame(status))
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		h.gameUpdated(game)
+		drawOfferResponse.GameOver = h.summarizeGameOver(ctx, pos, game.MoveHistory, pupil, status)
+		drawOfferResponse.Comment = drawOfferResponse.GameOver.Summary
+		drawOfferResponse.Version = game.Version
+	} else {
+		drawOfferResponse.Comment = h.declineDraw(ctx, pos, game.MoveHistory, pupil, score, sanLine(pos, search.PV, -1))
+	}
+	drawOfferResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, drawOfferResponse)
+}
+
+// closeGame returns a store update that ends a game in progress off the board.
+func closeGame(status chess.Status) func(*store.Game) error {
+	return func(g *store.Game) error {
+		if storedGameStatus(g) != nil {
+			return errGameClosed
+		}
+		g.Result, g.Reason = status.Result, status.Reason
+		return nil
+	}
+}
+
+// declineDraw has the coach turn down a draw offer in character, falling back to a stock
+// reply when the model fails. score is the coach's evaluation and line the engine's best
+// continuation.
+func (h *Handler) declineDraw(ctx context.Context, pos *chess.Position, history []string, pupil chess.Color, score int, line []string) string {
+	comment := "Thanks for the offer, but I think there's still plenty to play for here. Let's keep going!"
+
+	prompt := fmt.Sprintf(`You are a chess coach playing a game against your pupil, who plays %s. Your pupil has just offered you a draw, and you are declining it because an engine rates your position as better (evaluation %s in pawns from your point of view; "#n" is mate in n). The engine's best line is: %s
+
+Position (FEN): %s
+Moves: %s
+
+In 1-2 sentences, decline the draw in character: be friendly, hint at why you want to play on without quoting the evaluation or giving away your plan, and encourage your pupil to keep fighting. Refer to the pupil as "you". Use clear, casual language.
+
+Respond ONLY with a JSON object matching the schema.`, pupil, engine.FormatScore(score), strings.Join(line, " "), pos.FEN(), strings.Join(history, " "))
+
+	jsonString, err := h.callModel(ctx, h.modelRequest("drawOffer", prompt, drawOfferResponseSchema))
+	var reply drawOfferComment
+	switch {
+	case err != nil:
+		slog.WarnContext(ctx, "Using stock draw refusal", "err", err)
+	case json.Unmarshal([]byte(jsonString), &reply) != nil || strings.TrimSpace(reply.Comment) == "":
+		slog.WarnContext(ctx, "Using stock draw refusal; unusable model response")
+		slog.DebugContext(ctx, "Unusable draw refusal", "response", jsonString)
+	default:
+		comment = reply.Comment
+	}
+	return comment
+}
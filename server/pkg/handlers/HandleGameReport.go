@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/openings"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+var gameReportResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "An end-of-game report for the pupil.",
+	Properties: map[string]*genai.Schema{
+		"summary": {
+			Type:        genai.TypeString,
+			Description: "A short (2-4 sentence) overview of how the game went.",
+		},
+		"opening": {
+			Type:        genai.TypeString,
+			Description: "The name of the opening played, e.g. 'Italian Game: Two Knights Defense'.",
+		},
+		"key_moments": {
+			Type:        genai.TypeArray,
+			Description: "One explanation per key moment listed in the prompt, in order.",
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"ply":         {Type: genai.TypeInteger, Description: "The ply number exactly as given."},
+					"explanation": {Type: genai.TypeString, Description: "2-3 sentences on what happened and what the better idea was."},
+				},
+				Required: []string{"ply", "explanation"},
+			},
+		},
+		"study_topics": {
+			Type:        genai.TypeArray,
+			Description: "2-4 concrete topics to study, drawn from the mistakes in this game.",
+			Items:       &genai.Schema{Type: genai.TypeString},
+		},
+	},
+	Required: []string{"summary", "opening", "key_moments", "study_topics"},
+}
+
+type gameReport struct {
+	Summary    string `json:"summary"`
+	Opening    string `json:"opening"`
+	KeyMoments []struct {
+		Ply         int    `json:"ply"`
+		Explanation string `json:"explanation"`
+	} `json:"key_moments"`
+	StudyTopics []string `json:"study_topics"`
+}
+
+// HandleGameReport produces a post-mortem of a stored game: each side's accuracy and
+// centipawn loss from the local engine, and the coach's explanation of the key moments,
+// the opening and what to study next.
+func (h *Handler) HandleGameReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	game, err := visibleGame(r, h.Games, r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if len(game.MoveHistory) == 0 {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, "Game has no moves to report on")
+		return
+	}
+	if len(game.MoveHistory) > types.MaxReviewPlies {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, fmt.Sprintf("Reports cover games of at most %d plies", types.MaxReviewPlies))
+		return
+	}
+
+	start, err := parseGameFEN(game.InitialFen, game.Variant)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error parsing stored initial FEN of game", "game_id", game.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to analyze game")
+		return
+	}
+	positions, err := chess.Replay(start, game.MoveHistory)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error replaying game", "game_id", game.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to analyze game")
+		return
+	}
+	positions = append([]*chess.Position{start}, positions...)
+	moves, err := analysis.AnalyzeGame(start, game.MoveHistory, analysis.DefaultDepth)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error analyzing game", "game_id", game.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to analyze game")
+		return
+	}
+
+	reportResponse := types.GameReportResponse{
+		GameID:      game.ID,
+		White:       analysis.SummarizeSide(moves, chess.White.String()),
+		Black:       analysis.SummarizeSide(moves, chess.Black.String()),
+		KeyMoments:  []types.KeyMoment{},
+		StudyTopics: []string{},
+	}
+	if opening, ok := openings.Classify(start, game.MoveHistory); ok {
+		reportResponse.ECO, reportResponse.Opening = opening.ECO, opening.Name
+	}
+	reason := ""
+	if status := storedGameStatus(game); status != nil {
+		reportResponse.Result, reason = status.Result, status.Reason
+	}
+	for _, m := range analysis.KeyMoments(moves, types.KeyMomentCount) {
+		reportResponse.KeyMoments = append(reportResponse.KeyMoments, types.KeyMoment{
+			Ply:            m.Ply,
+			San:            m.San,
+			Side:           m.Side,
+			FenBefore:      positions[m.Ply-1].FEN(),
+			Fen:            positions[m.Ply].FEN(),
+			Classification: m.Classification,
+			BestMove:       m.BestMove,
+			Loss:           m.Loss,
+		})
+	}
+
+	ctx, cancel := h.requestContext(r, "gameReport")
+	defer cancel()
+
+	promptText := fmt.Sprintf(`You are a patient chess coach writing an end-of-game report for your pupil.
+
+Below is every move of the game with a chess engine's verdict, each side's accuracy, and the key moments the engine picked out. Centipawn loss measures how much worse a move was than the engine's best move.
+
+Explain each key moment in 2-3 sentences: what happened, why it mattered and what the better idea was, naming the engine's move when it preferred another. Name the opening played, keeping the name given below when there is one. Suggest 2-4 concrete study topics drawn from this game's mistakes (e.g. "back-rank weaknesses", "knight forks"), then summarize the game.
+
+Refer to the players as White and Black. Use clear, casual language.
+
+### Result
+%s
+### Opening
+%s
+### Accuracy
+%s
+### Key moments
+%s
+### Moves
+%s
+Respond ONLY with a JSON object matching the schema.`, reportResultText(reportResponse.Result, reason), reportOpeningText(reportResponse.Opening), formatSideReports(reportResponse.White, reportResponse.Black),
+		formatKeyMoments(reportResponse.KeyMoments), formatReviewMoves(moves, nil))
+
+	slog.InfoContext(r.Context(), "Asking the model for a game report", "game_id", game.ID)
+	jsonString, ok := h.generate(ctx, w, h.modelRequest("gameReport", promptText, gameReportResponseSchema))
+	if !ok {
+		return
+	}
+
+	var report gameReport
+	if err := json.Unmarshal([]byte(jsonString), &report); err != nil {
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse game report")
+		return
+	}
+	explanations := make(map[int]string, len(report.KeyMoments))
+	for _, km := range report.KeyMoments {
+		explanations[km.Ply] = km.Explanation
+	}
+	for i := range reportResponse.KeyMoments {
+		reportResponse.KeyMoments[i].Explanation = explanations[reportResponse.KeyMoments[i].Ply]
+	}
+	// The table's name is authoritative; the model only names openings it does not know.
+	if reportResponse.Opening == "" {
+		reportResponse.Opening = report.Opening
+	}
+	reportResponse.Summary = report.Summary
+	if report.StudyTopics != nil {
+		reportResponse.StudyTopics = report.StudyTopics
+	}
+	reportResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, reportResponse)
+}
+
+func reportResultText(result, reason string) string {
+	if result == "" {
+		return "The game is still in progress."
+	}
+	return fmt.Sprintf("%s (by %s)", result, endingName(reason))
+}
+
+func reportOpeningText(name string) string {
+	if name == "" {
+		return "Not in the opening table; name it from the moves."
+	}
+	return name
+}
+
+func formatSideReports(reports ...analysis.SideReport) string {
+	var sb strings.Builder
+	for _, r := range reports {
+		fmt.Fprintf(&sb, "%s: accuracy %.1f%%, average centipawn loss %.0f, %d inaccuracies, %d mistakes, %d blunders\n",
+			r.Side, r.Accuracy, r.AverageLoss, r.Inaccuracies, r.Mistakes, r.Blunders)
+	}
+	return sb.String()
+}
+
+func formatKeyMoments(moments []types.KeyMoment) string {
+	if len(moments) == 0 {
+		return "None: neither side made a significant error.\n"
+	}
+	var sb strings.Builder
+	for _, m := range moments {
+		fmt.Fprintf(&sb, "ply %d: %s (%s) %s, engine preferred %s, position before the move %s\n",
+			m.Ply, m.San, m.Side, m.Classification, m.BestMove, m.FenBefore)
+	}
+	return sb.String()
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/persona"
+	"arnavsurve/nara-chess/server/pkg/session"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsMaxMessageBytes matches the body limit of the JSON endpoints.
+	wsMaxMessageBytes = 1 << 20
+	wsWriteTimeout    = 10 * time.Second
+)
+
+// HandleGameSocket plays a whole game over one WebSocket connection. The client joins a
+// new or stored game and sends its moves; the server keeps the authoritative game in
+// h.Games and answers each move with the coach's move, commentary and arrows. Every
+// connection attached to the same game receives the same updates.
+func (h *Handler) HandleGameSocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || h.Config.Origins.Allowed(origin)
+		},
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already written the error response.
+		slog.WarnContext(r.Context(), "WebSocket upgrade failed", "err", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(wsMaxMessageBytes)
+	// Shutdown ends the game's connection, which ends the read loop below.
+	stopClosing := context.AfterFunc(h.stopping, func() {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(wsWriteTimeout))
+		conn.Close()
+	})
+	defer stopClosing()
+
+	send := func(msg session.ServerMessage) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(msg)
+	}
+	var s *session.Session
+	// Once joined, broadcasts write to conn from other goroutines, so every write goes
+	// through the session, which lets one through at a time.
+	sendError := func(message string) {
+		msg := session.ServerMessage{Type: session.TypeError, Error: message}
+		var err error
+		if s != nil {
+			err = s.Send(msg)
+		} else {
+			err = send(msg)
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), "Error sending WebSocket error", "err", err)
+		}
+	}
+	defer func() {
+		if s != nil {
+			h.Sessions.Leave(s)
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				slog.InfoContext(r.Context(), "WebSocket read error", "err", err)
+			}
+			return
+		}
+
+		var msg session.ClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			sendError("Invalid JSON message")
+			continue
+		}
+
+		switch msg.Type {
+		case session.TypeJoin:
+			if s != nil {
+				sendError("Already joined a game")
+				continue
+			}
+			game, playerSide, err := h.joinGame(r, msg)
+			if err != nil {
+				sendError(err.Error())
+				continue
+			}
+			s = h.Sessions.Join(game.ID, playerSide, send)
+			s.Send(session.ServerMessage{Type: session.TypeState, Game: game, Status: storedGameStatus(game)})
+			h.playCoachTurn(r.Context(), s, game)
+		case session.TypeMove:
+			if s == nil {
+				sendError("Join a game before sending moves")
+				continue
+			}
+			h.playPupilMove(r.Context(), s, msg.Move)
+		case session.TypeTakeback:
+			if s == nil {
+				sendError("Join a game before taking moves back")
+				continue
+			}
+			h.takeBackMoves(r.Context(), s, msg.Plies)
+		default:
+			sendError("Unknown message type")
+		}
+	}
+}
+
+// joinGame resolves a join message, sent on the connection opened with r, to the game it
+// names, creating one when it names none.
+func (h *Handler) joinGame(r *http.Request, msg session.ClientMessage) (*store.Game, string, error) {
+	playerSide := msg.PlayerSide
+	if playerSide == "" {
+		playerSide = "white"
+	}
+	if playerSide != "white" && playerSide != "black" {
+		return nil, "", errors.New("player_side must be 'white' or 'black'")
+	}
+
+	if msg.GameID != "" {
+		game, err := visibleGame(r, h.Games, msg.GameID)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, "", errors.New("Game not found")
+		}
+		return game, playerSide, err
+	}
+
+	if msg.Odds != "" && msg.Fen != "" {
+		return nil, "", errors.New("odds and fen cannot be combined")
+	}
+	initialFen, err := startFEN(msg.Fen, msg.Odds, playerSide)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := chess.ParseVariant(msg.Variant); err != nil {
+		return nil, "", err
+	}
+	if err := persona.Validate(msg.Persona); err != nil {
+		return nil, "", err
+	}
+	if len(msg.UserID) > types.MaxUserIDLength {
+		return nil, "", fmt.Errorf("user_id must be at most %d characters", types.MaxUserIDLength)
+	}
+	userID, err := pupilID(r, msg.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+	pos, err := parseGameFEN(initialFen, msg.Variant)
+	if err != nil {
+		return nil, "", errors.New(types.FENMessage("FEN", err))
+	}
+	game, err := h.Games.Create(store.Game{
+		InitialFen: pos.FEN(),
+		Variant:    storedVariant(pos.Variant),
+		Persona:    msg.Persona,
+		UserID:     userID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating game", "err", err)
+		return nil, "", errors.New("Failed to create game")
+	}
+	return game, playerSide, nil
+}
+
+// playPupilMove applies the pupil's move to the stored game, shares the new state and lets
+// the coach answer.
+func (h *Handler) playPupilMove(ctx context.Context, s *session.Session, move string) {
+	sendError := func(message string) {
+		s.Send(session.ServerMessage{Type: session.TypeError, Error: message})
+	}
+
+	game, err := h.Games.Get(s.GameID)
+	if err != nil {
+		sendError("Game not found")
+		return
+	}
+	if storedGameStatus(game) != nil {
+		sendError("The game is over")
+		return
+	}
+	if sideToMove(game) != s.PlayerSide {
+		sendError("It is not your turn")
+		return
+	}
+
+	game, err = h.Games.Update(game.ID, game.Version, applyMove(move, store.MoveComment{}))
+	if err != nil {
+		if errors.Is(err, store.ErrVersionConflict) {
+			sendError("Game was modified by another connection; wait for its update and retry")
+		} else {
+			sendError("Illegal move: " + move)
+		}
+		return
+	}
+	h.gameUpdated(game)
+
+	h.Sessions.Broadcast(game.ID, session.ServerMessage{Type: session.TypeState, Game: game, Status: storedGameStatus(game)})
+	h.playCoachTurn(ctx, s, game)
+}
+
+// takeBackMoves rewinds the stored game by plies, by default to the pupil's last move,
+// and shares the new state. The coach moves again if the takeback leaves it to move.
+func (h *Handler) takeBackMoves(ctx context.Context, s *session.Session, plies int) {
+	sendError := func(message string) {
+		s.Send(session.ServerMessage{Type: session.TypeError, Error: message})
+	}
+
+	game, err := h.Games.Get(s.GameID)
+	if err != nil {
+		sendError("Game not found")
+		return
+	}
+	if plies == 0 {
+		plies = 1
+		if sideToMove(game) == s.PlayerSide {
+			plies = 2
+		}
+	}
+	if plies < 1 || plies > types.MaxTakebackPlies {
+		sendError(fmt.Sprintf("plies must be between 1 and %d", types.MaxTakebackPlies))
+		return
+	}
+
+	game, err = h.Games.Update(game.ID, game.Version, takeBack(plies))
+	if err != nil {
+		if errors.Is(err, store.ErrVersionConflict) {
+			sendError("Game was modified by another connection; wait for its update and retry")
+		} else {
+			sendError(err.Error())
+		}
+		return
+	}
+
+	h.Sessions.Broadcast(game.ID, session.ServerMessage{Type: session.TypeState, Game: game, Status: storedGameStatus(game)})
+	h.playCoachTurn(ctx, s, game)
+}
+
+// playCoachTurn makes the coach's move when it is the coach's turn in game. The model call
+// is abandoned once parent, the connection's context, is done.
+func (h *Handler) playCoachTurn(parent context.Context, s *session.Session, game *store.Game) {
+	if sideToMove(game) == s.PlayerSide || storedGameStatus(game) != nil {
+		return
+	}
+
+	ctx, cancel := h.modelContext(parent, "generateMove", "")
+	defer cancel()
+
+	reply, err := h.coachMove(ctx, types.GameStateRequest{
+		Fen:         game.Fen,
+		InitialFen:  game.InitialFen,
+		MoveHistory: game.MoveHistory,
+		GameID:      game.ID,
+		Variant:     game.Variant,
+		Persona:     game.Persona,
+	}, false)
+	if err != nil {
+		message := "Internal server error"
+		var he *httpError
+		if errors.As(err, &he) {
+			message = he.message
+		}
+		s.Send(session.ServerMessage{Type: session.TypeError, Error: message})
+		return
+	}
+
+	updated, err := h.Games.Update(game.ID, game.Version, applyMove(reply.Move, store.MoveComment{
+		Comment: reply.Comment,
+		Title:   reply.Title,
+		Arrows:  reply.Arrows,
+	}))
+	if err != nil {
+		slog.ErrorContext(parent, "Error recording coach move in game", "move", reply.Move, "game_id", game.ID, "err", err)
+		s.Send(session.ServerMessage{Type: session.TypeError, Error: "Failed to record the coach's move"})
+		return
+	}
+	h.gameUpdated(updated)
+	reply.Meta = responseMeta(ctx)
+
+	h.Sessions.Broadcast(updated.ID, session.ServerMessage{
+		Type:   session.TypeCoachMove,
+		Game:   updated,
+		Reply:  &reply,
+		Status: storedGameStatus(updated),
+	})
+}
+
+// sideToMove returns "white" or "black" for the side to move in game.
+func sideToMove(game *store.Game) string {
+	pos, err := parseGameFEN(game.Fen, game.Variant)
+	if err != nil || pos.Turn == chess.White {
+		return "white"
+	}
+	return "black"
+}
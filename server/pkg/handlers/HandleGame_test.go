@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/render"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+	"testing"
+)
+
+// newListedGames stores a new game and one after 1. e4 and returns the handler over them.
+func newListedGames(t *testing.T) *Handler {
+	t.Helper()
+	h, _ := newTestHandler()
+	if _, err := h.Games.Create(store.Game{InitialFen: chess.StartFEN}); err != nil {
+		t.Fatal(err)
+	}
+	game, err := h.Games.Create(store.Game{InitialFen: chess.StartFEN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.Games.Update(game.ID, game.Version, func(g *store.Game) error {
+		g.Fen = afterE4
+		g.MoveHistory = append(g.MoveHistory, "e4")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestListGamesThumbnails(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		thumbnails bool
+	}{
+		{"by default", "", false},
+		{"when asked", "?include_thumbnails=true", true},
+		{"when declined", "?include_thumbnails=false", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newListedGames(t)
+			w := serve(h.HandleListGames, http.MethodGet, "/api/games"+tt.query, "")
+			resp := decodeResponse[types.GameListResponse](t, w, http.StatusOK)
+			if len(resp.Games) != 2 {
+				t.Fatalf("listed %d games, want 2", len(resp.Games))
+			}
+
+			for _, g := range resp.Games {
+				if !tt.thumbnails {
+					if g.Thumbnail != "" {
+						t.Errorf("game %s has thumbnail %q", g.GameID, g.Thumbnail)
+					}
+					continue
+				}
+				pos, err := chess.ParseFEN(g.Fen)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := render.BoardString(pos); g.Thumbnail != want {
+					t.Errorf("game %s at %s has thumbnail %q, want %q", g.GameID, g.Fen, g.Thumbnail, want)
+				}
+			}
+		})
+	}
+}
+
+func TestListGamesRejectsBadThumbnailFlag(t *testing.T) {
+	h := newListedGames(t)
+	w := serve(h.HandleListGames, http.MethodGet, "/api/games?include_thumbnails=sometimes", "")
+	resp := decodeResponse[apierror.Response](t, w, http.StatusBadRequest)
+	if resp.Error.Code != apierror.InvalidRequest || resp.Error.Field != "include_thumbnails" {
+		t.Errorf("error = %+v, want include_thumbnails rejected", resp.Error)
+	}
+}
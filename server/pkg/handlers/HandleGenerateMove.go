@@ -1,213 +1,195 @@
 package handlers
 
 import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/tablebase"
 	"arnavsurve/nara-chess/server/pkg/types"
-	"arnavsurve/nara-chess/server/pkg/utils"
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
-	"strings"
+	"slices"
 	"time"
-
-	"github.com/google/generative-ai-go/genai"
-	"google.golang.org/api/option"
 )
 
-func HandleGenerateMove(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+func (h *Handler) HandleGenerateMove(w http.ResponseWriter, r *http.Request) {
+	gameStateRequest, ok := decodeGameRequest[types.GameStateRequest](h, w, r)
+	if !ok {
 		return
 	}
 
-	var gameStateRequest types.GameStateRequest
-
-	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // Limit body size to 1MB
-
-	decoder := json.NewDecoder(r.Body)
-	decoder.DisallowUnknownFields()
+	ctx, cancel := h.requestContext(r, "generateMove")
+	defer cancel()
 
-	err := decoder.Decode(&gameStateRequest)
+	gameStateResponse, err := h.coachMove(ctx, gameStateRequest, true)
 	if err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-
-	if len(gameStateRequest.MoveHistory) == 0 && gameStateRequest.Fen == "" {
-		http.Error(w, "Request must contain either move_history or fen", http.StatusBadRequest)
-		return
-	}
-	if gameStateRequest.Fen == "" {
-		http.Error(w, "Request must contain the current board state FEN (fen field)", http.StatusBadRequest)
-		return
-	}
-	var wrongMove string
-	if gameStateRequest.WrongMove != "" {
-		wrongMove = fmt.Sprintf("\n\nHere, %s is an INVALID MOVE. Do not use this in your response.", gameStateRequest.WrongMove)
+	if gameStateResponse.Meta == nil {
+		gameStateResponse.Meta = responseMeta(ctx)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second) // 60 second timeout
-	defer cancel()
+	writeJSON(w, gameStateResponse)
 
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		log.Println("ERROR: GEMINI_API_KEY environment variable not set.")
-		http.Error(w, "Server configuration error", http.StatusInternalServerError)
-		return
-	}
+	slog.InfoContext(r.Context(), "Suggested coach move", "move", gameStateResponse.Move)
+}
 
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+// coachMove produces the coach's reply to the position in gameStateRequest, playing
+// forced moves locally and otherwise asking the model, which in hybrid mode only explains
+// the external engine's move. With useCache the model's reply is served from and added to
+// MoveCache, keyed by the position the history reaches; a reply served from it has Meta
+// set. Errors are *httpError values.
+func (h *Handler) coachMove(ctx context.Context, gameStateRequest types.GameStateRequest, useCache bool) (types.GameStateResponse, error) {
+	fen, sideWarning, err := h.authoritativeFen(gameStateRequest.Variant, gameStateRequest.InitialFen, gameStateRequest.Fen, gameStateRequest.MoveHistory)
 	if err != nil {
-		log.Printf("Error creating Gemini client: %v", err)
-		http.Error(w, "Failed to initialize analysis service", http.StatusInternalServerError)
-		return
+		return types.GameStateResponse{}, err
 	}
-	defer client.Close()
-
-	model := client.GenerativeModel("gemini-2.5-pro-exp-03-25")
-
-	gameStateResponseSchema := &genai.Schema{
-		Type:        genai.TypeObject,
-		Description: "Response containing commentary on the chess game state and next move.",
-		Properties: map[string]*genai.Schema{
-			"comment": {
-				Type:        genai.TypeString,
-				Description: "A brief commentary (1-3 sentences) on the current game situation, evaluating the state of the game for black and white. Include coaching information here.",
-			},
-			"move": {
-				Type:        genai.TypeString,
-				Description: "The move you would like to make in Standard Algebraic Notation (SAN), e.g., 'Nf3', 'O-O', 'e8=Q+'.",
-			},
-			"arrows": {
-				Type:        genai.TypeArray,
-				Description: "Optional coaching arrows to display. Each is a tuple of two square strings (from, to). Used to show threats, good ideas, plans, etc.",
-				Items: &genai.Schema{
-					Type: genai.TypeArray,
-					Items: &genai.Schema{
-						Type: genai.TypeString,
-					},
-				},
-			},
-			"title": {
-				Type:        genai.TypeString,
-				Description: "A short phrase to describe the current game.",
-			},
-		},
-		Required: []string{"comment", "move"},
-	}
-
-	model.GenerationConfig = genai.GenerationConfig{
-		ResponseMIMEType: "application/json",
-		ResponseSchema:   gameStateResponseSchema,
-		Temperature:      utils.PtrFloat32(0.4),
-	}
-
-	moveHistoryStr := strings.Join(gameStateRequest.MoveHistory, " ")
-
-	llmSide, pupilSide, err := utils.InferSidesFromFEN(gameStateRequest.Fen)
-	if err != nil {
-		log.Printf("Error parsing FEN for side inference: %v", err)
-		http.Error(w, "Invalid FEN", http.StatusBadRequest)
+	if sideWarning != "" {
+		slog.WarnContext(ctx, sideWarning)
 	}
+	gameStateRequest.Fen = fen
+	pupilMove := gradePupilMove(gameStateRequest.Variant, gameStateRequest.InitialFen, gameStateRequest.MoveHistory)
 
-	promptText := fmt.Sprintf(`You are a strong chess engine, commentator, and coach in an ongoing educational match against your pupil.
-
-You are playing as %s.  
-Your pupil is playing as %s.  
-It is currently your turn to move — your pupil just made the last move.  
-
-You must:
-1. Select the best next move for your side (%s) using strong chess principles.
-2. Evaluate the position for both sides — from your pupil’s perspective.
-3. Provide insightful, constructive feedback that helps your pupil improve.
-
-In your response:
-- Identify specific positional features (e.g., weak squares, piece activity, king safety, space, pawn structure).
-- **Explain the ideas behind your move and how it fits into a short-term or long-term plan.**
-- Mention any **good ideas** or **mistakes** your pupil made in their last move or overall game direction.
-- **Offer a brief tactical or strategic concept they could focus on (e.g., "look for pins", "consider open files", "avoid weakening squares like f3").**
-- **Relate their move to classical principles or named openings if appropriate (e.g., “this is common in the Italian Game”)**.
-- Use clear and simple language and talk in a casual tone, minimizing filler language. Be direct in your communication.
-- Think deeply when formulating your response to provide appropriate coaching based on the opponent's estimated skill level and bringing up interesting lines or characteristics of the game state.
-
-- If useful, include a list of 1–3 arrows that would help the pupil visualize the plan, threats, or key ideas on the board. ENSURE YOU ELABORATE ON THE MOVES THAT THESE ARROWS DESCRIBE. Only use arrows to help illustrate your description of *future moves*, threats, or key ideas. Do not use arrows without already having described the scenario for that arrow. Do not use an arrow to indicate a move that you or the player has made already or is currently making.
-- Use the format: ["from-square", "to-square"] — for example: ["e4", "e5"] to suggest a pawn push.
-- These arrows are used to help the user *learn*, so show things like threats, weak squares, tactical ideas, or developing moves that may be applicable to either side.
-- DO NOT use arrows unless the game's position ABSOLUTELY NECESSITATES an opportunity for in depth analysis. For textbook positions or early game, DO NOT RETURN ANY ARROWS.
+	// authoritativeFen has already rejected malformed FENs.
+	pos, _ := parseGameFEN(gameStateRequest.Fen, gameStateRequest.Variant)
+	positions := gamePositions(gameStateRequest.InitialFen, gameStateRequest.MoveHistory, pos)
 
+	if pos != nil {
+		if status := chess.GameStatus(positions); status.Over {
+			slog.InfoContext(ctx, "Game is over, summing up instead of moving", "reason", status.Reason)
+			gameStateResponse := h.gameOverReply(ctx, gameStateRequest, pos, status)
+			return finishCoachMove(gameStateRequest, positions, gameStateResponse, sideWarning, pupilMove), nil
+		}
+		if m, comment, ok := forcedMove(pos, gameStateRequest.MoveHistory); ok {
+			gameStateResponse := types.GameStateResponse{
+				Comment: comment,
+				Move:    pos.SAN(m),
+				Arrows:  [][2]string{},
+				Source:  SourceForced,
+			}
+			slog.InfoContext(ctx, "Played forced move locally", "move", gameStateResponse.Move)
+			return finishCoachMove(gameStateRequest, positions, gameStateResponse, sideWarning, pupilMove), nil
+		}
+	}
 
-**Pronoun usage rules**:
-- Refer to yourself as “I” and to the pupil as “you”.
-- Do **not** use “we”, “us”, or “our”.
-
-FEN: %s  
-Move History: %s
-Chat History: %s
-
-Output your response **strictly** as a JSON object matching this schema:
+	var gameStateResponse types.GameStateResponse
+	opts := h.coachOptions()
+	opts.Tablebase = h.probeTablebase(ctx, pos)
+	opts.Explorer = h.explorerStats(ctx, pos)
+	opts.TakenBack = h.recentTakeback(gameStateRequest)
+	opts.Profile = h.pupilProfile(gameStateRequest)
+
+	// A reply owed an acknowledgement of a takeback or a wrong move is not served from or
+	// added to the cache. Cached replies hold only what follows from the position; the rest
+	// is worked out from each request's history by finishCoachMove.
+	var cacheKey string
+	if useCache && pos != nil && gameStateRequest.WrongMove == "" && opts.TakenBack == nil {
+		cacheKey = moveCacheKey(pos, gameStateRequest, opts.Profile)
+		if cached, ok := h.MoveCache.Get(cacheKey); ok {
+			slog.InfoContext(ctx, "Served coach move from cache", "move", cached.Move)
+			cached.Meta = &types.ResponseMeta{CacheHit: true}
+			return finishCoachMove(gameStateRequest, positions, cached, sideWarning, pupilMove), nil
+		}
+	}
+	elo, _ := gameStateRequest.Difficulty.Elo()
+	engineMove, hybrid := h.hybridMove(ctx, pos, elo)
+	if hybrid {
+		gameStateResponse, err = h.Coach.ExplainMove(ctx, gameStateRequest, pos.SAN(engineMove), opts)
+		gameStateResponse.Source = SourceHybrid
+	} else {
+		gameStateResponse, err = h.Coach.GenerateCoachMove(ctx, gameStateRequest, opts)
+		gameStateResponse.Source = SourceModel
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, ai.ErrCircuitOpen) && pos != nil:
+		m, ok := engineMove, hybrid
+		if !ok {
+			m, ok = fallbackMove(pos)
+		}
+		if !ok {
+			return types.GameStateResponse{}, modelError(err)
+		}
+		slog.WarnContext(ctx, "Analysis service unavailable, playing engine move", "move", pos.SAN(m))
+		gameStateResponse = types.GameStateResponse{
+			Comment:  "My coaching notes are unavailable for a moment, so I'm playing the engine's choice here.",
+			Move:     pos.SAN(m),
+			Arrows:   [][2]string{},
+			Source:   SourceEngine,
+			Warnings: []string{"analysis service unavailable; move chosen by the local engine"},
+		}
+	default:
+		return types.GameStateResponse{}, modelError(err)
+	}
+	// Engine stand-ins are not cached so the coach answers once the model recovers.
+	if cacheKey != "" && gameStateResponse.Source != SourceEngine {
+		h.MoveCache.Add(cacheKey, gameStateResponse)
+	}
 
-{
-  "comment": "...", // Constructive coaching commentary (1–3 sentences)
-  "move": "..."     // Your move in SAN (e.g., "Nf3", "O-O", "e8=Q+")
-  "arrows": [["e4", "e5"], ["g1", "f3"]]
-  "title": "Italian Game, Hectic Endgame, King's Gambit, Unique Opening"
+	return finishCoachMove(gameStateRequest, positions, gameStateResponse, sideWarning, pupilMove), nil
 }
 
-Do NOT include anything outside the JSON object.`, llmSide, pupilSide, llmSide, gameStateRequest.Fen, moveHistoryStr, gameStateRequest.ChatHistory)
-	fmt.Println(promptText)
-	prompt := genai.Text(promptText + wrongMove)
-
-	log.Printf("Sending request to Gemini for move suggestion. FEN: %s", gameStateRequest.Fen)
-	resp, err := model.GenerateContent(ctx, prompt)
-	if err != nil {
-		log.Printf("Error generating content from Gemini: %v", err)
-		if errors.Is(err, context.DeadlineExceeded) {
-			http.Error(w, "Analysis request timed out", http.StatusGatewayTimeout)
-		} else {
-			http.Error(w, "Failed to get move suggestion from service", http.StatusInternalServerError)
+// finishCoachMove attaches the side-to-move warning, the grade of the pupil's move, the
+// pupil's mate hint, the end of the game if the coach's move ends it and the opening
+// reached with the coach's move to a reply. positions is the game before the coach's move.
+func finishCoachMove(req types.GameStateRequest, positions []*chess.Position, resp types.GameStateResponse, sideWarning string, pupilMove *analysis.MoveAnalysis) types.GameStateResponse {
+	resp.PupilMove = pupilMove
+	if sideWarning != "" {
+		resp.Warnings = append(resp.Warnings, sideWarning)
+	}
+	history := req.MoveHistory
+	if pos := positions[len(positions)-1]; pos != nil {
+		if m, err := pos.ParseSAN(resp.Move); err == nil {
+			next := pos.Play(m)
+			resp.PupilMate = findMateHint(next, false)
+			history = append(slices.Clip(history), resp.Move)
+			if status := chess.GameStatus(append(slices.Clip(positions), next)); status.Over {
+				resp.GameOver = newGameOver(status, next.Turn)
+			}
 		}
-		return
 	}
-
-	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
-		log.Printf("Error: Received empty or invalid response structure from Gemini. Response: %+v", resp)
-		http.Error(w, "Received empty analysis response", http.StatusInternalServerError)
-		return
+	if opening, ok := classifyOpening(req.InitialFen, req.Variant, history); ok {
+		resp.ECO, resp.OpeningName = opening.ECO, opening.Name
 	}
+	return resp
+}
 
-	jsonPart := resp.Candidates[0].Content.Parts[0]
-	jsonString, ok := jsonPart.(genai.Text)
-	if !ok {
-		log.Printf("Error: Expected response part to be genai.Text, but got %T. Content: %+v", jsonPart, jsonPart)
-		http.Error(w, "Received unexpected analysis format from service", http.StatusInternalServerError)
-		return
+// hybridMove asks Handler.Engine for the coach's move at the requested strength when
+// hybrid mode is on. The engine plays standard chess, so variants are left to the model.
+// Engine failures are logged and leave the choice to the model.
+func (h *Handler) hybridMove(ctx context.Context, pos *chess.Position, elo int) (chess.Move, bool) {
+	if !h.Config.HybridMoves || h.Engine == nil || pos == nil || pos.Variant != chess.Standard {
+		return chess.Move{}, false
 	}
-
-	log.Printf("Raw JSON received from Gemini: %s", jsonString)
-
-	var gameStateResponse types.GameStateResponse
-	err = json.Unmarshal([]byte(jsonString), &gameStateResponse)
+	m, err := h.Engine.BestMove(ctx, pos, elo)
 	if err != nil {
-		log.Printf("Error unmarshalling Gemini JSON response: %v\nRaw JSON was: %s", err, jsonString)
-		http.Error(w, "Failed to parse move suggestion", http.StatusInternalServerError)
-		return
+		slog.WarnContext(ctx, "Engine move selection failed, falling back to the model", "err", err)
+		return chess.Move{}, false
 	}
+	return m, true
+}
 
-	if gameStateResponse.Move == "" {
-		log.Printf("Warning: Gemini returned JSON but the 'move' field was empty. Raw: %s", jsonString)
-		http.Error(w, "Analysis service failed to provide a move", http.StatusInternalServerError)
-		return
-	}
+// tablebaseTimeout bounds a tablebase lookup, which only improves the coach's reply.
+const tablebaseTimeout = 3 * time.Second
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(gameStateResponse)
+// probeTablebase looks pos up in Handler.Tablebase when it has few enough pieces. Lookup
+// failures are logged and leave the coach to its own judgement.
+func (h *Handler) probeTablebase(ctx context.Context, pos *chess.Position) *tablebase.Result {
+	if h.Tablebase == nil || pos == nil || !tablebase.Covered(pos) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, tablebaseTimeout)
+	defer cancel()
+	r, err := h.Tablebase.Probe(ctx, pos)
 	if err != nil {
-		log.Printf("Error encoding JSON response for client: %v", err)
+		if !errors.Is(err, tablebase.ErrNotCovered) {
+			slog.WarnContext(ctx, "Tablebase lookup failed", "err", err)
+		}
+		return nil
 	}
-
-	log.Printf("Successfully processed request. Suggested move: %s", gameStateResponse.Move)
+	slog.InfoContext(ctx, "Tablebase result for the side to move", "category", r.Category)
+	return &r
 }
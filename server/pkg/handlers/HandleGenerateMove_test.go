@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func coachReply(move string) string {
+	return `{"comment": "Your move.", "move": "` + move + `", "arrows": [], "title": "Open Game"}`
+}
+
+// Retries after illegal moves are reported in the response's model calls.
+func TestGenerateMoveReportsModelCalls(t *testing.T) {
+	h, provider := newTestHandler(coachReply("Ke3"), coachReply("e5"))
+	body := `{"fen": "` + afterE4 + `", "initial_fen": "", "move_history": ["e4"]}`
+	w := serve(h.HandleGenerateMove, http.MethodPost, "/api/generateMove", body)
+	resp := decodeResponse[types.GameStateResponse](t, w, http.StatusOK)
+
+	if resp.Move != "e5" {
+		t.Errorf("move = %s, want e5", resp.Move)
+	}
+	if resp.Meta == nil || resp.Meta.ModelCalls != 2 || len(provider.requests) != 2 {
+		t.Errorf("meta = %+v after %d model calls, want 2 reported", resp.Meta, len(provider.requests))
+	}
+}
+
+func TestGenerateMoveBudgetCapsCalls(t *testing.T) {
+	h, provider := newTestHandler(coachReply("Ke3"), coachReply("Ke2"), coachReply("Kd3"), coachReply("e5"))
+	h.Config.MaxModelCalls = 2
+	h.Config.MaxMoveAttempts = 5
+	body := `{"fen": "` + afterE4 + `", "move_history": ["e4"]}`
+	w := serve(h.HandleGenerateMove, http.MethodPost, "/api/generateMove", body)
+
+	if len(provider.requests) != 2 {
+		t.Errorf("made %d model calls, want MaxModelCalls (2)", len(provider.requests))
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d (%s), want 500 once the budget is spent", w.Code, w.Body)
+	}
+}
+
+// circuitOpenProvider stands in for a provider behind an open circuit breaker.
+type circuitOpenProvider struct{}
+
+func (circuitOpenProvider) GenerateJSON(ctx context.Context, req ai.Request) (string, error) {
+	return "", ai.ErrCircuitOpen
+}
+
+// With the breaker open the local engine plays instead, and its move isn't cached.
+func TestGenerateMoveCircuitOpenFallsBack(t *testing.T) {
+	h := New(circuitOpenProvider{}, store.NewMemoryStore(), DefaultConfig())
+	pos, err := chess.ParseFEN(afterE4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"fen": "` + afterE4 + `", "move_history": ["e4"]}`
+	for range 2 {
+		w := serve(h.HandleGenerateMove, http.MethodPost, "/api/generateMove", body)
+		resp := decodeResponse[types.GameStateResponse](t, w, http.StatusOK)
+
+		if resp.Source != SourceEngine || len(resp.Warnings) == 0 {
+			t.Errorf("reply from %q with warnings %v, want the engine's", resp.Source, resp.Warnings)
+		}
+		if _, err := pos.ParseSAN(resp.Move); err != nil {
+			t.Errorf("engine move %s is illegal: %v", resp.Move, err)
+		}
+		if resp.Meta != nil && resp.Meta.CacheHit {
+			t.Error("engine move was served from the cache")
+		}
+	}
+}
+
+// A reply written with one pupil's profile in its prompt is never served to another pupil
+// in the same position.
+func TestGenerateMoveCachesPerPupil(t *testing.T) {
+	h, provider := newTestHandler(coachReply("e5"), coachReply("c5"), coachReply("e6"))
+	profiles := map[string]string{"alice": analysis.MotifHangingPiece, "bob": analysis.MotifMissedMate}
+	gameIDs := map[string]string{}
+	for user, theme := range profiles {
+		if _, err := h.Profiles.UpdateProfile(user, func(p *store.Profile) error {
+			p.Games, p.Themes[theme] = 3, 2
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		game, err := h.Games.Create(store.Game{InitialFen: chess.StartFEN, UserID: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := h.Games.Update(game.ID, game.Version, func(g *store.Game) error {
+			g.MoveHistory, g.Fen = []string{"e4"}, afterE4
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		gameIDs[user] = game.ID
+	}
+
+	generate := func(gameID string) types.GameStateResponse {
+		t.Helper()
+		body := `{"game_id": "` + gameID + `"}`
+		if gameID == "" {
+			body = `{"fen": "` + afterE4 + `", "move_history": ["e4"]}`
+		}
+		w := serve(h.HandleGenerateMove, http.MethodPost, "/api/generateMove", body)
+		return decodeResponse[types.GameStateResponse](t, w, http.StatusOK)
+	}
+
+	alice := generate(gameIDs["alice"])
+	bob := generate(gameIDs["bob"])
+	if bob.Meta != nil && bob.Meta.CacheHit {
+		t.Fatal("bob was served the reply written for alice")
+	}
+	if alice.Move != "e5" || bob.Move != "c5" {
+		t.Errorf("replies = %s, %s; want each pupil their own", alice.Move, bob.Move)
+	}
+	if !strings.Contains(provider.requests[0].Prompt, "leaving pieces undefended") || !strings.Contains(provider.requests[1].Prompt, "missing mates") {
+		t.Error("the prompts did not carry each pupil's own profile")
+	}
+
+	// Each pupil's reply is cached for them alone, and a request without a pupil has its
+	// own entry too.
+	if again := generate(gameIDs["alice"]); again.Meta == nil || !again.Meta.CacheHit || again.Move != "e5" {
+		t.Errorf("alice's second request = %+v, want her cached reply", again)
+	}
+	if anonymous := generate(""); anonymous.Meta != nil && anonymous.Meta.CacheHit {
+		t.Error("a request without a pupil was served a profiled reply")
+	}
+	if len(provider.requests) != 3 {
+		t.Errorf("made %d model calls, want 3", len(provider.requests))
+	}
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/buildinfo"
+	"arnavsurve/nara-chess/server/pkg/diagnostics"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// providerPingTimeout bounds /health/providers so a hung provider can't stall probes.
+const providerPingTimeout = 10 * time.Second
+
+// readyTimeout bounds /readyz's checks, well inside an orchestrator's probe timeout.
+const readyTimeout = 5 * time.Second
+
+// providerReadyTTL is how long /readyz trusts the providers' last health check. Probes
+// come every few seconds, and each ping is a request to the provider.
+const providerReadyTTL = time.Minute
+
+// RunSelfTest runs the move generator self-test and keeps the report for /health.
+func (h *Handler) RunSelfTest() diagnostics.Report {
+	report := diagnostics.Run()
+	if !report.Passed {
+		for _, c := range report.Checks {
+			if !c.Passed {
+				slog.Error("Engine self-test failed", "check", c.Name, "detail", c.Detail)
+			}
+		}
+	}
+
+	h.selfTestMu.Lock()
+	h.selfTest = &report
+	h.selfTestMu.Unlock()
+	return report
+}
+
+// HandleHealth reports the most recent engine self-test, answering 503 when it failed or
+// has not run.
+func (h *Handler) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	h.selfTestMu.Lock()
+	report := h.selfTest
+	h.selfTestMu.Unlock()
+
+	if report == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Engine self-test has not run")
+		return
+	}
+	writeReport(w, *report)
+}
+
+// providerChecker is implemented by an llm.Router.
+type providerChecker interface {
+	Health(ctx context.Context) map[string]error
+}
+
+// HandleProviderHealth pings the model providers that support it without generating
+// anything, answering 503 when none of them is healthy.
+func (h *Handler) HandleProviderHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), providerPingTimeout)
+	defer cancel()
+	health := h.providerHealth(ctx)
+
+	names := make([]string, 0, len(health))
+	for name := range health {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	response := types.ProviderHealthResponse{Providers: []types.ProviderHealth{}}
+	healthy := false
+	for _, name := range names {
+		ph := types.ProviderHealth{Provider: name, Healthy: health[name] == nil}
+		if err := health[name]; err != nil {
+			ph.Error = err.Error()
+		} else {
+			healthy = true
+		}
+		response.Providers = append(response.Providers, ph)
+	}
+
+	if !healthy && len(names) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.ErrorContext(r.Context(), "Error encoding JSON response for client", "err", err)
+		}
+		return
+	}
+	writeJSON(w, response)
+}
+
+// providerHealth pings the model providers that support it, mapping their names to the
+// results.
+func (h *Handler) providerHealth(ctx context.Context) map[string]error {
+	health := map[string]error{}
+	if c, ok := h.AI.(providerChecker); ok {
+		health = c.Health(ctx)
+	} else if err := ai.Ping(ctx, h.AI); !errors.Is(err, ai.ErrPingUnsupported) {
+		health["default"] = err
+	}
+	return health
+}
+
+// HandleLiveness answers as long as the process is serving requests.
+func (h *Handler) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, types.LivenessResponse{Status: "ok"})
+}
+
+// storePinger is implemented by stores behind a connection, such as *store.SQLiteStore.
+type storePinger interface {
+	Ping(ctx context.Context) error
+}
+
+// engineChecker is implemented by *engine.UCI.
+type engineChecker interface {
+	Alive() error
+}
+
+// HandleReadiness reports whether the server can do its work: the store is reachable, a
+// model provider accepts its key, the UCI engine is running when one is configured and
+// the engine self-test passed. It answers 503 when any check fails.
+func (h *Handler) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	response := types.ReadinessResponse{Ready: true}
+	check := func(name string, err error) {
+		c := types.ReadinessCheck{Name: name, Ready: err == nil}
+		if err != nil {
+			c.Error = err.Error()
+			response.Ready = false
+		}
+		response.Checks = append(response.Checks, c)
+	}
+	if p, ok := h.Games.(storePinger); ok {
+		check("store", p.Ping(ctx))
+	}
+	check("providers", h.providersReadiness(ctx))
+	if e, ok := h.Engine.(engineChecker); ok {
+		check("engine", e.Alive())
+	}
+	h.selfTestMu.Lock()
+	report := h.selfTest
+	h.selfTestMu.Unlock()
+	switch {
+	case report == nil:
+		check("selfTest", errors.New("engine self-test has not run"))
+	case !report.Passed:
+		check("selfTest", errors.New("engine self-test failed"))
+	default:
+		check("selfTest", nil)
+	}
+
+	if !response.Ready {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.ErrorContext(r.Context(), "Error encoding JSON response for client", "err", err)
+		}
+		return
+	}
+	writeJSON(w, response)
+}
+
+// providersReadiness returns nil when at least one model provider passed its health
+// check, or none has one, re-checking at most every providerReadyTTL.
+func (h *Handler) providersReadiness(ctx context.Context) error {
+	h.readyMu.Lock()
+	defer h.readyMu.Unlock()
+	if !h.providersCheckedAt.IsZero() && time.Since(h.providersCheckedAt) < providerReadyTTL {
+		return h.providersReady
+	}
+
+	health := h.providerHealth(ctx)
+	var errs []error
+	for name, err := range health {
+		if err == nil {
+			errs = nil
+			break
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+	if ctx.Err() != nil {
+		// The probe gave up, which says nothing about the providers.
+		return errors.Join(errs...)
+	}
+	h.providersReady, h.providersCheckedAt = errors.Join(errs...), time.Now()
+	return h.providersReady
+}
+
+// HandleVersion reports the commit the server was built from and when.
+func (h *Handler) HandleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, buildinfo.Read())
+}
+
+// HandleSelfTest re-runs the engine self-test on demand. It is mounted behind admin auth.
+func (h *Handler) HandleSelfTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	writeReport(w, h.RunSelfTest())
+}
+
+func writeReport(w http.ResponseWriter, report diagnostics.Report) {
+	if report.Passed {
+		writeJSON(w, report)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		slog.Error("Error encoding JSON response for client", "err", err)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/auth"
+	"arnavsurve/nara-chess/server/pkg/diagnostics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthReportsSelfTest(t *testing.T) {
+	h, _ := newTestHandler()
+
+	// Until the self-test has run the server is not healthy.
+	w := serve(h.HandleHealth, http.MethodGet, "/api/health", "")
+	if resp := decodeResponse[apierror.Response](t, w, http.StatusServiceUnavailable); resp.Error.Code != apierror.Unavailable {
+		t.Errorf("error = %+v before the self-test", resp.Error)
+	}
+
+	if report := h.RunSelfTest(); !report.Passed {
+		t.Fatalf("self-test failed: %+v", report.Checks)
+	}
+	w = serve(h.HandleHealth, http.MethodGet, "/api/health", "")
+	if report := decodeResponse[diagnostics.Report](t, w, http.StatusOK); !report.Passed || len(report.Checks) == 0 {
+		t.Errorf("health = %+v, want the passing report", report)
+	}
+
+	// A failed report answers 503 with the checks.
+	h.selfTest = &diagnostics.Report{Checks: []diagnostics.Check{{Name: "perft start position", Detail: "depth 3: got 1 nodes, want 8902"}}}
+	w = serve(h.HandleHealth, http.MethodGet, "/api/health", "")
+	if report := decodeResponse[diagnostics.Report](t, w, http.StatusServiceUnavailable); report.Passed || len(report.Checks) != 1 {
+		t.Errorf("health = %+v, want the failing report", report)
+	}
+}
+
+func TestSelfTestRequiresAdmin(t *testing.T) {
+	h, _ := newTestHandler()
+	handler := auth.RequireAdmin([]string{"secret"}, http.HandlerFunc(h.HandleSelfTest)).ServeHTTP
+
+	w := serve(handler, http.MethodPost, "/api/health/selfTest", "")
+	if resp := decodeResponse[apierror.Response](t, w, http.StatusForbidden); resp.Error.Code != apierror.Forbidden {
+		t.Errorf("error = %+v without the admin key", resp.Error)
+	}
+	if h.selfTest != nil {
+		t.Error("self-test ran without the admin key")
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/health/selfTest", nil)
+	r.Header.Set(auth.AdminKeyHeader, "secret")
+	handler(w, r)
+	if report := decodeResponse[diagnostics.Report](t, w, http.StatusOK); !report.Passed {
+		t.Errorf("self-test = %+v", report)
+	}
+	if h.selfTest == nil {
+		t.Error("the on-demand run was not kept for /health")
+	}
+}
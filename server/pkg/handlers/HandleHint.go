@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// hintLineLength is how many plies of the engine's line the coach sees when explaining
+// the best move.
+const hintLineLength = 4
+
+var hintResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "A hint toward the best move in a chess position.",
+	Properties: map[string]*genai.Schema{
+		"hint": {
+			Type:        genai.TypeString,
+			Description: "The hint, in one or two sentences.",
+		},
+	},
+	Required: []string{"hint"},
+}
+
+type hintText struct {
+	Hint string `json:"hint"`
+}
+
+// bestHintMove is the move a hint leads to: the engine's best move, or the first move of a
+// forced mate when the search missed one, with the line that follows it.
+type bestHintMove struct {
+	Move  chess.Move
+	Line  []string // SAN, starting with Move
+	Score int      // centipawns for the side to move
+	Mate  *engine.Mate
+}
+
+func findBestHintMove(pos *chess.Position) bestHintMove {
+	result := engine.Search(pos, types.DefaultSearchDepth)
+	best := bestHintMove{Move: result.Move, Score: result.Score}
+	pv := result.PV
+	if mate, ok := engine.FindMate(pos, pupilMateMoves); ok {
+		best.Mate = &mate
+		if mate.Move != result.Move {
+			best.Move, pv = mate.Move, []chess.Move{mate.Move}
+		}
+	}
+	best.Line = sanLine(pos, pv, hintLineLength)
+	return best
+}
+
+// hintTheme names what kind of move the best move is without giving it away.
+func hintTheme(pos *chess.Position, best bestHintMove) string {
+	switch {
+	case best.Mate != nil && best.Mate.In == 1:
+		return "checkmate in one"
+	case best.Mate != nil:
+		return fmt.Sprintf("a forced mate in %d", best.Mate.In)
+	case pos.InCheck():
+		return "getting out of check"
+	case best.Move.Promotion != chess.NoPieceType:
+		return "promoting a pawn"
+	case pos.IsCapture(best.Move):
+		return "a capture"
+	case pos.CheckInfo(best.Move).Check:
+		return "a check"
+	}
+	return "a quiet improving move"
+}
+
+// hintLeak returns the part of the answer a hint below HintLevelMove gives away, or "".
+// A theme hint may not name the moving piece's square either.
+func hintLeak(hint string, level int, san string, move chess.Move) string {
+	giveaways := []string{strings.TrimRight(san, "+#"), move.To.String()}
+	if level == types.HintLevelTheme {
+		giveaways = append(giveaways, move.From.String())
+	}
+	for _, g := range giveaways {
+		if strings.Contains(hint, g) {
+			return g
+		}
+	}
+	return ""
+}
+
+// fallbackHint is the plain hint used when the coach keeps giving too much away.
+func fallbackHint(resp types.HintResponse) string {
+	switch resp.Level {
+	case types.HintLevelTheme:
+		return fmt.Sprintf("Look for %s.", resp.Theme)
+	case types.HintLevelPiece:
+		return fmt.Sprintf("Look at your %s on %s.", resp.Piece, resp.Square)
+	}
+	return fmt.Sprintf("Play %s.", resp.Move)
+}
+
+// HandleHint gives the pupil a hint toward the best move at the requested level. The move
+// is found by the local engine and the coach only puts it into words, so the hint always
+// points at a sound move.
+func (h *Handler) HandleHint(w http.ResponseWriter, r *http.Request) {
+	hintRequest, ok := decodeGameRequest[types.HintRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	pos, err := parseGameFEN(hintRequest.Fen, hintRequest.Variant)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+	if len(pos.LegalMoves()) == 0 || wonOnHill(pos) {
+		apierror.Write(w, http.StatusBadRequest, apierror.GameOver, "The game is over in this position")
+		return
+	}
+
+	best := findBestHintMove(pos)
+	san := pos.SAN(best.Move)
+	hintResponse := types.HintResponse{Level: hintRequest.Level, Theme: hintTheme(pos, best)}
+	if hintRequest.Level >= types.HintLevelPiece {
+		hintResponse.Piece = pos.Board[best.Move.From].Type().Name()
+		hintResponse.Square = best.Move.From.String()
+	}
+	if hintRequest.Level == types.HintLevelMove {
+		hintResponse.Move = san
+		hintResponse.Uci = best.Move.String()
+		hintResponse.Arrows = [][2]string{{best.Move.From.String(), best.Move.To.String()}}
+	}
+
+	var task string
+	switch hintRequest.Level {
+	case types.HintLevelTheme:
+		task = "Give a gentle nudge about the theme only. Do not name the piece to move, any square it moves from or to, or the move."
+	case types.HintLevelPiece:
+		task = fmt.Sprintf("Tell the pupil to look at their %s on %s and why it matters, without saying where it goes or writing the move.", hintResponse.Piece, hintResponse.Square)
+	default:
+		task = fmt.Sprintf("Tell the pupil to play %s and explain in plain language why it is the best move, using the expected line.", san)
+	}
+
+	ctx, cancel := h.requestContext(r, "hint")
+	defer cancel()
+
+	prompt := fmt.Sprintf(`You are a patient chess coach giving the pupil a hint. The pupil is %s to move.
+
+FEN: %s
+Best move (found by an engine, do not second-guess it): %s
+Expected line: %s
+Evaluation for the pupil: %s
+Theme: %s
+
+%s
+
+Refer to the pupil as "you". Keep it to one or two sentences in clear, casual language.
+
+Respond ONLY with a JSON object matching the schema.`, pos.Turn, pos.FEN(), san, strings.Join(best.Line, " "),
+		engine.FormatScore(best.Score), hintResponse.Theme, task)
+
+	for {
+		jsonString, ok := h.generate(ctx, w, h.modelRequest("hint", prompt, hintResponseSchema))
+		if !ok {
+			return
+		}
+		var text hintText
+		if err := json.Unmarshal([]byte(jsonString), &text); err != nil {
+			slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+			slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse hint")
+			return
+		}
+
+		leak := ""
+		if hintRequest.Level < types.HintLevelMove {
+			leak = hintLeak(text.Hint, hintRequest.Level, san, best.Move)
+		}
+		if leak == "" && strings.TrimSpace(text.Hint) != "" {
+			hintResponse.Hint = text.Hint
+			break
+		}
+		if !ai.BudgetFrom(ctx).Remaining() {
+			slog.WarnContext(r.Context(), "Falling back to a plain hint", "level", hintRequest.Level)
+			hintResponse.Hint = fallbackHint(hintResponse)
+			break
+		}
+		if leak == "" {
+			slog.InfoContext(r.Context(), "Rejecting empty hint, regenerating")
+			prompt += "\n\nYour previous hint was empty."
+			continue
+		}
+		slog.InfoContext(r.Context(), "Rejecting hint that gives the answer away, regenerating", "level", hintRequest.Level, "leak", leak)
+		prompt += fmt.Sprintf("\n\nYour previous hint was rejected because it gave away %q. Give less away.", leak)
+	}
+
+	hintResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, hintResponse)
+}
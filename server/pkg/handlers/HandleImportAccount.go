@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/chesscom"
+	"arnavsurve/nara-chess/server/pkg/lichess"
+	"arnavsurve/nara-chess/server/pkg/pgn"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// accountName matches the usernames the game sites allow.
+var accountName = regexp.MustCompile(`^[A-Za-z0-9_-]{2,30}$`)
+
+// GameArchive fetches a player's recent games from another site, newest first, tagged like
+// a Lichess export. *lichess.Client and *chesscom.Client implement it.
+type GameArchive interface {
+	RecentGames(ctx context.Context, username string, max int) ([]pgn.Game, error)
+}
+
+// HandleImportLichess fetches the recent games of the Lichess user in the path, stores the
+// new ones for the pupil and queues them for review, which mines their puzzles and folds
+// them into the pupil's profile. ?max= bounds how many games are fetched and ?user_id=
+// names the pupil, by default "lichess:" and the lowercased username.
+func (h *Handler) HandleImportLichess(w http.ResponseWriter, r *http.Request) {
+	h.importAccount(w, r, "lichess", h.Lichess, lichess.ErrUserNotFound)
+}
+
+// HandleImportChessCom is HandleImportLichess for Chess.com users, whose pupils default to
+// "chess.com:" and the lowercased username.
+func (h *Handler) HandleImportChessCom(w http.ResponseWriter, r *http.Request) {
+	h.importAccount(w, r, "chess.com", h.ChessCom, chesscom.ErrUserNotFound)
+}
+
+// importAccount imports the recent games of the path's username on site from archive,
+// which reports an unknown user with notFound.
+func (h *Handler) importAccount(w http.ResponseWriter, r *http.Request, site string, archive GameArchive, notFound error) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	if archive == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, fmt.Sprintf("Importing from %s is not available", site))
+		return
+	}
+
+	username := r.PathValue("username")
+	if !accountName.MatchString(username) {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "username", "Invalid username: "+username)
+		return
+	}
+	max := types.DefaultAccountImportGames
+	if v := r.URL.Query().Get("max"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > types.MaxAccountImportGames {
+			apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "max", fmt.Sprintf("max must be between 1 and %d", types.MaxAccountImportGames))
+			return
+		}
+		max = n
+	}
+	userID, err := pupilID(r, r.URL.Query().Get("user_id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if userID == "" {
+		userID = site + ":" + strings.ToLower(username)
+	}
+
+	games, err := archive.RecentGames(r.Context(), username, max)
+	if errors.Is(err, notFound) {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, fmt.Sprintf("No %s user named %s", site, username))
+		return
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error fetching games", "site", site, "err", err)
+		apierror.Write(w, http.StatusBadGateway, apierror.UpstreamError, fmt.Sprintf("Failed to fetch games from %s", site))
+		return
+	}
+
+	stored, err := h.Games.List()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing games", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to list games")
+		return
+	}
+	imported := map[string]bool{}
+	for _, g := range stored {
+		if g.Source != "" {
+			imported[g.Source] = true
+		}
+	}
+
+	importResponse := types.AccountImportResponse{
+		Site:     site,
+		Username: username,
+		UserID:   userID,
+		Fetched:  len(games),
+		Games:    []types.AccountImportGame{},
+	}
+	var queue []*store.Game
+	for _, g := range games {
+		source := g.Tags["Site"]
+		if imported[source] {
+			importResponse.Duplicates++
+			continue
+		}
+		game, err := h.storeImportedGame(g, username, userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error storing imported game", "site", site, "source", source, "err", err)
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to store games")
+			return
+		}
+		if game == nil {
+			importResponse.Skipped++
+			continue
+		}
+		imported[source] = true
+		importResponse.Imported++
+		importResponse.Games = append(importResponse.Games, types.AccountImportGame{
+			GameID:    game.ID,
+			Source:    game.Source,
+			White:     g.Tags["White"],
+			Black:     g.Tags["Black"],
+			Date:      importedGameDate(g),
+			Result:    storedGameStatus(game).Result,
+			PupilSide: game.PupilSide,
+			Plies:     len(game.MoveHistory),
+		})
+		if h.reviewable(game) {
+			queue = append(queue, game)
+		}
+	}
+	slog.InfoContext(r.Context(), "Imported games", "site", site, "imported", importResponse.Imported, "fetched", importResponse.Fetched, "user_id", userID)
+
+	// Reviews replay whole games through the engine, so the queue runs one game at a time.
+	// A shutdown drops the games not yet reviewed.
+	importResponse.Queued = len(queue)
+	if len(queue) > 0 {
+		h.goBackground(func() {
+			for _, g := range queue {
+				if h.stopping.Err() != nil {
+					return
+				}
+				h.reviewGame(g)
+			}
+		})
+	}
+
+	writeJSON(w, importResponse)
+}
+
+// storeImportedGame stores g, played by username, as a finished game of userID's. It
+// returns nil without storing a game that has no source, is unfinished, contains an
+// illegal move or that username didn't play in.
+func (h *Handler) storeImportedGame(g pgn.Game, username, userID string) (*store.Game, error) {
+	source := g.Tags["Site"]
+	var side string
+	switch {
+	case strings.EqualFold(g.Tags["White"], username):
+		side = chess.White.String()
+	case strings.EqualFold(g.Tags["Black"], username):
+		side = chess.Black.String()
+	}
+	replayed := importGame(g)
+	if source == "" || side == "" || replayed.Reason != "" || g.Result == "" || g.Result == "*" {
+		return nil, nil
+	}
+
+	game, err := h.Games.Create(store.Game{
+		InitialFen: replayed.InitialFen,
+		UserID:     userID,
+		Source:     source,
+		PupilSide:  side,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h.Games.Update(game.ID, game.Version, func(stored *store.Game) error {
+		stored.MoveHistory = replayed.MoveHistory
+		if n := len(replayed.Fens); n > 0 {
+			stored.Fen = replayed.Fens[n-1]
+		}
+		// A game that ended on the board keeps its result in the moves; any other ended
+		// on time, by resignation or by agreement.
+		if storedGameStatus(stored) == nil {
+			stored.Result = g.Result
+			switch {
+			case g.Tags["Termination"] == "Time forfeit":
+				stored.Reason = chess.ReasonTimeout
+			case g.Result == "1/2-1/2":
+				stored.Reason = chess.ReasonDrawAgreed
+			default:
+				stored.Reason = chess.ReasonResignation
+			}
+		}
+		return nil
+	})
+}
+
+// importedGameDate is the day g was played, from its UTCDate or Date tag.
+func importedGameDate(g pgn.Game) string {
+	if date := g.Tags["UTCDate"]; date != "" {
+		return date
+	}
+	return g.Tags["Date"]
+}
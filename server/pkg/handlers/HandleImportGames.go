@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/pgn"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HandleImportGames parses a Lichess or Chess.com export and returns each game as a
+// normalized move history with the FEN after every ply, ready for analysis or storage.
+func (h *Handler) HandleImportGames(w http.ResponseWriter, r *http.Request) {
+	importRequest, ok := decodeAndValidate[types.ImportGamesRequest](w, r)
+	if !ok {
+		return
+	}
+
+	format := importRequest.Format
+	if format == "" {
+		format = types.ImportFormatPGN
+		if strings.HasPrefix(strings.TrimSpace(importRequest.Data), "{") {
+			format = types.ImportFormatLichessNDJSON
+		}
+	}
+
+	var games []pgn.Game
+	var err error
+	if format == types.ImportFormatLichessNDJSON {
+		games, err = pgn.ParseLichessNDJSON(strings.NewReader(importRequest.Data))
+	} else {
+		games, err = pgn.Parse(importRequest.Data)
+	}
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, fmt.Sprintf("Invalid %s export: %v", format, err))
+		return
+	}
+	if len(games) > types.MaxImportGames {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, fmt.Sprintf("Export may contain at most %d games", types.MaxImportGames))
+		return
+	}
+
+	importResponse := types.ImportGamesResponse{Format: format, Games: make([]types.ImportedGame, 0, len(games))}
+	for _, g := range games {
+		importResponse.Games = append(importResponse.Games, importGame(g))
+	}
+
+	writeJSON(w, importResponse)
+}
+
+// importGame replays a parsed game from its starting position, stopping at the first
+// illegal move.
+func importGame(g pgn.Game) types.ImportedGame {
+	imported := types.ImportedGame{
+		Tags:        g.Tags,
+		MoveHistory: []string{},
+		Fens:        []string{},
+		Result:      g.Result,
+	}
+
+	initialFen := chess.StartFEN
+	if fen := g.Tags["FEN"]; fen != "" {
+		initialFen = fen
+	}
+	pos, err := chess.ParseFEN(initialFen)
+	if err != nil {
+		imported.InitialFen = initialFen
+		imported.Reason = types.FENMessage("FEN tag", err)
+		return imported
+	}
+	imported.InitialFen = pos.FEN()
+
+	for i, san := range g.Moves {
+		m, err := notation.ParseMove(pos, san)
+		if err != nil {
+			imported.IllegalPly = i + 1
+			imported.IllegalMove = san
+			imported.Reason = err.Error()
+			break
+		}
+		imported.MoveHistory = append(imported.MoveHistory, pos.SAN(m))
+		pos = pos.Play(m)
+		imported.Fens = append(imported.Fens, pos.FEN())
+	}
+	return imported
+}
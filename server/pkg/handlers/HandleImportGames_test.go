@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func importGames(t *testing.T, format, data string) *types.ImportGamesResponse {
+	t.Helper()
+	body, err := json.Marshal(types.ImportGamesRequest{Format: format, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ := newTestHandler()
+	w := serve(h.HandleImportGames, http.MethodPost, "/api/importGames", string(body))
+	resp := decodeResponse[types.ImportGamesResponse](t, w, http.StatusOK)
+	return &resp
+}
+
+func TestImportGamesChessComPGN(t *testing.T) {
+	// Trimmed from a Chess.com export: clocks, loose SAN and a game that breaks off at an
+	// illegal move.
+	const data = `[Event "Live Chess"]
+[Site "Chess.com"]
+[Result "1-0"]
+
+1. e4 {[%clk 0:02:59.9]} 1... e5 {[%clk 0:02:58]} 2. Qh5 {[%clk 0:02:57]} 2... Nc6 3. Bc4 Nf6 4. Qf7 1-0
+
+[Event "Live Chess"]
+[Result "*"]
+
+1. d4 d5 2. Ke3 *
+`
+	resp := importGames(t, "", data)
+	if resp.Format != types.ImportFormatPGN || len(resp.Games) != 2 {
+		t.Fatalf("response = %+v, want 2 PGN games", resp)
+	}
+
+	g := resp.Games[0]
+	// Missing capture and mate marks are restored.
+	if want := strings.Fields("e4 e5 Qh5 Nc6 Bc4 Nf6 Qxf7#"); !slices.Equal(g.MoveHistory, want) {
+		t.Errorf("moves = %v, want %v", g.MoveHistory, want)
+	}
+	if g.InitialFen != chess.StartFEN || len(g.Fens) != len(g.MoveHistory) || g.Result != "1-0" || g.IllegalPly != 0 {
+		t.Errorf("first game = %+v", g)
+	}
+	if last := g.Fens[len(g.Fens)-1]; !strings.HasPrefix(last, "r1bqkb1r/pppp1Qpp/2n2n2/4p3/2B1P3/8/PPPP1PPP/RNB1K1NR b") {
+		t.Errorf("final FEN = %s", last)
+	}
+
+	g = resp.Games[1]
+	if !slices.Equal(g.MoveHistory, []string{"d4", "d5"}) || len(g.Fens) != 2 {
+		t.Errorf("second game kept %v, want the plies before the illegal move", g.MoveHistory)
+	}
+	if g.IllegalPly != 3 || g.IllegalMove != "Ke3" || g.Reason == "" {
+		t.Errorf("second game stopped at ply %d (%q, %q), want 3 Ke3", g.IllegalPly, g.IllegalMove, g.Reason)
+	}
+}
+
+func TestImportGamesLichessNDJSON(t *testing.T) {
+	// Trimmed from a Lichess API export, with one game from a set position.
+	const data = `{"id":"q7ZvsdUF","variant":"standard","status":"mate","players":{"white":{"user":{"name":"carol"}},"black":{"user":{"name":"dave"}}},"winner":"black","moves":"f3 e5 g4 Qh4#"}
+{"id":"Xp2mRt9a","variant":"fromPosition","initialFen":"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1","status":"outoftime","players":{"white":{"user":{"name":"carol"}},"black":{"aiLevel":3}},"winner":"white","moves":"e4 Kd7"}
+`
+	// The format is detected from the leading brace.
+	resp := importGames(t, "", data)
+	if resp.Format != types.ImportFormatLichessNDJSON || len(resp.Games) != 2 {
+		t.Fatalf("response = %+v, want 2 Lichess games", resp)
+	}
+
+	g := resp.Games[0]
+	if !slices.Equal(g.MoveHistory, []string{"f3", "e5", "g4", "Qh4#"}) || g.Result != "0-1" || g.Tags["Black"] != "dave" {
+		t.Errorf("first game = %+v", g)
+	}
+
+	g = resp.Games[1]
+	if g.InitialFen != "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1" || !slices.Equal(g.MoveHistory, []string{"e4", "Kd7"}) {
+		t.Errorf("second game = %+v, want it replayed from its FEN", g)
+	}
+	if g.Fens[1] != "8/3k4/8/8/4P3/8/8/4K3 w - - 1 2" {
+		t.Errorf("second game ends at %s", g.Fens[1])
+	}
+}
+
+func TestImportGamesBadFENTag(t *testing.T) {
+	resp := importGames(t, types.ImportFormatPGN, "[SetUp \"1\"]\n[FEN \"8/8/8/8/8/8/8/8 w - - 0 1\"]\n\n1. e4 *\n")
+	g := resp.Games[0]
+	if len(g.MoveHistory) != 0 || g.Reason == "" || g.InitialFen != "8/8/8/8/8/8/8/8 w - - 0 1" {
+		t.Errorf("game = %+v, want the bad FEN tag reported", g)
+	}
+}
+
+func TestImportGamesRejects(t *testing.T) {
+	tooMany := strings.Repeat("1. e4 *\n\n", types.MaxImportGames+1)
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		code   string
+	}{
+		{"empty", "", "  ", apierror.InvalidRequest},
+		{"unknown format", "chesscom_json", "1. e4 *", apierror.InvalidRequest},
+		{"malformed PGN", types.ImportFormatPGN, "1. e4 { unterminated", apierror.InvalidPGN},
+		{"malformed NDJSON", "", `{"id":`, apierror.InvalidPGN},
+		{"unsupported variant", types.ImportFormatLichessNDJSON, `{"id":"a","variant":"atomic","moves":"e4"}`, apierror.InvalidPGN},
+		{"too many games", types.ImportFormatPGN, tooMany, apierror.InvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(types.ImportGamesRequest{Format: tt.format, Data: tt.data})
+			if err != nil {
+				t.Fatal(err)
+			}
+			h, _ := newTestHandler()
+			w := serve(h.HandleImportGames, http.MethodPost, "/api/importGames", string(body))
+			resp := decodeResponse[apierror.Response](t, w, http.StatusBadRequest)
+			if resp.Error.Code != tt.code {
+				t.Errorf("error = %+v, want %s", resp.Error, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+)
+
+func (h *Handler) HandleLegalMoves(w http.ResponseWriter, r *http.Request) {
+	legalMovesRequest, ok := decodeGameRequest[types.LegalMovesRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	pos, err := parseGameFEN(legalMovesRequest.Fen, legalMovesRequest.Variant)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+
+	legalMovesResponse := types.LegalMovesResponse{Moves: []types.MoveInfo{}}
+	if !wonOnHill(pos) {
+		for _, m := range pos.CachedLegalMoves() {
+			legalMovesResponse.Moves = append(legalMovesResponse.Moves, describeMove(pos, m))
+		}
+	}
+
+	legalMovesResponse.Status = gameStatus(pos)
+
+	writeJSON(w, legalMovesResponse)
+}
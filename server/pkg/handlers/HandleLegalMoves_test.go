@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+	"testing"
+)
+
+func TestLegalMovesVariantGame(t *testing.T) {
+	h, _ := newTestHandler()
+	// After Kd4 White's king stands on the hill: the King of the Hill game is over, though
+	// a standard game from the same position would go on.
+	game := storeHillGame(t, h, "Kd4")
+
+	w := serve(h.HandleLegalMoves, http.MethodPost, "/api/legalMoves", `{"game_id": "`+game.ID+`"}`)
+	resp := decodeResponse[types.LegalMovesResponse](t, w, http.StatusOK)
+	if len(resp.Moves) != 0 {
+		t.Errorf("got %d legal moves in a game won on the hill, want none", len(resp.Moves))
+	}
+	if resp.Status == nil || resp.Status.Result != "1-0" || resp.Status.Reason != chess.ReasonKingOfTheHill {
+		t.Errorf("status = %+v, want 1-0 on the hill", resp.Status)
+	}
+
+	w = serve(h.HandleLegalMoves, http.MethodPost, "/api/legalMoves", `{"fen": "`+game.Fen+`"}`)
+	resp = decodeResponse[types.LegalMovesResponse](t, w, http.StatusOK)
+	if len(resp.Moves) == 0 || resp.Status != nil {
+		t.Errorf("standard position: %d moves, status %+v; want moves and no status", len(resp.Moves), resp.Status)
+	}
+}
+
+func TestLegalMovesRejectsUnknownVariant(t *testing.T) {
+	h, _ := newTestHandler()
+	w := serve(h.HandleLegalMoves, http.MethodPost, "/api/legalMoves", `{"fen": "`+hillFEN+`", "variant": "atomic"}`)
+	if resp := decodeResponse[apierror.Response](t, w, http.StatusBadRequest); resp.Error.Field != "variant" {
+		t.Errorf("error = %+v, want variant rejected", resp.Error)
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/lessons"
+	"arnavsurve/nara-chess/server/pkg/llm"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// HandleListLessons lists the built-in lessons, without their solutions.
+func (h *Handler) HandleListLessons(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	all := lessons.All()
+	lessonsResponse := types.LessonsResponse{Lessons: make([]types.LessonSummary, 0, len(all))}
+	for _, l := range all {
+		lessonsResponse.Lessons = append(lessonsResponse.Lessons, types.LessonSummary{
+			ID:      l.ID,
+			Title:   l.Title,
+			Theme:   l.Theme,
+			Summary: l.Summary,
+			Steps:   len(l.Steps),
+		})
+	}
+
+	writeJSON(w, lessonsResponse)
+}
+
+// HandleLessonStep walks the pupil through one step of a lesson with the chat coach. The
+// coach introduces the step, or checks the pupil's answer against the step's known
+// solutions and coaches them on it. The solutions stay hidden until the step is solved.
+func (h *Handler) HandleLessonStep(w http.ResponseWriter, r *http.Request) {
+	stepRequest, ok := decodeAndValidate[types.LessonStepRequest](w, r)
+	if !ok {
+		return
+	}
+
+	lesson, ok := lessons.Get(r.PathValue("id"))
+	if !ok {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Lesson not found")
+		return
+	}
+	if stepRequest.Step > len(lesson.Steps) {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "step", fmt.Sprintf("Lesson %s has %d steps", lesson.ID, len(lesson.Steps)))
+		return
+	}
+	step := lesson.Steps[stepRequest.Step-1]
+	pos, err := chess.ParseFEN(step.Fen)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error parsing FEN of lesson step", "lesson_id", lesson.ID, "step", stepRequest.Step, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load lesson")
+		return
+	}
+
+	stepResponse := types.LessonStepResponse{
+		LessonID: lesson.ID,
+		Step:     stepRequest.Step,
+		Steps:    len(lesson.Steps),
+		Fen:      step.Fen,
+		Task:     step.Task,
+	}
+	chatMessageRequest := types.ChatMessageRequest{
+		GameState:      types.GameStateRequest{Fen: step.Fen},
+		PlayerSide:     pos.Turn.String(),
+		MessageHistory: stepRequest.MessageHistory,
+	}
+	if stepRequest.Move != "" {
+		san, correct, err := step.Check(stepRequest.Move)
+		if err != nil {
+			apierror.WriteField(w, http.StatusBadRequest, apierror.IllegalMove, "move", "Illegal move: "+stepRequest.Move)
+			return
+		}
+		stepResponse.Played, stepResponse.Correct = san, &correct
+		if correct {
+			stepResponse.Solutions, stepResponse.Explanation = step.Solutions, step.Explanation
+			if stepRequest.Step < len(lesson.Steps) {
+				stepResponse.NextStep = stepRequest.Step + 1
+			} else {
+				stepResponse.Complete = true
+			}
+		}
+		chatMessageRequest.MessageHistory = append(chatMessageRequest.MessageHistory, types.ChatMessage{
+			Role:    "user",
+			Content: "My answer: " + san,
+		})
+	}
+
+	ctx, cancel := h.requestContext(r, "chat")
+	defer cancel()
+
+	promptText := chatPrompt(chatMessageRequest, "", nil) + lessonInstruction(lesson, stepRequest.Step, stepResponse)
+
+	slog.InfoContext(r.Context(), "Asking the model for a lesson step", "lesson_id", lesson.ID, "step", stepRequest.Step)
+	jsonString, ok := h.generate(ctx, w, h.modelRequest("chat", promptText, chatMessageResponseSchema))
+	if !ok {
+		return
+	}
+
+	var chatMessageResponse types.ChatMessageResponse
+	if err := json.Unmarshal([]byte(jsonString), &chatMessageResponse); err != nil {
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse lesson response")
+		return
+	}
+	if chatMessageResponse.Response == "" {
+		slog.WarnContext(r.Context(), "The model returned JSON but the 'response' field was empty")
+		slog.DebugContext(r.Context(), "Model response without a reply", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Analysis service failed to provide a response")
+		return
+	}
+
+	stepResponse.Response = chatMessageResponse.Response
+	stepResponse.Arrows = llm.SanitizeArrows(chatMessageResponse.Arrows, chatMessageResponse.ArrowGroups, pos)
+	stepResponse.ArrowGroups = chatMessageResponse.ArrowGroups
+	stepResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, stepResponse)
+}
+
+// lessonInstruction tells the chat coach where the pupil is in the lesson, what solves the
+// step and how the pupil's answer, if any, fared.
+func lessonInstruction(lesson lessons.Lesson, n int, resp types.LessonStepResponse) string {
+	step := lesson.Steps[n-1]
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `
+
+LESSON: You are guiding your pupil through the lesson %q, step %d of %d. The pupil has been set this task: %q
+The moves that solve it: %s. The idea: %s
+`, lesson.Title, n, len(lesson.Steps), step.Task, strings.Join(step.Solutions, " or "), step.Explanation)
+	switch {
+	case resp.Correct == nil:
+		sb.WriteString("Introduce this step: point out what to look for in the position without naming the solution or drawing an arrow for it, and invite your pupil to find the move.")
+	case *resp.Correct && resp.Complete:
+		fmt.Fprintf(&sb, "Your pupil answered %s, which is correct. Congratulate them briefly, explain why it works, and wrap up the lesson with its main takeaway.", resp.Played)
+	case *resp.Correct:
+		fmt.Fprintf(&sb, "Your pupil answered %s, which is correct. Congratulate them briefly, explain why it works, and invite them to the next step.", resp.Played)
+	default:
+		fmt.Fprintf(&sb, "Your pupil answered %s, which does not solve the task. Do not reveal the solution or draw an arrow for it. Explain briefly what %s misses and give a hint towards the idea so they can try again.", resp.Played, resp.Played)
+	}
+	return sb.String()
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"fmt"
+	"net/http"
+)
+
+// pupilMateMoves is the longest forced mate the server looks for on the pupil's behalf.
+const pupilMateMoves = 3
+
+// findMateHint returns a hint when the side to move in pos has a forced mate, or nil.
+// The mating move is only included when reveal is set.
+func findMateHint(pos *chess.Position, reveal bool) *types.MateHint {
+	mate, ok := engine.FindMate(pos, pupilMateMoves)
+	if !ok {
+		return nil
+	}
+
+	hint := &types.MateHint{MateIn: mate.In}
+	if mate.In == 1 {
+		hint.Hint = "You have checkmate in one here — can you find it?"
+	} else {
+		hint.Hint = fmt.Sprintf("You have a forced mate in %d here — can you find it?", mate.In)
+	}
+	if reveal {
+		hint.Move = pos.SAN(mate.Move)
+		hint.Arrows = [][2]string{{mate.Move.From.String(), mate.Move.To.String()}}
+		hint.Hint = fmt.Sprintf("Mate in %d starts with %s.", mate.In, hint.Move)
+	}
+	return hint
+}
+
+// HandleMateHint tells the pupil whether they have a forced mate, revealing the first move
+// only on request.
+func (h *Handler) HandleMateHint(w http.ResponseWriter, r *http.Request) {
+	mateHintRequest, ok := decodeGameRequest[types.MateHintRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	pos, err := parseGameFEN(mateHintRequest.Fen, mateHintRequest.Variant)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+
+	mateHintResponse := types.MateHintResponse{Mate: findMateHint(pos, mateHintRequest.Reveal)}
+	mateHintResponse.Found = mateHintResponse.Mate != nil
+
+	writeJSON(w, mateHintResponse)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	mateInTwoFEN   = "6k1/8/5K2/8/8/8/8/R7 w - - 0 1"
+	mateInThreeFEN = "6k1/8/8/6K1/8/8/8/R7 w - - 0 1"
+)
+
+func TestMateHint(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		reveal bool
+		want   types.MateHintResponse
+	}{
+		{"mate in 2", mateInTwoFEN, false, types.MateHintResponse{Found: true, Mate: &types.MateHint{
+			MateIn: 2,
+			Hint:   "You have a forced mate in 2 here — can you find it?",
+		}}},
+		{"mate in 3", mateInThreeFEN, false, types.MateHintResponse{Found: true, Mate: &types.MateHint{
+			MateIn: 3,
+			Hint:   "You have a forced mate in 3 here — can you find it?",
+		}}},
+		{"mate in 2 revealed", mateInTwoFEN, true, types.MateHintResponse{Found: true, Mate: &types.MateHint{
+			MateIn: 2,
+			Hint:   "Mate in 2 starts with Rh1.",
+			Move:   "Rh1",
+			Arrows: [][2]string{{"a1", "h1"}},
+		}}},
+		{"no mate", kingsFEN, true, types.MateHintResponse{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler()
+			body := `{"fen": "` + tt.fen + `", "reveal": ` + strconv.FormatBool(tt.reveal) + `}`
+			w := serve(h.HandleMateHint, http.MethodPost, "/api/mateHint", body)
+			resp := decodeResponse[types.MateHintResponse](t, w, http.StatusOK)
+
+			if resp.Found != tt.want.Found || (resp.Mate == nil) != (tt.want.Mate == nil) {
+				t.Fatalf("response = %+v, want %+v", resp, tt.want)
+			}
+			if resp.Mate == nil {
+				return
+			}
+			got, want := *resp.Mate, *tt.want.Mate
+			if got.MateIn != want.MateIn || got.Hint != want.Hint || got.Move != want.Move || len(got.Arrows) != len(want.Arrows) ||
+				(len(want.Arrows) > 0 && got.Arrows[0] != want.Arrows[0]) {
+				t.Errorf("mate = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// The coach's reply says when it leaves the pupil a forced mate, without giving it away.
+func TestGenerateMoveReportsPupilMate(t *testing.T) {
+	h, _ := newTestHandler(coachReply("Kg8"))
+	w := serve(h.HandleGenerateMove, http.MethodPost, "/api/generateMove",
+		`{"fen": "7k/8/5K2/8/8/8/8/R7 b - - 0 1", "initial_fen": "7k/8/5K2/8/8/8/8/R7 b - - 0 1", "move_history": []}`)
+	resp := decodeResponse[types.GameStateResponse](t, w, http.StatusOK)
+
+	if resp.PupilMate == nil || resp.PupilMate.MateIn != 2 {
+		t.Fatalf("pupil_mate = %+v, want mate in 2", resp.PupilMate)
+	}
+	if resp.PupilMate.Move != "" || len(resp.PupilMate.Arrows) != 0 || strings.Contains(resp.PupilMate.Hint, "Rh1") {
+		t.Errorf("pupil_mate = %+v gives the solution away", resp.PupilMate)
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/cache"
+	"arnavsurve/nara-chess/server/pkg/metrics"
+	"net/http"
+	"strings"
+)
+
+// breakerReporter is implemented by providers wrapped in an ai.Breaker.
+type breakerReporter interface {
+	Stats() ai.BreakerStats
+}
+
+// routerReporter is implemented by an llm.Router, which has a breaker per provider.
+type routerReporter interface {
+	Stats() map[string]ai.BreakerStats
+}
+
+// cacheStats returns the stats of the response caches by name.
+func (h *Handler) cacheStats() map[string]func() cache.TieredStats {
+	return map[string]func() cache.TieredStats{
+		"generateMove":          h.MoveCache.Stats,
+		"evaluate":              h.evaluations.Stats,
+		"candidates":            h.candidates.Stats,
+		"developmentSuggestion": h.developments.Stats,
+	}
+}
+
+// HandleMetrics serves the Prometheus metrics: requests and latency by route, model call
+// durations and fallbacks, rejected coach moves and the response caches' hit rates.
+// Clients asking for application/json get the operational state instead: the AI
+// providers' circuit breakers, the cache hit rates and, when there is a quota, today's
+// model calls.
+func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		metrics.Handler().ServeHTTP(w, r)
+		return
+	}
+
+	report := map[string]any{}
+	switch p := h.AI.(type) {
+	case breakerReporter:
+		report["ai_breaker"] = p.Stats()
+	case routerReporter:
+		report["ai_breakers"] = p.Stats()
+	}
+	caches := map[string]cache.TieredStats{}
+	for name, stats := range h.cacheStats() {
+		caches[name] = stats()
+	}
+	report["response_cache"] = caches
+	if h.Quota != nil {
+		callers, calls := h.Quota.Totals()
+		report["model_quota"] = map[string]int{"limit": h.Quota.Limit(), "callers": callers, "calls": calls}
+	}
+
+	writeJSON(w, report)
+}
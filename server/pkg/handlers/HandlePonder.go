@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// ponderDepth is the search depth used to guess the pupil's likely moves.
+const ponderDepth = 2
+
+// moveCacheKey identifies a cached coach reply to pos, the position req's history
+// reaches. The reply depends on the position and its variant, the drilling theme, the
+// difficulty, the coach's persona, whether reasoning was requested and the profile of the
+// pupil it was written for, so one pupil's weaknesses never reach another; chat history
+// only colours the comment and is left out.
+func moveCacheKey(pos *chess.Position, req types.GameStateRequest, profile *store.Profile) string {
+	pupil := ""
+	if profile != nil {
+		pupil = profile.UserID
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%t|%s", analysisKey(pos), req.Variant, req.Constraint, req.Difficulty, req.Persona, req.IncludeReasoning, pupil)
+}
+
+// HandlePonder uses the pupil's thinking time to pre-compute the coach's reply to their
+// most likely moves, so the following /generateMove is served from MoveCache.
+func (h *Handler) HandlePonder(w http.ResponseWriter, r *http.Request) {
+	ponderRequest, ok := decodeGameRequest[types.PonderRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	fen, _, err := h.authoritativeFen(ponderRequest.Variant, ponderRequest.InitialFen, ponderRequest.Fen, ponderRequest.MoveHistory)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pos, err := parseGameFEN(fen, ponderRequest.Variant)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+
+	// Replies are prepared for the pupil playing the stored game, as /generateMove will
+	// ask for them.
+	profile := h.pupilProfile(types.GameStateRequest{GameID: ponderRequest.GameID})
+	ponderResponse := types.PonderResponse{Candidates: []types.PonderedMove{}}
+	var requests []types.GameStateRequest
+	var keys []string
+	for _, c := range engine.TopMoves(pos, ponderDepth, ponderRequest.MaxCandidates) {
+		next := pos.Play(c.Move)
+		if len(next.LegalMoves()) == 0 {
+			continue // the game is over; there is no reply to prepare
+		}
+		san := pos.SAN(c.Move)
+		ponderResponse.Candidates = append(ponderResponse.Candidates, types.PonderedMove{Move: san, Fen: next.FEN()})
+		requests = append(requests, types.GameStateRequest{
+			Fen:         next.FEN(),
+			InitialFen:  ponderRequest.InitialFen,
+			MoveHistory: append(append([]string{}, ponderRequest.MoveHistory...), san),
+			ChatHistory: ponderRequest.ChatHistory,
+			Constraint:  ponderRequest.Constraint,
+			Difficulty:  ponderRequest.Difficulty,
+			Variant:     ponderRequest.Variant,
+			Persona:     ponderRequest.Persona,
+			GameID:      ponderRequest.GameID,
+		})
+		keys = append(keys, moveCacheKey(next, requests[len(requests)-1], profile))
+	}
+
+	// Candidates share one budget sized for a single attempt each plus the usual retries.
+	ctx, cancel := h.requestContext(r, "generateMove")
+	defer cancel()
+	ctx = ai.WithBudget(ctx, ai.NewBudget(len(requests)+h.Config.MaxModelCalls-1))
+
+	var wg sync.WaitGroup
+	for i, req := range requests {
+		if _, ok := h.MoveCache.Get(keys[i]); ok {
+			ponderResponse.Candidates[i].Ready = true
+			continue
+		}
+		wg.Add(1)
+		go func(i int, req types.GameStateRequest) {
+			defer wg.Done()
+			reply, err := h.coachMove(ctx, req, true)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error pondering reply", "move", ponderResponse.Candidates[i].Move, "err", err)
+				return
+			}
+			ponderResponse.Candidates[i].Ready = reply.Source != SourceEngine
+		}(i, req)
+	}
+	wg.Wait()
+
+	ponderResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, ponderResponse)
+
+	slog.InfoContext(r.Context(), "Pondered candidate moves", "count", len(ponderResponse.Candidates))
+}
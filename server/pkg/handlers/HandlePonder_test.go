@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// firstMoveProvider answers every coach prompt with the first legal move in the position
+// it names, so it can reply to positions the test doesn't know in advance.
+type firstMoveProvider struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *firstMoveProvider) GenerateJSON(ctx context.Context, req ai.Request) (string, error) {
+	p.mu.Lock()
+	p.calls++
+	p.mu.Unlock()
+
+	_, rest, ok := strings.Cut(req.Prompt, "FEN: ")
+	if !ok {
+		return "", errors.New("firstMoveProvider: prompt names no FEN")
+	}
+	fen, _, _ := strings.Cut(rest, "\n")
+	pos, err := chess.ParseFEN(strings.TrimSpace(fen))
+	if err != nil {
+		return "", err
+	}
+	return coachReply(pos.SAN(pos.LegalMoves()[0])), nil
+}
+
+func (p *firstMoveProvider) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func TestPonderPopulatesMoveCache(t *testing.T) {
+	provider := &firstMoveProvider{}
+	h := New(provider, store.NewMemoryStore(), DefaultConfig())
+
+	w := serve(h.HandlePonder, http.MethodPost, "/api/ponder",
+		`{"fen": "`+chess.StartFEN+`", "move_history": [], "max_candidates": 3}`)
+	resp := decodeResponse[types.PonderResponse](t, w, http.StatusOK)
+	if len(resp.Candidates) != 3 {
+		t.Fatalf("pondered %d candidates, want 3", len(resp.Candidates))
+	}
+	pondered := provider.Calls()
+	if pondered < len(resp.Candidates) {
+		t.Errorf("made %d model calls for %d candidates", pondered, len(resp.Candidates))
+	}
+
+	for _, c := range resp.Candidates {
+		if !c.Ready {
+			t.Errorf("reply to %s not ready", c.Move)
+		}
+
+		// The pupil plays the predicted move and the coach's reply comes from the cache.
+		w := serve(h.HandleGenerateMove, http.MethodPost, "/api/generateMove",
+			`{"fen": "`+c.Fen+`", "move_history": ["`+c.Move+`"]}`)
+		reply := decodeResponse[types.GameStateResponse](t, w, http.StatusOK)
+		if reply.Meta == nil || !reply.Meta.CacheHit {
+			t.Errorf("reply to %s was not served from the cache", c.Move)
+		}
+	}
+	if calls := provider.Calls(); calls != pondered {
+		t.Errorf("made %d model calls after pondering, want none", calls-pondered)
+	}
+
+	// Pondering again finds every reply already cached.
+	w = serve(h.HandlePonder, http.MethodPost, "/api/ponder",
+		`{"fen": "`+chess.StartFEN+`", "move_history": [], "max_candidates": 3}`)
+	decodeResponse[types.PonderResponse](t, w, http.StatusOK)
+	if calls := provider.Calls(); calls != pondered {
+		t.Errorf("pondering a cached position made %d model calls", calls-pondered)
+	}
+}
+
+func TestPonderUnpredictedMoveMisses(t *testing.T) {
+	provider := &firstMoveProvider{}
+	h := New(provider, store.NewMemoryStore(), DefaultConfig())
+
+	w := serve(h.HandlePonder, http.MethodPost, "/api/ponder",
+		`{"fen": "`+chess.StartFEN+`", "move_history": [], "max_candidates": 1}`)
+	resp := decodeResponse[types.PonderResponse](t, w, http.StatusOK)
+	if len(resp.Candidates) != 1 || resp.Candidates[0].Move == "a3" {
+		t.Fatalf("candidates = %+v, want one that isn't a3", resp.Candidates)
+	}
+
+	w = serve(h.HandleGenerateMove, http.MethodPost, "/api/generateMove",
+		`{"fen": "rnbqkbnr/pppppppp/8/8/8/P7/1PPPPPPP/RNBQKBNR b KQkq - 0 1", "move_history": ["a3"]}`)
+	reply := decodeResponse[types.GameStateResponse](t, w, http.StatusOK)
+	if reply.Meta != nil && reply.Meta.CacheHit {
+		t.Error("reply to an unpondered move was served from the cache")
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"errors"
+	"net/http"
+)
+
+// HandlePositionsFromHistory replays a move history and returns the FEN after every ply,
+// stopping at the first illegal move.
+func (h *Handler) HandlePositionsFromHistory(w http.ResponseWriter, r *http.Request) {
+	positionsRequest, ok := decodeGameRequest[types.PositionsFromHistoryRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	initialFen := positionsRequest.InitialFen
+	if initialFen == "" {
+		initialFen = chess.StartFEN
+	}
+	start, err := parseGameFEN(initialFen, positionsRequest.Variant)
+	if err != nil {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidFEN, "initial_fen", types.FENMessage("initial_fen", err))
+		return
+	}
+
+	positions, err := chess.Replay(start, positionsRequest.MoveHistory)
+
+	positionsResponse := types.PositionsFromHistoryResponse{Fens: make([]string, 0, len(positions))}
+	for _, pos := range positions {
+		positionsResponse.Fens = append(positionsResponse.Fens, pos.FEN())
+	}
+
+	var illegal *chess.IllegalMoveError
+	if errors.As(err, &illegal) {
+		positionsResponse.IllegalPly = illegal.Ply
+		positionsResponse.IllegalMove = illegal.Move
+		positionsResponse.Reason = illegal.Err.Error()
+	}
+
+	positionsResponse.Status = gameStatus(append([]*chess.Position{start}, positions...)...)
+
+	writeJSON(w, positionsResponse)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+	"testing"
+)
+
+func TestPositionsFromHistoryFullGame(t *testing.T) {
+	h := &Handler{}
+	w := serve(h.HandlePositionsFromHistory, http.MethodPost, "/api/positions",
+		`{"move_history": ["e4", "e5", "Bc4", "Nc6", "Qh5", "Nf6", "Qxf7#"]}`)
+	resp := decodeResponse[types.PositionsFromHistoryResponse](t, w, http.StatusOK)
+
+	if len(resp.Fens) != 7 {
+		t.Fatalf("got %d FENs, want one per ply (7)", len(resp.Fens))
+	}
+	if want := "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"; resp.Fens[0] != want {
+		t.Errorf("FEN after 1. e4 = %q, want %q", resp.Fens[0], want)
+	}
+	if want := "r1bqkb1r/pppp1Qpp/2n2n2/4p3/2B1P3/8/PPPP1PPP/RNB1K1NR b KQkq - 0 4"; resp.Fens[6] != want {
+		t.Errorf("final FEN = %q, want %q", resp.Fens[6], want)
+	}
+	if resp.IllegalPly != 0 || resp.IllegalMove != "" {
+		t.Errorf("reported illegal move %q at ply %d in a legal game", resp.IllegalMove, resp.IllegalPly)
+	}
+	if resp.Status == nil || resp.Status.Result != "1-0" || resp.Status.Reason != chess.ReasonCheckmate {
+		t.Errorf("status = %+v, want 1-0 by checkmate", resp.Status)
+	}
+}
+
+func TestPositionsFromHistoryIllegalMove(t *testing.T) {
+	h := &Handler{}
+	w := serve(h.HandlePositionsFromHistory, http.MethodPost, "/api/positions",
+		`{"move_history": ["e4", "e5", "Ke3", "Nf6"]}`)
+	resp := decodeResponse[types.PositionsFromHistoryResponse](t, w, http.StatusOK)
+
+	if len(resp.Fens) != 2 {
+		t.Errorf("got %d FENs, want the 2 before the illegal move", len(resp.Fens))
+	}
+	if resp.IllegalPly != 3 || resp.IllegalMove != "Ke3" {
+		t.Errorf("illegal move = %q at ply %d, want Ke3 at ply 3", resp.IllegalMove, resp.IllegalPly)
+	}
+	if resp.Reason == "" {
+		t.Error("illegal move has no reason")
+	}
+	if resp.Status != nil {
+		t.Errorf("status = %+v for an unfinished game", resp.Status)
+	}
+}
+
+func TestPositionsFromHistoryInitialFEN(t *testing.T) {
+	h := &Handler{}
+	w := serve(h.HandlePositionsFromHistory, http.MethodPost, "/api/positions",
+		`{"initial_fen": "`+kingsFEN+`", "move_history": ["Kd2"]}`)
+	resp := decodeResponse[types.PositionsFromHistoryResponse](t, w, http.StatusOK)
+	if want := "4k3/8/8/8/8/8/3K4/8 b - - 1 1"; len(resp.Fens) != 1 || resp.Fens[0] != want {
+		t.Errorf("FENs = %q, want [%q]", resp.Fens, want)
+	}
+	if resp.Status == nil || resp.Status.Reason != chess.ReasonInsufficientMaterial {
+		t.Errorf("status = %+v, want a draw by insufficient material", resp.Status)
+	}
+
+	w = serve(h.HandlePositionsFromHistory, http.MethodPost, "/api/positions",
+		`{"initial_fen": "not a position", "move_history": []}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid initial FEN: status = %d, want 400", w.Code)
+	}
+}
+
+func TestPositionsFromHistoryVariant(t *testing.T) {
+	h := &Handler{}
+	w := serve(h.HandlePositionsFromHistory, http.MethodPost, "/api/positions",
+		`{"initial_fen": "`+hillFEN+`", "move_history": ["Kd4"], "variant": "king_of_the_hill"}`)
+	resp := decodeResponse[types.PositionsFromHistoryResponse](t, w, http.StatusOK)
+	if resp.Status == nil || resp.Status.Result != "1-0" || resp.Status.Reason != chess.ReasonKingOfTheHill {
+		t.Errorf("status = %+v, want 1-0 on the hill", resp.Status)
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+)
+
+// HandlePrincipalVariation searches a position with the local engine and returns the
+// expected line with the evaluation after every ply.
+func (h *Handler) HandlePrincipalVariation(w http.ResponseWriter, r *http.Request) {
+	pvRequest, ok := decodeGameRequest[types.PrincipalVariationRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	pos, err := parseGameFEN(pvRequest.Fen, pvRequest.Variant)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+	if len(pos.LegalMoves()) == 0 || wonOnHill(pos) {
+		apierror.Write(w, http.StatusBadRequest, apierror.GameOver, "The game is over in this position")
+		return
+	}
+
+	perspective := analysisPerspective(pvRequest.AnalyzeFor, pos.Turn, chess.White)
+	result := engine.Search(pos, pvRequest.Depth)
+	score := engine.ScoreFor(engine.WhiteScore(result.Score, pos.Turn), perspective)
+
+	pvResponse := types.PrincipalVariationResponse{
+		Perspective: perspective.String(),
+		BestMove:    pos.SAN(result.Move),
+		Score:       score,
+		Mate:        engine.MateIn(score),
+		Display:     engine.FormatScore(score),
+		Depth:       result.Depth,
+		PV:          []types.PlyEvaluation{},
+	}
+	for i, e := range engine.AnnotatePV(pos, result.PV, pvRequest.Depth) {
+		e.Score = engine.ScoreFor(e.Score, perspective)
+		pvResponse.PV = append(pvResponse.PV, types.PlyEvaluation{
+			Ply:     i + 1,
+			San:     e.San,
+			Uci:     e.Move.String(),
+			Fen:     e.Fen,
+			Score:   e.Score,
+			Mate:    engine.MateIn(e.Score),
+			Display: engine.FormatScore(e.Score),
+		})
+	}
+
+	writeJSON(w, pvResponse)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+)
+
+// ratingWindow is how many recent games the estimated rating follows. Older games fade out
+// of it so the estimate keeps up with a pupil who is improving.
+const ratingWindow = 5
+
+// errProfileUnchanged aborts a profile update for a game that was already folded in.
+var errProfileUnchanged = errors.New("game already in profile")
+
+// HandleGetProfile serves what the coach has learnt about a pupil from their finished
+// games.
+func (h *Handler) HandleGetProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if h.Profiles == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Profiles are not available")
+		return
+	}
+
+	userID := r.PathValue("user_id")
+	if err := canSee(r, userID); err != nil {
+		writeError(w, err)
+		return
+	}
+	profile, err := h.Profiles.GetProfile(userID)
+	if errors.Is(err, store.ErrProfileNotFound) {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Profile not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading profile", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load profile")
+		return
+	}
+
+	writeJSON(w, profile)
+}
+
+// updateProfile folds the finished game g, analysed as moves, into the profile of its
+// pupil, who played side. A game already in the profile is skipped.
+func (h *Handler) updateProfile(g *store.Game, moves []analysis.MoveAnalysis, side string) {
+	report := analysis.SummarizeSide(moves, side)
+	opening, hasOpening := classifyOpening(g.InitialFen, g.Variant, g.MoveHistory)
+	_, err := h.Profiles.UpdateProfile(g.UserID, func(p *store.Profile) error {
+		if slices.Contains(p.GameIDs, g.ID) {
+			return errProfileUnchanged
+		}
+		p.GameIDs = append(p.GameIDs, g.ID)
+		p.Games++
+		if report.Moves > 0 {
+			p.Rating = blendRating(p.Rating, min(p.Games, ratingWindow), ratingFromLoss(report.AverageLoss))
+		}
+		for _, m := range moves {
+			if m.Side != side || (m.Classification != analysis.ClassMistake && m.Classification != analysis.ClassBlunder) {
+				continue
+			}
+			if m.Motif != "" {
+				p.Themes[m.Motif]++
+			}
+			p.Phases[m.Phase]++
+		}
+		if hasOpening {
+			p.Openings[opening.Name]++
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errProfileUnchanged) {
+		slog.Error("Error updating profile with game", "user_id", g.UserID, "game_id", g.ID, "err", err)
+	}
+}
+
+// ratingFromLoss estimates the rating of a player from their average centipawn loss in a
+// game: about 2400 at 20, 1900 at 40 and 1000 at 100.
+func ratingFromLoss(averageLoss float64) int {
+	rating := 3000 * math.Exp(-0.011*averageLoss)
+	return int(math.Round(min(max(rating, 400), 2800)))
+}
+
+// blendRating moves the rating estimate current towards a game's estimate by one part in
+// n. A pupil without an estimate takes the game's outright.
+func blendRating(current, n, game int) int {
+	if current == 0 {
+		return game
+	}
+	return current + (game-current)/n
+}
+
+// pupilProfile returns the profile of the pupil playing the stored game req is bound to,
+// or nil when there is none.
+func (h *Handler) pupilProfile(req types.GameStateRequest) *store.Profile {
+	if h.Profiles == nil || req.GameID == "" {
+		return nil
+	}
+	game, err := h.Games.Get(req.GameID)
+	if err != nil || game.UserID == "" {
+		return nil
+	}
+	profile, err := h.Profiles.GetProfile(game.UserID)
+	if err != nil {
+		if !errors.Is(err, store.ErrProfileNotFound) {
+			slog.Error("Error loading profile", "user_id", game.UserID, "err", err)
+		}
+		return nil
+	}
+	return profile
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+var progressReportResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "A progress report on the pupil's recent games.",
+	Properties: map[string]*genai.Schema{
+		"narrative": {
+			Type:        genai.TypeString,
+			Description: "3-5 sentences on how the pupil's play has developed across these games, citing the stats.",
+		},
+		"improvement_plan": {
+			Type:        genai.TypeArray,
+			Description: "3-5 concrete steps for the coming weeks, most important first.",
+			Items:       &genai.Schema{Type: genai.TypeString},
+		},
+	},
+	Required: []string{"narrative", "improvement_plan"},
+}
+
+type progressReport struct {
+	Narrative string   `json:"narrative"`
+	Plan      []string `json:"improvement_plan"`
+}
+
+// HandleProgressReport sums up the stored games of the pupil named by ?user_id=: the
+// local engine measures the accuracy trend, blunder rate by phase and results by opening
+// over their most recent games, and the coach turns them into a narrative and an
+// improvement plan.
+func (h *Handler) HandleProgressReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, err := pupilID(r, r.URL.Query().Get("user_id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if userID == "" {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "user_id", "Request must name the pupil (user_id query parameter)")
+		return
+	}
+
+	games, err := h.Games.List()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing games", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to list games")
+		return
+	}
+	// Games are listed most recent first; keep the latest and report them oldest first.
+	var recent []*store.Game
+	for _, g := range games {
+		if g.UserID == userID && g.Variant == "" && len(g.MoveHistory) > 0 && len(g.MoveHistory) <= types.MaxReviewPlies {
+			recent = append(recent, g)
+		}
+		if len(recent) == types.MaxProgressReportGames {
+			break
+		}
+	}
+	if len(recent) == 0 {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "No stored games to report on for this user")
+		return
+	}
+	slices.Reverse(recent)
+
+	reportResponse := types.ProgressReportResponse{
+		UserID:       userID,
+		BlunderRates: map[string]types.PhaseBlunders{},
+		Openings:     []types.OpeningRecord{},
+		Plan:         []string{},
+	}
+	if h.Profiles != nil {
+		if profile, err := h.Profiles.GetProfile(userID); err == nil {
+			reportResponse.EstimatedRating = profile.Rating
+		}
+	}
+	openingIndex := map[string]int{}
+	for _, g := range recent {
+		start, err := parseGameFEN(g.InitialFen, g.Variant)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error parsing stored initial FEN of game", "game_id", g.ID, "err", err)
+			continue
+		}
+		moves, err := analysis.AnalyzeGame(start, g.MoveHistory, analysis.DefaultDepth)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error analyzing game", "game_id", g.ID, "err", err)
+			continue
+		}
+		side := pupilSide(g, start)
+		report := analysis.SummarizeSide(moves, side)
+		progress := types.GameProgress{
+			GameID:      g.ID,
+			PlayedAt:    g.UpdatedAt,
+			Side:        side,
+			Outcome:     pupilOutcome(g, side),
+			Accuracy:    report.Accuracy,
+			AverageLoss: math.Round(report.AverageLoss*10) / 10,
+		}
+		if opening, ok := classifyOpening(g.InitialFen, g.Variant, g.MoveHistory); ok {
+			progress.Opening = opening.Name
+		}
+		reportResponse.AccuracyTrend = append(reportResponse.AccuracyTrend, progress)
+
+		for _, m := range moves {
+			if m.Side != side {
+				continue
+			}
+			phase := reportResponse.BlunderRates[m.Phase]
+			phase.Moves++
+			if m.Classification == analysis.ClassBlunder {
+				phase.Blunders++
+			}
+			reportResponse.BlunderRates[m.Phase] = phase
+		}
+
+		if progress.Opening != "" {
+			i, ok := openingIndex[progress.Opening]
+			if !ok {
+				i = len(reportResponse.Openings)
+				openingIndex[progress.Opening] = i
+				reportResponse.Openings = append(reportResponse.Openings, types.OpeningRecord{Name: progress.Opening})
+			}
+			record := &reportResponse.Openings[i]
+			record.Games++
+			switch progress.Outcome {
+			case "win":
+				record.Wins++
+			case "draw":
+				record.Draws++
+			case "loss":
+				record.Losses++
+			}
+		}
+	}
+	if len(reportResponse.AccuracyTrend) == 0 {
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to analyze games")
+		return
+	}
+	reportResponse.GamesAnalyzed = len(reportResponse.AccuracyTrend)
+	reportResponse.AccuracyChange = accuracyChange(reportResponse.AccuracyTrend)
+	for phase, b := range reportResponse.BlunderRates {
+		b.Rate = math.Round(float64(b.Blunders)*1000/float64(b.Moves)) / 10
+		reportResponse.BlunderRates[phase] = b
+	}
+	sort.SliceStable(reportResponse.Openings, func(i, j int) bool {
+		return reportResponse.Openings[i].Games > reportResponse.Openings[j].Games
+	})
+
+	ctx, cancel := h.requestContext(r, "progressReport")
+	defer cancel()
+
+	promptText := fmt.Sprintf(`You are a patient chess coach writing a progress report for your pupil.
+
+Below are statistics a chess engine computed over the pupil's %d most recent games, oldest first. Accuracy runs from 0 to 100; centipawn loss measures how much worse a move was than the engine's best move.
+
+Write a short narrative of how their play has developed: whether accuracy is trending up or down, in which phase of the game they blunder most, and how they fare in their favourite openings. Then give 3-5 concrete improvement steps for the coming weeks, most important first, each grounded in these numbers.
+
+Address the pupil as "you". Use clear, encouraging language.
+
+### Estimated rating
+%s
+### Games
+%s
+### Blunders by phase
+%s
+### Openings
+%s
+Respond ONLY with a JSON object matching the schema.`, reportResponse.GamesAnalyzed, ratingText(reportResponse.EstimatedRating),
+		formatGameProgress(reportResponse.AccuracyTrend, reportResponse.AccuracyChange), formatPhaseBlunders(reportResponse.BlunderRates),
+		formatOpeningRecords(reportResponse.Openings))
+
+	slog.InfoContext(r.Context(), "Asking the model for a progress report", "games", reportResponse.GamesAnalyzed)
+	jsonString, ok := h.generate(ctx, w, h.modelRequest("progressReport", promptText, progressReportResponseSchema))
+	if !ok {
+		return
+	}
+
+	var report progressReport
+	if err := json.Unmarshal([]byte(jsonString), &report); err != nil {
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse progress report")
+		return
+	}
+	reportResponse.Narrative = report.Narrative
+	if report.Plan != nil {
+		reportResponse.Plan = report.Plan
+	}
+	reportResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, reportResponse)
+}
+
+// pupilOutcome is "win", "loss" or "draw" for the pupil playing side in g, or empty while
+// g is in progress.
+func pupilOutcome(g *store.Game, side string) string {
+	status := storedGameStatus(g)
+	if status == nil {
+		return ""
+	}
+	switch status.Result {
+	case "1-0":
+		if side == "white" {
+			return "win"
+		}
+		return "loss"
+	case "0-1":
+		if side == "black" {
+			return "win"
+		}
+		return "loss"
+	}
+	return "draw"
+}
+
+// accuracyChange compares the average accuracy of the later half of trend with the
+// earlier half. A single game has no trend.
+func accuracyChange(trend []types.GameProgress) float64 {
+	if len(trend) < 2 {
+		return 0
+	}
+	mean := func(games []types.GameProgress) float64 {
+		total := 0.0
+		for _, g := range games {
+			total += g.Accuracy
+		}
+		return total / float64(len(games))
+	}
+	half := len(trend) / 2
+	return math.Round((mean(trend[len(trend)-half:])-mean(trend[:half]))*10) / 10
+}
+
+func ratingText(rating int) string {
+	if rating == 0 {
+		return "Not known yet."
+	}
+	return fmt.Sprintf("About %d Elo.", rating)
+}
+
+func formatGameProgress(trend []types.GameProgress, change float64) string {
+	var sb strings.Builder
+	for i, g := range trend {
+		outcome := g.Outcome
+		if outcome == "" {
+			outcome = "unfinished"
+		}
+		opening := g.Opening
+		if opening == "" {
+			opening = "unnamed opening"
+		}
+		fmt.Fprintf(&sb, "%d. %s as %s, %s: accuracy %.1f%%, average centipawn loss %.0f\n",
+			i+1, outcome, g.Side, opening, g.Accuracy, g.AverageLoss)
+	}
+	fmt.Fprintf(&sb, "Accuracy change, later games against earlier ones: %+.1f points\n", change)
+	return sb.String()
+}
+
+func formatPhaseBlunders(rates map[string]types.PhaseBlunders) string {
+	var sb strings.Builder
+	for _, phase := range []string{analysis.PhaseOpening, analysis.PhaseMiddlegame, analysis.PhaseEndgame} {
+		if b, ok := rates[phase]; ok {
+			fmt.Fprintf(&sb, "%s: %d blunders in %d moves (%.1f per 100 moves)\n", phase, b.Blunders, b.Moves, b.Rate)
+		}
+	}
+	return sb.String()
+}
+
+func formatOpeningRecords(records []types.OpeningRecord) string {
+	if len(records) == 0 {
+		return "None of the games reached a named opening.\n"
+	}
+	var sb strings.Builder
+	for _, o := range records {
+		fmt.Fprintf(&sb, "%s: %d games, %d wins, %d draws, %d losses\n", o.Name, o.Games, o.Wins, o.Draws, o.Losses)
+	}
+	return sb.String()
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/srs"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// gameUpdated reviews a game once it is over: it mines the game for puzzles and folds it
+// into the pupil's profile. Both need the whole game replayed through the engine, so the
+// review runs in the background. Puzzles and profiles are standard chess, so variant
+// games are not reviewed.
+func (h *Handler) gameUpdated(g *store.Game) {
+	if h.reviewable(g) {
+		h.goBackground(func() { h.reviewGame(g) })
+	}
+}
+
+// reviewable reports whether g is a finished standard game whose review has anywhere to go.
+func (h *Handler) reviewable(g *store.Game) bool {
+	profile := h.Profiles != nil && g.UserID != ""
+	return (h.Puzzles != nil || profile) && g.Variant == "" && storedGameStatus(g) != nil
+}
+
+// reviewGame analyses a finished game once for minePuzzles and updateProfile.
+func (h *Handler) reviewGame(g *store.Game) {
+	start, err := parseGameFEN(g.InitialFen, g.Variant)
+	if err != nil {
+		slog.Error("Error reviewing game", "game_id", g.ID, "err", err)
+		return
+	}
+	moves, err := analysis.AnalyzeGame(start, g.MoveHistory, analysis.DefaultDepth)
+	if err != nil {
+		slog.Error("Error reviewing game", "game_id", g.ID, "err", err)
+		return
+	}
+	side := pupilSide(g, start)
+	if h.Puzzles != nil {
+		h.minePuzzles(g, start, moves, side)
+	}
+	if h.Profiles != nil && g.UserID != "" {
+		h.updateProfile(g, moves, side)
+	}
+}
+
+// minePuzzles stores a puzzle for every blunder the pupil, playing side, made in g.
+// Mining a game twice adds nothing new.
+func (h *Handler) minePuzzles(g *store.Game, start *chess.Position, moves []analysis.MoveAnalysis, side string) {
+	positions, _ := chess.Replay(start, g.MoveHistory)
+	positions = append([]*chess.Position{start}, positions...)
+
+	now := time.Now().UTC()
+	added := 0
+	for _, m := range moves {
+		if m.Side != side || m.Classification != analysis.ClassBlunder || m.BestMove == "" {
+			continue
+		}
+		ok, err := h.Puzzles.AddPuzzle(&store.Puzzle{
+			GameID:    g.ID,
+			UserID:    g.UserID,
+			Ply:       m.Ply,
+			Fen:       positions[m.Ply-1].FEN(),
+			Side:      m.Side,
+			Played:    m.San,
+			Solution:  m.BestMove,
+			Motif:     m.Motif,
+			Loss:      m.Loss,
+			Card:      srs.New(now),
+			CreatedAt: now,
+		})
+		if err != nil {
+			slog.Error("Error storing puzzle from game", "game_id", g.ID, "ply", m.Ply, "err", err)
+			return
+		}
+		if ok {
+			added++
+		}
+	}
+	if added > 0 {
+		slog.Info("Added puzzles from game", "count", added, "game_id", g.ID)
+	}
+}
+
+// pupilSide returns "white" or "black" for the side the pupil played in g. Only imported
+// games record it, but only the coach's moves carry commentary; without any, the pupil is
+// taken to have moved first, as the coach only ever replies.
+func pupilSide(g *store.Game, start *chess.Position) string {
+	if g.PupilSide != "" {
+		return g.PupilSide
+	}
+	first := start.Turn
+	if len(g.Comments) == 0 {
+		return first.String()
+	}
+	coach := first
+	if g.Comments[0].Ply%2 == 0 {
+		coach = first.Other()
+	}
+	return coach.Other().String()
+}
+
+// HandleNextPuzzle serves the puzzle due for review of the pupil named by ?user_id=, or of
+// the signed-in user, if any. Without either it serves the puzzles of games that named no
+// pupil.
+func (h *Handler) HandleNextPuzzle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if h.Puzzles == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Puzzles are not available")
+		return
+	}
+
+	userID, err := pupilID(r, r.URL.Query().Get("user_id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	p, err := h.Puzzles.NextPuzzle(userID)
+	if errors.Is(err, store.ErrPuzzleNotFound) {
+		writeJSON(w, types.NextPuzzleResponse{})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading next puzzle", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load puzzle")
+		return
+	}
+	if p.Card.DueAt.After(time.Now()) {
+		writeJSON(w, types.NextPuzzleResponse{NextDueAt: &p.Card.DueAt})
+		return
+	}
+	view := puzzleView(p)
+	writeJSON(w, types.NextPuzzleResponse{Puzzle: &view})
+}
+
+// HandlePuzzleAttempt checks the pupil's answer to a puzzle and schedules its next review.
+// Besides the stored solution, any move the engine rates best counts as solving it.
+func (h *Handler) HandlePuzzleAttempt(w http.ResponseWriter, r *http.Request) {
+	attemptRequest, ok := decodeAndValidate[types.PuzzleAttemptRequest](w, r)
+	if !ok {
+		return
+	}
+
+	if h.Puzzles == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Puzzles are not available")
+		return
+	}
+
+	p, err := h.Puzzles.GetPuzzle(r.PathValue("id"))
+	if err == nil && canSee(r, p.UserID) != nil {
+		err = store.ErrPuzzleNotFound
+	}
+	if errors.Is(err, store.ErrPuzzleNotFound) {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Puzzle not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading puzzle", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load puzzle")
+		return
+	}
+	pos, err := chess.ParseFEN(p.Fen)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error parsing FEN of puzzle", "puzzle_id", p.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load puzzle")
+		return
+	}
+	m, err := notation.ParseMove(pos, attemptRequest.Move)
+	if err != nil {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.IllegalMove, "move", "Illegal move: "+attemptRequest.Move)
+		return
+	}
+
+	correct := pos.SAN(m) == p.Solution
+	if !correct {
+		if ma, err := analysis.AnalyzeMove(pos, pos.SAN(m), analysis.DefaultDepth); err == nil {
+			correct = ma.Classification == analysis.ClassBest || ma.Classification == analysis.ClassBrilliant
+		}
+	}
+
+	now := time.Now().UTC()
+	saved, err := h.Puzzles.UpdatePuzzle(p.ID, func(s *store.Puzzle) error {
+		s.Attempts++
+		if correct {
+			s.Solved++
+		}
+		s.Card = s.Card.Review(correct, now)
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving puzzle", "puzzle_id", p.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to save puzzle")
+		return
+	}
+
+	writeJSON(w, types.PuzzleAttemptResponse{
+		Correct:  correct,
+		Solution: p.Solution,
+		Played:   p.Played,
+		Puzzle:   puzzleView(saved),
+	})
+}
+
+func puzzleView(p *store.Puzzle) types.Puzzle {
+	return types.Puzzle{
+		ID:       p.ID,
+		GameID:   p.GameID,
+		Ply:      p.Ply,
+		Fen:      p.Fen,
+		Side:     p.Side,
+		Motif:    p.Motif,
+		Attempts: p.Attempts,
+		Solved:   p.Solved,
+		DueAt:    p.Card.DueAt,
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPuzzleAttemptOtherUser(t *testing.T) {
+	h, _ := newTestHandler()
+	p := &store.Puzzle{GameID: "g1", UserID: store.UserIDPrefix + "alice", Ply: 1, Fen: chess.StartFEN, Side: "white", Played: "a3", Solution: "e4"}
+	if _, err := h.Puzzles.AddPuzzle(p); err != nil {
+		t.Fatal(err)
+	}
+
+	attempt := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/puzzles/"+p.ID+"/attempt", strings.NewReader(`{"move": "e4"}`))
+		r.SetPathValue("id", p.ID)
+		handler(w, r)
+		return w
+	}
+	// Neither another user nor an anonymous pupil learns that alice's puzzle exists.
+	for name, handler := range map[string]http.HandlerFunc{
+		"other user": signIn(t, store.UserIDPrefix+"bob", h.HandlePuzzleAttempt),
+		"anonymous":  h.HandlePuzzleAttempt,
+	} {
+		w := attempt(handler)
+		if resp := decodeResponse[apierror.Response](t, w, http.StatusNotFound); resp.Error.Message != "Puzzle not found" {
+			t.Errorf("%s: error = %+v, want the puzzle not found", name, resp.Error)
+		}
+	}
+
+	if w := attempt(signIn(t, p.UserID, h.HandlePuzzleAttempt)); w.Code != http.StatusOK {
+		t.Errorf("owner's attempt: status = %d, want 200", w.Code)
+	}
+	if got, err := h.Puzzles.GetPuzzle(p.ID); err != nil || got.Attempts != 1 {
+		t.Errorf("puzzle after the attempts = %+v, %v; want only the owner's counted", got, err)
+	}
+}
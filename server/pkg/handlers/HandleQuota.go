@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/account"
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/auth"
+	"arnavsurve/nara-chess/server/pkg/ratelimit"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// QuotaHeader prefixes the headers describing the caller's daily model call quota, apart
+// from the RateLimit headers of the request rate.
+const QuotaHeader = "X-Quota"
+
+// callerOf is whom the request with ctx is made for: the signed-in user, else the API key
+// or client address the key middleware let it through as. It is empty for background
+// work.
+func callerOf(ctx context.Context) string {
+	if userID := account.UserID(ctx); userID != "" {
+		return userID
+	}
+	return auth.Caller(ctx)
+}
+
+// quotaCaller is whom r's model calls are charged to: callerOf, else r's client address.
+func quotaCaller(r *http.Request) string {
+	if caller := callerOf(r.Context()); caller != "" {
+		return caller
+	}
+	return "ip:" + ratelimit.ClientIP(r, false)
+}
+
+// MeterModelCalls charges the model calls of next, a model-backed endpoint, to the
+// caller's daily quota. Callers with none left are refused with 429 and Retry-After up
+// front; a request that runs out part way fails its next model call.
+func (h *Handler) MeterModelCalls(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.Quota == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		caller := quotaCaller(r)
+		status := h.Quota.Status(caller)
+		status.SetHeaders(w.Header(), QuotaHeader)
+		if !status.Allowed {
+			apierror.Write(w, http.StatusTooManyRequests, apierror.QuotaExceeded,
+				fmt.Sprintf("Daily quota of %d model calls exhausted", status.Limit))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ai.WithMeter(r.Context(), h.Quota.Meter(caller))))
+	})
+}
+
+// HandleQuota reports the caller's model call quota for today.
+func (h *Handler) HandleQuota(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if h.Quota == nil {
+		writeJSON(w, types.QuotaResponse{})
+		return
+	}
+	status := h.Quota.Status(quotaCaller(r))
+	status.SetHeaders(w.Header(), QuotaHeader)
+	resetAt := time.Now().UTC().Add(status.Reset).Round(time.Second)
+	writeJSON(w, types.QuotaResponse{
+		Limit:     status.Limit,
+		Used:      status.Limit - status.Remaining,
+		Remaining: status.Remaining,
+		ResetAt:   &resetAt,
+	})
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/pgn"
+	"arnavsurve/nara-chess/server/pkg/repertoire"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// errNotInRepertoire rejects a drill answer for a position the repertoire doesn't reach.
+var errNotInRepertoire = errors.New("position is not in the repertoire")
+
+// HandleRepertoires registers an opening repertoire on POST, and on GET lists the
+// repertoires of the pupil named by ?user_id=.
+func (h *Handler) HandleRepertoires(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	if h.Repertoires == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Repertoires are not available")
+		return
+	}
+	if r.Method == http.MethodGet {
+		h.listRepertoires(w, r)
+		return
+	}
+
+	repertoireRequest, ok := decodeAndValidate[types.NewRepertoireRequest](w, r)
+	if !ok {
+		return
+	}
+	userID, err := pupilID(r, repertoireRequest.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	games, err := pgn.Parse(repertoireRequest.PGN)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, fmt.Sprintf("Invalid PGN: %v", err))
+		return
+	}
+	if len(games) == 0 {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, "PGN contains no lines")
+		return
+	}
+	if len(games) > types.MaxRepertoireLines {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, fmt.Sprintf("Repertoire may contain at most %d lines", types.MaxRepertoireLines))
+		return
+	}
+	side := chess.White
+	if repertoireRequest.Side == "black" {
+		side = chess.Black
+	}
+	now := time.Now().UTC()
+	lines, cards, err := repertoire.Build(games, side, now)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, "Invalid repertoire: "+err.Error())
+		return
+	}
+	if len(cards) == 0 {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, fmt.Sprintf("Repertoire has no moves for %s", repertoireRequest.Side))
+		return
+	}
+
+	rep := &store.Repertoire{
+		UserID:    userID,
+		Name:      repertoireRequest.Name,
+		Side:      repertoireRequest.Side,
+		Lines:     lines,
+		Cards:     cards,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := h.Repertoires.AddRepertoire(rep); err != nil {
+		slog.ErrorContext(r.Context(), "Error storing repertoire", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to store repertoire")
+		return
+	}
+	slog.InfoContext(r.Context(), "Stored repertoire", "repertoire_id", rep.ID, "lines", len(rep.Lines), "positions", len(rep.Cards))
+
+	writeJSON(w, repertoireView(rep, now))
+}
+
+func (h *Handler) listRepertoires(w http.ResponseWriter, r *http.Request) {
+	userID, err := pupilID(r, r.URL.Query().Get("user_id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if userID == "" {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "user_id", "Request must name the pupil (user_id query parameter)")
+		return
+	}
+	list, err := h.Repertoires.ListRepertoires(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing repertoires", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to list repertoires")
+		return
+	}
+	now := time.Now()
+	listResponse := types.RepertoiresResponse{Repertoires: make([]types.Repertoire, 0, len(list))}
+	for _, rep := range list {
+		listResponse.Repertoires = append(listResponse.Repertoires, repertoireView(rep, now))
+	}
+
+	writeJSON(w, listResponse)
+}
+
+// HandleGetRepertoire returns one repertoire with the pupil's recall of it.
+func (h *Handler) HandleGetRepertoire(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	rep, ok := h.loadRepertoire(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, repertoireView(rep, time.Now()))
+}
+
+// HandleRepertoireDrill quizzes the pupil on a repertoire. GET serves the position due for
+// review soonest; POST checks the pupil's move in a position against the repertoire and
+// schedules the position's next review.
+func (h *Handler) HandleRepertoireDrill(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.nextRepertoireDrill(w, r)
+	case http.MethodPost:
+		h.answerRepertoireDrill(w, r)
+	default:
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (h *Handler) nextRepertoireDrill(w http.ResponseWriter, r *http.Request) {
+	rep, ok := h.loadRepertoire(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	drillResponse := types.RepertoireDrillResponse{RepertoireID: rep.ID}
+	next := -1
+	for i, card := range rep.Cards {
+		if next < 0 || card.Card.DueAt.Before(rep.Cards[next].Card.DueAt) {
+			next = i
+		}
+	}
+	if next >= 0 {
+		card := rep.Cards[next]
+		if card.Card.DueAt.After(time.Now()) {
+			drillResponse.NextDueAt = &card.Card.DueAt
+		} else {
+			drill := drillView(rep, card)
+			drillResponse.Drill = &drill
+		}
+	}
+
+	writeJSON(w, drillResponse)
+}
+
+func (h *Handler) answerRepertoireDrill(w http.ResponseWriter, r *http.Request) {
+	answerRequest, ok := decodeAndValidate[types.RepertoireAnswerRequest](w, r)
+	if !ok {
+		return
+	}
+	if h.Repertoires == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Repertoires are not available")
+		return
+	}
+
+	pos, err := chess.ParseFEN(answerRequest.Fen)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+	m, err := notation.ParseMove(pos, answerRequest.Move)
+	if err != nil {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.IllegalMove, "move", "Illegal move: "+answerRequest.Move)
+		return
+	}
+	played := pos.SAN(m)
+	key := repertoire.Key(pos)
+
+	var answered store.RepertoireCard
+	var correct bool
+	now := time.Now().UTC()
+	rep, err := h.Repertoires.UpdateRepertoire(r.PathValue("id"), func(rep *store.Repertoire) error {
+		if canSee(r, rep.UserID) != nil {
+			return store.ErrRepertoireNotFound
+		}
+		i := slices.IndexFunc(rep.Cards, func(c store.RepertoireCard) bool { return c.Key == key })
+		if i < 0 {
+			return errNotInRepertoire
+		}
+		card := &rep.Cards[i]
+		correct = slices.Contains(card.Moves, played)
+		card.Attempts++
+		if correct {
+			card.Correct++
+		}
+		card.Card = card.Card.Review(correct, now)
+		answered = *card
+		return nil
+	})
+	if errors.Is(err, store.ErrRepertoireNotFound) {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Repertoire not found")
+		return
+	}
+	if errors.Is(err, errNotInRepertoire) {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "fen", "Position is not in the repertoire")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving repertoire drill", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to save repertoire")
+		return
+	}
+
+	writeJSON(w, types.RepertoireAnswerResponse{
+		Correct:    correct,
+		Played:     played,
+		Moves:      answered.Moves,
+		Drill:      drillView(rep, answered),
+		Repertoire: repertoireView(rep, now),
+	})
+}
+
+// loadRepertoire fetches the repertoire id for r, writing the error response when it
+// can't. A repertoire r may not see is reported as not found, like a game.
+func (h *Handler) loadRepertoire(w http.ResponseWriter, r *http.Request, id string) (*store.Repertoire, bool) {
+	if h.Repertoires == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Repertoires are not available")
+		return nil, false
+	}
+	rep, err := h.Repertoires.GetRepertoire(id)
+	if err == nil && canSee(r, rep.UserID) != nil {
+		err = store.ErrRepertoireNotFound
+	}
+	if errors.Is(err, store.ErrRepertoireNotFound) {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Repertoire not found")
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading repertoire", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load repertoire")
+		return nil, false
+	}
+	return rep, true
+}
+
+// repertoireView sums up rep, counting the positions due at now.
+func repertoireView(rep *store.Repertoire, now time.Time) types.Repertoire {
+	view := types.Repertoire{
+		ID:        rep.ID,
+		UserID:    rep.UserID,
+		Name:      rep.Name,
+		Side:      rep.Side,
+		Lines:     rep.Lines,
+		Positions: len(rep.Cards),
+		CreatedAt: rep.CreatedAt,
+	}
+	for _, card := range rep.Cards {
+		if !card.Card.DueAt.After(now) {
+			view.Due++
+		}
+		view.Attempts += card.Attempts
+		view.Correct += card.Correct
+	}
+	if view.Attempts > 0 {
+		view.Accuracy = math.Round(float64(view.Correct)*1000/float64(view.Attempts)) / 10
+	}
+	return view
+}
+
+func drillView(rep *store.Repertoire, card store.RepertoireCard) types.RepertoireDrill {
+	return types.RepertoireDrill{
+		Fen:      card.Fen,
+		Side:     rep.Side,
+		Line:     card.Line,
+		Attempts: card.Attempts,
+		Correct:  card.Correct,
+		DueAt:    card.Card.DueAt,
+	}
+}
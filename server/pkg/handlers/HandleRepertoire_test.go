@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRepertoireOtherUser(t *testing.T) {
+	h, _ := newTestHandler()
+	alice, bob := store.UserIDPrefix+"alice", store.UserIDPrefix+"bob"
+	w := serve(signIn(t, alice, h.HandleRepertoires), http.MethodPost, "/api/repertoire",
+		`{"user_id": "`+alice+`", "name": "Italian", "side": "white", "pgn": "1. e4 e5 2. Nf3 *"}`)
+	rep := decodeResponse[types.Repertoire](t, w, http.StatusOK)
+
+	request := func(handler http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.SetPathValue("id", rep.ID)
+		handler(w, r)
+		return w
+	}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		body    string
+	}{
+		{"get", h.HandleGetRepertoire, http.MethodGet, "/api/repertoire/" + rep.ID, ""},
+		{"next drill", h.HandleRepertoireDrill, http.MethodGet, "/api/repertoire/" + rep.ID + "/drill", ""},
+		{"answer drill", h.HandleRepertoireDrill, http.MethodPost, "/api/repertoire/" + rep.ID + "/drill", `{"fen": "` + chess.StartFEN + `", "move": "e4"}`},
+	}
+	for _, tt := range tests {
+		// Neither another user nor an anonymous pupil learns that alice's repertoire exists.
+		for who, handler := range map[string]http.HandlerFunc{"other user": signIn(t, bob, tt.handler), "anonymous": tt.handler} {
+			w := request(handler, tt.method, tt.target, tt.body)
+			if resp := decodeResponse[apierror.Response](t, w, http.StatusNotFound); resp.Error.Message != "Repertoire not found" {
+				t.Errorf("%s by %s: error = %+v, want the repertoire not found", tt.name, who, resp.Error)
+			}
+		}
+		if w := request(signIn(t, alice, tt.handler), tt.method, tt.target, tt.body); w.Code != http.StatusOK {
+			t.Errorf("%s by the owner: status = %d, want 200", tt.name, w.Code)
+		}
+	}
+}
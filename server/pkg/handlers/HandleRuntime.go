@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"log/slog"
+	"net/http"
+	"runtime"
+)
+
+// HandleRuntime reports goroutines, memory, model calls in flight and the depth of the
+// server's queues. It is mounted behind admin auth.
+func (h *Handler) HandleRuntime(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	response := types.RuntimeResponse{
+		Goroutines:         runtime.NumGoroutine(),
+		HeapBytes:          mem.HeapAlloc,
+		GCCycles:           mem.NumGC,
+		ModelCallsInFlight: ai.InFlight(),
+		ModelCallsQueued:   ai.Queued(),
+		BackgroundTasks:    int(h.backgroundTasks.Load()),
+		AnalysisJobs:       map[string]int{},
+	}
+	if h.Jobs != nil {
+		jobs, err := h.Jobs.CountJobs()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error counting analysis jobs", "err", err)
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to count analysis jobs")
+			return
+		}
+		response.AnalysisJobs = jobs
+	}
+	response.WatchedGames, response.GameSessions = h.Sessions.Count()
+	writeJSON(w, response)
+}
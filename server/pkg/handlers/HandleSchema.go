@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/schema"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+)
+
+// HandleSchema returns JSON Schema definitions for the request and response contracts,
+// generated from the Go types.
+func (h *Handler) HandleSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"$schema": schema.Draft,
+		"definitions": map[string]any{
+			"GameStateRequest":    schema.ForRequest(types.GameStateRequest{}),
+			"GameStateResponse":   schema.ForResponse(types.GameStateResponse{}),
+			"ChatMessageRequest":  schema.ForRequest(types.ChatMessageRequest{}),
+			"ChatMessageResponse": schema.ForResponse(types.ChatMessageResponse{}),
+		},
+	})
+}
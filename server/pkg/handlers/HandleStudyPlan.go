@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+var studyPlanResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "A structured study plan for a chess pupil.",
+	Properties: map[string]*genai.Schema{
+		"summary": {
+			Type:        genai.TypeString,
+			Description: "A short (2-3 sentence) overview of the pupil's main weaknesses.",
+		},
+		"themes": {
+			Type:        genai.TypeArray,
+			Description: "Two to four themes to drill, most important first.",
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"name":   {Type: genai.TypeString, Description: "Name of the theme, e.g. 'Hanging pieces'."},
+					"reason": {Type: genai.TypeString, Description: "Why this theme matters for this pupil, citing the stats."},
+					"drills": {Type: genai.TypeArray, Description: "Concrete exercises.", Items: &genai.Schema{Type: genai.TypeString}},
+				},
+				Required: []string{"name", "reason", "drills"},
+			},
+		},
+		"puzzle_motifs": {
+			Type:        genai.TypeArray,
+			Description: "Tactical puzzle motifs to practice, e.g. 'fork', 'back-rank mate'.",
+			Items:       &genai.Schema{Type: genai.TypeString},
+		},
+		"openings_to_review": {
+			Type:        genai.TypeArray,
+			Description: "Opening lines from the pupil's games worth reviewing.",
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"moves":  {Type: genai.TypeString, Description: "The opening moves exactly as given in the stats."},
+					"reason": {Type: genai.TypeString, Description: "What to review in this line."},
+				},
+				Required: []string{"moves", "reason"},
+			},
+		},
+	},
+	Required: []string{"summary", "themes", "puzzle_motifs", "openings_to_review"},
+}
+
+// HandleStudyPlan analyzes a batch of the pupil's games locally and has the model turn the
+// resulting statistics into an actionable study plan.
+func (h *Handler) HandleStudyPlan(w http.ResponseWriter, r *http.Request) {
+	studyPlanRequest, ok := decodeAndValidate[types.StudyPlanRequest](w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := analyzeGames(studyPlanRequest.Games)
+	if err != nil {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "games", err.Error())
+		return
+	}
+
+	statsJSON, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding study plan stats", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to build study plan")
+		return
+	}
+
+	ctx, cancel := h.requestContext(r, "studyPlan")
+	defer cancel()
+
+	promptText := fmt.Sprintf(`You are a patient chess coach writing a personal study plan for your pupil.
+
+Below are statistics computed by a chess engine over the pupil's %d most recent games. Centipawn loss measures how much worse each move was than the engine's choice. Motifs count recurring error patterns (hanging_piece: left a piece to be captured, missed_mate: had a forced mate and missed it, allowed_mate: walked into a forced mate, missed_tactic: missed a winning capture or check).
+
+Ground every recommendation in these numbers. Focus on the phases and motifs with the most errors. Only recommend reviewing openings listed in the stats, and copy their moves exactly.
+
+### Stats
+%s
+
+Respond ONLY with a JSON object matching the schema.`, stats.GamesAnalyzed, statsJSON)
+
+	slog.InfoContext(r.Context(), "Asking the model for a study plan", "games", stats.GamesAnalyzed)
+	jsonString, ok := h.generate(ctx, w, h.modelRequest("studyPlan", promptText, studyPlanResponseSchema))
+	if !ok {
+		return
+	}
+
+	var studyPlanResponse types.StudyPlanResponse
+	if err := json.Unmarshal([]byte(jsonString), &studyPlanResponse); err != nil {
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse study plan")
+		return
+	}
+	studyPlanResponse.Stats = stats
+
+	studyPlanResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, studyPlanResponse)
+}
+
+func analyzeGames(games []types.GameRecord) (*analysis.Weaknesses, error) {
+	stats := analysis.NewWeaknesses()
+	for i, game := range games {
+		initialFen := game.InitialFen
+		if initialFen == "" {
+			initialFen = chess.StartFEN
+		}
+		start, err := parseGameFEN(initialFen, game.Variant)
+		if err != nil {
+			return nil, fmt.Errorf("games[%d]: %s", i, types.FENMessage("initial_fen", err))
+		}
+
+		moves, err := analysis.AnalyzeGame(start, game.MoveHistory, analysis.DefaultDepth)
+		var illegal *chess.IllegalMoveError
+		if errors.As(err, &illegal) {
+			return nil, fmt.Errorf("games[%d]: illegal move %q at ply %d", i, illegal.Move, illegal.Ply)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("games[%d]: %v", i, err)
+		}
+
+		pupil := chess.White
+		if game.PupilSide == "black" {
+			pupil = chess.Black
+		}
+		stats.AddGame(game.MoveHistory, moves, pupil)
+	}
+	return stats, nil
+}
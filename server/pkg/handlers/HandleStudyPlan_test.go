@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const studyPlanReply = `{
+  "summary": "You leave your queen en prise early.",
+  "themes": [{"name": "Hanging pieces", "reason": "Two blunders lost material.", "drills": ["Check every capture before moving"]}],
+  "puzzle_motifs": ["hanging piece"],
+  "openings_to_review": [{"moves": "e4 e5 Qh5 Nc6 Qxf7+ Kxf7", "reason": "The early queen raid."}]
+}`
+
+func TestStudyPlanSeededGames(t *testing.T) {
+	h, provider := newTestHandler(studyPlanReply)
+	// The pupil gives the queen away in both games, once with each colour.
+	body := `{"games": [
+		{"move_history": ["e4", "e5", "Qh5", "Nc6", "Qxf7+", "Kxf7"], "pupil_side": "white"},
+		{"move_history": ["d4", "e5", "dxe5", "Qh4", "Nf3", "Qxf2+"], "pupil_side": "black"}
+	]}`
+	w := serve(h.HandleStudyPlan, http.MethodPost, "/api/study-plan", body)
+	resp := decodeResponse[types.StudyPlanResponse](t, w, http.StatusOK)
+
+	if resp.Stats == nil || resp.Stats.GamesAnalyzed != 2 {
+		t.Fatalf("stats = %+v, want both games analyzed", resp.Stats)
+	}
+	if resp.Stats.Blunders == 0 {
+		t.Error("stats count no blunders in games that give the queen away")
+	}
+	if resp.Summary == "" || len(resp.Themes) != 1 || resp.Themes[0].Name != "Hanging pieces" || len(resp.PuzzleMotifs) != 1 {
+		t.Errorf("plan = %+v, want the model's plan", resp)
+	}
+
+	if len(provider.requests) != 1 {
+		t.Fatalf("made %d model calls, want 1", len(provider.requests))
+	}
+	prompt := provider.requests[0].Prompt
+	for _, want := range []string{`"games_analyzed": 2`, `"blunders"`, "e4 e5 Qh5 Nc6"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt lacks the locally computed %s", want)
+		}
+	}
+}
+
+func TestStudyPlanRejectsIllegalGame(t *testing.T) {
+	h, provider := newTestHandler()
+	body := `{"games": [{"move_history": ["e4", "e4"], "pupil_side": "white"}]}`
+	if w := serve(h.HandleStudyPlan, http.MethodPost, "/api/study-plan", body); w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+	if len(provider.requests) != 0 {
+		t.Error("asked the model about an illegal game")
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/llm"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+var teachingLineResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "A narrated sequence of moves forming a short lesson.",
+	Properties: map[string]*genai.Schema{
+		"title": {
+			Type:        genai.TypeString,
+			Description: "A short title for the lesson.",
+		},
+		"steps": {
+			Type:        genai.TypeArray,
+			Description: "Consecutive moves for both sides, starting with the side to move.",
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"move": {
+						Type:        genai.TypeString,
+						Description: "The move in Standard Algebraic Notation (SAN).",
+					},
+					"comment": {
+						Type:        genai.TypeString,
+						Description: "One or two sentences explaining the idea behind the move.",
+					},
+					"arrows": llm.ArrowListSchema("One arrow highlighting the key idea after this move."),
+				},
+				Required: []string{"move", "comment"},
+			},
+		},
+	},
+	Required: []string{"title", "steps"},
+}
+
+// HandleTeachingLine asks the coach for a short narrated line from a position and only
+// returns it once every move has been replayed legally on the board.
+func (h *Handler) HandleTeachingLine(w http.ResponseWriter, r *http.Request) {
+	teachingLineRequest, ok := decodeGameRequest[types.TeachingLineRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	start, err := parseGameFEN(teachingLineRequest.Fen, teachingLineRequest.Variant)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+	if len(start.LegalMoves()) == 0 || wonOnHill(start) {
+		apierror.Write(w, http.StatusBadRequest, apierror.GameOver, "The game is over in this position")
+		return
+	}
+
+	theme := "the most instructive idea in the position"
+	if teachingLineRequest.Theme != "" {
+		theme = teachingLineRequest.Theme
+	}
+
+	ctx, cancel := h.requestContext(r, "teachingLine")
+	defer cancel()
+
+	prompt := fmt.Sprintf(`You are a chess coach preparing a short guided lesson. Starting from the position below, write a line of %d consecutive moves or fewer, alternating sides and starting with %s to move, that demonstrates %s.
+
+For each move give:
+- "move": the move in SAN, legal in the position reached after the previous steps
+- "comment": one or two sentences explaining the idea in plain language
+- "arrows": exactly one arrow ["from", "to"] highlighting what to look at next
+
+Refer to the pupil as "you" and yourself as "I". Do not use "we".
+
+FEN: %s
+
+Respond ONLY with a JSON object matching the schema.`, teachingLineRequest.MaxSteps, start.Turn, theme, teachingLineRequest.Fen)
+
+	var teachingLineResponse types.TeachingLineResponse
+	for {
+		slog.InfoContext(r.Context(), "Asking the model for a teaching line")
+		jsonString, ok := h.generate(ctx, w, h.modelRequest("teachingLine", prompt, teachingLineResponseSchema))
+		if !ok {
+			return
+		}
+
+		teachingLineResponse = types.TeachingLineResponse{}
+		if err := json.Unmarshal([]byte(jsonString), &teachingLineResponse); err != nil {
+			slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+			slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse teaching line")
+			return
+		}
+
+		illegal := replayTeachingLine(start, &teachingLineResponse, teachingLineRequest.MaxSteps)
+		if illegal == nil || !ai.BudgetFrom(ctx).Remaining() {
+			if illegal != nil {
+				slog.WarnContext(r.Context(), "Truncating teaching line at illegal move", "err", illegal)
+			}
+			break
+		}
+		slog.InfoContext(r.Context(), "Rejecting teaching line with illegal move, regenerating", "err", illegal)
+		prompt += fmt.Sprintf("\n\nYour previous line was rejected: %s is not legal at step %d. Check every move against the position.", illegal.Move, illegal.Ply)
+	}
+
+	if len(teachingLineResponse.Steps) == 0 {
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Analysis service failed to provide a legal line")
+		return
+	}
+
+	teachingLineResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, teachingLineResponse)
+}
+
+// replayTeachingLine caps the line at maxSteps, replays it from start and fills in the
+// canonical SAN and resulting FEN of each step. The line is truncated before the first
+// illegal move, which is returned.
+func replayTeachingLine(start *chess.Position, line *types.TeachingLineResponse, maxSteps int) *chess.IllegalMoveError {
+	if len(line.Steps) > maxSteps {
+		line.Steps = line.Steps[:maxSteps]
+	}
+
+	pos := start
+	for i := range line.Steps {
+		step := &line.Steps[i]
+		m, err := notation.ParseMove(pos, step.Move)
+		if err != nil {
+			illegal := &chess.IllegalMoveError{Ply: i + 1, Move: step.Move, Err: err}
+			line.Steps = line.Steps[:i]
+			return illegal
+		}
+		step.Move = pos.SAN(m)
+		step.Arrows = llm.SanitizeArrows(step.Arrows, nil, llm.ArrowBoards(pos, step.Move)...)
+		pos = pos.Play(m)
+		step.Fen = pos.FEN()
+	}
+	return nil
+}
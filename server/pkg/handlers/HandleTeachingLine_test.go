@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func teachingLineReply(moves ...string) string {
+	line := struct {
+		Title string                   `json:"title"`
+		Steps []types.TeachingLineStep `json:"steps"`
+	}{Title: "Italian Game"}
+	for _, m := range moves {
+		line.Steps = append(line.Steps, types.TeachingLineStep{Move: m, Comment: "Develop.", Arrows: [][2]string{{"e2", "e4"}}})
+	}
+	b, _ := json.Marshal(line)
+	return string(b)
+}
+
+// assertLegalLine replays every step of line from fen, checking each step's FEN.
+func assertLegalLine(t *testing.T, fen string, line types.TeachingLineResponse) {
+	t.Helper()
+	pos, err := chess.ParseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, step := range line.Steps {
+		m, err := pos.ParseSAN(step.Move)
+		if err != nil {
+			t.Fatalf("step %d: %s is illegal: %v", i+1, step.Move, err)
+		}
+		pos = pos.Play(m)
+		if step.Fen != pos.FEN() {
+			t.Errorf("step %d: fen = %q, want %q", i+1, step.Fen, pos.FEN())
+		}
+	}
+}
+
+func TestTeachingLineIsLegal(t *testing.T) {
+	h, provider := newTestHandler(teachingLineReply("e4", "e5", "nf3", "Nc6", "Bc4", "Bc5", "c3", "Nf6"))
+	w := serve(h.HandleTeachingLine, http.MethodPost, "/api/teaching-line", `{"fen": "`+chess.StartFEN+`", "max_steps": 6}`)
+	resp := decodeResponse[types.TeachingLineResponse](t, w, http.StatusOK)
+
+	if len(resp.Steps) != 6 {
+		t.Fatalf("got %d steps, want the line capped at max_steps (6)", len(resp.Steps))
+	}
+	if resp.Steps[2].Move != "Nf3" {
+		t.Errorf("step 3 = %q, want it in canonical SAN (Nf3)", resp.Steps[2].Move)
+	}
+	assertLegalLine(t, chess.StartFEN, resp)
+	if len(provider.requests) != 1 {
+		t.Errorf("made %d model calls, want 1", len(provider.requests))
+	}
+}
+
+func TestTeachingLineRegeneratesIllegalLine(t *testing.T) {
+	h, provider := newTestHandler(
+		teachingLineReply("e4", "e5", "Bb5", "Nc6", "Bxc6", "Ke6"),
+		teachingLineReply("e4", "e5", "Nf3", "Nc6", "Bb5", "a6"),
+	)
+	w := serve(h.HandleTeachingLine, http.MethodPost, "/api/teaching-line", `{"fen": "`+chess.StartFEN+`"}`)
+	resp := decodeResponse[types.TeachingLineResponse](t, w, http.StatusOK)
+
+	if len(provider.requests) != 2 {
+		t.Fatalf("made %d model calls, want a second after the illegal line", len(provider.requests))
+	}
+	if !strings.Contains(provider.requests[1].Prompt, "Ke6 is not legal at step 6") {
+		t.Error("retry prompt does not name the illegal move")
+	}
+	if len(resp.Steps) != 6 || resp.Steps[5].Move != "a6" {
+		t.Errorf("steps = %+v, want the whole regenerated line", resp.Steps)
+	}
+	assertLegalLine(t, chess.StartFEN, resp)
+}
+
+// Once the budget is spent the line is cut before its first illegal move.
+func TestTeachingLineTruncatesIllegalLine(t *testing.T) {
+	h, _ := newTestHandler(teachingLineReply("d4", "d5", "c4", "Qxc4", "Nc3"))
+	h.Config.MaxModelCalls = 1
+	w := serve(h.HandleTeachingLine, http.MethodPost, "/api/teaching-line", `{"fen": "`+chess.StartFEN+`"}`)
+	resp := decodeResponse[types.TeachingLineResponse](t, w, http.StatusOK)
+
+	if len(resp.Steps) != 3 {
+		t.Errorf("got %d steps, want the 3 before the illegal Qxc4", len(resp.Steps))
+	}
+	assertLegalLine(t, chess.StartFEN, resp)
+}
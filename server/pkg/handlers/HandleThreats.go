@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+)
+
+// HandleThreats lists the immediate tactical threats one side has in a position, found by
+// the move generator alone, for the board's threat overlay.
+func (h *Handler) HandleThreats(w http.ResponseWriter, r *http.Request) {
+	threatsRequest, ok := decodeGameRequest[types.ThreatsRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	pos, err := parseGameFEN(threatsRequest.Fen, threatsRequest.Variant)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+
+	by := analysisPerspective(threatsRequest.Side, pos.Turn, pos.Turn.Other())
+	threatsResponse := types.ThreatsResponse{
+		Side:    by.String(),
+		Threats: analysis.Threats(pos, by, pupilMateMoves),
+	}
+	if threatsResponse.Threats == nil {
+		threatsResponse.Threats = []analysis.Threat{}
+	}
+
+	writeJSON(w, threatsResponse)
+}
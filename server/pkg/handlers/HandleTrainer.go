@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"arnavsurve/nara-chess/server/pkg/masters"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// guessEngineMoves is how many of the engine's preferred moves a graded guess lists.
+const guessEngineMoves = 3
+
+// guessPoints scores each grade of guess.
+var guessPoints = map[string]int{
+	types.GuessMaster:   3,
+	types.GuessEngine:   2,
+	types.GuessPlayable: 1,
+	types.GuessMiss:     0,
+}
+
+var guessResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "The coach's explanation of a master's move.",
+	Properties: map[string]*genai.Schema{
+		"explanation": {
+			Type:        genai.TypeString,
+			Description: "2-4 sentences on the idea behind the master's move and how the pupil's guess compares.",
+		},
+	},
+	Required: []string{"explanation"},
+}
+
+type guessExplanation struct {
+	Explanation string `json:"explanation"`
+}
+
+// HandleTrainerGuess runs the guess-the-move trainer over the imported master games. GET
+// poses a move to guess: the game named by ?game_id=, or a random one, at ?ply=, or else
+// at the first move of ?side= (white by default). POST grades the pupil's guess against
+// the master's move and the engine's alternatives, and the coach explains the master's
+// idea.
+func (h *Handler) HandleTrainerGuess(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.guessPosition(w, r)
+	case http.MethodPost:
+		h.gradeGuess(w, r)
+	default:
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (h *Handler) guessPosition(w http.ResponseWriter, r *http.Request) {
+	if h.MasterGames == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "The guess-the-move trainer is not available")
+		return
+	}
+
+	query := r.URL.Query()
+	ply := 1
+	if v := query.Get("ply"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "ply", "ply must be a positive integer")
+			return
+		}
+		ply = n
+	} else {
+		switch query.Get("side") {
+		case "", "white":
+		case "black":
+			ply = 2
+		default:
+			apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "side", `side must be "white" or "black"`)
+			return
+		}
+	}
+
+	var g *store.MasterGame
+	var err error
+	if id := query.Get("game_id"); id != "" {
+		g, err = h.MasterGames.GetMasterGame(id)
+	} else {
+		g, err = h.randomMasterGame()
+	}
+	if errors.Is(err, store.ErrMasterGameNotFound) {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Master game not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading master game", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load master game")
+		return
+	}
+	if ply > len(g.Moves) {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "ply", fmt.Sprintf("Master game %s has %d plies", g.ID, len(g.Moves)))
+		return
+	}
+
+	pos, err := masterPosition(g, ply)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error replaying master game", "game_id", g.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load master game")
+		return
+	}
+	writeJSON(w, guessView(g, ply, pos))
+}
+
+// randomMasterGame picks a game from the library at random, or returns
+// ErrMasterGameNotFound when none have been imported.
+func (h *Handler) randomMasterGame() (*store.MasterGame, error) {
+	count, err := h.MasterGames.CountMasterGames()
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, store.ErrMasterGameNotFound
+	}
+	return h.MasterGames.MasterGameAt(rand.IntN(count))
+}
+
+func (h *Handler) gradeGuess(w http.ResponseWriter, r *http.Request) {
+	guessRequest, ok := decodeAndValidate[types.GuessMoveRequest](w, r)
+	if !ok {
+		return
+	}
+
+	if h.MasterGames == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "The guess-the-move trainer is not available")
+		return
+	}
+
+	g, err := h.MasterGames.GetMasterGame(guessRequest.GameID)
+	if errors.Is(err, store.ErrMasterGameNotFound) {
+		apierror.Write(w, http.StatusNotFound, apierror.NotFound, "Master game not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading master game", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load master game")
+		return
+	}
+	ply := guessRequest.Ply
+	if ply > len(g.Moves) {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "ply", fmt.Sprintf("Master game %s has %d plies", g.ID, len(g.Moves)))
+		return
+	}
+	pos, err := masterPosition(g, ply)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error replaying master game", "game_id", g.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load master game")
+		return
+	}
+	m, err := notation.ParseMove(pos, guessRequest.Move)
+	if err != nil {
+		apierror.WriteField(w, http.StatusBadRequest, apierror.IllegalMove, "move", "Illegal move: "+guessRequest.Move)
+		return
+	}
+
+	guessResponse := types.GuessMoveResponse{
+		GameID:      g.ID,
+		Ply:         ply,
+		Guess:       pos.SAN(m),
+		MasterMove:  g.Moves[ply-1],
+		EngineMoves: []string{},
+	}
+	guessAnalysis, err := analysis.AnalyzeMove(pos, guessResponse.Guess, analysis.DefaultDepth)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error analyzing guess in master game", "guess", guessResponse.Guess, "game_id", g.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to grade guess")
+		return
+	}
+	masterAnalysis, err := analysis.AnalyzeMove(pos, guessResponse.MasterMove, analysis.DefaultDepth)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error analyzing master move in master game", "move", guessResponse.MasterMove, "game_id", g.ID, "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to grade guess")
+		return
+	}
+	guessResponse.GuessLoss = guessAnalysis.Loss
+	guessResponse.MasterLoss = masterAnalysis.Loss
+	for _, res := range engine.TopMoves(pos, analysis.DefaultDepth, guessEngineMoves) {
+		guessResponse.EngineMoves = append(guessResponse.EngineMoves, pos.SAN(res.Move))
+	}
+	guessResponse.Grade = guessGrade(guessResponse, guessAnalysis)
+	guessResponse.Points = guessPoints[guessResponse.Grade]
+	if next := ply + 2; next <= len(g.Moves) {
+		nextPos, err := masterPosition(g, next)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error replaying master game", "game_id", g.ID, "err", err)
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to load master game")
+			return
+		}
+		view := guessView(g, next, nextPos)
+		guessResponse.Next = &view
+	}
+
+	ctx, cancel := h.requestContext(r, "trainerGuess")
+	defer cancel()
+
+	master := g.White
+	if pos.Turn == chess.Black {
+		master = g.Black
+	}
+	if master == "" {
+		master = "The master"
+	}
+	var guessText string
+	if guessResponse.Grade == types.GuessMaster {
+		guessText = "Your pupil guessed it correctly."
+	} else {
+		guessText = fmt.Sprintf("Your pupil guessed %s instead, which the engine grades as %s (centipawn loss %d against %d for the master's move).",
+			guessResponse.Guess, strings.ReplaceAll(guessResponse.Grade, "_", " "), guessResponse.GuessLoss, guessResponse.MasterLoss)
+	}
+
+	promptText := fmt.Sprintf(`You are a patient chess coach replaying a famous game with your pupil, who is trying to guess each of the master's moves.
+
+Game: %s
+Moves so far: %s
+Position (FEN): %s, %s to move
+
+%s played %s here. %s
+A chess engine's preferred moves in this position are: %s
+
+Explain in 2-4 sentences the idea behind %s: what it threatens, prepares or prevents, and why a strong player would choose it. If the pupil's guess differs, say briefly how it compares. Do not reveal any later moves of the game.
+
+Address the pupil as "you". Use clear, casual language.
+
+Respond ONLY with a JSON object matching the schema.`, masterGameTitle(g), movesOrNone(g.Moves[:ply-1]), pos.FEN(), pos.Turn,
+		master, guessResponse.MasterMove, guessText, strings.Join(guessResponse.EngineMoves, ", "), guessResponse.MasterMove)
+
+	jsonString, ok := h.generate(ctx, w, h.modelRequest("trainerGuess", promptText, guessResponseSchema))
+	if !ok {
+		return
+	}
+	var explanation guessExplanation
+	if err := json.Unmarshal([]byte(jsonString), &explanation); err != nil {
+		slog.ErrorContext(r.Context(), "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(r.Context(), "Unparseable model response", "response", jsonString)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to parse explanation")
+		return
+	}
+	guessResponse.Explanation = explanation.Explanation
+	guessResponse.Meta = responseMeta(ctx)
+
+	writeJSON(w, guessResponse)
+}
+
+// guessGrade grades a guess from its analysis. A guess other than the master's move
+// still counts as the engine's when it is one of the engine's preferred moves, or the
+// engine rates it no worse than the master's move.
+func guessGrade(resp types.GuessMoveResponse, guess analysis.MoveAnalysis) string {
+	switch {
+	case resp.Guess == resp.MasterMove:
+		return types.GuessMaster
+	case slices.Contains(resp.EngineMoves, resp.Guess) || resp.GuessLoss <= resp.MasterLoss:
+		return types.GuessEngine
+	case guess.Classification == analysis.ClassBest || guess.Classification == analysis.ClassGood:
+		return types.GuessPlayable
+	}
+	return types.GuessMiss
+}
+
+// masterPosition returns the position before the 1-based ply of g.
+func masterPosition(g *store.MasterGame, ply int) (*chess.Position, error) {
+	start := chess.NewGame()
+	if ply == 1 {
+		return start, nil
+	}
+	positions, err := chess.Replay(start, g.Moves[:ply-1])
+	if err != nil {
+		return nil, err
+	}
+	return positions[len(positions)-1], nil
+}
+
+func guessView(g *store.MasterGame, ply int, pos *chess.Position) types.GuessPositionResponse {
+	view := types.GuessPositionResponse{
+		Game: types.MasterGameInfo{
+			ID:     g.ID,
+			White:  g.White,
+			Black:  g.Black,
+			Event:  g.Event,
+			Site:   g.Site,
+			Date:   g.Date,
+			Result: g.Result,
+			Plies:  len(g.Moves),
+		},
+		Ply:         ply,
+		Side:        pos.Turn.String(),
+		Fen:         pos.FEN(),
+		MovesPlayed: slices.Clone(g.Moves[:ply-1]),
+	}
+	if ply > 1 {
+		view.LastMove = g.Moves[ply-2]
+	}
+	return view
+}
+
+// masterGameTitle describes g as "White vs Black, Event, Date", leaving out what is unknown.
+func masterGameTitle(g *store.MasterGame) string {
+	white, black := g.White, g.Black
+	if white == "" {
+		white = "Unknown"
+	}
+	if black == "" {
+		black = "Unknown"
+	}
+	parts := []string{white + " vs " + black}
+	for _, s := range []string{g.Event, g.Date} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func movesOrNone(moves []string) string {
+	if len(moves) == 0 {
+		return "(none yet)"
+	}
+	return strings.Join(moves, " ")
+}
+
+// HandleImportMasterGames loads a PGN collection of master games from the request body
+// into the guess-the-move trainer's library.
+func (h *Handler) HandleImportMasterGames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	if h.MasterGames == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "The guess-the-move trainer is not available")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidJSON, "Failed to read request body")
+		return
+	}
+	games, skipped, err := masters.Read(string(body))
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidPGN, fmt.Sprintf("Invalid PGN: %v", err))
+		return
+	}
+	if err := h.MasterGames.AddMasterGames(games); err != nil {
+		slog.ErrorContext(r.Context(), "Error importing master games", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to import master games")
+		return
+	}
+	slog.InfoContext(r.Context(), "Imported master games", "count", len(games), "skipped", skipped)
+
+	writeJSON(w, types.MasterGameImportResponse{Imported: len(games), Skipped: skipped})
+}
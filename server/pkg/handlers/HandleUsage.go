@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// recordUsage stores the tokens of a model call made by caller on behalf of endpoint,
+// with its estimated cost. A failure is only logged: the call has been made either way.
+func (h *Handler) recordUsage(caller, endpoint string, u ai.Usage) {
+	if h.Usage == nil {
+		return
+	}
+	rec := &store.UsageRecord{
+		UserID:       caller,
+		Endpoint:     endpoint,
+		Provider:     u.Provider,
+		Model:        u.Model,
+		InputTokens:  u.InputTokens,
+		OutputTokens: u.OutputTokens,
+		Cost:         h.Config.Prices.Cost(u),
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := h.Usage.RecordUsage(rec); err != nil {
+		slog.Error("Error recording model usage", "err", err)
+	}
+}
+
+// HandleUsage sums the model calls of the last ?days= days (30 by default) by user,
+// endpoint and model, so the operator can see where the money goes. It is mounted behind
+// admin auth.
+func (h *Handler) HandleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return
+	}
+	if h.Usage == nil {
+		apierror.Write(w, http.StatusServiceUnavailable, apierror.Unavailable, "Usage accounting is not available")
+		return
+	}
+	days := types.DefaultUsageDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > types.MaxUsageDays {
+			apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidRequest, "days", fmt.Sprintf("days must be between 1 and %d", types.MaxUsageDays))
+			return
+		}
+		days = n
+	}
+	since := time.Now().UTC().AddDate(0, 0, -days)
+
+	usageResponse := types.UsageResponse{Since: since, Total: types.UsageTotal{Key: "total"}}
+	for _, grouping := range []struct {
+		group string
+		into  *[]types.UsageTotal
+	}{
+		{store.UsageByUser, &usageResponse.ByUser},
+		{store.UsageByEndpoint, &usageResponse.ByEndpoint},
+		{store.UsageByModel, &usageResponse.ByModel},
+	} {
+		totals, err := h.Usage.UsageTotals(grouping.group, since)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error summing model usage", "group", grouping.group, "err", err)
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Failed to sum model usage")
+			return
+		}
+		*grouping.into = make([]types.UsageTotal, len(totals))
+		for i, t := range totals {
+			(*grouping.into)[i] = types.UsageTotal(t)
+		}
+	}
+	// Every call has exactly one model, so the model totals add up to the whole.
+	for _, t := range usageResponse.ByModel {
+		usageResponse.Total.Calls += t.Calls
+		usageResponse.Total.InputTokens += t.InputTokens
+		usageResponse.Total.OutputTokens += t.OutputTokens
+		usageResponse.Total.Cost += t.Cost
+	}
+
+	writeJSON(w, usageResponse)
+}
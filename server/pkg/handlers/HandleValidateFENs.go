@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"errors"
+	"net/http"
+)
+
+// HandleValidateFENs checks a batch of FENs, reporting each one's syntax errors or
+// illegal-position problems. Results are returned in request order.
+func (h *Handler) HandleValidateFENs(w http.ResponseWriter, r *http.Request) {
+	validateFENsRequest, ok := decodeAndValidate[types.ValidateFENsRequest](w, r)
+	if !ok {
+		return
+	}
+
+	validateFENsResponse := types.ValidateFENsResponse{
+		Results: make([]types.FENValidation, len(validateFENsRequest.Fens)),
+	}
+	for i, fen := range validateFENsRequest.Fens {
+		result := types.FENValidation{Index: i}
+		pos, err := chess.ValidateFEN(fen)
+		var positionErr *chess.PositionError
+		switch {
+		case err == nil:
+			result.Valid = true
+			result.Fen = pos.FEN()
+			validateFENsResponse.Valid++
+		case errors.As(err, &positionErr):
+			result.Reasons = positionErr.Problems
+			validateFENsResponse.Invalid++
+		default:
+			result.Reasons = []string{err.Error()}
+			validateFENsResponse.Invalid++
+		}
+		validateFENsResponse.Results[i] = result
+	}
+
+	writeJSON(w, validateFENsResponse)
+}
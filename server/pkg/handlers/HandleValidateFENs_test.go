@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateFENs(t *testing.T) {
+	fens := []string{
+		chess.StartFEN,
+		"not a fen",
+		"7k/8/8/8/8/8/8/K6K w - - 0 1",
+		// Extra spaces are tidied away in the normalized FEN.
+		"7k/8/8/8/8/8/8/K7  w - - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1",
+		"P7/8/8/8/8/8/8/8 w - - 0 1",
+	}
+	body, err := json.Marshal(types.ValidateFENsRequest{Fens: fens})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ := newTestHandler()
+	w := serve(h.HandleValidateFENs, http.MethodPost, "/api/validateFens", string(body))
+	resp := decodeResponse[types.ValidateFENsResponse](t, w, http.StatusOK)
+
+	if resp.Valid != 2 || resp.Invalid != 4 || len(resp.Results) != len(fens) {
+		t.Fatalf("response = %+v, want 2 valid and 4 invalid", resp)
+	}
+	want := []struct {
+		valid   bool
+		fen     string
+		reasons []string
+	}{
+		{true, chess.StartFEN, nil},
+		{false, "", []string{""}},
+		{false, "", []string{"white has 2 kings"}},
+		{true, "7k/8/8/8/8/8/8/K7 w - - 0 1", nil},
+		{false, "", []string{""}},
+		{false, "", []string{"white pawn on a8", "white has 0 kings", "black has 0 kings"}},
+	}
+	for i, got := range resp.Results {
+		if got.Index != i || got.Valid != want[i].valid || got.Fen != want[i].fen || len(got.Reasons) != len(want[i].reasons) {
+			t.Errorf("result %d = %+v, want %+v", i, got, want[i])
+			continue
+		}
+		for j, reason := range want[i].reasons {
+			if !strings.Contains(got.Reasons[j], reason) || got.Reasons[j] == "" {
+				t.Errorf("result %d reason %d = %q, want %q", i, j, got.Reasons[j], reason)
+			}
+		}
+	}
+}
+
+func TestValidateFENsRejectsBatch(t *testing.T) {
+	tooMany := make([]string, types.MaxValidateFENs+1)
+	for i := range tooMany {
+		tooMany[i] = chess.StartFEN
+	}
+	body, err := json.Marshal(types.ValidateFENsRequest{Fens: tooMany})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, body := range map[string]string{
+		"empty":    `{"fens": []}`,
+		"too many": string(body),
+	} {
+		t.Run(name, func(t *testing.T) {
+			h, _ := newTestHandler()
+			w := serve(h.HandleValidateFENs, http.MethodPost, "/api/validateFens", body)
+			resp := decodeResponse[apierror.Response](t, w, http.StatusBadRequest)
+			if resp.Error.Field != "fens" {
+				t.Errorf("error = %+v, want fens rejected", resp.Error)
+			}
+		})
+	}
+}
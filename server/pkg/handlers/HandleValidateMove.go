@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+)
+
+func (h *Handler) HandleValidateMove(w http.ResponseWriter, r *http.Request) {
+	validateMoveRequest, ok := decodeGameRequest[types.ValidateMoveRequest](h, w, r)
+	if !ok {
+		return
+	}
+
+	pos, err := parseGameFEN(validateMoveRequest.Fen, validateMoveRequest.Variant)
+	if err != nil {
+		writeFENError(w, err)
+		return
+	}
+
+	var validateMoveResponse types.ValidateMoveResponse
+	m, err := notation.ParseMove(pos, validateMoveRequest.Move)
+	if wonOnHill(pos) {
+		validateMoveResponse.Reason = "the game is already won on the hill"
+	} else if err != nil {
+		validateMoveResponse.Reason = err.Error()
+	} else {
+		info := describeMove(pos, m)
+		validateMoveResponse.Legal = true
+		validateMoveResponse.Move = &info
+		validateMoveResponse.FenAfter = pos.Play(m).FEN()
+		validateMoveResponse.StatusAfter = gameStatus(pos, pos.Play(m))
+	}
+
+	writeJSON(w, validateMoveResponse)
+}
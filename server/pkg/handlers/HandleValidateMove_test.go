@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func validateMove(t *testing.T, h *Handler, req types.ValidateMoveRequest) types.ValidateMoveResponse {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := serve(h.HandleValidateMove, http.MethodPost, "/api/validateMove", string(body))
+	return decodeResponse[types.ValidateMoveResponse](t, w, http.StatusOK)
+}
+
+func TestValidateMoveVariant(t *testing.T) {
+	h, _ := newTestHandler()
+
+	if resp := validateMove(t, h, types.ValidateMoveRequest{Fen: hillFEN, Move: "Kd4"}); !resp.Legal || resp.StatusAfter != nil {
+		t.Errorf("standard Kd4 = %+v, want legal with the game going on", resp)
+	}
+	resp := validateMove(t, h, types.ValidateMoveRequest{Fen: hillFEN, Move: "Kd4", Variant: "king_of_the_hill"})
+	if !resp.Legal || resp.StatusAfter == nil || resp.StatusAfter.Reason != chess.ReasonKingOfTheHill {
+		t.Errorf("King of the Hill Kd4 = %+v, want it to win on the hill", resp)
+	}
+}
+
+func TestValidateMoveVariantGame(t *testing.T) {
+	h, _ := newTestHandler()
+	game := storeHillGame(t, h, "Kd4")
+
+	// Qh2 would be legal in a standard game, but this one was won on the hill.
+	resp := validateMove(t, h, types.ValidateMoveRequest{GameID: game.ID, Move: "Qh2"})
+	if resp.Legal || resp.Reason == "" {
+		t.Errorf("Qh2 after the game was won = %+v, want it rejected with a reason", resp)
+	}
+}
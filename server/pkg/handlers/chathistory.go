@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// maxSummaryTokens caps the summary of a conversation's older messages, leaving the rest
+// of the budget to the recent ones.
+const maxSummaryTokens = 400
+
+const chatSummaryPrompt = `You are a chess coach keeping notes on a long conversation with your pupil, so you can carry on with it once its older messages are gone.
+
+Rewrite your notes so far to also cover the messages below. Keep what matters for the rest of the conversation: the questions the pupil asked and your answers, the plans and ideas discussed, what the pupil struggles with or wants to work on, and anything you promised to come back to. Leave out greetings and small talk.
+
+Write at most %d words, in the third person ("the pupil asked about...").
+
+### Notes so far
+%s
+
+### Messages to add, oldest first
+%s
+
+### Response Format
+Respond ONLY with a JSON object in the following format:
+
+{
+  "summary": "..."
+}`
+
+var chatSummarySchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"summary": {
+			Type:        genai.TypeString,
+			Description: "The notes on the conversation so far.",
+		},
+	},
+	Required: []string{"summary"},
+}
+
+// estimateTokens approximates the tokens a model counts in s at four characters apiece,
+// close enough for English prose and FENs to keep prompts within a budget without asking
+// the provider.
+func estimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + 3) / 4
+}
+
+// fitChatHistory keeps req's chat prompt within Config.ChatTokenBudget. A conversation
+// that fits is left alone; otherwise its older messages are dropped from
+// req.MessageHistory and the returned summary of them takes their place in the prompt.
+// The summary is kept with the session, so later requests reuse it until the messages
+// after it outgrow the budget in turn and it is brought up to date.
+//
+// A summary that can't be brought up to date costs the conversation the messages it would
+// have added rather than failing the request.
+func (h *Handler) fitChatHistory(ctx context.Context, req *types.ChatMessageRequest, profile *store.Profile) string {
+	history := req.MessageHistory
+	if len(history) == 0 {
+		return ""
+	}
+	bare := *req
+	bare.MessageHistory = nil
+	available := h.Config.ChatTokenBudget - estimateTokens(chatPrompt(bare, "", profile))
+	if historyTokens(history) <= available {
+		return ""
+	}
+
+	session := req.SessionID
+	if session == "" {
+		session = req.GameState.GameID
+	}
+	var stored *store.ChatSummary
+	if session != "" && h.ChatSummaries != nil {
+		s, err := h.ChatSummaries.GetChatSummary(session)
+		switch {
+		case err == nil:
+			if s.Covered < len(history) && s.Digest == chatDigest(history[:s.Covered]) {
+				stored = s
+			}
+		case !errors.Is(err, store.ErrChatSummaryNotFound):
+			slog.ErrorContext(ctx, "Error loading chat summary", "session_id", session, "err", err)
+		}
+	}
+	if stored != nil && historyTokens(history[stored.Covered:]) <= available-estimateTokens(formatChatSummary(stored.Summary)) {
+		req.MessageHistory = history[stored.Covered:]
+		return stored.Summary
+	}
+
+	// Keep only half the room for recent messages, so the summary lasts a few turns
+	// before it needs bringing up to date again.
+	summaryRoom := min(maxSummaryTokens, max(available, 0)/4)
+	covered := len(history) - recentMessages(history, (available-summaryRoom)/2)
+	from, notes := 0, ""
+	if stored != nil && stored.Covered <= covered {
+		from, notes = stored.Covered, stored.Summary
+	}
+
+	req.MessageHistory = history[covered:]
+	if covered == from || summaryRoom == 0 {
+		return notes
+	}
+	summary, err := h.summarizeChat(ctx, notes, history[from:covered], summaryRoom)
+	if err != nil {
+		slog.WarnContext(ctx, "Error summarizing chat history, dropping older messages", "session_id", session,
+			"dropped", covered-from, "err", err)
+		return notes
+	}
+	slog.InfoContext(ctx, "Summarized older chat messages", "session_id", session, "covered", covered,
+		"kept", len(history)-covered)
+	if session != "" && h.ChatSummaries != nil {
+		err := h.ChatSummaries.PutChatSummary(&store.ChatSummary{
+			SessionID: session,
+			Covered:   covered,
+			Digest:    chatDigest(history[:covered]),
+			Summary:   summary,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error saving chat summary", "session_id", session, "err", err)
+		}
+	}
+	return summary
+}
+
+// summarizeChat asks the model to fold messages into notes, an earlier summary, keeping
+// the result within maxTokens.
+func (h *Handler) summarizeChat(ctx context.Context, notes string, messages []types.ChatMessage, maxTokens int) (string, error) {
+	if notes == "" {
+		notes = "(none yet)"
+	}
+	// Words run a little over a token each.
+	promptText := fmt.Sprintf(chatSummaryPrompt, maxTokens*3/4, notes, formatChatHistory(messages))
+	slog.DebugContext(ctx, "Chat summary prompt", "prompt", promptText)
+	jsonString, err := h.callModel(ctx, h.modelRequest("chatSummary", promptText, chatSummarySchema))
+	if err != nil {
+		return "", err
+	}
+	var response struct {
+		Summary string `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(jsonString), &response); err != nil {
+		return "", fmt.Errorf("parsing chat summary: %w", err)
+	}
+	summary := strings.TrimSpace(response.Summary)
+	if summary == "" {
+		return "", errors.New("empty chat summary")
+	}
+	if runes := []rune(summary); estimateTokens(summary) > maxTokens {
+		summary = string(runes[:maxTokens*4]) + "…"
+	}
+	return summary, nil
+}
+
+// formatChatSummary introduces summary in the chat transcript, ahead of the messages it
+// leaves out.
+func formatChatSummary(summary string) string {
+	if summary == "" {
+		return ""
+	}
+	return "(Summary of the earlier conversation: " + summary + ")\n"
+}
+
+// recentMessages returns how many of the newest messages fit in tokens, at least the
+// newest one, which the coach is replying to.
+func recentMessages(history []types.ChatMessage, tokens int) int {
+	n := 1
+	used := historyTokens(history[len(history)-1:])
+	for n < len(history) {
+		next := historyTokens(history[len(history)-n-1 : len(history)-n])
+		if used+next > tokens {
+			break
+		}
+		used += next
+		n++
+	}
+	return n
+}
+
+// historyTokens estimates the tokens messages take in the chat prompt.
+func historyTokens(messages []types.ChatMessage) int {
+	return estimateTokens(formatChatHistory(messages))
+}
+
+// chatDigest identifies messages, to tell whether a stored summary still describes the
+// start of a session's history.
+func chatDigest(messages []types.ChatMessage) string {
+	sum := sha256.New()
+	for _, msg := range messages {
+		json.NewEncoder(sum).Encode(msg)
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
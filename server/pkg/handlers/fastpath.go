@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"arnavsurve/nara-chess/server/pkg/utils"
+	"fmt"
+	"strings"
+)
+
+const (
+	SourceModel  = "model"
+	SourceForced = "forced"
+	// SourceHybrid marks a move chosen by Handler.Engine and explained by the model.
+	SourceHybrid = "hybrid"
+	// SourceEngine marks a move chosen by the local engine because the model was unavailable.
+	SourceEngine = "engine"
+	// SourceGameOver marks a reply to a finished game, which has no move.
+	SourceGameOver = "game_over"
+)
+
+const (
+	// forcedSearchDepth is deep enough to see that declining a recapture loses material.
+	forcedSearchDepth = 3
+	// A recapture is only played without the model when every alternative is at least
+	// this much worse.
+	forcedRecaptureMargin = 200
+	// fallbackSearchDepth is used when the local engine stands in for the model.
+	fallbackSearchDepth = 4
+)
+
+// forcedMove returns a move that can be played without consulting the model: the only
+// legal move, or a recapture on the square of the opponent's last capture that the local
+// engine rates clearly above every alternative.
+func forcedMove(pos *chess.Position, history []string) (chess.Move, string, bool) {
+	legal := pos.LegalMoves()
+	if len(legal) == 1 {
+		return legal[0], "This is the only legal move, so I play it.", true
+	}
+	if len(history) == 0 {
+		return chess.Move{}, "", false
+	}
+
+	last := utils.NormalizeSAN(history[len(history)-1])
+	if !strings.Contains(last, "x") {
+		return chess.Move{}, "", false
+	}
+	target, ok := captureSquare(last)
+	if !ok {
+		return chess.Move{}, "", false
+	}
+
+	var recaptures, others []chess.Move
+	for _, m := range legal {
+		if m.To == target && pos.IsCapture(m) {
+			recaptures = append(recaptures, m)
+		} else {
+			others = append(others, m)
+		}
+	}
+	if len(recaptures) == 0 || len(others) == 0 {
+		return chess.Move{}, "", false
+	}
+
+	best := engine.SearchMoves(pos, forcedSearchDepth, recaptures)
+	alt := engine.SearchMoves(pos, forcedSearchDepth, others)
+	if best.Score-alt.Score < forcedRecaptureMargin {
+		return chess.Move{}, "", false
+	}
+	comment := fmt.Sprintf("I recapture on %s — anything else would just leave me down material.", target)
+	return best.Move, comment, true
+}
+
+// captureSquare extracts the destination square of a normalized SAN capture such as
+// "Nxe5", "exd8=Q+" or "Bxf7#".
+func captureSquare(san string) (chess.Square, bool) {
+	s := strings.TrimRight(san, "+#")
+	if i := strings.Index(s, "="); i >= 0 {
+		s = s[:i]
+	}
+	if len(s) < 2 {
+		return chess.NoSquare, false
+	}
+	sq, err := chess.ParseSquare(s[len(s)-2:])
+	return sq, err == nil
+}
+
+// fallbackMove picks a move with the local engine when the model is unavailable.
+func fallbackMove(pos *chess.Position) (chess.Move, bool) {
+	result := engine.Search(pos, fallbackSearchDepth)
+	return result.Move, len(result.PV) > 0
+}
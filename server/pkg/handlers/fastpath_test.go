@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+	"testing"
+)
+
+// replayed returns the position history reaches from the standard start.
+func replayed(t *testing.T, history ...string) *chess.Position {
+	t.Helper()
+	positions, err := chess.Replay(chess.NewGame(), history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) == 0 {
+		return chess.NewGame()
+	}
+	return positions[len(positions)-1]
+}
+
+// The black king's only move is Kg8.
+const singleMoveFEN = "7k/8/6K1/8/8/8/8/R7 b - - 0 1"
+
+func TestForcedMoveSingleLegalMove(t *testing.T) {
+	pos, err := chess.ParseFEN(singleMoveFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, comment, ok := forcedMove(pos, nil)
+	if !ok || pos.SAN(m) != "Kg8" {
+		t.Fatalf("forcedMove = %v, %v; want the only move Kg8", m, ok)
+	}
+	if comment == "" {
+		t.Error("forced move has no comment")
+	}
+}
+
+func TestForcedMoveRecapture(t *testing.T) {
+	history := []string{"e4", "e5", "Nf3", "Nc6", "Bb5", "a6", "Bxc6"}
+	pos := replayed(t, history...)
+	m, _, ok := forcedMove(pos, history)
+	if !ok {
+		t.Fatal("forcedMove passed on recapturing the bishop")
+	}
+	if san := pos.SAN(m); san != "dxc6" && san != "bxc6" {
+		t.Errorf("forcedMove played %s, want a recapture on c6", san)
+	}
+}
+
+func TestForcedMoveNotForced(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []string
+	}{
+		{"opening position", nil},
+		{"last move no capture", []string{"e4", "e5", "Nf3"}},
+		// Black needn't recapture at once: the queen can take back on d5 later.
+		{"recapture one of several good moves", []string{"e4", "d5", "exd5", "Nf6", "c4", "c6", "dxc6"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos := replayed(t, tt.history...)
+			if m, _, ok := forcedMove(pos, tt.history); ok {
+				t.Errorf("forcedMove played %s in an open position", pos.SAN(m))
+			}
+		})
+	}
+}
+
+func TestGenerateMoveForcedSkipsModel(t *testing.T) {
+	h, provider := newTestHandler()
+	w := serve(h.HandleGenerateMove, http.MethodPost, "/api/generateMove",
+		`{"fen": "`+singleMoveFEN+`", "initial_fen": "`+singleMoveFEN+`", "move_history": []}`)
+	resp := decodeResponse[types.GameStateResponse](t, w, http.StatusOK)
+
+	if resp.Move != "Kg8" || resp.Source != SourceForced {
+		t.Errorf("reply = %s from %q, want the forced Kg8", resp.Move, resp.Source)
+	}
+	if len(provider.requests) != 0 {
+		t.Errorf("made %d model calls for a forced move", len(provider.requests))
+	}
+}
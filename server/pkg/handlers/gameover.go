@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+var gameOverResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "The coach's closing words on a finished game.",
+	Properties: map[string]*genai.Schema{
+		"summary": {
+			Type:        genai.TypeString,
+			Description: "1-2 sentences to the pupil about how the game ended.",
+		},
+	},
+	Required: []string{"summary"},
+}
+
+type gameOverSummary struct {
+	Summary string `json:"summary"`
+}
+
+// gamePositions replays history from initialFen, the standard start when empty, under
+// pos's variant so the draw rules can count repetitions. Without history, or when it does
+// not replay, the game is just pos.
+func gamePositions(initialFen string, history []string, pos *chess.Position) []*chess.Position {
+	if len(history) == 0 {
+		return []*chess.Position{pos}
+	}
+	if initialFen == "" {
+		initialFen = chess.StartFEN
+	}
+	start, err := chess.ParseVariantFEN(initialFen, pos.Variant)
+	if err != nil {
+		return []*chess.Position{pos}
+	}
+	positions, err := chess.Replay(start, history)
+	if err != nil {
+		return []*chess.Position{pos}
+	}
+	return append([]*chess.Position{start}, positions...)
+}
+
+// newGameOver describes a finished game with a stock summary for pupil until the coach
+// writes its own.
+func newGameOver(status chess.Status, pupil chess.Color) *types.GameOver {
+	gameOver := &types.GameOver{
+		GameStatus: types.GameStatus{Result: status.Result, Reason: status.Reason, Material: status.Material},
+	}
+	switch status.Result {
+	case "1-0":
+		gameOver.Winner = chess.White.String()
+	case "0-1":
+		gameOver.Winner = chess.Black.String()
+	}
+	switch {
+	case gameOver.Winner == "":
+		gameOver.Summary = fmt.Sprintf("The game is drawn by %s. Well played; a draw is a fair result here.", endingName(status.Reason))
+	case gameOver.Winner == pupil.String() && status.Reason == chess.ReasonKingOfTheHill:
+		gameOver.Summary = "Your king made it to the centre, so you win! Great game; that march was well timed."
+	case gameOver.Winner == pupil.String():
+		gameOver.Summary = "Checkmate, you win! Great game; that finish was well earned."
+	case status.Reason == chess.ReasonKingOfTheHill:
+		gameOver.Summary = "My king reached the centre, so I win this one. Keep an eye on the hill next time; let's look at where the game turned."
+	case status.Reason == chess.ReasonResignation:
+		gameOver.Summary = "Good game. Resigning a lost position is no shame; let's look at where the game turned and try again."
+	default:
+		gameOver.Summary = "Checkmate, I win this one. Don't be discouraged; let's look at where the game turned and try again."
+	}
+	return gameOver
+}
+
+// endingName describes how a game ended in words.
+func endingName(reason string) string {
+	switch reason {
+	case chess.ReasonStalemate:
+		return "stalemate"
+	case chess.ReasonInsufficientMaterial:
+		return "insufficient material"
+	case chess.ReasonFiftyMoveRule:
+		return "the fifty-move rule"
+	case chess.ReasonThreefoldRepetition:
+		return "threefold repetition"
+	case chess.ReasonDrawAgreed:
+		return "agreement"
+	case chess.ReasonKingOfTheHill:
+		return "reaching the centre with the king"
+	default:
+		return strings.ReplaceAll(reason, "_", " ")
+	}
+}
+
+// summarizeGameOver has the coach sum up a finished game for pupil, celebrating a win or
+// consoling a loss. The stock summary stands in when the model fails.
+func (h *Handler) summarizeGameOver(ctx context.Context, pos *chess.Position, history []string, pupil chess.Color, status chess.Status) *types.GameOver {
+	gameOver := newGameOver(status, pupil)
+
+	var outcome string
+	switch gameOver.Winner {
+	case "":
+		outcome = fmt.Sprintf("The game is drawn by %s. Sum it up warmly and fairly.", endingName(status.Reason))
+	case pupil.String():
+		outcome = fmt.Sprintf("Your pupil beat you by %s. Celebrate the win and name what they did well.", endingName(status.Reason))
+	default:
+		outcome = fmt.Sprintf("You beat your pupil by %s. Console them and point to one thing to learn from the game.", endingName(status.Reason))
+	}
+	prompt := fmt.Sprintf(`You are a chess coach who has just finished a game against your pupil, who played %s.
+
+Final position (FEN): %s
+Moves: %s
+%s
+
+In 1-2 sentences, speak to your pupil about how the game ended. Refer to the pupil as "you". Use clear, casual language.
+
+Respond ONLY with a JSON object matching the schema.`, pupil, pos.FEN(), strings.Join(history, " "), outcome)
+
+	jsonString, err := h.callModel(ctx, h.modelRequest("gameOver", prompt, gameOverResponseSchema))
+	var summary gameOverSummary
+	switch {
+	case err != nil:
+		slog.WarnContext(ctx, "Using stock game-over summary", "err", err)
+	case json.Unmarshal([]byte(jsonString), &summary) != nil || strings.TrimSpace(summary.Summary) == "":
+		slog.WarnContext(ctx, "Using stock game-over summary; unusable model response")
+		slog.DebugContext(ctx, "Unusable game-over summary", "response", jsonString)
+	default:
+		gameOver.Summary = summary.Summary
+	}
+	return gameOver
+}
+
+// gameOverReply answers a move request in a finished game without asking the model for a
+// move: the coach only sums up the game.
+func (h *Handler) gameOverReply(ctx context.Context, req types.GameStateRequest, pos *chess.Position, status chess.Status) types.GameStateResponse {
+	pupil := pos.Turn.Other() // the coach is always the side to move
+	gameOver := h.summarizeGameOver(ctx, pos, req.MoveHistory, pupil, status)
+	return types.GameStateResponse{
+		Comment:  gameOver.Summary,
+		Arrows:   [][2]string{},
+		Source:   SourceGameOver,
+		GameOver: gameOver,
+	}
+}
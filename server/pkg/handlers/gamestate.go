@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/openings"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"errors"
+	"fmt"
+)
+
+// parseGameFEN parses fen for a game played under variant, a chess.Variant name. An empty
+// variant leaves it to the FEN, so one with a pocket is Crazyhouse.
+func parseGameFEN(fen, variant string) (*chess.Position, error) {
+	if variant == "" {
+		return chess.ParseFEN(fen)
+	}
+	v, err := chess.ParseVariant(variant)
+	if err != nil {
+		return nil, err
+	}
+	return chess.ParseVariantFEN(fen, v)
+}
+
+// storedVariant is the name a game played under v is stored with; standard chess is
+// left empty.
+func storedVariant(v chess.Variant) string {
+	if v == chess.Standard {
+		return ""
+	}
+	return v.String()
+}
+
+// authoritativeFen derives the position a request is in by replaying history from
+// initialFen (the standard start when empty) instead of trusting the client's fen. Both
+// are read under variant, as parseGameFEN does. An
+// empty fen is filled in from the history; a fen that disagrees with it is rejected. When
+// Config.CorrectSideMismatch is set, a fen that only has the wrong side to move is
+// replaced by the derived position and a warning is returned. Without history, fen is the
+// only state there is and is returned as is once it parses. history is rewritten in place
+// in canonical SAN, so moves sent as UCI or with notation quirks read normally downstream.
+// Errors are *httpError values.
+func (h *Handler) authoritativeFen(variant, initialFen, fen string, history []string) (string, string, error) {
+	if len(history) == 0 {
+		if _, err := parseGameFEN(fen, variant); fen != "" && err != nil {
+			return "", "", badRequest(apierror.InvalidFEN, "fen", "%s", types.FENMessage("FEN", err))
+		}
+		return fen, "", nil
+	}
+
+	if initialFen == "" {
+		initialFen = chess.StartFEN
+	}
+	start, err := parseGameFEN(initialFen, variant)
+	if err != nil {
+		return "", "", badRequest(apierror.InvalidFEN, "initial_fen", "%s", types.FENMessage("initial_fen", err))
+	}
+	normalized, err := notation.NormalizeLine(start, history)
+	if err != nil {
+		var illegal *chess.IllegalMoveError
+		if errors.As(err, &illegal) {
+			return "", "", badRequest(apierror.IllegalMove, "move_history", "Inconsistent game state: move %d of move_history (%s) is illegal", illegal.Ply, illegal.Move)
+		}
+		return "", "", badRequest(apierror.IllegalMove, "move_history", "Invalid move_history")
+	}
+	copy(history, normalized)
+	positions, _ := chess.Replay(start, history)
+	derived := positions[len(positions)-1]
+	if fen == "" {
+		return derived.FEN(), "", nil
+	}
+
+	claimed, err := parseGameFEN(fen, variant)
+	if err != nil {
+		return "", "", badRequest(apierror.InvalidFEN, "fen", "%s", types.FENMessage("FEN", err))
+	}
+	if claimed.SamePosition(derived) {
+		return derived.FEN(), "", nil
+	}
+
+	// A FEN that is right apart from the side to move gets the more specific message.
+	flipped := *claimed
+	flipped.Turn = derived.Turn
+	flipped.EnPassant = derived.EnPassant
+	if claimed.Turn != derived.Turn && flipped.SamePosition(derived) {
+		mismatch := fmt.Sprintf("FEN has %q to move but a move history of %d plies implies %q", claimed.Turn, len(history), derived.Turn)
+		if !h.Config.CorrectSideMismatch {
+			return "", "", badRequest(apierror.InvalidRequest, "fen", "Inconsistent game state: %s", mismatch)
+		}
+		return derived.FEN(), "Side to move corrected: " + mismatch, nil
+	}
+	return "", "", badRequest(apierror.InvalidRequest, "fen", "Inconsistent game state: fen does not match move_history, which reaches %s", derived.FEN())
+}
+
+// gradePupilMove analyzes the last move of history, replayed under variant from initialFen
+// (the standard start when empty). It returns nil when there is no move or the history
+// doesn't replay; authoritativeFen reports the latter.
+func gradePupilMove(variant, initialFen string, history []string) *analysis.MoveAnalysis {
+	if len(history) == 0 {
+		return nil
+	}
+	if initialFen == "" {
+		initialFen = chess.StartFEN
+	}
+	start, err := parseGameFEN(initialFen, variant)
+	if err != nil {
+		return nil
+	}
+	positions, err := chess.Replay(start, history[:len(history)-1])
+	if err != nil {
+		return nil
+	}
+	pos := start
+	if len(positions) > 0 {
+		pos = positions[len(positions)-1]
+	}
+	ma, err := analysis.AnalyzeMove(pos, history[len(history)-1], analysis.DefaultDepth)
+	if err != nil {
+		return nil
+	}
+	ma.Ply = len(history)
+	return &ma
+}
+
+// classifyOpening names the opening of a game played under variant and replayed from
+// initialFen, the standard position when empty.
+func classifyOpening(initialFen, variant string, history []string) (openings.Opening, bool) {
+	if initialFen == "" {
+		initialFen = chess.StartFEN
+	}
+	start, err := parseGameFEN(initialFen, variant)
+	if err != nil {
+		return openings.Opening{}, false
+	}
+	return openings.Classify(start, history)
+}
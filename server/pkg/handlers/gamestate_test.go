@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const (
+	// afterE4 and afterE4E5 are the positions 1. e4 and 1. e4 e5 reach.
+	afterE4   = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"
+	afterE4E5 = "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2"
+	// afterE4WhiteToMove is afterE4 with the wrong side to move.
+	afterE4WhiteToMove = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 1"
+)
+
+func TestAuthoritativeFenConsistent(t *testing.T) {
+	tests := []struct {
+		name    string
+		fen     string
+		history []string
+		want    string
+	}{
+		{"one ply", afterE4, []string{"e4"}, afterE4},
+		{"two plies", afterE4E5, []string{"e4", "e5"}, afterE4E5},
+		{"fen filled in from the history", "", []string{"e4", "e5"}, afterE4E5},
+		{"no history", afterE4WhiteToMove, nil, afterE4WhiteToMove},
+	}
+	h := &Handler{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fen, warning, err := h.authoritativeFen("", "", tt.fen, tt.history)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fen != tt.want || warning != "" {
+				t.Errorf("authoritativeFen = %q, %q; want %q and no warning", fen, warning, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthoritativeFenSideMismatch(t *testing.T) {
+	h := &Handler{}
+	_, _, err := h.authoritativeFen("", "", afterE4WhiteToMove, []string{"e4"})
+	var herr *httpError
+	if !errors.As(err, &herr) {
+		t.Fatalf("err = %v, want an *httpError", err)
+	}
+	if herr.status != http.StatusBadRequest || herr.code != apierror.InvalidRequest || herr.field != "fen" {
+		t.Errorf("error = %d %s on %q, want 400 %s on fen", herr.status, herr.code, herr.field, apierror.InvalidRequest)
+	}
+	if !strings.Contains(herr.message, `FEN has "white" to move but a move history of 1 plies implies "black"`) {
+		t.Errorf("message = %q, want the side mismatch spelled out", herr.message)
+	}
+}
+
+func TestAuthoritativeFenCorrectsSideMismatch(t *testing.T) {
+	h := &Handler{Config: Config{CorrectSideMismatch: true}}
+	fen, warning, err := h.authoritativeFen("", "", afterE4WhiteToMove, []string{"e4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fen != afterE4 {
+		t.Errorf("fen = %q, want the derived %q", fen, afterE4)
+	}
+	if !strings.HasPrefix(warning, "Side to move corrected") {
+		t.Errorf("warning = %q, want the correction reported", warning)
+	}
+}
+
+// A FEN differing from the history by more than the side to move is never corrected.
+func TestAuthoritativeFenOtherMismatch(t *testing.T) {
+	h := &Handler{Config: Config{CorrectSideMismatch: true}}
+	_, _, err := h.authoritativeFen("", "", afterE4E5, []string{"d4", "d5"})
+	var herr *httpError
+	if !errors.As(err, &herr) || herr.status != http.StatusBadRequest || !strings.Contains(herr.message, "does not match") {
+		t.Errorf("err = %v, want a 400 for a FEN the history doesn't reach", err)
+	}
+}
+
+func TestAuthoritativeFenIllegalHistory(t *testing.T) {
+	h := &Handler{}
+	_, _, err := h.authoritativeFen("", "", "", []string{"e4", "e4"})
+	var herr *httpError
+	if !errors.As(err, &herr) || herr.code != apierror.IllegalMove || herr.field != "move_history" {
+		t.Errorf("err = %v, want an illegal move in move_history", err)
+	}
+}
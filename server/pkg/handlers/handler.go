@@ -0,0 +1,544 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/account"
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/auth"
+	"arnavsurve/nara-chess/server/pkg/cache"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/cors"
+	"arnavsurve/nara-chess/server/pkg/diagnostics"
+	"arnavsurve/nara-chess/server/pkg/explorer"
+	"arnavsurve/nara-chess/server/pkg/llm"
+	"arnavsurve/nara-chess/server/pkg/metrics"
+	"arnavsurve/nara-chess/server/pkg/ratelimit"
+	"arnavsurve/nara-chess/server/pkg/session"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/tablebase"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"arnavsurve/nara-chess/server/pkg/webhook"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Config struct {
+	Temperature float32
+	// Timeout bounds the model calls of a request, for endpoints whose profile sets none.
+	Timeout time.Duration
+	// MaxModelCalls caps the model calls a single request may make, retries included.
+	MaxModelCalls int
+	// MaxMoveAttempts caps how often /generateMove asks the model again after rejecting an
+	// illegal or stalemating move.
+	MaxMoveAttempts int
+	// ChatTokenBudget caps the estimated tokens of the chat prompt: once a conversation
+	// outgrows it, its older messages give way to a summary.
+	ChatTokenBudget int
+	// CorrectSideMismatch rewrites the FEN's side to move when it disagrees with the move
+	// history instead of rejecting the request.
+	CorrectSideMismatch bool
+	// MoveCacheSize and MoveCacheTTL bound the cache of coach replies filled by
+	// /generateMove and /ponder.
+	MoveCacheSize int
+	MoveCacheTTL  time.Duration
+	// AnalysisCacheSize and AnalysisCacheTTL bound each of the caches of the coach's
+	// analyses of a position, such as /evaluate's.
+	AnalysisCacheSize int
+	AnalysisCacheTTL  time.Duration
+	// Profiles overrides generation settings per endpoint, keyed by route name.
+	Profiles map[string]Profile
+	// Origins decides the browser origins allowed to open /ws/game.
+	Origins *cors.Policy
+	// HybridMoves lets Handler.Engine choose the coach's moves, leaving the model to write
+	// only the commentary. It has no effect without an engine.
+	HybridMoves bool
+	// ExplorerPrompt adds master game statistics from Handler.Explorer to the coach's
+	// prompt in the opening.
+	ExplorerPrompt bool
+	// LoginRedirect is the client page the browser returns to once signed in.
+	LoginRedirect string
+	// Prices estimates the cost of the model calls recorded in Handler.Usage.
+	Prices ai.Prices
+	// ResponseCache keeps the entries of the response caches where every server instance
+	// shares them, such as in Redis. When nil, New uses Games if it is a
+	// store.ResponseCache.
+	ResponseCache cache.Backing
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Temperature:       0.4,
+		Timeout:           60 * time.Second,
+		MaxModelCalls:     3,
+		MaxMoveAttempts:   3,
+		ChatTokenBudget:   8000,
+		MoveCacheSize:     256,
+		MoveCacheTTL:      10 * time.Minute,
+		AnalysisCacheSize: 1024,
+		AnalysisCacheTTL:  30 * 24 * time.Hour,
+		Origins:           cors.DefaultPolicy(),
+		LoginRedirect:     cors.DevOrigin,
+		Prices:            ai.DefaultPrices,
+	}
+}
+
+// Handler holds the dependencies shared by every endpoint.
+type Handler struct {
+	AI    ai.Provider
+	Games store.GameStore
+	// Puzzles keeps the puzzles mined from finished games. New uses Games when it can hold
+	// them; nil disables puzzles.
+	Puzzles store.PuzzleStore
+	// PuzzleLibrary holds the imported Lichess puzzles served by /puzzle/daily. Like
+	// Puzzles, New takes it from Games when it can.
+	PuzzleLibrary store.PuzzleLibrary
+	// Profiles keeps what the coach has learnt about each pupil. Like Puzzles, New takes it
+	// from Games when it can.
+	Profiles store.ProfileStore
+	// MasterGames holds the imported master games of the guess-the-move trainer. Like
+	// Puzzles, New takes it from Games when it can.
+	MasterGames store.MasterGameLibrary
+	// Repertoires keeps the pupils' opening repertoires. Like Puzzles, New takes it from
+	// Games when it can.
+	Repertoires store.RepertoireStore
+	// Jobs keeps the background analysis jobs and is the queue of StartAnalysisWorkers.
+	// Like Puzzles, New takes it from Games when it can.
+	Jobs store.AnalysisJobStore
+	// Users keeps the users with accounts. Like Puzzles, New takes it from Games when it
+	// can.
+	Users store.UserStore
+	// Usage records the tokens and estimated cost of every model call. Like Puzzles, New
+	// takes it from Games when it can; nil disables recording.
+	Usage store.UsageStore
+	// ChatSummaries keeps the summaries of long chat sessions. Like Puzzles, New takes it
+	// from Games when it can; without it a summary lasts one request.
+	ChatSummaries store.ChatSummaryStore
+	Config        Config
+	// Coach generates the coach's moves on top of AI.
+	Coach *llm.Service
+	// Engine is an external engine such as Stockfish, used when Config.HybridMoves is set.
+	Engine MoveEngine
+	// Tablebase supplies exact results for endgames with few pieces; nil disables probing.
+	Tablebase TablebaseProber
+	// Explorer serves /explorer and, with Config.ExplorerPrompt, master statistics for the
+	// coach's prompt; nil disables both.
+	Explorer OpeningExplorer
+	// Lichess and ChessCom serve /import/lichess/{username} and /import/chesscom/{username};
+	// nil disables either.
+	Lichess  GameArchive
+	ChessCom GameArchive
+	// Webhooks signs and sends the callbacks of analysis jobs; nil disables callbacks.
+	Webhooks *webhook.Sender
+	// Keys authenticates API requests and issues the keys of /apiKeys; nil disables
+	// issuing keys.
+	Keys *auth.KeyStore
+	// Accounts signs users in and tracks their sessions; nil disables sign-in.
+	Accounts *account.Service
+	// Quota caps each caller's model calls per day; nil leaves them unlimited.
+	Quota *ratelimit.Quota
+	// Sessions tracks the /ws/game connections attached to each game.
+	Sessions *session.Manager
+	// MoveCache holds coach replies, keyed by moveCacheKey. It and the other response
+	// caches keep their entries in Config.ResponseCache too, so they outlive restarts.
+	MoveCache *cache.Tiered[types.GameStateResponse]
+
+	// dailyPuzzles holds /puzzle/daily's response per UTC date.
+	dailyPuzzles *cache.Tiered[types.DailyPuzzleResponse]
+	// evaluations, candidates and developments hold the responses of /evaluate,
+	// /candidates and /developmentSuggestion keyed by analysisKey.
+	evaluations  *cache.Tiered[types.EvaluateResponse]
+	candidates   *cache.Tiered[types.CandidatesResponse]
+	developments *cache.Tiered[types.DevelopmentSuggestionResponse]
+	// blindfold holds the blindfold exercises awaiting an answer, keyed by exercise ID.
+	blindfold *cache.Tiered[blindfoldExercise]
+	// jobWake wakes an idle analysis worker when a job is queued.
+	jobWake chan struct{}
+	// stopping is cancelled by Shutdown; background work tracks itself in background.
+	stopping   context.Context
+	stop       context.CancelFunc
+	background sync.WaitGroup
+	// backgroundTasks counts the goroutines of goBackground still running.
+	backgroundTasks atomic.Int64
+
+	selfTestMu sync.Mutex
+	selfTest   *diagnostics.Report
+
+	// readyMu guards the providers' health as /readyz last saw it, which it reuses for
+	// providerReadyTTL rather than pinging them on every probe.
+	readyMu            sync.Mutex
+	providersReady     error
+	providersCheckedAt time.Time
+}
+
+func New(provider ai.Provider, games store.GameStore, cfg Config) *Handler {
+	puzzles, _ := games.(store.PuzzleStore)
+	library, _ := games.(store.PuzzleLibrary)
+	profiles, _ := games.(store.ProfileStore)
+	masters, _ := games.(store.MasterGameLibrary)
+	repertoires, _ := games.(store.RepertoireStore)
+	jobs, _ := games.(store.AnalysisJobStore)
+	users, _ := games.(store.UserStore)
+	usage, _ := games.(store.UsageStore)
+	chats, _ := games.(store.ChatSummaryStore)
+	responses := cfg.ResponseCache
+	if stored, ok := games.(store.ResponseCache); ok && responses == nil {
+		responses = stored
+	}
+	stopping, stop := context.WithCancel(context.Background())
+	h := &Handler{
+		AI:            provider,
+		Games:         games,
+		Puzzles:       puzzles,
+		PuzzleLibrary: library,
+		Profiles:      profiles,
+		MasterGames:   masters,
+		Repertoires:   repertoires,
+		Jobs:          jobs,
+		Users:         users,
+		Usage:         usage,
+		ChatSummaries: chats,
+		Config:        cfg,
+		Coach:         llm.New(provider),
+		Sessions:      session.NewManager(),
+		MoveCache:     cache.NewTiered[types.GameStateResponse]("generateMove", cfg.MoveCacheSize, cfg.MoveCacheTTL, responses),
+		dailyPuzzles:  cache.NewTiered[types.DailyPuzzleResponse]("dailyPuzzle", 2, dailyPuzzleTTL, responses),
+		evaluations:   cache.NewTiered[types.EvaluateResponse]("evaluate", cfg.AnalysisCacheSize, cfg.AnalysisCacheTTL, responses),
+		candidates:    cache.NewTiered[types.CandidatesResponse]("candidates", cfg.AnalysisCacheSize, cfg.AnalysisCacheTTL, responses),
+		developments:  cache.NewTiered[types.DevelopmentSuggestionResponse]("developmentSuggestion", cfg.AnalysisCacheSize, cfg.AnalysisCacheTTL, responses),
+		blindfold:     cache.NewTiered[blindfoldExercise]("blindfold", blindfoldCacheSize, types.BlindfoldTTL, responses),
+		jobWake:       make(chan struct{}, 1),
+		stopping:      stopping,
+		stop:          stop,
+	}
+	for name, stats := range h.cacheStats() {
+		metrics.RegisterCache(name, func() metrics.CacheStats { return metrics.CacheStats(stats()) })
+	}
+	return h
+}
+
+// Shutdown stops h's background work so the process can exit: WebSocket games are closed,
+// analysis workers stop after the move they are scoring, leaving their jobs to resume on
+// the next start, and game reviews and callbacks under way are waited for until ctx is
+// done.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	h.stop()
+	done := make(chan struct{})
+	go func() {
+		h.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// goBackground runs fn in a goroutine that Shutdown waits for.
+func (h *Handler) goBackground(fn func()) {
+	h.background.Add(1)
+	h.backgroundTasks.Add(1)
+	go func() {
+		defer h.background.Done()
+		defer h.backgroundTasks.Add(-1)
+		fn()
+	}()
+}
+
+// MoveEngine picks moves for the coach in hybrid mode, playing at about elo when it is
+// positive. *engine.UCI implements it.
+type MoveEngine interface {
+	BestMove(ctx context.Context, pos *chess.Position, elo int) (chess.Move, error)
+}
+
+// TablebaseProber looks up endgame positions in tablebases. *tablebase.Client implements
+// it.
+type TablebaseProber interface {
+	Probe(ctx context.Context, pos *chess.Position) (tablebase.Result, error)
+}
+
+// OpeningExplorer reports how master games continued from a position. *explorer.Client
+// implements it.
+type OpeningExplorer interface {
+	Masters(ctx context.Context, pos *chess.Position) (explorer.Stats, error)
+}
+
+// validator is implemented by request types that check their own required fields.
+type validator interface {
+	Validate() error
+}
+
+// decodeAndValidate enforces POST, limits the body to 1MB, strictly decodes the JSON body
+// into T and runs its Validate method when it has one. On failure it writes the error
+// response and returns false.
+func decodeAndValidate[T any](w http.ResponseWriter, r *http.Request) (T, bool) {
+	return decodeRequest[T](w, r, nil)
+}
+
+// decodeGameRequest is decodeAndValidate for requests that may name a stored game with
+// game_id. The game's position replaces the request's own before validation.
+func decodeGameRequest[T any](h *Handler, w http.ResponseWriter, r *http.Request) (T, bool) {
+	return decodeRequest[T](w, r, h.Games)
+}
+
+func decodeRequest[T any](w http.ResponseWriter, r *http.Request, games store.GameStore) (T, bool) {
+	var req T
+
+	if r.Method != http.MethodPost {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.MethodNotAllowed, "Method not allowed")
+		return req, false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // Limit body size to 1MB
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	// An empty body decodes as the zero request so endpoints with only optional fields
+	// accept it; required fields are still enforced by Validate.
+	if err := decoder.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidJSON, "Invalid JSON")
+		return req, false
+	}
+
+	if gb, ok := any(&req).(types.GameBound); ok && games != nil && gb.BoundGameID() != "" {
+		game, err := visibleGame(r, games, gb.BoundGameID())
+		if err != nil {
+			writeStoreError(w, err)
+			return req, false
+		}
+		gb.UseGame(game.InitialFen, game.Fen, game.MoveHistory)
+		if vb, ok := gb.(types.VariantBound); ok {
+			vb.UseVariant(game.Variant)
+		}
+		if pb, ok := gb.(types.PersonaBound); ok {
+			pb.UsePersona(game.Persona)
+		}
+	}
+
+	if v, ok := any(&req).(validator); ok {
+		if err := v.Validate(); err != nil {
+			writeValidationError(w, err)
+			return req, false
+		}
+	}
+
+	return req, true
+}
+
+// writeValidationError rejects a request whose Validate method returned err, listing
+// every field at fault.
+func writeValidationError(w http.ResponseWriter, err error) {
+	var invalid *types.ValidationError
+	var field *types.FieldError
+	switch {
+	case errors.As(err, &invalid):
+	case errors.As(err, &field):
+		invalid = &types.ValidationError{Fields: []*types.FieldError{field}}
+	default:
+		apierror.Write(w, http.StatusBadRequest, apierror.InvalidRequest, err.Error())
+		return
+	}
+	fields := make([]apierror.FieldError, len(invalid.Fields))
+	for i, f := range invalid.Fields {
+		fields[i] = apierror.FieldError{Code: f.Code, Field: f.Field, Message: f.Message}
+	}
+	apierror.WriteFields(w, fields)
+}
+
+// ProviderHeader names the model provider a request would like to be served by, such as
+// "openai". The configured providers remain the failover for it.
+const ProviderHeader = "X-Nara-Provider"
+
+// requestContext returns the context for a model-backed request to endpoint. It derives
+// from r's context, so the model call is abandoned once the client disconnects, and
+// otherwise follows modelContext, asking for the provider named by r's ProviderHeader.
+func (h *Handler) requestContext(r *http.Request, endpoint string) (context.Context, context.CancelFunc) {
+	return h.modelContext(r.Context(), endpoint, r.Header.Get(ProviderHeader))
+}
+
+// modelContext returns the context for model calls on behalf of endpoint within parent:
+// bounded by the endpoint's timeout, carrying a fresh model call budget and a trace of the
+// models that answered, recording each call's usage, and asking for the named provider
+// when one is given.
+func (h *Handler) modelContext(parent context.Context, endpoint, provider string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(parent, h.timeout(endpoint))
+	if provider != "" {
+		ctx = llm.WithProvider(ctx, provider)
+	}
+	caller := callerOf(parent)
+	ctx = ai.WithUsage(ctx, func(u ai.Usage) { h.recordUsage(caller, endpoint, u) })
+	ctx = ai.WithTrace(ctx, &ai.Trace{})
+	return ai.WithBudget(ctx, ai.NewBudget(h.Config.MaxModelCalls)), cancel
+}
+
+// analysisKey identifies pos for the analysis caches by its placement, side to move,
+// castling rights and en passant square, leaving out the move counters so the same
+// position reached at a different move shares an entry. A variant's positions are kept
+// apart from the same placement in standard chess.
+func analysisKey(pos *chess.Position) string {
+	fields := strings.Fields(pos.FEN())
+	if pos.Variant != chess.Standard {
+		fields[3] += " " + pos.Variant.String()
+	}
+	return strings.Join(fields[:4], " ")
+}
+
+// responseMeta reports the model calls the request has made so far and which model
+// answered.
+func responseMeta(ctx context.Context) *types.ResponseMeta {
+	meta := &types.ResponseMeta{}
+	if b := ai.BudgetFrom(ctx); b != nil {
+		meta.ModelCalls = b.Used()
+	}
+	if t := ai.TraceFrom(ctx); t != nil {
+		meta.Provider, meta.Model = t.Answered()
+	}
+	return meta
+}
+
+// httpError carries the status code, error code and client-facing message for a failed
+// request, the request field at fault and the underlying error when there are ones.
+type httpError struct {
+	status  int
+	code    string
+	field   string
+	message string
+	err     error
+}
+
+func (e *httpError) Error() string {
+	return e.message
+}
+
+func (e *httpError) Unwrap() error {
+	return e.err
+}
+
+func errorf(status int, format string, args ...any) error {
+	return &httpError{status: status, code: apierror.CodeFor(status), message: fmt.Sprintf(format, args...)}
+}
+
+// badRequest is errorf for a bad request with code, naming the request field at fault
+// unless field is empty.
+func badRequest(code, field, format string, args ...any) error {
+	return &httpError{status: http.StatusBadRequest, code: code, field: field, message: fmt.Sprintf(format, args...)}
+}
+
+// writeError sends err to the client, using its status and code when it is an
+// *httpError.
+func writeError(w http.ResponseWriter, err error) {
+	var he *httpError
+	if errors.As(err, &he) {
+		apierror.WriteField(w, he.status, he.code, he.field, he.message)
+		return
+	}
+	apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+}
+
+// writeFENError rejects a request whose FEN failed to parse.
+func writeFENError(w http.ResponseWriter, err error) {
+	apierror.WriteField(w, http.StatusBadRequest, apierror.InvalidFEN, "fen", types.FENMessage("FEN", err))
+}
+
+// callModel draws one call from the request's budget and calls the AI provider, mapping
+// failures to *httpError.
+func (h *Handler) callModel(ctx context.Context, req ai.Request) (string, error) {
+	return h.streamModel(ctx, req, nil)
+}
+
+// streamModel is callModel passing each piece of the response to onChunk as it arrives.
+// A nil onChunk makes a plain, non-streaming call.
+func (h *Handler) streamModel(ctx context.Context, req ai.Request, onChunk func(string) error) (string, error) {
+	if err := ai.TakeCall(ctx); err != nil {
+		slog.WarnContext(ctx, "Model call refused", "err", err)
+		return "", modelError(err)
+	}
+
+	var jsonString string
+	var err error
+	if onChunk == nil {
+		jsonString, err = h.AI.GenerateJSON(ctx, req)
+	} else {
+		jsonString, err = ai.GenerateStream(ctx, h.AI, req, onChunk)
+	}
+	if err == nil {
+		slog.DebugContext(ctx, "Model response", "response", jsonString)
+		return jsonString, nil
+	}
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		slog.InfoContext(ctx, "Model call abandoned: the client went away")
+	} else {
+		slog.ErrorContext(ctx, "Error generating content from the model", "err", err)
+	}
+	return "", modelError(err)
+}
+
+// modelError maps errors from the provider and the llm service to the response the client
+// sees.
+func modelError(err error) error {
+	var noLegal *llm.NoLegalMoveError
+	status, code, message := http.StatusInternalServerError, apierror.ModelError, "Failed to get move suggestion from service"
+	var field string
+	switch {
+	case errors.Is(err, ai.ErrBudgetExhausted):
+		status, code, message = http.StatusServiceUnavailable, apierror.Unavailable, "Analysis service retry budget exhausted"
+	case errors.Is(err, ratelimit.ErrQuotaExhausted):
+		status, code, message = http.StatusTooManyRequests, apierror.QuotaExceeded, "Daily model call quota exhausted"
+	case errors.Is(err, llm.ErrUnknownProvider):
+		status, code, message = http.StatusBadRequest, apierror.InvalidRequest, strings.TrimPrefix(err.Error(), "llm: ")
+	case errors.Is(err, llm.ErrInvalidFEN):
+		status, code, field, message = http.StatusBadRequest, apierror.InvalidFEN, "fen", types.FENMessage("FEN", err)
+	case errors.Is(err, llm.ErrBadResponse):
+		message = "Failed to parse move suggestion"
+	case errors.Is(err, llm.ErrEmptyMove):
+		message = "Analysis service failed to provide a move"
+	case errors.As(err, &noLegal):
+		message = noLegal.Error()
+	case errors.Is(err, ai.ErrMissingAPIKey):
+		code, message = apierror.Internal, "Server configuration error"
+	case errors.Is(err, ai.ErrOverloaded):
+		status, code, message = http.StatusServiceUnavailable, apierror.Unavailable, "Analysis service is busy, try again shortly"
+	case errors.Is(err, ai.ErrCircuitOpen), ai.IsTransient(err):
+		status, code, message = http.StatusServiceUnavailable, apierror.Unavailable, "Analysis service is temporarily unavailable"
+	case errors.Is(err, context.DeadlineExceeded):
+		status, code, message = http.StatusGatewayTimeout, apierror.ModelTimeout, "Analysis request timed out"
+	case errors.Is(err, ai.ErrEmptyResponse):
+		message = "Received empty analysis response"
+	case errors.Is(err, ai.ErrMalformedJSON):
+		message = "Received malformed analysis response"
+	case errors.Is(err, ai.ErrUnexpectedFormat):
+		message = "Received unexpected analysis format from service"
+	}
+	return &httpError{status: status, code: code, field: field, message: message, err: err}
+}
+
+// generate is callModel for handlers that write their own responses. It returns false
+// once an error response has been written.
+func (h *Handler) generate(ctx context.Context, w http.ResponseWriter, req ai.Request) (string, bool) {
+	jsonString, err := h.callModel(ctx, req)
+	if err != nil {
+		writeError(w, err)
+		return "", false
+	}
+	return jsonString, true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Error encoding JSON response for client", "err", err)
+	}
+}
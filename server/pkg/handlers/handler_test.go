@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/account"
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const kingsFEN = "4k3/8/8/8/8/8/8/4K3 w - - 0 1"
+
+// decodeLegalMoves runs decodeGameRequest for a LegalMovesRequest sent as body, returning
+// the request and the recorded response.
+func decodeLegalMoves(games store.GameStore, method, body string) (types.LegalMovesRequest, bool, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, "/api/legal-moves", strings.NewReader(body))
+	req, ok := decodeRequest[types.LegalMovesRequest](w, r, games)
+	return req, ok, w
+}
+
+func decodeErrorResponse(t *testing.T, w *httptest.ResponseRecorder) apierror.Error {
+	t.Helper()
+	var resp apierror.Response
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding error response %q: %v", w.Body.String(), err)
+	}
+	return resp.Error
+}
+
+func TestDecodeRequestRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+		code   string
+		field  string
+	}{
+		{
+			name:   "wrong method",
+			method: http.MethodGet,
+			status: http.StatusMethodNotAllowed,
+			code:   apierror.MethodNotAllowed,
+		},
+		{
+			name:   "malformed JSON",
+			method: http.MethodPost,
+			body:   `{"fen": `,
+			status: http.StatusBadRequest,
+			code:   apierror.InvalidJSON,
+		},
+		{
+			name:   "wrong field type",
+			method: http.MethodPost,
+			body:   `{"fen": 42}`,
+			status: http.StatusBadRequest,
+			code:   apierror.InvalidJSON,
+		},
+		{
+			name:   "unknown field",
+			method: http.MethodPost,
+			body:   `{"fen": "` + chess.StartFEN + `", "board": "start"}`,
+			status: http.StatusBadRequest,
+			code:   apierror.InvalidJSON,
+		},
+		{
+			name:   "oversized body",
+			method: http.MethodPost,
+			body:   `{"fen": "` + strings.Repeat(" ", 1<<20) + `"}`,
+			status: http.StatusBadRequest,
+			code:   apierror.InvalidJSON,
+		},
+		{
+			name:   "missing required field",
+			method: http.MethodPost,
+			body:   `{}`,
+			status: http.StatusBadRequest,
+			code:   apierror.InvalidRequest,
+			field:  "fen",
+		},
+		{
+			name:   "empty body fails validation",
+			method: http.MethodPost,
+			status: http.StatusBadRequest,
+			code:   apierror.InvalidRequest,
+			field:  "fen",
+		},
+		{
+			name:   "invalid FEN",
+			method: http.MethodPost,
+			body:   `{"fen": "not a position"}`,
+			status: http.StatusBadRequest,
+			code:   apierror.InvalidFEN,
+			field:  "fen",
+		},
+		{
+			name:   "unknown game",
+			method: http.MethodPost,
+			body:   `{"game_id": "missing"}`,
+			status: http.StatusNotFound,
+			code:   apierror.NotFound,
+		},
+	}
+	games := store.NewMemoryStore()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok, w := decodeLegalMoves(games, tt.method, tt.body)
+			if ok {
+				t.Fatal("decodeRequest accepted the request")
+			}
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			got := decodeErrorResponse(t, w)
+			if got.Code != tt.code || got.Field != tt.field {
+				t.Errorf("error = %s on %q, want %s on %q", got.Code, got.Field, tt.code, tt.field)
+			}
+		})
+	}
+}
+
+func TestDecodeRequestAccepts(t *testing.T) {
+	req, ok, w := decodeLegalMoves(nil, http.MethodPost, `{"fen": "`+kingsFEN+`"}`)
+	if !ok {
+		t.Fatalf("decodeRequest rejected a valid request: %d %s", w.Code, w.Body)
+	}
+	if req.Fen != kingsFEN {
+		t.Errorf("fen = %q, want %q", req.Fen, kingsFEN)
+	}
+}
+
+func TestDecodeRequestUsesStoredGame(t *testing.T) {
+	games := store.NewMemoryStore()
+	game, err := games.Create(store.Game{InitialFen: kingsFEN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, ok, w := decodeLegalMoves(games, http.MethodPost, `{"game_id": "`+game.ID+`"}`)
+	if !ok {
+		t.Fatalf("decodeRequest rejected a request for a stored game: %d %s", w.Code, w.Body)
+	}
+	if req.Fen != kingsFEN {
+		t.Errorf("fen = %q, want the stored game's %q", req.Fen, kingsFEN)
+	}
+}
+
+// serve sends body to handler as a request of method to target and returns the response.
+func serve(handler http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+// signIn returns handler behind a session of userID, as the account middleware serves
+// it to a signed-in user.
+func signIn(t *testing.T, userID string, handler http.HandlerFunc) http.HandlerFunc {
+	t.Helper()
+	accounts, err := account.NewService(strings.Repeat("s", account.MinSecretLength), time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := httptest.NewRecorder()
+	if err := accounts.StartSession(session, userID); err != nil {
+		t.Fatal(err)
+	}
+	cookies := session.Result().Cookies()
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		accounts.Middleware(handler).ServeHTTP(w, r)
+	}
+}
+
+// decodeResponse decodes w's JSON body into T, failing the test unless it has status.
+func decodeResponse[T any](t *testing.T, w *httptest.ResponseRecorder, status int) T {
+	t.Helper()
+	var resp T
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d: %s", w.Code, status, w.Body)
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response %q: %v", w.Body.String(), err)
+	}
+	return resp
+}
+
+// scriptedProvider answers model calls with its responses in turn, recording every
+// request.
+type scriptedProvider struct {
+	mu        sync.Mutex
+	responses []string
+	requests  []ai.Request
+}
+
+func (p *scriptedProvider) GenerateJSON(ctx context.Context, req ai.Request) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, req)
+	if len(p.responses) == 0 {
+		return "", errors.New("scriptedProvider: out of responses")
+	}
+	resp := p.responses[0]
+	p.responses = p.responses[1:]
+	return resp, nil
+}
+
+// newTestHandler returns a handler over a fresh memory store whose model answers with
+// responses.
+func newTestHandler(responses ...string) (*Handler, *scriptedProvider) {
+	provider := &scriptedProvider{responses: responses}
+	return New(provider, store.NewMemoryStore(), DefaultConfig()), provider
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+)
+
+func describeMove(pos *chess.Position, m chess.Move) types.MoveInfo {
+	check := pos.CheckInfo(m)
+	return types.MoveInfo{
+		San:             pos.SAN(m),
+		Uci:             m.String(),
+		From:            m.From.String(),
+		To:              m.To.String(),
+		GivesCheck:      check.Check,
+		DiscoveredCheck: check.Discovered,
+		DoubleCheck:     check.Double,
+	}
+}
+
+// gameStatus reports how the game ending in the last of positions is over, or nil while it
+// is still in progress.
+func gameStatus(positions ...*chess.Position) *types.GameStatus {
+	status := chess.GameStatus(positions)
+	if !status.Over {
+		return nil
+	}
+	return &types.GameStatus{Result: status.Result, Reason: status.Reason, Material: status.Material}
+}
+
+// wonOnHill reports whether pos ends a King of the Hill game on the hill, where no move
+// may be played although both sides still have pieces that could move.
+func wonOnHill(pos *chess.Position) bool {
+	return pos.Variant == chess.KingOfTheHill && (pos.OnHill(chess.White) || pos.OnHill(chess.Black))
+}
+
+// sanLine renders up to n moves of an engine line from pos in SAN, stopping at the first
+// move that is not legal. A negative n renders the whole line.
+func sanLine(pos *chess.Position, pv []chess.Move, n int) []string {
+	if n < 0 || n > len(pv) {
+		n = len(pv)
+	}
+	line := make([]string, 0, n)
+	for _, m := range pv[:n] {
+		if !pos.IsLegal(m) {
+			break
+		}
+		line = append(line, pos.SAN(m))
+		pos = pos.Play(m)
+	}
+	return line
+}
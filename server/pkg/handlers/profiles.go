@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/llm"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Schema choices for a profile. The built-in schema is the endpoint's own response schema;
+// "none" drops it and relies on the prompt's format instructions alone.
+const (
+	SchemaBuiltin = "builtin"
+	SchemaNone    = "none"
+)
+
+// profileEndpoints lists the model-backed endpoints a profile may be keyed by.
+var profileEndpoints = map[string]bool{
+	"generateMove":          true,
+	"chat":                  true,
+	"chatSummary":           true,
+	"studyPlan":             true,
+	"teachingLine":          true,
+	"developmentSuggestion": true,
+	"analyzePgn":            true,
+	"gameReport":            true,
+	"dailyPuzzle":           true,
+	"hint":                  true,
+	"evaluate":              true,
+	"candidates":            true,
+	"explore":               true,
+	"gameOver":              true,
+	"drawOffer":             true,
+	"progressReport":        true,
+	"trainerGuess":          true,
+	"blindfold":             true,
+}
+
+// Profile tunes the model call for one endpoint. Zero fields keep the server defaults.
+type Profile struct {
+	Model       string   `json:"model"`
+	Temperature *float32 `json:"temperature"`
+	MaxTokens   int32    `json:"max_tokens"`
+	Schema      string   `json:"schema"`
+	// Timeout bounds the endpoint's requests, as a duration such as "90s", in place of
+	// Config.Timeout.
+	Timeout string `json:"timeout"`
+}
+
+func (p Profile) validate() error {
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("temperature %v must be between 0 and 2", *p.Temperature)
+	}
+	if p.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens %d must not be negative", p.MaxTokens)
+	}
+	switch p.Schema {
+	case "", SchemaBuiltin, SchemaNone:
+	default:
+		return fmt.Errorf("unknown schema %q (want %q or %q)", p.Schema, SchemaBuiltin, SchemaNone)
+	}
+	if p.Timeout != "" {
+		if d, err := time.ParseDuration(p.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("timeout %q must be a positive duration such as \"90s\"", p.Timeout)
+		}
+	}
+	return nil
+}
+
+// LoadProfiles reads a JSON object mapping endpoint names to profiles and validates every
+// entry. An empty path yields no profiles.
+func LoadProfiles(path string) (map[string]Profile, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading profiles: %w", err)
+	}
+	defer f.Close()
+
+	// Unknown fields are rejected so a misspelt setting fails at startup instead of being ignored.
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	var profiles map[string]Profile
+	if err := decoder.Decode(&profiles); err != nil {
+		return nil, fmt.Errorf("parsing profiles %s: %w", path, err)
+	}
+
+	endpoints := make([]string, 0, len(profiles))
+	for endpoint := range profiles {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		if !profileEndpoints[endpoint] {
+			return nil, fmt.Errorf("profile %q: unknown endpoint", endpoint)
+		}
+		if err := profiles[endpoint].validate(); err != nil {
+			return nil, fmt.Errorf("profile %q: %w", endpoint, err)
+		}
+	}
+	return profiles, nil
+}
+
+// modelRequest builds the model call for an endpoint, applying its profile over the
+// configured defaults.
+func (h *Handler) modelRequest(endpoint, prompt string, schema *genai.Schema) ai.Request {
+	req := ai.Request{
+		Prompt:      prompt,
+		Schema:      schema,
+		Temperature: h.Config.Temperature,
+	}
+
+	p, ok := h.Config.Profiles[endpoint]
+	if !ok {
+		return req
+	}
+	req.Model = p.Model
+	req.MaxOutputTokens = p.MaxTokens
+	if p.Temperature != nil {
+		req.Temperature = *p.Temperature
+	}
+	if p.Schema == SchemaNone {
+		req.Schema = nil
+	}
+	return req
+}
+
+// timeout is how long a request to endpoint may wait on the model: its profile's timeout,
+// or the configured one.
+func (h *Handler) timeout(endpoint string) time.Duration {
+	if d, err := time.ParseDuration(h.Config.Profiles[endpoint].Timeout); err == nil && d > 0 {
+		return d
+	}
+	return h.Config.Timeout
+}
+
+// coachOptions builds the llm options for coach moves from the generateMove profile.
+func (h *Handler) coachOptions() llm.Options {
+	req := h.modelRequest("generateMove", "", nil)
+	return llm.Options{
+		Temperature:     req.Temperature,
+		Model:           req.Model,
+		MaxOutputTokens: req.MaxOutputTokens,
+		OmitSchema:      h.Config.Profiles["generateMove"].Schema == SchemaNone,
+		MaxAttempts:     h.Config.MaxMoveAttempts,
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// writeProfiles writes contents to a profiles file and returns its path.
+func writeProfiles(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadProfiles(t *testing.T) {
+	path := writeProfiles(t, `{
+		"generateMove": {"model": "gemini-2.0-flash", "temperature": 0.2, "max_tokens": 512, "timeout": "90s"},
+		"chat": {"schema": "none"}
+	}`)
+	profiles, err := LoadProfiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	move := profiles["generateMove"]
+	if move.Model != "gemini-2.0-flash" || move.Temperature == nil || *move.Temperature != 0.2 || move.MaxTokens != 512 || move.Timeout != "90s" {
+		t.Errorf("generateMove profile = %+v", move)
+	}
+	if profiles["chat"].Schema != SchemaNone {
+		t.Errorf("chat profile = %+v, want schema none", profiles["chat"])
+	}
+
+	if profiles, err := LoadProfiles(""); err != nil || profiles != nil {
+		t.Errorf("LoadProfiles(\"\") = %v, %v; want no profiles", profiles, err)
+	}
+}
+
+func TestLoadProfilesRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     string
+	}{
+		{"malformed", `{"chat": `, "parsing profiles"},
+		{"unknown field", `{"chat": {"temprature": 0.5}}`, "unknown field"},
+		{"unknown endpoint", `{"chatt": {}}`, `profile "chatt": unknown endpoint`},
+		{"temperature too high", `{"chat": {"temperature": 2.5}}`, "temperature 2.5 must be between 0 and 2"},
+		{"negative temperature", `{"chat": {"temperature": -1}}`, "temperature -1 must be between 0 and 2"},
+		{"negative max tokens", `{"hint": {"max_tokens": -5}}`, "max_tokens -5 must not be negative"},
+		{"unknown schema", `{"hint": {"schema": "strict"}}`, `unknown schema "strict"`},
+		{"bad timeout", `{"hint": {"timeout": "soon"}}`, `timeout "soon"`},
+		{"zero timeout", `{"hint": {"timeout": "0s"}}`, `timeout "0s"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadProfiles(writeProfiles(t, tt.contents))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadProfiles = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadProfiles(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadProfiles read a missing file")
+	}
+}
+
+func TestModelRequestAppliesProfile(t *testing.T) {
+	h, _ := newTestHandler()
+	h.Config.Temperature = 0.7
+	h.Config.Timeout = 30 * time.Second
+	cold := float32(0.1)
+	h.Config.Profiles = map[string]Profile{
+		"chat": {Model: "gemini-2.0-flash", Temperature: &cold, MaxTokens: 256, Schema: SchemaNone, Timeout: "90s"},
+		"hint": {MaxTokens: 128},
+	}
+	schema := &genai.Schema{Type: genai.TypeObject}
+
+	req := h.modelRequest("chat", "prompt", schema)
+	if req.Model != "gemini-2.0-flash" || req.Temperature != cold || req.MaxOutputTokens != 256 || req.Schema != nil {
+		t.Errorf("chat request = %+v", req)
+	}
+
+	// Fields the profile leaves out keep the server defaults.
+	req = h.modelRequest("hint", "prompt", schema)
+	if req.Model != "" || req.Temperature != 0.7 || req.MaxOutputTokens != 128 || req.Schema != schema {
+		t.Errorf("hint request = %+v", req)
+	}
+
+	req = h.modelRequest("studyPlan", "prompt", schema)
+	if req.Model != "" || req.Temperature != 0.7 || req.MaxOutputTokens != 0 || req.Schema != schema || req.Prompt != "prompt" {
+		t.Errorf("request without a profile = %+v", req)
+	}
+
+	if d := h.timeout("chat"); d != 90*time.Second {
+		t.Errorf("chat timeout = %v, want 90s", d)
+	}
+	if d := h.timeout("hint"); d != 30*time.Second {
+		t.Errorf("hint timeout = %v, want the configured 30s", d)
+	}
+}
+
+func TestGenerateMoveUsesProfile(t *testing.T) {
+	h, provider := newTestHandler(coachReply("e5"))
+	cold := float32(0.1)
+	h.Config.Profiles = map[string]Profile{
+		"generateMove": {Model: "gemini-2.0-flash", Temperature: &cold, MaxTokens: 512, Schema: SchemaNone},
+	}
+
+	w := serve(h.HandleGenerateMove, http.MethodPost, "/api/generateMove",
+		`{"fen": "`+afterE4+`", "move_history": ["e4"]}`)
+	decodeResponse[struct{}](t, w, http.StatusOK)
+
+	if len(provider.requests) != 1 {
+		t.Fatalf("made %d model calls, want 1", len(provider.requests))
+	}
+	req := provider.requests[0]
+	if req.Model != "gemini-2.0-flash" || req.Temperature != cold || req.MaxOutputTokens != 512 || req.Schema != nil {
+		t.Errorf("model request = model %q, temperature %v, max tokens %d, schema %v", req.Model, req.Temperature, req.MaxOutputTokens, req.Schema)
+	}
+}
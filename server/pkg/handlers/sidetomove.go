@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+)
+
+// analysisPerspective resolves an analyze_for value to a color. An empty value yields
+// fallback.
+func analysisPerspective(analyzeFor string, turn, fallback chess.Color) chess.Color {
+	switch analyzeFor {
+	case types.AnalyzeForWhite:
+		return chess.White
+	case types.AnalyzeForBlack:
+		return chess.Black
+	case types.AnalyzeForSideToMove:
+		return turn
+	}
+	return fallback
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAnalysisPerspective(t *testing.T) {
+	tests := []struct {
+		analyzeFor string
+		turn       chess.Color
+		want       chess.Color
+	}{
+		{"", chess.Black, chess.White},
+		{types.AnalyzeForWhite, chess.Black, chess.White},
+		{types.AnalyzeForBlack, chess.White, chess.Black},
+		{types.AnalyzeForSideToMove, chess.White, chess.White},
+		{types.AnalyzeForSideToMove, chess.Black, chess.Black},
+	}
+	for _, tt := range tests {
+		if got := analysisPerspective(tt.analyzeFor, tt.turn, chess.White); got != tt.want {
+			t.Errorf("analysisPerspective(%q, %s) = %s, want %s", tt.analyzeFor, tt.turn, got, tt.want)
+		}
+	}
+}
+
+// White is a queen up with Black to move.
+const queenUpFEN = "k7/8/8/8/8/8/8/KQ6 b - - 0 1"
+
+func TestPrincipalVariationAnalyzeFor(t *testing.T) {
+	tests := []struct {
+		analyzeFor  string
+		perspective string
+		winning     bool
+	}{
+		{"", "white", true},
+		{types.AnalyzeForWhite, "white", true},
+		{types.AnalyzeForBlack, "black", false},
+		{types.AnalyzeForSideToMove, "black", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.analyzeFor, func(t *testing.T) {
+			h, _ := newTestHandler()
+			body := `{"fen": "` + queenUpFEN + `", "depth": 2, "analyze_for": "` + tt.analyzeFor + `"}`
+			w := serve(h.HandlePrincipalVariation, http.MethodPost, "/api/principalVariation", body)
+			resp := decodeResponse[types.PrincipalVariationResponse](t, w, http.StatusOK)
+
+			if resp.Perspective != tt.perspective {
+				t.Errorf("perspective = %s, want %s", resp.Perspective, tt.perspective)
+			}
+			if winning := resp.Score > 0; winning != tt.winning {
+				t.Errorf("score = %d for %s, want winning %v", resp.Score, tt.perspective, tt.winning)
+			}
+			for _, ply := range resp.PV {
+				if winning := ply.Score > 0; winning != tt.winning {
+					t.Errorf("ply %d (%s) scored %d for %s, want winning %v", ply.Ply, ply.San, ply.Score, tt.perspective, tt.winning)
+				}
+			}
+
+			// The perspective doesn't change whose move it is.
+			if resp.BestMove != "Ka7" {
+				t.Errorf("best move = %s, want Black's only move Ka7", resp.BestMove)
+			}
+		})
+	}
+}
+
+func TestAnalyzeForRejectsUnknown(t *testing.T) {
+	h, _ := newTestHandler()
+	w := serve(h.HandlePrincipalVariation, http.MethodPost, "/api/principalVariation",
+		`{"fen": "`+queenUpFEN+`", "analyze_for": "pupil"}`)
+	resp := decodeResponse[apierror.Response](t, w, http.StatusBadRequest)
+	if resp.Error.Code != apierror.InvalidRequest || resp.Error.Field != "analyze_for" {
+		t.Errorf("error = %+v, want analyze_for rejected", resp.Error)
+	}
+}
+
+func TestChatPromptAnalyzeFor(t *testing.T) {
+	tests := []struct {
+		analyzeFor string
+		want       string
+	}{
+		{"", ""},
+		{types.AnalyzeForWhite, "from white's point of view"},
+		{types.AnalyzeForBlack, "from black's point of view"},
+		{types.AnalyzeForSideToMove, "from black's point of view"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.analyzeFor, func(t *testing.T) {
+			req := types.ChatMessageRequest{
+				MessageHistory: []types.ChatMessage{{Role: "user", Content: "Who is better?"}},
+				GameState:      types.GameStateRequest{Fen: queenUpFEN},
+				AnalyzeFor:     tt.analyzeFor,
+			}
+			prompt := chatPrompt(req, "", nil)
+			if tt.want == "" {
+				if strings.Contains(prompt, "PERSPECTIVE") {
+					t.Error("prompt sets a perspective without analyze_for")
+				}
+				return
+			}
+			if !strings.Contains(prompt, tt.want) || !strings.Contains(prompt, "It is still black to move.") {
+				t.Errorf("prompt lacks %q for black to move:\n%s", tt.want, prompt)
+			}
+		})
+	}
+}
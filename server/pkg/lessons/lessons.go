@@ -0,0 +1,105 @@
+// Package lessons holds the built-in lessons: short series of teaching positions on one
+// theme, such as forks or basic checkmates, each with the moves that solve it.
+package lessons
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/notation"
+)
+
+//go:embed lessons.json
+var lessonsJSON []byte
+
+// Lesson is a series of steps on one theme, meant to be solved in order.
+type Lesson struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Theme   string `json:"theme"`
+	Summary string `json:"summary"`
+	Steps   []Step `json:"steps"`
+}
+
+// Step is one teaching position and the task the pupil solves in it.
+type Step struct {
+	Fen  string `json:"fen"`
+	Task string `json:"task"`
+	// Solutions lists every move that solves the step, in SAN.
+	Solutions []string `json:"solutions"`
+	// Explanation is the idea behind the solution.
+	Explanation string `json:"explanation"`
+}
+
+var all []Lesson
+
+func init() {
+	var err error
+	all, err = load(lessonsJSON)
+	if err != nil {
+		panic(fmt.Sprintf("lessons: invalid lesson data: %v", err))
+	}
+}
+
+// load parses the lessons and checks that every step's position is valid and every
+// solution is a legal move written in canonical SAN.
+func load(data []byte) ([]Lesson, error) {
+	var lessons []Lesson
+	if err := json.Unmarshal(data, &lessons); err != nil {
+		return nil, err
+	}
+	for _, l := range lessons {
+		if l.ID == "" || len(l.Steps) == 0 {
+			return nil, fmt.Errorf("lesson %q has no ID or no steps", l.ID)
+		}
+		for i, s := range l.Steps {
+			pos, err := chess.ParseFEN(s.Fen)
+			if err != nil {
+				return nil, fmt.Errorf("lesson %s step %d: %w", l.ID, i+1, err)
+			}
+			if len(s.Solutions) == 0 {
+				return nil, fmt.Errorf("lesson %s step %d has no solution", l.ID, i+1)
+			}
+			for _, san := range s.Solutions {
+				m, err := pos.ParseSAN(san)
+				if err != nil || pos.SAN(m) != san {
+					return nil, fmt.Errorf("lesson %s step %d: solution %q is not a legal move in canonical SAN", l.ID, i+1, san)
+				}
+			}
+		}
+	}
+	return lessons, nil
+}
+
+// All returns every lesson, in teaching order.
+func All() []Lesson {
+	return slices.Clone(all)
+}
+
+// Get returns the lesson with the given ID.
+func Get(id string) (Lesson, bool) {
+	for _, l := range all {
+		if l.ID == id {
+			return l, true
+		}
+	}
+	return Lesson{}, false
+}
+
+// Check reads the pupil's move in the step's position, in SAN or UCI, and reports it in
+// canonical SAN together with whether it solves the step. An illegal move is an error.
+func (s Step) Check(move string) (string, bool, error) {
+	pos, err := chess.ParseFEN(s.Fen)
+	if err != nil {
+		return "", false, err
+	}
+	m, err := notation.ParseMove(pos, move)
+	if err != nil {
+		return "", false, err
+	}
+	san := pos.SAN(m)
+	return san, slices.Contains(s.Solutions, san), nil
+}
@@ -0,0 +1,78 @@
+// Package lichess fetches a player's games from the lichess API.
+package lichess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/pgn"
+)
+
+// LichessURL is the public lichess API.
+const LichessURL = "https://lichess.org"
+
+// ErrUserNotFound is returned for a username lichess doesn't know.
+var ErrUserNotFound = errors.New("lichess: user not found")
+
+// perfTypes are the standard chess speeds; variant games can't be coached.
+const perfTypes = "ultraBullet,bullet,blitz,rapid,classical,correspondence"
+
+// Client queries the lichess API.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient returns a client for the lichess API at baseURL. token, when set, is sent as a
+// lichess API bearer token, which raises the export rate limit.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// RecentGames returns up to max of username's most recent finished standard games, newest
+// first, with PGN-style tags.
+func (c *Client) RecentGames(ctx context.Context, username string, max int) ([]pgn.Game, error) {
+	query := url.Values{}
+	query.Set("max", strconv.Itoa(max))
+	query.Set("perfType", perfTypes)
+	query.Set("finished", "true")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/games/user/"+url.PathEscape(username)+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.Header.Set("User-Agent", "nara-chess")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("lichess: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrUserNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("lichess: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	games, err := pgn.ParseLichessNDJSON(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("lichess: reading games: %w", err)
+	}
+	return games, nil
+}
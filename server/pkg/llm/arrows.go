@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"arnavsurve/nara-chess/server/pkg/utils"
+	"slices"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// ArrowGroupsInstruction asks the model to sort its arrows into ArrowGroupsSchema.
+const ArrowGroupsInstruction = `
+
+You may also sort your arrows by purpose in "arrow_groups": "threats" (what the opponent is threatening or could threaten), "plans" (ideas to pursue), and "defenses" (moves or squares that parry a threat). Every grouped arrow is also shown, so do not repeat it in "arrows".`
+
+// ArrowListSchema describes a list of [from, to] arrows.
+func ArrowListSchema(description string) *genai.Schema {
+	return &genai.Schema{
+		Type:        genai.TypeArray,
+		Description: description,
+		Items: &genai.Schema{
+			Type: genai.TypeArray,
+			Items: &genai.Schema{
+				Type: genai.TypeString,
+			},
+		},
+	}
+}
+
+var ArrowGroupsSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "Optional arrows grouped by purpose so the board can color-code them.",
+	Properties: map[string]*genai.Schema{
+		"threats":  ArrowListSchema("Arrows showing threats."),
+		"plans":    ArrowListSchema("Arrows showing plans and ideas."),
+		"defenses": ArrowListSchema("Arrows showing defensive resources."),
+	},
+}
+
+// SanitizeArrows cleans the model's arrows in both the flat list and the groups, then
+// makes the flat list the union of every kept arrow so clients unaware of groups still see
+// them. Square names are trimmed and lower-cased, and arrows whose squares are still not
+// a1-h8, or that start and end on the same square, are dropped. When boards are given an
+// arrow must start on a square occupied on one of them: one drawn backwards, from an empty
+// square to an occupied one, is reversed and any other is dropped. Nil boards are ignored.
+func SanitizeArrows(arrows [][2]string, groups *types.ArrowGroups, boards ...*chess.Position) [][2]string {
+	boards = slices.DeleteFunc(slices.Clone(boards), func(b *chess.Position) bool { return b == nil })
+	occupied := func(square string) bool {
+		sq, _ := chess.ParseSquare(square)
+		for _, b := range boards {
+			if b.Board[sq] != chess.NoPiece {
+				return true
+			}
+		}
+		return false
+	}
+
+	seen := make(map[[2]string]bool)
+	union := [][2]string{}
+
+	keep := func(list [][2]string) [][2]string {
+		valid := list[:0]
+		for _, a := range list {
+			a = [2]string{normalizeSquare(a[0]), normalizeSquare(a[1])}
+			if !utils.IsValidSquare(a[0]) || !utils.IsValidSquare(a[1]) || a[0] == a[1] {
+				continue
+			}
+			if len(boards) > 0 && !occupied(a[0]) {
+				if !occupied(a[1]) {
+					continue
+				}
+				a = [2]string{a[1], a[0]}
+			}
+			valid = append(valid, a)
+			if !seen[a] {
+				seen[a] = true
+				union = append(union, a)
+			}
+		}
+		return valid
+	}
+
+	keep(arrows)
+	if groups != nil {
+		groups.Threats = keep(groups.Threats)
+		groups.Plans = keep(groups.Plans)
+		groups.Defenses = keep(groups.Defenses)
+	}
+	return union
+}
+
+func normalizeSquare(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// ArrowBoards returns the boards a comment on move san in pos may draw on: the position
+// before the move and, when san is legal there, the one after it. It returns nil for a
+// nil pos.
+func ArrowBoards(pos *chess.Position, san string) []*chess.Position {
+	if pos == nil {
+		return nil
+	}
+	boards := []*chess.Position{pos}
+	if m, err := pos.ParseSAN(san); err == nil {
+		boards = append(boards, pos.Play(m))
+	}
+	return boards
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseCoachMoveGroupedArrows(t *testing.T) {
+	resp, err := parseCoachMove(context.Background(), `{
+		"comment": "Develop and watch f7.",
+		"move": "Nf3",
+		"arrows": [["d2", "d4"]],
+		"arrow_groups": {
+			"threats": [["d8", "h4"]],
+			"plans": [["f1", "c4"], ["e1", "g1"]],
+			"defenses": [["g1", "f3"]]
+		},
+		"title": "Open Game"
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &types.ArrowGroups{
+		Threats:  [][2]string{{"d8", "h4"}},
+		Plans:    [][2]string{{"f1", "c4"}, {"e1", "g1"}},
+		Defenses: [][2]string{{"g1", "f3"}},
+	}
+	if !reflect.DeepEqual(resp.ArrowGroups, want) {
+		t.Errorf("arrow groups = %+v, want %+v", resp.ArrowGroups, want)
+	}
+
+	union := SanitizeArrows(resp.Arrows, resp.ArrowGroups)
+	wantUnion := [][2]string{{"d2", "d4"}, {"d8", "h4"}, {"f1", "c4"}, {"e1", "g1"}, {"g1", "f3"}}
+	if !reflect.DeepEqual(union, wantUnion) {
+		t.Errorf("arrows = %v, want the union %v", union, wantUnion)
+	}
+}
+
+func TestParseCoachMoveLegacyArrows(t *testing.T) {
+	resp, err := parseCoachMove(context.Background(),
+		`{"comment": "Take the center.", "move": "e4", "arrows": [["e2", "e4"], ["d2", "d4"]], "title": "Opening"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ArrowGroups != nil {
+		t.Errorf("arrow groups = %+v for a response without them", resp.ArrowGroups)
+	}
+	want := [][2]string{{"e2", "e4"}, {"d2", "d4"}}
+	if got := SanitizeArrows(resp.Arrows, resp.ArrowGroups); !reflect.DeepEqual(got, want) {
+		t.Errorf("arrows = %v, want %v", got, want)
+	}
+}
+
+func TestSanitizeArrows(t *testing.T) {
+	groups := &types.ArrowGroups{
+		Threats:  [][2]string{{"D8", " h4 "}, {"z9", "h4"}},
+		Plans:    [][2]string{{"e2", "e4"}, {"c4", "f1"}},
+		Defenses: [][2]string{{"g1", "g1"}, {"e5", "e6"}},
+	}
+	arrows := [][2]string{{"e2", "e4"}, {"i1", "a1"}}
+	start := chess.NewGame()
+
+	union := SanitizeArrows(arrows, groups, start)
+
+	wantGroups := &types.ArrowGroups{
+		Threats: [][2]string{{"d8", "h4"}},
+		// An arrow drawn backwards to a piece is turned around.
+		Plans:    [][2]string{{"e2", "e4"}, {"f1", "c4"}},
+		Defenses: [][2]string{},
+	}
+	if !reflect.DeepEqual(groups, wantGroups) {
+		t.Errorf("groups = %+v, want %+v", groups, wantGroups)
+	}
+	wantUnion := [][2]string{{"e2", "e4"}, {"d8", "h4"}, {"f1", "c4"}}
+	if !reflect.DeepEqual(union, wantUnion) {
+		t.Errorf("arrows = %v, want %v", union, wantUnion)
+	}
+}
+
+func TestGenerateCoachMoveSanitizesArrowGroups(t *testing.T) {
+	provider := &scriptedProvider{responses: []string{`{
+		"comment": "Hit back in the center.",
+		"move": "e5",
+		"arrows": [],
+		"arrow_groups": {"plans": [["g8", "f6"], ["q1", "f6"]]},
+		"title": "Open Game"
+	}`}}
+	ctx := ai.WithBudget(context.Background(), ai.NewBudget(5))
+	resp, err := New(provider).GenerateCoachMove(ctx, startState(), Options{MaxAttempts: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ArrowGroups == nil || !reflect.DeepEqual(resp.ArrowGroups.Plans, [][2]string{{"g8", "f6"}}) {
+		t.Errorf("arrow groups = %+v, want only the valid plan", resp.ArrowGroups)
+	}
+	if !reflect.DeepEqual(resp.Arrows, [][2]string{{"g8", "f6"}}) {
+		t.Errorf("arrows = %v, want the grouped arrow in the flat list", resp.Arrows)
+	}
+}
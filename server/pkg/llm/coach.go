@@ -0,0 +1,457 @@
+// Package llm builds the coach's model calls: prompts, response schemas, parsing and the
+// retry loop that keeps model moves legal. Handlers stay thin wrappers over it.
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/explorer"
+	"arnavsurve/nara-chess/server/pkg/metrics"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/tablebase"
+	"arnavsurve/nara-chess/server/pkg/tracing"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"arnavsurve/nara-chess/server/pkg/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/google/generative-ai-go/genai"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	ErrInvalidFEN  = errors.New("llm: invalid FEN")
+	ErrBadResponse = errors.New("llm: unparseable model response")
+	ErrEmptyMove   = errors.New("llm: model response has no move")
+	ErrNoLegalMove = errors.New("llm: model did not suggest a legal move")
+)
+
+// NoLegalMoveError reports the moves rejected before the attempts ran out.
+type NoLegalMoveError struct {
+	Attempts int
+	Rejected string
+}
+
+func (e *NoLegalMoveError) Error() string {
+	return fmt.Sprintf("Analysis service did not suggest a legal move after %d attempts (rejected: %s)", e.Attempts, e.Rejected)
+}
+
+func (e *NoLegalMoveError) Unwrap() error {
+	return ErrNoLegalMove
+}
+
+const constraintInstruction = `
+
+DRILLING THEME: Your pupil's coach is drilling the following theme: "%s".
+Choose your move to illustrate this theme whenever a legal move allows it, even if it is not the objectively strongest move. Mention the theme in your comment. Your move must still be legal.`
+
+const reasoningInstruction = `
+
+REASONING: Also fill the "analysis" field with your full reasoning: the candidate moves you considered for both sides, the concrete lines you calculated and why you rejected the alternatives. Keep "comment" short and beginner-friendly; the analysis is for advanced readers.`
+
+var gameStateResponseSchema = &genai.Schema{
+	Type:        genai.TypeObject,
+	Description: "Response containing commentary on the chess game state and next move.",
+	Properties: map[string]*genai.Schema{
+		"comment": {
+			Type:        genai.TypeString,
+			Description: "A brief commentary (1-3 sentences) on the current game situation, evaluating the state of the game for black and white. Include coaching information here.",
+		},
+		"move": {
+			Type:        genai.TypeString,
+			Description: "The move you would like to make in Standard Algebraic Notation (SAN), e.g., 'Nf3', 'O-O', 'e8=Q+'.",
+		},
+		"arrows": {
+			Type:        genai.TypeArray,
+			Description: "Optional coaching arrows to display. Each is a tuple of two square strings (from, to). Used to show threats, good ideas, plans, etc.",
+			Items: &genai.Schema{
+				Type: genai.TypeArray,
+				Items: &genai.Schema{
+					Type: genai.TypeString,
+				},
+			},
+		},
+		"arrow_groups": ArrowGroupsSchema,
+		"title": {
+			Type:        genai.TypeString,
+			Description: "A short phrase to describe the current game.",
+		},
+	},
+	Required: []string{"comment", "move"},
+}
+
+// analysisProperty holds the detailed reasoning requested by include_reasoning.
+var analysisProperty = &genai.Schema{
+	Type:        genai.TypeString,
+	Description: "Your detailed reasoning: candidate moves, calculated lines and why alternatives were rejected.",
+}
+
+// gameStateResponseSchemaWithAnalysis adds the optional reasoning field, requested only
+// when the client asks for it since it costs extra output tokens.
+var gameStateResponseSchemaWithAnalysis = withProperty(gameStateResponseSchema, "analysis", analysisProperty)
+
+// withProperty returns a copy of an object schema with one more optional property.
+func withProperty(schema *genai.Schema, name string, property *genai.Schema) *genai.Schema {
+	c := *schema
+	c.Properties = make(map[string]*genai.Schema, len(schema.Properties)+1)
+	for k, v := range schema.Properties {
+		c.Properties[k] = v
+	}
+	c.Properties[name] = property
+	return &c
+}
+
+// withoutProperty returns a copy of schema with the named property removed.
+func withoutProperty(schema *genai.Schema, name string) *genai.Schema {
+	c := *schema
+	c.Properties = make(map[string]*genai.Schema, len(schema.Properties))
+	for k, v := range schema.Properties {
+		if k != name {
+			c.Properties[k] = v
+		}
+	}
+	c.Required = nil
+	for _, r := range schema.Required {
+		if r != name {
+			c.Required = append(c.Required, r)
+		}
+	}
+	return &c
+}
+
+// Service asks the model for the coach's moves. It owns the prompt, the response schema,
+// parsing, and the retry loop that rejects illegal, stalemating and tablebase-losing moves.
+type Service struct {
+	Provider ai.Provider
+
+	// group collapses identical model calls made at the same time into one; flights
+	// tracks who waits on each.
+	group    singleflight.Group
+	flightMu sync.Mutex
+	flights  map[string]*flight
+}
+
+func New(provider ai.Provider) *Service {
+	return &Service{Provider: provider}
+}
+
+// Options tunes a single GenerateCoachMove call.
+type Options struct {
+	Temperature     float32
+	Model           string
+	MaxOutputTokens int32
+	// OmitSchema drops the response schema and relies on the prompt's format instructions.
+	OmitSchema bool
+	// MaxAttempts caps how often the model is asked again after a rejected move.
+	MaxAttempts int
+	// Tablebase is the exact result of an endgame position, when one was probed. Moves
+	// that worsen it are rejected like illegal ones.
+	Tablebase *tablebase.Result
+	// Explorer is how masters continued from the position, when known.
+	Explorer *explorer.Stats
+	// TakenBack lists the moves the pupil just took back, which the coach acknowledges.
+	TakenBack []string
+	// Profile is what the coach has learnt about the pupil from earlier games, when known.
+	Profile *store.Profile
+}
+
+// GenerateCoachMove asks the model for the coach's move and comment in state. The move is
+// checked against the position and, when illegal, needlessly stalemating or worse than the
+// tablebase allows, the model is asked again with every rejected move listed, up to
+// opts.MaxAttempts times. The returned move is in canonical SAN and the arrows are
+// sanitized.
+func (s *Service) GenerateCoachMove(ctx context.Context, state types.GameStateRequest, opts Options) (types.GameStateResponse, error) {
+	ctx, span := tracing.Start(ctx, "llm.GenerateCoachMove", attribute.String("fen", state.Fen))
+	defer span.End()
+	var rejected rejectedMoves
+	if state.WrongMove != "" {
+		rejected.add(state.WrongMove, "an INVALID MOVE")
+	}
+
+	_, buildSpan := tracing.Start(ctx, "llm.buildPrompt")
+	moveHistoryStr := strings.Join(state.MoveHistory, " ")
+
+	llmSide, pupilSide, err := utils.InferSidesFromFEN(state.Fen)
+	if err != nil {
+		slog.WarnContext(ctx, "Error parsing FEN for side inference", "err", err)
+		err = fmt.Errorf("%w: %w", ErrInvalidFEN, err)
+		tracing.End(buildSpan, err)
+		return types.GameStateResponse{}, err
+	}
+
+	coach := statePersona(state)
+	promptText := fmt.Sprintf(`You are %s in an ongoing educational match against your pupil.
+
+You are playing as %s.  
+Your pupil is playing as %s.  
+It is currently your turn to move — your pupil just made the last move.  
+
+You must:
+1. Select the best next move for your side (%s) using strong chess principles.
+2. Evaluate the position for both sides — from your pupil’s perspective.
+3. Provide insightful, constructive feedback that helps your pupil improve.
+
+In your response:
+- Identify specific positional features (e.g., weak squares, piece activity, king safety, space, pawn structure).
+- **Explain the ideas behind your move and how it fits into a short-term or long-term plan.**
+- Mention any **good ideas** or **mistakes** your pupil made in their last move or overall game direction.
+- **Offer a brief tactical or strategic concept they could focus on (e.g., "look for pins", "consider open files", "avoid weakening squares like f3").**
+- **Relate their move to classical principles or named openings if appropriate (e.g., “this is common in the Italian Game”)**.
+- %s
+- Think deeply when formulating your response to provide appropriate coaching based on the opponent's estimated skill level and bringing up interesting lines or characteristics of the game state.
+
+- If useful, include a list of 1–3 arrows that would help the pupil visualize the plan, threats, or key ideas on the board. ENSURE YOU ELABORATE ON THE MOVES THAT THESE ARROWS DESCRIBE. Only use arrows to help illustrate your description of *future moves*, threats, or key ideas. Do not use arrows without already having described the scenario for that arrow. Do not use an arrow to indicate a move that you or the player has made already or is currently making.
+- Use the format: ["from-square", "to-square"] — for example: ["e4", "e5"] to suggest a pawn push.
+- These arrows are used to help the user *learn*, so show things like threats, weak squares, tactical ideas, or developing moves that may be applicable to either side.
+- DO NOT use arrows unless the game's position ABSOLUTELY NECESSITATES an opportunity for in depth analysis. For textbook positions or early game, DO NOT RETURN ANY ARROWS.
+
+
+**Pronoun usage rules**:
+- Refer to yourself as “I” and to the pupil as “you”.
+- Do **not** use “we”, “us”, or “our”.
+
+FEN: %s  
+Move History: %s
+Chat History: %s
+
+Output your response **strictly** as a JSON object matching this schema:
+
+{
+  "comment": "...", // Constructive coaching commentary (1–3 sentences)
+  "move": "..."     // Your move in SAN (e.g., "Nf3", "O-O", "e8=Q+")
+  "arrows": [["e4", "e5"], ["g1", "f3"]]
+  "title": "Italian Game, Hectic Endgame, King's Gambit, Unique Opening"
+}
+
+Do NOT include anything outside the JSON object.`, coach.RoleOr("a strong chess engine, commentator, and coach"), llmSide, pupilSide, llmSide,
+		coach.ToneOr("Use clear and simple language and talk in a casual tone, minimizing filler language. Be direct in your communication."),
+		state.Fen, moveHistoryStr, state.ChatHistory)
+	slog.DebugContext(ctx, "Coach move prompt", "prompt", promptText)
+
+	prompt := promptText + ArrowGroupsInstruction
+	if state.Constraint != "" {
+		prompt += fmt.Sprintf(constraintInstruction, state.Constraint)
+	}
+	elo, _ := state.Difficulty.Elo()
+	prompt += difficultyInstruction(elo)
+	prompt += styleInstruction(coach)
+	schema := gameStateResponseSchema
+	if state.IncludeReasoning {
+		prompt += reasoningInstruction
+		schema = gameStateResponseSchemaWithAnalysis
+	}
+	if opts.OmitSchema {
+		schema = nil
+	}
+
+	// Positions that fail to parse still get a move; they just skip the local checks.
+	pos := statePosition(state)
+
+	avoidStalemate := pos != nil && stalemateRisk(pos)
+	if avoidStalemate {
+		prompt += stalemateWarning
+	}
+	prompt += tablebaseInstruction(opts.Tablebase, true)
+	prompt += explorerInstruction(opts.Explorer)
+	prompt += takebackInstruction(opts.TakenBack)
+	prompt += variantInstruction(pos)
+	prompt += handicapInstruction(state.InitialFen, pos)
+	prompt += ProfileInstruction(opts.Profile)
+	buildSpan.SetAttributes(attribute.Int("prompt.length", len(prompt)))
+	buildSpan.End()
+
+	var gameStateResponse types.GameStateResponse
+	for attempt := 1; ; attempt++ {
+		slog.InfoContext(ctx, "Asking the model for the coach's move", "attempt", attempt)
+		jsonString, err := s.generate(ctx, ai.Request{
+			Prompt:          prompt + rejected.instruction(),
+			Schema:          schema,
+			Temperature:     opts.Temperature,
+			Model:           opts.Model,
+			MaxOutputTokens: opts.MaxOutputTokens,
+		})
+		if err != nil {
+			return types.GameStateResponse{}, err
+		}
+
+		_, parseSpan := tracing.Start(ctx, "llm.parse")
+		gameStateResponse, err = parseCoachMove(ctx, jsonString)
+		tracing.End(parseSpan, err)
+		if err != nil {
+			return types.GameStateResponse{}, err
+		}
+
+		// Check the move against the position so an illegal move is retried here instead of
+		// the client resubmitting it as wrong_move.
+		_, validateSpan := tracing.Start(ctx, "llm.validate", attribute.String("move", gameStateResponse.Move))
+		legal := true
+		if pos != nil {
+			if m, err := notation.ParseMove(pos, gameStateResponse.Move); err != nil {
+				legal = false
+			} else {
+				gameStateResponse.Move = pos.SAN(m)
+			}
+		}
+
+		// Stalemating and result-worsening moves are still legal, so they are played rather
+		// than failing the request once the attempts run out.
+		lastAttempt := attempt >= opts.MaxAttempts || !ai.BudgetFrom(ctx).Remaining()
+		verdict := metrics.MoveAccepted
+		switch {
+		case !legal:
+			verdict = metrics.MoveIllegal
+		case lastAttempt:
+		case avoidStalemate && stalematesOpponent(pos, gameStateResponse.Move):
+			verdict = metrics.MoveStalemate
+		case throwsAwayResult(opts.Tablebase, gameStateResponse.Move):
+			verdict = metrics.MoveTablebase
+		}
+		validateSpan.SetAttributes(attribute.String("verdict", verdict))
+		validateSpan.End()
+		metrics.Move(verdict)
+		if verdict == metrics.MoveAccepted {
+			break
+		}
+
+		switch verdict {
+		case metrics.MoveIllegal:
+			slog.InfoContext(ctx, "Rejecting illegal move", "move", gameStateResponse.Move, "attempt", attempt)
+			rejected.add(gameStateResponse.Move, "an INVALID MOVE")
+			if lastAttempt {
+				return types.GameStateResponse{}, &NoLegalMoveError{Attempts: attempt, Rejected: rejected.String()}
+			}
+		case metrics.MoveStalemate:
+			slog.InfoContext(ctx, "Rejecting stalemating move, regenerating", "move", gameStateResponse.Move)
+			rejected.add(gameStateResponse.Move, "stalemates your pupil and throws away the win")
+		case metrics.MoveTablebase:
+			slog.InfoContext(ctx, "Rejecting move that worsens the tablebase result, regenerating", "move", gameStateResponse.Move)
+			rejected.add(gameStateResponse.Move, "throws away the tablebase result")
+		}
+		metrics.MoveRetry(verdict)
+	}
+
+	gameStateResponse.Arrows = SanitizeArrows(gameStateResponse.Arrows, gameStateResponse.ArrowGroups, ArrowBoards(pos, gameStateResponse.Move)...)
+	return gameStateResponse, nil
+}
+
+// parseCoachMove decodes the model's answer to a move request, normalizing the move's SAN.
+func parseCoachMove(ctx context.Context, jsonString string) (types.GameStateResponse, error) {
+	var resp types.GameStateResponse
+	if err := json.Unmarshal([]byte(jsonString), &resp); err != nil {
+		slog.ErrorContext(ctx, "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(ctx, "Unparseable model response", "response", jsonString)
+		return types.GameStateResponse{}, fmt.Errorf("%w: %v", ErrBadResponse, err)
+	}
+
+	resp.Move = utils.NormalizeSAN(resp.Move)
+	if resp.Move == "" {
+		slog.WarnContext(ctx, "The model returned JSON but the 'move' field was empty")
+		slog.DebugContext(ctx, "Model response without a move", "response", jsonString)
+		return types.GameStateResponse{}, ErrEmptyMove
+	}
+	return resp, nil
+}
+
+// generate draws one call from the request's budget and quota and calls the provider,
+// sharing the call with identical requests made at the same time.
+func (s *Service) generate(ctx context.Context, req ai.Request) (string, error) {
+	if err := ai.TakeCall(ctx); err != nil {
+		return "", err
+	}
+	jsonString, err := s.sharedGenerate(ctx, req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error generating content from the model", "err", err)
+		return "", err
+	}
+	slog.DebugContext(ctx, "Model response", "response", jsonString)
+	return jsonString, nil
+}
+
+// commentResponseSchema is gameStateResponseSchema for a move that has already been chosen.
+var commentResponseSchema = withoutProperty(gameStateResponseSchema, "move")
+
+const explainMovePrompt = `You are %s in an ongoing educational match against your pupil.
+
+You are playing as %s.
+Your pupil is playing as %s.
+It is your turn, and a strong engine has already chosen your move: %s. You must play exactly this move; do not suggest a different one.
+
+Write the coaching commentary for this move:
+- Explain the idea behind %s and how it fits into a short-term or long-term plan.
+- Mention any good ideas or mistakes your pupil made in their last move or overall game direction.
+- Offer a brief tactical or strategic concept they could focus on.
+- Relate the position to classical principles or named openings if appropriate.
+- %s
+- Only add arrows (["from-square", "to-square"]) for future moves, threats or key ideas you have described, and none for textbook or early-game positions.
+
+Refer to yourself as "I" and to the pupil as "you". Do not use "we", "us", or "our".
+
+FEN: %s
+Move History: %s
+Chat History: %s
+
+Output your response strictly as a JSON object with "comment", optional "arrows" and a short "title" describing the game. Do NOT include anything outside the JSON object.`
+
+// ExplainMove asks the model to comment on move, which was chosen elsewhere (typically by
+// an engine), instead of choosing one itself. move must be legal in state.Fen; the
+// returned response carries it in canonical SAN.
+func (s *Service) ExplainMove(ctx context.Context, state types.GameStateRequest, move string, opts Options) (types.GameStateResponse, error) {
+	llmSide, pupilSide, err := utils.InferSidesFromFEN(state.Fen)
+	if err != nil {
+		return types.GameStateResponse{}, fmt.Errorf("%w: %w", ErrInvalidFEN, err)
+	}
+
+	coach := statePersona(state)
+	prompt := fmt.Sprintf(explainMovePrompt, coach.RoleOr("a chess coach"), llmSide, pupilSide, move, move,
+		coach.ToneOr("Use clear and simple language and a casual tone, minimizing filler. Be direct."), state.Fen,
+		strings.Join(state.MoveHistory, " "), state.ChatHistory) + ArrowGroupsInstruction
+	if state.Constraint != "" {
+		prompt += fmt.Sprintf(constraintInstruction, state.Constraint)
+	}
+	elo, _ := state.Difficulty.Elo()
+	prompt += explainDifficultyInstruction(elo)
+	prompt += tablebaseInstruction(opts.Tablebase, false)
+	prompt += explorerInstruction(opts.Explorer)
+	prompt += takebackInstruction(opts.TakenBack)
+	pos := statePosition(state)
+	prompt += variantInstruction(pos)
+	prompt += handicapInstruction(state.InitialFen, pos)
+	prompt += ProfileInstruction(opts.Profile)
+	schema := commentResponseSchema
+	if state.IncludeReasoning {
+		prompt += reasoningInstruction
+		schema = withProperty(commentResponseSchema, "analysis", analysisProperty)
+	}
+	if opts.OmitSchema {
+		schema = nil
+	}
+
+	slog.InfoContext(ctx, "Asking the model to explain the engine's move", "move", move)
+	jsonString, err := s.generate(ctx, ai.Request{
+		Prompt:          prompt,
+		Schema:          schema,
+		Temperature:     opts.Temperature,
+		Model:           opts.Model,
+		MaxOutputTokens: opts.MaxOutputTokens,
+	})
+	if err != nil {
+		return types.GameStateResponse{}, err
+	}
+
+	var resp types.GameStateResponse
+	if err := json.Unmarshal([]byte(jsonString), &resp); err != nil {
+		slog.ErrorContext(ctx, "Error unmarshalling model JSON response", "err", err)
+		slog.DebugContext(ctx, "Unparseable model response", "response", jsonString)
+		return types.GameStateResponse{}, fmt.Errorf("%w: %v", ErrBadResponse, err)
+	}
+	// The engine's move stands whatever the model wrote into the response.
+	resp.Move = move
+	resp.Arrows = SanitizeArrows(resp.Arrows, resp.ArrowGroups, ArrowBoards(pos, move)...)
+	return resp, nil
+}
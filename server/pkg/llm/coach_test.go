@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func startState() types.GameStateRequest {
+	return types.GameStateRequest{Fen: "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", InitialFen: chess.StartFEN, MoveHistory: []string{"e4"}}
+}
+
+func TestGenerateCoachMoveConstraintReachesPrompt(t *testing.T) {
+	const theme = "only play developing moves"
+	state := startState()
+	state.Constraint = theme
+
+	provider := &scriptedProvider{responses: []string{coachReply("Nc6")}}
+	ctx := ai.WithBudget(context.Background(), ai.NewBudget(5))
+	if _, err := New(provider).GenerateCoachMove(ctx, state, Options{MaxAttempts: 3}); err != nil {
+		t.Fatal(err)
+	}
+	prompt := provider.requests[0].Prompt
+	if !strings.Contains(prompt, `DRILLING THEME: Your pupil's coach is drilling the following theme: "`+theme+`"`) {
+		t.Error("prompt lacks the drilling theme")
+	}
+	if !strings.Contains(prompt, "even if it is not the objectively strongest move") {
+		t.Error("prompt does not let the coach trade strength for the theme")
+	}
+}
+
+func TestGenerateCoachMoveWithoutConstraint(t *testing.T) {
+	provider := &scriptedProvider{responses: []string{coachReply("e5")}}
+	ctx := ai.WithBudget(context.Background(), ai.NewBudget(5))
+	if _, err := New(provider).GenerateCoachMove(ctx, startState(), Options{MaxAttempts: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(provider.requests[0].Prompt, "DRILLING THEME") {
+		t.Error("prompt has a drilling theme nobody asked for")
+	}
+}
+
+// A constrained coach is still held to legal moves.
+func TestGenerateCoachMoveConstraintKeepsMovesLegal(t *testing.T) {
+	state := startState()
+	state.Constraint = "only play developing moves"
+
+	provider := &scriptedProvider{responses: []string{coachReply("Bc5"), coachReply("Nf6")}}
+	ctx := ai.WithBudget(context.Background(), ai.NewBudget(5))
+	resp, err := New(provider).GenerateCoachMove(ctx, state, Options{MaxAttempts: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Move != "Nf6" {
+		t.Errorf("move = %s, want the legal Nf6 after the illegal Bc5", resp.Move)
+	}
+	if retry := provider.requests[1].Prompt; !strings.Contains(retry, "Bc5") || !strings.Contains(retry, "DRILLING THEME") {
+		t.Error("retry prompt lost the rejected move or the theme")
+	}
+}
+
+func TestExplainMoveConstraintReachesPrompt(t *testing.T) {
+	const theme = "fight for the center"
+	state := startState()
+	state.Constraint = theme
+
+	provider := &scriptedProvider{responses: []string{`{"comment": "Striking back.", "arrows": [], "title": "Open Game"}`}}
+	if _, err := New(provider).ExplainMove(context.Background(), state, "e5", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(provider.requests[0].Prompt, `"`+theme+`"`) {
+		t.Error("prompt lacks the drilling theme")
+	}
+}
+
+// The request's budget caps the model calls even when more attempts are allowed.
+func TestGenerateCoachMoveBudgetCapsCalls(t *testing.T) {
+	provider := &scriptedProvider{responses: []string{coachReply("Ke3"), coachReply("Ke2"), coachReply("Kd3"), coachReply("e5")}}
+	budget := ai.NewBudget(3)
+	ctx := ai.WithBudget(context.Background(), budget)
+
+	_, err := New(provider).GenerateCoachMove(ctx, startState(), Options{MaxAttempts: 10})
+	var noLegal *NoLegalMoveError
+	if !errors.As(err, &noLegal) {
+		t.Fatalf("err = %v, want a *NoLegalMoveError", err)
+	}
+	if len(provider.requests) != 3 || budget.Used() != 3 {
+		t.Errorf("made %d model calls and used %d of the budget, want 3", len(provider.requests), budget.Used())
+	}
+}
@@ -0,0 +1,55 @@
+package llm
+
+import "fmt"
+
+// Elo bands that change how the coach plays and talks.
+const (
+	beginnerMaxElo     = 1199
+	intermediateMaxElo = 1799
+)
+
+const beginnerInstruction = `
+
+DIFFICULTY: Play like a coach facing a beginner rated about %d Elo.
+- Use simple, everyday language. Avoid jargon; when you must use a chess term, explain it in a few words.
+- Do not always play the strongest move. Prefer natural, instructive moves, and now and then leave an opportunity your pupil can spot, such as an undefended piece or a simple fork, so they learn to look for it. Never throw the game away with absurd moves.
+- Keep to one idea per comment.`
+
+const intermediateInstruction = `
+
+DIFFICULTY: Play like a coach facing a club player rated about %d Elo.
+- Play sound, principled moves, but favor clear plans over razor-sharp complications, and occasionally choose a slightly weaker move that sets up an instructive middlegame or tactic.
+- Standard chess terms are fine; explain deeper concepts briefly.`
+
+const advancedInstruction = `
+
+DIFFICULTY: Play at full strength against a strong player rated about %d Elo.
+- Choose the objectively best move.
+- Use precise technical language and point out concrete lines.`
+
+// difficultyInstruction returns the prompt addition for elo, or "" at full strength.
+func difficultyInstruction(elo int) string {
+	switch {
+	case elo <= 0:
+		return ""
+	case elo <= beginnerMaxElo:
+		return fmt.Sprintf(beginnerInstruction, elo)
+	case elo <= intermediateMaxElo:
+		return fmt.Sprintf(intermediateInstruction, elo)
+	}
+	return fmt.Sprintf(advancedInstruction, elo)
+}
+
+// explainDifficultyInstruction adapts only the language of an explanation to elo, since
+// the move has already been chosen.
+func explainDifficultyInstruction(elo int) string {
+	switch {
+	case elo <= 0:
+		return ""
+	case elo <= beginnerMaxElo:
+		return fmt.Sprintf("\n\nDIFFICULTY: Your pupil is a beginner rated about %d Elo. Use simple, everyday language, explain any chess term you use, and keep to one idea.", elo)
+	case elo <= intermediateMaxElo:
+		return fmt.Sprintf("\n\nDIFFICULTY: Your pupil is a club player rated about %d Elo. Standard chess terms are fine; explain deeper concepts briefly.", elo)
+	}
+	return fmt.Sprintf("\n\nDIFFICULTY: Your pupil is a strong player rated about %d Elo. Use precise technical language and concrete lines.", elo)
+}
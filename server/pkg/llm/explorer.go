@@ -0,0 +1,38 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+
+	"arnavsurve/nara-chess/server/pkg/explorer"
+)
+
+// explorerMoves is how many of the most played master moves the prompt lists.
+const explorerMoves = 5
+
+// explorerInstruction lists the moves masters played most from the position so opening
+// advice can point to real practice.
+func explorerInstruction(s *explorer.Stats) string {
+	if s == nil || len(s.Moves) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n\nMASTER PRACTICE: This position occurred in %d master games", s.Games())
+	if s.Opening != nil {
+		fmt.Fprintf(&sb, " (%s %s)", s.Opening.ECO, s.Opening.Name)
+	}
+	sb.WriteString(". The most played moves and how those games ended:")
+	for i, m := range s.Moves {
+		if i == explorerMoves {
+			break
+		}
+		n := m.Games()
+		if n == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n- %s: %d games, White won %d%%, drawn %d%%, Black won %d%%",
+			m.SAN, n, 100*m.White/n, 100*m.Draws/n, 100*m.Black/n)
+	}
+	sb.WriteString("\nWhere it helps, mention what masters usually play here; you need not play the most popular move.")
+	return sb.String()
+}
@@ -0,0 +1,156 @@
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// flight is a model call shared by the identical requests waiting on it, such as several
+// clients, or a client and its own retry, asking about the same position at once.
+type flight struct {
+	key    string
+	ctx    context.Context
+	cancel context.CancelFunc
+	// trace records the provider and model that answered, for every waiter's response.
+	trace   *ai.Trace
+	waiters int
+
+	// deadlineMu guards deadline and unbounded, which the call's context reports: the
+	// latest deadline among the waiters, or none once a waiter without one has joined.
+	deadlineMu sync.Mutex
+	deadline   time.Time
+	unbounded  bool
+}
+
+// join extends f's deadline to cover the waiter with ctx.
+func (f *flight) join(ctx context.Context) {
+	f.deadlineMu.Lock()
+	defer f.deadlineMu.Unlock()
+	d, ok := ctx.Deadline()
+	switch {
+	case !ok:
+		f.unbounded = true
+	case d.After(f.deadline):
+		f.deadline = d
+	}
+}
+
+// flightContext is the context of a flight's call. It reports the flight's deadline
+// rather than its first waiter's, so a retrying provider gives up when the last waiter
+// would, not the first.
+type flightContext struct {
+	context.Context
+	f *flight
+}
+
+func (c flightContext) Deadline() (time.Time, bool) {
+	c.f.deadlineMu.Lock()
+	defer c.f.deadlineMu.Unlock()
+	if c.f.unbounded {
+		return time.Time{}, false
+	}
+	return c.f.deadline, true
+}
+
+// sharedGenerate calls the provider with req, or waits on the identical call already
+// under way and shares its answer. The call belongs to no single request: it goes on while
+// any of its waiters does, and is cancelled once they have all given up. Its retries draw
+// on a budget of its own, holding what its first waiter had left.
+func (s *Service) sharedGenerate(ctx context.Context, req ai.Request) (string, error) {
+	key, err := flightKey(ctx, req)
+	if err != nil {
+		return s.Provider.GenerateJSON(ctx, req)
+	}
+
+	s.flightMu.Lock()
+	f := s.flights[key]
+	if f == nil {
+		trace := &ai.Trace{}
+		var budget *ai.Budget
+		if left := ai.BudgetFrom(ctx).Left(); left >= 0 {
+			budget = ai.NewBudget(left)
+		}
+		callCtx, cancel := context.WithCancel(ai.WithBudget(ai.WithTrace(context.WithoutCancel(ctx), trace), budget))
+		f = &flight{key: key, cancel: cancel, trace: trace}
+		f.ctx = flightContext{Context: callCtx, f: f}
+		if s.flights == nil {
+			s.flights = make(map[string]*flight)
+		}
+		s.flights[key] = f
+	}
+	f.waiters++
+	f.join(ctx)
+	// The flight and the group's call are registered together under flightMu, so a request
+	// never joins one without the other.
+	results := s.group.DoChan(key, func() (any, error) {
+		defer s.land(f)
+		return s.Provider.GenerateJSON(f.ctx, req)
+	})
+	s.flightMu.Unlock()
+
+	select {
+	case res := <-results:
+		s.leave(f)
+		if res.Shared {
+			slog.DebugContext(ctx, "Shared a model call with identical requests")
+		}
+		if t := ai.TraceFrom(ctx); t != nil {
+			t.Adopt(f.trace)
+		}
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return res.Val.(string), nil
+	case <-ctx.Done():
+		s.leave(f)
+		return "", ctx.Err()
+	}
+}
+
+// land retires f once its call has returned.
+func (s *Service) land(f *flight) {
+	s.flightMu.Lock()
+	defer s.flightMu.Unlock()
+	s.retire(f)
+	f.cancel()
+}
+
+// leave gives up one waiter's place on f, cancelling the call when nobody waits for it.
+// Requests arriving after that start a call of their own rather than joining one that is
+// being cancelled.
+func (s *Service) leave(f *flight) {
+	s.flightMu.Lock()
+	defer s.flightMu.Unlock()
+	f.waiters--
+	if f.waiters == 0 {
+		s.retire(f)
+		f.cancel()
+	}
+}
+
+// retire stops new requests from joining f. s.flightMu must be held.
+func (s *Service) retire(f *flight) {
+	if s.flights[f.key] == f {
+		delete(s.flights, f.key)
+		s.group.Forget(f.key)
+	}
+}
+
+// flightKey identifies the calls that would send the same request to the same provider.
+func flightKey(ctx context.Context, req ai.Request) (string, error) {
+	data, err := json.Marshal(struct {
+		Provider string
+		Request  ai.Request
+	}{ProviderFrom(ctx), req})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
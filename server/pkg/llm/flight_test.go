@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedProvider holds every call until release is closed, failing the first with a
+// transient error, and records the deadline each call saw.
+type gatedProvider struct {
+	release   chan struct{}
+	mu        sync.Mutex
+	deadlines []time.Time
+}
+
+func (p *gatedProvider) GenerateJSON(ctx context.Context, req ai.Request) (string, error) {
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	d, _ := ctx.Deadline()
+	p.deadlines = append(p.deadlines, d)
+	if len(p.deadlines) == 1 {
+		return "", io.ErrUnexpectedEOF
+	}
+	return coachReply("e5"), nil
+}
+
+// waitForWaiters waits until the flight for req has n waiters.
+func waitForWaiters(t *testing.T, s *Service, ctx context.Context, req ai.Request, n int) {
+	t.Helper()
+	key, err := flightKey(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		s.flightMu.Lock()
+		f := s.flights[key]
+		joined := f != nil && f.waiters == n
+		s.flightMu.Unlock()
+		if joined {
+			return
+		}
+	}
+	t.Fatalf("flight never had %d waiters", n)
+}
+
+// A shared call outlives the request that started it with the deadline of the waiter
+// still there, and retries without drawing on the departed request's budget.
+func TestSharedCallOutlivesLeader(t *testing.T) {
+	provider := &gatedProvider{release: make(chan struct{})}
+	s := New(ai.NewRetrier(provider, 1, time.Millisecond))
+	req := ai.Request{Prompt: "same position"}
+
+	leaderBudget := ai.NewBudget(2)
+	leaderCtx, cancelLeader := context.WithTimeout(ai.WithBudget(context.Background(), leaderBudget), time.Minute)
+	defer cancelLeader()
+	followerCtx, cancelFollower := context.WithTimeout(ai.WithBudget(context.Background(), ai.NewBudget(5)), time.Hour)
+	defer cancelFollower()
+	followerDeadline, _ := followerCtx.Deadline()
+
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := s.generate(leaderCtx, req)
+		leaderErr <- err
+	}()
+	waitForWaiters(t, s, leaderCtx, req, 1)
+	type result struct {
+		text string
+		err  error
+	}
+	follower := make(chan result, 1)
+	go func() {
+		text, err := s.generate(followerCtx, req)
+		follower <- result{text, err}
+	}()
+	waitForWaiters(t, s, followerCtx, req, 2)
+
+	cancelLeader()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("leader: err = %v, want context.Canceled", err)
+	}
+	close(provider.release)
+	if res := <-follower; res.err != nil || res.text != coachReply("e5") {
+		t.Fatalf("follower = %q, %v; want the retried reply", res.text, res.err)
+	}
+
+	if len(provider.deadlines) != 2 {
+		t.Fatalf("provider was called %d times, want a call and a retry", len(provider.deadlines))
+	}
+	for i, d := range provider.deadlines {
+		if !d.Equal(followerDeadline) {
+			t.Errorf("call %d saw deadline %v, want the follower's %v", i+1, d, followerDeadline)
+		}
+	}
+	if used := leaderBudget.Used(); used != 1 {
+		t.Errorf("leader's budget used %d calls, want only its own 1", used)
+	}
+}
@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+	"fmt"
+	"strings"
+)
+
+// handicapInstruction tells the coach, who is to move in pos, about the material odds the
+// game started with, so it acknowledges the handicap and teaches to it. Games that did not
+// start from a handicapped standard setup get no instruction.
+func handicapInstruction(initialFen string, pos *chess.Position) string {
+	if initialFen == "" || pos == nil {
+		return ""
+	}
+	start, err := chess.ParseVariantFEN(initialFen, pos.Variant)
+	if err != nil {
+		return ""
+	}
+	missing, ok := start.Handicap()
+	if !ok {
+		return ""
+	}
+	coach, pupil := pos.Turn, pos.Turn.Other()
+
+	var lines []string
+	if len(missing[coach]) > 0 {
+		lines = append(lines, fmt.Sprintf("You gave odds: you started without your %s.", missingPieces(missing[coach])))
+	}
+	if len(missing[pupil]) > 0 {
+		lines = append(lines, fmt.Sprintf("Your pupil gave odds: they started without their %s.", missingPieces(missing[pupil])))
+	}
+	var advice string
+	switch lead := engine.Material(start, pupil) - engine.Material(start, coach); {
+	case lead > 0:
+		advice = "Make up for the missing material with active, enterprising play rather than trading down, and teach your pupil how to convert a material advantage: trade pieces when ahead, keep the position simple and avoid needless risks."
+	case lead < 0:
+		advice = "With extra material on your side, play solidly and show your pupil how to fight back when down material: seek activity, complications and counterplay instead of passive defence."
+	default:
+		advice = "The material is level, so coach as usual, pointing out how the missing pieces change the opening."
+	}
+	return fmt.Sprintf(`
+
+HANDICAP: This is an odds game. %s Acknowledge the handicap naturally early in the game, without repeating it every move. %s`, strings.Join(lines, " "), advice)
+}
+
+// missingPieces names the starting pieces that were left off the board, such as "queen on
+// d1 and knight on b1".
+func missingPieces(squares []chess.Square) string {
+	standard := chess.NewGame()
+	names := make([]string, len(squares))
+	for i, sq := range squares {
+		names[i] = fmt.Sprintf("%s on %s", standard.Board[sq].Type().Name(), sq)
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
@@ -0,0 +1,24 @@
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/persona"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"fmt"
+)
+
+// statePersona returns the persona state asks for. Unknown names, which validated
+// requests never carry, fall back to the default coach.
+func statePersona(state types.GameStateRequest) persona.Persona {
+	p, _ := persona.Lookup(state.Persona)
+	return p
+}
+
+// styleInstruction asks the coach to choose moves in the persona's playing style.
+func styleInstruction(p persona.Persona) string {
+	if p.Style == "" {
+		return ""
+	}
+	return fmt.Sprintf(`
+
+PLAYING STYLE (%s): %s Your move must still be legal.`, p.Title, p.Style)
+}
@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/store"
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// themeHabits describes each mistake motif as a habit the coach can warn about.
+var themeHabits = map[string]string{
+	analysis.MotifHangingPiece: "leaving pieces undefended",
+	analysis.MotifMissedMate:   "missing mates",
+	analysis.MotifAllowedMate:  "overlooking mating threats against their own king",
+	analysis.MotifMissedTactic: "missing tactics such as forks, pins and checks",
+}
+
+// profileListLength caps how many themes and openings the summary lists.
+const profileListLength = 3
+
+// ProfileInstruction summarizes what the coach has learnt about its pupil from their
+// finished games, so the coaching builds on past games instead of starting afresh. It is
+// empty for a pupil without a profile.
+func ProfileInstruction(p *store.Profile) string {
+	if p == nil || p.Games == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n\nPUPIL PROFILE: From %d finished game(s) with your pupil", p.Games)
+	if p.Rating > 0 {
+		fmt.Fprintf(&sb, ", they play at roughly %d Elo", p.Rating)
+	}
+	sb.WriteString(".")
+	var habits []string
+	for _, theme := range mostFrequent(p.Themes) {
+		if habit, ok := themeHabits[theme]; ok {
+			habits = append(habits, fmt.Sprintf("%s (%d)", habit, p.Themes[theme]))
+		}
+	}
+	if len(habits) > 0 {
+		fmt.Fprintf(&sb, " Their recurring mistakes: %s.", strings.Join(habits, "; "))
+	}
+	if phases := mostFrequent(p.Phases); len(phases) > 0 {
+		fmt.Fprintf(&sb, " Most of their mistakes come in the %s.", phases[0])
+	}
+	if names := mostFrequent(p.Openings); len(names) > 0 {
+		fmt.Fprintf(&sb, " Openings they have played: %s.", strings.Join(names, ", "))
+	}
+	sb.WriteString(` Pitch your coaching at their level, and when the position offers a chance to repeat one of their recurring mistakes, warn them about it directly (for example, "you often miss back-rank threats, so check your first rank here").`)
+	return sb.String()
+}
+
+// mostFrequent returns up to profileListLength keys of counts, most frequent first and
+// ties in name order.
+func mostFrequent(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k, n := range counts {
+		if n > 0 {
+			keys = append(keys, k)
+		}
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), strings.Compare(a, b))
+	})
+	return keys[:min(len(keys), profileListLength)]
+}
@@ -0,0 +1,157 @@
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+var ErrUnknownProvider = errors.New("llm: unknown provider")
+
+// CoachProvider is a model backend the coach can run on. ai.Gemini, ai.OpenAI,
+// ai.Anthropic and ai.Ollama implement it, as does an ai.Breaker wrapping one of them.
+type CoachProvider interface {
+	ai.Provider
+	// Name identifies the provider in configuration and in the provider header.
+	Name() string
+}
+
+type providerKey struct{}
+
+// WithProvider returns a context asking for the named provider to be tried first. An empty
+// name keeps the default.
+func WithProvider(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, providerKey{}, name)
+}
+
+// ProviderFrom returns the provider name stored by WithProvider, or "".
+func ProviderFrom(ctx context.Context) string {
+	name, _ := ctx.Value(providerKey{}).(string)
+	return name
+}
+
+// Router is an ai.Provider over several CoachProviders. Each call goes to the provider the
+// context asks for, or else the first one, and fails over to the rest in order when it
+// errors. Request.Model names a model of the first provider, so it is dropped for the
+// others, which use their own configured model.
+type Router struct {
+	providers []CoachProvider
+}
+
+// NewRouter returns a Router trying providers in the given order. Names must be unique.
+func NewRouter(providers ...CoachProvider) (*Router, error) {
+	if len(providers) == 0 {
+		return nil, errors.New("llm: no providers configured")
+	}
+	seen := map[string]bool{}
+	for _, p := range providers {
+		if seen[p.Name()] {
+			return nil, fmt.Errorf("llm: provider %q configured twice", p.Name())
+		}
+		seen[p.Name()] = true
+	}
+	return &Router{providers: providers}, nil
+}
+
+// Names lists the providers in failover order.
+func (r *Router) Names() []string {
+	names := make([]string, len(r.providers))
+	for i, p := range r.providers {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Stats reports the circuit breaker of every provider wrapped in an ai.Breaker.
+func (r *Router) Stats() map[string]ai.BreakerStats {
+	stats := map[string]ai.BreakerStats{}
+	for _, p := range r.providers {
+		if b, ok := p.(interface{ Stats() ai.BreakerStats }); ok {
+			stats[p.Name()] = b.Stats()
+		}
+	}
+	return stats
+}
+
+// Health pings every provider that supports a health check, mapping its name to the
+// result.
+func (r *Router) Health(ctx context.Context) map[string]error {
+	health := map[string]error{}
+	for _, p := range r.providers {
+		if err := ai.Ping(ctx, p); !errors.Is(err, ai.ErrPingUnsupported) {
+			health[p.Name()] = err
+		}
+	}
+	return health
+}
+
+func (r *Router) GenerateJSON(ctx context.Context, req ai.Request) (string, error) {
+	return r.route(ctx, req, func(p CoachProvider, req ai.Request) (string, bool, error) {
+		text, err := p.GenerateJSON(ctx, req)
+		return text, false, err
+	})
+}
+
+// GenerateJSONStream streams from the chosen provider. Once a provider has delivered part
+// of its response the stream can't be restarted elsewhere, so its failure is returned
+// without failing over.
+func (r *Router) GenerateJSONStream(ctx context.Context, req ai.Request, onChunk func(string) error) (string, error) {
+	return r.route(ctx, req, func(p CoachProvider, req ai.Request) (string, bool, error) {
+		delivered := false
+		text, err := ai.GenerateStream(ctx, p, req, func(chunk string) error {
+			delivered = true
+			return onChunk(chunk)
+		})
+		return text, delivered, err
+	})
+}
+
+// route calls providers in order until one succeeds. call reports whether its failure is
+// final. When every provider fails the errors are joined, so errors.Is still finds, say,
+// ai.ErrCircuitOpen.
+func (r *Router) route(ctx context.Context, req ai.Request, call func(CoachProvider, ai.Request) (string, bool, error)) (string, error) {
+	order, err := r.order(ProviderFrom(ctx))
+	if err != nil {
+		return "", err
+	}
+
+	var errs []error
+	for i, p := range order {
+		attempt := req
+		if p != r.providers[0] {
+			attempt.Model = ""
+		}
+		text, final, err := call(p, attempt)
+		if err == nil {
+			if i > 0 {
+				slog.InfoContext(ctx, "Provider answered after failover", "provider", p.Name())
+			}
+			return text, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		if final || ctx.Err() != nil {
+			break
+		}
+		if i < len(order)-1 {
+			slog.WarnContext(ctx, "Provider failed, failing over", "provider", p.Name(), "next", order[i+1].Name(), "err", err)
+		}
+	}
+	return "", errors.Join(errs...)
+}
+
+// order returns the providers to try, starting with the named one when given.
+func (r *Router) order(name string) ([]CoachProvider, error) {
+	if name == "" {
+		return r.providers, nil
+	}
+	for i, p := range r.providers {
+		if strings.EqualFold(p.Name(), name) {
+			order := append([]CoachProvider{p}, r.providers[:i]...)
+			return append(order, r.providers[i+1:]...), nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q (configured: %s)", ErrUnknownProvider, name, strings.Join(r.Names(), ", "))
+}
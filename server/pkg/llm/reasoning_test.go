@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseCoachMoveReasoning(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		analysis string
+	}{
+		{"without analysis", `{"comment": "Solid.", "move": "e5", "arrows": []}`, ""},
+		{"with analysis", `{"comment": "Solid.", "move": "e5", "arrows": [], "analysis": "I weighed c5 against e5; e5 keeps the centre."}`, "I weighed c5 against e5; e5 keeps the centre."},
+		{"empty analysis", `{"comment": "Solid.", "move": "e5", "analysis": ""}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := parseCoachMove(context.Background(), tt.json)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Move != "e5" || resp.Comment != "Solid." || resp.Analysis != tt.analysis {
+				t.Errorf("parsed %+v, want e5 with analysis %q", resp, tt.analysis)
+			}
+		})
+	}
+}
+
+func TestGenerateCoachMoveRequestsReasoning(t *testing.T) {
+	const reasoning = "c5 fights for d4 at once."
+	for _, include := range []bool{false, true} {
+		state := startState()
+		state.IncludeReasoning = include
+		reply := `{"comment": "The Sicilian.", "move": "c5", "arrows": [], "analysis": "` + reasoning + `"}`
+		if !include {
+			reply = coachReply("c5")
+		}
+		provider := &scriptedProvider{responses: []string{reply}}
+		ctx := ai.WithBudget(context.Background(), ai.NewBudget(5))
+
+		resp, err := New(provider).GenerateCoachMove(ctx, state, Options{MaxAttempts: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := provider.requests[0]
+		_, hasProperty := req.Schema.Properties["analysis"]
+		if hasProperty != include || strings.Contains(req.Prompt, "REASONING:") != include {
+			t.Errorf("include_reasoning %v: schema has analysis %v, prompt asks for it %v", include, hasProperty, strings.Contains(req.Prompt, "REASONING:"))
+		}
+		want := ""
+		if include {
+			want = reasoning
+		}
+		if resp.Analysis != want {
+			t.Errorf("include_reasoning %v: analysis = %q, want %q", include, resp.Analysis, want)
+		}
+	}
+
+	// The schema without the field is left as it was.
+	if _, ok := gameStateResponseSchema.Properties["analysis"]; ok {
+		t.Error("the base schema gained the analysis field")
+	}
+}
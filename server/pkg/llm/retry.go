@@ -0,0 +1,46 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rejectedMoves accumulates the moves the server refused while asking the model for a
+// move, so every retry prompt lists all earlier mistakes rather than only the last one.
+type rejectedMoves []rejectedMove
+
+type rejectedMove struct {
+	move   string
+	reason string
+}
+
+// add records a rejected move; a move the model repeats is listed only once.
+func (r *rejectedMoves) add(move, reason string) {
+	for _, rm := range *r {
+		if rm.move == move {
+			return
+		}
+	}
+	*r = append(*r, rejectedMove{move: move, reason: reason})
+}
+
+// instruction renders the rejected moves for the prompt, or "" when there are none.
+func (r rejectedMoves) instruction() string {
+	if len(r) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nREJECTED MOVES: The following moves were rejected. Do NOT use any of them in your response:")
+	for _, rm := range r {
+		fmt.Fprintf(&sb, "\n- %s: %s", rm.move, rm.reason)
+	}
+	return sb.String()
+}
+
+func (r rejectedMoves) String() string {
+	moves := make([]string, len(r))
+	for i, rm := range r {
+		moves[i] = rm.move
+	}
+	return strings.Join(moves, ", ")
+}
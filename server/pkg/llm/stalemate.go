@@ -0,0 +1,40 @@
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/engine"
+)
+
+const (
+	// Stalemate avoidance kicks in once the side to move is at least a rook ahead and the
+	// opponent is down to a handful of moves.
+	stalemateMaterialLead = 500
+	stalemateMaxMobility  = 8
+)
+
+const stalemateWarning = `
+
+IMPORTANT: You are winning by a large margin and your pupil has very few legal moves. Do NOT play a move that leaves them with no legal moves while not in check — that is stalemate and throws away the win. Restrict their king patiently and deliver checkmate.`
+
+// stalemateRisk reports whether the side to move is winning overwhelmingly while the
+// opponent is close to running out of moves.
+func stalemateRisk(pos *chess.Position) bool {
+	lead := engine.Material(pos, pos.Turn) - engine.Material(pos, pos.Turn.Other())
+	if lead < stalemateMaterialLead {
+		return false
+	}
+
+	opponent := *pos
+	opponent.Turn = pos.Turn.Other()
+	opponent.EnPassant = chess.NoSquare
+	return len(opponent.LegalMoves()) <= stalemateMaxMobility
+}
+
+// stalematesOpponent reports whether san is a legal move that stalemates the opponent.
+func stalematesOpponent(pos *chess.Position, san string) bool {
+	m, err := pos.ParseSAN(san)
+	if err != nil {
+		return false
+	}
+	return pos.Play(m).IsStalemate()
+}
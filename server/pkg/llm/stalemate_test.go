@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/ai"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// scriptedProvider answers model calls with its responses in turn, recording every
+// request.
+type scriptedProvider struct {
+	mu        sync.Mutex
+	responses []string
+	requests  []ai.Request
+}
+
+func (p *scriptedProvider) GenerateJSON(ctx context.Context, req ai.Request) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, req)
+	if len(p.responses) == 0 {
+		return "", errors.New("scriptedProvider: out of responses")
+	}
+	resp := p.responses[0]
+	p.responses = p.responses[1:]
+	return resp, nil
+}
+
+func coachReply(move string) string {
+	return `{"comment": "Nice try.", "move": "` + move + `", "arrows": [], "title": "Endgame"}`
+}
+
+// Black's king on a8 is boxed in by the white king; the queen must give mate, not take
+// the last free squares away.
+const nearStalemateFEN = "k7/8/1K6/8/8/8/8/2Q5 w - - 0 1"
+
+func TestStalemateRisk(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		want bool
+	}{
+		{"KQ v K near the edge", nearStalemateFEN, true},
+		{"defender with a knight to move", "8/8/8/3k4/8/1n6/8/Q3K3 w - - 0 1", false},
+		{"losing side to move", "k7/8/1K6/8/8/8/8/2Q5 b - - 0 1", false},
+		{"level material", chess.StartFEN, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := chess.ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stalemateRisk(pos); got != tt.want {
+				t.Errorf("stalemateRisk = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStalematesOpponent(t *testing.T) {
+	pos, err := chess.ParseFEN(nearStalemateFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for san, want := range map[string]bool{"Qc7": true, "Qc8#": false, "Qh1": false, "Qz9": false} {
+		if got := stalematesOpponent(pos, san); got != want {
+			t.Errorf("stalematesOpponent(%s) = %v, want %v", san, got, want)
+		}
+	}
+}
+
+func TestGenerateCoachMoveRegeneratesStalemate(t *testing.T) {
+	provider := &scriptedProvider{responses: []string{coachReply("Qc7"), coachReply("Qc8#")}}
+	ctx := ai.WithBudget(context.Background(), ai.NewBudget(5))
+	state := types.GameStateRequest{Fen: nearStalemateFEN, InitialFen: nearStalemateFEN, MoveHistory: []string{}}
+
+	resp, err := New(provider).GenerateCoachMove(ctx, state, Options{MaxAttempts: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Move != "Qc8#" {
+		t.Errorf("move = %s, want the mate Qc8#", resp.Move)
+	}
+	if len(provider.requests) != 2 {
+		t.Fatalf("made %d model calls, want 2", len(provider.requests))
+	}
+	if !strings.Contains(provider.requests[0].Prompt, stalemateWarning) {
+		t.Error("first prompt lacks the stalemate warning")
+	}
+	if retry := provider.requests[1].Prompt; !strings.Contains(retry, "Qc7") || !strings.Contains(retry, "stalemates") {
+		t.Error("second prompt does not reject the stalemating Qc7")
+	}
+}
+
+func TestGenerateCoachMovePlaysStalemateOnLastAttempt(t *testing.T) {
+	provider := &scriptedProvider{responses: []string{coachReply("Qc7")}}
+	ctx := ai.WithBudget(context.Background(), ai.NewBudget(5))
+	state := types.GameStateRequest{Fen: nearStalemateFEN, InitialFen: nearStalemateFEN, MoveHistory: []string{}}
+
+	resp, err := New(provider).GenerateCoachMove(ctx, state, Options{MaxAttempts: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Move != "Qc7" {
+		t.Errorf("move = %s, want the legal Qc7 once attempts run out", resp.Move)
+	}
+}
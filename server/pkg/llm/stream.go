@@ -0,0 +1,144 @@
+package llm
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldStream follows a JSON object as it streams in and yields the growing value of one
+// top-level string field, so prose can be shown before the object is complete.
+type FieldStream struct {
+	Field string
+
+	buf     strings.Builder
+	emitted string
+}
+
+func NewFieldStream(field string) *FieldStream {
+	return &FieldStream{Field: field}
+}
+
+// Feed appends a chunk of the JSON text and returns the part of the field's value that
+// has become available since the previous call, or "" when there is nothing new.
+func (f *FieldStream) Feed(chunk string) string {
+	f.buf.WriteString(chunk)
+	value, ok := partialStringField(f.buf.String(), f.Field)
+	if !ok || !strings.HasPrefix(value, f.emitted) {
+		return ""
+	}
+	delta := value[len(f.emitted):]
+	f.emitted = value
+	return delta
+}
+
+// partialStringField finds field among the top-level keys of the possibly truncated JSON
+// object in text and decodes as much of its string value as has arrived.
+func partialStringField(text, field string) (string, bool) {
+	depth := 0
+	expectKey := false
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '{', '[':
+			depth++
+			expectKey = c == '{' && depth == 1
+		case '}', ']':
+			depth--
+		case ',':
+			expectKey = depth == 1
+		case '"':
+			key, end, closed := scanString(text, i+1)
+			if !closed {
+				return "", false
+			}
+			if depth == 1 && expectKey {
+				expectKey = false
+				if key == field {
+					j := skipSpace(text, end)
+					if j >= len(text) || text[j] != ':' {
+						return "", false
+					}
+					j = skipSpace(text, j+1)
+					if j >= len(text) || text[j] != '"' {
+						return "", false
+					}
+					value, _, _ := scanString(text, j+1)
+					return value, true
+				}
+			}
+			i = end - 1
+		}
+	}
+	return "", false
+}
+
+// scanString decodes the JSON string whose contents start at text[start]. It returns the
+// index just past the closing quote and whether the string was closed; an unclosed string
+// is decoded up to its last complete character.
+func scanString(text string, start int) (string, int, bool) {
+	i := start
+	for i < len(text) {
+		switch text[i] {
+		case '\\':
+			i += 2
+			continue
+		case '"':
+			var s string
+			if err := json.Unmarshal([]byte(text[start-1:i+1]), &s); err != nil {
+				return "", i + 1, false
+			}
+			return s, i + 1, true
+		}
+		i++
+	}
+
+	partial := text[start:]
+	// Drop an escape sequence cut off by the end of the chunk.
+	for k := 0; k < len(partial); k++ {
+		if partial[k] != '\\' {
+			continue
+		}
+		if !completeEscape(partial[k:]) {
+			partial = partial[:k]
+			break
+		}
+		k++
+	}
+	// Hold back a high surrogate until its pair arrives, and a UTF-8 sequence split
+	// across chunks, so later calls only ever extend the decoded value.
+	if n := len(partial); n >= 6 && partial[n-6] == '\\' && partial[n-5] == 'u' && isHighSurrogate(partial[n-4:]) {
+		partial = partial[:n-6]
+	}
+	for n := 0; n < utf8.UTFMax && !utf8.ValidString(partial); n++ {
+		partial = partial[:len(partial)-1]
+	}
+	var s string
+	if err := json.Unmarshal([]byte(`"`+partial+`"`), &s); err != nil {
+		return "", len(text), false
+	}
+	return s, len(text), false
+}
+
+// completeEscape reports whether the escape sequence starting at s[0] is whole.
+func completeEscape(s string) bool {
+	if len(s) < 2 {
+		return false
+	}
+	if s[1] == 'u' {
+		return len(s) >= 6
+	}
+	return true
+}
+
+func isHighSurrogate(hex string) bool {
+	v, err := strconv.ParseUint(hex, 16, 16)
+	return err == nil && v >= 0xD800 && v <= 0xDBFF
+}
+
+func skipSpace(text string, i int) int {
+	for i < len(text) && strings.IndexByte(" \t\r\n", text[i]) >= 0 {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"arnavsurve/nara-chess/server/pkg/tablebase"
+)
+
+// tablebaseInstruction states the exact result of a tablebase position so the coach's
+// evaluation and advice match it. When choosing is set the coach is also told which moves
+// keep the result.
+func tablebaseInstruction(r *tablebase.Result, choosing bool) string {
+	if r == nil {
+		return ""
+	}
+	outcome := tablebase.Outcome(r.Category)
+	pupil := map[string]string{"win": "loss", "draw": "draw", "loss": "win"}[outcome]
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n\nENDGAME TABLEBASE: This position is in the Syzygy endgame tablebases, so its result is known exactly. With perfect play it is a %s for you and a %s for your pupil.", outcome, pupil)
+	switch r.Category {
+	case tablebase.CursedWin:
+		sb.WriteString(" You could force mate, but not before the fifty-move rule draws the game.")
+	case tablebase.BlessedLoss:
+		sb.WriteString(" Your pupil could force mate, but not before the fifty-move rule draws the game.")
+	}
+	if r.DTZ != nil && outcome != "draw" {
+		dtz := *r.DTZ
+		if dtz < 0 {
+			dtz = -dtz
+		}
+		fmt.Fprintf(&sb, " The winning side needs %d plies to reach the next capture or pawn move with best play.", dtz)
+	}
+	switch best := r.BestMoves(); {
+	case !choosing:
+	case outcome == "loss" && len(r.Moves) > 0:
+		fmt.Fprintf(&sb, " Every move loses; %s resists longest, so play it.", r.Moves[0].SAN)
+	case len(best) > 0:
+		fmt.Fprintf(&sb, " Moves that keep this result: %s. Play one of them.", strings.Join(best, ", "))
+	}
+	sb.WriteString(" Base your evaluation and advice on this result, not on your own assessment.")
+	return sb.String()
+}
+
+// throwsAwayResult reports whether san is legal in the tablebase position but worsens its
+// result for the side to move.
+func throwsAwayResult(r *tablebase.Result, san string) bool {
+	if r == nil || tablebase.Outcome(r.Category) == "loss" {
+		return false
+	}
+	best := r.BestMoves()
+	return len(best) > 0 && !slices.Contains(best, san)
+}
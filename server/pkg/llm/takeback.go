@@ -0,0 +1,17 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// takebackInstruction asks the coach to acknowledge moves the pupil has just taken back,
+// so the comment does not read as if they were never played.
+func takebackInstruction(moves []string) string {
+	if len(moves) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`
+
+TAKEBACK: Your pupil just took back %s and is trying something else. Briefly and kindly acknowledge the takeback in your comment (for example, what the new try does differently). Taking moves back is part of learning; do not scold.`, strings.Join(moves, " "))
+}
@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"fmt"
+)
+
+// statePosition parses state's FEN under the variant it names, or nil when it does not
+// parse. Checked requests never carry a FEN their variant rejects.
+func statePosition(state types.GameStateRequest) *chess.Position {
+	pos, err := chess.ParseFEN(state.Fen)
+	if err != nil {
+		return nil
+	}
+	if v, err := chess.ParseVariant(state.Variant); err == nil && state.Variant != "" {
+		pos.Variant = v
+	}
+	return pos
+}
+
+// variantInstruction explains the rules of a chess variant to the coach, which otherwise
+// plays and comments as if the game were standard chess.
+func variantInstruction(pos *chess.Position) string {
+	if pos == nil {
+		return ""
+	}
+	switch pos.Variant {
+	case chess.KingOfTheHill:
+		return `
+
+VARIANT: This game is King of the Hill. The normal rules apply, but a king that safely reaches one of the centre squares d4, e4, d5 or e5 wins the game at once. Race your own king to the centre when it is safe, stop your pupil's king from getting there, and point out these threats in your comment.`
+	case chess.Crazyhouse:
+		return fmt.Sprintf(`
+
+VARIANT: This game is Crazyhouse. A captured piece joins the capturer's pocket, and instead of moving a piece on the board a player may drop a piece from their pocket onto any empty square (pawns not on the first or last rank). Write a drop as the piece letter, "@" and the square, such as "N@f3" or "P@e6". The pocket is the bracketed list after the board in the FEN, white's pieces in upper case. Pockets now: you hold %s; your pupil holds %s. Drops make attacks on the king come fast, so weigh king safety and material in hand, and mention useful drops in your comment.`,
+			pocketText(pos.Pockets[pos.Turn]), pocketText(pos.Pockets[pos.Turn.Other()]))
+	}
+	return ""
+}
+
+// pocketText lists the pieces in a Crazyhouse pocket in words, such as "2 pawns, 1 knight".
+func pocketText(pocket chess.Pocket) string {
+	text := ""
+	for _, t := range []chess.PieceType{chess.Queen, chess.Rook, chess.Bishop, chess.Knight, chess.Pawn} {
+		n := pocket[t]
+		if n == 0 {
+			continue
+		}
+		if text != "" {
+			text += ", "
+		}
+		name := t.Name()
+		if n > 1 {
+			name += "s"
+		}
+		text += fmt.Sprintf("%d %s", n, name)
+	}
+	if text == "" {
+		return "nothing"
+	}
+	return text
+}
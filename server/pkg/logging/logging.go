@@ -0,0 +1,110 @@
+// Package logging sets up the server's structured logs and gives each request a
+// correlation ID, which tags every line logged while serving it and is returned to the
+// client in the X-Request-ID header.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+)
+
+// Header carries the request ID in both directions.
+const Header = "X-Request-ID"
+
+// Formats Setup writes logs in.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Setup makes slog's default logger, which the log package also writes through, write to
+// w in format. Lines are logged from info level up, or from debug level when debug is
+// set; debug lines include prompts, model answers and other content of the pupils' games.
+func Setup(w io.Writer, format string, debug bool) {
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if debug {
+		opts.Level = slog.LevelDebug
+	}
+	var h slog.Handler = slog.NewTextHandler(w, opts)
+	if format == FormatJSON {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	slog.SetDefault(slog.New(requestHandler{h}))
+	// Lines from dependencies still using the log package come through slog already
+	// timestamped.
+	log.SetFlags(0)
+}
+
+// requestHandler adds the request ID from the context to each record.
+type requestHandler struct {
+	slog.Handler
+}
+
+func (h requestHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestHandler) WithGroup(name string) slog.Handler {
+	return requestHandler{h.Handler.WithGroup(name)}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose log lines are tagged with id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the request ctx belongs to, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Middleware gives each request an ID, kept in its context and set in the response's
+// X-Request-ID header. An ID the caller sent is kept when it looks like one, so a request
+// can be followed through the services in front of the server.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !validID(id) {
+			id = newID()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+// validID accepts up to 64 letters, digits, dashes, underscores and dots, which keeps
+// the logs and headers free of anything a caller might inject.
+func validID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
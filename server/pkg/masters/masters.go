@@ -0,0 +1,89 @@
+// Package masters reads collections of master games in PGN, such as the classics
+// published by PGN Mentor or TWIC, into the library of the guess-the-move trainer.
+package masters
+
+import (
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"strings"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/pgn"
+	"arnavsurve/nara-chess/server/pkg/store"
+)
+
+// Read parses the games in text. Games that do not start from the standard position, are
+// not standard chess, have no moves or contain an illegal move are skipped and counted.
+// A PGN syntax error stops the read and is returned with the games parsed before it.
+func Read(text string) ([]store.MasterGame, int, error) {
+	parsed, err := pgn.Parse(text)
+	games := make([]store.MasterGame, 0, len(parsed))
+	skipped := 0
+	for _, g := range parsed {
+		mg, convErr := convert(g)
+		if convErr != nil {
+			skipped++
+			continue
+		}
+		games = append(games, mg)
+	}
+	return games, skipped, err
+}
+
+// convert replays g from the starting position, normalizing its moves to SAN.
+func convert(g pgn.Game) (store.MasterGame, error) {
+	if g.Tags["FEN"] != "" || g.Tags["SetUp"] == "1" {
+		return store.MasterGame{}, errors.New("game does not start from the standard position")
+	}
+	if v := g.Tags["Variant"]; v != "" && !strings.EqualFold(v, "standard") {
+		return store.MasterGame{}, errors.New("game is not standard chess")
+	}
+	if len(g.Moves) == 0 {
+		return store.MasterGame{}, errors.New("game has no moves")
+	}
+
+	pos := chess.NewGame()
+	moves := make([]string, 0, len(g.Moves))
+	for _, san := range g.Moves {
+		m, err := notation.ParseMove(pos, san)
+		if err != nil {
+			return store.MasterGame{}, err
+		}
+		moves = append(moves, pos.SAN(m))
+		pos = pos.Play(m)
+	}
+
+	mg := store.MasterGame{
+		White:  tag(g, "White"),
+		Black:  tag(g, "Black"),
+		Event:  tag(g, "Event"),
+		Site:   tag(g, "Site"),
+		Date:   tag(g, "Date"),
+		Result: g.Result,
+		Moves:  moves,
+	}
+	mg.ID = gameID(mg)
+	return mg, nil
+}
+
+// tag returns g's tag name, treating PGN's "?" placeholder as unknown.
+func tag(g pgn.Game, name string) string {
+	v := strings.TrimSpace(g.Tags[name])
+	if strings.Trim(v, "?.") == "" {
+		return ""
+	}
+	return v
+}
+
+// gameID derives an ID from the game's players, event, date and moves, so importing the
+// same collection again replaces its games instead of adding copies.
+func gameID(g store.MasterGame) string {
+	f := fnv.New64a()
+	for _, s := range []string{g.White, g.Black, g.Event, g.Date, strings.Join(g.Moves, " ")} {
+		f.Write([]byte(s))
+		f.Write([]byte{0})
+	}
+	return hex.EncodeToString(f.Sum(nil))
+}
@@ -0,0 +1,248 @@
+// Package metrics exports the server's Prometheus metrics: requests and their latency by
+// route, model calls by provider and model and their queueing, the coach's rejected moves and the hit rates
+// of the response caches. The instrumented packages record into it directly; Handler
+// serves everything recorded in the text format Prometheus scrapes.
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registry holds the server's metrics alone, rather than the default registry's, so
+// nothing a dependency registers leaks into them.
+var registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nara",
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route and status code.",
+	}, []string{"route", "code"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "nara",
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to answer HTTP requests, by route.",
+		Buckets:   []float64{0.005, 0.025, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"route"})
+
+	modelCalls = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "nara",
+		Subsystem: "model",
+		Name:      "call_duration_seconds",
+		Help:      "Time taken by model calls, by provider, model and outcome.",
+		Buckets:   prometheus.ExponentialBuckets(0.25, 2, 9),
+	}, []string{"provider", "model", "outcome"})
+	modelFallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nara",
+		Subsystem: "model",
+		Name:      "fallbacks_total",
+		Help:      "Model calls retried with the next model of the chain, by provider and the model that failed.",
+	}, []string{"provider", "model"})
+	modelRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nara",
+		Subsystem: "model",
+		Name:      "retries_total",
+		Help:      "Model calls made again after a transient failure, by provider and model.",
+	}, []string{"provider", "model"})
+	modelQueueTime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "nara",
+		Subsystem: "model",
+		Name:      "queue_duration_seconds",
+		Help:      "Time model calls waited for a free slot of their provider, by provider.",
+		Buckets:   []float64{0, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"provider"})
+	modelShed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nara",
+		Subsystem: "model",
+		Name:      "shed_total",
+		Help:      "Model calls refused because too many were waiting for their provider, by provider.",
+	}, []string{"provider"})
+
+	moves = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nara",
+		Subsystem: "coach",
+		Name:      "moves_total",
+		Help:      "Moves the model suggested, by verdict: accepted, or the reason it was rejected.",
+	}, []string{"verdict"})
+	moveRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nara",
+		Subsystem: "coach",
+		Name:      "move_retries_total",
+		Help:      "Moves asked of the model again after a rejected one, by the reason for the rejection.",
+	}, []string{"reason"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests, requestDuration, modelCalls, modelFallbacks, modelRetries, modelQueueTime, modelShed, moves, moveRetries, caches,
+	)
+}
+
+// Verdicts on the moves the model suggests.
+const (
+	MoveAccepted  = "accepted"
+	MoveIllegal   = "illegal"
+	MoveStalemate = "stalemate"
+	MoveTablebase = "tablebase"
+)
+
+var handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+
+// Handler serves the metrics in Prometheus's text format.
+func Handler() http.Handler {
+	return handler
+}
+
+// Middleware counts and times the requests next serves. route names the endpoint a
+// request reached, such as "POST /v1/chat", so requests for different games or users
+// share a series; it returns "" for requests that match no endpoint.
+func Middleware(route func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := route(r)
+		if name == "" {
+			name = "unmatched"
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(sw, r)
+		requestDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		requests.WithLabelValues(name, strconv.Itoa(sw.status)).Inc()
+	})
+}
+
+// statusWriter remembers the status code of the response written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush and Hijack pass through to the connection: streamed responses and WebSockets look
+// for them on the ResponseWriter itself.
+func (w *statusWriter) Flush() {
+	w.wroteHeader = true
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && !w.wroteHeader {
+		w.status, w.wroteHeader = http.StatusSwitchingProtocols, true
+	}
+	return conn, rw, err
+}
+
+// ModelCall records a call to model of provider that took d and failed with err, if any.
+// An empty model is the provider's default.
+func ModelCall(provider, model string, d time.Duration, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	modelCalls.WithLabelValues(provider, modelLabel(model), outcome).Observe(d.Seconds())
+}
+
+// ModelFallback records that model of provider failed and the next model is being tried.
+func ModelFallback(provider, model string) {
+	modelFallbacks.WithLabelValues(provider, modelLabel(model)).Inc()
+}
+
+// ModelRetry records that a call to model of provider failed transiently and is being
+// made again.
+func ModelRetry(provider, model string) {
+	modelRetries.WithLabelValues(provider, modelLabel(model)).Inc()
+}
+
+// ModelQueued records that a call to provider waited d for a free slot.
+func ModelQueued(provider string, d time.Duration) {
+	modelQueueTime.WithLabelValues(provider).Observe(d.Seconds())
+}
+
+// ModelShed records that a call to provider was refused because too many were waiting.
+func ModelShed(provider string) {
+	modelShed.WithLabelValues(provider).Inc()
+}
+
+func modelLabel(model string) string {
+	if model == "" {
+		return "default"
+	}
+	return model
+}
+
+// Move records the verdict, one of the Move constants, on a move the model suggested.
+func Move(verdict string) {
+	moves.WithLabelValues(verdict).Inc()
+}
+
+// MoveRetry records that the model is asked for another move after one rejected for
+// reason, one of the Move constants.
+func MoveRetry(reason string) {
+	moveRetries.WithLabelValues(reason).Inc()
+}
+
+// CacheStats are a cache's lookups so far, by how they were answered.
+type CacheStats struct {
+	// Hits were answered from memory and Loaded from the cache's backing store.
+	Hits, Loaded, Misses int64
+}
+
+// RegisterCache exports the lookups of the cache called name, which stats reports when
+// the metrics are scraped. Registering a name again replaces its stats.
+func RegisterCache(name string, stats func() CacheStats) {
+	caches.mu.Lock()
+	defer caches.mu.Unlock()
+	caches.stats[name] = stats
+}
+
+var caches = &cacheCollector{stats: make(map[string]func() CacheStats)}
+
+var cacheLookups = prometheus.NewDesc("nara_cache_lookups_total",
+	"Response cache lookups by cache and result: hit in memory, loaded from the backing store, or miss.",
+	[]string{"cache", "result"}, nil)
+
+// cacheCollector reads the registered caches' stats at each scrape.
+type cacheCollector struct {
+	mu    sync.Mutex
+	stats map[string]func() CacheStats
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheLookups
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, stats := range c.stats {
+		s := stats()
+		for result, n := range map[string]int64{"hit": s.Hits, "loaded": s.Loaded, "miss": s.Misses} {
+			ch <- prometheus.MustNewConstMetric(cacheLookups, prometheus.CounterValue, float64(n), name, result)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minGzipSize is the smallest response worth compressing; below it the gzip framing
+// outweighs the savings.
+const minGzipSize = 1024
+
+var gzipWriters = sync.Pool{New: func() any {
+	w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+	return w
+}}
+
+// Gzip compresses responses for clients that accept gzip. Whether a response is
+// compressed is decided when it starts, from its headers: event streams, responses
+// already encoded, those declaring a length too small to gain from it and media that is
+// compressed already go out as they are, as do WebSocket upgrades. A flush sends what has
+// been compressed so far.
+func Gzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		gw := &gzipWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(enc, ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		q, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !found {
+			return true
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(q), 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+// gzipWriter compresses what is written through it once the response turns out to be
+// worth compressing.
+type gzipWriter struct {
+	http.ResponseWriter
+	gz      *gzip.Writer
+	started bool
+}
+
+// start decides, as the response starts, whether to compress it. first is the start of
+// the body, used to sniff its type when the handler didn't set one.
+func (w *gzipWriter) start(status int, first []byte) {
+	if w.started {
+		return
+	}
+	w.started = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(first) > 0 {
+		h.Set("Content-Type", http.DetectContentType(first))
+	}
+	if !compressible(status, h) {
+		return
+	}
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", "gzip")
+	w.gz = gzipWriters.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+}
+
+// compressible reports whether a response with status and headers h is worth compressing.
+func compressible(status int, h http.Header) bool {
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < minGzipSize {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	switch {
+	case mediaType == "text/event-stream":
+		// Events must reach the client as they are sent.
+		return false
+	case strings.HasPrefix(mediaType, "text/"), mediaType == "image/svg+xml",
+		strings.HasSuffix(mediaType, "json"), strings.HasSuffix(mediaType, "xml"),
+		mediaType == "application/javascript", mediaType == "application/x-chess-pgn":
+		return true
+	}
+	return false
+}
+
+func (w *gzipWriter) WriteHeader(status int) {
+	if status >= http.StatusOK || status == http.StatusSwitchingProtocols {
+		w.start(status, nil)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	w.start(http.StatusOK, b)
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// Flush sends the data compressed so far before flushing the connection, so streamed
+// responses keep streaming.
+func (w *gzipWriter) Flush() {
+	w.start(http.StatusOK, nil)
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *gzipWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.gz != nil {
+		return nil, nil, errors.New("middleware: can't hijack a compressed response")
+	}
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.started = true
+	}
+	return conn, rw, err
+}
+
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close finishes the compressed stream, if there is one, and returns its writer to the
+// pool.
+func (w *gzipWriter) close() {
+	if w.gz == nil {
+		return
+	}
+	w.gz.Close()
+	w.gz.Reset(nil)
+	gzipWriters.Put(w.gz)
+	w.gz = nil
+}
@@ -0,0 +1,96 @@
+// Package middleware holds the HTTP middleware main wraps around every request: panic
+// recovery, access logging and response compression.
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/apierror"
+)
+
+// Recover turns a panic in next into a 500 with the usual JSON error, logging it with its
+// stack. A panic after the response has started can't change its status, so the
+// connection is dropped instead, leaving the client with a response it can tell was cut
+// short.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			slog.ErrorContext(r.Context(), "Panic serving request", "method", r.Method, "path", r.URL.Path,
+				"panic", fmt.Sprint(v), "stack", string(debug.Stack()))
+			if rw.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			apierror.Write(w, http.StatusInternalServerError, apierror.Internal, "Internal server error")
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// AccessLog logs a line for each request next serves once it has been answered, with its
+// status, the bytes sent and how long it took. route names the endpoint the request
+// reached, such as "POST /v1/chat", or returns "" when none matched. Query strings are
+// left out since they can carry keys.
+func AccessLog(route func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rw, r)
+		slog.InfoContext(r.Context(), "Request served", "method", r.Method, "path", r.URL.Path,
+			"route", route(r), "status", rw.status, "bytes", rw.written, "duration", time.Since(start))
+	})
+}
+
+// responseWriter remembers the status and size of the response written through it.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	written     int64
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush and Hijack pass through to the connection: streamed responses and WebSockets look
+// for them on the ResponseWriter itself.
+func (w *responseWriter) Flush() {
+	w.wroteHeader = true
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && !w.wroteHeader {
+		w.status, w.wroteHeader = http.StatusSwitchingProtocols, true
+	}
+	return conn, rw, err
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,184 @@
+// Package notation reads moves however pupils and models write them: standard algebraic
+// notation with the usual quirks, long algebraic notation ("Ng1-f3", "e2xe4"), UCI
+// ("g1f3", "e7e8q") or Crazyhouse drops ("N@f3"), and turns them into legal moves and
+// canonical SAN.
+package notation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/utils"
+)
+
+// coordinateMove matches UCI and long algebraic notation once utils.NormalizeSAN has run.
+var coordinateMove = regexp.MustCompile(`^([KQRBN])?([a-h][1-8])[-x:]?([a-h][1-8])=?([NBRQnbrq])?$`)
+
+// dropMove matches a Crazyhouse drop in either case, with or without the pawn's letter.
+var dropMove = regexp.MustCompile(`^([PNBRQpnbrq])?@([a-h][1-8])$`)
+
+// looseSAN matches SAN with optional disambiguation and capture marks, so "Nbd2" where
+// "Nd2" is enough and "Bc4" for "Bxc4" still resolve.
+var looseSAN = regexp.MustCompile(`^([KQRBN])?([a-h])?([1-8])?x?([a-h][1-8])(?:=([NBRQ]))?$`)
+
+// AmbiguousMoveError reports a move that fits more than one legal move.
+type AmbiguousMoveError struct {
+	Move       string
+	Candidates []string // in SAN
+}
+
+func (e *AmbiguousMoveError) Error() string {
+	return fmt.Sprintf("ambiguous move %q: could be %s", e.Move, strings.Join(e.Candidates, " or "))
+}
+
+// ParseMove resolves s to a legal move in pos. It tries exact SAN first, then Crazyhouse
+// drops written in lower case, UCI and long algebraic notation, then SAN with missing or
+// extra disambiguation and capture marks. Check and mate suffixes are never required, and a promotion without a piece is to
+// a queen.
+func ParseMove(pos *chess.Position, s string) (chess.Move, error) {
+	san := utils.NormalizeSAN(s)
+	m, exactErr := pos.ParseSAN(san)
+	if exactErr == nil {
+		return m, nil
+	}
+	bare := strings.TrimRight(san, "+#")
+
+	if parts := dropMove.FindStringSubmatch(bare); parts != nil {
+		if m, err := pos.ParseSAN(strings.ToUpper(parts[1]) + "@" + parts[2]); err == nil {
+			return m, nil
+		}
+		return chess.Move{}, exactErr
+	}
+
+	if parts := coordinateMove.FindStringSubmatch(bare); parts != nil {
+		if m, ok := coordinate(pos, parts); ok {
+			return m, nil
+		}
+	}
+	if parts := looseSAN.FindStringSubmatch(bare); parts != nil {
+		matches := loose(pos, parts)
+		switch len(matches) {
+		case 1:
+			return matches[0], nil
+		case 0:
+		default:
+			err := &AmbiguousMoveError{Move: s}
+			for _, m := range matches {
+				err.Candidates = append(err.Candidates, pos.SAN(m))
+			}
+			return chess.Move{}, err
+		}
+	}
+	return chess.Move{}, exactErr
+}
+
+// Normalize rewrites s as the canonical SAN of the move it names in pos, check or mate
+// suffix included.
+func Normalize(pos *chess.Position, s string) (string, error) {
+	m, err := ParseMove(pos, s)
+	if err != nil {
+		return "", err
+	}
+	return pos.SAN(m), nil
+}
+
+// NormalizeLine normalizes each move of line in turn from start. An unreadable move is
+// reported as a *chess.IllegalMoveError.
+func NormalizeLine(start *chess.Position, line []string) ([]string, error) {
+	out := make([]string, 0, len(line))
+	pos := start
+	for i, s := range line {
+		m, err := ParseMove(pos, s)
+		if err != nil {
+			return out, &chess.IllegalMoveError{Ply: i + 1, Move: s, Err: err}
+		}
+		out = append(out, pos.SAN(m))
+		pos = pos.Play(m)
+	}
+	return out, nil
+}
+
+// WellFormed reports whether s is written like a move in one of the notations ParseMove
+// reads, without checking it against a position. A move that is not well-formed can't be
+// legal anywhere.
+func WellFormed(s string) bool {
+	bare := strings.TrimRight(utils.NormalizeSAN(s), "+#!?")
+	if bare == "O-O" || bare == "O-O-O" {
+		return true
+	}
+	return dropMove.MatchString(bare) || coordinateMove.MatchString(bare) || looseSAN.MatchString(bare)
+}
+
+func coordinate(pos *chess.Position, parts []string) (chess.Move, bool) {
+	from, _ := chess.ParseSquare(parts[2])
+	to, _ := chess.ParseSquare(parts[3])
+	piece := pos.Board[from]
+	if piece == chess.NoPiece || piece.Color() != pos.Turn {
+		return chess.Move{}, false
+	}
+	if parts[1] != "" && piece.Type().Letter() != parts[1] {
+		return chess.Move{}, false
+	}
+	// Some engines castle as "king takes own rook", e1h1.
+	if piece.Type() == chess.King && pos.Board[to] == chess.NewPiece(pos.Turn, chess.Rook) {
+		if to.File() > from.File() {
+			to = chess.NewSquare(6, from.Rank())
+		} else {
+			to = chess.NewSquare(2, from.Rank())
+		}
+	}
+	promotion := chess.NoPieceType
+	if parts[4] != "" {
+		promotion = pieceType(strings.ToUpper(parts[4]))
+	} else if piece.Type() == chess.Pawn && lastRank(to) {
+		promotion = chess.Queen
+	}
+	m := chess.Move{From: from, To: to, Promotion: promotion}
+	return m, pos.IsLegal(m)
+}
+
+func loose(pos *chess.Position, parts []string) []chess.Move {
+	want := chess.Pawn
+	if parts[1] != "" {
+		want = pieceType(parts[1])
+	}
+	to, _ := chess.ParseSquare(parts[4])
+	promotion := chess.NoPieceType
+	if parts[5] != "" {
+		promotion = pieceType(parts[5])
+	} else if want == chess.Pawn && lastRank(to) {
+		promotion = chess.Queen
+	}
+
+	var matches []chess.Move
+	for _, m := range pos.LegalMoves() {
+		if m.To != to || m.Promotion != promotion || pos.Board[m.From].Type() != want {
+			continue
+		}
+		if parts[2] != "" && m.From.String()[0] != parts[2][0] {
+			continue
+		}
+		if parts[3] != "" && m.From.String()[1] != parts[3][0] {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+// lastRank reports whether a pawn reaching sq promotes. A promotion written without a
+// piece is taken to be to a queen.
+func lastRank(sq chess.Square) bool {
+	return sq.Rank() == 0 || sq.Rank() == 7
+}
+
+func pieceType(letter string) chess.PieceType {
+	for _, t := range []chess.PieceType{chess.Knight, chess.Bishop, chess.Rook, chess.Queen, chess.King} {
+		if t.Letter() == letter {
+			return t
+		}
+	}
+	return chess.NoPieceType
+}
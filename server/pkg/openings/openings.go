@@ -0,0 +1,98 @@
+// Package openings names the opening a game is in from a bundled ECO table.
+package openings
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+// ecoTable lists one named line per row as "eco<TAB>name<TAB>pgn", in the layout of the
+// lichess chess-openings data set.
+//
+//go:embed eco.tsv
+var ecoTable string
+
+// Opening is a named line from the ECO table.
+type Opening struct {
+	ECO  string
+	Name string
+	// Moves is the line in SAN from the standard starting position.
+	Moves []string
+}
+
+// byPosition indexes the table by the Zobrist hash of the position each line ends in, so a
+// game that reaches a line by another move order is still recognized.
+var byPosition map[uint64]Opening
+
+func init() {
+	var err error
+	byPosition, err = load(ecoTable)
+	if err != nil {
+		panic(fmt.Sprintf("openings: invalid ECO table: %v", err))
+	}
+}
+
+func load(table string) (map[uint64]Opening, error) {
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	index := make(map[uint64]Opening, len(lines))
+	for i, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: want 3 fields, got %d", i+2, len(fields))
+		}
+		o := Opening{ECO: fields[0], Name: fields[1], Moves: movetext(fields[2])}
+		positions, err := chess.Replay(chess.NewGame(), o.Moves)
+		if err != nil {
+			return nil, fmt.Errorf("line %d (%s %s): %w", i+2, o.ECO, o.Name, err)
+		}
+		key := positionKey(positions[len(positions)-1])
+		if prev, ok := index[key]; ok {
+			return nil, fmt.Errorf("line %d (%s %s): same position as %s %s", i+2, o.ECO, o.Name, prev.ECO, prev.Name)
+		}
+		index[key] = o
+	}
+	return index, nil
+}
+
+// positionKey hashes pos without its en passant square, which every double pawn push sets
+// whether or not a capture is possible and would otherwise keep transpositions apart.
+func positionKey(pos *chess.Position) uint64 {
+	p := *pos
+	p.EnPassant = chess.NoSquare
+	return p.Hash()
+}
+
+// movetext drops the move numbers from "1. e4 e5 2. Nf3".
+func movetext(pgn string) []string {
+	var moves []string
+	for _, tok := range strings.Fields(pgn) {
+		if !strings.HasSuffix(tok, ".") {
+			moves = append(moves, tok)
+		}
+	}
+	return moves
+}
+
+// Classify names the opening of the game played from start through history by the last
+// position along the game that is in the table, so a game keeps its opening's name once
+// it leaves book. An illegal move ends the search. It reports false when no
+// position along the game is in the table.
+func Classify(start *chess.Position, history []string) (Opening, bool) {
+	var found Opening
+	ok := false
+	pos := start
+	for _, san := range history {
+		m, err := pos.ParseSAN(san)
+		if err != nil {
+			break
+		}
+		pos = pos.Play(m)
+		if o, match := byPosition[positionKey(pos)]; match {
+			found, ok = o, true
+		}
+	}
+	return found, ok
+}
@@ -0,0 +1,125 @@
+// Package persona keeps the registry of coach personalities. A persona swaps who the coach
+// says it is and how it talks in every prompt, so a game can be coached by a stern
+// grandmaster or a cheerful club player without the prompts themselves changing.
+package persona
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Persona is one coaching personality. Empty fields keep the prompt's own wording.
+type Persona struct {
+	// Name is the key a game selects the persona by, such as "strict_grandmaster".
+	Name string
+	// Title is the persona as players would describe it, such as "Strict grandmaster".
+	Title string
+	// Role completes "You are ..." at the top of a prompt.
+	Role string
+	// Tone replaces the prompt's instruction on how to speak.
+	Tone string
+	// Style steers the coach's choice of move. Prompts that do not choose a move ignore it.
+	Style string
+}
+
+// Built-in persona names.
+const (
+	StrictGrandmaster = "strict_grandmaster"
+	FriendlyClubCoach = "friendly_club_coach"
+	RomanticAttacker  = "romantic_attacker"
+)
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Persona{}
+)
+
+func init() {
+	for _, p := range []Persona{
+		{
+			Name:  StrictGrandmaster,
+			Title: "Strict grandmaster",
+			Role:  "a demanding grandmaster who coaches serious students",
+			Tone:  "Speak tersely and precisely, like a grandmaster reviewing a student's game. Do not praise routine moves; name inaccuracies plainly and expect the pupil to calculate. Use exact chess terminology.",
+			Style: "Play the most principled, objectively strongest move and punish every inaccuracy.",
+		},
+		{
+			Name:  FriendlyClubCoach,
+			Title: "Friendly club coach",
+			Role:  "a friendly club coach who loves helping improving players",
+			Tone:  "Speak warmly and encouragingly, like a club coach over a casual board. Praise good ideas before pointing out mistakes, keep explanations simple, and avoid jargon unless you explain it.",
+			Style: "Prefer sound, instructive moves that show a clear plan the pupil can learn from.",
+		},
+		{
+			Name:  RomanticAttacker,
+			Title: "Romantic-era attacker",
+			Role:  "a swashbuckling romantic-era attacking player",
+			Tone:  "Speak with the flair of Morphy or Anderssen at the board, enthusing about initiative, open lines and the attack on the king. Celebrate bold sacrifices and urge the pupil to play actively rather than grab material.",
+			Style: "Favour gambits, rapid development and sacrifices that open lines towards the enemy king, as long as the move is not simply losing.",
+		},
+	} {
+		Register(p)
+	}
+}
+
+// Register adds p to the registry, replacing any persona with the same name.
+func Register(p Persona) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[p.Name] = p
+}
+
+// Lookup returns the persona registered as name. The empty name is the default coach: a
+// zero Persona that leaves every prompt as written.
+func Lookup(name string) (Persona, bool) {
+	if name == "" {
+		return Persona{}, true
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names lists the registered personas, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks an optional persona name.
+func Validate(name string) error {
+	if _, ok := Lookup(name); ok {
+		return nil
+	}
+	names := Names()
+	for i, n := range names {
+		names[i] = strconv.Quote(n)
+	}
+	return fmt.Errorf("persona must be one of %s", strings.Join(names, ", "))
+}
+
+// RoleOr returns the persona's role, or fallback when it has none.
+func (p Persona) RoleOr(fallback string) string {
+	if p.Role == "" {
+		return fallback
+	}
+	return p.Role
+}
+
+// ToneOr returns the persona's tone, or fallback when it has none.
+func (p Persona) ToneOr(fallback string) string {
+	if p.Tone == "" {
+		return fallback
+	}
+	return p.Tone
+}
@@ -0,0 +1,60 @@
+package pgn
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// chessComArchive is the subset of a Chess.com monthly games archive that describes the
+// games.
+type chessComArchive struct {
+	Games []struct {
+		URL   string `json:"url"`
+		PGN   string `json:"pgn"`
+		Rules string `json:"rules"`
+		White struct {
+			Result string `json:"result"`
+		} `json:"white"`
+		Black struct {
+			Result string `json:"result"`
+		} `json:"black"`
+	} `json:"games"`
+}
+
+// ParseChessComArchive reads a Chess.com monthly games archive and returns its standard
+// chess games, oldest first, tagged like a Lichess export: Site is the game's URL rather
+// than "Chess.com" and Termination is "Time forfeit" or "Normal".
+func ParseChessComArchive(r io.Reader) ([]Game, error) {
+	var archive chessComArchive
+	if err := json.NewDecoder(r).Decode(&archive); err != nil {
+		return nil, err
+	}
+	var games []Game
+	for i, cg := range archive.Games {
+		if cg.Rules != "chess" {
+			continue
+		}
+		parsed, err := Parse(cg.PGN)
+		if err != nil {
+			return games, fmt.Errorf("game %d: %w", i+1, err)
+		}
+		if len(parsed) != 1 {
+			return games, fmt.Errorf("game %d: PGN holds %d games", i+1, len(parsed))
+		}
+		g := parsed[0]
+		if cg.URL != "" {
+			g.Tags["Site"] = cg.URL
+		}
+		g.Tags["Termination"] = "Normal"
+		if chessComTimeout(cg.White.Result) || chessComTimeout(cg.Black.Result) {
+			g.Tags["Termination"] = "Time forfeit"
+		}
+		games = append(games, g)
+	}
+	return games, nil
+}
+
+func chessComTimeout(result string) bool {
+	return result == "timeout" || result == "timevsinsufficient"
+}
@@ -0,0 +1,87 @@
+package pgn
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// chessComArchiveJSON builds a trimmed Chess.com monthly archive: a game won by mate, a
+// Chess960 game that is skipped and a game lost on time.
+func chessComArchiveJSON(t *testing.T) string {
+	t.Helper()
+	type player struct {
+		Result string `json:"result"`
+	}
+	type game struct {
+		URL   string `json:"url"`
+		PGN   string `json:"pgn"`
+		Rules string `json:"rules"`
+		White player `json:"white"`
+		Black player `json:"black"`
+	}
+	archive := struct {
+		Games []game `json:"games"`
+	}{Games: []game{
+		{
+			URL:   "https://www.chess.com/game/live/1001",
+			PGN:   "[Event \"Live Chess\"]\n[Site \"Chess.com\"]\n[White \"alice\"]\n[Black \"bob\"]\n[Result \"1-0\"]\n\n1. e4 {[%clk 0:02:59.9]} 1... e5 {[%clk 0:02:58]} 2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7# 1-0\n",
+			Rules: "chess",
+			White: player{"win"},
+			Black: player{"checkmated"},
+		},
+		{
+			URL:   "https://www.chess.com/game/live/1002",
+			PGN:   "[Event \"Live Chess 960\"]\n[SetUp \"1\"]\n[FEN \"bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w HFhf - 0 1\"]\n\n1. e4 *\n",
+			Rules: "chess960",
+		},
+		{
+			URL:   "https://www.chess.com/game/live/1003",
+			PGN:   "[Event \"Live Chess\"]\n[White \"bob\"]\n[Black \"alice\"]\n[Result \"0-1\"]\n\n1. d4 {[%clk 0:00:01]} 1... d5 {[%clk 0:02:30]} 0-1\n",
+			Rules: "chess",
+			White: player{"timeout"},
+			Black: player{"win"},
+		},
+	}}
+	data, err := json.Marshal(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseChessComArchive(t *testing.T) {
+	games, err := ParseChessComArchive(strings.NewReader(chessComArchiveJSON(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("parsed %d games, want the 2 standard ones", len(games))
+	}
+
+	g := games[0]
+	if !slices.Equal(g.Moves, strings.Fields("e4 e5 Qh5 Nc6 Bc4 Nf6 Qxf7#")) || g.Result != "1-0" {
+		t.Errorf("first game = %+v", g)
+	}
+	if g.Tags["Site"] != "https://www.chess.com/game/live/1001" || g.Tags["Termination"] != "Normal" {
+		t.Errorf("first game tags = %v", g.Tags)
+	}
+
+	g = games[1]
+	if g.Tags["Termination"] != "Time forfeit" || g.Result != "0-1" {
+		t.Errorf("second game ended %s by %q, want 0-1 on time", g.Result, g.Tags["Termination"])
+	}
+}
+
+func TestParseChessComArchiveRejects(t *testing.T) {
+	for _, data := range []string{
+		`{"games": [`,
+		`{"games": [{"rules": "chess", "pgn": "1. e4 { unterminated"}]}`,
+		`{"games": [{"rules": "chess", "pgn": "1. e4 *\n\n1. d4 *"}]}`,
+	} {
+		if _, err := ParseChessComArchive(strings.NewReader(data)); err == nil {
+			t.Errorf("ParseChessComArchive(%s) succeeded", data)
+		}
+	}
+}
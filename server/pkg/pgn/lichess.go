@@ -0,0 +1,90 @@
+package pgn
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// lichessGame is the subset of a Lichess NDJSON game export that describes the game.
+type lichessGame struct {
+	ID         string `json:"id"`
+	Moves      string `json:"moves"`
+	InitialFen string `json:"initialFen"`
+	Status     string `json:"status"`
+	Winner     string `json:"winner"`
+	Variant    string `json:"variant"`
+	CreatedAt  int64  `json:"createdAt"` // Unix milliseconds
+	Players    struct {
+		White lichessPlayer `json:"white"`
+		Black lichessPlayer `json:"black"`
+	} `json:"players"`
+}
+
+type lichessPlayer struct {
+	User struct {
+		Name string `json:"name"`
+	} `json:"user"`
+}
+
+// ParseLichessNDJSON reads a Lichess NDJSON export, one game object per line, and returns
+// the games with PGN-style tags.
+func ParseLichessNDJSON(r io.Reader) ([]Game, error) {
+	var games []Game
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var lg lichessGame
+		if err := json.Unmarshal([]byte(text), &lg); err != nil {
+			return games, fmt.Errorf("line %d: %w", line, err)
+		}
+		if lg.Variant != "" && lg.Variant != "standard" && lg.Variant != "fromPosition" {
+			return games, fmt.Errorf("line %d: unsupported variant %q", line, lg.Variant)
+		}
+
+		g := Game{Tags: map[string]string{}, Moves: strings.Fields(lg.Moves), Comments: map[int]string{}, Result: lichessResult(lg)}
+		if lg.ID != "" {
+			g.Tags["Site"] = "https://lichess.org/" + lg.ID
+		}
+		if name := lg.Players.White.User.Name; name != "" {
+			g.Tags["White"] = name
+		}
+		if name := lg.Players.Black.User.Name; name != "" {
+			g.Tags["Black"] = name
+		}
+		if lg.InitialFen != "" {
+			g.Tags["SetUp"] = "1"
+			g.Tags["FEN"] = lg.InitialFen
+		}
+		if lg.CreatedAt > 0 {
+			g.Tags["UTCDate"] = time.UnixMilli(lg.CreatedAt).UTC().Format("2006.01.02")
+		}
+		g.Tags["Termination"] = "Normal"
+		if lg.Status == "outoftime" || lg.Status == "timeout" {
+			g.Tags["Termination"] = "Time forfeit"
+		}
+		g.Tags["Result"] = g.Result
+		games = append(games, g)
+	}
+	return games, scanner.Err()
+}
+
+func lichessResult(lg lichessGame) string {
+	switch {
+	case lg.Winner == "white":
+		return "1-0"
+	case lg.Winner == "black":
+		return "0-1"
+	case lg.Status == "created" || lg.Status == "started" || lg.Status == "aborted":
+		return "*"
+	default:
+		return "1/2-1/2"
+	}
+}
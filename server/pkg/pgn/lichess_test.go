@@ -0,0 +1,82 @@
+package pgn
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// lichessNDJSON is trimmed from a Lichess API export: one finished game, one from a set
+// position lost on time and one still in progress.
+const lichessNDJSON = `{"id":"q7ZvsdUF","rated":true,"variant":"standard","speed":"blitz","createdAt":1704067200000,"status":"mate","players":{"white":{"user":{"name":"carol"},"rating":1500},"black":{"user":{"name":"dave"},"rating":1490}},"winner":"black","moves":"f3 e5 g4 Qh4#","clock":{"initial":300,"increment":0}}
+
+{"id":"Xp2mRt9a","variant":"fromPosition","initialFen":"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1","status":"outoftime","players":{"white":{"user":{"name":"carol"}},"black":{"aiLevel":3}},"winner":"white","moves":"e4 Kd7"}
+{"id":"Lw0pQ1zz","variant":"standard","status":"started","players":{"white":{"user":{"name":"erin"}},"black":{"user":{"name":"frank"}}},"moves":"d4"}
+`
+
+func TestParseLichessNDJSON(t *testing.T) {
+	games, err := ParseLichessNDJSON(strings.NewReader(lichessNDJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 3 {
+		t.Fatalf("parsed %d games, want 3", len(games))
+	}
+
+	g := games[0]
+	if !slices.Equal(g.Moves, []string{"f3", "e5", "g4", "Qh4#"}) || g.Result != "0-1" {
+		t.Errorf("first game = %+v", g)
+	}
+	wantTags := map[string]string{
+		"Site":        "https://lichess.org/q7ZvsdUF",
+		"White":       "carol",
+		"Black":       "dave",
+		"UTCDate":     "2024.01.01",
+		"Termination": "Normal",
+		"Result":      "0-1",
+	}
+	for name, want := range wantTags {
+		if g.Tags[name] != want {
+			t.Errorf("%s = %q, want %q", name, g.Tags[name], want)
+		}
+	}
+
+	g = games[1]
+	if g.Tags["FEN"] != "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1" || g.Tags["SetUp"] != "1" {
+		t.Errorf("set-up tags = %v", g.Tags)
+	}
+	if g.Tags["Termination"] != "Time forfeit" || g.Result != "1-0" {
+		t.Errorf("second game ended %s by %q, want 1-0 on time", g.Result, g.Tags["Termination"])
+	}
+	// An anonymous opponent, such as the computer, has no name tag.
+	if _, ok := g.Tags["Black"]; ok {
+		t.Errorf("Black = %q for the computer", g.Tags["Black"])
+	}
+
+	if g := games[2]; g.Result != "*" {
+		t.Errorf("game in progress has result %q, want *", g.Result)
+	}
+}
+
+func TestParseLichessNDJSONRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"malformed line", `{"id":"a","moves":"e4"}` + "\n" + `{"id":`, "line 2"},
+		{"variant", `{"id":"a","variant":"crazyhouse","moves":"e4"}`, `unsupported variant "crazyhouse"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games, err := ParseLichessNDJSON(strings.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseLichessNDJSON = %v, want an error containing %q", err, tt.want)
+			}
+			// Games before the bad line are kept.
+			if tt.name == "malformed line" && len(games) != 1 {
+				t.Errorf("kept %d games, want 1", len(games))
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package pgn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Game is one game read from an export: its tag pairs, the mainline moves in SAN as
+// written, the mainline comments and the result token.
+type Game struct {
+	Tags  map[string]string
+	Moves []string
+	// Comments maps a ply to the comment that follows it; ply 0 holds a comment before
+	// the first move. Several comments after one move are joined with a space.
+	Comments map[int]string
+	Result   string
+}
+
+// Parse splits PGN text into games. Variations, NAGs, move numbers and move annotation
+// glyphs are dropped, leaving only the mainline moves and their comments. Embedded
+// commands such as [%clk] and [%eval] are stripped from comments.
+func Parse(text string) ([]Game, error) {
+	p := &parser{text: text}
+	var games []Game
+	for {
+		g, err := p.game()
+		if err != nil {
+			return games, fmt.Errorf("game %d: %w", len(games)+1, err)
+		}
+		if g == nil {
+			return games, nil
+		}
+		games = append(games, *g)
+	}
+}
+
+type parser struct {
+	text string
+	pos  int
+}
+
+// game reads the next game, returning nil at the end of the input.
+func (p *parser) game() (*Game, error) {
+	g := &Game{Tags: map[string]string{}, Comments: map[int]string{}}
+	inMoves := false
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.text) {
+			break
+		}
+
+		switch c := p.text[p.pos]; {
+		case c == '[':
+			if inMoves {
+				// A tag section after movetext without a result starts the next game.
+				return g, nil
+			}
+			if err := p.tag(g); err != nil {
+				return nil, err
+			}
+		case c == '%' && (p.pos == 0 || p.text[p.pos-1] == '\n'):
+			p.skipLine()
+		case c == ';':
+			p.skipLine()
+		case c == '{':
+			end := strings.IndexByte(p.text[p.pos:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			if text := commentText(p.text[p.pos+1 : p.pos+end]); text != "" {
+				ply := len(g.Moves)
+				if prev := g.Comments[ply]; prev != "" {
+					text = prev + " " + text
+				}
+				g.Comments[ply] = text
+			}
+			p.pos += end + 1
+		case c == '(':
+			if err := p.skipVariation(); err != nil {
+				return nil, err
+			}
+		default:
+			inMoves = true
+			token := p.token()
+			switch {
+			case token == "1-0" || token == "0-1" || token == "1/2-1/2" || token == "*":
+				g.Result = token
+				return g, nil
+			case strings.HasPrefix(token, "$"):
+			default:
+				if san := moveText(token); san != "" {
+					g.Moves = append(g.Moves, san)
+				}
+			}
+		}
+	}
+
+	if !inMoves && len(g.Tags) == 0 {
+		return nil, nil
+	}
+	return g, nil
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.text) && strings.IndexByte(" \t\r\n", p.text[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *parser) skipLine() {
+	if end := strings.IndexByte(p.text[p.pos:], '\n'); end >= 0 {
+		p.pos += end + 1
+	} else {
+		p.pos = len(p.text)
+	}
+}
+
+// token reads up to the next whitespace or PGN delimiter.
+func (p *parser) token() string {
+	start := p.pos
+	for p.pos < len(p.text) && strings.IndexByte(" \t\r\n{}();[", p.text[p.pos]) < 0 {
+		p.pos++
+	}
+	if p.pos == start {
+		p.pos++ // a stray delimiter such as '}' or ')'
+	}
+	return p.text[start:p.pos]
+}
+
+func (p *parser) tag(g *Game) error {
+	end := strings.IndexByte(p.text[p.pos:], '\n')
+	if end < 0 {
+		end = len(p.text) - p.pos
+	}
+	line := strings.TrimSpace(p.text[p.pos : p.pos+end])
+	p.pos += end
+
+	if !strings.HasSuffix(line, "]") {
+		return fmt.Errorf("malformed tag %q", line)
+	}
+	body := strings.TrimSpace(line[1 : len(line)-1])
+	name, value, ok := strings.Cut(body, " ")
+	value = strings.TrimSpace(value)
+	if !ok || len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return fmt.Errorf("malformed tag %q", line)
+	}
+	value = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+	g.Tags[name] = value
+	return nil
+}
+
+func (p *parser) skipVariation() error {
+	depth := 0
+	for p.pos < len(p.text) {
+		switch p.text[p.pos] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return nil
+			}
+		case '{':
+			end := strings.IndexByte(p.text[p.pos:], '}')
+			if end < 0 {
+				return fmt.Errorf("unterminated comment")
+			}
+			p.pos += end
+		}
+		p.pos++
+	}
+	return fmt.Errorf("unterminated variation")
+}
+
+// commentText removes embedded commands such as [%clk 0:03:00] from a comment body and
+// collapses its whitespace.
+func commentText(body string) string {
+	for {
+		start := strings.Index(body, "[%")
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(body[start:], ']')
+		if end < 0 {
+			break
+		}
+		body = body[:start] + " " + body[start+end+1:]
+	}
+	return strings.Join(strings.Fields(body), " ")
+}
+
+// moveText strips a move number prefix ("12.", "12...") and annotation glyphs from a
+// movetext token, returning "" when nothing of the move is left.
+func moveText(token string) string {
+	if i := strings.LastIndexByte(token, '.'); i >= 0 {
+		token = token[i+1:]
+	}
+	return strings.TrimRight(token, "!?")
+}
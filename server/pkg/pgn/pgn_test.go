@@ -0,0 +1,106 @@
+package pgn
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// chessComPGN is trimmed from a Chess.com bulk export: clock commands after every move,
+// black's moves numbered "1...", and two games back to back.
+const chessComPGN = `[Event "Live Chess"]
+[Site "Chess.com"]
+[White "alice"]
+[Black "bob"]
+[Result "1-0"]
+[TimeControl "180"]
+
+1. e4 {[%clk 0:02:59.9]} 1... e5 {[%clk 0:02:58.1]} 2. Qh5 {[%clk 0:02:57]} 2... Nc6 {[%clk 0:02:55.2]} 3. Bc4 {[%clk 0:02:56.3]} 3... Nf6 {[%clk 0:02:50]} 4. Qxf7# {[%clk 0:02:55.8]} 1-0
+
+[Event "Live Chess"]
+[Site "Chess.com"]
+[White "bob"]
+[Black "alice"]
+[Result "1/2-1/2"]
+
+1. d4 {[%clk 0:02:59]} 1... d5 {[%clk 0:02:59]} 1/2-1/2
+`
+
+// lichessPGN is trimmed from a Lichess export with evaluations, NAGs, glyphs and a
+// variation.
+const lichessPGN = `[Event "Rated Blitz game"]
+[Site "https://lichess.org/abcdefgh"]
+[White "carol"]
+[Black "dave \"the rook\""]
+[Result "0-1"]
+
+1. f3 { [%eval 0.0] [%clk 0:05:00] } 1... e5 { [%eval 0.2] } 2. g4?? $4 { Blunder. [%eval #-1] } (2. e4 { [%eval 0.1] } 2... Nc6) 2... Qh4# { Fool's mate. } 0-1
+`
+
+func TestParseChessComPGN(t *testing.T) {
+	games, err := Parse(chessComPGN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("parsed %d games, want 2", len(games))
+	}
+
+	g := games[0]
+	if want := strings.Fields("e4 e5 Qh5 Nc6 Bc4 Nf6 Qxf7#"); !slices.Equal(g.Moves, want) {
+		t.Errorf("moves = %v, want %v", g.Moves, want)
+	}
+	if g.Result != "1-0" || g.Tags["White"] != "alice" || g.Tags["TimeControl"] != "180" {
+		t.Errorf("game = %+v", g)
+	}
+	// Clock-only comments leave nothing behind.
+	if len(g.Comments) != 0 {
+		t.Errorf("comments = %v, want the clocks stripped", g.Comments)
+	}
+
+	if g := games[1]; !slices.Equal(g.Moves, []string{"d4", "d5"}) || g.Result != "1/2-1/2" || g.Tags["White"] != "bob" {
+		t.Errorf("second game = %+v", g)
+	}
+}
+
+func TestParseLichessPGN(t *testing.T) {
+	games, err := Parse(lichessPGN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 {
+		t.Fatalf("parsed %d games, want 1", len(games))
+	}
+	g := games[0]
+
+	// The variation, the NAG and the glyphs are dropped.
+	if want := []string{"f3", "e5", "g4", "Qh4#"}; !slices.Equal(g.Moves, want) {
+		t.Errorf("moves = %v, want %v", g.Moves, want)
+	}
+	if g.Result != "0-1" || g.Tags["Black"] != `dave "the rook"` {
+		t.Errorf("game = %+v", g)
+	}
+	want := map[int]string{3: "Blunder.", 4: "Fool's mate."}
+	if len(g.Comments) != len(want) || g.Comments[3] != want[3] || g.Comments[4] != want[4] {
+		t.Errorf("comments = %q, want %q with the evals stripped", g.Comments, want)
+	}
+}
+
+func TestParseRejectsMalformed(t *testing.T) {
+	for _, text := range []string{
+		"[Event \"x\"\n\n1. e4 *",
+		"1. e4 { unterminated",
+		"1. e4 (1. d4 d5",
+	} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("Parse(%q) succeeded", text)
+		}
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	games, err := Parse("  \n% escaped line\n")
+	if err != nil || len(games) != 0 {
+		t.Errorf("Parse = %v, %v; want no games", games, err)
+	}
+}
@@ -0,0 +1,147 @@
+package pgn
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sevenTagRoster is the order PGN requires for the mandatory tags.
+var sevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+// maxLineLength is the export format's limit on movetext lines.
+const maxLineLength = 80
+
+// Arrow is a board arrow written into a comment as a %cal command.
+type Arrow struct {
+	From, To string
+	// Color is one of the %cal color letters: G(reen), R(ed), Y(ellow) or B(lue).
+	Color byte
+}
+
+// Annotation is the coaching attached to one move.
+type Annotation struct {
+	Comment string
+	Arrows  []Arrow
+}
+
+// Format writes g in PGN export format. The seven tag roster comes first with "?"
+// placeholders for missing tags, then the remaining tags sorted by name. Move numbers
+// follow the FEN tag when one is present. annotations is keyed by ply like g.Comments,
+// which it replaces; arrows are encoded as [%cal] commands so Lichess and ChessBase draw
+// them.
+func Format(g Game, annotations map[int]Annotation) string {
+	var sb strings.Builder
+
+	result := g.Result
+	if result == "" {
+		result = "*"
+	}
+	written := map[string]bool{}
+	for _, name := range sevenTagRoster {
+		value := g.Tags[name]
+		switch {
+		case name == "Result":
+			value = result
+		case value == "":
+			value = "?"
+		}
+		writeTag(&sb, name, value)
+		written[name] = true
+	}
+	var rest []string
+	for name := range g.Tags {
+		if !written[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		writeTag(&sb, name, g.Tags[name])
+	}
+	sb.WriteString("\n")
+
+	black, number := startingMove(g.Tags["FEN"])
+	var tokens []string
+	if a, ok := annotations[0]; ok {
+		tokens = append(tokens, commentToken(a))
+	}
+	needNumber := true
+	for i, san := range g.Moves {
+		switch {
+		case !black:
+			tokens = append(tokens, strconv.Itoa(number)+".")
+		case needNumber:
+			tokens = append(tokens, strconv.Itoa(number)+"...")
+		}
+		tokens = append(tokens, san)
+		needNumber = false
+
+		if a, ok := annotations[i+1]; ok {
+			tokens = append(tokens, commentToken(a))
+			needNumber = true
+		}
+		if black {
+			number++
+		}
+		black = !black
+	}
+	tokens = append(tokens, result)
+
+	line := 0
+	for i, t := range tokens {
+		if i > 0 {
+			if line+1+len(t) > maxLineLength {
+				sb.WriteString("\n")
+				line = 0
+			} else {
+				sb.WriteString(" ")
+				line++
+			}
+		}
+		sb.WriteString(t)
+		line += len(t)
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+func writeTag(sb *strings.Builder, name, value string) {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	fmt.Fprintf(sb, "[%s \"%s\"]\n", name, value)
+}
+
+// startingMove reads the side to move and move number from a FEN, defaulting to White's
+// first move.
+func startingMove(fen string) (black bool, number int) {
+	fields := strings.Fields(fen)
+	number = 1
+	if len(fields) >= 6 {
+		if n, err := strconv.Atoi(fields[5]); err == nil && n > 0 {
+			number = n
+		}
+	}
+	return len(fields) >= 2 && fields[1] == "b", number
+}
+
+// commentToken renders an annotation as a brace comment. A brace would end the comment
+// early, so it is replaced.
+func commentToken(a Annotation) string {
+	var parts []string
+	if len(a.Arrows) > 0 {
+		cal := make([]string, len(a.Arrows))
+		for i, arrow := range a.Arrows {
+			color := arrow.Color
+			if color == 0 {
+				color = 'G'
+			}
+			cal[i] = string(color) + arrow.From + arrow.To
+		}
+		parts = append(parts, "[%cal "+strings.Join(cal, ",")+"]")
+	}
+	if a.Comment != "" {
+		parts = append(parts, strings.NewReplacer("{", "(", "}", ")").Replace(a.Comment))
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
@@ -0,0 +1,140 @@
+// Package puzzledb reads the Lichess puzzle database, the CSV published at
+// https://database.lichess.org/#puzzles (decompressed).
+package puzzledb
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/store"
+)
+
+// Column layout of the CSV: PuzzleId,FEN,Moves,Rating,RatingDeviation,Popularity,NbPlays,
+// Themes,GameUrl,OpeningTags. The header row is optional.
+const (
+	colID = iota
+	colFEN
+	colMoves
+	colRating
+	colRatingDeviation
+	colPopularity
+	colPlays
+	colThemes
+	colGameURL
+	minColumns
+)
+
+// Read parses puzzles from r and calls fn with each one whose popularity is at least
+// minPopularity. Rows that are malformed or whose moves are illegal are skipped and
+// counted. An error from fn stops the read and is returned.
+func Read(r io.Reader, minPopularity int, fn func(store.LibraryPuzzle) error) (skipped int, err error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return skipped, nil
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return skipped, err
+		}
+		if rec[colID] == "PuzzleId" {
+			continue
+		}
+
+		p, err := parseRecord(rec)
+		if err != nil {
+			skipped++
+			continue
+		}
+		if p.Popularity < minPopularity {
+			continue
+		}
+		if err := fn(p); err != nil {
+			return skipped, err
+		}
+	}
+}
+
+func parseRecord(rec []string) (store.LibraryPuzzle, error) {
+	if len(rec) < minColumns {
+		return store.LibraryPuzzle{}, fmt.Errorf("want at least %d columns, got %d", minColumns, len(rec))
+	}
+	p := store.LibraryPuzzle{
+		ID:      rec[colID],
+		Fen:     rec[colFEN],
+		Moves:   strings.Fields(rec[colMoves]),
+		Themes:  strings.Fields(rec[colThemes]),
+		GameURL: rec[colGameURL],
+	}
+	var err error
+	if p.Rating, err = strconv.Atoi(rec[colRating]); err != nil {
+		return p, err
+	}
+	if p.Popularity, err = strconv.Atoi(rec[colPopularity]); err != nil {
+		return p, err
+	}
+	if p.Plays, err = strconv.Atoi(rec[colPlays]); err != nil {
+		return p, err
+	}
+	if len(p.Moves) < 2 {
+		return p, errors.New("puzzle has no solution")
+	}
+	if _, err := Replay(p); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// Line is a library puzzle as played out: the opponent's move that sets it, the position
+// the solver then faces and the solution, all in SAN. The solution alternates the
+// solver's moves with the opponent's replies, starting and ending with the solver's.
+type Line struct {
+	Setup    string
+	Position *chess.Position
+	Solution []string
+}
+
+// Replay plays out p's moves from its FEN.
+func Replay(p store.LibraryPuzzle) (Line, error) {
+	pos, err := chess.ParseFEN(p.Fen)
+	if err != nil {
+		return Line{}, err
+	}
+	var line Line
+	for i, uci := range p.Moves {
+		m, err := parseUCI(pos, uci)
+		if err != nil {
+			return Line{}, err
+		}
+		if i == 0 {
+			line.Setup = pos.SAN(m)
+			pos = pos.Play(m)
+			line.Position = pos
+			continue
+		}
+		line.Solution = append(line.Solution, pos.SAN(m))
+		pos = pos.Play(m)
+	}
+	return line, nil
+}
+
+func parseUCI(pos *chess.Position, uci string) (chess.Move, error) {
+	for _, m := range pos.LegalMoves() {
+		if m.String() == uci {
+			return m, nil
+		}
+	}
+	return chess.Move{}, fmt.Errorf("illegal move %q in %s", uci, pos.FEN())
+}
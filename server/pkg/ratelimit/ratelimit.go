@@ -0,0 +1,288 @@
+// Package ratelimit limits how fast and how much each caller may use the API: a token
+// bucket per caller for the request rate, and a daily quota of model calls. Both are kept
+// in memory, or in Redis when several server instances must share them.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// Status is where a caller stands against a limit after a request.
+type Status struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the whole limit is available again.
+	Reset time.Duration
+	// RetryAfter is how long a refused caller must wait before trying again.
+	RetryAfter time.Duration
+}
+
+// SetHeaders describes s in h as prefix-Limit, prefix-Remaining and prefix-Reset, in
+// whole seconds, and for a refused request Retry-After. The "RateLimit" prefix gives the
+// IETF draft's standard headers.
+func (s Status) SetHeaders(h http.Header, prefix string) {
+	h.Set(prefix+"-Limit", strconv.Itoa(s.Limit))
+	h.Set(prefix+"-Remaining", strconv.Itoa(s.Remaining))
+	h.Set(prefix+"-Reset", seconds(s.Reset))
+	if !s.Allowed {
+		h.Set("Retry-After", seconds(s.RetryAfter))
+	}
+}
+
+// HeaderNames lists the headers SetHeaders sets with prefix, besides Retry-After.
+func HeaderNames(prefix string) []string {
+	return []string{prefix + "-Limit", prefix + "-Remaining", prefix + "-Reset"}
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// Buckets keeps a token bucket per caller, each refilling at the same rate. A nil
+// *Buckets allows everything.
+type Buckets struct {
+	limit rate.Limit
+	burst int
+	// idle is how long a bucket takes to refill completely, after which it is no different
+	// from a new one and can be dropped.
+	idle time.Duration
+	// shared keeps the buckets in Redis instead of buckets when set.
+	shared *sharedBuckets
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+// NewBuckets returns buckets allowing perMinute requests a minute, in bursts of up to as
+// many. A non-positive perMinute returns nil, which disables the limit. With a shared
+// client the buckets are kept in Redis under names starting with prefix, so every server
+// instance using it draws from the same buckets.
+func NewBuckets(perMinute int, shared redis.UniversalClient, prefix string) *Buckets {
+	if perMinute <= 0 {
+		return nil
+	}
+	b := &Buckets{
+		limit:   rate.Limit(float64(perMinute) / 60),
+		burst:   perMinute,
+		idle:    time.Minute,
+		buckets: make(map[string]*bucket),
+		swept:   time.Now(),
+	}
+	if shared != nil {
+		b.shared = &sharedBuckets{client: shared, prefix: prefix}
+	}
+	return b
+}
+
+// Allow takes a token from caller's bucket if it has one.
+func (b *Buckets) Allow(caller string) Status {
+	if b == nil {
+		return Status{Allowed: true}
+	}
+	if b.shared != nil {
+		return b.allowShared(caller)
+	}
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sweep(now)
+	bk, ok := b.buckets[caller]
+	if !ok {
+		bk = &bucket{limiter: rate.NewLimiter(b.limit, b.burst)}
+		b.buckets[caller] = bk
+	}
+	bk.seen = now
+
+	s := Status{Allowed: true, Limit: b.burst}
+	r := bk.limiter.ReserveN(now, 1)
+	if wait := r.DelayFrom(now); wait > 0 {
+		r.CancelAt(now)
+		s.Allowed, s.RetryAfter = false, wait
+	}
+	tokens := bk.limiter.TokensAt(now)
+	s.Remaining = max(int(tokens), 0)
+	s.Reset = time.Duration((float64(b.burst) - tokens) / float64(b.limit) * float64(time.Second))
+	return s
+}
+
+// sweep drops the buckets that have refilled since their last use, at most once per idle
+// period.
+func (b *Buckets) sweep(now time.Time) {
+	if now.Sub(b.swept) < b.idle {
+		return
+	}
+	b.swept = now
+	for caller, bk := range b.buckets {
+		if now.Sub(bk.seen) >= b.idle {
+			delete(b.buckets, caller)
+		}
+	}
+}
+
+// ErrQuotaExhausted is returned for a model call over the caller's daily quota.
+var ErrQuotaExhausted = errors.New("ratelimit: daily model call quota exhausted")
+
+// Quota counts each caller's model calls per UTC day and refuses those over its limit.
+// Counts are kept in memory, so a restart gives everyone a fresh day, unless they are
+// shared through Redis. A nil *Quota allows everything.
+type Quota struct {
+	limit int
+	// shared keeps the counts in Redis instead of used when set.
+	shared *sharedQuota
+
+	mu   sync.Mutex
+	day  string
+	used map[string]int
+}
+
+// NewQuota returns a quota of perDay model calls per caller. A non-positive perDay
+// returns nil, which disables the quota. With a shared client the counts are kept in
+// Redis under names starting with prefix, like NewBuckets.
+func NewQuota(perDay int, shared redis.UniversalClient, prefix string) *Quota {
+	if perDay <= 0 {
+		return nil
+	}
+	q := &Quota{limit: perDay, used: make(map[string]int)}
+	if shared != nil {
+		q.shared = &sharedQuota{client: shared, prefix: prefix}
+	}
+	return q
+}
+
+// Limit is the number of calls each caller may make a day.
+func (q *Quota) Limit() int {
+	if q == nil {
+		return 0
+	}
+	return q.limit
+}
+
+// Status reports caller's quota for today without using any of it. It is refused once
+// nothing remains.
+func (q *Quota) Status(caller string) Status {
+	if q == nil {
+		return Status{Allowed: true}
+	}
+	now := time.Now().UTC()
+	remaining := max(q.limit-q.count(caller, now), 0)
+	untilTomorrow := nextDay(now).Sub(now)
+	s := Status{Allowed: remaining > 0, Limit: q.limit, Remaining: remaining, Reset: untilTomorrow}
+	if !s.Allowed {
+		s.RetryAfter = untilTomorrow
+	}
+	return s
+}
+
+// Charge uses one of caller's calls for today, or returns ErrQuotaExhausted when none
+// remain.
+func (q *Quota) Charge(caller string) error {
+	if q == nil {
+		return nil
+	}
+	if q.shared != nil {
+		return q.chargeShared(caller)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover(time.Now().UTC())
+	if q.used[caller] >= q.limit {
+		return fmt.Errorf("%w (%d calls a day)", ErrQuotaExhausted, q.limit)
+	}
+	q.used[caller]++
+	return nil
+}
+
+// Meter returns a meter charging caller's quota, for ai.WithMeter.
+func (q *Quota) Meter(caller string) *Meter {
+	return &Meter{quota: q, caller: caller}
+}
+
+// Meter charges the model calls of a request to its caller's quota.
+type Meter struct {
+	quota  *Quota
+	caller string
+}
+
+// Charge uses one of the caller's calls.
+func (m *Meter) Charge() error {
+	return m.quota.Charge(m.caller)
+}
+
+// Totals returns how many callers have made model calls today and how many calls they
+// made between them.
+func (q *Quota) Totals() (callers, calls int) {
+	if q == nil {
+		return 0, 0
+	}
+	if q.shared != nil {
+		return q.totalsShared()
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover(time.Now().UTC())
+	for _, n := range q.used {
+		calls += n
+	}
+	return len(q.used), calls
+}
+
+// count returns how many calls caller has made today.
+func (q *Quota) count(caller string, now time.Time) int {
+	if q.shared != nil {
+		return q.countShared(caller, now)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover(now)
+	return q.used[caller]
+}
+
+// rollover starts a new day's counts once the UTC date changes.
+func (q *Quota) rollover(now time.Time) {
+	if day := now.Format(time.DateOnly); day != q.day {
+		q.day = day
+		clear(q.used)
+	}
+}
+
+func nextDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+// ClientIP returns the address of the client that sent r. Behind a reverse proxy, with
+// trustProxy, that is the first address in X-Forwarded-For; otherwise it is the address
+// of the connection, which would be the proxy's.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
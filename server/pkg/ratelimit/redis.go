@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds each Redis command. Limits fail open: a request is let through when
+// Redis can't be asked about it, since a limiter is no reason to take the API down.
+const redisTimeout = time.Second
+
+type sharedBuckets struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// takeToken refills the bucket in KEYS[1] for the time since it was last used and takes a
+// token from it if it has one. ARGV holds the refill rate in tokens per millisecond, the
+// burst, the current time in milliseconds and how long an idle bucket is kept. It returns
+// whether a token was taken and the tokens left, as a string since Lua numbers would be
+// truncated.
+var takeToken = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = tonumber(state[1]) or burst
+local at = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - at) * rate)
+local taken = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	taken = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'at', now)
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {taken, tostring(tokens)}
+`)
+
+// allowShared is Allow for buckets kept in Redis. The instances' clocks stand in for a
+// shared one, so they should be kept in sync.
+func (b *Buckets) allowShared(caller string) Status {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	perSecond := float64(b.limit)
+	res, err := takeToken.Run(ctx, b.shared.client, []string{b.shared.prefix + caller},
+		perSecond/1000, b.burst, time.Now().UnixMilli(), b.idle.Milliseconds()).Slice()
+	var taken int64
+	var tokens float64
+	if err == nil {
+		if len(res) != 2 {
+			err = fmt.Errorf("unexpected reply %v", res)
+		} else {
+			taken, _ = res[0].(int64)
+			left, _ := res[1].(string)
+			tokens, err = strconv.ParseFloat(left, 64)
+		}
+	}
+	if err != nil {
+		slog.Error("Error taking a rate limit token from Redis, letting the request through", "err", err)
+		return Status{Allowed: true, Limit: b.burst, Remaining: b.burst}
+	}
+
+	s := Status{Allowed: taken == 1, Limit: b.burst, Remaining: max(int(tokens), 0)}
+	s.Reset = time.Duration((float64(b.burst) - tokens) / perSecond * float64(time.Second))
+	if !s.Allowed {
+		s.RetryAfter = time.Duration((1 - tokens) / perSecond * float64(time.Second))
+	}
+	return s
+}
+
+// sharedQuota keeps a hash per UTC day mapping callers to their calls, which Redis drops
+// once the day is over.
+type sharedQuota struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+func (s *sharedQuota) key(now time.Time) string {
+	return s.prefix + now.Format(time.DateOnly)
+}
+
+// chargeCall adds a call to ARGV[1]'s count in the day's hash, KEYS[1], unless it has
+// reached the limit, ARGV[2], in which case it returns -1. ARGV[3] is when the day ends,
+// in Unix milliseconds.
+var chargeCall = redis.NewScript(`
+local used = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+if used >= tonumber(ARGV[2]) then
+	return -1
+end
+redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+redis.call('PEXPIREAT', KEYS[1], ARGV[3])
+return used + 1
+`)
+
+func (q *Quota) chargeShared(caller string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	now := time.Now().UTC()
+	used, err := chargeCall.Run(ctx, q.shared.client, []string{q.shared.key(now)},
+		caller, q.limit, nextDay(now).UnixMilli()).Int()
+	if err != nil {
+		slog.Error("Error charging a model call to the quota in Redis, allowing it", "err", err)
+		return nil
+	}
+	if used < 0 {
+		return fmt.Errorf("%w (%d calls a day)", ErrQuotaExhausted, q.limit)
+	}
+	return nil
+}
+
+func (q *Quota) countShared(caller string, now time.Time) int {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	used, err := q.shared.client.HGet(ctx, q.shared.key(now), caller).Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		slog.Error("Error reading the quota from Redis", "err", err)
+	}
+	return used
+}
+
+func (q *Quota) totalsShared() (callers, calls int) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	counts, err := q.shared.client.HVals(ctx, q.shared.key(time.Now().UTC())).Result()
+	if err != nil {
+		slog.Error("Error reading the quota from Redis", "err", err)
+		return 0, 0
+	}
+	for _, c := range counts {
+		n, _ := strconv.Atoi(c)
+		calls += n
+	}
+	return len(counts), calls
+}
@@ -0,0 +1,29 @@
+package render
+
+import (
+	"strings"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+// BoardString draws the board as eight ranks of eight characters, eighth rank first,
+// separated by "/". Pieces use their FEN letters and empty squares are ".", e.g.
+// "rnbqkbnr/pppppppp/......../......../....P.../......../PPPP.PPP/RNBQKBNR".
+func BoardString(pos *chess.Position) string {
+	var sb strings.Builder
+	sb.Grow(71)
+	for rank := 7; rank >= 0; rank-- {
+		for file := 0; file < 8; file++ {
+			piece := pos.Board[chess.NewSquare(file, rank)]
+			if piece == chess.NoPiece {
+				sb.WriteByte('.')
+			} else {
+				sb.WriteByte(piece.FENChar())
+			}
+		}
+		if rank > 0 {
+			sb.WriteByte('/')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,27 @@
+package render
+
+import (
+	"testing"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+func TestBoardString(t *testing.T) {
+	tests := []struct {
+		fen  string
+		want string
+	}{
+		{chess.StartFEN, "rnbqkbnr/pppppppp/......../......../......../......../PPPPPPPP/RNBQKBNR"},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", "rnbqkbnr/pppppppp/......../......../....P.../......../PPPP.PPP/RNBQKBNR"},
+		{"7k/8/8/8/8/8/8/K7 w - - 0 1", ".......k/......../......../......../......../......../......../K......."},
+	}
+	for _, tt := range tests {
+		pos, err := chess.ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := BoardString(pos); got != tt.want {
+			t.Errorf("BoardString(%s) = %s, want %s", tt.fen, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package repertoire turns a pupil's opening lines into the positions drilled by the
+// repertoire trainer.
+package repertoire
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/pgn"
+	"arnavsurve/nara-chess/server/pkg/srs"
+	"arnavsurve/nara-chess/server/pkg/store"
+)
+
+// Build reads each game of a PGN repertoire as one line from the starting position and
+// returns the lines in SAN with a card for every position in them where side is to move.
+// A card accepts every move the lines play in its position. Cards are ordered by where
+// the lines first reach them and are all due at now.
+func Build(games []pgn.Game, side chess.Color, now time.Time) ([][]string, []store.RepertoireCard, error) {
+	lines := make([][]string, 0, len(games))
+	var cards []store.RepertoireCard
+	index := map[string]int{}
+	for i, g := range games {
+		if g.Tags["FEN"] != "" || g.Tags["SetUp"] == "1" {
+			return nil, nil, fmt.Errorf("line %d does not start from the standard position", i+1)
+		}
+		if len(g.Moves) == 0 {
+			return nil, nil, fmt.Errorf("line %d has no moves", i+1)
+		}
+		pos := chess.NewGame()
+		line := make([]string, 0, len(g.Moves))
+		for _, san := range g.Moves {
+			m, err := notation.ParseMove(pos, san)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			played := pos.SAN(m)
+			if pos.Turn == side {
+				key := Key(pos)
+				n, ok := index[key]
+				if !ok {
+					n = len(cards)
+					index[key] = n
+					cards = append(cards, store.RepertoireCard{
+						Key:  key,
+						Fen:  pos.FEN(),
+						Line: slices.Clone(line),
+						Card: srs.New(now),
+					})
+				}
+				if !slices.Contains(cards[n].Moves, played) {
+					cards[n].Moves = append(cards[n].Moves, played)
+				}
+			}
+			line = append(line, played)
+			pos = pos.Play(m)
+		}
+		lines = append(lines, line)
+	}
+	return lines, cards, nil
+}
+
+// Key identifies pos by its placement, side to move, castling rights and en passant
+// square, ignoring the move counters so transposed lines share a card.
+func Key(pos *chess.Position) string {
+	fields := strings.Fields(pos.FEN())
+	return strings.Join(fields[:4], " ")
+}
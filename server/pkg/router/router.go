@@ -0,0 +1,144 @@
+// Package router mounts the API's endpoints under versioned path prefixes, so a later
+// version can change them without breaking the clients of an earlier one.
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"arnavsurve/nara-chess/server/pkg/apierror"
+)
+
+// Router serves the versions of the API mounted on it.
+type Router struct {
+	mux *http.ServeMux
+}
+
+// New returns a Router with no versions mounted.
+func New() *Router {
+	return &Router{mux: http.NewServeMux()}
+}
+
+// ServeHTTP routes r to the endpoint matching its method and path. Requests for unknown
+// paths, or with a method the path doesn't take, get a JSON error like the endpoints'.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if rt.Pattern(r) == "" {
+		w = &muxErrorWriter{ResponseWriter: w}
+	}
+	rt.mux.ServeHTTP(w, r)
+}
+
+// Pattern returns the pattern of the endpoint r is routed to, such as "POST /v1/chat", or
+// "" when no endpoint takes r's method and path.
+func (rt *Router) Pattern(r *http.Request) string {
+	_, pattern := rt.mux.Handler(r)
+	return pattern
+}
+
+// muxErrorWriter replaces the plain-text 404 and 405 responses of http.ServeMux with
+// apierror responses, keeping headers such as Allow. Other responses, such as the mux's
+// redirects, pass through.
+type muxErrorWriter struct {
+	http.ResponseWriter
+	replaced bool
+}
+
+func (w *muxErrorWriter) WriteHeader(status int) {
+	switch status {
+	case http.StatusNotFound:
+		w.replaced = true
+		apierror.Write(w.ResponseWriter, status, apierror.NotFound, "Not found")
+	case http.StatusMethodNotAllowed:
+		w.replaced = true
+		apierror.Write(w.ResponseWriter, status, apierror.MethodNotAllowed, "Method not allowed")
+	default:
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *muxErrorWriter) Write(b []byte) (int, error) {
+	if w.replaced {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Handle registers h for pattern outside any version, for operational endpoints such as
+// the orchestrators' probes, whose paths never change. Like http.ServeMux.Handle, it
+// panics on a malformed or conflicting pattern.
+func (rt *Router) Handle(pattern string, h http.Handler) {
+	rt.mux.Handle(pattern, h)
+}
+
+// HandleFunc registers f for pattern like Handle.
+func (rt *Router) HandleFunc(pattern string, f http.HandlerFunc) {
+	rt.Handle(pattern, f)
+}
+
+// Version mounts a version of the API under /name, such as /v1.
+func (rt *Router) Version(name string) *Version {
+	return &Version{router: rt, prefix: "/" + name}
+}
+
+// Version registers the endpoints of one version of the API.
+type Version struct {
+	router *Router
+	prefix string
+	// unversioned also serves the version's endpoints without its prefix.
+	unversioned bool
+	// middleware wraps each endpoint, outermost first.
+	middleware []func(http.Handler) http.Handler
+}
+
+// Unversioned also serves v's endpoints at their paths without the version prefix, for
+// clients written before the API was versioned. Those responses carry a Deprecation
+// header pointing them at the versioned path. It must be called before the endpoints are
+// registered.
+func (v *Version) Unversioned() *Version {
+	v.unversioned = true
+	return v
+}
+
+// With returns a view of v whose endpoints are registered wrapped in middleware, inside
+// any middleware v already has, for checks such as authentication that only some of the
+// endpoints need.
+func (v *Version) With(middleware func(http.Handler) http.Handler) *Version {
+	c := *v
+	c.middleware = append(slices.Clone(v.middleware), middleware)
+	return &c
+}
+
+// Handle registers h for pattern, a method and a path such as "POST /chat" or
+// "GET /game/{id}", relative to the version's prefix; requests for the path with another
+// method get 405 Method Not Allowed. Like http.ServeMux.Handle, Handle panics on a
+// malformed or conflicting pattern.
+func (v *Version) Handle(pattern string, h http.Handler) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok || !strings.HasPrefix(path, "/") {
+		panic(fmt.Sprintf("router: pattern %q must be a method and a path", pattern))
+	}
+	for _, mw := range slices.Backward(v.middleware) {
+		h = mw(h)
+	}
+	v.router.mux.Handle(method+" "+v.prefix+path, h)
+	if v.unversioned {
+		v.router.mux.Handle(pattern, deprecated(v.prefix, h))
+	}
+}
+
+// HandleFunc registers f for pattern like Handle.
+func (v *Version) HandleFunc(pattern string, f http.HandlerFunc) {
+	v.Handle(pattern, f)
+}
+
+// deprecated marks the responses of h, reached without the version prefix, as deprecated
+// and links them to the versioned path.
+func deprecated(prefix string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", prefix, r.URL.Path))
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,108 @@
+// Package schema derives JSON Schema documents from the API's Go types so clients always
+// see the contract the server actually decodes and encodes.
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// ForResponse returns the JSON Schema for a response type. Struct fields follow
+// encoding/json naming, and every field without omitempty is required since the server
+// always encodes it.
+func ForResponse(v any) map[string]any {
+	return forType(reflect.TypeOf(v), true)
+}
+
+// ForRequest returns the JSON Schema for a request type. Requests decode with unknown
+// fields disallowed but every known field optional; which fields a request actually needs
+// is enforced by its Validate method, so no fields are marked required.
+func ForRequest(v any) map[string]any {
+	return forType(reflect.TypeOf(v), false)
+}
+
+func forType(t reflect.Type, response bool) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return forType(t.Elem(), response)
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": forType(t.Elem(), response)}
+	case reflect.Array:
+		return map[string]any{
+			"type":     "array",
+			"items":    forType(t.Elem(), response),
+			"minItems": t.Len(),
+			"maxItems": t.Len(),
+		}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": forType(t.Elem(), response)}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		return forStruct(t, response)
+	}
+	return map[string]any{}
+}
+
+func forStruct(t reflect.Type, response bool) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for _, f := range Fields(t) {
+		properties[f.Name] = forType(f.Type, response)
+		if response && !f.OmitEmpty {
+			required = append(required, f.Name)
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+type Field struct {
+	Name      string
+	Type      reflect.Type
+	OmitEmpty bool
+}
+
+// Fields lists the JSON-visible fields of a struct type in declaration order.
+func Fields(t reflect.Type) []Field {
+	var fields []Field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, Field{
+			Name:      name,
+			Type:      sf.Type,
+			OmitEmpty: strings.Contains(opts, "omitempty"),
+		})
+	}
+	return fields
+}
@@ -0,0 +1,93 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"arnavsurve/nara-chess/server/pkg/types"
+)
+
+// checkFields reports every exported field of typ, and of the structs it contains, that
+// has no property in s.
+func checkFields(t *testing.T, path string, typ reflect.Type, s map[string]any) {
+	t.Helper()
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+			s, _ = s["items"].(map[string]any)
+		} else if typ.Kind() == reflect.Map {
+			s, _ = s["additionalProperties"].(map[string]any)
+		}
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		return
+	}
+
+	properties, _ := s["properties"].(map[string]any)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		property, ok := properties[name].(map[string]any)
+		if !ok {
+			t.Errorf("%s.%s has no %q property", path, f.Name, name)
+			continue
+		}
+		checkFields(t, path+"."+f.Name, f.Type, property)
+	}
+}
+
+func TestSchemaCoversExportedFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		v      any
+		schema map[string]any
+	}{
+		{"GameStateRequest", types.GameStateRequest{}, ForRequest(types.GameStateRequest{})},
+		{"GameStateResponse", types.GameStateResponse{}, ForResponse(types.GameStateResponse{})},
+		{"ChatMessageRequest", types.ChatMessageRequest{}, ForRequest(types.ChatMessageRequest{})},
+		{"ChatMessageResponse", types.ChatMessageResponse{}, ForResponse(types.ChatMessageResponse{})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.schema["type"] != "object" {
+				t.Fatalf("schema type = %v, want object", tt.schema["type"])
+			}
+			checkFields(t, tt.name, reflect.TypeOf(tt.v), tt.schema)
+		})
+	}
+}
+
+func TestSchemaRequired(t *testing.T) {
+	type reply struct {
+		Move    string   `json:"move"`
+		Comment string   `json:"comment,omitempty"`
+		Hidden  string   `json:"-"`
+		Scores  [2]int   `json:"scores"`
+		Notes   []string `json:"notes,omitempty"`
+		private string
+	}
+	_ = reply{}.private
+
+	response := ForResponse(reply{})
+	if got := response["required"]; !reflect.DeepEqual(got, []string{"move", "scores"}) {
+		t.Errorf("response required = %v, want [move scores]", got)
+	}
+	properties := response["properties"].(map[string]any)
+	if len(properties) != 4 {
+		t.Errorf("properties = %v, want the four JSON fields", properties)
+	}
+	if scores := properties["scores"].(map[string]any); scores["minItems"] != 2 || scores["maxItems"] != 2 {
+		t.Errorf("scores = %v, want exactly two items", scores)
+	}
+
+	if got := ForRequest(reply{})["required"].([]string); len(got) != 0 {
+		t.Errorf("request required = %v, want none", got)
+	}
+}
@@ -0,0 +1,213 @@
+package session
+
+import (
+	"arnavsurve/nara-chess/server/pkg/store"
+	"arnavsurve/nara-chess/server/pkg/types"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Message types sent by the client over /ws/game.
+const (
+	// TypeJoin opens or resumes a game. An empty GameID starts a new game from Fen, or
+	// from the standard position when Fen is empty.
+	TypeJoin = "join"
+	// TypeMove plays the pupil's Move.
+	TypeMove = "move"
+	// TypeTakeback takes back Plies moves. Zero takes back the pupil's last move and, when
+	// the coach has answered it, the coach's reply.
+	TypeTakeback = "takeback"
+)
+
+// Message types sent by the server over /ws/game.
+const (
+	// TypeState carries the authoritative game after a join, the pupil's move or a
+	// takeback.
+	TypeState = "state"
+	// TypeCoachMove carries the game after the coach's move together with its commentary.
+	TypeCoachMove = "coach_move"
+	TypeError     = "error"
+)
+
+type ClientMessage struct {
+	Type       string `json:"type"`
+	GameID     string `json:"game_id,omitempty"`
+	Fen        string `json:"fen,omitempty"`
+	PlayerSide string `json:"player_side,omitempty"`
+	Move       string `json:"move,omitempty"`
+	Plies      int    `json:"plies,omitempty"`
+	// Variant names the rules a game created by a join message is played under, Odds
+	// the material the coach gives up in it (see chess.Odds) and Persona the coach's
+	// personality (see the persona package).
+	Variant string `json:"variant,omitempty"`
+	Odds    string `json:"odds,omitempty"`
+	Persona string `json:"persona,omitempty"`
+	// UserID names the pupil whose profile a game created by a join message feeds.
+	UserID string `json:"user_id,omitempty"`
+}
+
+type ServerMessage struct {
+	Type  string                   `json:"type"`
+	Game  *store.Game              `json:"game,omitempty"`
+	Reply *types.GameStateResponse `json:"reply,omitempty"`
+	// Status is set once the game is over.
+	Status *types.GameStatus `json:"status,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// Session is one client connection playing a game.
+type Session struct {
+	GameID     string
+	PlayerSide string
+
+	mu   sync.Mutex
+	send func(ServerMessage) error
+}
+
+// Send delivers msg to the client. Sends are serialized since connections allow only one
+// writer at a time.
+func (s *Session) Send(msg ServerMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.send(msg)
+}
+
+// Manager tracks the sessions attached to each game so every connection watching a game
+// sees the same state.
+type Manager struct {
+	mu       sync.Mutex
+	sessions map[string]map[*Session]struct{}
+	// shared carries broadcasts to the sessions other instances hold once Share is called.
+	shared redis.UniversalClient
+}
+
+// sharedChannel is the Redis channel broadcasts travel between instances on.
+const sharedChannel = "nara:games"
+
+// sharedTimeout bounds publishing a broadcast to Redis.
+const sharedTimeout = time.Second
+
+type sharedBroadcast struct {
+	GameID  string        `json:"game_id"`
+	Message ServerMessage `json:"message"`
+}
+
+func NewManager() *Manager {
+	return &Manager{sessions: make(map[string]map[*Session]struct{})}
+}
+
+// Join attaches a new session to gameID. send writes one message to the client.
+func (m *Manager) Join(gameID, playerSide string, send func(ServerMessage) error) *Session {
+	s := &Session{GameID: gameID, PlayerSide: playerSide, send: send}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sessions[gameID] == nil {
+		m.sessions[gameID] = make(map[*Session]struct{})
+	}
+	m.sessions[gameID][s] = struct{}{}
+	return s
+}
+
+// Count returns how many games have sessions attached on this instance, and how many
+// sessions there are.
+func (m *Manager) Count() (games, sessions int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, attached := range m.sessions {
+		sessions += len(attached)
+	}
+	return len(m.sessions), sessions
+}
+
+// Leave detaches s from its game.
+func (m *Manager) Leave(s *Session) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions[s.GameID], s)
+	if len(m.sessions[s.GameID]) == 0 {
+		delete(m.sessions, s.GameID)
+	}
+}
+
+// Share has broadcasts reach the sessions attached to the same game on every instance
+// sharing client, until ctx is done. Each instance delivers the broadcasts it receives
+// from Redis, its own included.
+func (m *Manager) Share(ctx context.Context, client redis.UniversalClient) error {
+	sub := client.Subscribe(ctx, sharedChannel)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return err
+	}
+	m.mu.Lock()
+	m.shared = client
+	m.mu.Unlock()
+
+	go func() {
+		defer sub.Close()
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				m.mu.Lock()
+				m.shared = nil
+				m.mu.Unlock()
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				var b sharedBroadcast
+				if err := json.Unmarshal([]byte(message.Payload), &b); err != nil {
+					slog.Error("Error decoding a broadcast from Redis", "err", err)
+					continue
+				}
+				m.deliver(b.GameID, b.Message)
+			}
+		}
+	}()
+	return nil
+}
+
+// Broadcast sends msg to every session attached to gameID. Failed sends are logged; the
+// connection's own read loop notices the broken connection and leaves. A broadcast that
+// can't be shared through Redis still reaches this instance's sessions.
+func (m *Manager) Broadcast(gameID string, msg ServerMessage) {
+	m.mu.Lock()
+	shared := m.shared
+	m.mu.Unlock()
+	if shared != nil {
+		payload, err := json.Marshal(sharedBroadcast{GameID: gameID, Message: msg})
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), sharedTimeout)
+			err = shared.Publish(ctx, sharedChannel, payload).Err()
+			cancel()
+		}
+		if err == nil {
+			return
+		}
+		slog.Error("Error sharing a broadcast through Redis", "type", msg.Type, "game_id", gameID, "err", err)
+	}
+	m.deliver(gameID, msg)
+}
+
+// deliver sends msg to the sessions this instance holds on gameID.
+func (m *Manager) deliver(gameID string, msg ServerMessage) {
+	m.mu.Lock()
+	sessions := make([]*Session, 0, len(m.sessions[gameID]))
+	for s := range m.sessions[gameID] {
+		sessions = append(sessions, s)
+	}
+	m.mu.Unlock()
+
+	for _, s := range sessions {
+		if err := s.Send(msg); err != nil {
+			slog.Warn("Error sending to a session", "type", msg.Type, "game_id", gameID, "err", err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Package srs schedules reviews with the SM-2 spaced-repetition algorithm, graded simply
+// as pass or fail.
+package srs
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	InitialEase = 2.5
+	MinEase     = 1.3
+	// RetryDelay is how soon a failed card comes back, so a mistake is drilled again in the
+	// same session.
+	RetryDelay = 10 * time.Minute
+	// failPenalty is the drop in ease after a failed review.
+	failPenalty = 0.2
+)
+
+// Card is the review state of one item.
+type Card struct {
+	// Repetitions counts the passes since the last failure.
+	Repetitions  int       `json:"repetitions"`
+	IntervalDays int       `json:"interval_days"`
+	Ease         float64   `json:"ease"`
+	DueAt        time.Time `json:"due_at"`
+}
+
+// New returns a card due immediately.
+func New(now time.Time) Card {
+	return Card{Ease: InitialEase, DueAt: now}
+}
+
+// Review returns the card's state after a review at now. A pass schedules the next review
+// after one day, then six, then the previous interval times the ease; a failure resets
+// the repetitions, lowers the ease and brings the card back after RetryDelay.
+func (c Card) Review(correct bool, now time.Time) Card {
+	if !correct {
+		c.Repetitions = 0
+		c.IntervalDays = 0
+		c.Ease = math.Max(MinEase, c.Ease-failPenalty)
+		c.DueAt = now.Add(RetryDelay)
+		return c
+	}
+
+	c.Repetitions++
+	switch c.Repetitions {
+	case 1:
+		c.IntervalDays = 1
+	case 2:
+		c.IntervalDays = 6
+	default:
+		c.IntervalDays = int(math.Round(float64(c.IntervalDays) * c.Ease))
+	}
+	c.DueAt = now.AddDate(0, 0, c.IntervalDays)
+	return c
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+var ErrAPIKeyNotFound = errors.New("store: API key not found")
+
+// APIKey is a key clients authenticate with. Only a hash of the key is kept; the key
+// itself is shown once, when it is created.
+type APIKey struct {
+	ID   string `json:"key_id"`
+	Name string `json:"name"`
+	// Hash identifies the key; see auth.HashKey.
+	Hash string `json:"-"`
+	// Prefix is the start of the key, so its owner can tell which key this is.
+	Prefix    string    `json:"prefix"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (k *APIKey) clone() *APIKey {
+	c := *k
+	c.Scopes = slices.Clone(k.Scopes)
+	return &c
+}
+
+// APIKeyStore keeps the API keys issued to clients.
+type APIKeyStore interface {
+	// AddAPIKey stores k under a new ID, which it sets.
+	AddAPIKey(k *APIKey) error
+	// APIKeyByHash returns the key whose hash is hash.
+	APIKeyByHash(hash string) (*APIKey, error)
+	// ListAPIKeys returns every key, oldest first.
+	ListAPIKeys() ([]*APIKey, error)
+	DeleteAPIKey(id string) error
+}
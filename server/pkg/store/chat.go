@@ -0,0 +1,29 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrChatSummaryNotFound = errors.New("store: chat summary not found")
+
+// ChatSummary condenses the older turns of a chat session, which take its place in the
+// coach's prompt once the whole conversation no longer fits.
+type ChatSummary struct {
+	SessionID string `json:"session_id"`
+	// Covered is how many of the session's first messages the summary stands for, and
+	// Digest identifies them, so a client that rewrites its history gets a fresh summary.
+	Covered   int       `json:"covered"`
+	Digest    string    `json:"digest"`
+	Summary   string    `json:"summary"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ChatSummaryStore keeps the latest summary of each chat session.
+type ChatSummaryStore interface {
+	// GetChatSummary returns sessionID's summary, or ErrChatSummaryNotFound before its
+	// conversation has needed one.
+	GetChatSummary(sessionID string) (*ChatSummary, error)
+	// PutChatSummary stores s as its session's summary, replacing any earlier one.
+	PutChatSummary(s *ChatSummary) error
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/analysis"
+)
+
+var ErrJobNotFound = errors.New("store: analysis job not found")
+
+// Analysis job statuses. A job is queued until a worker claims it, running while its
+// moves are scored, and then done or failed.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Delivery statuses of an analysis job's callback.
+const (
+	CallbackPending   = "pending"
+	CallbackDelivered = "delivered"
+	CallbackFailed    = "failed"
+)
+
+// AnalysisJob is a full-game analysis run in the background, too long for one request.
+type AnalysisJob struct {
+	ID     string `json:"job_id"`
+	Status string `json:"status"`
+	// GameID names the stored game analysed, if the job was made from one.
+	GameID      string   `json:"game_id,omitempty"`
+	InitialFen  string   `json:"initial_fen"`
+	MoveHistory []string `json:"move_history"`
+	Depth       int      `json:"depth"`
+	// Moves holds the moves scored so far, in ply order. A job resumed after a restart
+	// carries on from the first move missing.
+	Moves []analysis.MoveAnalysis `json:"moves"`
+	Error string                  `json:"error,omitempty"`
+	// CallbackURL receives the job's report once it is done or failed; CallbackStatus
+	// follows the delivery from pending to delivered or failed.
+	CallbackURL    string    `json:"callback_url,omitempty"`
+	CallbackStatus string    `json:"callback_status,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (j *AnalysisJob) clone() *AnalysisJob {
+	c := *j
+	c.MoveHistory = slices.Clone(j.MoveHistory)
+	c.Moves = slices.Clone(j.Moves)
+	return &c
+}
+
+// AnalysisJobStore keeps analysis jobs, and is the queue the workers take them from.
+type AnalysisJobStore interface {
+	// AddJob stores j under a new ID, which it sets.
+	AddJob(j *AnalysisJob) error
+	GetJob(id string) (*AnalysisJob, error)
+	// ClaimJob marks the oldest queued job running and returns it, or ErrJobNotFound when
+	// none is queued.
+	ClaimJob() (*AnalysisJob, error)
+	// UpdateJob applies fn to a copy of the job and stores the result atomically. fn's
+	// error aborts the update and is returned unchanged.
+	UpdateJob(id string, fn func(*AnalysisJob) error) (*AnalysisJob, error)
+	// RequeueJobs returns the jobs left running by a previous process to the queue and
+	// reports how many there were.
+	RequeueJobs() (int, error)
+	// CountJobs returns how many jobs there are of each status that has any.
+	CountJobs() (map[string]int, error)
+}
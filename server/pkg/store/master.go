@@ -0,0 +1,28 @@
+package store
+
+import "errors"
+
+var ErrMasterGameNotFound = errors.New("store: master game not found")
+
+// MasterGame is a famous game imported for the guess-the-move trainer. It starts from the
+// standard position, and Moves is its mainline in SAN.
+type MasterGame struct {
+	ID     string   `json:"game_id"`
+	White  string   `json:"white"`
+	Black  string   `json:"black"`
+	Event  string   `json:"event"`
+	Site   string   `json:"site"`
+	Date   string   `json:"date"`
+	Result string   `json:"result"`
+	Moves  []string `json:"moves"`
+}
+
+// MasterGameLibrary holds imported master games.
+type MasterGameLibrary interface {
+	// AddMasterGames stores gs, replacing games with the same IDs.
+	AddMasterGames(gs []MasterGame) error
+	CountMasterGames() (int, error)
+	// MasterGameAt returns the game at index in ID order.
+	MasterGameAt(index int) (*MasterGame, error)
+	GetMasterGame(id string) (*MasterGame, error)
+}
@@ -0,0 +1,544 @@
+package store
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	games   map[string]*Game
+	puzzles map[string]*Puzzle
+	// profiles is keyed by user ID.
+	profiles    map[string]*Profile
+	repertoires map[string]*Repertoire
+	jobs        map[string]*AnalysisJob
+	apiKeys     map[string]*APIKey
+	users       map[string]*User
+	usage       []UsageRecord
+	// chats is keyed by session ID.
+	chats map[string]*ChatSummary
+	// library holds the imported library puzzles sorted by ID.
+	library []LibraryPuzzle
+	// masters holds the imported master games sorted by ID.
+	masters []MasterGame
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		games:       make(map[string]*Game),
+		puzzles:     make(map[string]*Puzzle),
+		profiles:    make(map[string]*Profile),
+		repertoires: make(map[string]*Repertoire),
+		jobs:        make(map[string]*AnalysisJob),
+		apiKeys:     make(map[string]*APIKey),
+		users:       make(map[string]*User),
+		chats:       make(map[string]*ChatSummary),
+	}
+}
+
+func (s *MemoryStore) Create(setup Game) (*Game, error) {
+	now := time.Now().UTC()
+	g := &Game{
+		ID:          NewID(),
+		InitialFen:  setup.InitialFen,
+		Variant:     setup.Variant,
+		Persona:     setup.Persona,
+		UserID:      setup.UserID,
+		Source:      setup.Source,
+		PupilSide:   setup.PupilSide,
+		Fen:         setup.InitialFen,
+		MoveHistory: []string{},
+		Comments:    []MoveComment{},
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.games[g.ID] = g
+	return g.clone(), nil
+}
+
+func (s *MemoryStore) Get(id string) (*Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.games[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return g.clone(), nil
+}
+
+func (s *MemoryStore) List() ([]*Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	games := make([]*Game, 0, len(s.games))
+	for _, g := range s.games {
+		games = append(games, g.clone())
+	}
+	sort.Slice(games, func(i, j int) bool {
+		if !games[i].UpdatedAt.Equal(games[j].UpdatedAt) {
+			return games[i].UpdatedAt.After(games[j].UpdatedAt)
+		}
+		return games[i].ID < games[j].ID
+	})
+	return games, nil
+}
+
+func (s *MemoryStore) Update(id string, expectedVersion int, fn func(*Game) error) (*Game, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.games[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if current.Version != expectedVersion {
+		return nil, ErrVersionConflict
+	}
+
+	next := current.clone()
+	if err := fn(next); err != nil {
+		return nil, err
+	}
+	next.Version = current.Version + 1
+	next.UpdatedAt = time.Now().UTC()
+	s.games[id] = next
+	return next.clone(), nil
+}
+
+func (s *MemoryStore) AddPuzzle(p *Puzzle) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.puzzles {
+		if existing.GameID == p.GameID && existing.Ply == p.Ply {
+			return false, nil
+		}
+	}
+	p.ID = NewID()
+	c := *p
+	s.puzzles[p.ID] = &c
+	return true, nil
+}
+
+func (s *MemoryStore) GetPuzzle(id string) (*Puzzle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.puzzles[id]
+	if !ok {
+		return nil, ErrPuzzleNotFound
+	}
+	c := *p
+	return &c, nil
+}
+
+func (s *MemoryStore) NextPuzzle(userID string) (*Puzzle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next *Puzzle
+	for _, p := range s.puzzles {
+		if p.UserID != userID {
+			continue
+		}
+		if next == nil || p.Card.DueAt.Before(next.Card.DueAt) || (p.Card.DueAt.Equal(next.Card.DueAt) && p.ID < next.ID) {
+			next = p
+		}
+	}
+	if next == nil {
+		return nil, ErrPuzzleNotFound
+	}
+	c := *next
+	return &c, nil
+}
+
+func (s *MemoryStore) UpdatePuzzle(id string, fn func(*Puzzle) error) (*Puzzle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.puzzles[id]
+	if !ok {
+		return nil, ErrPuzzleNotFound
+	}
+	next := *current
+	if err := fn(&next); err != nil {
+		return nil, err
+	}
+	s.puzzles[id] = &next
+	c := next
+	return &c, nil
+}
+
+func (s *MemoryStore) AddLibraryPuzzles(ps []LibraryPuzzle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range ps {
+		i, found := slices.BinarySearchFunc(s.library, p.ID, func(lp LibraryPuzzle, id string) int {
+			return strings.Compare(lp.ID, id)
+		})
+		if found {
+			s.library[i] = p
+		} else {
+			s.library = slices.Insert(s.library, i, p)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) CountLibraryPuzzles() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.library), nil
+}
+
+func (s *MemoryStore) LibraryPuzzleAt(index int) (*LibraryPuzzle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index < 0 || index >= len(s.library) {
+		return nil, ErrPuzzleNotFound
+	}
+	p := s.library[index]
+	return &p, nil
+}
+
+func (s *MemoryStore) AddMasterGames(gs []MasterGame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, g := range gs {
+		g.Moves = slices.Clone(g.Moves)
+		i, found := s.findMasterGame(g.ID)
+		if found {
+			s.masters[i] = g
+		} else {
+			s.masters = slices.Insert(s.masters, i, g)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) CountMasterGames() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.masters), nil
+}
+
+func (s *MemoryStore) MasterGameAt(index int) (*MasterGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index < 0 || index >= len(s.masters) {
+		return nil, ErrMasterGameNotFound
+	}
+	g := s.masters[index]
+	g.Moves = slices.Clone(g.Moves)
+	return &g, nil
+}
+
+func (s *MemoryStore) GetMasterGame(id string) (*MasterGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, found := s.findMasterGame(id)
+	if !found {
+		return nil, ErrMasterGameNotFound
+	}
+	g := s.masters[i]
+	g.Moves = slices.Clone(g.Moves)
+	return &g, nil
+}
+
+// findMasterGame returns where the master game id is, or would be, in s.masters.
+func (s *MemoryStore) findMasterGame(id string) (int, bool) {
+	return slices.BinarySearchFunc(s.masters, id, func(g MasterGame, id string) int {
+		return strings.Compare(g.ID, id)
+	})
+}
+
+func (s *MemoryStore) GetProfile(userID string) (*Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.profiles[userID]
+	if !ok {
+		return nil, ErrProfileNotFound
+	}
+	return p.clone(), nil
+}
+
+func (s *MemoryStore) UpdateProfile(userID string, fn func(*Profile) error) (*Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := newProfile(userID)
+	if current, ok := s.profiles[userID]; ok {
+		next = current.clone()
+	}
+	if err := fn(next); err != nil {
+		return nil, err
+	}
+	next.UpdatedAt = time.Now().UTC()
+	s.profiles[userID] = next
+	return next.clone(), nil
+}
+
+func (s *MemoryStore) GetChatSummary(sessionID string) (*ChatSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[sessionID]
+	if !ok {
+		return nil, ErrChatSummaryNotFound
+	}
+	copied := *c
+	return &copied, nil
+}
+
+func (s *MemoryStore) PutChatSummary(c *ChatSummary) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.UpdatedAt = time.Now().UTC()
+	copied := *c
+	s.chats[c.SessionID] = &copied
+	return nil
+}
+
+func (s *MemoryStore) AddRepertoire(r *Repertoire) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.ID = NewID()
+	s.repertoires[r.ID] = r.clone()
+	return nil
+}
+
+func (s *MemoryStore) GetRepertoire(id string) (*Repertoire, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.repertoires[id]
+	if !ok {
+		return nil, ErrRepertoireNotFound
+	}
+	return r.clone(), nil
+}
+
+func (s *MemoryStore) ListRepertoires(userID string) ([]*Repertoire, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []*Repertoire
+	for _, r := range s.repertoires {
+		if r.UserID == userID {
+			list = append(list, r.clone())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (s *MemoryStore) UpdateRepertoire(id string, fn func(*Repertoire) error) (*Repertoire, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.repertoires[id]
+	if !ok {
+		return nil, ErrRepertoireNotFound
+	}
+	next := current.clone()
+	if err := fn(next); err != nil {
+		return nil, err
+	}
+	next.UpdatedAt = time.Now().UTC()
+	s.repertoires[id] = next
+	return next.clone(), nil
+}
+
+func (s *MemoryStore) AddJob(j *AnalysisJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.ID = NewID()
+	s.jobs[j.ID] = j.clone()
+	return nil
+}
+
+func (s *MemoryStore) GetJob(id string) (*AnalysisJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return j.clone(), nil
+}
+
+func (s *MemoryStore) ClaimJob() (*AnalysisJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var oldest *AnalysisJob
+	for _, j := range s.jobs {
+		if j.Status != JobQueued {
+			continue
+		}
+		if oldest == nil || j.CreatedAt.Before(oldest.CreatedAt) || (j.CreatedAt.Equal(oldest.CreatedAt) && j.ID < oldest.ID) {
+			oldest = j
+		}
+	}
+	if oldest == nil {
+		return nil, ErrJobNotFound
+	}
+	oldest.Status = JobRunning
+	oldest.UpdatedAt = time.Now().UTC()
+	return oldest.clone(), nil
+}
+
+func (s *MemoryStore) UpdateJob(id string, fn func(*AnalysisJob) error) (*AnalysisJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	next := current.clone()
+	if err := fn(next); err != nil {
+		return nil, err
+	}
+	next.UpdatedAt = time.Now().UTC()
+	s.jobs[id] = next
+	return next.clone(), nil
+}
+
+// RequeueJobs has nothing to do: jobs in memory don't outlive the process.
+func (s *MemoryStore) RequeueJobs() (int, error) {
+	return 0, nil
+}
+
+func (s *MemoryStore) CountJobs() (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := map[string]int{}
+	for _, j := range s.jobs {
+		counts[j.Status]++
+	}
+	return counts, nil
+}
+
+func (s *MemoryStore) AddAPIKey(k *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k.ID = NewID()
+	s.apiKeys[k.ID] = k.clone()
+	return nil
+}
+
+func (s *MemoryStore) APIKeyByHash(hash string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.apiKeys {
+		if k.Hash == hash {
+			return k.clone(), nil
+		}
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+func (s *MemoryStore) ListAPIKeys() ([]*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*APIKey, 0, len(s.apiKeys))
+	for _, k := range s.apiKeys {
+		list = append(list, k.clone())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (s *MemoryStore) DeleteAPIKey(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.apiKeys[id]; !ok {
+		return ErrAPIKeyNotFound
+	}
+	delete(s.apiKeys, id)
+	return nil
+}
+
+func (s *MemoryStore) LoginUser(u User) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	for _, existing := range s.users {
+		if existing.Provider == u.Provider && existing.Subject == u.Subject {
+			existing.Name = u.Name
+			existing.Email = u.Email
+			existing.LastLoginAt = now
+			c := *existing
+			return &c, nil
+		}
+	}
+	u.ID = UserIDPrefix + NewID()
+	u.CreatedAt = now
+	u.LastLoginAt = now
+	s.users[u.ID] = &u
+	c := u
+	return &c, nil
+}
+
+func (s *MemoryStore) GetUser(id string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	c := *u
+	return &c, nil
+}
+
+func (s *MemoryStore) RecordUsage(r *UsageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.ID = NewID()
+	s.usage = append(s.usage, *r)
+	return nil
+}
+
+func (s *MemoryStore) UsageTotals(group string, since time.Time) ([]UsageTotal, error) {
+	if _, err := usageKey(&UsageRecord{}, group); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	totals := make(map[string]*UsageTotal)
+	for i := range s.usage {
+		r := &s.usage[i]
+		if r.CreatedAt.Before(since) {
+			continue
+		}
+		key, _ := usageKey(r, group)
+		t, ok := totals[key]
+		if !ok {
+			t = &UsageTotal{Key: key}
+			totals[key] = t
+		}
+		t.add(r)
+	}
+	list := make([]UsageTotal, 0, len(totals))
+	for _, t := range totals {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Cost != list[j].Cost {
+			return list[i].Cost > list[j].Cost
+		}
+		if list[i].Calls != list[j].Calls {
+			return list[i].Calls > list[j].Calls
+		}
+		return list[i].Key < list[j].Key
+	})
+	return list, nil
+}
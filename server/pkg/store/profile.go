@@ -0,0 +1,58 @@
+package store
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"time"
+)
+
+var ErrProfileNotFound = errors.New("store: profile not found")
+
+// Profile is what the coach has learnt about one pupil from their finished games.
+type Profile struct {
+	UserID string `json:"user_id"`
+	// Rating is the pupil's estimated rating, judged from the accuracy of their moves.
+	Rating int `json:"estimated_rating"`
+	Games  int `json:"games"`
+	// Themes counts the pupil's mistakes and blunders by motif, such as "hanging_piece".
+	Themes map[string]int `json:"mistake_themes"`
+	// Phases counts the pupil's mistakes and blunders by game phase.
+	Phases map[string]int `json:"mistake_phases"`
+	// Openings counts the pupil's games by the opening they reached.
+	Openings map[string]int `json:"openings"`
+	// GameIDs lists the games folded into the profile, so no game counts twice.
+	GameIDs   []string  `json:"game_ids"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// newProfile returns an empty profile for userID.
+func newProfile(userID string) *Profile {
+	return &Profile{
+		UserID:   userID,
+		Themes:   map[string]int{},
+		Phases:   map[string]int{},
+		Openings: map[string]int{},
+		GameIDs:  []string{},
+	}
+}
+
+func (p *Profile) clone() *Profile {
+	c := *p
+	c.Themes = maps.Clone(p.Themes)
+	c.Phases = maps.Clone(p.Phases)
+	c.Openings = maps.Clone(p.Openings)
+	c.GameIDs = slices.Clone(p.GameIDs)
+	return &c
+}
+
+// ProfileStore keeps one profile per pupil.
+type ProfileStore interface {
+	// GetProfile returns userID's profile, or ErrProfileNotFound before their first game
+	// has been folded in.
+	GetProfile(userID string) (*Profile, error)
+	// UpdateProfile applies fn to a copy of userID's profile, starting from an empty one
+	// when they have none, and stores the result atomically. fn's error aborts the update
+	// and is returned unchanged.
+	UpdateProfile(userID string, fn func(*Profile) error) (*Profile, error)
+}
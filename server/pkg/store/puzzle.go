@@ -0,0 +1,69 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/srs"
+)
+
+var ErrPuzzleNotFound = errors.New("store: puzzle not found")
+
+// Puzzle is a position from a finished game where the pupil blundered, kept for them to
+// solve again. Card schedules its reviews.
+type Puzzle struct {
+	ID     string `json:"puzzle_id"`
+	GameID string `json:"game_id"`
+	// UserID is the pupil of the game, whose puzzle it is.
+	UserID string `json:"user_id,omitempty"`
+	// Ply is the blunder's 1-based ply in the game; Fen is the position before it.
+	Ply  int    `json:"ply"`
+	Fen  string `json:"fen"`
+	Side string `json:"side"`
+	// Played is the blunder and Solution the engine's move, both in SAN.
+	Played    string    `json:"played"`
+	Solution  string    `json:"solution"`
+	Motif     string    `json:"motif,omitempty"`
+	Loss      int       `json:"centipawn_loss"`
+	Card      srs.Card  `json:"schedule"`
+	Attempts  int       `json:"attempts"`
+	Solved    int       `json:"solved"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PuzzleStore keeps the pupil's puzzles.
+type PuzzleStore interface {
+	// AddPuzzle stores p with a new ID unless its game already has a puzzle at p.Ply, and
+	// reports whether it did.
+	AddPuzzle(p *Puzzle) (bool, error)
+	GetPuzzle(id string) (*Puzzle, error)
+	// NextPuzzle returns the puzzle of userID due soonest, or ErrPuzzleNotFound when they
+	// have none. An empty userID stands for the pupils who gave none.
+	NextPuzzle(userID string) (*Puzzle, error)
+	// UpdatePuzzle applies fn to a copy of the puzzle and stores the result atomically.
+	// fn's error aborts the update and is returned unchanged.
+	UpdatePuzzle(id string, fn func(*Puzzle) error) (*Puzzle, error)
+}
+
+// LibraryPuzzle is a puzzle from the Lichess puzzle database. Moves are in UCI from Fen:
+// the first is the opponent's move that sets the puzzle, and the solver plays the rest
+// alternately with the opponent's replies.
+type LibraryPuzzle struct {
+	ID         string   `json:"puzzle_id"`
+	Fen        string   `json:"fen"`
+	Moves      []string `json:"moves"`
+	Rating     int      `json:"rating"`
+	Popularity int      `json:"popularity"`
+	Plays      int      `json:"plays"`
+	Themes     []string `json:"themes"`
+	GameURL    string   `json:"game_url"`
+}
+
+// PuzzleLibrary holds imported library puzzles.
+type PuzzleLibrary interface {
+	// AddLibraryPuzzles stores ps, replacing puzzles with the same IDs.
+	AddLibraryPuzzles(ps []LibraryPuzzle) error
+	CountLibraryPuzzles() (int, error)
+	// LibraryPuzzleAt returns the puzzle at index in ID order.
+	LibraryPuzzleAt(index int) (*LibraryPuzzle, error)
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/srs"
+)
+
+var ErrRepertoireNotFound = errors.New("store: repertoire not found")
+
+// Repertoire is the set of opening lines a pupil has chosen to play with one side, broken
+// into positions to drill.
+type Repertoire struct {
+	ID     string `json:"repertoire_id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	Side   string `json:"side"`
+	// Lines are the repertoire's lines from the starting position, in SAN.
+	Lines [][]string `json:"lines"`
+	// Cards holds one drill per position where Side is to move, in the order the lines
+	// first reach them.
+	Cards     []RepertoireCard `json:"cards"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// RepertoireCard drills one position of a repertoire: the pupil must recall one of Moves.
+type RepertoireCard struct {
+	// Key identifies the position whatever the move counters, so transpositions share a card.
+	Key string `json:"key"`
+	Fen string `json:"fen"`
+	// Line is the first line's moves reaching the position, in SAN.
+	Line     []string `json:"line"`
+	Moves    []string `json:"moves"`
+	Card     srs.Card `json:"schedule"`
+	Attempts int      `json:"attempts"`
+	Correct  int      `json:"correct"`
+}
+
+func (r *Repertoire) clone() *Repertoire {
+	c := *r
+	c.Lines = make([][]string, len(r.Lines))
+	for i, line := range r.Lines {
+		c.Lines[i] = slices.Clone(line)
+	}
+	c.Cards = make([]RepertoireCard, len(r.Cards))
+	for i, card := range r.Cards {
+		card.Line = slices.Clone(card.Line)
+		card.Moves = slices.Clone(card.Moves)
+		c.Cards[i] = card
+	}
+	return &c
+}
+
+// RepertoireStore keeps pupils' opening repertoires.
+type RepertoireStore interface {
+	// AddRepertoire stores r under a new ID, which it sets.
+	AddRepertoire(r *Repertoire) error
+	GetRepertoire(id string) (*Repertoire, error)
+	// ListRepertoires returns userID's repertoires, oldest first.
+	ListRepertoires(userID string) ([]*Repertoire, error)
+	// UpdateRepertoire applies fn to a copy of the repertoire and stores the result
+	// atomically. fn's error aborts the update and is returned unchanged.
+	UpdateRepertoire(id string, fn func(*Repertoire) error) (*Repertoire, error)
+}
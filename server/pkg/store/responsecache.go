@@ -0,0 +1,17 @@
+package store
+
+import "time"
+
+// ResponseCache keeps cached responses, such as the coach's analysis of common positions,
+// across restarts. It is the cache.Backing of the handlers' caches. Only SQLiteStore
+// implements it: in memory, the caches' own LRUs are all there is to keep.
+type ResponseCache interface {
+	// LoadEntry returns the value stored under key and when it expires; ok is false when
+	// there is none or it has expired.
+	LoadEntry(key string) (value []byte, expires time.Time, ok bool, err error)
+	// SaveEntry stores value under key until expires, replacing any entry there. A zero
+	// expires keeps it for good.
+	SaveEntry(key string, value []byte, expires time.Time) error
+	// PruneEntries deletes the entries expired by now and returns how many it deleted.
+	PruneEntries(now time.Time) (int, error)
+}
@@ -0,0 +1,1058 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS games (
+	id           TEXT PRIMARY KEY,
+	initial_fen  TEXT NOT NULL,
+	fen          TEXT NOT NULL,
+	move_history TEXT NOT NULL,
+	comments     TEXT NOT NULL,
+	takebacks    TEXT NOT NULL DEFAULT '[]',
+	result       TEXT NOT NULL DEFAULT '',
+	reason       TEXT NOT NULL DEFAULT '',
+	variant      TEXT NOT NULL DEFAULT '',
+	persona      TEXT NOT NULL DEFAULT '',
+	user_id      TEXT NOT NULL DEFAULT '',
+	source       TEXT NOT NULL DEFAULT '',
+	pupil_side   TEXT NOT NULL DEFAULT '',
+	version      INTEGER NOT NULL,
+	created_at   INTEGER NOT NULL,
+	updated_at   INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS games_updated_at ON games (updated_at DESC, id);
+CREATE TABLE IF NOT EXISTS puzzles (
+	id            TEXT PRIMARY KEY,
+	game_id       TEXT NOT NULL,
+	user_id       TEXT NOT NULL DEFAULT '',
+	ply           INTEGER NOT NULL,
+	fen           TEXT NOT NULL,
+	side          TEXT NOT NULL,
+	played        TEXT NOT NULL,
+	solution      TEXT NOT NULL,
+	motif         TEXT NOT NULL,
+	loss          INTEGER NOT NULL,
+	repetitions   INTEGER NOT NULL,
+	interval_days INTEGER NOT NULL,
+	ease          REAL NOT NULL,
+	due_at        INTEGER NOT NULL,
+	attempts      INTEGER NOT NULL,
+	solved        INTEGER NOT NULL,
+	created_at    INTEGER NOT NULL,
+	UNIQUE (game_id, ply)
+);
+CREATE INDEX IF NOT EXISTS puzzles_due_at ON puzzles (due_at, id);
+CREATE TABLE IF NOT EXISTS profiles (
+	user_id    TEXT PRIMARY KEY,
+	rating     INTEGER NOT NULL,
+	games      INTEGER NOT NULL,
+	themes     TEXT NOT NULL,
+	phases     TEXT NOT NULL,
+	openings   TEXT NOT NULL,
+	game_ids   TEXT NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS library_puzzles (
+	id         TEXT PRIMARY KEY,
+	fen        TEXT NOT NULL,
+	moves      TEXT NOT NULL,
+	rating     INTEGER NOT NULL,
+	popularity INTEGER NOT NULL,
+	plays      INTEGER NOT NULL,
+	themes     TEXT NOT NULL,
+	game_url   TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS repertoires (
+	id         TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	side       TEXT NOT NULL,
+	lines      TEXT NOT NULL,
+	cards      TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS repertoires_user_id ON repertoires (user_id, created_at, id);
+CREATE TABLE IF NOT EXISTS master_games (
+	id     TEXT PRIMARY KEY,
+	white  TEXT NOT NULL,
+	black  TEXT NOT NULL,
+	event  TEXT NOT NULL,
+	site   TEXT NOT NULL,
+	date   TEXT NOT NULL,
+	result TEXT NOT NULL,
+	moves  TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS analysis_jobs (
+	id              TEXT PRIMARY KEY,
+	status          TEXT NOT NULL,
+	game_id         TEXT NOT NULL,
+	initial_fen     TEXT NOT NULL,
+	move_history    TEXT NOT NULL,
+	depth           INTEGER NOT NULL,
+	moves           TEXT NOT NULL,
+	error           TEXT NOT NULL,
+	callback_url    TEXT NOT NULL DEFAULT '',
+	callback_status TEXT NOT NULL DEFAULT '',
+	created_at      INTEGER NOT NULL,
+	updated_at      INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS analysis_jobs_status ON analysis_jobs (status, created_at, id);
+CREATE TABLE IF NOT EXISTS api_keys (
+	id         TEXT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	hash       TEXT NOT NULL UNIQUE,
+	prefix     TEXT NOT NULL,
+	scopes     TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS users (
+	id            TEXT PRIMARY KEY,
+	provider      TEXT NOT NULL,
+	subject       TEXT NOT NULL,
+	name          TEXT NOT NULL,
+	email         TEXT NOT NULL,
+	created_at    INTEGER NOT NULL,
+	last_login_at INTEGER NOT NULL,
+	UNIQUE (provider, subject)
+);
+CREATE TABLE IF NOT EXISTS model_usage (
+	id            TEXT PRIMARY KEY,
+	user_id       TEXT NOT NULL,
+	endpoint      TEXT NOT NULL,
+	provider      TEXT NOT NULL,
+	model         TEXT NOT NULL,
+	input_tokens  INTEGER NOT NULL,
+	output_tokens INTEGER NOT NULL,
+	cost          REAL NOT NULL,
+	created_at    INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS model_usage_created_at ON model_usage (created_at);
+CREATE TABLE IF NOT EXISTS response_cache (
+	key        TEXT PRIMARY KEY,
+	value      BLOB NOT NULL,
+	expires_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS chat_summaries (
+	session_id TEXT PRIMARY KEY,
+	covered    INTEGER NOT NULL,
+	digest     TEXT NOT NULL,
+	summary    TEXT NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+`
+
+// sqliteIndexes holds the indexes over columns in sqliteColumns, created once those are
+// in place.
+const sqliteIndexes = `
+CREATE INDEX IF NOT EXISTS puzzles_user_id ON puzzles (user_id, due_at, id);
+`
+
+// sqliteColumns lists columns added to existing tables since they were first created, with
+// their definitions. OpenSQLite adds any a database is missing.
+var sqliteColumns = []struct{ table, column, definition string }{
+	{"games", "takebacks", "TEXT NOT NULL DEFAULT '[]'"},
+	{"games", "result", "TEXT NOT NULL DEFAULT ''"},
+	{"games", "reason", "TEXT NOT NULL DEFAULT ''"},
+	{"games", "variant", "TEXT NOT NULL DEFAULT ''"},
+	{"games", "persona", "TEXT NOT NULL DEFAULT ''"},
+	{"games", "user_id", "TEXT NOT NULL DEFAULT ''"},
+	{"games", "source", "TEXT NOT NULL DEFAULT ''"},
+	{"games", "pupil_side", "TEXT NOT NULL DEFAULT ''"},
+	{"puzzles", "user_id", "TEXT NOT NULL DEFAULT ''"},
+	{"analysis_jobs", "callback_url", "TEXT NOT NULL DEFAULT ''"},
+	{"analysis_jobs", "callback_status", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// SQLiteStore keeps games in a SQLite database so they survive restarts. Move history,
+// comments and takebacks are stored as JSON arrays; timestamps as Unix nanoseconds.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens the database at path, creating it and its schema when missing.
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+	// A single connection serializes writers, which SQLite would otherwise reject as busy.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store: create schema: %w", err)
+	}
+	if err := addMissingColumns(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store: migrate schema: %w", err)
+	}
+	if _, err := db.Exec(sqliteIndexes); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store: create indexes: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// addMissingColumns brings databases created by older versions up to sqliteColumns.
+func addMissingColumns(db *sql.DB) error {
+	for _, c := range sqliteColumns {
+		var n int
+		err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column).Scan(&n)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, c.table, c.column, c.definition)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Ping checks that the database can still be reached.
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLiteStore) Create(setup Game) (*Game, error) {
+	now := time.Now().UTC()
+	g := &Game{
+		ID:          NewID(),
+		InitialFen:  setup.InitialFen,
+		Variant:     setup.Variant,
+		Persona:     setup.Persona,
+		UserID:      setup.UserID,
+		Source:      setup.Source,
+		PupilSide:   setup.PupilSide,
+		Fen:         setup.InitialFen,
+		MoveHistory: []string{},
+		Comments:    []MoveComment{},
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	history, comments, takebacks, err := encodeGame(g)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.db.Exec(`INSERT INTO games (`+gameColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		g.ID, g.InitialFen, g.Fen, history, comments, takebacks, g.Result, g.Reason, g.Variant, g.Persona, g.UserID, g.Source, g.PupilSide,
+		g.Version, g.CreatedAt.UnixNano(), g.UpdatedAt.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (s *SQLiteStore) Get(id string) (*Game, error) {
+	return scanGame(s.db.QueryRow(`SELECT `+gameColumns+` FROM games WHERE id = ?`, id))
+}
+
+func (s *SQLiteStore) List() ([]*Game, error) {
+	rows, err := s.db.Query(`SELECT ` + gameColumns + ` FROM games ORDER BY updated_at DESC, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	games := []*Game{}
+	for rows.Next() {
+		g, err := scanGame(rows)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+	return games, rows.Err()
+}
+
+func (s *SQLiteStore) Update(id string, expectedVersion int, fn func(*Game) error) (*Game, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := scanGame(tx.QueryRow(`SELECT `+gameColumns+` FROM games WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	if current.Version != expectedVersion {
+		return nil, ErrVersionConflict
+	}
+
+	next := current.clone()
+	if err := fn(next); err != nil {
+		return nil, err
+	}
+	next.Version = current.Version + 1
+	next.UpdatedAt = time.Now().UTC()
+
+	history, comments, takebacks, err := encodeGame(next)
+	if err != nil {
+		return nil, err
+	}
+	// The version check in the WHERE clause catches a writer that committed between the
+	// read and this update.
+	res, err := tx.Exec(`UPDATE games SET fen = ?, move_history = ?, comments = ?, takebacks = ?, result = ?, reason = ?,
+		version = ?, updated_at = ? WHERE id = ? AND version = ?`,
+		next.Fen, history, comments, takebacks, next.Result, next.Reason, next.Version, next.UpdatedAt.UnixNano(), id, current.Version)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrVersionConflict
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+const gameColumns = `id, initial_fen, fen, move_history, comments, takebacks, result, reason, variant, persona, user_id, source, pupil_side, version, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanGame(row rowScanner) (*Game, error) {
+	var (
+		g                            Game
+		history, comments, takebacks string
+		createdAt, updated           int64
+	)
+	err := row.Scan(&g.ID, &g.InitialFen, &g.Fen, &history, &comments, &takebacks, &g.Result, &g.Reason, &g.Variant, &g.Persona, &g.UserID, &g.Source, &g.PupilSide, &g.Version, &createdAt, &updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(history), &g.MoveHistory); err != nil {
+		return nil, fmt.Errorf("store: decode move history of %s: %w", g.ID, err)
+	}
+	if err := json.Unmarshal([]byte(comments), &g.Comments); err != nil {
+		return nil, fmt.Errorf("store: decode comments of %s: %w", g.ID, err)
+	}
+	if err := json.Unmarshal([]byte(takebacks), &g.Takebacks); err != nil {
+		return nil, fmt.Errorf("store: decode takebacks of %s: %w", g.ID, err)
+	}
+	g.CreatedAt = time.Unix(0, createdAt).UTC()
+	g.UpdatedAt = time.Unix(0, updated).UTC()
+	return &g, nil
+}
+
+func encodeGame(g *Game) (history, comments, takebacks string, err error) {
+	// Store empty lists as [] rather than null.
+	h, err := json.Marshal(append([]string{}, g.MoveHistory...))
+	if err != nil {
+		return "", "", "", err
+	}
+	c, err := json.Marshal(append([]MoveComment{}, g.Comments...))
+	if err != nil {
+		return "", "", "", err
+	}
+	t, err := json.Marshal(append([]Takeback{}, g.Takebacks...))
+	if err != nil {
+		return "", "", "", err
+	}
+	return string(h), string(c), string(t), nil
+}
+
+func (s *SQLiteStore) AddPuzzle(p *Puzzle) (bool, error) {
+	id := NewID()
+	res, err := s.db.Exec(`INSERT INTO puzzles (`+puzzleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (game_id, ply) DO NOTHING`,
+		id, p.GameID, p.UserID, p.Ply, p.Fen, p.Side, p.Played, p.Solution, p.Motif, p.Loss,
+		p.Card.Repetitions, p.Card.IntervalDays, p.Card.Ease, p.Card.DueAt.UnixNano(),
+		p.Attempts, p.Solved, p.CreatedAt.UnixNano())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	p.ID = id
+	return true, nil
+}
+
+func (s *SQLiteStore) GetPuzzle(id string) (*Puzzle, error) {
+	return scanPuzzle(s.db.QueryRow(`SELECT `+puzzleColumns+` FROM puzzles WHERE id = ?`, id))
+}
+
+func (s *SQLiteStore) NextPuzzle(userID string) (*Puzzle, error) {
+	return scanPuzzle(s.db.QueryRow(`SELECT `+puzzleColumns+` FROM puzzles WHERE user_id = ? ORDER BY due_at, id LIMIT 1`, userID))
+}
+
+func (s *SQLiteStore) UpdatePuzzle(id string, fn func(*Puzzle) error) (*Puzzle, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	p, err := scanPuzzle(tx.QueryRow(`SELECT `+puzzleColumns+` FROM puzzles WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(p); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE puzzles SET repetitions = ?, interval_days = ?, ease = ?, due_at = ?, attempts = ?, solved = ?
+		WHERE id = ?`,
+		p.Card.Repetitions, p.Card.IntervalDays, p.Card.Ease, p.Card.DueAt.UnixNano(), p.Attempts, p.Solved, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+const puzzleColumns = `id, game_id, user_id, ply, fen, side, played, solution, motif, loss, repetitions, interval_days, ease, due_at, attempts, solved, created_at`
+
+func scanPuzzle(row rowScanner) (*Puzzle, error) {
+	var (
+		p                Puzzle
+		dueAt, createdAt int64
+	)
+	err := row.Scan(&p.ID, &p.GameID, &p.UserID, &p.Ply, &p.Fen, &p.Side, &p.Played, &p.Solution, &p.Motif, &p.Loss,
+		&p.Card.Repetitions, &p.Card.IntervalDays, &p.Card.Ease, &dueAt, &p.Attempts, &p.Solved, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPuzzleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Card.DueAt = time.Unix(0, dueAt).UTC()
+	p.CreatedAt = time.Unix(0, createdAt).UTC()
+	return &p, nil
+}
+
+func (s *SQLiteStore) GetProfile(userID string) (*Profile, error) {
+	return scanProfile(s.db.QueryRow(`SELECT `+profileColumns+` FROM profiles WHERE user_id = ?`, userID))
+}
+
+// UpdateProfile stores the profile's counts and game list as JSON.
+func (s *SQLiteStore) UpdateProfile(userID string, fn func(*Profile) error) (*Profile, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	next, err := scanProfile(tx.QueryRow(`SELECT `+profileColumns+` FROM profiles WHERE user_id = ?`, userID))
+	if errors.Is(err, ErrProfileNotFound) {
+		next = newProfile(userID)
+	} else if err != nil {
+		return nil, err
+	}
+	if err := fn(next); err != nil {
+		return nil, err
+	}
+	next.UpdatedAt = time.Now().UTC()
+
+	var encoded [4]string
+	for i, v := range []any{next.Themes, next.Phases, next.Openings, next.GameIDs} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = string(b)
+	}
+	_, err = tx.Exec(`INSERT OR REPLACE INTO profiles (`+profileColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, next.Rating, next.Games, encoded[0], encoded[1], encoded[2], encoded[3], next.UpdatedAt.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+const profileColumns = `user_id, rating, games, themes, phases, openings, game_ids, updated_at`
+
+func scanProfile(row rowScanner) (*Profile, error) {
+	var (
+		p                                 Profile
+		themes, phases, openings, gameIDs string
+		updatedAt                         int64
+	)
+	err := row.Scan(&p.UserID, &p.Rating, &p.Games, &themes, &phases, &openings, &gameIDs, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProfileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range []struct {
+		data string
+		dest any
+	}{{themes, &p.Themes}, {phases, &p.Phases}, {openings, &p.Openings}, {gameIDs, &p.GameIDs}} {
+		if err := json.Unmarshal([]byte(f.data), f.dest); err != nil {
+			return nil, fmt.Errorf("store: decode profile of %s: %w", p.UserID, err)
+		}
+	}
+	p.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	return &p, nil
+}
+
+func (s *SQLiteStore) GetChatSummary(sessionID string) (*ChatSummary, error) {
+	var (
+		c         ChatSummary
+		updatedAt int64
+	)
+	err := s.db.QueryRow(`SELECT session_id, covered, digest, summary, updated_at FROM chat_summaries WHERE session_id = ?`,
+		sessionID).Scan(&c.SessionID, &c.Covered, &c.Digest, &c.Summary, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrChatSummaryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	return &c, nil
+}
+
+func (s *SQLiteStore) PutChatSummary(c *ChatSummary) error {
+	c.UpdatedAt = time.Now().UTC()
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO chat_summaries (session_id, covered, digest, summary, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		c.SessionID, c.Covered, c.Digest, c.Summary, c.UpdatedAt.UnixNano())
+	return err
+}
+
+// AddLibraryPuzzles stores ps in one transaction. Moves and themes are kept
+// space-separated, as in the Lichess CSV.
+func (s *SQLiteStore) AddLibraryPuzzles(ps []LibraryPuzzle) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO library_puzzles (` + libraryColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, p := range ps {
+		if _, err := stmt.Exec(p.ID, p.Fen, strings.Join(p.Moves, " "), p.Rating, p.Popularity, p.Plays,
+			strings.Join(p.Themes, " "), p.GameURL); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) CountLibraryPuzzles() (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM library_puzzles`).Scan(&n)
+	return n, err
+}
+
+func (s *SQLiteStore) LibraryPuzzleAt(index int) (*LibraryPuzzle, error) {
+	var (
+		p             LibraryPuzzle
+		moves, themes string
+	)
+	err := s.db.QueryRow(`SELECT `+libraryColumns+` FROM library_puzzles ORDER BY id LIMIT 1 OFFSET ?`, index).
+		Scan(&p.ID, &p.Fen, &moves, &p.Rating, &p.Popularity, &p.Plays, &themes, &p.GameURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPuzzleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Moves = strings.Fields(moves)
+	p.Themes = strings.Fields(themes)
+	return &p, nil
+}
+
+const libraryColumns = `id, fen, moves, rating, popularity, plays, themes, game_url`
+
+// AddMasterGames stores gs in one transaction. Moves are kept space-separated, as in PGN.
+func (s *SQLiteStore) AddMasterGames(gs []MasterGame) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO master_games (` + masterColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, g := range gs {
+		if _, err := stmt.Exec(g.ID, g.White, g.Black, g.Event, g.Site, g.Date, g.Result, strings.Join(g.Moves, " ")); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) CountMasterGames() (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM master_games`).Scan(&n)
+	return n, err
+}
+
+func (s *SQLiteStore) MasterGameAt(index int) (*MasterGame, error) {
+	return scanMasterGame(s.db.QueryRow(`SELECT `+masterColumns+` FROM master_games ORDER BY id LIMIT 1 OFFSET ?`, index))
+}
+
+func (s *SQLiteStore) GetMasterGame(id string) (*MasterGame, error) {
+	return scanMasterGame(s.db.QueryRow(`SELECT `+masterColumns+` FROM master_games WHERE id = ?`, id))
+}
+
+const masterColumns = `id, white, black, event, site, date, result, moves`
+
+func scanMasterGame(row rowScanner) (*MasterGame, error) {
+	var (
+		g     MasterGame
+		moves string
+	)
+	err := row.Scan(&g.ID, &g.White, &g.Black, &g.Event, &g.Site, &g.Date, &g.Result, &moves)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMasterGameNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.Moves = strings.Fields(moves)
+	return &g, nil
+}
+
+// AddRepertoire stores the repertoire's lines and cards as JSON.
+func (s *SQLiteStore) AddRepertoire(r *Repertoire) error {
+	id := NewID()
+	if err := writeRepertoire(s.db, id, r); err != nil {
+		return err
+	}
+	r.ID = id
+	return nil
+}
+
+func (s *SQLiteStore) GetRepertoire(id string) (*Repertoire, error) {
+	return scanRepertoire(s.db.QueryRow(`SELECT `+repertoireColumns+` FROM repertoires WHERE id = ?`, id))
+}
+
+func (s *SQLiteStore) ListRepertoires(userID string) ([]*Repertoire, error) {
+	rows, err := s.db.Query(`SELECT `+repertoireColumns+` FROM repertoires WHERE user_id = ? ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*Repertoire
+	for rows.Next() {
+		r, err := scanRepertoire(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+func (s *SQLiteStore) UpdateRepertoire(id string, fn func(*Repertoire) error) (*Repertoire, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	next, err := scanRepertoire(tx.QueryRow(`SELECT `+repertoireColumns+` FROM repertoires WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(next); err != nil {
+		return nil, err
+	}
+	next.UpdatedAt = time.Now().UTC()
+	if err := writeRepertoire(tx, id, next); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// execer is a *sql.DB or *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// writeRepertoire inserts or replaces the row for r under id.
+func writeRepertoire(db execer, id string, r *Repertoire) error {
+	lines, err := json.Marshal(r.Lines)
+	if err != nil {
+		return err
+	}
+	cards, err := json.Marshal(r.Cards)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO repertoires (`+repertoireColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, r.UserID, r.Name, r.Side, string(lines), string(cards), r.CreatedAt.UnixNano(), r.UpdatedAt.UnixNano())
+	return err
+}
+
+const repertoireColumns = `id, user_id, name, side, lines, cards, created_at, updated_at`
+
+func scanRepertoire(row rowScanner) (*Repertoire, error) {
+	var (
+		r                    Repertoire
+		lines, cards         string
+		createdAt, updatedAt int64
+	)
+	err := row.Scan(&r.ID, &r.UserID, &r.Name, &r.Side, &lines, &cards, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRepertoireNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(lines), &r.Lines); err != nil {
+		return nil, fmt.Errorf("store: decode lines of repertoire %s: %w", r.ID, err)
+	}
+	if err := json.Unmarshal([]byte(cards), &r.Cards); err != nil {
+		return nil, fmt.Errorf("store: decode cards of repertoire %s: %w", r.ID, err)
+	}
+	r.CreatedAt = time.Unix(0, createdAt).UTC()
+	r.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	return &r, nil
+}
+
+// AddJob stores the job's move history and scored moves as JSON.
+func (s *SQLiteStore) AddJob(j *AnalysisJob) error {
+	id := NewID()
+	if err := writeJob(s.db, id, j); err != nil {
+		return err
+	}
+	j.ID = id
+	return nil
+}
+
+func (s *SQLiteStore) GetJob(id string) (*AnalysisJob, error) {
+	return scanJob(s.db.QueryRow(`SELECT `+jobColumns+` FROM analysis_jobs WHERE id = ?`, id))
+}
+
+func (s *SQLiteStore) ClaimJob() (*AnalysisJob, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	j, err := scanJob(tx.QueryRow(`SELECT `+jobColumns+` FROM analysis_jobs WHERE status = ? ORDER BY created_at, id LIMIT 1`, JobQueued))
+	if err != nil {
+		return nil, err
+	}
+	j.Status = JobRunning
+	j.UpdatedAt = time.Now().UTC()
+	if _, err := tx.Exec(`UPDATE analysis_jobs SET status = ?, updated_at = ? WHERE id = ?`, j.Status, j.UpdatedAt.UnixNano(), j.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (s *SQLiteStore) UpdateJob(id string, fn func(*AnalysisJob) error) (*AnalysisJob, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	next, err := scanJob(tx.QueryRow(`SELECT `+jobColumns+` FROM analysis_jobs WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(next); err != nil {
+		return nil, err
+	}
+	next.UpdatedAt = time.Now().UTC()
+	if err := writeJob(tx, id, next); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+func (s *SQLiteStore) RequeueJobs() (int, error) {
+	res, err := s.db.Exec(`UPDATE analysis_jobs SET status = ? WHERE status = ?`, JobQueued, JobRunning)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLiteStore) CountJobs() (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT status, COUNT(*) FROM analysis_jobs GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+// writeJob inserts or replaces the row for j under id.
+func writeJob(db execer, id string, j *AnalysisJob) error {
+	history, err := json.Marshal(j.MoveHistory)
+	if err != nil {
+		return err
+	}
+	moves, err := json.Marshal(j.Moves)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO analysis_jobs (`+jobColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, j.Status, j.GameID, j.InitialFen, string(history), j.Depth, string(moves), j.Error, j.CallbackURL, j.CallbackStatus,
+		j.CreatedAt.UnixNano(), j.UpdatedAt.UnixNano())
+	return err
+}
+
+const jobColumns = `id, status, game_id, initial_fen, move_history, depth, moves, error, callback_url, callback_status, created_at, updated_at`
+
+func scanJob(row rowScanner) (*AnalysisJob, error) {
+	var (
+		j                    AnalysisJob
+		history, moves       string
+		createdAt, updatedAt int64
+	)
+	err := row.Scan(&j.ID, &j.Status, &j.GameID, &j.InitialFen, &history, &j.Depth, &moves, &j.Error, &j.CallbackURL, &j.CallbackStatus, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(history), &j.MoveHistory); err != nil {
+		return nil, fmt.Errorf("store: decode move history of analysis job %s: %w", j.ID, err)
+	}
+	if err := json.Unmarshal([]byte(moves), &j.Moves); err != nil {
+		return nil, fmt.Errorf("store: decode moves of analysis job %s: %w", j.ID, err)
+	}
+	j.CreatedAt = time.Unix(0, createdAt).UTC()
+	j.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	return &j, nil
+}
+
+// AddAPIKey stores the key's hash, never the key itself.
+func (s *SQLiteStore) AddAPIKey(k *APIKey) error {
+	scopes, err := json.Marshal(k.Scopes)
+	if err != nil {
+		return err
+	}
+	id := NewID()
+	_, err = s.db.Exec(`INSERT INTO api_keys (`+apiKeyColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		id, k.Name, k.Hash, k.Prefix, string(scopes), k.CreatedAt.UnixNano())
+	if err != nil {
+		return err
+	}
+	k.ID = id
+	return nil
+}
+
+func (s *SQLiteStore) APIKeyByHash(hash string) (*APIKey, error) {
+	return scanAPIKey(s.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE hash = ?`, hash))
+}
+
+func (s *SQLiteStore) ListAPIKeys() ([]*APIKey, error) {
+	rows, err := s.db.Query(`SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, k)
+	}
+	return list, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteAPIKey(id string) error {
+	res, err := s.db.Exec(`DELETE FROM api_keys WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+const apiKeyColumns = `id, name, hash, prefix, scopes, created_at`
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var (
+		k         APIKey
+		scopes    string
+		createdAt int64
+	)
+	err := row.Scan(&k.ID, &k.Name, &k.Hash, &k.Prefix, &scopes, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &k.Scopes); err != nil {
+		return nil, fmt.Errorf("store: decode scopes of API key %s: %w", k.ID, err)
+	}
+	k.CreatedAt = time.Unix(0, createdAt).UTC()
+	return &k, nil
+}
+
+func (s *SQLiteStore) LoginUser(u User) (*User, error) {
+	now := time.Now().UTC().UnixNano()
+	_, err := s.db.Exec(`INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (provider, subject) DO UPDATE SET name = excluded.name, email = excluded.email, last_login_at = excluded.last_login_at`,
+		UserIDPrefix+NewID(), u.Provider, u.Subject, u.Name, u.Email, now, now)
+	if err != nil {
+		return nil, err
+	}
+	return scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE provider = ? AND subject = ?`, u.Provider, u.Subject))
+}
+
+func (s *SQLiteStore) GetUser(id string) (*User, error) {
+	return scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+const userColumns = `id, provider, subject, name, email, created_at, last_login_at`
+
+func scanUser(row rowScanner) (*User, error) {
+	var (
+		u                    User
+		createdAt, lastLogin int64
+	)
+	err := row.Scan(&u.ID, &u.Provider, &u.Subject, &u.Name, &u.Email, &createdAt, &lastLogin)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.CreatedAt = time.Unix(0, createdAt).UTC()
+	u.LastLoginAt = time.Unix(0, lastLogin).UTC()
+	return &u, nil
+}
+
+func (s *SQLiteStore) RecordUsage(r *UsageRecord) error {
+	id := NewID()
+	_, err := s.db.Exec(`INSERT INTO model_usage (id, user_id, endpoint, provider, model, input_tokens, output_tokens, cost, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, r.UserID, r.Endpoint, r.Provider, r.Model, r.InputTokens, r.OutputTokens, r.Cost, r.CreatedAt.UnixNano())
+	if err != nil {
+		return err
+	}
+	r.ID = id
+	return nil
+}
+
+func (s *SQLiteStore) UsageTotals(group string, since time.Time) ([]UsageTotal, error) {
+	// group is spliced into the query, so only the known columns are let through.
+	if _, err := usageKey(&UsageRecord{}, group); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT `+group+`, COUNT(*), SUM(input_tokens), SUM(output_tokens), SUM(cost)
+		FROM model_usage WHERE created_at >= ? GROUP BY `+group+`
+		ORDER BY SUM(cost) DESC, COUNT(*) DESC, `+group, since.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []UsageTotal{}
+	for rows.Next() {
+		var t UsageTotal
+		if err := rows.Scan(&t.Key, &t.Calls, &t.InputTokens, &t.OutputTokens, &t.Cost); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// LoadEntry reads a cached response. Expired entries are left for PruneEntries.
+func (s *SQLiteStore) LoadEntry(key string) ([]byte, time.Time, bool, error) {
+	var (
+		value     []byte
+		expiresAt int64
+	)
+	err := s.db.QueryRow(`SELECT value, expires_at FROM response_cache WHERE key = ?`, key).Scan(&value, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, time.Time{}, false, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	if expiresAt == 0 {
+		return value, time.Time{}, true, nil
+	}
+	expires := time.Unix(0, expiresAt).UTC()
+	if !time.Now().Before(expires) {
+		return nil, time.Time{}, false, nil
+	}
+	return value, expires, true, nil
+}
+
+func (s *SQLiteStore) SaveEntry(key string, value []byte, expires time.Time) error {
+	var expiresAt int64
+	if !expires.IsZero() {
+		expiresAt = expires.UnixNano()
+	}
+	_, err := s.db.Exec(`INSERT INTO response_cache (key, value, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		key, value, expiresAt)
+	return err
+}
+
+func (s *SQLiteStore) PruneEntries(now time.Time) (int, error) {
+	res, err := s.db.Exec(`DELETE FROM response_cache WHERE expires_at != 0 AND expires_at <= ?`, now.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
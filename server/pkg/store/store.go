@@ -0,0 +1,88 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrNotFound        = errors.New("store: game not found")
+	ErrVersionConflict = errors.New("store: game was modified concurrently")
+)
+
+type Game struct {
+	ID          string   `json:"game_id"`
+	InitialFen  string   `json:"initial_fen"`
+	Fen         string   `json:"fen"`
+	MoveHistory []string `json:"move_history"`
+	// Comments holds the commentary recorded with moves, in ply order.
+	Comments []MoveComment `json:"comments"`
+	// Takebacks records every takeback, oldest first.
+	Takebacks []Takeback `json:"takebacks,omitempty"`
+	// Result and Reason close a game that ended off the board, by resignation, an agreed
+	// draw or, in imported games, on time. Games that end on the board leave them empty;
+	// their result follows from the moves.
+	Result  string `json:"result,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Variant string `json:"variant,omitempty"` // a chess.Variant name; empty is standard chess
+	Persona string `json:"persona,omitempty"` // the coach's persona name; empty is the default coach
+	UserID  string `json:"user_id,omitempty"` // the pupil whose profile the game feeds
+	// Source is the URL of a game imported from another site, and PupilSide the side the
+	// pupil played in it. Games played here leave both empty.
+	Source    string    `json:"source,omitempty"`
+	PupilSide string    `json:"pupil_side,omitempty"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MoveComment is coaching attached to the move at Ply (1-based) in a game's history.
+type MoveComment struct {
+	Ply     int         `json:"ply"`
+	Comment string      `json:"comment"`
+	Title   string      `json:"title,omitempty"`
+	Arrows  [][2]string `json:"arrows,omitempty"`
+}
+
+// Takeback records moves taken back from a game. Ply is the length of the move history
+// once they were removed.
+type Takeback struct {
+	Ply   int       `json:"ply"`
+	Moves []string  `json:"moves"`
+	At    time.Time `json:"at"`
+}
+
+func (g *Game) clone() *Game {
+	c := *g
+	c.MoveHistory = slices.Clone(g.MoveHistory)
+	c.Comments = slices.Clone(g.Comments)
+	c.Takebacks = slices.Clone(g.Takebacks)
+	return &c
+}
+
+// GameStore persists games with optimistic concurrency control: every successful update
+// increments Version, and an update made against a stale version fails with
+// ErrVersionConflict instead of overwriting a concurrent change.
+type GameStore interface {
+	// Create stores a new game set up from setup's InitialFen, Variant, Persona, UserID,
+	// Source and PupilSide; the store fills in every other field.
+	Create(setup Game) (*Game, error)
+	Get(id string) (*Game, error)
+	// List returns every game, most recently updated first.
+	List() ([]*Game, error)
+	// Update applies fn to a copy of the game at expectedVersion and stores the result
+	// atomically. fn's error aborts the update and is returned unchanged.
+	Update(id string, expectedVersion int, fn func(*Game) error) (*Game, error)
+}
+
+// NewID returns a random 32-character hex ID, as the stores give games and other records.
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
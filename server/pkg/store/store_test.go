@@ -0,0 +1,214 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// gameStores returns each GameStore implementation, empty.
+func gameStores(t *testing.T) map[string]GameStore {
+	t.Helper()
+	sqlite, err := OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	return map[string]GameStore{"memory": NewMemoryStore(), "sqlite": sqlite}
+}
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+func playMove(san, fen string) func(*Game) error {
+	return func(g *Game) error {
+		g.MoveHistory = append(g.MoveHistory, san)
+		g.Fen = fen
+		return nil
+	}
+}
+
+func TestUpdateConflictingVersions(t *testing.T) {
+	for name, games := range gameStores(t) {
+		t.Run(name, func(t *testing.T) {
+			game, err := games.Create(Game{InitialFen: startFEN})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Two submissions of the same move against the same version, as from a
+			// double-click.
+			var wg sync.WaitGroup
+			errs := make([]error, 2)
+			for i := range errs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, errs[i] = games.Update(game.ID, game.Version, playMove("e4", "after e4"))
+				}()
+			}
+			wg.Wait()
+
+			succeeded, conflicted := 0, 0
+			for _, err := range errs {
+				switch {
+				case err == nil:
+					succeeded++
+				case errors.Is(err, ErrVersionConflict):
+					conflicted++
+				default:
+					t.Fatalf("Update: %v", err)
+				}
+			}
+			if succeeded != 1 || conflicted != 1 {
+				t.Fatalf("%d updates succeeded and %d conflicted, want 1 and 1", succeeded, conflicted)
+			}
+
+			stored, err := games.Get(game.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Version != game.Version+1 || len(stored.MoveHistory) != 1 {
+				t.Errorf("stored game has version %d and %d moves, want %d and 1", stored.Version, len(stored.MoveHistory), game.Version+1)
+			}
+		})
+	}
+}
+
+func TestUpdateManyConcurrentWriters(t *testing.T) {
+	const writers = 16
+	for name, games := range gameStores(t) {
+		t.Run(name, func(t *testing.T) {
+			game, err := games.Create(Game{InitialFen: startFEN})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			succeeded := 0
+			for range writers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := games.Update(game.ID, game.Version, playMove("d4", "after d4"))
+					if err != nil && !errors.Is(err, ErrVersionConflict) {
+						t.Errorf("Update: %v", err)
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					if err == nil {
+						succeeded++
+					}
+				}()
+			}
+			wg.Wait()
+			if succeeded != 1 {
+				t.Errorf("%d of %d writers succeeded, want exactly 1", succeeded, writers)
+			}
+		})
+	}
+}
+
+func TestUpdateSequentialVersions(t *testing.T) {
+	for name, games := range gameStores(t) {
+		t.Run(name, func(t *testing.T) {
+			game, err := games.Create(Game{InitialFen: startFEN})
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, err := games.Update(game.ID, game.Version, playMove("e4", "after e4"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := games.Update(game.ID, game.Version, playMove("d4", "after d4")); !errors.Is(err, ErrVersionConflict) {
+				t.Errorf("update against the old version: err = %v, want ErrVersionConflict", err)
+			}
+			second, err := games.Update(game.ID, first.Version, playMove("e5", "after e5"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if second.Version != game.Version+2 || len(second.MoveHistory) != 2 {
+				t.Errorf("game has version %d and moves %v after two updates", second.Version, second.MoveHistory)
+			}
+		})
+	}
+}
+
+func TestUpdateFailedFnLeavesGame(t *testing.T) {
+	errRejected := errors.New("rejected")
+	for name, games := range gameStores(t) {
+		t.Run(name, func(t *testing.T) {
+			game, err := games.Create(Game{InitialFen: startFEN})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = games.Update(game.ID, game.Version, func(g *Game) error {
+				g.MoveHistory = append(g.MoveHistory, "e4")
+				return errRejected
+			})
+			if !errors.Is(err, errRejected) {
+				t.Fatalf("err = %v, want the update function's error", err)
+			}
+			stored, err := games.Get(game.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Version != game.Version || len(stored.MoveHistory) != 0 {
+				t.Errorf("failed update changed the game: version %d, moves %v", stored.Version, stored.MoveHistory)
+			}
+			if _, err := games.Update("missing", 1, playMove("e4", "after e4")); !errors.Is(err, ErrNotFound) {
+				t.Errorf("update of a missing game: err = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+// puzzleStores returns each PuzzleStore implementation, empty.
+func puzzleStores(t *testing.T) map[string]PuzzleStore {
+	t.Helper()
+	stores := map[string]PuzzleStore{}
+	for name, games := range gameStores(t) {
+		stores[name] = games.(PuzzleStore)
+	}
+	return stores
+}
+
+func TestUpdatePuzzleConcurrentAttempts(t *testing.T) {
+	const attempts = 16
+	for name, puzzles := range puzzleStores(t) {
+		t.Run(name, func(t *testing.T) {
+			p := &Puzzle{GameID: "g1", Ply: 1, Fen: startFEN, Side: "white", Played: "a3", Solution: "e4"}
+			if _, err := puzzles.AddPuzzle(p); err != nil {
+				t.Fatal(err)
+			}
+			var wg sync.WaitGroup
+			for i := range attempts {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := puzzles.UpdatePuzzle(p.ID, func(p *Puzzle) error {
+						p.Attempts++
+						if i%2 == 0 {
+							p.Solved++
+						}
+						return nil
+					})
+					if err != nil {
+						t.Errorf("UpdatePuzzle: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			stored, err := puzzles.GetPuzzle(p.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Attempts != attempts || stored.Solved != attempts/2 {
+				t.Errorf("puzzle has %d attempts and %d solves, want %d and %d", stored.Attempts, stored.Solved, attempts, attempts/2)
+			}
+			if _, err := puzzles.UpdatePuzzle("missing", func(*Puzzle) error { return nil }); !errors.Is(err, ErrPuzzleNotFound) {
+				t.Errorf("update of a missing puzzle: err = %v, want ErrPuzzleNotFound", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrUsageGroup = errors.New("store: unknown usage grouping")
+
+// UsageRecord is the tokens one model call consumed and what they are estimated to cost.
+type UsageRecord struct {
+	ID string `json:"usage_id"`
+	// UserID is whom the call was made for: a user ID, or the caller an anonymous request
+	// was let in as, such as "key:…" or "ip:…". It is empty for background work.
+	UserID       string    `json:"user_id"`
+	Endpoint     string    `json:"endpoint"`
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	Cost         float64   `json:"cost_usd"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// The groupings UsageTotals can sum records by.
+const (
+	UsageByUser     = "user_id"
+	UsageByEndpoint = "endpoint"
+	UsageByModel    = "model"
+)
+
+// usageKey returns the field of r that group sums by.
+func usageKey(r *UsageRecord, group string) (string, error) {
+	switch group {
+	case UsageByUser:
+		return r.UserID, nil
+	case UsageByEndpoint:
+		return r.Endpoint, nil
+	case UsageByModel:
+		return r.Model, nil
+	}
+	return "", ErrUsageGroup
+}
+
+// UsageTotal sums the usage records sharing a Key.
+type UsageTotal struct {
+	Key          string  `json:"key"`
+	Calls        int     `json:"calls"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost_usd"`
+}
+
+func (t *UsageTotal) add(r *UsageRecord) {
+	t.Calls++
+	t.InputTokens += r.InputTokens
+	t.OutputTokens += r.OutputTokens
+	t.Cost += r.Cost
+}
+
+// UsageStore keeps a record of every model call for cost accounting.
+type UsageStore interface {
+	// RecordUsage stores r under a new ID, which it sets.
+	RecordUsage(r *UsageRecord) error
+	// UsageTotals sums the records made at or after since by group, one of the UsageBy
+	// constants, most expensive first. Any other group fails with ErrUsageGroup.
+	UsageTotals(group string, since time.Time) ([]UsageTotal, error)
+}
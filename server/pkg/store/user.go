@@ -0,0 +1,41 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrUserNotFound = errors.New("store: user not found")
+
+// UserIDPrefix starts the ID of every user with an account, setting them apart from the
+// free-form user IDs pupils without one choose, such as "lichess:name".
+const UserIDPrefix = "user:"
+
+// IsUserID reports whether id is the ID of a user with an account.
+func IsUserID(id string) bool {
+	return strings.HasPrefix(id, UserIDPrefix)
+}
+
+// User is a pupil with an account, signed in through an OAuth provider. Their ID is the
+// user_id of their games, profile, puzzles and repertoires.
+type User struct {
+	ID string `json:"user_id"`
+	// Provider names the sign-in provider, such as "google", and Subject is the user's
+	// ID there.
+	Provider    string    `json:"provider"`
+	Subject     string    `json:"-"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+// UserStore keeps the users with accounts.
+type UserStore interface {
+	// LoginUser records a sign-in by u.Provider and u.Subject. The first creates the user
+	// under a new ID; later ones update their name, email and LastLoginAt. It returns the
+	// stored user.
+	LoginUser(u User) (*User, error)
+	GetUser(id string) (*User, error)
+}
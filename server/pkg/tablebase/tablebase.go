@@ -0,0 +1,159 @@
+// Package tablebase looks up exact endgame results in Syzygy tablebases through the
+// lichess tablebase API, either the public service or a self-hosted lila-tablebase server
+// reading local Syzygy files.
+package tablebase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"arnavsurve/nara-chess/server/pkg/cache"
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+// LichessURL is the public lichess tablebase service.
+const LichessURL = "https://tablebase.lichess.ovh"
+
+// MaxPieces is the most pieces, kings included, a Syzygy table covers.
+const MaxPieces = 7
+
+var ErrNotCovered = errors.New("tablebase: position is not covered")
+
+// Categories reported by the API, from the point of view of the side to move. Cursed
+// wins and blessed losses are decisive only without the fifty-move rule.
+const (
+	Win         = "win"
+	CursedWin   = "cursed-win"
+	Draw        = "draw"
+	BlessedLoss = "blessed-loss"
+	Loss        = "loss"
+)
+
+// Result is a probed position. Moves is sorted best first for the side to move, and each
+// move's Category is from the opponent's point of view after it.
+type Result struct {
+	Category  string `json:"category"`
+	DTZ       *int   `json:"dtz"`
+	DTM       *int   `json:"dtm"`
+	Checkmate bool   `json:"checkmate"`
+	Stalemate bool   `json:"stalemate"`
+	Moves     []Move `json:"moves"`
+}
+
+type Move struct {
+	UCI      string `json:"uci"`
+	SAN      string `json:"san"`
+	Category string `json:"category"`
+	DTZ      *int   `json:"dtz"`
+}
+
+// Outcome reduces a category to "win", "draw" or "loss" under the fifty-move rule, or ""
+// when the tables could not decide it.
+func Outcome(category string) string {
+	switch category {
+	case Win, "syzygy-win", "maybe-win":
+		return "win"
+	case CursedWin, Draw, BlessedLoss:
+		return "draw"
+	case Loss, "syzygy-loss", "maybe-loss":
+		return "loss"
+	}
+	return ""
+}
+
+// BestMoves lists the moves that keep the position's result for the side to move, in SAN.
+func (r Result) BestMoves() []string {
+	want := Outcome(r.Category)
+	var best []string
+	for _, m := range r.Moves {
+		if want != "" && reverse(Outcome(m.Category)) == want {
+			best = append(best, m.SAN)
+		}
+	}
+	return best
+}
+
+func reverse(outcome string) string {
+	switch outcome {
+	case "win":
+		return "loss"
+	case "loss":
+		return "win"
+	}
+	return outcome
+}
+
+// Covered reports whether pos is small enough for the tables. Syzygy has no castling and
+// only covers standard chess.
+func Covered(pos *chess.Position) bool {
+	if pos.Castling != 0 || pos.Variant != chess.Standard {
+		return false
+	}
+	pieces := 0
+	for _, p := range pos.Board {
+		if p != chess.NoPiece {
+			pieces++
+		}
+	}
+	return pieces <= MaxPieces
+}
+
+// Client probes a lichess tablebase API server, remembering recent answers since the
+// result of a position never changes.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	results *cache.LRU[string, Result]
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 5 * time.Second},
+		results: cache.NewLRU[string, Result](1024, 0),
+	}
+}
+
+// Probe looks up pos, returning ErrNotCovered for positions outside the tables.
+func (c *Client) Probe(ctx context.Context, pos *chess.Position) (Result, error) {
+	if !Covered(pos) {
+		return Result{}, ErrNotCovered
+	}
+	fen := pos.FEN()
+	if r, ok := c.results.Get(fen); ok {
+		return r, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/standard?fen="+url.QueryEscape(fen), nil)
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("User-Agent", "nara-chess")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("tablebase: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Result{}, fmt.Errorf("tablebase: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var r Result
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return Result{}, fmt.Errorf("tablebase: decoding response: %w", err)
+	}
+	if Outcome(r.Category) == "" {
+		// Not cached: the server may have the tables on a later request.
+		return Result{}, ErrNotCovered
+	}
+	c.results.Add(fen, r)
+	return r, nil
+}
@@ -0,0 +1,82 @@
+// Package tracing records OpenTelemetry spans for the request pipeline, from the HTTP
+// handler through prompt building, model calls and parsing to the checks on the model's
+// answer, and exports them over OTLP so slow requests can be followed end to end. Until
+// Setup is called spans are dropped at no cost.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName identifies the server's spans in the tracing backend.
+const ServiceName = "nara-chess"
+
+// tracer picks up the provider Setup installs, including for spans started before it.
+var tracer = otel.Tracer("arnavsurve/nara-chess/server")
+
+// Setup exports spans to the OTLP/HTTP collector at endpoint, such as
+// http://localhost:4318; an endpoint without a path gets the standard /v1/traces. Every
+// trace is sampled unless OTEL_TRACES_SAMPLER says otherwise. The returned shutdown
+// flushes the spans still buffered.
+func Setup(ctx context.Context, endpoint string) (shutdown func(context.Context) error, err error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("tracing endpoint: %w", err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(u.String()))
+	if err != nil {
+		return nil, fmt.Errorf("creating the OTLP exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("describing the service: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Middleware starts a span for each request next serves, continuing the trace of a
+// caller that sent a traceparent header. route names the span after the endpoint the
+// request reached, such as "POST /v1/chat", or returns "" when none matched.
+func Middleware(route func(*http.Request) string, next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		if name := route(r); name != "" {
+			return name
+		}
+		return "unmatched"
+	}))
+}
+
+// Start starts a span called name as a child of the one in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it failed with err when err isn't nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -1,5 +1,157 @@
 package types
 
+import (
+	"arnavsurve/nara-chess/server/pkg/analysis"
+	"arnavsurve/nara-chess/server/pkg/apierror"
+	"arnavsurve/nara-chess/server/pkg/auth"
+	"arnavsurve/nara-chess/server/pkg/chess"
+	"arnavsurve/nara-chess/server/pkg/notation"
+	"arnavsurve/nara-chess/server/pkg/persona"
+	"arnavsurve/nara-chess/server/pkg/utils"
+	"arnavsurve/nara-chess/server/pkg/webhook"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is a request that failed validation because of one field. Field is the
+// field's JSON name, indexed into arrays like "games[2].pupil_side", and Code the
+// apierror code of the problem.
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+func fieldErrorf(field, format string, args ...any) error {
+	return &FieldError{Field: field, Code: apierror.InvalidRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+// ValidationError lists every problem Validate found with a request, in the order the
+// fields were checked.
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// problems collects what is wrong with a request, so Validate reports every invalid field
+// at once rather than stopping at the first.
+type problems struct {
+	fields []*FieldError
+}
+
+// add records err, which is nil when there is no problem. A *FieldError or
+// *ValidationError keeps its fields; any other error is recorded without one.
+func (p *problems) add(err error) {
+	p.nest("", err)
+}
+
+// addf records a problem with field.
+func (p *problems) addf(field, format string, args ...any) {
+	p.add(fieldErrorf(field, format, args...))
+}
+
+// nest records err like add, with its fields inside prefix, such as "games[2]", and its
+// messages led by the prefix.
+func (p *problems) nest(prefix string, err error) {
+	var invalid *ValidationError
+	var field *FieldError
+	switch {
+	case err == nil:
+		return
+	case errors.As(err, &invalid):
+		for _, f := range invalid.Fields {
+			p.nest(prefix, f)
+		}
+		return
+	case !errors.As(err, &field):
+		field = &FieldError{Code: apierror.InvalidRequest, Message: err.Error()}
+	}
+	if prefix != "" {
+		nested := *field
+		nested.Field = prefix
+		if field.Field != "" {
+			nested.Field += "." + field.Field
+		}
+		nested.Message = prefix + ": " + field.Message
+		field = &nested
+	}
+	p.fields = append(p.fields, field)
+}
+
+// fen records a problem with fen, the value of field, unless it is empty or parses under
+// variant. An unknown variant is left to validateVariant and fen read as standard chess.
+func (p *problems) fen(field, fen, variant string) {
+	if fen == "" {
+		return
+	}
+	var err error
+	if v, verr := chess.ParseVariant(variant); variant != "" && verr == nil {
+		_, err = chess.ParseVariantFEN(fen, v)
+	} else {
+		_, err = chess.ParseFEN(fen)
+	}
+	if err != nil {
+		name := field
+		if field == "fen" {
+			name = "FEN"
+		}
+		p.fields = append(p.fields, &FieldError{Field: field, Code: apierror.InvalidFEN, Message: FENMessage(name, err)})
+	}
+}
+
+// move records a problem with move, the value of field, unless it is empty or written like
+// a move. Whether it is legal is left to the handler, which knows the position.
+func (p *problems) move(field, move string) {
+	if move != "" && !notation.WellFormed(move) {
+		p.fields = append(p.fields, &FieldError{Field: field, Code: apierror.IllegalMove, Message: fmt.Sprintf("%s: %q is not a move", field, move)})
+	}
+}
+
+// moves records a problem with each move of field that is not written like a move.
+func (p *problems) moves(field string, moves []string) {
+	for i, m := range moves {
+		p.move(fmt.Sprintf("%s[%d]", field, i), m)
+	}
+}
+
+// err is the problems found as a *ValidationError, or nil when there are none.
+func (p *problems) err() error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: p.fields}
+}
+
+// FENMessage is the client-facing message for a FEN that failed to parse, naming the
+// FEN field at fault. name is the request field the FEN came from.
+func FENMessage(name string, err error) string {
+	var fenErr *chess.FENError
+	switch {
+	case !errors.As(err, &fenErr):
+		return "Invalid " + name
+	case fenErr.Field == chess.FENFields:
+		return fmt.Sprintf("Invalid %s: %s", name, fenErr.Reason)
+	default:
+		return fmt.Sprintf("Invalid %s: %s field %q: %s", name, fenErr.Field, fenErr.Value, fenErr.Reason)
+	}
+}
+
 type ChatMessage struct {
 	Content string `json:"content"`
 	Role    string `json:"role"`
@@ -8,24 +160,2089 @@ type ChatMessage struct {
 type GameStateRequest struct {
 	MoveHistory []string      `json:"move_history"`
 	ChatHistory []ChatMessage `json:"chat_history"`
-	Fen         string        `json:"fen"`
-	WrongMove   string        `json:"wrong_move"`
+	// Fen may be omitted when MoveHistory is given; the server derives the position from
+	// the history either way and rejects a Fen that disagrees with it.
+	Fen string `json:"fen"`
+	// InitialFen is the position MoveHistory starts from, the standard start when empty.
+	InitialFen string `json:"initial_fen,omitempty"`
+	WrongMove  string `json:"wrong_move"`
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Constraint is an optional drilling theme (e.g. "only play developing moves") that
+	// biases the coach's move choice. It trades playing strength for pedagogy: the coach
+	// may deliberately skip the objectively best move to stay on theme.
+	Constraint string `json:"constraint"`
+	// IncludeReasoning asks the coach for its deeper reasoning in the response's Analysis
+	// field. It costs extra output tokens, so it is off by default.
+	IncludeReasoning bool `json:"include_reasoning"`
+	// Difficulty sets the coach's playing strength. Empty plays at full strength.
+	Difficulty Difficulty `json:"difficulty,omitempty"`
+	// Variant names the rules the game is played under, such as "king_of_the_hill". Empty
+	// leaves it to the FEN: standard chess, or Crazyhouse when the FEN has a pocket.
+	Variant string `json:"variant,omitempty"`
+	// Persona names the coach's personality, such as "strict_grandmaster" (see the persona
+	// package). Empty is the default coach.
+	Persona string `json:"persona,omitempty"`
+}
+
+const MaxConstraintLength = 200
+
+func (r *GameStateRequest) Validate() error {
+	var p problems
+	if len(r.MoveHistory) == 0 && r.Fen == "" {
+		p.add(errors.New("Request must contain move_history, fen or a stored game (game_id field)"))
+	}
+	p.fen("fen", r.Fen, r.Variant)
+	p.fen("initial_fen", r.InitialFen, r.Variant)
+	p.moves("move_history", r.MoveHistory)
+	if len(r.Constraint) > MaxConstraintLength {
+		p.addf("constraint", "constraint must be at most %d characters", MaxConstraintLength)
+	}
+	_, err := r.Difficulty.Elo()
+	p.add(err)
+	p.add(validatePersona(r.Persona))
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+// validatePersona checks an optional persona name.
+func validatePersona(name string) error {
+	if err := persona.Validate(name); err != nil {
+		return fieldErrorf("persona", "%v", err)
+	}
+	return nil
+}
+
+// validateVariant checks an optional variant name.
+func validateVariant(variant string) error {
+	if _, err := chess.ParseVariant(variant); err != nil {
+		names := make([]string, len(chess.Variants))
+		for i, v := range chess.Variants {
+			names[i] = strconv.Quote(v.String())
+		}
+		return fieldErrorf("variant", "variant must be one of %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// Named difficulty levels and the Elo rating each stands for.
+const (
+	DifficultyBeginner     = "beginner"
+	DifficultyIntermediate = "intermediate"
+	DifficultyAdvanced     = "advanced"
+)
+
+var difficultyElo = map[string]int{
+	DifficultyBeginner:     800,
+	DifficultyIntermediate: 1400,
+	DifficultyAdvanced:     2000,
+}
+
+// Bounds on a numeric difficulty.
+const (
+	MinDifficultyElo = 400
+	MaxDifficultyElo = 3000
+)
+
+// Difficulty is a named level or an Elo rating. It decodes from a JSON string such as
+// "beginner" or "1200", or from a bare number.
+type Difficulty string
+
+func (d *Difficulty) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		*d = Difficulty(n.String())
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*d = Difficulty(s)
+	return nil
+}
+
+// Elo returns the rating the difficulty stands for, or 0 for full strength when it is
+// empty.
+func (d Difficulty) Elo() (int, error) {
+	if d == "" {
+		return 0, nil
+	}
+	if elo, ok := difficultyElo[strings.ToLower(string(d))]; ok {
+		return elo, nil
+	}
+	elo, err := strconv.Atoi(string(d))
+	if err != nil || elo < MinDifficultyElo || elo > MaxDifficultyElo {
+		return 0, fieldErrorf("difficulty", "difficulty must be %s, %s, %s or an Elo rating between %d and %d",
+			DifficultyBeginner, DifficultyIntermediate, DifficultyAdvanced, MinDifficultyElo, MaxDifficultyElo)
+	}
+	return elo, nil
+}
+
+// ResponseMeta carries bookkeeping about how a response was produced.
+type ResponseMeta struct {
+	ModelCalls int `json:"model_calls"`
+	// Provider and Model name the backend that answered the last model call, which may be
+	// a fallback when the primary model failed.
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// CacheHit is set when the response was served from a cache, such as one filled by /ponder.
+	CacheHit bool `json:"cache_hit,omitempty"`
+}
+
+// ArrowGroups sorts coaching arrows by purpose. Every grouped arrow is also included in the
+// flat arrows field of the enclosing response.
+type ArrowGroups struct {
+	Threats  [][2]string `json:"threats,omitempty"`
+	Plans    [][2]string `json:"plans,omitempty"`
+	Defenses [][2]string `json:"defenses,omitempty"`
 }
 
 type GameStateResponse struct {
-	Comment string      `json:"comment"`
-	Move    string      `json:"move"`
-	Arrows  [][2]string `json:"arrows"`
-	Title   string      `json:"title"`
+	Comment string `json:"comment"`
+	// Analysis is the coach's detailed reasoning, present only when include_reasoning was
+	// set. Clients typically hide it behind a "show reasoning" toggle.
+	Analysis    string       `json:"analysis,omitempty"`
+	Move        string       `json:"move"`
+	Arrows      [][2]string  `json:"arrows"`
+	ArrowGroups *ArrowGroups `json:"arrow_groups,omitempty"`
+	Title       string       `json:"title"`
+	// ECO and OpeningName classify the game including the coach's move against the
+	// server's opening table. They are empty until the game reaches a position in the table.
+	ECO         string `json:"eco,omitempty"`
+	OpeningName string `json:"opening_name,omitempty"`
+	// Source is "model" when the coach chose the move, "forced" when the server played an
+	// only move or obvious recapture without consulting the model, "hybrid" when an
+	// external engine chose the move and the model explained it, and "engine" when the
+	// local engine stood in for an unavailable model.
+	Source string `json:"source,omitempty"`
+	// Warnings lists corrections the server applied to the request.
+	Warnings []string `json:"warnings,omitempty"`
+	// PupilMate is set when the pupil has a forced mate after the coach's move.
+	PupilMate *MateHint `json:"pupil_mate,omitempty"`
+	// PupilMove grades the pupil's last move (brilliant, best, good, inaccuracy, mistake or
+	// blunder) by the local engine's evaluation before and after it. It needs move_history.
+	PupilMove *analysis.MoveAnalysis `json:"pupil_move,omitempty"`
+	// GameOver is set once the game has ended, either with the coach's move or before it.
+	// In the latter case Move is empty and the coach only sums up the game.
+	GameOver *GameOver     `json:"game_over,omitempty"`
+	Meta     *ResponseMeta `json:"meta,omitempty"`
+}
+
+// Perspectives accepted by analyze_for. Analysis is otherwise given for the side implied by
+// the request; these override it without changing whose move it is.
+const (
+	AnalyzeForWhite      = "white"
+	AnalyzeForBlack      = "black"
+	AnalyzeForSideToMove = "side_to_move"
+)
+
+func validateAnalyzeFor(analyzeFor string) error {
+	switch analyzeFor {
+	case "", AnalyzeForWhite, AnalyzeForBlack, AnalyzeForSideToMove:
+		return nil
+	}
+	return fieldErrorf("analyze_for", "analyze_for must be %q, %q or %q", AnalyzeForWhite, AnalyzeForBlack, AnalyzeForSideToMove)
 }
 
 type ChatMessageRequest struct {
 	MessageHistory []ChatMessage    `json:"message_history"`
 	GameState      GameStateRequest `json:"game_state"`
 	PlayerSide     string           `json:"player_side"`
+	AnalyzeFor     string           `json:"analyze_for"`
+	// SessionID names the conversation, so the summary of its older messages is kept
+	// between requests; the stored game of game_state.game_id names it when omitted.
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// MaxSessionIDLength caps the length of a chat session ID.
+const MaxSessionIDLength = 128
+
+func (r *ChatMessageRequest) Validate() error {
+	var p problems
+	state := &r.GameState
+	if len(state.MoveHistory) == 0 && state.Fen == "" {
+		p.addf("game_state.fen", "Request must contain the current board state FEN (fen field), its move_history or a stored game (game_id field)")
+	}
+	p.fen("game_state.fen", state.Fen, state.Variant)
+	p.fen("game_state.initial_fen", state.InitialFen, state.Variant)
+	p.moves("game_state.move_history", state.MoveHistory)
+	p.nest("game_state", validateVariant(state.Variant))
+	p.nest("game_state", validatePersona(state.Persona))
+	switch r.PlayerSide {
+	case "", "white", "black":
+	default:
+		p.addf("player_side", `player_side must be "white" or "black"`)
+	}
+	p.add(validateAnalyzeFor(r.AnalyzeFor))
+	if len(r.SessionID) > MaxSessionIDLength {
+		p.addf("session_id", "session_id must be at most %d characters", MaxSessionIDLength)
+	}
+	return p.err()
 }
 
 type ChatMessageResponse struct {
-	Response string      `json:"response"`
-	Arrows   [][2]string `json:"arrows"`
+	Response    string        `json:"response"`
+	Arrows      [][2]string   `json:"arrows"`
+	ArrowGroups *ArrowGroups  `json:"arrow_groups,omitempty"`
+	Meta        *ResponseMeta `json:"meta,omitempty"`
+}
+
+// ChatStreamToken is the payload of a "token" event on /chat/stream: the next piece of the
+// coach's reply text.
+type ChatStreamToken struct {
+	Text string `json:"text"`
+}
+
+// ChatStreamError is the payload of an "error" event on /chat/stream. Status is the HTTP
+// status /chat would have answered with.
+type ChatStreamError struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+}
+
+type MoveInfo struct {
+	San             string `json:"san"`
+	Uci             string `json:"uci"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	GivesCheck      bool   `json:"gives_check"`
+	DiscoveredCheck bool   `json:"discovered_check"`
+	DoubleCheck     bool   `json:"double_check"`
+}
+
+type ValidateMoveRequest struct {
+	Fen  string `json:"fen"`
+	Move string `json:"move"`
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (r *ValidateMoveRequest) Validate() error {
+	var p problems
+	if r.Fen == "" {
+		p.addf("fen", "Request must contain the current board state FEN (fen field) or a stored game (game_id field)")
+	}
+	p.fen("fen", r.Fen, r.Variant)
+	if r.Move == "" {
+		p.addf("move", "Request must contain a move in SAN (move field)")
+	}
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+type ValidateMoveResponse struct {
+	Legal    bool      `json:"legal"`
+	Reason   string    `json:"reason,omitempty"`
+	Move     *MoveInfo `json:"move,omitempty"`
+	FenAfter string    `json:"fen_after,omitempty"`
+	// StatusAfter is set when the move ends the game.
+	StatusAfter *GameStatus `json:"status_after,omitempty"`
+}
+
+const MaxValidateFENs = 500
+
+type ValidateFENsRequest struct {
+	Fens []string `json:"fens"`
+}
+
+func (r *ValidateFENsRequest) Validate() error {
+	var p problems
+	if len(r.Fens) == 0 {
+		p.addf("fens", "Request must contain at least one FEN (fens field)")
+	}
+	if len(r.Fens) > MaxValidateFENs {
+		p.addf("fens", "Request may contain at most %d FENs", MaxValidateFENs)
+	}
+	return p.err()
+}
+
+// FENValidation is the result for one FEN. Fen is the normalized form of a valid FEN.
+type FENValidation struct {
+	Index   int      `json:"index"`
+	Valid   bool     `json:"valid"`
+	Fen     string   `json:"fen,omitempty"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+type ValidateFENsResponse struct {
+	Results []FENValidation `json:"results"`
+	Valid   int             `json:"valid"`
+	Invalid int             `json:"invalid"`
+}
+
+type LegalMovesRequest struct {
+	Fen string `json:"fen"`
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (r *LegalMovesRequest) Validate() error {
+	var p problems
+	if r.Fen == "" {
+		p.addf("fen", "Request must contain the current board state FEN (fen field) or a stored game (game_id field)")
+	}
+	p.fen("fen", r.Fen, r.Variant)
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+// GameStatus explains why a game is over. Material names the kind of insufficient
+// material ("KvK", "KBvK", "KNvK" or "KBvKB") when Reason is "insufficient_material".
+type GameStatus struct {
+	Result   string `json:"result"`
+	Reason   string `json:"reason"`
+	Material string `json:"material,omitempty"`
+}
+
+// GameOver reports a finished game to the pupil. Winner is "white" or "black" after
+// checkmate and empty after a draw; Summary is the coach's closing word on the game.
+type GameOver struct {
+	GameStatus
+	Winner  string `json:"winner,omitempty"`
+	Summary string `json:"summary"`
+}
+
+type LegalMovesResponse struct {
+	Moves  []MoveInfo  `json:"moves"`
+	Status *GameStatus `json:"status,omitempty"`
+}
+
+type PositionsFromHistoryRequest struct {
+	MoveHistory []string `json:"move_history"`
+	InitialFen  string   `json:"initial_fen"`
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (r *PositionsFromHistoryRequest) Validate() error {
+	var p problems
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+type PositionsFromHistoryResponse struct {
+	Fens        []string `json:"fens"`
+	IllegalPly  int      `json:"illegal_ply,omitempty"`
+	IllegalMove string   `json:"illegal_move,omitempty"`
+	Reason      string   `json:"reason,omitempty"`
+	// Status is set when the last position reached ends the game.
+	Status *GameStatus `json:"status,omitempty"`
+}
+
+type GameRecord struct {
+	MoveHistory []string `json:"move_history"`
+	InitialFen  string   `json:"initial_fen"`
+	PupilSide   string   `json:"pupil_side"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (g *GameRecord) Validate() error {
+	var p problems
+	if len(g.MoveHistory) == 0 {
+		p.addf("move_history", "each game must contain a move_history")
+	}
+	p.fen("initial_fen", g.InitialFen, g.Variant)
+	p.add(validateVariant(g.Variant))
+	p.moves("move_history", g.MoveHistory)
+	if g.PupilSide != "white" && g.PupilSide != "black" {
+		p.addf("pupil_side", `pupil_side must be "white" or "black"`)
+	}
+	return p.err()
+}
+
+const MaxStudyPlanGames = 20
+
+type StudyPlanRequest struct {
+	Games []GameRecord `json:"games"`
+}
+
+func (r *StudyPlanRequest) Validate() error {
+	var p problems
+	if len(r.Games) == 0 {
+		p.addf("games", "Request must contain at least one game (games field)")
+	}
+	if len(r.Games) > MaxStudyPlanGames {
+		p.addf("games", "Request may contain at most %d games", MaxStudyPlanGames)
+	}
+	for i := range r.Games {
+		p.nest(fmt.Sprintf("games[%d]", i), r.Games[i].Validate())
+	}
+	return p.err()
+}
+
+type StudyTheme struct {
+	Name   string   `json:"name"`
+	Reason string   `json:"reason"`
+	Drills []string `json:"drills"`
+}
+
+type OpeningReview struct {
+	Moves  string `json:"moves"`
+	Reason string `json:"reason"`
+}
+
+type StudyPlanResponse struct {
+	Summary          string               `json:"summary"`
+	Themes           []StudyTheme         `json:"themes"`
+	PuzzleMotifs     []string             `json:"puzzle_motifs"`
+	OpeningsToReview []OpeningReview      `json:"openings_to_review"`
+	Stats            *analysis.Weaknesses `json:"stats"`
+	Meta             *ResponseMeta        `json:"meta,omitempty"`
+}
+
+const (
+	DefaultTeachingLineSteps = 6
+	MaxTeachingLineSteps     = 10
+)
+
+type TeachingLineRequest struct {
+	Fen      string `json:"fen"`
+	Theme    string `json:"theme"`
+	MaxSteps int    `json:"max_steps"`
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (r *TeachingLineRequest) Validate() error {
+	var p problems
+	if r.Fen == "" {
+		p.addf("fen", "Request must contain the current board state FEN (fen field) or a stored game (game_id field)")
+	}
+	p.fen("fen", r.Fen, r.Variant)
+	if r.MaxSteps < 0 || r.MaxSteps > MaxTeachingLineSteps {
+		p.addf("max_steps", "max_steps must be between 1 and %d", MaxTeachingLineSteps)
+	}
+	if r.MaxSteps == 0 {
+		r.MaxSteps = DefaultTeachingLineSteps
+	}
+	if len(r.Theme) > MaxConstraintLength {
+		p.addf("theme", "theme must be at most %d characters", MaxConstraintLength)
+	}
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+type TeachingLineStep struct {
+	Move    string      `json:"move"`
+	Comment string      `json:"comment"`
+	Arrows  [][2]string `json:"arrows"`
+	Fen     string      `json:"fen"`
+}
+
+type TeachingLineResponse struct {
+	Title string             `json:"title"`
+	Steps []TeachingLineStep `json:"steps"`
+	Meta  *ResponseMeta      `json:"meta,omitempty"`
+}
+
+type NewGameRequest struct {
+	InitialFen string `json:"initial_fen"`
+	// Variant names the rules the game is played under; empty is standard chess.
+	Variant string `json:"variant,omitempty"`
+	// Odds starts a handicap game from the standard position with material the coach gives
+	// up, such as "queen" or "knight+pawn" (see chess.Odds). It cannot be combined with
+	// InitialFen.
+	Odds string `json:"odds,omitempty"`
+	// PupilSide is the side the pupil plays in an odds game, "white" by default; the coach
+	// gives odds from the other side.
+	PupilSide string `json:"pupil_side,omitempty"`
+	// Persona names the coach's personality for the whole game; empty is the default coach.
+	Persona string `json:"persona,omitempty"`
+	// UserID names the pupil playing the game. Their profile adapts the coaching, and the
+	// game is folded into it once it is over. Empty keeps the game anonymous.
+	UserID string `json:"user_id,omitempty"`
+}
+
+// MaxUserIDLength caps the length of a pupil's user ID.
+const MaxUserIDLength = 128
+
+func (r *NewGameRequest) Validate() error {
+	var p problems
+	if r.Odds != "" {
+		if r.InitialFen != "" {
+			p.addf("odds", "odds and initial_fen cannot be combined")
+		}
+		if _, err := chess.OddsPosition(r.Odds, chess.White); err != nil {
+			p.addf("odds", "%v", err)
+		}
+	}
+	p.fen("initial_fen", r.InitialFen, r.Variant)
+	switch r.PupilSide {
+	case "", "white", "black":
+	default:
+		p.addf("pupil_side", `pupil_side must be "white" or "black"`)
+	}
+	p.add(validatePersona(r.Persona))
+	if len(r.UserID) > MaxUserIDLength {
+		p.addf("user_id", "user_id must be at most %d characters", MaxUserIDLength)
+	}
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+// GameSummary is one entry of the game list. Thumbnail is a compact drawing of the current
+// position, included only when the client asks for it.
+type GameSummary struct {
+	GameID    string    `json:"game_id"`
+	Fen       string    `json:"fen"`
+	Moves     int       `json:"moves"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Thumbnail string    `json:"thumbnail,omitempty"`
+}
+
+type GameListResponse struct {
+	Games []GameSummary `json:"games"`
+}
+
+// ProviderHealth is the result of pinging one model provider.
+type ProviderHealth struct {
+	Provider string `json:"provider"`
+	Healthy  bool   `json:"healthy"`
+	Error    string `json:"error,omitempty"`
+}
+
+type ProviderHealthResponse struct {
+	Providers []ProviderHealth `json:"providers"`
+}
+
+type LivenessResponse struct {
+	Status string `json:"status"`
+}
+
+// ReadinessCheck is the outcome of one of /readyz's checks.
+type ReadinessCheck struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+type GameMoveRequest struct {
+	Move            string `json:"move"`
+	ExpectedVersion int    `json:"expected_version"`
+	// Comment, Title and Arrows are optional coaching stored with the move, typically
+	// copied from the coach's /generateMove reply.
+	Comment string      `json:"comment,omitempty"`
+	Title   string      `json:"title,omitempty"`
+	Arrows  [][2]string `json:"arrows,omitempty"`
+}
+
+func (r *GameMoveRequest) Validate() error {
+	var p problems
+	if r.Move == "" {
+		p.addf("move", "Request must contain a move in SAN (move field)")
+	}
+	p.move("move", r.Move)
+	if r.ExpectedVersion < 1 {
+		p.addf("expected_version", "Request must contain the game version the move was made against (expected_version field)")
+	}
+	for _, a := range r.Arrows {
+		if !utils.IsValidSquare(a[0]) || !utils.IsValidSquare(a[1]) {
+			p.addf("arrows", "arrows: invalid square in %v", a)
+		}
+	}
+	return p.err()
+}
+
+// MaxTakebackPlies is how far one takeback may rewind: the pupil's move and the coach's
+// reply to it.
+const MaxTakebackPlies = 2
+
+// TakebackRequest rewinds a stored game. Plies defaults to 1.
+type TakebackRequest struct {
+	Plies           int `json:"plies"`
+	ExpectedVersion int `json:"expected_version"`
+}
+
+func (r *TakebackRequest) Validate() error {
+	var p problems
+	if r.Plies == 0 {
+		r.Plies = 1
+	}
+	if r.Plies < 1 || r.Plies > MaxTakebackPlies {
+		p.addf("plies", "plies must be between 1 and %d", MaxTakebackPlies)
+	}
+	if r.ExpectedVersion < 1 {
+		p.addf("expected_version", "Request must contain the game version the takeback was made against (expected_version field)")
+	}
+	return p.err()
+}
+
+// ResignRequest resigns a stored game on the pupil's behalf.
+type ResignRequest struct {
+	PupilSide       string `json:"pupil_side"`
+	ExpectedVersion int    `json:"expected_version"`
+}
+
+func (r *ResignRequest) Validate() error {
+	return validateGameAction(r.PupilSide, r.ExpectedVersion)
+}
+
+type ResignResponse struct {
+	GameOver *GameOver `json:"game_over"`
+	// Version is the closed game's version.
+	Version int           `json:"version"`
+	Meta    *ResponseMeta `json:"meta,omitempty"`
+}
+
+// DrawOfferRequest offers the coach a draw in a stored game on the pupil's behalf.
+type DrawOfferRequest struct {
+	PupilSide       string `json:"pupil_side"`
+	ExpectedVersion int    `json:"expected_version"`
+}
+
+func (r *DrawOfferRequest) Validate() error {
+	return validateGameAction(r.PupilSide, r.ExpectedVersion)
+}
+
+// DrawOfferResponse is the coach's answer to a draw offer. Score is the engine's evaluation
+// in centipawns from the coach's point of view, which decided the answer. GameOver is set
+// when the offer was accepted, closing the game.
+type DrawOfferResponse struct {
+	Accepted bool          `json:"accepted"`
+	Comment  string        `json:"comment"`
+	Score    int           `json:"score"`
+	Display  string        `json:"display"`
+	GameOver *GameOver     `json:"game_over,omitempty"`
+	Version  int           `json:"version"`
+	Meta     *ResponseMeta `json:"meta,omitempty"`
+}
+
+func validateGameAction(pupilSide string, expectedVersion int) error {
+	var p problems
+	if pupilSide != "white" && pupilSide != "black" {
+		p.addf("pupil_side", `pupil_side must be "white" or "black"`)
+	}
+	if expectedVersion < 1 {
+		p.addf("expected_version", "Request must contain the game version the request was made against (expected_version field)")
+	}
+	return p.err()
+}
+
+const (
+	DefaultSearchDepth = 4
+	MaxSearchDepth     = 6
+)
+
+type PrincipalVariationRequest struct {
+	Fen        string `json:"fen"`
+	Depth      int    `json:"depth"`
+	AnalyzeFor string `json:"analyze_for"` // defaults to white
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (r *PrincipalVariationRequest) Validate() error {
+	var p problems
+	if r.Fen == "" {
+		p.addf("fen", "Request must contain the current board state FEN (fen field) or a stored game (game_id field)")
+	}
+	p.fen("fen", r.Fen, r.Variant)
+	if r.Depth < 0 || r.Depth > MaxSearchDepth {
+		p.addf("depth", "depth must be between 1 and %d", MaxSearchDepth)
+	}
+	if r.Depth == 0 {
+		r.Depth = DefaultSearchDepth
+	}
+	p.add(validateAnalyzeFor(r.AnalyzeFor))
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+// PlyEvaluation is one move of a principal variation. Score is in centipawns from the
+// response's perspective; Display renders it as "+0.35" or, for forced mates, "#3".
+type PlyEvaluation struct {
+	Ply     int    `json:"ply"`
+	San     string `json:"san"`
+	Uci     string `json:"uci"`
+	Fen     string `json:"fen"`
+	Score   int    `json:"score"`
+	Mate    int    `json:"mate,omitempty"`
+	Display string `json:"display"`
+}
+
+type PrincipalVariationResponse struct {
+	// Perspective is the side the scores are given for, "white" or "black".
+	Perspective string          `json:"perspective"`
+	BestMove    string          `json:"best_move"`
+	Score       int             `json:"score"`
+	Mate        int             `json:"mate,omitempty"`
+	Display     string          `json:"display"`
+	Depth       int             `json:"depth"`
+	PV          []PlyEvaluation `json:"pv"`
+}
+
+type EvaluateRequest struct {
+	Fen string `json:"fen"`
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (r *EvaluateRequest) Validate() error {
+	var p problems
+	if r.Fen == "" {
+		p.addf("fen", "Request must contain the current board state FEN (fen field) or a stored game (game_id field)")
+	}
+	p.fen("fen", r.Fen, r.Variant)
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+// EvaluateResponse is the data for an evaluation bar. Scores are from White's point of
+// view; WhiteWinPercent is White's expected score, for the bar's fill.
+type EvaluateResponse struct {
+	Fen             string   `json:"fen"`
+	Score           int      `json:"score"`
+	Mate            int      `json:"mate,omitempty"`
+	Display         string   `json:"display"`
+	WhiteWinPercent float64  `json:"white_win_percent"`
+	BestMove        string   `json:"best_move"`
+	BestLine        []string `json:"best_line"`
+	Depth           int      `json:"depth"`
+	// Summary is one sentence on who stands better and why, for a tooltip.
+	Summary string        `json:"summary"`
+	Meta    *ResponseMeta `json:"meta,omitempty"`
+}
+
+// CandidateMoveCount is how many moves /candidates compares.
+const CandidateMoveCount = 3
+
+type CandidatesRequest struct {
+	Fen string `json:"fen"`
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (r *CandidatesRequest) Validate() error {
+	var p problems
+	if r.Fen == "" {
+		p.addf("fen", "Request must contain the current board state FEN (fen field) or a stored game (game_id field)")
+	}
+	p.fen("fen", r.Fen, r.Variant)
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+// CandidateMove is one of the engine's strongest moves with the plan behind it. Score is
+// in centipawns for the side to move.
+type CandidateMove struct {
+	Rank    int         `json:"rank"`
+	Move    string      `json:"move"`
+	Uci     string      `json:"uci"`
+	Score   int         `json:"score"`
+	Mate    int         `json:"mate,omitempty"`
+	Display string      `json:"display"`
+	Line    []string    `json:"line"`
+	Plan    string      `json:"plan"`
+	Arrows  [][2]string `json:"arrows"`
+}
+
+type CandidatesResponse struct {
+	Side       string          `json:"side"`
+	Candidates []CandidateMove `json:"candidates"`
+	Meta       *ResponseMeta   `json:"meta,omitempty"`
+}
+
+// MaxExploreMoves bounds the hypothetical line /explore replays.
+const MaxExploreMoves = 20
+
+type ExploreRequest struct {
+	Fen string `json:"fen"`
+	// Moves is the hypothetical line in SAN, starting with the side to move in Fen.
+	Moves []string `json:"moves"`
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (r *ExploreRequest) Validate() error {
+	var p problems
+	if r.Fen == "" {
+		p.addf("fen", "Request must contain the current board state FEN (fen field) or a stored game (game_id field)")
+	}
+	p.fen("fen", r.Fen, r.Variant)
+	if len(r.Moves) == 0 {
+		p.addf("moves", "Request must contain the line to explore (moves field)")
+	}
+	if len(r.Moves) > MaxExploreMoves {
+		p.addf("moves", "moves must hold at most %d moves", MaxExploreMoves)
+	}
+	p.moves("moves", r.Moves)
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+// Refutation is how the opponent punishes the first unsound move of an explored line.
+type Refutation struct {
+	Ply  int    `json:"ply"`
+	Move string `json:"move"`
+	// BetterMove is what the engine would have played instead of Move.
+	BetterMove string `json:"better_move"`
+	Reply      string `json:"reply"`
+	// Line starts with Reply.
+	Line []string `json:"line"`
+	Loss int      `json:"centipawn_loss"`
+}
+
+// ExploreResponse analyzes the position a hypothetical line leads to. Score is from White's
+// point of view.
+type ExploreResponse struct {
+	Fen      string                  `json:"fen"`
+	Moves    []analysis.MoveAnalysis `json:"moves"`
+	Score    int                     `json:"score"`
+	Mate     int                     `json:"mate,omitempty"`
+	Display  string                  `json:"display"`
+	BestLine []string                `json:"best_line"`
+	// Sound is false when a move of the line is a mistake or worse; Refutation then shows
+	// the first one punished.
+	Sound      bool          `json:"sound"`
+	Refutation *Refutation   `json:"refutation,omitempty"`
+	Status     *GameStatus   `json:"status,omitempty"`
+	Analysis   string        `json:"analysis"`
+	Meta       *ResponseMeta `json:"meta,omitempty"`
+}
+
+type ThreatsRequest struct {
+	Fen string `json:"fen"`
+	// Side is whose threats to list: "white", "black" or "side_to_move". It defaults to
+	// the side that just moved, whose threats the side to move has to answer.
+	Side string `json:"side"`
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (r *ThreatsRequest) Validate() error {
+	var p problems
+	if r.Fen == "" {
+		p.addf("fen", "Request must contain the current board state FEN (fen field) or a stored game (game_id field)")
+	}
+	p.fen("fen", r.Fen, r.Variant)
+	switch r.Side {
+	case "", AnalyzeForWhite, AnalyzeForBlack, AnalyzeForSideToMove:
+	default:
+		p.addf("side", "side must be %q, %q or %q", AnalyzeForWhite, AnalyzeForBlack, AnalyzeForSideToMove)
+	}
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+type ThreatsResponse struct {
+	Side    string            `json:"side"`
+	Threats []analysis.Threat `json:"threats"`
+}
+
+type DevelopmentSuggestionRequest struct {
+	Fen string `json:"fen"`
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (r *DevelopmentSuggestionRequest) Validate() error {
+	var p problems
+	if r.Fen == "" {
+		p.addf("fen", "Request must contain the current board state FEN (fen field) or a stored game (game_id field)")
+	}
+	p.fen("fen", r.Fen, r.Variant)
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+type DevelopmentSuggestionResponse struct {
+	OpeningPhase bool                  `json:"opening_phase"`
+	Comment      string                `json:"comment"`
+	Suggestions  []analysis.Suggestion `json:"suggestions"`
+	Arrows       [][2]string           `json:"arrows"`
+	Meta         *ResponseMeta         `json:"meta,omitempty"`
+}
+
+const (
+	DefaultPonderCandidates = 3
+	MaxPonderCandidates     = 5
+)
+
+type PonderRequest struct {
+	Fen           string        `json:"fen"`
+	MoveHistory   []string      `json:"move_history"`
+	InitialFen    string        `json:"initial_fen,omitempty"`
+	ChatHistory   []ChatMessage `json:"chat_history"`
+	Constraint    string        `json:"constraint"`
+	Difficulty    Difficulty    `json:"difficulty,omitempty"`
+	MaxCandidates int           `json:"max_candidates"`
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+	// Persona is as in GameStateRequest.
+	Persona string `json:"persona,omitempty"`
+}
+
+func (r *PonderRequest) Validate() error {
+	var p problems
+	if len(r.MoveHistory) == 0 && r.Fen == "" {
+		p.addf("fen", "Request must contain the current board state FEN (fen field), its move_history or a stored game (game_id field)")
+	}
+	p.fen("fen", r.Fen, r.Variant)
+	p.fen("initial_fen", r.InitialFen, r.Variant)
+	p.moves("move_history", r.MoveHistory)
+	if r.MaxCandidates < 0 || r.MaxCandidates > MaxPonderCandidates {
+		p.addf("max_candidates", "max_candidates must be between 1 and %d", MaxPonderCandidates)
+	}
+	if r.MaxCandidates == 0 {
+		r.MaxCandidates = DefaultPonderCandidates
+	}
+	if len(r.Constraint) > MaxConstraintLength {
+		p.addf("constraint", "constraint must be at most %d characters", MaxConstraintLength)
+	}
+	_, err := r.Difficulty.Elo()
+	p.add(err)
+	p.add(validatePersona(r.Persona))
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+// PonderedMove is a likely pupil move whose coach reply has been cached.
+type PonderedMove struct {
+	Move  string `json:"move"`
+	Fen   string `json:"fen"`
+	Ready bool   `json:"ready"` // false when the reply could not be computed in time
+}
+
+type PonderResponse struct {
+	Candidates []PonderedMove `json:"candidates"`
+	Meta       *ResponseMeta  `json:"meta,omitempty"`
+}
+
+// MateHint tells the pupil a forced mate is available. Move and Arrows give the solution
+// away and are only filled in when it was asked for.
+type MateHint struct {
+	MateIn int         `json:"mate_in"`
+	Hint   string      `json:"hint"`
+	Move   string      `json:"move,omitempty"`
+	Arrows [][2]string `json:"arrows,omitempty"`
+}
+
+type MateHintRequest struct {
+	Fen    string `json:"fen"`
+	Reveal bool   `json:"reveal"`
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (r *MateHintRequest) Validate() error {
+	var p problems
+	if r.Fen == "" {
+		p.addf("fen", "Request must contain the current board state FEN (fen field) or a stored game (game_id field)")
+	}
+	p.fen("fen", r.Fen, r.Variant)
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+type MateHintResponse struct {
+	Found bool      `json:"found"`
+	Mate  *MateHint `json:"mate,omitempty"`
+}
+
+// Hint levels accepted by /hint, each giving away more than the last.
+const (
+	HintLevelTheme = 1 // a nudge about the theme of the best move
+	HintLevelPiece = 2 // which piece to move
+	HintLevelMove  = 3 // the move itself, explained
+)
+
+type HintRequest struct {
+	Fen   string `json:"fen"`
+	Level int    `json:"level"` // defaults to HintLevelTheme
+	// GameID names a stored game whose position replaces the request's own.
+	GameID string `json:"game_id,omitempty"`
+	// Variant is as in GameStateRequest.
+	Variant string `json:"variant,omitempty"`
+}
+
+func (r *HintRequest) Validate() error {
+	var p problems
+	if r.Fen == "" {
+		p.addf("fen", "Request must contain the current board state FEN (fen field) or a stored game (game_id field)")
+	}
+	p.fen("fen", r.Fen, r.Variant)
+	if r.Level < 0 || r.Level > HintLevelMove {
+		p.addf("level", "level must be between %d and %d", HintLevelTheme, HintLevelMove)
+	}
+	if r.Level == 0 {
+		r.Level = HintLevelTheme
+	}
+	p.add(validateVariant(r.Variant))
+	return p.err()
+}
+
+// HintResponse is a hint toward the local engine's best move. Theme is always given; Piece
+// and Square from HintLevelPiece; Move, Uci and Arrows only at HintLevelMove.
+type HintResponse struct {
+	Level  int           `json:"level"`
+	Hint   string        `json:"hint"`
+	Theme  string        `json:"theme"`
+	Piece  string        `json:"piece,omitempty"`
+	Square string        `json:"square,omitempty"`
+	Move   string        `json:"move,omitempty"`
+	Uci    string        `json:"uci,omitempty"`
+	Arrows [][2]string   `json:"arrows,omitempty"`
+	Meta   *ResponseMeta `json:"meta,omitempty"`
+}
+
+const (
+	ImportFormatPGN           = "pgn"
+	ImportFormatLichessNDJSON = "lichess_ndjson"
+	MaxImportGames            = 200
+)
+
+type ImportGamesRequest struct {
+	// Format is "pgn" (Chess.com and Lichess PGN exports) or "lichess_ndjson". When empty
+	// it is detected from the data.
+	Format string `json:"format"`
+	Data   string `json:"data"`
+}
+
+func (r *ImportGamesRequest) Validate() error {
+	var p problems
+	if strings.TrimSpace(r.Data) == "" {
+		p.addf("data", "Request must contain the exported games (data field)")
+	}
+	switch r.Format {
+	case "", ImportFormatPGN, ImportFormatLichessNDJSON:
+	default:
+		p.addf("format", "format must be %q or %q", ImportFormatPGN, ImportFormatLichessNDJSON)
+	}
+	return p.err()
+}
+
+// ImportedGame is one game from an import, replayed into normalized SAN and the FEN after
+// every ply. A game with an illegal move keeps the plies before it.
+type ImportedGame struct {
+	Tags        map[string]string `json:"tags"`
+	InitialFen  string            `json:"initial_fen"`
+	MoveHistory []string          `json:"move_history"`
+	Fens        []string          `json:"fens"`
+	Result      string            `json:"result,omitempty"`
+	IllegalPly  int               `json:"illegal_ply,omitempty"`
+	IllegalMove string            `json:"illegal_move,omitempty"`
+	Reason      string            `json:"reason,omitempty"`
+}
+
+type ImportGamesResponse struct {
+	Format string         `json:"format"`
+	Games  []ImportedGame `json:"games"`
+}
+
+// Limits of an account import: how many recent games are fetched by default and at most.
+const (
+	DefaultAccountImportGames = 20
+	MaxAccountImportGames     = 100
+)
+
+// AccountImportResponse reports the games fetched from a pupil's account on another site.
+// New games are stored and, when puzzles or profiles are kept, Queued for review in the
+// background; Duplicates were imported before and Skipped games are unfinished or contain
+// an illegal move.
+type AccountImportResponse struct {
+	Site       string              `json:"site"`
+	Username   string              `json:"username"`
+	UserID     string              `json:"user_id"`
+	Fetched    int                 `json:"fetched"`
+	Imported   int                 `json:"imported"`
+	Duplicates int                 `json:"duplicates"`
+	Skipped    int                 `json:"skipped"`
+	Queued     int                 `json:"queued"`
+	Games      []AccountImportGame `json:"games"`
+}
+
+// AccountImportGame is one newly stored game of an account import.
+type AccountImportGame struct {
+	GameID    string `json:"game_id"`
+	Source    string `json:"source"`
+	White     string `json:"white"`
+	Black     string `json:"black"`
+	Date      string `json:"date,omitempty"`
+	Result    string `json:"result"`
+	PupilSide string `json:"pupil_side"`
+	Plies     int    `json:"plies"`
+}
+
+// MaxReviewPlies bounds /analyze/pgn so a whole game is annotated in one model call.
+const MaxReviewPlies = 300
+
+type AnalyzePGNRequest struct {
+	// Pgn holds exactly one game; tag pairs, comments and variations are allowed.
+	Pgn string `json:"pgn"`
+}
+
+func (r *AnalyzePGNRequest) Validate() error {
+	var p problems
+	if strings.TrimSpace(r.Pgn) == "" {
+		p.addf("pgn", "Request must contain a PGN game (pgn field)")
+	}
+	return p.err()
+}
+
+// ReviewedMove is one ply of a reviewed game: the local engine's verdict and the coach's
+// annotation.
+type ReviewedMove struct {
+	Ply            int    `json:"ply"`
+	San            string `json:"san"`
+	Side           string `json:"side"`
+	Fen            string `json:"fen"` // after the move
+	Classification string `json:"classification"`
+	BestMove       string `json:"best_move"`
+	Loss           int    `json:"centipawn_loss"`
+	Motif          string `json:"motif,omitempty"`
+	// PgnComment is the comment that followed the move in the submitted PGN.
+	PgnComment string `json:"pgn_comment,omitempty"`
+	Comment    string `json:"comment"`
+}
+
+// KeyMomentCount is how many turning points /game/{id}/report explains.
+const KeyMomentCount = 3
+
+// KeyMoment is a turning point of a reported game.
+type KeyMoment struct {
+	Ply            int    `json:"ply"`
+	San            string `json:"san"`
+	Side           string `json:"side"`
+	FenBefore      string `json:"fen_before"`
+	Fen            string `json:"fen"` // after the move
+	Classification string `json:"classification"`
+	BestMove       string `json:"best_move"`
+	Loss           int    `json:"centipawn_loss"`
+	Explanation    string `json:"explanation"`
+}
+
+// GameReportResponse is the post-mortem of a stored game.
+type GameReportResponse struct {
+	GameID string `json:"game_id"`
+	// Result is set once the game is over.
+	Result string `json:"result,omitempty"`
+	// ECO is set when the opening is in the server's opening table; Opening is otherwise
+	// the coach's name for it.
+	ECO         string              `json:"eco,omitempty"`
+	Opening     string              `json:"opening"`
+	White       analysis.SideReport `json:"white"`
+	Black       analysis.SideReport `json:"black"`
+	KeyMoments  []KeyMoment         `json:"key_moments"`
+	StudyTopics []string            `json:"study_topics"`
+	Summary     string              `json:"summary"`
+	Meta        *ResponseMeta       `json:"meta,omitempty"`
+}
+
+// MaxProgressReportGames caps how many of a pupil's most recent games /profile/report
+// analyzes.
+const MaxProgressReportGames = MaxStudyPlanGames
+
+// GameProgress is one game in a progress report's accuracy trend.
+type GameProgress struct {
+	GameID   string    `json:"game_id"`
+	PlayedAt time.Time `json:"played_at"`
+	Side     string    `json:"side"`
+	Opening  string    `json:"opening,omitempty"`
+	// Outcome is "win", "loss" or "draw" for the pupil, and empty while the game goes on.
+	Outcome     string  `json:"outcome,omitempty"`
+	Accuracy    float64 `json:"accuracy"`
+	AverageLoss float64 `json:"average_centipawn_loss"`
+}
+
+// PhaseBlunders counts the pupil's blunders in one phase of the game.
+type PhaseBlunders struct {
+	Moves    int `json:"moves"`
+	Blunders int `json:"blunders"`
+	// Rate is blunders per 100 moves.
+	Rate float64 `json:"blunders_per_100_moves"`
+}
+
+// OpeningRecord is the pupil's score in one opening.
+type OpeningRecord struct {
+	Name   string `json:"name"`
+	Games  int    `json:"games"`
+	Wins   int    `json:"wins"`
+	Draws  int    `json:"draws"`
+	Losses int    `json:"losses"`
+}
+
+// ProgressReportResponse sums up a pupil's progress across their stored games.
+type ProgressReportResponse struct {
+	UserID string `json:"user_id"`
+	// EstimatedRating comes from the pupil's profile, once one has been built.
+	EstimatedRating int `json:"estimated_rating,omitempty"`
+	GamesAnalyzed   int `json:"games_analyzed"`
+	// AccuracyTrend lists the analyzed games oldest first.
+	AccuracyTrend []GameProgress `json:"accuracy_trend"`
+	// AccuracyChange is the average accuracy of the later half of the games minus that of
+	// the earlier half, so a positive change means the pupil is improving.
+	AccuracyChange float64                  `json:"accuracy_change"`
+	BlunderRates   map[string]PhaseBlunders `json:"blunder_rate_by_phase"`
+	// Openings is sorted by games played, most first.
+	Openings  []OpeningRecord `json:"favorite_openings"`
+	Narrative string          `json:"narrative"`
+	Plan      []string        `json:"improvement_plan"`
+	Meta      *ResponseMeta   `json:"meta,omitempty"`
+}
+
+// DefaultCriticalSwing is the centipawn change that makes a move a critical moment on the
+// evaluation graph when the request does not set its own threshold.
+const DefaultCriticalSwing = 150
+
+// EvalGraphResponse is White's evaluation after every move of a stored game, for plotting,
+// with the moves that swung it by more than Threshold centipawns.
+type EvalGraphResponse struct {
+	GameID          string                    `json:"game_id"`
+	Threshold       int                       `json:"threshold"`
+	Points          []analysis.EvalPoint      `json:"points"`
+	CriticalMoments []analysis.CriticalMoment `json:"critical_moments"`
+}
+
+type AnalyzePGNResponse struct {
+	Tags       map[string]string `json:"tags"`
+	InitialFen string            `json:"initial_fen"`
+	Result     string            `json:"result,omitempty"`
+	Summary    string            `json:"summary"`
+	Moves      []ReviewedMove    `json:"moves"`
+	Meta       *ResponseMeta     `json:"meta,omitempty"`
+}
+
+// Analysis jobs search deeper than in-request analysis by default, and may cover games
+// too long for one request.
+const (
+	DefaultJobDepth = DefaultSearchDepth
+	MaxJobPlies     = 1000
+)
+
+// AnalysisJobRequest queues a full-game analysis of a stored game (game_id), a PGN game
+// (pgn), or a move history from initial_fen, the standard start by default. The finished
+// job's report is posted to callback_url when one is given.
+type AnalysisJobRequest struct {
+	GameID      string   `json:"game_id,omitempty"`
+	Pgn         string   `json:"pgn,omitempty"`
+	InitialFen  string   `json:"initial_fen,omitempty"`
+	MoveHistory []string `json:"move_history,omitempty"`
+	Depth       int      `json:"depth"`
+	CallbackURL string   `json:"callback_url,omitempty"`
+}
+
+func (r *AnalysisJobRequest) Validate() error {
+	var p problems
+	switch {
+	case strings.TrimSpace(r.Pgn) == "" && len(r.MoveHistory) == 0 && r.GameID != "":
+		p.add(errors.New("Game has no moves to analyse"))
+	case strings.TrimSpace(r.Pgn) == "" && len(r.MoveHistory) == 0:
+		p.add(errors.New("Request must contain a stored game (game_id field), a PGN game (pgn field) or moves (move_history field)"))
+	case strings.TrimSpace(r.Pgn) != "" && len(r.MoveHistory) > 0:
+		p.add(errors.New("Request must contain either a PGN game or moves, not both"))
+	}
+	if len(r.MoveHistory) > MaxJobPlies {
+		p.addf("move_history", "Analysis jobs cover games of at most %d plies", MaxJobPlies)
+	}
+	p.fen("initial_fen", r.InitialFen, "")
+	p.moves("move_history", r.MoveHistory)
+	if r.Depth < 0 || r.Depth > MaxSearchDepth {
+		p.addf("depth", "depth must be between 1 and %d", MaxSearchDepth)
+	}
+	if r.Depth == 0 {
+		r.Depth = DefaultJobDepth
+	}
+	if r.CallbackURL != "" {
+		if err := webhook.ValidURL(r.CallbackURL); err != nil {
+			p.addf("callback_url", "%v", err)
+		}
+	}
+	return p.err()
+}
+
+// AnalysisJob reports an analysis job's progress: Analyzed of Plies moves are scored so
+// far, and Moves holds them. Error says why a failed job failed. It is also the body of
+// the job's callback.
+type AnalysisJob struct {
+	ID       string                  `json:"job_id"`
+	Status   string                  `json:"status"`
+	GameID   string                  `json:"game_id,omitempty"`
+	Depth    int                     `json:"depth"`
+	Plies    int                     `json:"plies"`
+	Analyzed int                     `json:"analyzed"`
+	Progress float64                 `json:"progress"` // percent
+	Moves    []analysis.MoveAnalysis `json:"moves"`
+	Error    string                  `json:"error,omitempty"`
+	// CallbackStatus is "pending", "delivered" or "failed" for a job with a callback URL.
+	CallbackStatus string    `json:"callback_status,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// GameBound is implemented by requests that can name a stored game with game_id instead of
+// sending its position. UseGame overwrites the request's position with the game's.
+type GameBound interface {
+	BoundGameID() string
+	UseGame(initialFen, fen string, moveHistory []string)
+}
+
+// VariantBound is implemented by game-bound requests that play under the stored game's
+// variant. UseVariant overwrites the request's variant with the game's.
+type VariantBound interface {
+	UseVariant(variant string)
+}
+
+func (r *GameStateRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *PonderRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *EvaluateRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *CandidatesRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *LegalMovesRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *ValidateMoveRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *ThreatsRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *HintRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *MateHintRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *PrincipalVariationRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *ExploreRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *TeachingLineRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *DevelopmentSuggestionRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *PositionsFromHistoryRequest) UseVariant(variant string) {
+	r.Variant = variant
+}
+
+func (r *ChatMessageRequest) UseVariant(variant string) {
+	r.GameState.UseVariant(variant)
+}
+
+// PersonaBound is implemented by game-bound requests coached in the stored game's persona.
+// UsePersona overwrites the request's persona with the game's, unless the game has none.
+type PersonaBound interface {
+	UsePersona(name string)
+}
+
+func (r *GameStateRequest) UsePersona(name string) {
+	if name != "" {
+		r.Persona = name
+	}
+}
+
+func (r *PonderRequest) UsePersona(name string) {
+	if name != "" {
+		r.Persona = name
+	}
+}
+
+func (r *ChatMessageRequest) UsePersona(name string) {
+	r.GameState.UsePersona(name)
+}
+
+func (r *GameStateRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *GameStateRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.InitialFen, r.Fen, r.MoveHistory = initialFen, fen, moveHistory
+}
+
+func (r *ValidateMoveRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *ValidateMoveRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.Fen = fen
+}
+
+func (r *LegalMovesRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *LegalMovesRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.Fen = fen
+}
+
+func (r *PositionsFromHistoryRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *PositionsFromHistoryRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.InitialFen, r.MoveHistory = initialFen, moveHistory
+}
+
+func (r *TeachingLineRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *TeachingLineRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.Fen = fen
+}
+
+func (r *PrincipalVariationRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *PrincipalVariationRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.Fen = fen
+}
+
+func (r *EvaluateRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *EvaluateRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.Fen = fen
+}
+
+func (r *CandidatesRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *CandidatesRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.Fen = fen
+}
+
+func (r *ExploreRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *ExploreRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.Fen = fen
+}
+
+func (r *ThreatsRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *ThreatsRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.Fen = fen
+}
+
+func (r *DevelopmentSuggestionRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *DevelopmentSuggestionRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.Fen = fen
+}
+
+func (r *PonderRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *PonderRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.InitialFen, r.Fen, r.MoveHistory = initialFen, fen, moveHistory
+}
+
+func (r *MateHintRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *MateHintRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.Fen = fen
+}
+
+func (r *HintRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *HintRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.Fen = fen
+}
+
+func (r *ChatMessageRequest) BoundGameID() string {
+	return r.GameState.GameID
+}
+
+func (r *ChatMessageRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.GameState.UseGame(initialFen, fen, moveHistory)
+}
+
+func (r *AnalysisJobRequest) BoundGameID() string {
+	return r.GameID
+}
+
+func (r *AnalysisJobRequest) UseGame(initialFen, fen string, moveHistory []string) {
+	r.InitialFen, r.MoveHistory = initialFen, moveHistory
+}
+
+// ExplorerMove is one move masters chose from an /explorer position. The result
+// percentages are of the games that continued with it.
+type ExplorerMove struct {
+	San   string `json:"san"`
+	Uci   string `json:"uci"`
+	Games int    `json:"games"`
+	// Popularity is the percentage of the position's games that continued with the move.
+	Popularity    float64 `json:"popularity"`
+	WhiteWins     float64 `json:"white_wins"`
+	Draws         float64 `json:"draws"`
+	BlackWins     float64 `json:"black_wins"`
+	AverageRating int     `json:"average_rating,omitempty"`
+}
+
+// ExplorerResponse summarizes the master games that reached a position, most played move
+// first.
+type ExplorerResponse struct {
+	Fen         string         `json:"fen"`
+	ECO         string         `json:"eco,omitempty"`
+	OpeningName string         `json:"opening_name,omitempty"`
+	Games       int            `json:"games"`
+	WhiteWins   float64        `json:"white_wins"`
+	Draws       float64        `json:"draws"`
+	BlackWins   float64        `json:"black_wins"`
+	Moves       []ExplorerMove `json:"moves"`
+}
+
+// Puzzle is a blunder from one of the pupil's games, posed without its solution: find the
+// best move for Side in Fen.
+type Puzzle struct {
+	ID     string `json:"puzzle_id"`
+	GameID string `json:"game_id"`
+	Ply    int    `json:"ply"`
+	Fen    string `json:"fen"`
+	Side   string `json:"side"`
+	// Motif names the error pattern of the original blunder, when one was detected.
+	Motif    string    `json:"motif,omitempty"`
+	Attempts int       `json:"attempts"`
+	Solved   int       `json:"solved"`
+	DueAt    time.Time `json:"due_at"`
+}
+
+// NextPuzzleResponse carries the puzzle due now, or when none is due, the time the next
+// one will be.
+type NextPuzzleResponse struct {
+	Puzzle    *Puzzle    `json:"puzzle"`
+	NextDueAt *time.Time `json:"next_due_at,omitempty"`
+}
+
+type PuzzleAttemptRequest struct {
+	Move string `json:"move"`
+}
+
+func (r *PuzzleAttemptRequest) Validate() error {
+	var p problems
+	if r.Move == "" {
+		p.addf("move", "Request must contain a move in SAN (move field)")
+	}
+	p.move("move", r.Move)
+	return p.err()
+}
+
+type PuzzleAttemptResponse struct {
+	Correct bool `json:"correct"`
+	// Solution is the engine's move and Played the pupil's original blunder.
+	Solution string `json:"solution"`
+	Played   string `json:"played"`
+	Puzzle   Puzzle `json:"puzzle"`
+}
+
+// DailyPuzzleResponse is the puzzle of the day from the imported Lichess puzzle database.
+// The solver plays Side from Fen, reached by the opponent's LastMove.
+type DailyPuzzleResponse struct {
+	Date     string `json:"date"`
+	PuzzleID string `json:"puzzle_id"`
+	Fen      string `json:"fen"`
+	Side     string `json:"side"`
+	LastMove string `json:"last_move"`
+	// Solution alternates the solver's moves with the opponent's replies, in SAN.
+	Solution []string `json:"solution"`
+	Rating   int      `json:"rating"`
+	Themes   []string `json:"themes"`
+	GameURL  string   `json:"game_url,omitempty"`
+	// Hints are DailyPuzzleHints hints, from a gentle nudge to nearly the answer.
+	Hints []string      `json:"hints"`
+	Meta  *ResponseMeta `json:"meta,omitempty"`
+}
+
+// DailyPuzzleHints is the number of hints served with the daily puzzle.
+const DailyPuzzleHints = 3
+
+type PuzzleImportResponse struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+// LessonSummary lists a lesson without its solutions.
+type LessonSummary struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Theme   string `json:"theme"`
+	Summary string `json:"summary"`
+	Steps   int    `json:"steps"`
+}
+
+type LessonsResponse struct {
+	Lessons []LessonSummary `json:"lessons"`
+}
+
+// LessonStepRequest works through one step of a lesson. Without a move the coach
+// introduces the step; with one it checks the pupil's answer and coaches them on it.
+type LessonStepRequest struct {
+	// Step is the 1-based step of the lesson.
+	Step int `json:"step"`
+	// Move is the pupil's answer, in SAN or UCI.
+	Move string `json:"move,omitempty"`
+	// MessageHistory is the conversation about this lesson so far, most recent last.
+	MessageHistory []ChatMessage `json:"message_history"`
+}
+
+func (r *LessonStepRequest) Validate() error {
+	var p problems
+	if r.Step < 1 {
+		p.addf("step", "Request must contain the 1-based lesson step (step field)")
+	}
+	p.move("move", r.Move)
+	return p.err()
+}
+
+type LessonStepResponse struct {
+	LessonID string `json:"lesson_id"`
+	Step     int    `json:"step"`
+	Steps    int    `json:"steps"`
+	Fen      string `json:"fen"`
+	Task     string `json:"task"`
+	// Played is the pupil's move in SAN and Correct whether it solved the step. Both are
+	// unset when the request had no move.
+	Played  string `json:"played,omitempty"`
+	Correct *bool  `json:"correct,omitempty"`
+	// Solutions and Explanation are revealed once the step is solved.
+	Solutions   []string `json:"solutions,omitempty"`
+	Explanation string   `json:"explanation,omitempty"`
+	// NextStep is the step to continue with once this one is solved; it stays zero after
+	// the last step, which completes the lesson.
+	NextStep    int           `json:"next_step,omitempty"`
+	Complete    bool          `json:"complete"`
+	Response    string        `json:"response"`
+	Arrows      [][2]string   `json:"arrows"`
+	ArrowGroups *ArrowGroups  `json:"arrow_groups,omitempty"`
+	Meta        *ResponseMeta `json:"meta,omitempty"`
+}
+
+type MasterGameImportResponse struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+// MasterGameInfo describes a master game without its moves.
+type MasterGameInfo struct {
+	ID     string `json:"game_id"`
+	White  string `json:"white"`
+	Black  string `json:"black"`
+	Event  string `json:"event,omitempty"`
+	Site   string `json:"site,omitempty"`
+	Date   string `json:"date,omitempty"`
+	Result string `json:"result"`
+	Plies  int    `json:"plies"`
+}
+
+// GuessPositionResponse poses one move of a master game to guess: the master playing Side
+// moved next in Fen, reached by MovesPlayed from the starting position.
+type GuessPositionResponse struct {
+	Game MasterGameInfo `json:"game"`
+	// Ply is the 1-based ply of the move to guess.
+	Ply         int      `json:"ply"`
+	Side        string   `json:"side"`
+	Fen         string   `json:"fen"`
+	MovesPlayed []string `json:"moves_played"`
+	LastMove    string   `json:"last_move,omitempty"`
+}
+
+// GuessMoveRequest is the pupil's guess at the master's move at Ply of a master game.
+type GuessMoveRequest struct {
+	GameID string `json:"game_id"`
+	Ply    int    `json:"ply"`
+	// Move is the guess, in SAN or UCI.
+	Move string `json:"move"`
+}
+
+func (r *GuessMoveRequest) Validate() error {
+	var p problems
+	if r.GameID == "" {
+		p.addf("game_id", "Request must name the master game (game_id field)")
+	}
+	if r.Ply < 1 {
+		p.addf("ply", "Request must contain the 1-based ply to guess (ply field)")
+	}
+	if r.Move == "" {
+		p.addf("move", "Request must contain a move in SAN (move field)")
+	}
+	p.move("move", r.Move)
+	return p.err()
+}
+
+// Grades for a guess at a master's move, best first.
+const (
+	// GuessMaster is the master's own move.
+	GuessMaster = "master_move"
+	// GuessEngine is another of the engine's preferred moves, or one it rates no worse
+	// than the master's.
+	GuessEngine = "engine_move"
+	// GuessPlayable gives away little, if more than the master's move.
+	GuessPlayable = "playable"
+	GuessMiss     = "miss"
+)
+
+type GuessMoveResponse struct {
+	GameID     string `json:"game_id"`
+	Ply        int    `json:"ply"`
+	Guess      string `json:"guess"`
+	MasterMove string `json:"master_move"`
+	Grade      string `json:"grade"`
+	// Points scores the guess: 3 for the master's move down to 0 for a miss.
+	Points int `json:"points"`
+	// GuessLoss and MasterLoss are the centipawns each move gives away by the engine's
+	// estimate.
+	GuessLoss  int `json:"guess_centipawn_loss"`
+	MasterLoss int `json:"master_centipawn_loss"`
+	// EngineMoves are the engine's preferred moves in the position, best first.
+	EngineMoves []string `json:"engine_moves"`
+	// Explanation is the coach's account of the idea behind the master's move.
+	Explanation string `json:"explanation"`
+	// Next poses the master's following move for the same side; it is nil once the game
+	// has no more moves for that side.
+	Next *GuessPositionResponse `json:"next,omitempty"`
+	Meta *ResponseMeta          `json:"meta,omitempty"`
+}
+
+// MaxRepertoireLines caps the lines in one repertoire.
+const MaxRepertoireLines = 200
+
+// NewRepertoireRequest registers an opening repertoire. PGN holds one game per line, each
+// from the starting position; variations inside a game are ignored, so give each branch
+// as its own game.
+type NewRepertoireRequest struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	// Side is the side the pupil plays the repertoire with: "white" or "black".
+	Side string `json:"side"`
+	PGN  string `json:"pgn"`
+}
+
+func (r *NewRepertoireRequest) Validate() error {
+	var p problems
+	if r.UserID == "" {
+		p.addf("user_id", "Request must name the pupil (user_id field)")
+	}
+	if len(r.UserID) > MaxUserIDLength {
+		p.addf("user_id", "user_id must be at most %d characters", MaxUserIDLength)
+	}
+	if r.Side != "white" && r.Side != "black" {
+		p.addf("side", `side must be "white" or "black"`)
+	}
+	if strings.TrimSpace(r.PGN) == "" {
+		p.addf("pgn", "Request must contain the repertoire's lines in PGN (pgn field)")
+	}
+	return p.err()
+}
+
+// Repertoire sums up a stored repertoire and how well the pupil recalls it.
+type Repertoire struct {
+	ID        string     `json:"repertoire_id"`
+	UserID    string     `json:"user_id"`
+	Name      string     `json:"name"`
+	Side      string     `json:"side"`
+	Lines     [][]string `json:"lines"`
+	Positions int        `json:"positions"`
+	// Due counts the positions due for review now.
+	Due      int `json:"due"`
+	Attempts int `json:"attempts"`
+	Correct  int `json:"correct"`
+	// Accuracy is the percentage of drill answers that were correct, 0 before any.
+	Accuracy  float64   `json:"accuracy"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RepertoiresResponse struct {
+	Repertoires []Repertoire `json:"repertoires"`
+}
+
+// RepertoireDrill is one repertoire position to recall, posed without its moves: play
+// Side's repertoire move in Fen, reached by Line.
+type RepertoireDrill struct {
+	Fen      string    `json:"fen"`
+	Side     string    `json:"side"`
+	Line     []string  `json:"line"`
+	Attempts int       `json:"attempts"`
+	Correct  int       `json:"correct"`
+	DueAt    time.Time `json:"due_at"`
+}
+
+// RepertoireDrillResponse carries the position due now, or when none is due, the time the
+// next one will be.
+type RepertoireDrillResponse struct {
+	RepertoireID string           `json:"repertoire_id"`
+	Drill        *RepertoireDrill `json:"drill"`
+	NextDueAt    *time.Time       `json:"next_due_at,omitempty"`
+}
+
+// RepertoireAnswerRequest answers the drill of the repertoire position Fen.
+type RepertoireAnswerRequest struct {
+	Fen string `json:"fen"`
+	// Move is the pupil's answer, in SAN or UCI.
+	Move string `json:"move"`
+}
+
+func (r *RepertoireAnswerRequest) Validate() error {
+	var p problems
+	if r.Fen == "" {
+		p.addf("fen", "Request must contain the drilled position (fen field)")
+	}
+	p.fen("fen", r.Fen, "")
+	if r.Move == "" {
+		p.addf("move", "Request must contain a move in SAN (move field)")
+	}
+	p.move("move", r.Move)
+	return p.err()
+}
+
+type RepertoireAnswerResponse struct {
+	Correct bool   `json:"correct"`
+	Played  string `json:"played"`
+	// Moves are the repertoire's moves in the position, in SAN.
+	Moves []string `json:"moves"`
+	// Drill is the position's updated schedule and Repertoire the updated totals.
+	Drill      RepertoireDrill `json:"drill"`
+	Repertoire Repertoire      `json:"repertoire"`
+}
+
+// Blindfold drill questions.
+const (
+	// BlindfoldPieceOn asks what stands on a square once the moves are played.
+	BlindfoldPieceOn = "piece_on"
+	// BlindfoldWhereIs asks which square a piece ends up on.
+	BlindfoldWhereIs = "where_is"
+	// BlindfoldBestMove asks for a good move in the final position.
+	BlindfoldBestMove = "best_move"
+)
+
+// BlindfoldKinds lists every blindfold question.
+var BlindfoldKinds = []string{BlindfoldPieceOn, BlindfoldWhereIs, BlindfoldBestMove}
+
+// Limits on the moves played out in a blindfold drill.
+const (
+	MinBlindfoldPlies     = 2
+	MaxBlindfoldPlies     = 24
+	DefaultBlindfoldPlies = 6
+)
+
+// BlindfoldRequest asks for a blindfold visualization drill: Plies moves from the starting
+// position, described only in words, and one question about the position they reach.
+type BlindfoldRequest struct {
+	// Plies defaults to DefaultBlindfoldPlies.
+	Plies int `json:"plies,omitempty"`
+	// Kind picks the question from BlindfoldKinds; empty picks one at random.
+	Kind string `json:"kind,omitempty"`
+}
+
+func (r *BlindfoldRequest) Validate() error {
+	var p problems
+	if r.Plies == 0 {
+		r.Plies = DefaultBlindfoldPlies
+	}
+	if r.Plies < MinBlindfoldPlies || r.Plies > MaxBlindfoldPlies {
+		p.addf("plies", "plies must be between %d and %d", MinBlindfoldPlies, MaxBlindfoldPlies)
+	}
+	if r.Kind != "" && !slices.Contains(BlindfoldKinds, r.Kind) {
+		p.addf("kind", "kind must be one of %s", strings.Join(BlindfoldKinds, ", "))
+	}
+	return p.err()
+}
+
+// BlindfoldExercise poses a drill without showing the board. The pupil answers by
+// exercise ID, which stays valid for BlindfoldTTL.
+type BlindfoldExercise struct {
+	ExerciseID string `json:"exercise_id"`
+	Kind       string `json:"kind"`
+	// Moves are the moves from the starting position in SAN, and Narration the coach's
+	// description of them in words.
+	Moves     []string      `json:"moves"`
+	Narration string        `json:"narration"`
+	Question  string        `json:"question"`
+	Meta      *ResponseMeta `json:"meta,omitempty"`
+}
+
+// BlindfoldTTL is how long a blindfold exercise can be answered.
+const BlindfoldTTL = time.Hour
+
+// BlindfoldAnswerRequest answers a blindfold drill. Depending on the question, Answer is a
+// piece such as "white knight" or "empty", a square such as "f3", or a move in SAN or UCI.
+type BlindfoldAnswerRequest struct {
+	Answer string `json:"answer"`
+}
+
+func (r *BlindfoldAnswerRequest) Validate() error {
+	var p problems
+	if strings.TrimSpace(r.Answer) == "" {
+		p.addf("answer", "Request must contain an answer (answer field)")
+	}
+	return p.err()
+}
+
+type BlindfoldAnswerResponse struct {
+	Correct bool `json:"correct"`
+	// Expected is the right answer; for a best-move question, the engine's move, though any
+	// good move is accepted.
+	Expected string `json:"expected"`
+	// Feedback explains the answer against the final position, revealed as Fen.
+	Feedback string `json:"feedback"`
+	Fen      string `json:"fen"`
+}
+
+// MaxAPIKeyNameLength bounds the name given to an API key.
+const MaxAPIKeyNameLength = 100
+
+// NewAPIKeyRequest issues an API key with the given scopes (see auth.Scopes). A key
+// without scopes may use every endpoint except the coach's and the admin ones.
+type NewAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+func (r *NewAPIKeyRequest) Validate() error {
+	var p problems
+	if strings.TrimSpace(r.Name) == "" {
+		p.addf("name", "Request must name the key (name field)")
+	}
+	if len(r.Name) > MaxAPIKeyNameLength {
+		p.addf("name", "name must be at most %d characters", MaxAPIKeyNameLength)
+	}
+	for i, scope := range r.Scopes {
+		if !slices.Contains(auth.Scopes, scope) {
+			p.addf(fmt.Sprintf("scopes[%d]", i), "scopes[%d]: scope must be one of %s", i, strings.Join(auth.Scopes, ", "))
+		}
+	}
+	return p.err()
+}
+
+// APIKey describes an issued API key without the key itself.
+type APIKey struct {
+	ID        string    `json:"key_id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewAPIKeyResponse carries a new key. Key is shown only in this response; the server
+// keeps just its hash.
+type NewAPIKeyResponse struct {
+	Key    string `json:"key"`
+	APIKey APIKey `json:"api_key"`
+}
+
+type APIKeysResponse struct {
+	Keys []APIKey `json:"keys"`
+}
+
+// QuotaResponse reports the caller's model calls today against their daily quota. Limit
+// is 0, and the rest empty, when calls are unlimited.
+type QuotaResponse struct {
+	Limit     int        `json:"limit"`
+	Used      int        `json:"used"`
+	Remaining int        `json:"remaining"`
+	ResetAt   *time.Time `json:"reset_at,omitempty"`
+}
+
+// DefaultUsageDays and MaxUsageDays bound how many days back /admin/usage sums.
+const (
+	DefaultUsageDays = 30
+	MaxUsageDays     = 366
+)
+
+// UsageTotal sums the model calls sharing a user, endpoint or model. Cost is estimated
+// from list prices.
+type UsageTotal struct {
+	Key          string  `json:"key"`
+	Calls        int     `json:"calls"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost_usd"`
+}
+
+// UsageResponse breaks down the model calls made since Since by user, endpoint and model,
+// most expensive first.
+// RuntimeResponse is a snapshot of the server's load for /admin/runtime, for looking into
+// a stall. The counts are this instance's own.
+type RuntimeResponse struct {
+	Goroutines int    `json:"goroutines"`
+	HeapBytes  uint64 `json:"heap_bytes"`
+	GCCycles   uint32 `json:"gc_cycles"`
+	// ModelCallsInFlight counts the model calls waiting on a provider.
+	ModelCallsInFlight int `json:"model_calls_in_flight"`
+	// ModelCallsQueued counts those of them waiting for a free slot of their provider.
+	ModelCallsQueued int `json:"model_calls_queued"`
+	// BackgroundTasks counts work running after its request was answered, such as game
+	// reviews, analysis jobs and callbacks.
+	BackgroundTasks int `json:"background_tasks"`
+	// AnalysisJobs counts the analysis jobs by status; queued ones are waiting for a worker.
+	AnalysisJobs map[string]int `json:"analysis_jobs"`
+	// WatchedGames and GameSessions count the games with /ws/game connections and the
+	// connections.
+	WatchedGames int `json:"watched_games"`
+	GameSessions int `json:"game_sessions"`
+}
+
+type UsageResponse struct {
+	Since      time.Time    `json:"since"`
+	Total      UsageTotal   `json:"total"`
+	ByUser     []UsageTotal `json:"by_user"`
+	ByEndpoint []UsageTotal `json:"by_endpoint"`
+	ByModel    []UsageTotal `json:"by_model"`
 }
@@ -1,26 +1,82 @@
 package utils
 
 import (
-	"fmt"
 	"strings"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
 )
 
 func PtrFloat32(f float32) *float32 {
 	return &f
 }
 
+// InferSidesFromFEN names the coach's side (the side to move) and the pupil's side in a
+// game position. A malformed FEN is reported as a *chess.FENError.
 func InferSidesFromFEN(fen string) (llmSide string, pupilSide string, err error) {
-	parts := strings.Split(fen, " ")
-	if len(parts) < 2 {
-		return "", "", fmt.Errorf("invalid FEN: not enough parts")
+	pos, err := chess.ParseFEN(fen)
+	if err != nil {
+		return "", "", err
 	}
-	turn := parts[1]
-	switch turn {
-	case "w":
+	if pos.Turn == chess.White {
 		return "White", "Black", nil // White to move, so Black was the pupil
-	case "b":
-		return "Black", "White", nil // Black to move, so White was the pupil
-	default:
-		return "", "", fmt.Errorf("invalid FEN turn field: %s", turn)
 	}
+	return "Black", "White", nil // Black to move, so White was the pupil
+}
+
+// NormalizeSAN repairs common model formatting quirks in a SAN move before it is parsed:
+// surrounding whitespace, quotes and move numbers, annotation glyphs (!, ?), castling
+// written with zeros or lowercase letters, lowercase piece letters and promotions written
+// without "=". Check and mate suffixes are preserved.
+func NormalizeSAN(san string) string {
+	s := strings.TrimSpace(san)
+	s = strings.Trim(s, "\"'`.,;:()[] ")
+
+	// Drop a leading move number such as "12." or "12...".
+	if i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }); i > 0 && s[i] == '.' {
+		s = strings.TrimLeft(s[i:], ". ")
+	}
+
+	s = strings.TrimRight(s, "!?")
+	suffix := ""
+	if strings.HasSuffix(s, "+") || strings.HasSuffix(s, "#") {
+		suffix = s[len(s)-1:]
+		s = strings.TrimRight(s[:len(s)-1], "+#!?")
+	}
+
+	switch strings.ToUpper(strings.ReplaceAll(s, "0", "O")) {
+	case "O-O", "OO":
+		return "O-O" + suffix
+	case "O-O-O", "OOO":
+		return "O-O-O" + suffix
+	}
+
+	if len(s) >= 3 {
+		switch s[0] {
+		case 'n', 'r', 'q', 'k':
+			s = strings.ToUpper(s[:1]) + s[1:]
+		case 'b':
+			// "bxc3" and "b8q" are pawn moves; "bc4" or "bb5" can only be a bishop move.
+			if s[1] >= 'a' && s[1] <= 'h' {
+				s = "B" + s[1:]
+			}
+		}
+	}
+
+	// Promotions: "e8q", "e8Q" and "e8=q" all become "e8=Q".
+	if n := len(s); n >= 3 && s[0] >= 'a' && s[0] <= 'h' {
+		last := s[n-1]
+		if strings.ContainsRune("nbrqNBRQ", rune(last)) {
+			body := strings.TrimSuffix(s[:n-1], "=")
+			if r := body[len(body)-1]; r == '1' || r == '8' {
+				s = body + "=" + strings.ToUpper(string(last))
+			}
+		}
+	}
+
+	return s + suffix
+}
+
+// IsValidSquare reports whether s names a board square from a1 to h8.
+func IsValidSquare(s string) bool {
+	return len(s) == 2 && s[0] >= 'a' && s[0] <= 'h' && s[1] >= '1' && s[1] <= '8'
 }
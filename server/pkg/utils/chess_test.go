@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"testing"
+
+	"arnavsurve/nara-chess/server/pkg/chess"
+)
+
+func TestNormalizeSAN(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"canonical", "Nf3", "Nf3"},
+		{"lowercase knight", "nf3", "Nf3"},
+		{"lowercase rook capture", "rxe1", "Rxe1"},
+		{"lowercase queen with check", "qh5+", "Qh5+"},
+		{"lowercase king", "ke2", "Ke2"},
+		{"lowercase bishop", "bc4", "Bc4"},
+		{"b-pawn capture stays a pawn", "bxc3", "bxc3"},
+		{"b-pawn promotion stays a pawn", "b8q", "b8=Q"},
+		{"castling with zeros", "0-0", "O-O"},
+		{"long castling with zeros", "0-0-0", "O-O-O"},
+		{"lowercase castling", "o-o", "O-O"},
+		{"castling without dashes", "OOO", "O-O-O"},
+		{"castling with check", "O-O-O+", "O-O-O+"},
+		{"castling with zeros and mate", "0-0#", "O-O#"},
+		{"promotion without equals", "e8q", "e8=Q"},
+		{"promotion with lowercase piece", "e8=q", "e8=Q"},
+		{"capture promotion with check", "dxc1n+", "dxc1=N+"},
+		{"annotation glyphs", "Nf3!?", "Nf3"},
+		{"annotation after check", "Qxf7#!!", "Qxf7#"},
+		{"annotation before check", "Bb5?+", "Bb5+"},
+		{"surrounding whitespace", "  e4 ", "e4"},
+		{"quoted", `"Nf3"`, "Nf3"},
+		{"trailing punctuation", "Nf3.", "Nf3"},
+		{"white move number", "12.Nf3", "Nf3"},
+		{"black move number", "12... Nf6", "Nf6"},
+		{"pawn move untouched", "e4", "e4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeSAN(tt.in); got != tt.want {
+				t.Errorf("NormalizeSAN(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// Normalized malformed moves parse as the moves the model meant.
+func TestNormalizeSANParses(t *testing.T) {
+	tests := []struct {
+		fen  string
+		in   string
+		want string
+	}{
+		{chess.StartFEN, "nf3", "g1f3"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "0-0", "e1g1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "0-0-0", "e8c8"},
+		{"8/4P3/8/8/8/8/8/k3K3 w - - 0 1", "e8q", "e7e8q"},
+	}
+	for _, tt := range tests {
+		pos, err := chess.ParseFEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := pos.ParseSAN(NormalizeSAN(tt.in))
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+		} else if m.String() != tt.want {
+			t.Errorf("%q parsed as %s, want %s", tt.in, m, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package webhook delivers signed notifications to URLs that clients register for them.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Headers of a delivery. SignatureHeader holds "sha256=" and the hex HMAC-SHA256 of the
+// body under the shared secret; receivers recompute it to check the sender.
+const (
+	EventHeader     = "X-Nara-Event"
+	SignatureHeader = "X-Nara-Signature"
+)
+
+// A delivery the receiver failed to accept is tried attempts times, pausing backoff
+// before the first retry and twice as long before each one after.
+const (
+	attempts = 3
+	backoff  = 2 * time.Second
+)
+
+// ErrPrivateAddress refuses a delivery to an address that isn't on the public internet,
+// such as loopback, a private network or the cloud metadata service: anyone may register
+// a callback URL, and it must not reach the server's own network.
+var ErrPrivateAddress = errors.New("webhook: callback address is not public")
+
+// Sender posts signed JSON notifications.
+type Sender struct {
+	secret []byte
+	http   *http.Client
+}
+
+// NewSender returns a sender that signs with secret. It only connects to public
+// addresses, checked once the callback's host has been resolved so a name pointing inward
+// is caught too, and doesn't follow redirects, which could lead anywhere.
+func NewSender(secret string) *Sender {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: publicOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &Sender{
+		secret: []byte(secret),
+		http: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// publicOnly is a net.Dialer Control refusing connections to addresses that aren't
+// public.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isPublic(addr) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which IsPrivate leaves out.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublic reports whether addr is a unicast address on the public internet.
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// Sign returns the signature header value of body under secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidURL reports why u can't receive notifications: it must be an absolute http or
+// https URL, and not name a host that is obviously private. Names resolving to private
+// addresses are only caught when a delivery is made.
+func ValidURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("callback URL must be an absolute http or https URL")
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("callback URL must be on the public internet")
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublic(addr) {
+		return errors.New("callback URL must be on the public internet")
+	}
+	return nil
+}
+
+// Post sends v as JSON to u as the named event. A receiver that can't be reached or
+// answers with a server error is retried a few times with growing pauses; any other
+// answer but 2xx fails at once.
+func (s *Sender) Post(ctx context.Context, u, event string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	signature := Sign(s.secret, body)
+
+	wait := backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := s.post(ctx, u, event, signature, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt == attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// post makes one delivery, reporting whether a failure is worth retrying.
+func (s *Sender) post(ctx context.Context, u, event, signature string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "nara-chess")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(SignatureHeader, signature)
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	text, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return resp.StatusCode >= 500, fmt.Errorf("webhook: %s: %s", resp.Status, strings.TrimSpace(string(text)))
+}